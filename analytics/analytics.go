@@ -8,14 +8,25 @@ import (
 )
 
 // RunRecord is a single recorded execution (prompt id/version, latency, tokens, success).
+// ChainName and StepName identify the chain step that ran the prompt, if any. Fingerprint is the
+// prompt's core.Prompt.Fingerprint, if known, so runs can be tied to the exact content that ran
+// (a version edited in place, or the same content under several versions).
 type RunRecord struct {
 	PromptID   string
 	Version    string
+	Fingerprint string
+	ChainName  string
+	StepName   string
 	LatencyMs  int64
 	InputTokens  int
 	OutputTokens int
 	Success    bool
 	At         time.Time
+	// Stream marks a streamed run, for which TimeToFirstTokenMs and TokensPerSecond (see
+	// executor.ExecuteResult) are recorded.
+	Stream             bool
+	TimeToFirstTokenMs int64
+	TokensPerSecond    float64
 }
 
 // Store is the interface for recording and querying prompt runs.
@@ -28,12 +39,46 @@ type Store interface {
 type Query struct {
 	PromptID   string
 	Version    string
+	Fingerprint string
+	ChainName  string
+	StepName   string
 	From       time.Time
 	To         time.Time
-	GroupBy    string // "prompt", "version", "day", "hour"
+	GroupBy    string // "prompt", "version", "fingerprint", "chain", "step", "day", "hour"
 	Limit      int
 }
 
+// matches reports whether r passes the query's field filters (time range is checked by callers).
+func (q Query) matches(r RunRecord) bool {
+	return (q.PromptID == "" || r.PromptID == q.PromptID) &&
+		(q.Version == "" || r.Version == q.Version) &&
+		(q.Fingerprint == "" || r.Fingerprint == q.Fingerprint) &&
+		(q.ChainName == "" || r.ChainName == q.ChainName) &&
+		(q.StepName == "" || r.StepName == q.StepName)
+}
+
+// groupKey returns the aggregate bucket for r. "step" keys are chain/step so equally named steps
+// in different chains stay separate.
+func groupKey(groupBy string, r RunRecord) string {
+	switch groupBy {
+	case "prompt":
+		return r.PromptID
+	case "version":
+		return r.PromptID + "@" + r.Version
+	case "fingerprint":
+		return r.Fingerprint
+	case "chain":
+		return r.ChainName
+	case "step":
+		return r.ChainName + "/" + r.StepName
+	case "day":
+		return r.At.Format("2006-01-02")
+	case "hour":
+		return r.At.Format("2006-01-02-15")
+	}
+	return "all"
+}
+
 // Aggregate is a bucketed aggregate (e.g. per prompt or per day).
 type Aggregate struct {
 	Key               string  `json:"key"`
@@ -42,6 +87,28 @@ type Aggregate struct {
 	AvgLatencyMs      float64 `json:"avg_latency_ms"`
 	TotalInputTokens  int64   `json:"total_input_tokens"`
 	TotalOutputTokens int64   `json:"total_output_tokens"`
+	// StreamRuns counts the successful streamed runs, over which AvgTimeToFirstTokenMs and
+	// AvgTokensPerSecond are averaged.
+	StreamRuns            int64   `json:"stream_runs,omitempty"`
+	AvgTimeToFirstTokenMs float64 `json:"avg_ttft_ms,omitempty"`
+	AvgTokensPerSecond    float64 `json:"avg_tokens_per_sec,omitempty"`
+}
+
+// add counts r in the aggregate.
+func (a *Aggregate) add(r RunRecord) {
+	a.Runs++
+	if r.Success {
+		a.SuccessCount++
+	}
+	a.AvgLatencyMs = (a.AvgLatencyMs*float64(a.Runs-1) + float64(r.LatencyMs)) / float64(a.Runs)
+	a.TotalInputTokens += int64(r.InputTokens)
+	a.TotalOutputTokens += int64(r.OutputTokens)
+	if r.Stream && r.Success {
+		a.StreamRuns++
+		n := float64(a.StreamRuns)
+		a.AvgTimeToFirstTokenMs = (a.AvgTimeToFirstTokenMs*(n-1) + float64(r.TimeToFirstTokenMs)) / n
+		a.AvgTokensPerSecond = (a.AvgTokensPerSecond*(n-1) + r.TokensPerSecond) / n
+	}
 }
 
 // MemoryStore is an in-memory implementation (bounded slice, no persistence).
@@ -70,7 +137,8 @@ func (m *MemoryStore) Record(ctx context.Context, r RunRecord) error {
 	return nil
 }
 
-// Query implements Store. GroupBy "prompt" groups by PromptID, "version" by PromptID+Version, "day" by date.
+// Query implements Store. GroupBy "prompt" groups by PromptID, "version" by PromptID+Version,
+// "fingerprint" by Fingerprint, "chain" by ChainName, "step" by ChainName+StepName, "day" by date.
 func (m *MemoryStore) Query(ctx context.Context, q Query) ([]Aggregate, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -79,10 +147,7 @@ func (m *MemoryStore) Query(ctx context.Context, q Query) ([]Aggregate, error) {
 	}
 	agg := make(map[string]*Aggregate)
 	for _, r := range m.records {
-		if q.PromptID != "" && r.PromptID != q.PromptID {
-			continue
-		}
-		if q.Version != "" && r.Version != q.Version {
+		if !q.matches(r) {
 			continue
 		}
 		if !q.From.IsZero() && r.At.Before(q.From) {
@@ -91,30 +156,11 @@ func (m *MemoryStore) Query(ctx context.Context, q Query) ([]Aggregate, error) {
 		if !q.To.IsZero() && r.At.After(q.To) {
 			continue
 		}
-		var k string
-		switch q.GroupBy {
-		case "prompt":
-			k = r.PromptID
-		case "version":
-			k = r.PromptID + "@" + r.Version
-		case "day":
-			k = r.At.Format("2006-01-02")
-		case "hour":
-			k = r.At.Format("2006-01-02-15")
-		default:
-			k = "all"
-		}
+		k := groupKey(q.GroupBy, r)
 		if agg[k] == nil {
 			agg[k] = &Aggregate{Key: k}
 		}
-		a := agg[k]
-		a.Runs++
-		if r.Success {
-			a.SuccessCount++
-		}
-		a.AvgLatencyMs = (a.AvgLatencyMs*float64(a.Runs-1) + float64(r.LatencyMs)) / float64(a.Runs)
-		a.TotalInputTokens += int64(r.InputTokens)
-		a.TotalOutputTokens += int64(r.OutputTokens)
+		agg[k].add(r)
 	}
 	out := make([]Aggregate, 0, len(agg))
 	for _, a := range agg {
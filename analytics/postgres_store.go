@@ -17,7 +17,8 @@ type PostgresStore struct {
 }
 
 // NewPostgresStore creates a store that uses the given *sql.DB (e.g. driver "postgres").
-// Table is created if it doesn't exist (id, prompt_id, version, latency_ms, input_tokens, output_tokens, success, at).
+// Table is created if it doesn't exist (id, prompt_id, version, fingerprint, chain_name, step_name, latency_ms, input_tokens, output_tokens, success, at,
+// stream, ttft_ms, tokens_per_sec).
 func NewPostgresStore(db *sql.DB, tableName string) (*PostgresStore, error) {
 	if tableName == "" {
 		tableName = defaultTableName
@@ -34,14 +35,24 @@ func (s *PostgresStore) migrate(ctx context.Context) error {
 		id BIGSERIAL PRIMARY KEY,
 		prompt_id TEXT NOT NULL,
 		version TEXT NOT NULL,
+		chain_name TEXT NOT NULL DEFAULT '',
+		step_name TEXT NOT NULL DEFAULT '',
 		latency_ms BIGINT NOT NULL DEFAULT 0,
 		input_tokens INT NOT NULL DEFAULT 0,
 		output_tokens INT NOT NULL DEFAULT 0,
 		success BOOLEAN NOT NULL DEFAULT false,
 		at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 	);
+	ALTER TABLE ` + s.tableName + ` ADD COLUMN IF NOT EXISTS chain_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE ` + s.tableName + ` ADD COLUMN IF NOT EXISTS step_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE ` + s.tableName + ` ADD COLUMN IF NOT EXISTS stream BOOLEAN NOT NULL DEFAULT false;
+	ALTER TABLE ` + s.tableName + ` ADD COLUMN IF NOT EXISTS ttft_ms BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE ` + s.tableName + ` ADD COLUMN IF NOT EXISTS tokens_per_sec DOUBLE PRECISION NOT NULL DEFAULT 0;
+	ALTER TABLE ` + s.tableName + ` ADD COLUMN IF NOT EXISTS fingerprint TEXT NOT NULL DEFAULT '';
 	CREATE INDEX IF NOT EXISTS idx_prompt_runs_prompt_version ON ` + s.tableName + ` (prompt_id, version);
-	CREATE INDEX IF NOT EXISTS idx_prompt_runs_at ON ` + s.tableName + ` (at);`
+	CREATE INDEX IF NOT EXISTS idx_prompt_runs_at ON ` + s.tableName + ` (at);
+	CREATE INDEX IF NOT EXISTS idx_prompt_runs_chain_step ON ` + s.tableName + ` (chain_name, step_name);
+	CREATE INDEX IF NOT EXISTS idx_prompt_runs_fingerprint ON ` + s.tableName + ` (fingerprint);`
 	_, err := s.db.ExecContext(ctx, q)
 	return err
 }
@@ -52,9 +63,10 @@ func (s *PostgresStore) Record(ctx context.Context, r RunRecord) error {
 		r.At = time.Now()
 	}
 	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO `+s.tableName+` (prompt_id, version, latency_ms, input_tokens, output_tokens, success, at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		r.PromptID, r.Version, r.LatencyMs, r.InputTokens, r.OutputTokens, r.Success, r.At)
+		`INSERT INTO `+s.tableName+` (prompt_id, version, chain_name, step_name, latency_ms, input_tokens, output_tokens, success, at, stream, ttft_ms, tokens_per_sec, fingerprint)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		r.PromptID, r.Version, r.ChainName, r.StepName, r.LatencyMs, r.InputTokens, r.OutputTokens, r.Success, r.At,
+		r.Stream, r.TimeToFirstTokenMs, r.TokensPerSecond, r.Fingerprint)
 	return err
 }
 
@@ -73,6 +85,21 @@ func (s *PostgresStore) Query(ctx context.Context, q Query) ([]Aggregate, error)
 		where += fmt.Sprintf(" AND version = $%d", n)
 		n++
 	}
+	if q.Fingerprint != "" {
+		args = append(args, q.Fingerprint)
+		where += fmt.Sprintf(" AND fingerprint = $%d", n)
+		n++
+	}
+	if q.ChainName != "" {
+		args = append(args, q.ChainName)
+		where += fmt.Sprintf(" AND chain_name = $%d", n)
+		n++
+	}
+	if q.StepName != "" {
+		args = append(args, q.StepName)
+		where += fmt.Sprintf(" AND step_name = $%d", n)
+		n++
+	}
 	if !q.From.IsZero() {
 		args = append(args, q.From)
 		where += fmt.Sprintf(" AND at >= $%d", n)
@@ -90,6 +117,12 @@ func (s *PostgresStore) Query(ctx context.Context, q Query) ([]Aggregate, error)
 		groupCol = "prompt_id"
 	case "version":
 		groupCol = "prompt_id || '@' || version"
+	case "fingerprint":
+		groupCol = "fingerprint"
+	case "chain":
+		groupCol = "chain_name"
+	case "step":
+		groupCol = "chain_name || '/' || step_name"
 	case "day":
 		groupCol = "date_trunc('day', at)::date::text"
 	case "hour":
@@ -107,7 +140,10 @@ func (s *PostgresStore) Query(ctx context.Context, q Query) ([]Aggregate, error)
 		COUNT(*) FILTER (WHERE success)::bigint AS success_count,
 		COALESCE(AVG(latency_ms) FILTER (WHERE success), 0) AS avg_latency_ms,
 		COALESCE(SUM(input_tokens), 0)::bigint AS total_input_tokens,
-		COALESCE(SUM(output_tokens), 0)::bigint AS total_output_tokens
+		COALESCE(SUM(output_tokens), 0)::bigint AS total_output_tokens,
+		COUNT(*) FILTER (WHERE stream AND success)::bigint AS stream_runs,
+		COALESCE(AVG(ttft_ms) FILTER (WHERE stream AND success), 0) AS avg_ttft_ms,
+		COALESCE(AVG(tokens_per_sec) FILTER (WHERE stream AND success), 0) AS avg_tokens_per_sec
 		FROM ` + s.tableName + `
 		WHERE ` + where + `
 		GROUP BY ` + groupCol + `
@@ -123,7 +159,8 @@ func (s *PostgresStore) Query(ctx context.Context, q Query) ([]Aggregate, error)
 	for rows.Next() {
 		var a Aggregate
 		var k sql.NullString
-		if err := rows.Scan(&k, &a.Runs, &a.SuccessCount, &a.AvgLatencyMs, &a.TotalInputTokens, &a.TotalOutputTokens); err != nil {
+		if err := rows.Scan(&k, &a.Runs, &a.SuccessCount, &a.AvgLatencyMs, &a.TotalInputTokens, &a.TotalOutputTokens,
+			&a.StreamRuns, &a.AvgTimeToFirstTokenMs, &a.AvgTokensPerSecond); err != nil {
 			return nil, err
 		}
 		if k.Valid {
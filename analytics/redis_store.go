@@ -29,11 +29,17 @@ func NewRedisStore(client redis.UniversalClient, key string) *RedisStore {
 type redisRecord struct {
 	PromptID      string `json:"prompt_id"`
 	Version       string `json:"version"`
+	Fingerprint   string `json:"fingerprint,omitempty"`
+	ChainName     string `json:"chain_name,omitempty"`
+	StepName      string `json:"step_name,omitempty"`
 	LatencyMs     int64  `json:"latency_ms"`
 	InputTokens   int    `json:"input_tokens"`
 	OutputTokens  int    `json:"output_tokens"`
 	Success       bool   `json:"success"`
 	At            string `json:"at"` // RFC3339
+	Stream             bool    `json:"stream,omitempty"`
+	TimeToFirstTokenMs int64   `json:"ttft_ms,omitempty"`
+	TokensPerSecond    float64 `json:"tokens_per_sec,omitempty"`
 }
 
 // Record implements Store.
@@ -45,11 +51,17 @@ func (r *RedisStore) Record(ctx context.Context, rec RunRecord) error {
 	payload := redisRecord{
 		PromptID:     rec.PromptID,
 		Version:      rec.Version,
+		Fingerprint:  rec.Fingerprint,
+		ChainName:    rec.ChainName,
+		StepName:     rec.StepName,
 		LatencyMs:    rec.LatencyMs,
 		InputTokens:  rec.InputTokens,
 		OutputTokens: rec.OutputTokens,
 		Success:      rec.Success,
 		At:           rec.At.Format(time.RFC3339),
+		Stream:             rec.Stream,
+		TimeToFirstTokenMs: rec.TimeToFirstTokenMs,
+		TokensPerSecond:    rec.TokensPerSecond,
 	}
 	raw, err := json.Marshal(payload)
 	if err != nil {
@@ -89,11 +101,17 @@ func (r *RedisStore) Query(ctx context.Context, q Query) ([]Aggregate, error) {
 			records = append(records, RunRecord{
 				PromptID:     rr.PromptID,
 				Version:      rr.Version,
+				Fingerprint:  rr.Fingerprint,
+				ChainName:    rr.ChainName,
+				StepName:     rr.StepName,
 				LatencyMs:    rr.LatencyMs,
 				InputTokens:  rr.InputTokens,
 				OutputTokens: rr.OutputTokens,
 				Success:      rr.Success,
 				At:           at,
+				Stream:             rr.Stream,
+				TimeToFirstTokenMs: rr.TimeToFirstTokenMs,
+				TokensPerSecond:    rr.TokensPerSecond,
 			})
 		}
 		if len(vals) < batch {
@@ -103,36 +121,14 @@ func (r *RedisStore) Query(ctx context.Context, q Query) ([]Aggregate, error) {
 	// Filter and aggregate (same logic as MemoryStore)
 	agg := make(map[string]*Aggregate)
 	for _, rec := range records {
-		if q.PromptID != "" && rec.PromptID != q.PromptID {
+		if !q.matches(rec) {
 			continue
 		}
-		if q.Version != "" && rec.Version != q.Version {
-			continue
-		}
-		var k string
-		switch q.GroupBy {
-		case "prompt":
-			k = rec.PromptID
-		case "version":
-			k = rec.PromptID + "@" + rec.Version
-		case "day":
-			k = rec.At.Format("2006-01-02")
-		case "hour":
-			k = rec.At.Format("2006-01-02-15")
-		default:
-			k = "all"
-		}
+		k := groupKey(q.GroupBy, rec)
 		if agg[k] == nil {
 			agg[k] = &Aggregate{Key: k}
 		}
-		a := agg[k]
-		a.Runs++
-		if rec.Success {
-			a.SuccessCount++
-		}
-		a.AvgLatencyMs = (a.AvgLatencyMs*float64(a.Runs-1) + float64(rec.LatencyMs)) / float64(a.Runs)
-		a.TotalInputTokens += int64(rec.InputTokens)
-		a.TotalOutputTokens += int64(rec.OutputTokens)
+		agg[k].add(rec)
 	}
 	out := make([]Aggregate, 0, len(agg))
 	for _, a := range agg {
@@ -8,7 +8,8 @@ import (
 	"time"
 )
 
-// Server exposes Store over HTTP: POST /record, GET /aggregates.
+// Server exposes Store over HTTP: POST /record, GET /aggregates
+// (query: prompt_id, version, chain_name, step_name, group_by, from, to, limit).
 type Server struct {
 	Store Store
 	Addr  string
@@ -26,11 +27,17 @@ func NewServer(store Store, addr string) *Server {
 type recordRequest struct {
 	PromptID       string `json:"prompt_id"`
 	Version        string `json:"version"`
+	Fingerprint    string `json:"fingerprint,omitempty"`
+	ChainName      string `json:"chain_name,omitempty"`
+	StepName       string `json:"step_name,omitempty"`
 	LatencyMs      int64  `json:"latency_ms"`
 	InputTokens    int    `json:"input_tokens"`
 	OutputTokens   int    `json:"output_tokens"`
 	Success        bool   `json:"success"`
 	At             string `json:"at,omitempty"` // RFC3339
+	Stream             bool    `json:"stream,omitempty"`
+	TimeToFirstTokenMs int64   `json:"ttft_ms,omitempty"`
+	TokensPerSecond    float64 `json:"tokens_per_sec,omitempty"`
 }
 
 // aggregateResponse is the JSON response for GET /aggregates.
@@ -61,10 +68,16 @@ func (s *Server) handleRecord(w http.ResponseWriter, r *http.Request) {
 	rec := RunRecord{
 		PromptID:      req.PromptID,
 		Version:       req.Version,
+		Fingerprint:   req.Fingerprint,
+		ChainName:     req.ChainName,
+		StepName:      req.StepName,
 		LatencyMs:     req.LatencyMs,
 		InputTokens:   req.InputTokens,
 		OutputTokens:  req.OutputTokens,
 		Success:       req.Success,
+		Stream:             req.Stream,
+		TimeToFirstTokenMs: req.TimeToFirstTokenMs,
+		TokensPerSecond:    req.TokensPerSecond,
 	}
 	if req.At != "" {
 		if t, err := time.Parse(time.RFC3339, req.At); err == nil {
@@ -81,10 +94,13 @@ func (s *Server) handleRecord(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleAggregates(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	q := Query{
-		PromptID: r.URL.Query().Get("prompt_id"),
-		Version:  r.URL.Query().Get("version"),
-		GroupBy:  r.URL.Query().Get("group_by"),
-		Limit:    100,
+		PromptID:  r.URL.Query().Get("prompt_id"),
+		Version:   r.URL.Query().Get("version"),
+		Fingerprint: r.URL.Query().Get("fingerprint"),
+		ChainName: r.URL.Query().Get("chain_name"),
+		StepName:  r.URL.Query().Get("step_name"),
+		GroupBy:   r.URL.Query().Get("group_by"),
+		Limit:     100,
 	}
 	if from := r.URL.Query().Get("from"); from != "" {
 		if t, err := time.Parse(time.RFC3339, from); err == nil {
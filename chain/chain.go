@@ -14,7 +14,8 @@ import (
 
 // ChainResult holds outputs from chain steps (keyed by step name).
 type ChainResult struct {
-	outputs map[string]string
+	outputs    map[string]string
+	renderOnly map[string]bool
 }
 
 // Get returns the output of a step by name.
@@ -25,6 +26,12 @@ func (c *ChainResult) Get(step string) string {
 	return c.outputs[step]
 }
 
+// RenderOnly reports whether step's output is its rendered prompt rather than a model response,
+// because the chain has no executor or an offline one (see executor.WithOffline).
+func (c *ChainResult) RenderOnly(step string) bool {
+	return c.renderOnly[step]
+}
+
 // All returns a copy of all step outputs.
 func (c *ChainResult) All() map[string]string {
 	if c.outputs == nil {
@@ -62,6 +69,15 @@ func WithFallback(p *core.Prompt) StepOption {
 	}
 }
 
+// WithStreaming runs this step via Provider.Stream, aggregating the chunks into the step output.
+// If chunkTimeout > 0, an attempt fails when no chunk arrives within it (and is retried like any other error).
+func WithStreaming(chunkTimeout time.Duration) StepOption {
+	return func(s *stepDef) {
+		s.stream = true
+		s.chunkTimeout = chunkTimeout
+	}
+}
+
 // WithCondition runs this step only when the condition returns true (given current chain result).
 func WithCondition(cond func(ctx context.Context, result *ChainResult) bool) StepOption {
 	return func(s *stepDef) {
@@ -69,31 +85,44 @@ func WithCondition(cond func(ctx context.Context, result *ChainResult) bool) Ste
 	}
 }
 
+// StepFunc computes a step's output in code instead of with a prompt, e.g. to fetch or reshape
+// data between prompt steps. input holds the chain input and the outputs of earlier steps.
+type StepFunc func(ctx context.Context, input core.Input) (string, error)
+
 type stepDef struct {
-	name       string
-	prompt     *core.Prompt
-	maxRetries int
-	backoff    executor.BackoffFunc
-	timeout    time.Duration
-	fallback   *core.Prompt
-	condition  func(ctx context.Context, result *ChainResult) bool
+	name         string
+	prompt       *core.Prompt
+	fn           StepFunc
+	maxRetries   int
+	backoff      executor.BackoffFunc
+	timeout      time.Duration
+	fallback     *core.Prompt
+	condition    func(ctx context.Context, result *ChainResult) bool
+	contracts    []Contract
+	stream       bool
+	chunkTimeout time.Duration
 }
 
 // StepDef is a step definition for use in Parallel. Create with ChainStep.
 type StepDef struct {
-	Name       string
-	Prompt     *core.Prompt
-	MaxRetries int
-	Backoff    executor.BackoffFunc
-	Timeout    time.Duration
-	Fallback   *core.Prompt
-	Condition  func(ctx context.Context, result *ChainResult) bool
+	Name         string
+	Prompt       *core.Prompt
+	Func         StepFunc
+	MaxRetries   int
+	Backoff      executor.BackoffFunc
+	Timeout      time.Duration
+	Fallback     *core.Prompt
+	Condition    func(ctx context.Context, result *ChainResult) bool
+	Contracts    []Contract
+	Stream       bool
+	ChunkTimeout time.Duration
 }
 
 func (s StepDef) toInternal() stepDef {
 	return stepDef{
-		name: s.Name, prompt: s.Prompt, maxRetries: s.MaxRetries, backoff: s.Backoff,
-		timeout: s.Timeout, fallback: s.Fallback, condition: s.Condition,
+		name: s.Name, prompt: s.Prompt, fn: s.Func, maxRetries: s.MaxRetries, backoff: s.Backoff,
+		timeout: s.Timeout, fallback: s.Fallback, condition: s.Condition, contracts: s.Contracts,
+		stream: s.Stream, chunkTimeout: s.ChunkTimeout,
 	}
 }
 
@@ -105,9 +134,9 @@ type node struct {
 
 // Chain represents a multi-step prompt flow.
 type Chain struct {
-	name     string
-	nodes    []node
-	exec     *executor.Executor
+	name         string
+	nodes        []node
+	exec         *executor.Executor
 	defaultModel string
 }
 
@@ -122,7 +151,8 @@ func (c *Chain) WithExecutor(e *executor.Executor) *Chain {
 	return c
 }
 
-// WithDefaultModel sets the model used for each step when no model is set on the executor request.
+// WithDefaultModel sets the model used for steps whose prompt does not declare one (see
+// core.ModelConfig).
 func (c *Chain) WithDefaultModel(model string) *Chain {
 	c.defaultModel = model
 	return c
@@ -138,6 +168,18 @@ func (c *Chain) Step(name string, p *core.Prompt, opts ...StepOption) *Chain {
 	return c
 }
 
+// Func adds a sequential step whose output is computed by fn; like a prompt step's, it is stored
+// under name and passed to later steps as the input variable name. WithRetry, WithTimeout,
+// WithCondition, and WithContract apply; WithFallback and WithStreaming do not.
+func (c *Chain) Func(name string, fn StepFunc, opts ...StepOption) *Chain {
+	s := stepDef{name: name, fn: fn}
+	for _, o := range opts {
+		o(&s)
+	}
+	c.nodes = append(c.nodes, node{parallel: false, steps: []stepDef{s}})
+	return c
+}
+
 // Parallel adds a group of steps that run in parallel (same input, outputs merged).
 // Use ChainStep to build each step: Parallel(ChainStep("a", promptA), ChainStep("b", promptB)).
 func (c *Chain) Parallel(steps ...StepDef) *Chain {
@@ -160,13 +202,22 @@ func ChainStep(name string, p *core.Prompt, opts ...StepOption) StepDef {
 	}
 	return StepDef{
 		Name: s.name, Prompt: s.prompt, MaxRetries: s.maxRetries, Backoff: s.backoff,
-		Timeout: s.timeout, Fallback: s.fallback, Condition: s.condition,
+		Timeout: s.timeout, Fallback: s.fallback, Condition: s.condition, Contracts: s.contracts,
+		Stream: s.stream, ChunkTimeout: s.chunkTimeout,
 	}
 }
 
-// Execute runs the chain with the given input. If an executor is set, each step is run through the LLM; otherwise only rendering is performed.
+// FuncStep returns a StepFunc step definition for use in Parallel (see Chain.Func).
+func FuncStep(name string, fn StepFunc, opts ...StepOption) StepDef {
+	d := ChainStep(name, nil, opts...)
+	d.Func = fn
+	return d
+}
+
+// Execute runs the chain with the given input. If an executor is set, each step is run through the
+// LLM; otherwise, or if the executor is offline, only rendering is performed (see RenderOnly).
 func (c *Chain) Execute(ctx context.Context, input core.Input) (*ChainResult, error) {
-	result := &ChainResult{outputs: make(map[string]string)}
+	result := &ChainResult{outputs: make(map[string]string), renderOnly: make(map[string]bool)}
 	currentInput := make(core.Input)
 	for k, v := range input {
 		currentInput[k] = v
@@ -178,38 +229,58 @@ func (c *Chain) Execute(ctx context.Context, input core.Input) (*ChainResult, er
 				return nil, err
 			}
 			for k, v := range outputs {
-				result.outputs[k] = v
-				currentInput[k] = v
+				result.outputs[k] = v.val
+				currentInput[k] = v.val
+				if v.renderOnly {
+					result.renderOnly[k] = true
+				}
 			}
 		} else {
 			for _, s := range n.steps {
 				if s.condition != nil && !s.condition(ctx, result) {
 					continue
 				}
-				out, err := c.runStep(ctx, &s, currentInput)
+				out, renderOnly, err := c.runStep(ctx, &s, currentInput)
 				if err != nil {
 					return nil, fmt.Errorf("chain step %q: %w", s.name, err)
 				}
+				if err := c.checkContracts(ctx, &s, out, renderOnly); err != nil {
+					return nil, err
+				}
 				result.outputs[s.name] = out
 				currentInput[s.name] = out
+				if renderOnly {
+					result.renderOnly[s.name] = true
+				}
 			}
 		}
 	}
 	return result, nil
 }
 
-func (c *Chain) runStep(ctx context.Context, s *stepDef, input core.Input) (string, error) {
+// offline reports whether the chain's executor is offline (see executor.WithOffline).
+func (c *Chain) offline() bool {
+	return c.exec != nil && c.exec.Offline
+}
+
+// runStep runs one step and reports whether its output is only the rendered prompt.
+func (c *Chain) runStep(ctx context.Context, s *stepDef, input core.Input) (string, bool, error) {
 	timeout := s.timeout
 	if timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
-	if c.exec != nil {
+	if s.fn != nil {
+		out, err := s.runFunc(ctx, input)
+		return out, false, err
+	}
+	if c.exec != nil && !c.offline() {
 		req := executor.ExecuteRequest{
 			Prompt: s.prompt, Input: input, Timeout: timeout,
+			Stream: s.stream, ChunkTimeout: s.chunkTimeout,
 		}
-		if c.defaultModel != "" {
+		if config, _ := core.ModelConfigOf(s.prompt); c.defaultModel != "" && config.Model == "" {
 			req.Model = c.defaultModel
 		}
 		// Retry loop
@@ -217,7 +288,7 @@ func (c *Chain) runStep(ctx context.Context, s *stepDef, input core.Input) (stri
 		for attempt := 0; attempt <= s.maxRetries; attempt++ {
 			res, err := c.exec.Execute(ctx, req)
 			if err == nil {
-				return res.Content, nil
+				return res.Content, false, nil
 			}
 			lastErr = err
 			if attempt == s.maxRetries {
@@ -225,16 +296,16 @@ func (c *Chain) runStep(ctx context.Context, s *stepDef, input core.Input) (stri
 					req.Prompt = s.fallback
 					res, err := c.exec.Execute(ctx, req)
 					if err != nil {
-						return "", fmt.Errorf("step and fallback failed: %w", lastErr)
+						return "", false, fmt.Errorf("step and fallback failed: %w", lastErr)
 					}
-					return res.Content, nil
+					return res.Content, false, nil
 				}
-				return "", lastErr
+				return "", false, lastErr
 			}
 			if s.backoff != nil {
 				select {
 				case <-ctx.Done():
-					return "", ctx.Err()
+					return "", false, ctx.Err()
 				case <-time.After(s.backoff(attempt)):
 				}
 			}
@@ -243,18 +314,41 @@ func (c *Chain) runStep(ctx context.Context, s *stepDef, input core.Input) (stri
 	// Render only
 	rendered, err := s.prompt.Render(ctx, input)
 	if err != nil {
-		return "", err
+		return "", false, err
+	}
+	return rendered.User, true, nil
+}
+
+// runFunc runs a StepFunc step, retrying it like a prompt step.
+func (s *stepDef) runFunc(ctx context.Context, input core.Input) (string, error) {
+	for attempt := 0; ; attempt++ {
+		out, err := s.fn(ctx, input)
+		if err == nil || attempt >= s.maxRetries {
+			return out, err
+		}
+		if s.backoff != nil {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(s.backoff(attempt)):
+			}
+		}
 	}
-	return rendered.User, nil
 }
 
-func (c *Chain) runParallel(ctx context.Context, steps []stepDef, input core.Input, result *ChainResult) (map[string]string, error) {
+// stepOutput is the output of a parallel step.
+type stepOutput struct {
+	val        string
+	renderOnly bool
+}
+
+func (c *Chain) runParallel(ctx context.Context, steps []stepDef, input core.Input, result *ChainResult) (map[string]stepOutput, error) {
 	type pair struct {
 		name string
-		val  string
+		out  stepOutput
 		err  error
 	}
-	out := make(map[string]string)
+	out := make(map[string]stepOutput)
 	var wg sync.WaitGroup
 	ch := make(chan pair, len(steps))
 	for _, s := range steps {
@@ -264,8 +358,11 @@ func (c *Chain) runParallel(ctx context.Context, steps []stepDef, input core.Inp
 		wg.Add(1)
 		go func(s stepDef) {
 			defer wg.Done()
-			val, err := c.runStep(ctx, &s, input)
-			ch <- pair{s.name, val, err}
+			val, renderOnly, err := c.runStep(ctx, &s, input)
+			if err == nil {
+				err = c.checkContracts(ctx, &s, val, renderOnly)
+			}
+			ch <- pair{s.name, stepOutput{val, renderOnly}, err}
 		}(s)
 	}
 	wg.Wait()
@@ -274,7 +371,7 @@ func (c *Chain) runParallel(ctx context.Context, steps []stepDef, input core.Inp
 		if p.err != nil {
 			return nil, p.err
 		}
-		out[p.name] = p.val
+		out[p.name] = p.out
 	}
 	return out, nil
 }
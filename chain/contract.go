@@ -0,0 +1,94 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/evaluator"
+)
+
+// Contract validates a step's output before the chain proceeds to the next step.
+type Contract interface {
+	Check(ctx context.Context, output string) error
+}
+
+// ContractFunc adapts a function to Contract.
+type ContractFunc func(ctx context.Context, output string) error
+
+// Check implements Contract.
+func (f ContractFunc) Check(ctx context.Context, output string) error {
+	return f(ctx, output)
+}
+
+// ContractError reports a step whose output violated its contract. The chain stops at that step.
+type ContractError struct {
+	Step      string
+	Output    string
+	Violation error
+}
+
+func (e *ContractError) Error() string {
+	out := e.Output
+	if len(out) > 200 {
+		out = out[:200] + "..."
+	}
+	return fmt.Sprintf("chain step %q: output contract violated: %v (output: %q)", e.Step, e.Violation, out)
+}
+
+// Unwrap returns the violation.
+func (e *ContractError) Unwrap() error {
+	return e.Violation
+}
+
+// WithContract adds an output contract to the step. Contracts run in order after the step
+// (or its fallback) succeeds; the first violation fails the chain with a *ContractError. A chain
+// without an executor, or with an offline one, only renders its prompt steps; there is no model
+// output to check, so their contracts are skipped. StepFunc steps are always checked.
+func WithContract(c Contract) StepOption {
+	return func(s *stepDef) {
+		s.contracts = append(s.contracts, c)
+	}
+}
+
+// JSONSchema returns a contract requiring the output to be JSON matching schema. A surrounding
+// markdown code fence (```json ... ```) is ignored. See core.ValidateSchema for the supported
+// keywords.
+func JSONSchema(schema map[string]interface{}) Contract {
+	return ContractFunc(func(ctx context.Context, output string) error {
+		var v interface{}
+		if err := json.Unmarshal([]byte(core.StripCodeFence(output)), &v); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		return core.ValidateSchema(schema, v)
+	})
+}
+
+// EvaluatorContract returns a contract that runs ev against the output and fails unless the score passes.
+func EvaluatorContract(ev evaluator.Evaluator, expected evaluator.Expected) Contract {
+	return ContractFunc(func(ctx context.Context, output string) error {
+		score, err := ev.Evaluate(ctx, output, expected)
+		if err != nil {
+			return fmt.Errorf("evaluator: %w", err)
+		}
+		if !score.Pass {
+			return fmt.Errorf("evaluator failed (score %.2f): %s", score.Value, score.Reason)
+		}
+		return nil
+	})
+}
+
+// checkContracts runs the step's contracts against its output, unless the output is only the
+// rendered prompt (see runStep).
+func (c *Chain) checkContracts(ctx context.Context, s *stepDef, output string, renderOnly bool) error {
+	if renderOnly {
+		return nil
+	}
+	for _, contract := range s.contracts {
+		if err := contract.Check(ctx, output); err != nil {
+			return &ContractError{Step: s.name, Output: output, Violation: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom"
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/executor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContracts_RenderOnly(t *testing.T) {
+	ctx := context.Background()
+	p := loom.New("extract").WithTemplate("Return JSON for {{.q}}").Build(nil)
+	schema := JSONSchema(map[string]interface{}{"type": "object"})
+	notJSON := func(ctx context.Context, input core.Input) (string, error) { return "not JSON", nil }
+
+	for name, c := range map[string]*Chain{
+		"no executor": NewChain("c"),
+		"offline":     NewChain("c").WithExecutor(executor.New(nil, executor.WithOffline(true))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := c.Step("a", p, WithContract(schema)).
+				Parallel(ChainStep("b", p, WithContract(schema))).
+				Execute(ctx, core.Input{"q": "y"})
+			require.NoError(t, err, "rendered prompts are not checked")
+			assert.Equal(t, "Return JSON for y", res.Get("a"))
+			assert.True(t, res.RenderOnly("b"))
+
+			_, err = c.Func("f", notJSON, WithContract(schema)).Execute(ctx, core.Input{"q": "y"})
+			var cerr *ContractError
+			require.ErrorAs(t, err, &cerr, "StepFunc outputs are always checked")
+			assert.Equal(t, "f", cerr.Step)
+		})
+	}
+}
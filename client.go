@@ -0,0 +1,196 @@
+package loom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/template"
+)
+
+// Client serves prompts from a registry to application code. Handles returned by Prompt follow
+// the production version of their id: writes made through the client's registry are applied at
+// once, and other processes' writes too when the backend supports watching (e.g. Redis).
+type Client struct {
+	reg    *registry.NotifyingRegistry
+	engine *template.Engine
+	warmUp func(ctx context.Context, h *Handle) error
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	handles map[string]*Handle
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithEngine sets the template engine for prompts served by the client (default: DefaultEngine).
+func WithEngine(eng *template.Engine) ClientOption {
+	return func(c *Client) {
+		c.engine = eng
+	}
+}
+
+// WithWarmUp sets a function Preload calls for each prompt it loads, e.g. to send a dry-run
+// request that opens provider connections and primes the provider's prompt cache:
+//
+//	loom.WithWarmUp(func(ctx context.Context, h *loom.Handle) error {
+//		_, err := exec.Execute(ctx, executor.ExecuteRequest{Prompt: h.Prompt(), Input: sampleInputs[h.ID()], MaxTokens: 1})
+//		return err
+//	})
+func WithWarmUp(fn func(ctx context.Context, h *Handle) error) ClientOption {
+	return func(c *Client) {
+		c.warmUp = fn
+	}
+}
+
+// NewClient returns a client for reg. If reg is not already a *registry.NotifyingRegistry it is
+// wrapped in one; use Registry for writes so that handles see them immediately. Call Close to stop
+// watching the backend.
+func NewClient(reg registry.Registry, opts ...ClientOption) *Client {
+	n, ok := reg.(*registry.NotifyingRegistry)
+	if !ok {
+		n = registry.NewNotifyingRegistry(reg)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{reg: n, engine: defaultEngine, cancel: cancel, handles: make(map[string]*Handle)}
+	for _, o := range opts {
+		o(c)
+	}
+	// Watch returns at once if the backend cannot report other processes' writes.
+	go func() { _ = n.Watch(ctx) }()
+	return c
+}
+
+// Registry returns the client's registry.
+func (c *Client) Registry() *registry.NotifyingRegistry {
+	return c.reg
+}
+
+// Handle is a live reference to the production version of a prompt id. It is safe for concurrent use.
+type Handle struct {
+	id      string
+	current atomic.Pointer[core.Prompt]
+	cancel  func()
+}
+
+// Prompt returns the handle for id, loading its production version on first use.
+func (c *Client) Prompt(ctx context.Context, id string) (*Handle, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h, ok := c.handles[id]; ok {
+		return h, nil
+	}
+	h := &Handle{id: id}
+	// Subscribe before loading so a change between the two is not missed. If the production
+	// version goes away, the handle keeps serving the last one it had.
+	h.cancel = c.reg.OnChange(id, func(p *core.Prompt) {
+		if p != nil {
+			h.current.Store(c.compile(p))
+		}
+	})
+	p, err := c.reg.GetProduction(ctx, id)
+	if err != nil {
+		h.cancel()
+		return nil, err
+	}
+	h.current.CompareAndSwap(nil, c.compile(p))
+	c.handles[id] = h
+	return h, nil
+}
+
+// Preload loads the production version of each id (every id with one if none are given), parses
+// its templates, and runs the WithWarmUp function, so the first requests after startup do not pay
+// for them. Errors for one id do not stop the others; they are joined in the returned error.
+func (c *Client) Preload(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		var err error
+		if ids, err = c.productionIDs(ctx); err != nil {
+			return fmt.Errorf("preload: %w", err)
+		}
+	}
+	var errs []error
+	for _, id := range ids {
+		if err := c.preload(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("preload %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Client) preload(ctx context.Context, id string) error {
+	h, err := c.Prompt(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := c.engine.Compile(h.Prompt()); err != nil {
+		return err
+	}
+	if c.warmUp != nil {
+		return c.warmUp(ctx, h)
+	}
+	return nil
+}
+
+// productionIDs returns every id with a production version.
+func (c *Client) productionIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	seen := make(map[string]bool)
+	filter := registry.Filter{Stage: registry.StageProduction, Limit: 1000}
+	for {
+		page, next, err := registry.ListPage(ctx, c.reg, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page {
+			if !seen[p.ID] {
+				seen[p.ID] = true
+				ids = append(ids, p.ID)
+			}
+		}
+		if next == "" {
+			return ids, nil
+		}
+		filter.Cursor = next
+	}
+}
+
+// compile returns a copy of p rendered by the client's engine, with its templates parsed so that
+// a new production version is ready before its first render.
+func (c *Client) compile(p *core.Prompt) *core.Prompt {
+	cp := p.Copy()
+	cp.SetRenderer(c.engine)
+	_ = c.engine.Compile(cp) // syntax errors are reported by Render
+	return cp
+}
+
+// Close stops watching for changes and detaches all handles, which keep serving their last prompt.
+func (c *Client) Close() error {
+	c.cancel()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, h := range c.handles {
+		h.cancel()
+		delete(c.handles, id)
+	}
+	return nil
+}
+
+// ID returns the prompt id the handle follows.
+func (h *Handle) ID() string {
+	return h.id
+}
+
+// Prompt returns the current production version. Callers must not modify it.
+func (h *Handle) Prompt() *core.Prompt {
+	return h.current.Load()
+}
+
+// Render renders the current production version with input.
+func (h *Handle) Render(ctx context.Context, input Input) (*Rendered, error) {
+	return h.current.Load().Render(ctx, input)
+}
@@ -2,17 +2,17 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"flag"
 	"log"
 	"os"
 
 	"github.com/klejdi94/loom/analytics"
-	"github.com/redis/go-redis/v9"
-	_ "github.com/lib/pq"
+	"github.com/klejdi94/loom/config"
 )
 
 func main() {
+	configPath := flag.String("config", "", "YAML config file (its analytics section; see package config); flags set explicitly override it")
 	addr := flag.String("addr", ":8080", "Listen address")
 	storeKind := flag.String("store", "memory", "Store: memory, postgres, redis")
 	maxRecords := flag.Int("max", 100000, "Max in-memory records when store=memory (0 = unbounded)")
@@ -22,46 +22,48 @@ func main() {
 	pgTable := flag.String("table", "prompt_runs", "Postgres table name when store=postgres")
 	flag.Parse()
 
-	if v := os.Getenv("ANALYTICS_DSN"); v != "" && *dsn == "" {
-		*dsn = v
+	cfg := &config.Config{}
+	// Without -config every flag (default or not) describes the store; with it, only those set explicitly.
+	visit := flag.VisitAll
+	if *configPath != "" {
+		var err error
+		if cfg, err = config.Load(*configPath); err != nil {
+			log.Fatal(err)
+		}
+		visit = flag.Visit
+	}
+	visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "store":
+			cfg.Analytics.Store = *storeKind
+		case "max":
+			cfg.Analytics.Max = *maxRecords
+		case "dsn":
+			cfg.Analytics.DSN = *dsn
+		case "redis":
+			cfg.Analytics.Redis = *redisAddr
+		case "redis-key":
+			cfg.Analytics.Key = *redisKey
+		case "table":
+			cfg.Analytics.Table = *pgTable
+		}
+	})
+	if v := os.Getenv("ANALYTICS_DSN"); v != "" && cfg.Analytics.DSN == "" {
+		cfg.Analytics.DSN = v
 	}
-	if v := os.Getenv("ANALYTICS_REDIS"); v != "" && *redisAddr == "" {
-		*redisAddr = v
+	if v := os.Getenv("ANALYTICS_REDIS"); v != "" && cfg.Analytics.Redis == "" {
+		cfg.Analytics.Redis = v
 	}
 
-	var store analytics.Store
-	switch *storeKind {
-	case "memory":
-		store = analytics.NewMemoryStore(*maxRecords)
-	case "postgres":
-		if *dsn == "" {
-			log.Fatal("postgres store requires -dsn or ANALYTICS_DSN")
-		}
-		db, err := openPostgres(*dsn)
-		if err != nil {
-			log.Fatalf("postgres: %v", err)
-		}
-		defer db.Close()
-		pg, err := analytics.NewPostgresStore(db, *pgTable)
-		if err != nil {
-			log.Fatalf("postgres store: %v", err)
-		}
-		store = pg
-	case "redis":
-		if *redisAddr == "" {
-			log.Fatal("redis store requires -redis or ANALYTICS_REDIS")
-		}
-		rdb := redis.NewClient(&redis.Options{Addr: *redisAddr})
-		store = analytics.NewRedisStore(rdb, *redisKey)
-	default:
-		log.Fatalf("unknown store: %s", *storeKind)
+	if cfg.Analytics.Store == "" {
+		cfg.Analytics.Store = "memory"
+	}
+	store, err := cfg.Analytics.Open(context.Background())
+	if err != nil {
+		log.Fatalf("%s store: %v", cfg.Analytics.Store, err)
 	}
 
 	srv := analytics.NewServer(store, *addr)
-	log.Printf("analytics server listening on %s (store=%s)", *addr, *storeKind)
+	log.Printf("analytics server listening on %s (store=%s)", *addr, cfg.Analytics.Store)
 	log.Fatal(srv.ListenAndServe())
 }
-
-func openPostgres(dsn string) (*sql.DB, error) {
-	return sql.Open("postgres", dsn)
-}
@@ -1,13 +1,17 @@
 // Command dashboard serves a simple UI that calls the analytics API and shows charts.
+// With -registry it also lists prompt read counts from a loom-server started with -track-usage.
 package main
 
 import (
 	"embed"
+	"encoding/json"
 	"flag"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+
+	"github.com/klejdi94/loom/registry"
 )
 
 //go:embed static
@@ -16,6 +20,8 @@ var staticFS embed.FS
 func main() {
 	addr := flag.String("addr", ":8081", "Listen address for dashboard")
 	apiBase := flag.String("api", "http://localhost:8080", "Analytics API base URL (or DASHBOARD_API env)")
+	registryURL := flag.String("registry", os.Getenv("DASHBOARD_REGISTRY"), "Registry server URL for prompt usage (or DASHBOARD_REGISTRY env); usage card hidden if empty")
+	apiKey := flag.String("api-key", os.Getenv("LOOM_API_KEY"), "API key for -registry (or LOOM_API_KEY env)")
 	flag.Parse()
 
 	if v := os.Getenv("DASHBOARD_API"); v != "" && *apiBase == "http://localhost:8080" {
@@ -34,6 +40,20 @@ func main() {
 		body := bytesReplace(index, []byte("__API_BASE__"), []byte(*apiBase))
 		w.Write(body)
 	})
+	// Usage is fetched server-side so the browser needs neither the API key nor CORS on the registry.
+	mux.HandleFunc("GET /usage", func(w http.ResponseWriter, r *http.Request) {
+		if *registryURL == "" {
+			http.NotFound(w, r)
+			return
+		}
+		usage, err := registry.NewHTTPClient(*registryURL, nil).WithAPIKey(*apiKey).ListUsage(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(usage)
+	})
 
 	log.Printf("dashboard listening on %s (api=%s)", *addr, *apiBase)
 	log.Fatal(http.ListenAndServe(*addr, mux))
@@ -0,0 +1,167 @@
+// Command loom-server exposes a prompt registry over HTTP (see registry/httpserver for routes)
+// and, with -grpc-addr, over gRPC (see registry/grpcregistry).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/klejdi94/loom/config"
+	"github.com/klejdi94/loom/provider"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/grpcregistry"
+	"github.com/klejdi94/loom/registry/grpcregistry/registrypb"
+	"github.com/klejdi94/loom/registry/httpserver"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	configPath := flag.String("config", "", "YAML config file (registry, providers, middleware; see package config); flags set explicitly override it")
+	addr := flag.String("addr", ":8090", "Listen address")
+	grpcAddr := flag.String("grpc-addr", "", "gRPC listen address (e.g. :9090); disabled if empty")
+	backend := flag.String("backend", "file", "Registry backend: memory, file, postgres, redis, dynamodb")
+	regDir := flag.String("registry", ".loom", "Registry directory when backend=file")
+	dsn := flag.String("dsn", "", "PostgreSQL DSN when backend=postgres (or LOOM_DSN env)")
+	table := flag.String("table", "prompts", "Postgres table name when backend=postgres")
+	redisAddr := flag.String("redis", "", "Redis address when backend=redis (or LOOM_REDIS env)")
+	redisPrefix := flag.String("redis-prefix", "loom:prompts", "Redis key prefix when backend=redis")
+	readyProviders := flag.String("ready-providers", "", "Comma-separated providers checked by /ready: openai, anthropic, gemini, cohere, cerebras, ollama (keys from env), or names from -config (default: all of them)")
+	dynamoTable := flag.String("dynamo-table", "loom-prompts", "DynamoDB table when backend=dynamodb (AWS config from env)")
+	executeProvider := flag.String("execute-provider", "", "Provider that runs POST /prompts/{id}/execute: openai, anthropic, ... (keys from env) or a name from -config, with the -config middleware; disabled if empty")
+	trackUsage := flag.Bool("track-usage", false, "Count Get/GetProduction per prompt id (in memory), served at /usage and /prompts/{id}/usage")
+	apiKeysFile := flag.String("api-keys", "", `JSON file mapping API keys to stage scopes and limits, e.g. {"<key>": {"read": ["production"], "write": [], "requests_per_minute": 600}}; open API if empty`)
+	metrics := flag.Bool("metrics", false, "Serve Prometheus metrics at /metrics, including registry operation latency and outcomes by backend (or metrics in the -config registry)")
+	offline := flag.Bool("offline", false, "Execute routes return the rendered prompt instead of calling -execute-provider (or LOOM_OFFLINE env, or offline in -config)")
+	flag.Parse()
+
+	cfg := &config.Config{}
+	// Without -config every flag (default or not) describes the registry; with it, only those set explicitly.
+	visit := flag.VisitAll
+	if *configPath != "" {
+		var err error
+		if cfg, err = config.Load(*configPath); err != nil {
+			log.Fatal(err)
+		}
+		visit = flag.Visit
+	}
+	visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "backend":
+			cfg.Registry.Backend = *backend
+		case "registry":
+			cfg.Registry.Dir = *regDir
+		case "dsn":
+			cfg.Registry.DSN = *dsn
+		case "table":
+			cfg.Registry.Table = *table
+		case "redis":
+			cfg.Registry.Redis = *redisAddr
+		case "redis-prefix":
+			cfg.Registry.RedisPrefix = *redisPrefix
+		case "dynamo-table":
+			cfg.Registry.DynamoTable = *dynamoTable
+		case "track-usage":
+			cfg.Registry.TrackUsage = *trackUsage
+		case "metrics":
+			cfg.Registry.Metrics = *metrics
+		case "offline":
+			cfg.Offline = *offline
+		}
+	})
+	if v, _ := strconv.ParseBool(os.Getenv("LOOM_OFFLINE")); v {
+		cfg.Offline = true
+	}
+	if v := os.Getenv("LOOM_DSN"); v != "" && cfg.Registry.DSN == "" {
+		cfg.Registry.DSN = v
+	}
+	if v := os.Getenv("LOOM_REDIS"); v != "" && cfg.Registry.Redis == "" {
+		cfg.Registry.Redis = v
+	}
+	if cfg.Registry.Backend == "" {
+		cfg.Registry.Backend = "file"
+	}
+	if cfg.Registry.Backend == "http" {
+		log.Fatal("loom-server cannot use the http backend")
+	}
+
+	reg, err := cfg.Registry.Open(context.Background())
+	if err != nil {
+		log.Fatalf("%s registry: %v", cfg.Registry.Backend, err)
+	}
+
+	var apiKeys map[string]registry.Scope
+	var limits map[string]registry.Limits
+	if *apiKeysFile != "" {
+		data, err := os.ReadFile(*apiKeysFile)
+		if err != nil {
+			log.Fatalf("api keys: %v", err)
+		}
+		var keys map[string]struct {
+			registry.Scope
+			registry.Limits
+		}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			log.Fatalf("api keys: %v", err)
+		}
+		apiKeys = make(map[string]registry.Scope, len(keys))
+		limits = make(map[string]registry.Limits, len(keys))
+		for key, k := range keys {
+			apiKeys[key] = k.Scope
+			limits[key] = k.Limits
+		}
+		log.Printf("loaded %d API keys", len(apiKeys))
+	}
+
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("grpc listen: %v", err)
+		}
+		gs := grpc.NewServer()
+		registrypb.RegisterRegistryServiceServer(gs, grpcregistry.NewServer(reg).WithAPIKeys(apiKeys).WithLimits(limits))
+		log.Printf("loom gRPC server listening on %s", *grpcAddr)
+		go func() { log.Fatal(gs.Serve(lis)) }()
+	}
+
+	srv := httpserver.New(reg, *addr)
+	srv.APIKeys = apiKeys
+	srv.Limits = limits
+	if cfg.Registry.Metrics {
+		srv.Metrics = promhttp.Handler()
+	}
+	var ready []string
+	if *readyProviders != "" {
+		ready = strings.Split(*readyProviders, ",")
+	} else {
+		for name := range cfg.Providers {
+			ready = append(ready, name)
+		}
+	}
+	if len(ready) > 0 {
+		srv.Providers = make(map[string]provider.Provider)
+		for _, name := range ready {
+			name = strings.TrimSpace(name)
+			p, err := cfg.NewProvider(name)
+			if err != nil {
+				log.Fatalf("ready provider %s: %v", name, err)
+			}
+			srv.Providers[name] = p
+		}
+	}
+	if *executeProvider != "" {
+		exec, err := cfg.NewExecutor(*executeProvider)
+		if err != nil {
+			log.Fatalf("execute provider %s: %v", *executeProvider, err)
+		}
+		srv.Executor = exec
+	}
+	log.Printf("loom server listening on %s (backend=%s)", *addr, cfg.Registry.Backend)
+	log.Fatal(srv.ListenAndServe())
+}
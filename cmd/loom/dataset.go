@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klejdi94/loom/dataset"
+	"sigs.k8s.io/yaml"
+)
+
+// datasetCmd manages the evaluation datasets in store:
+//
+//	dataset put [file]               store a new version from a YAML or JSON file (default: stdin)
+//	dataset get <name> [version]     print a version (default: latest; ranges like ^1.2 work)
+//	dataset list [name]              list dataset names, or the versions of name
+//	dataset delete <name> <version>  delete a version
+func datasetCmd(ctx context.Context, store dataset.Store, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "dataset requires put|get|list|delete")
+		os.Exit(1)
+	}
+	var err error
+	switch args[0] {
+	case "put":
+		err = datasetPut(ctx, store, args[1:])
+	case "get":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "dataset get requires <name> [version]")
+			os.Exit(1)
+		}
+		version := ""
+		if len(args) >= 3 {
+			version = args[2]
+		}
+		var d *dataset.Dataset
+		if d, err = dataset.Resolve(ctx, store, args[1], version); err == nil {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(d)
+		}
+	case "list":
+		err = datasetList(ctx, store, args[1:])
+	case "delete":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "dataset delete requires <name> <version>")
+			os.Exit(1)
+		}
+		err = store.Delete(ctx, args[1], args[2])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown dataset command %q (put, get, list, delete)\n", args[0])
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dataset:", err)
+		os.Exit(1)
+	}
+}
+
+func datasetPut(ctx context.Context, store dataset.Store, args []string) error {
+	var data []byte
+	var err error
+	if len(args) > 0 && args[0] != "-" {
+		data, err = os.ReadFile(args[0])
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+	var d dataset.Dataset
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	if err := store.Put(ctx, &d); err != nil {
+		return err
+	}
+	fmt.Printf("stored %s (%d cases)\n", d.Ref(), len(d.Cases))
+	return nil
+}
+
+func datasetList(ctx context.Context, store dataset.Store, args []string) error {
+	if len(args) == 0 {
+		names, err := store.Names(ctx)
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return err
+	}
+	versions, err := store.Versions(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		d, err := store.Get(ctx, args[0], v)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%d cases\t%s\t%s\n", v, len(d.Cases), d.CreatedAt.Format("2006-01-02"), d.Description)
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/klejdi94/loom/config"
+	"github.com/klejdi94/loom/cost"
+	"github.com/klejdi94/loom/dataset"
+	"github.com/klejdi94/loom/evaluator"
+	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/provider"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/template"
+	"sigs.k8s.io/yaml"
+)
+
+// matrixFile is the YAML (or JSON) file passed to eval -matrix:
+//
+//	prompt: summarize
+//	versions: [1.0.0, ^1.1]       # exact, latest, or ranges; default: production
+//	evaluators: [contains]        # exact (default) and/or contains
+//	expected_output_tokens: 256   # used to estimate cost against -budget
+//	concurrency: 4
+//	models:
+//	  - name: gpt-4o-mini
+//	    provider: openai          # a -config provider, or openai, anthropic, gemini, cohere, cerebras, ollama (keys from env)
+//	    model: gpt-4o-mini
+//	    input_per_1k: 0.00015
+//	    output_per_1k: 0.0006
+//	dataset: support-qa@^1.0       # cases from a stored dataset (see loom dataset), before those below
+//	cases:
+//	  - name: short
+//	    input: {text: "..."}
+//	    expected: {output: "...", contains: ["..."]}
+type matrixFile struct {
+	Prompt               string        `json:"prompt"`
+	Versions             []string      `json:"versions"`
+	Evaluators           []string      `json:"evaluators"`
+	ExpectedOutputTokens int           `json:"expected_output_tokens"`
+	Concurrency          int           `json:"concurrency"`
+	Budget               float64       `json:"budget"`
+	Models               []matrixModel `json:"models"`
+	Dataset              string        `json:"dataset"`
+	Cases                []matrixCase  `json:"cases"`
+}
+
+// calibrationFile is the YAML (or JSON) file passed to eval -calibrate:
+//
+//	dataset: support-judge@^1     # labeled cases: each has actual and pass (see loom dataset)
+//	min_kappa: 0.6                # eval exits 2 if a judge's Cohen's kappa is lower
+//	concurrency: 4
+//	judges:
+//	  - name: mini-strict
+//	    provider: openai          # as in matrix files
+//	    model: gpt-4o-mini
+//	    criteria: "Must cite the refund policy."
+//	    prompt: judge-strict      # optional: judge prompt id in the registry (production version)
+type calibrationFile struct {
+	Dataset     string             `json:"dataset"`
+	MinKappa    float64            `json:"min_kappa"`
+	Concurrency int                `json:"concurrency"`
+	Judges      []calibrationJudge `json:"judges"`
+}
+
+type calibrationJudge struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Criteria string `json:"criteria"`
+	Prompt   string `json:"prompt"`
+}
+
+type matrixModel struct {
+	Name        string  `json:"name"`
+	Provider    string  `json:"provider"`
+	Model       string  `json:"model"`
+	InputPer1K  float64 `json:"input_per_1k"`
+	OutputPer1K float64 `json:"output_per_1k"`
+}
+
+type matrixCase struct {
+	Name     string                 `json:"name"`
+	Input    map[string]interface{} `json:"input"`
+	Expected struct {
+		Output   string   `json:"output"`
+		Contains []string `json:"contains"`
+	} `json:"expected"`
+}
+
+func eval(ctx context.Context, reg registry.Registry, datasets dataset.Store, cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	matrixPath := fs.String("matrix", "", "Matrix file (YAML or JSON) with prompt versions, models, and cases")
+	calibratePath := fs.String("calibrate", "", "Calibration file (YAML or JSON) with judges and a labeled dataset; reports agreement instead of running a matrix")
+	budget := fs.Float64("budget", 0, "Maximum spend in USD across the run (overrides the file's budget; 0: unlimited)")
+	format := fs.String("format", "markdown", "Report format: markdown or json")
+	out := fs.String("o", "", "Write the report to this file instead of stdout")
+	timeout := fs.Duration("timeout", 60*time.Second, "Timeout per request")
+	_ = fs.Parse(args)
+	if *calibratePath != "" {
+		calibrate(ctx, reg, datasets, cfg, *calibratePath, *format, *out, *timeout)
+		return
+	}
+	if *matrixPath == "" {
+		fmt.Fprintln(os.Stderr, "eval requires -matrix <file> or -calibrate <file>")
+		os.Exit(1)
+	}
+	m, err := loadMatrix(ctx, reg, datasets, cfg, *matrixPath, *timeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "eval:", err)
+		os.Exit(1)
+	}
+	if *budget > 0 {
+		m.Budget = cost.NewBudget(*budget)
+	}
+	report, err := m.Run(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "eval:", err)
+		os.Exit(1)
+	}
+	writeReport(report, report.Markdown, *format, *out)
+	for _, c := range report.Cells {
+		if c.Failed > 0 {
+			os.Exit(2)
+		}
+	}
+}
+
+// loadMatrix reads a matrix file and resolves its prompt versions and providers; providers are
+// looked up in cfg (with its middleware) and otherwise built from the env, and its dataset in datasets.
+// With an offline cfg no provider is built and the cases are only rendered.
+func loadMatrix(ctx context.Context, reg registry.Registry, datasets dataset.Store, cfg *config.Config, path string, timeout time.Duration) (*evaluator.Matrix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f matrixFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Prompt == "" {
+		return nil, fmt.Errorf("%s: prompt is required", path)
+	}
+	m := &evaluator.Matrix{
+		ExpectedOutputTokens: f.ExpectedOutputTokens,
+		Concurrency:          f.Concurrency,
+	}
+	if f.Budget > 0 {
+		m.Budget = cost.NewBudget(f.Budget)
+	}
+	eng := template.NewEngine()
+	versions := f.Versions
+	if len(versions) == 0 {
+		versions = []string{"production"}
+	}
+	for _, v := range versions {
+		p, err := getVersion(ctx, reg, f.Prompt, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s@%s: %w", f.Prompt, v, err)
+		}
+		p.SetRenderer(eng)
+		m.Prompts = append(m.Prompts, p)
+	}
+	execs := make(map[string]*executor.Executor)
+	for _, mm := range f.Models {
+		exec, ok := execs[mm.Provider]
+		if !ok {
+			exec, err = cfg.NewExecutor(mm.Provider, executor.WithTimeout(timeout))
+			if err != nil {
+				return nil, fmt.Errorf("model %q: %w", mm.Name, err)
+			}
+			execs[mm.Provider] = exec
+		}
+		m.Models = append(m.Models, evaluator.MatrixModel{
+			Name:      mm.Name,
+			Executor:  exec,
+			Model:     mm.Model,
+			Estimator: cost.NewEstimator(mm.Model, mm.InputPer1K, mm.OutputPer1K),
+		})
+	}
+	for _, name := range f.Evaluators {
+		switch name {
+		case "exact":
+			m.Evaluators = append(m.Evaluators, evaluator.ExactMatch{})
+		case "contains":
+			m.Evaluators = append(m.Evaluators, evaluator.ContainsAll{})
+		default:
+			return nil, fmt.Errorf("%s: unknown evaluator %q (exact, contains)", path, name)
+		}
+	}
+	if f.Dataset != "" {
+		name, version := dataset.ParseRef(f.Dataset)
+		ds, err := dataset.Resolve(ctx, datasets, name, version)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		m.Cases = ds.EvalCases()
+	}
+	for _, c := range f.Cases {
+		m.Cases = append(m.Cases, evaluator.Case{
+			Name:     c.Name,
+			Input:    c.Input,
+			Expected: evaluator.Expected{Output: c.Expected.Output, Contains: c.Expected.Contains},
+		})
+	}
+	return m, nil
+}
+
+// writeReport writes report as markdown (via md) or JSON to out, or stdout if out is empty.
+func writeReport(report interface{}, md func() string, format, out string) {
+	var body []byte
+	switch format {
+	case "markdown", "md":
+		body = []byte(md())
+	case "json":
+		body, _ = json.MarshalIndent(report, "", "  ")
+		body = append(body, '\n')
+	default:
+		fmt.Fprintln(os.Stderr, "format must be markdown|json")
+		os.Exit(1)
+	}
+	var err error
+	if out != "" {
+		err = os.WriteFile(out, body, 0644)
+	} else {
+		_, err = os.Stdout.Write(body)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// calibrate runs the judges of a calibration file over its labeled dataset and reports their
+// agreement with the labels; it exits 2 if a judge's kappa is below the file's min_kappa.
+func calibrate(ctx context.Context, reg registry.Registry, datasets dataset.Store, cfg *config.Config, path, format, out string, timeout time.Duration) {
+	if cfg.Offline {
+		fmt.Fprintln(os.Stderr, "eval -calibrate: judges need their providers, which are not called offline")
+		os.Exit(1)
+	}
+	c, minKappa, err := loadCalibration(ctx, reg, datasets, cfg, path, timeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "eval:", err)
+		os.Exit(1)
+	}
+	report, err := c.Run(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "eval:", err)
+		os.Exit(1)
+	}
+	writeReport(report, report.Markdown, format, out)
+	for _, j := range report.Judges {
+		if j.Kappa < minKappa {
+			os.Exit(2)
+		}
+	}
+}
+
+// loadCalibration reads a calibration file, its labeled dataset, and its judges' providers (as
+// loadMatrix does) and judge prompts. It also returns the file's min_kappa.
+func loadCalibration(ctx context.Context, reg registry.Registry, datasets dataset.Store, cfg *config.Config, path string, timeout time.Duration) (*evaluator.Calibration, float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	var f calibrationFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Dataset == "" {
+		return nil, 0, fmt.Errorf("%s: dataset is required", path)
+	}
+	name, version := dataset.ParseRef(f.Dataset)
+	ds, err := dataset.Resolve(ctx, datasets, name, version)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	c := &evaluator.Calibration{Cases: ds.LabeledCases(), Concurrency: f.Concurrency}
+	if len(c.Cases) == 0 {
+		return nil, 0, fmt.Errorf("%s: dataset %s has no labeled cases (cases need actual and pass)", path, ds.Ref())
+	}
+	providers := make(map[string]provider.Provider)
+	for _, cj := range f.Judges {
+		p, ok := providers[cj.Provider]
+		if !ok {
+			p, err = cfg.NewProvider(cj.Provider)
+			if err != nil {
+				return nil, 0, fmt.Errorf("judge %q: %w", cj.Name, err)
+			}
+			providers[cj.Provider] = p
+		}
+		judge := &evaluator.LLMJudge{
+			Executor: executor.New(p, executor.WithTimeout(timeout)),
+			Model:    cj.Model,
+			Criteria: cj.Criteria,
+		}
+		if cj.Prompt != "" {
+			rj, err := evaluator.NewRegistryJudge(ctx, reg, cj.Prompt, judge.Executor, nil)
+			if err != nil {
+				return nil, 0, fmt.Errorf("judge %q: %w", cj.Name, err)
+			}
+			judge.Prompt = rj.Prompt
+		}
+		label := cj.Name
+		if label == "" {
+			label = cj.Model
+		}
+		c.Judges = append(c.Judges, evaluator.CalibrationJudge{Name: label, Judge: judge})
+	}
+	return c, f.MinKappa, nil
+}
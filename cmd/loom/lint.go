@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klejdi94/loom"
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/lint"
+	"github.com/klejdi94/loom/registry"
+)
+
+// lintCmd checks prompt files (YAML or JSON documents, see loom.Document), or every prompt in the
+// registry if no paths are given, and prints the findings. It exits 2 if any finding is an error,
+// or with -strict, if there are any findings at all.
+func lintCmd(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format: text or json")
+	maxTokens := fs.Int("max-tokens", lint.DefaultMaxTokens, "Report templates longer than this many tokens")
+	disable := fs.String("disable", "", "Rules to skip (comma-separated, e.g. missing-system,long-template)")
+	strict := fs.Bool("strict", false, "Exit 2 on warnings too")
+	_ = fs.Parse(args)
+	opts := []lint.Option{lint.WithMaxTokens(*maxTokens)}
+	if *disable != "" {
+		for _, r := range strings.Split(*disable, ",") {
+			opts = append(opts, lint.Disable(strings.TrimSpace(r)))
+		}
+	}
+	linter := lint.New(opts...)
+
+	var prompts []*core.Prompt
+	var err error
+	if fs.NArg() == 0 {
+		prompts, err = registryPrompts(ctx, reg)
+	} else {
+		prompts, err = filePrompts(fs.Args())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lint:", err)
+		os.Exit(1)
+	}
+	findings := []lint.Finding{}
+	for _, p := range prompts {
+		findings = append(findings, linter.Lint(p)...)
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(findings)
+	case "text":
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		fmt.Fprintf(os.Stderr, "%d prompts, %d findings\n", len(prompts), len(findings))
+	default:
+		err = fmt.Errorf("unknown format %q (text, json)", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lint:", err)
+		os.Exit(1)
+	}
+	if lint.HasErrors(findings) || (*strict && len(findings) > 0) {
+		os.Exit(2)
+	}
+}
+
+// registryPrompts returns every prompt version in reg, archived ones excluded.
+func registryPrompts(ctx context.Context, reg registry.Registry) ([]*core.Prompt, error) {
+	var prompts []*core.Prompt
+	filter := registry.Filter{Limit: 500}
+	for {
+		page, next, err := registry.ListPage(ctx, reg, filter)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, page...)
+		if next == "" {
+			return prompts, nil
+		}
+		filter.Cursor = next
+	}
+}
+
+// filePrompts reads the prompt documents at paths; directories are searched recursively for
+// .yaml, .yml, and .json files.
+func filePrompts(paths []string) ([]*core.Prompt, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(path, func(p string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(p)) {
+			case ".yaml", ".yml", ".json":
+				if !e.IsDir() {
+					found = append(found, p)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	prompts := make([]*core.Prompt, 0, len(files))
+	for _, f := range files {
+		p, err := loom.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, p)
+	}
+	return prompts, nil
+}
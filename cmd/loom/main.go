@@ -1,32 +1,95 @@
-// Command loom is a CLI for managing prompts (list, get, store, promote, delete, tag).
+// Command loom is a CLI for managing prompts (list, get, store, promote, delete, tag, deprecate, alias, rollback),
+// inspecting their audit log (history), evaluating them (eval), checking them for mistakes (lint), and previewing
+// template edits against sample inputs (preview).
 package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"math"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/klejdi94/loom/config"
 	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/dataset"
 	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/rollback"
 )
 
 func main() {
+	configPath := flag.String("config", "", "YAML config file (registry, providers, middleware; see package config); -registry and -server override its registry")
 	regDir := flag.String("registry", ".loom", "Registry directory (file backend)")
+	server := flag.String("server", "", "Registry server URL (e.g. http://localhost:8090); overrides -registry")
+	apiKey := flag.String("api-key", os.Getenv("LOOM_API_KEY"), "API key for -server (or LOOM_API_KEY env)")
+	actor := flag.String("actor", defaultActor(), "Name recorded in the audit log for changes (or LOOM_ACTOR, USER env)")
+	datasetsDir := flag.String("datasets", "", "Evaluation dataset directory (default: _datasets in the -registry directory)")
+	namespace := flag.String("namespace", os.Getenv("LOOM_NAMESPACE"), "Registry namespace to work in (or LOOM_NAMESPACE env; default: the default namespace)")
+	offlineEnv, _ := strconv.ParseBool(os.Getenv("LOOM_OFFLINE"))
+	offline := flag.Bool("offline", offlineEnv, "Render prompts without calling providers, e.g. in CI or air-gapped environments (or LOOM_OFFLINE env, or offline in -config)")
 	flag.Parse()
 	args := flag.Args()
 	if len(args) == 0 {
 		printUsage()
 		os.Exit(1)
 	}
-	reg, err := registry.NewFileRegistry(*regDir)
+	if args[0] == "keygen" {
+		keygen()
+		return
+	}
+	cfg := &config.Config{}
+	if *configPath != "" {
+		var err error
+		if cfg, err = config.Load(*configPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *offline {
+		cfg.Offline = true
+	}
+	regSet := false
+	flag.Visit(func(f *flag.Flag) { regSet = regSet || f.Name == "registry" })
+	switch {
+	case *server != "":
+		cfg.Registry = config.RegistryConfig{Backend: "http", URL: *server, APIKey: *apiKey}
+	case regSet || *configPath == "":
+		cfg.Registry = config.RegistryConfig{Backend: "file", Dir: *regDir}
+	case cfg.Registry.APIKey == "":
+		cfg.Registry.APIKey = *apiKey
+	}
+	if *namespace != "" {
+		if err := registry.ValidateNamespace(*namespace); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	reg, err := openRegistry(context.Background(), cfg.Registry)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "registry:", err)
 		os.Exit(1)
 	}
-	ctx := context.Background()
+	ctx := registry.WithNamespace(context.Background(), *namespace)
+	if *actor != "" {
+		// Registries opened here (not -server) authorize the actor too (registry authz in -config).
+		ctx = registry.WithActor(ctx, *actor)
+		ctx = registry.WithPrincipal(ctx, *actor)
+	}
+	if *datasetsDir == "" {
+		*datasetsDir = filepath.Join(*regDir, "_datasets")
+	}
+	datasets := dataset.NewFileStore(*datasetsDir)
 	cmd := args[0]
 	rest := args[1:]
 	switch cmd {
@@ -40,10 +103,44 @@ func main() {
 		promote(ctx, reg, rest)
 	case "delete":
 		deleteCmd(ctx, reg, rest)
+	case "archive":
+		archive(ctx, reg, rest)
+	case "restore":
+		restore(ctx, reg, rest)
 	case "tag":
 		tag(ctx, reg, rest)
+	case "deprecate":
+		deprecate(ctx, reg, rest)
 	case "versions":
 		versions(ctx, reg, rest)
+	case "verify":
+		verify(ctx, reg, rest)
+	case "history":
+		history(ctx, reg, rest)
+	case "alias":
+		alias(ctx, reg, rest)
+	case "aliases":
+		aliases(ctx, reg, rest)
+	case "fork":
+		fork(ctx, reg, rest)
+	case "export":
+		export(ctx, reg, rest)
+	case "import":
+		importCmd(ctx, reg, rest)
+	case "sync":
+		syncCmd(ctx, reg, rest)
+	case "rollback":
+		rollbackCmd(ctx, reg, cfg, rest)
+	case "prune":
+		pruneCmd(ctx, reg, rest)
+	case "eval":
+		eval(ctx, reg, datasets, cfg, rest)
+	case "dataset":
+		datasetCmd(ctx, datasets, rest)
+	case "lint":
+		lintCmd(ctx, reg, rest)
+	case "preview":
+		previewCmd(ctx, reg, rest)
 	default:
 		printUsage()
 		os.Exit(1)
@@ -51,24 +148,103 @@ func main() {
 }
 
 func printUsage() {
-	fmt.Fprintf(os.Stderr, `Usage: loom [ -registry <dir> ] <command> [args]
+	fmt.Fprintf(os.Stderr, `Usage: loom [-config <file>] [ -registry <dir> | -server <url> [-api-key <key>] ] [-actor <name>] [-namespace <ns>] [-datasets <dir>] [-offline] <command> [args]
 
 Commands:
-  list                    List all prompts
-  get <id> [version]      Get prompt (default: production version)
-  store                   Store prompt from stdin (JSON)
+  list [-archived] [-q words] [-meta key=value,...] [-sort id|created_at|updated_at] [-desc] [-limit n] [-cursor c]
+                         List prompts (-archived: include archived versions; -q, -meta: search)
+  get [-resolve] [-env e] <id> [version]  Get prompt (default: production, or environment e's production version;
+                         version may be latest, @alias or a range like ^1.2); -resolve assembles it with its dependencies
+  store [-check] [-next]  Store prompt from stdin (JSON); -check fails if its Revision is stale; -next picks
+                         the next version from what changed (no version needed) and skips unchanged content
   promote <id> <version> [stage]  Promote version (stage: dev|staging|production)
-  delete <id> <version>  Delete a version
+  promote -env <e> <id> [version]  Point environment e (e.g. eu-prod) at its own production version;
+                         no version makes it follow the global one again
+  delete <id> <version>  Delete a version permanently
+  archive <id> <version> Archive a version (reversible with restore)
+  restore <id> <version> Restore an archived version
   tag <id> <version> <tag...>  Add tags
+  deprecate [-by id@version] [-reason text] [-sunset YYYY-MM-DD] [-clear] <id> <version>
+                         Mark a version deprecated (it keeps working; get and DeprecationWarner readers are
+                         warned to move to -by); -clear removes the mark
   versions <id>          List versions for an id
+  verify [id [version]]  Check stored versions against their checksums (default: every version); exits 1 on a mismatch
+  alias <id> <alias> [version]  Point an alias (e.g. stable) at a version; no version removes it
+  aliases <id>           List aliases for an id
+  fork [-version v] [-lineage] <id> <version> <new-id>
+                         Copy a version (production, latest, @alias, or a range work too) to a new id in dev
+  keygen                 Print a new ed25519 key pair for signing prompts (registry signing in -config)
+  history <id>           Show the audit log (who stored, promoted, tagged, deleted, archived) for an id
+  export [-o file]        Write every prompt version, stage, tag, and alias to a tar bundle (default: stdout)
+  import [file]          Restore a bundle written by export (default: stdin)
+  sync -to <config> [-stages production,...] [-prune] [-conflict source|destination|fail] [-every 1m]
+                         Mirror this registry into the one in another config file (once, or every interval)
+  prune [-keep n] [-older-than 90d] [-skip-stages staging,...] [-dry-run] [id...]
+                         Delete old versions (every id by default) outside the newest -keep and older than
+                         -older-than; production and aliased versions are always kept
+  rollback [-dry-run] <id>  Promote the version that was in production before the current one
+  rollback -history <id>    List the production promotions and rollbacks of <id> (time, version, actor), newest first
+  rollback -watch [-every 1m] [-bake-in 30m] [-max-error-increase 0.05] [-max-latency-increase 0.5] [-min-runs 20] [-dry-run] [id...]
+                         Roll back new production versions whose runs in the -config analytics store regress
+  eval -matrix <file> [-budget usd] [-format markdown|json] [-o file]
+                         Run cases across prompt versions and models; exits 2 if any case fails
+  eval -calibrate <file> [-format markdown|json] [-o file]
+                         Measure LLM judges against a labeled dataset (accuracy, Cohen's kappa); exits 2 below min_kappa
+  dataset put [file]     Store a new evaluation dataset version from YAML or JSON (default: stdin)
+  dataset get <name> [version]  Print a dataset version (default: latest; ranges like ^1.2 work)
+  dataset list [name]    List datasets, or the versions of one
+  dataset delete <name> <version>  Delete a dataset version
+  lint [-format text|json] [-max-tokens n] [-disable rule,...] [-strict] [path...]
+                         Check prompt files (or directories of them; default: every prompt in the registry) for
+                         undeclared or unused variables, template syntax errors, a missing system message, and
+                         overlong templates; exits 2 on errors (-strict: on any finding)
+  preview [-samples file] [-base version|file] [-format text|json] [-fail-on-change] <file | id [version]>
+                         Render a prompt file or version with sample inputs (default: its example inputs) and
+                         print each render, or its diff from -base (e.g. production); exits 2 if a sample fails
+                         to render (-fail-on-change: or if any render changed)
 
-Registry: file-based in -registry directory (default: .loom)
+Registry: file-based in -registry directory (default: .loom), a loom-server at -server, or the registry
+in -config (a YAML file that also configures eval's providers and middleware). -namespace selects
+the namespace within it; prompts in other namespaces are not visible. Datasets are files in -datasets
+(default: <registry dir>/_datasets), shared by eval matrices that reference them. With -offline, eval
+renders each case instead of calling the models and skips evaluators that need a provider.
 `)
 }
 
+// openRegistry opens the registry described by rc, partitioned by namespace (see
+// registry.NamespacedRegistry). Registry servers apply namespaces themselves.
+func openRegistry(ctx context.Context, rc config.RegistryConfig) (registry.Registry, error) {
+	reg, err := rc.Open(ctx)
+	if err != nil || rc.Backend == "http" {
+		return reg, err
+	}
+	return registry.NewNamespaced(reg), nil
+}
+
 func list(ctx context.Context, reg registry.Registry, args []string) {
-	filter := registry.Filter{Limit: 500}
-	prompts, err := reg.List(ctx, filter)
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	archived := fs.Bool("archived", false, "Include archived versions")
+	query := fs.String("q", "", "Only prompts whose name, description, or template contain these words")
+	meta := fs.String("meta", "", "Only prompts with these metadata values (comma-separated key=value)")
+	sortBy := fs.String("sort", "id", "Order by id, created_at, or updated_at")
+	desc := fs.Bool("desc", false, "Reverse the order")
+	limit := fs.Int("limit", 500, "Maximum prompts to print")
+	cursor := fs.String("cursor", "", "Continue after a previous page (printed to stderr when more remain)")
+	_ = fs.Parse(args)
+	filter := registry.Filter{Limit: *limit, IncludeArchived: *archived, Query: *query,
+		SortBy: registry.SortField(*sortBy), Descending: *desc, Cursor: *cursor}
+	if *meta != "" {
+		filter.Metadata = make(map[string]string)
+		for _, kv := range strings.Split(*meta, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "invalid -meta %q: want key=value\n", kv)
+				os.Exit(1)
+			}
+			filter.Metadata[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	prompts, next, err := registry.ListPage(ctx, reg, filter)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -76,9 +252,17 @@ func list(ctx context.Context, reg registry.Registry, args []string) {
 	for _, p := range prompts {
 		fmt.Printf("%s\t%s\t%s\n", p.ID, p.Version, p.Name)
 	}
+	if next != "" {
+		fmt.Fprintf(os.Stderr, "more results: -cursor %s\n", next)
+	}
 }
 
 func get(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	resolve := fs.Bool("resolve", false, "Assemble the prompt with its dependencies (see registry.Resolver)")
+	env := fs.String("env", "", "Get the production version for this environment (see promote -env)")
+	_ = fs.Parse(args)
+	args = fs.Args()
 	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "get requires <id> [version]")
 		os.Exit(1)
@@ -90,15 +274,24 @@ func get(ctx context.Context, reg registry.Registry, args []string) {
 	}
 	var p *core.Prompt
 	var err error
-	if version == "" {
-		p, err = reg.GetProduction(ctx, id)
+	if *env != "" && (version == "" || version == "production") {
+		p, err = registry.GetProductionFor(ctx, reg, id, *env)
 	} else {
-		p, err = reg.Get(ctx, id, version)
+		p, err = getVersion(ctx, reg, id, version)
+	}
+	if err == nil && *resolve {
+		var res *registry.Resolved
+		if res, err = registry.NewResolver(reg).GetResolved(ctx, id, p.Version); err == nil {
+			p = res.Prompt
+		}
 	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	if w := core.CheckDeprecated(p); w != nil {
+		fmt.Fprintln(os.Stderr, "warning:", w)
+	}
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
 	if err := enc.Encode(p); err != nil {
@@ -107,24 +300,78 @@ func get(ctx context.Context, reg registry.Registry, args []string) {
 	}
 }
 
+// getVersion returns id's production version if version is "" or "production", the highest
+// version for "latest", and otherwise the exact version or, if there is none, the highest
+// version in the range (e.g. "^1.2").
+func getVersion(ctx context.Context, reg registry.Registry, id, version string) (*core.Prompt, error) {
+	switch version {
+	case "", "production":
+		return reg.GetProduction(ctx, id)
+	case "latest":
+		return registry.GetLatest(ctx, reg, id)
+	}
+	p, err := reg.Get(ctx, id, version)
+	if errors.Is(err, core.ErrPromptNotFound) {
+		if rp, rerr := registry.Resolve(ctx, reg, id, version); rerr == nil || !errors.Is(rerr, core.ErrInvalidVersion) {
+			return rp, rerr
+		}
+	}
+	return p, err
+}
+
 func store(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("store", flag.ExitOnError)
+	check := fs.Bool("check", false, "Store only if the stored revision equals the input's Revision (0: must not exist)")
+	next := fs.Bool("next", false, "Store as the next version (minor for template changes, patch for metadata-only); nothing if unchanged")
+	_ = fs.Parse(args)
 	var p core.Prompt
 	if err := json.NewDecoder(os.Stdin).Decode(&p); err != nil {
 		fmt.Fprintln(os.Stderr, "decode:", err)
 		os.Exit(1)
 	}
-	if p.ID == "" || p.Version == "" {
+	if p.ID == "" || (p.Version == "" && !*next) {
 		fmt.Fprintln(os.Stderr, "prompt must have id and version")
 		os.Exit(1)
 	}
-	if err := reg.Store(ctx, &p); err != nil {
+	if *next {
+		stored, err := registry.StoreNext(ctx, reg, &p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !stored {
+			fmt.Printf("unchanged: %s@%s\n", p.ID, p.Version)
+			return
+		}
+		fmt.Printf("stored %s@%s (revision %d)\n", p.ID, p.Version, p.Revision)
+		return
+	}
+	var err error
+	if *check {
+		err = registry.StoreIfMatch(ctx, reg, &p, p.Revision)
+	} else {
+		err = reg.Store(ctx, &p)
+	}
+	if errors.Is(err, core.ErrConflict) {
+		fmt.Fprintf(os.Stderr, "%s@%s was changed since revision %d; get it again and reapply your edit\n", p.ID, p.Version, p.Revision)
+		os.Exit(1)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	fmt.Printf("stored %s@%s\n", p.ID, p.Version)
+	fmt.Printf("stored %s@%s (revision %d)\n", p.ID, p.Version, p.Revision)
 }
 
 func promote(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("promote", flag.ExitOnError)
+	env := fs.String("env", "", "Point this environment's production pointer at the version instead (no version removes it)")
+	_ = fs.Parse(args)
+	args = fs.Args()
+	if *env != "" {
+		promoteFor(ctx, reg, *env, args)
+		return
+	}
 	if len(args) < 2 {
 		fmt.Fprintln(os.Stderr, "promote requires <id> <version> [stage]")
 		os.Exit(1)
@@ -151,6 +398,26 @@ func promote(ctx context.Context, reg registry.Registry, args []string) {
 	fmt.Printf("promoted %s@%s to %s\n", id, version, stage)
 }
 
+func promoteFor(ctx context.Context, reg registry.Registry, env string, args []string) {
+	if len(args) < 1 || (len(args) >= 3 && strings.ToLower(args[2]) != "production") {
+		fmt.Fprintln(os.Stderr, "promote -env requires <id> [version]")
+		os.Exit(1)
+	}
+	id, version := args[0], ""
+	if len(args) >= 2 {
+		version = args[1]
+	}
+	if err := registry.PromoteFor(ctx, reg, id, version, env); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if version == "" {
+		fmt.Printf("%s in %s follows the global production version\n", id, env)
+		return
+	}
+	fmt.Printf("promoted %s@%s to production in %s\n", id, version, env)
+}
+
 func deleteCmd(ctx context.Context, reg registry.Registry, args []string) {
 	if len(args) < 2 {
 		fmt.Fprintln(os.Stderr, "delete requires <id> <version>")
@@ -163,6 +430,30 @@ func deleteCmd(ctx context.Context, reg registry.Registry, args []string) {
 	fmt.Printf("deleted %s@%s\n", args[0], args[1])
 }
 
+func archive(ctx context.Context, reg registry.Registry, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "archive requires <id> <version>")
+		os.Exit(1)
+	}
+	if err := reg.Archive(ctx, args[0], args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("archived %s@%s\n", args[0], args[1])
+}
+
+func restore(ctx context.Context, reg registry.Registry, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "restore requires <id> <version>")
+		os.Exit(1)
+	}
+	if err := reg.Restore(ctx, args[0], args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("restored %s@%s\n", args[0], args[1])
+}
+
 func tag(ctx context.Context, reg registry.Registry, args []string) {
 	if len(args) < 3 {
 		fmt.Fprintln(os.Stderr, "tag requires <id> <version> <tag...>")
@@ -177,6 +468,51 @@ func tag(ctx context.Context, reg registry.Registry, args []string) {
 	fmt.Printf("tagged %s@%s with %v\n", id, version, tags)
 }
 
+func deprecate(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("deprecate", flag.ExitOnError)
+	by := fs.String("by", "", "The prompt replacing it, as id@version (or id for its production version)")
+	reason := fs.String("reason", "", "Why it is deprecated, shown to its users")
+	sunset := fs.String("sunset", "", "Date after which it may be removed (YYYY-MM-DD)")
+	remove := fs.Bool("clear", false, "Remove the deprecation instead")
+	_ = fs.Parse(args)
+	args = fs.Args()
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "deprecate requires <id> <version>")
+		os.Exit(1)
+	}
+	id, version := args[0], args[1]
+	if *remove {
+		if err := registry.Deprecate(ctx, reg, id, version, nil); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s@%s is no longer deprecated\n", id, version)
+		return
+	}
+	d := &core.Deprecation{DeprecatedBy: *by, Reason: *reason}
+	if *sunset != "" {
+		t, err := time.Parse(time.DateOnly, *sunset)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -sunset %q: want YYYY-MM-DD\n", *sunset)
+			os.Exit(1)
+		}
+		d.Sunset = t
+	}
+	if *by != "" {
+		// Catch typos: consumers are told to move to the replacement, so it must exist.
+		byID, byVersion := d.Replacement()
+		if _, err := getVersion(ctx, reg, byID, byVersion); err != nil {
+			fmt.Fprintf(os.Stderr, "replacement %s: %v\n", *by, err)
+			os.Exit(1)
+		}
+	}
+	if err := registry.Deprecate(ctx, reg, id, version, d); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(&core.DeprecationWarning{ID: id, Version: version, Deprecation: *d})
+}
+
 func versions(ctx context.Context, reg registry.Registry, args []string) {
 	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "versions requires <id>")
@@ -188,6 +524,407 @@ func versions(ctx context.Context, reg registry.Registry, args []string) {
 		os.Exit(1)
 	}
 	for _, vi := range infos {
+		if vi.Archived {
+			fmt.Printf("%s\t%s\t%v\tarchived\n", vi.Version, vi.Stage, vi.Tags)
+			continue
+		}
 		fmt.Printf("%s\t%s\t%v\n", vi.Version, vi.Stage, vi.Tags)
 	}
 }
+
+func verify(ctx context.Context, reg registry.Registry, args []string) {
+	ids := args
+	if len(ids) == 0 {
+		seen := make(map[string]bool)
+		filter := registry.Filter{IncludeArchived: true, Limit: 500}
+		for {
+			page, next, err := registry.ListPage(ctx, reg, filter)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			for _, p := range page {
+				if !seen[p.ID] {
+					seen[p.ID] = true
+					ids = append(ids, p.ID)
+				}
+			}
+			if next == "" {
+				break
+			}
+			filter.Cursor = next
+		}
+	}
+	var refs []registry.VersionRef
+	if len(args) >= 2 {
+		refs = append(refs, registry.VersionRef{ID: args[0], Version: args[1]})
+	} else {
+		for _, id := range ids {
+			infos, err := reg.ListVersions(ctx, id)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			for _, vi := range infos {
+				refs = append(refs, registry.VersionRef{ID: id, Version: vi.Version})
+			}
+		}
+	}
+	failed := false
+	for _, ref := range refs {
+		err := registry.Verify(ctx, reg, ref.ID, ref.Version)
+		switch {
+		case err == nil:
+			fmt.Printf("%s@%s\tok\n", ref.ID, ref.Version)
+		case errors.Is(err, registry.ErrNoChecksum):
+			fmt.Printf("%s@%s\tno checksum\n", ref.ID, ref.Version)
+		default:
+			fmt.Printf("%s@%s\t%v\n", ref.ID, ref.Version, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func history(ctx context.Context, reg registry.Registry, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "history requires <id>")
+		os.Exit(1)
+	}
+	entries, err := registry.History(ctx, reg, args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, e := range entries {
+		actor := e.Actor
+		if actor == "" {
+			actor = "-"
+		}
+		var detail string
+		switch e.Action {
+		case registry.AuditStore:
+			detail = fmt.Sprintf("revision %d", e.Revision)
+		case registry.AuditPromote, registry.AuditRollback:
+			detail = string(e.Stage)
+		case registry.AuditTag:
+			detail = strings.Join(e.Tags, ",")
+		case registry.AuditAlias:
+			detail = "@" + e.Alias
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.RFC3339), actor, e.Action, e.Version, detail)
+	}
+}
+
+func alias(ctx context.Context, reg registry.Registry, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "alias requires <id> <alias> [version]")
+		os.Exit(1)
+	}
+	id, name := args[0], strings.TrimPrefix(args[1], "@")
+	version := ""
+	if len(args) >= 3 {
+		version = args[2]
+	}
+	if err := registry.SetAlias(ctx, reg, id, name, version); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if version == "" {
+		fmt.Printf("removed alias %s@%s\n", id, name)
+		return
+	}
+	fmt.Printf("aliased %s@%s to %s\n", id, name, version)
+}
+
+func aliases(ctx context.Context, reg registry.Registry, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "aliases requires <id>")
+		os.Exit(1)
+	}
+	m, err := registry.Aliases(ctx, reg, args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s\t%s\n", name, m[name])
+	}
+}
+
+// keygen prints a new ed25519 key pair for registry signing (see registry.NewSigned).
+func keygen() {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("private_key: %s\npublic_key:  %s\n", base64.StdEncoding.EncodeToString(priv.Seed()), base64.StdEncoding.EncodeToString(pub))
+}
+
+func fork(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("fork", flag.ExitOnError)
+	version := fs.String("version", "", "Version of the fork (default: the source's version)")
+	lineage := fs.Bool("lineage", false, "Record the source id and version in the fork's metadata")
+	_ = fs.Parse(args)
+	args = fs.Args()
+	if len(args) < 3 {
+		fmt.Fprintln(os.Stderr, "fork requires <id> <version> <new-id>")
+		os.Exit(1)
+	}
+	src, err := getVersion(ctx, reg, args[0], args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts := []registry.ForkOption{registry.WithForkVersion(*version)}
+	if *lineage {
+		opts = append(opts, registry.WithLineage())
+	}
+	p, err := registry.Fork(ctx, reg, src.ID, src.Version, args[2], opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("forked %s@%s to %s@%s\n", src.ID, src.Version, p.ID, p.Version)
+}
+
+func export(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("o", "", "Write the bundle to this file instead of stdout")
+	_ = fs.Parse(args)
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := registry.Export(ctx, reg, w); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func importCmd(ctx context.Context, reg registry.Registry, args []string) {
+	r := os.Stdin
+	if len(args) >= 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+	if err := registry.Import(ctx, reg, r); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("imported")
+}
+
+func syncCmd(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	to := fs.String("to", "", "Config file whose registry receives the prompts")
+	stages := fs.String("stages", "", "Comma-separated stages to mirror (default: all versions)")
+	prune := fs.Bool("prune", false, "Delete destination versions that are not mirrored from this registry")
+	conflict := fs.String("conflict", "source", "When a destination version differs: source (overwrite), destination (keep), or fail")
+	every := fs.Duration("every", 0, "Keep syncing at this interval until interrupted (0: sync once)")
+	_ = fs.Parse(args)
+	if *to == "" {
+		fmt.Fprintln(os.Stderr, "sync requires -to <config>")
+		os.Exit(1)
+	}
+	dstCfg, err := config.Load(*to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	dst, err := openRegistry(ctx, dstCfg.Registry)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "destination registry:", err)
+		os.Exit(1)
+	}
+	var opts []registry.SyncOption
+	switch *conflict {
+	case "source":
+	case "destination":
+		opts = append(opts, registry.WithConflictPolicy(registry.DestinationWins))
+	case "fail":
+		opts = append(opts, registry.WithConflictPolicy(registry.FailOnConflict))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown -conflict %q\n", *conflict)
+		os.Exit(1)
+	}
+	if *stages != "" {
+		var list []registry.Stage
+		for _, st := range strings.Split(*stages, ",") {
+			list = append(list, registry.Stage(strings.TrimSpace(st)))
+		}
+		opts = append(opts, registry.WithSyncStages(list...))
+	}
+	if *prune {
+		opts = append(opts, registry.WithPrune())
+	}
+	if *every > 0 {
+		opts = append(opts, registry.WithSyncErrorHandler(func(err error) { fmt.Fprintln(os.Stderr, err) }))
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		if err := registry.NewSyncer(reg, dst, opts...).Run(ctx, *every); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	res, err := registry.NewSyncer(reg, dst, opts...).Sync(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("stored %d, updated %d, deleted %d, kept %d conflicting\n", res.Stored, res.Updated, res.Deleted, res.Conflicts)
+}
+
+// defaultActor returns LOOM_ACTOR, or the login name from USER.
+func defaultActor() string {
+	if v := os.Getenv("LOOM_ACTOR"); v != "" {
+		return v
+	}
+	return os.Getenv("USER")
+}
+
+func pruneCmd(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	keep := fs.Int("keep", 0, "Keep the newest n versions of each id")
+	olderThan := fs.String("older-than", "", "Only delete versions not updated for this long (e.g. 90d, 36h)")
+	skip := fs.String("skip-stages", "", "Keep versions in these stages (comma-separated)")
+	dryRun := fs.Bool("dry-run", false, "Print the versions that would be deleted without deleting them")
+	_ = fs.Parse(args)
+	policy := registry.PrunePolicy{KeepLast: *keep, IDs: fs.Args(), DryRun: *dryRun}
+	if *olderThan != "" {
+		age, err := parseAge(*olderThan)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-older-than:", err)
+			os.Exit(1)
+		}
+		policy.OlderThan = age
+	}
+	if *skip != "" {
+		for _, st := range strings.Split(*skip, ",") {
+			policy.SkipStages = append(policy.SkipStages, registry.Stage(strings.TrimSpace(st)))
+		}
+	}
+	pruned, err := registry.Prune(ctx, reg, policy)
+	verb := "deleted"
+	if *dryRun {
+		verb = "would delete"
+	}
+	for _, vi := range pruned {
+		fmt.Printf("%s %s@%s\t%s\t%s\n", verb, vi.ID, vi.Version, vi.Stage, vi.UpdatedAt.Format(time.RFC3339))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// parseAge parses a duration like time.ParseDuration, also accepting whole days ("90d").
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 || n > int(math.MaxInt64/(24*time.Hour)) {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func rollbackCmd(ctx context.Context, reg registry.Registry, cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	watch := fs.Bool("watch", false, "Watch new production versions and roll back those that regress")
+	every := fs.Duration("every", time.Minute, "With -watch: how often to check")
+	bakeIn := fs.Duration("bake-in", 30*time.Minute, "With -watch: how long after its promotion a version is watched")
+	maxErr := fs.Float64("max-error-increase", 0.05, "With -watch: allowed error rate increase over the previous version (0: do not check)")
+	maxLatency := fs.Float64("max-latency-increase", 0.5, "With -watch: allowed average latency increase as a fraction (0: do not check)")
+	minRuns := fs.Int64("min-runs", 20, "With -watch: runs needed before a version is judged")
+	dryRun := fs.Bool("dry-run", false, "Print the version that would be promoted (with -watch: report regressions) without rolling back")
+	history := fs.Bool("history", false, "List the production promotions of <id>, newest first, instead of rolling back")
+	_ = fs.Parse(args)
+	if !*watch {
+		if fs.NArg() < 1 {
+			fmt.Fprintln(os.Stderr, "rollback requires <id> (or -watch)")
+			os.Exit(1)
+		}
+		id := fs.Arg(0)
+		switch {
+		case *history:
+			promotions, err := registry.Promotions(ctx, reg, id)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			for _, e := range promotions {
+				fmt.Printf("%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.Version, e.Actor)
+			}
+		case *dryRun:
+			version, err := registry.PreviousProduction(ctx, reg, id)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("would roll back %s to %s\n", id, version)
+		default:
+			version, err := registry.Rollback(ctx, reg, id)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("rolled back %s to %s\n", id, version)
+		}
+		return
+	}
+	if cfg.Analytics.Store == "" || cfg.Analytics.Store == "memory" {
+		fmt.Fprintln(os.Stderr, "rollback -watch requires a postgres or redis analytics store in -config")
+		os.Exit(1)
+	}
+	store, err := cfg.Analytics.Open(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "analytics:", err)
+		os.Exit(1)
+	}
+	opts := []rollback.Option{
+		rollback.WithBakeIn(*bakeIn),
+		rollback.OnError(func(err error) { fmt.Fprintln(os.Stderr, err) }),
+		rollback.OnRegression(func(_ context.Context, e rollback.Event) {
+			action := "rolled back to"
+			if !e.RolledBack {
+				action = "would roll back to"
+			}
+			fmt.Printf("%s@%s regressed (%s); %s %s\n", e.ID, e.Version, e.Reason, action, e.Baseline)
+		}),
+	}
+	if *dryRun {
+		opts = append(opts, rollback.DryRun())
+	}
+	if fs.NArg() > 0 {
+		opts = append(opts, rollback.WithIDs(fs.Args()...))
+	}
+	th := rollback.Thresholds{MaxErrorRateIncrease: *maxErr, MaxLatencyIncrease: *maxLatency, MinRuns: *minRuns}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	if err := rollback.New(reg, store, th, opts...).Run(ctx, *every); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
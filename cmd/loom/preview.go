@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/klejdi94/loom"
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/template"
+	"sigs.k8s.io/yaml"
+)
+
+// previewCmd renders a prompt file, or a stored version, with sample inputs (a YAML or JSON list
+// of objects; default: the prompt's example inputs) and prints each render, or with -base, its diff
+// from another version's. It exits 2 if any sample fails to render, or with -fail-on-change, if
+// any render changed.
+func previewCmd(ctx context.Context, reg registry.Registry, args []string) {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	samplesPath := fs.String("samples", "", "YAML or JSON file with a list of sample inputs (default: the prompt's example inputs)")
+	base := fs.String("base", "", "Diff against this version of the prompt's id in the registry (production, latest, @alias, a range), or a prompt file")
+	format := fs.String("format", "text", "Output format: text or json")
+	failOnChange := fs.Bool("fail-on-change", false, "Exit 2 if any render differs from -base")
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fmt.Fprintln(os.Stderr, "Usage: loom preview [-samples file] [-base version|file] [-format text|json] [-fail-on-change] <file | id [version]>")
+		os.Exit(1)
+	}
+	fail := func(err error) {
+		fmt.Fprintln(os.Stderr, "preview:", err)
+		os.Exit(1)
+	}
+	p, err := previewPrompt(ctx, reg, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fail(err)
+	}
+	var opts []template.PreviewOption
+	if *base != "" {
+		var bp *core.Prompt
+		if _, err = os.Stat(*base); err == nil {
+			bp, err = loom.ReadFile(*base)
+		} else {
+			bp, err = getVersion(ctx, reg, p.ID, *base)
+		}
+		if err != nil {
+			fail(fmt.Errorf("base: %w", err))
+		}
+		opts = append(opts, template.CompareTo(bp))
+	}
+	samples, err := readSamples(*samplesPath, p)
+	if err != nil {
+		fail(err)
+	}
+
+	report := template.Preview(ctx, p, samples, opts...)
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	case "text":
+		for _, r := range report.Results {
+			fmt.Printf("== sample %d\n", r.Sample)
+			switch {
+			case r.Error != "":
+				fmt.Println("error:", r.Error)
+			case *base == "":
+				fmt.Println(r.Text)
+			case r.BaseError != "":
+				fmt.Println("base error:", r.BaseError)
+				fmt.Println(r.Text)
+			case r.Diff == "":
+				fmt.Println("unchanged")
+			default:
+				fmt.Print(r.Diff)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d samples, %d errors", len(report.Results), report.Errors)
+		if *base != "" {
+			fmt.Fprintf(os.Stderr, ", %d changed", report.Changed)
+		}
+		fmt.Fprintln(os.Stderr)
+	default:
+		err = fmt.Errorf("unknown format %q (text, json)", *format)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if report.Errors > 0 || (*failOnChange && report.Changed > 0) {
+		os.Exit(2)
+	}
+}
+
+// previewPrompt reads the prompt file at arg, or if there is no such file, gets version of the
+// prompt with id arg from reg.
+func previewPrompt(ctx context.Context, reg registry.Registry, arg, version string) (*core.Prompt, error) {
+	if _, err := os.Stat(arg); err == nil {
+		if version != "" {
+			return nil, fmt.Errorf("%s is a file; a version is only for prompt ids", arg)
+		}
+		return loom.ReadFile(arg)
+	}
+	return getVersion(ctx, reg, arg, version)
+}
+
+// readSamples reads the list of inputs at path, or returns p's example inputs (or a single empty
+// input if it has none) if path is empty.
+func readSamples(path string, p *core.Prompt) ([]core.Input, error) {
+	if path == "" {
+		samples := make([]core.Input, 0, len(p.Examples))
+		for _, ex := range p.Examples {
+			samples = append(samples, core.Input(ex.Input))
+		}
+		if len(samples) == 0 {
+			samples = append(samples, core.Input{})
+		}
+		return samples, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var samples []core.Input
+	if err := yaml.Unmarshal(data, &samples); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%s: no samples", path)
+	}
+	return samples, nil
+}
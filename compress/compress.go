@@ -0,0 +1,93 @@
+// Package compress shortens long context variables (meeting transcripts, logs, retrieved
+// documents) to a token budget before they are rendered into a prompt. Heuristic prunes
+// repetitive and low-salience sentences without a model call; LLM has a model rewrite the text
+// and falls back to Heuristic when the model overshoots. Either can run as a chain step (Step),
+// as a template function (FuncMap), or on an input directly (Variables).
+//
+//	c := &compress.LLM{Provider: p, Model: "gpt-4o-mini"}
+//	ch := chain.NewChain("minutes").
+//		Func("transcript", compress.Step(c, "transcript", 2000)). // replaces .transcript for later steps
+//		Step("minutes", minutesPrompt)
+package compress
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/klejdi94/loom/chain"
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/cost"
+)
+
+// Compressor shortens text to at most maxTokens tokens, keeping its most salient content. Text
+// that already fits is returned unchanged.
+type Compressor interface {
+	Compress(ctx context.Context, text string, maxTokens int) (string, error)
+}
+
+// CompressorFunc adapts a function to Compressor.
+type CompressorFunc func(ctx context.Context, text string, maxTokens int) (string, error)
+
+// Compress implements Compressor.
+func (f CompressorFunc) Compress(ctx context.Context, text string, maxTokens int) (string, error) {
+	return f(ctx, text, maxTokens)
+}
+
+// Step returns a chain step that compresses the input variable to maxTokens. Add it under the
+// variable's name (chain.Chain.Func) so later steps render the shorter text in its place.
+func Step(c Compressor, variable string, maxTokens int) chain.StepFunc {
+	return func(ctx context.Context, input core.Input) (string, error) {
+		v, ok := input[variable]
+		if !ok || v == nil {
+			return "", nil
+		}
+		return c.Compress(ctx, fmt.Sprint(v), maxTokens)
+	}
+}
+
+// FuncMap returns a "compress" template function for template.WithFuncMap:
+//
+//	{{compress .transcript 1500}}
+//
+// Template functions have no context, so c runs with context.Background(); give an LLM
+// compressor's provider its own timeout (e.g. middleware) if rendering must be bounded.
+func FuncMap(c Compressor) template.FuncMap {
+	return template.FuncMap{
+		"compress": func(v interface{}, maxTokens int) (string, error) {
+			if v == nil {
+				return "", nil
+			}
+			return c.Compress(context.Background(), fmt.Sprint(v), maxTokens)
+		},
+	}
+}
+
+// Variables returns a copy of input with each variable in budgets (name -> max tokens)
+// compressed with c; other variables are copied as is.
+func Variables(ctx context.Context, c Compressor, input core.Input, budgets map[string]int) (core.Input, error) {
+	out := make(core.Input, len(input))
+	for k, v := range input {
+		out[k] = v
+	}
+	for name, maxTokens := range budgets {
+		v, ok := input[name]
+		if !ok || v == nil {
+			continue
+		}
+		text, err := c.Compress(ctx, fmt.Sprint(v), maxTokens)
+		if err != nil {
+			return nil, fmt.Errorf("compress %s: %w", name, err)
+		}
+		out[name] = text
+	}
+	return out, nil
+}
+
+// counterOr returns c, or cost.SimpleCounter if c is nil.
+func counterOr(c cost.TokenCounter) cost.TokenCounter {
+	if c == nil {
+		return cost.SimpleCounter{}
+	}
+	return c
+}
@@ -0,0 +1,255 @@
+package compress
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/klejdi94/loom/cost"
+)
+
+// DefaultOmission marks where Heuristic dropped text.
+const DefaultOmission = "[...]"
+
+// Heuristic compresses text without a model. It splits the text into sentences (and lines),
+// drops repeats (lines equal up to case, digits, and spacing, like log lines with different
+// timestamps), and keeps the most salient of the rest in their original order until the budget is
+// used. A sentence's salience is how often its content words recur elsewhere in the text, so
+// sentences about the text's main topics win over small talk; speaker labels ("Alice:") are
+// ignored, sentences whose words do not recur are dropped, and sentences matching Keep are
+// preferred. If not even one sentence fits, the text is cut at a word boundary.
+type Heuristic struct {
+	// Counter counts tokens; cost.SimpleCounter if nil.
+	Counter cost.TokenCounter
+	// Keep holds patterns for sentences to keep before any others, e.g. `(?i)decid|action item|error`.
+	Keep []*regexp.Regexp
+	// Omission replaces each run of dropped sentences; DefaultOmission if empty, "-" for nothing.
+	Omission string
+}
+
+// segment is one sentence of the input.
+type segment struct {
+	text   string
+	line   int // index of the input line it came from
+	tokens int
+	score  float64
+	dup    bool
+}
+
+// Compress implements Compressor.
+func (h *Heuristic) Compress(ctx context.Context, text string, maxTokens int) (string, error) {
+	counter := counterOr(h.Counter)
+	if counter.CountTokens(text) <= maxTokens {
+		return text, nil
+	}
+	if maxTokens <= 0 {
+		return "", nil
+	}
+	omission := h.Omission
+	switch omission {
+	case "":
+		omission = DefaultOmission
+	case "-":
+		omission = ""
+	}
+	segs := split(text)
+	h.score(segs, counter)
+
+	// Sentences without recurring content words are only used if no sentence has any.
+	salient := false
+	for _, s := range segs {
+		salient = salient || s.score > 0
+	}
+	order := make([]int, 0, len(segs))
+	for i := range segs {
+		if !segs[i].dup && (segs[i].score > 0 || !salient) {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return segs[order[a]].score > segs[order[b]].score })
+
+	omTokens := 0
+	if omission != "" {
+		omTokens = counter.CountTokens(omission)
+	}
+	kept := make([]bool, len(segs))
+	used, gaps := 0, 1
+	for _, i := range order {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		// Keeping i splits its run of dropped segments in two, shortens it, or closes it.
+		left := i == 0 || kept[i-1]
+		right := i == len(segs)-1 || kept[i+1]
+		delta := 0
+		switch {
+		case left && right:
+			delta = -1
+		case !left && !right:
+			delta = 1
+		}
+		if used+segs[i].tokens+(gaps+delta)*omTokens > maxTokens {
+			continue
+		}
+		kept[i] = true
+		used += segs[i].tokens
+		gaps += delta
+	}
+	for {
+		out := join(segs, kept, omission)
+		if out == "" || counter.CountTokens(out) <= maxTokens {
+			if strings.TrimSpace(strings.ReplaceAll(out, omission, "")) == "" {
+				return truncate(text, maxTokens, counter), nil
+			}
+			return out, nil
+		}
+		// Separators are not budgeted above; drop the least salient kept sentence and retry.
+		for j := len(order) - 1; j >= 0; j-- {
+			if kept[order[j]] {
+				kept[order[j]] = false
+				break
+			}
+		}
+	}
+}
+
+// score sets each segment's token count and salience and marks repeats.
+func (h *Heuristic) score(segs []segment, counter cost.TokenCounter) {
+	seen := make(map[string]bool, len(segs))
+	freq := make(map[string]int)
+	words := make([][]string, len(segs))
+	for i := range segs {
+		segs[i].tokens = counter.CountTokens(segs[i].text)
+		key := normalize(segs[i].text)
+		if seen[key] {
+			segs[i].dup = true
+			continue
+		}
+		seen[key] = true
+		words[i] = contentWords(speakerLabel.ReplaceAllString(segs[i].text, ""))
+		for _, w := range words[i] {
+			freq[w]++
+		}
+	}
+	top := 0.0
+	for i := range segs {
+		for _, w := range words[i] {
+			segs[i].score += float64(freq[w] - 1)
+		}
+		top = math.Max(top, segs[i].score)
+	}
+	for i := range segs {
+		if segs[i].dup {
+			continue
+		}
+		for _, re := range h.Keep {
+			if re.MatchString(segs[i].text) {
+				segs[i].score += 2*top + 1
+				break
+			}
+		}
+	}
+}
+
+// split returns the sentences of text, line by line; blank lines are skipped.
+func split(text string) []segment {
+	var segs []segment
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		start := 0
+		runes := []rune(line)
+		for i, r := range runes {
+			end := i == len(runes)-1
+			if !end && (r == '.' || r == '!' || r == '?') && unicode.IsSpace(runes[i+1]) {
+				end = true
+			}
+			if end {
+				if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+					segs = append(segs, segment{text: s, line: n})
+				}
+				start = i + 1
+			}
+		}
+	}
+	return segs
+}
+
+// join renders the kept segments in order: sentences of one line joined by spaces, lines by
+// newlines, and omission on its own line for each run of dropped segments.
+func join(segs []segment, kept []bool, omission string) string {
+	var sb strings.Builder
+	prev := -2 // index of the previous kept segment
+	for i, s := range segs {
+		if !kept[i] {
+			continue
+		}
+		switch {
+		case prev == i-1 && segs[prev].line == s.line:
+			sb.WriteByte(' ')
+		case sb.Len() > 0:
+			sb.WriteByte('\n')
+		}
+		if prev != i-1 && i > 0 && omission != "" {
+			sb.WriteString(omission)
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(s.text)
+		prev = i
+	}
+	if sb.Len() > 0 && prev != len(segs)-1 && omission != "" {
+		sb.WriteByte('\n')
+		sb.WriteString(omission)
+	}
+	return sb.String()
+}
+
+// truncate returns the longest prefix of text ending at a word boundary that fits maxTokens.
+func truncate(text string, maxTokens int, counter cost.TokenCounter) string {
+	words := strings.Fields(text)
+	n := sort.Search(len(words)+1, func(n int) bool {
+		return counter.CountTokens(strings.Join(words[:n], " ")) > maxTokens
+	})
+	if n == 0 {
+		return ""
+	}
+	return strings.Join(words[:n-1], " ")
+}
+
+var (
+	digitRun     = regexp.MustCompile(`[0-9]+`)
+	speakerLabel = regexp.MustCompile(`^[\pL\pN _.-]{1,40}:\s`)
+)
+
+// normalize returns the form of s used to detect repeats.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(digitRun.ReplaceAllString(strings.ToLower(s), "0")), " ")
+}
+
+// contentWords returns the distinct lowercased words of s of three or more letters that are not stopwords.
+func contentWords(s string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if len([]rune(w)) >= 3 && !stopwords[w] && !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+var stopwords = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(`the and for are but not you all any can had her was one our out has him his how its may
+		new now old see two who did get let put say she too use that this with have from they will would there their what
+		about which when make like time just know take into your some could them than then look only come over think also
+		back after work first well even want because these give most been were said each very much where those being
+		here should okay yeah sure thing things really right going gonna maybe actually basically thanks thank
+		hello morning good great fine please sounds everyone`) {
+		m[w] = true
+	}
+	return m
+}()
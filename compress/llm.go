@@ -0,0 +1,122 @@
+package compress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/klejdi94/loom/cost"
+	"github.com/klejdi94/loom/provider"
+)
+
+// DefaultInstructions is the system prompt LLM uses when Instructions is empty.
+const DefaultInstructions = `You compress text for use as context in another prompt. Keep every fact, decision, number, name, date, error, and open question; drop filler, repetition, greetings, and small talk. Write dense plain text (no preamble, no commentary, no markdown headings) in the language of the input.`
+
+// LLM compresses text with a model. Text longer than ChunkTokens is split at line boundaries,
+// each chunk compressed to its share of the budget, and the results joined. The model's output is
+// capped with CompletionRequest.MaxTokens; if it still exceeds the budget by the Counter's
+// estimate, Heuristic prunes it to fit.
+type LLM struct {
+	Provider provider.Provider
+	Model    string
+	// Instructions is the system prompt; DefaultInstructions if empty. Each request's prompt states
+	// the token budget and contains the text.
+	Instructions string
+	// Counter counts tokens; cost.SimpleCounter if nil.
+	Counter cost.TokenCounter
+	// ChunkTokens is the most text sent per request; 8000 if 0.
+	ChunkTokens int
+}
+
+// Compress implements Compressor.
+func (l *LLM) Compress(ctx context.Context, text string, maxTokens int) (string, error) {
+	counter := counterOr(l.Counter)
+	total := counter.CountTokens(text)
+	if total <= maxTokens {
+		return text, nil
+	}
+	if maxTokens <= 0 {
+		return "", nil
+	}
+	chunkTokens := l.ChunkTokens
+	if chunkTokens <= 0 {
+		chunkTokens = 8000
+	}
+	var out string
+	if total <= chunkTokens {
+		var err error
+		if out, err = l.complete(ctx, text, maxTokens); err != nil {
+			return "", err
+		}
+	} else {
+		chunks := chunk(text, chunkTokens, counter)
+		parts := make([]string, 0, len(chunks))
+		for i, c := range chunks {
+			budget := maxTokens * counter.CountTokens(c) / total
+			if budget < 1 {
+				budget = 1
+			}
+			part, err := l.complete(ctx, c, budget)
+			if err != nil {
+				return "", fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+			}
+			parts = append(parts, part)
+		}
+		out = strings.Join(parts, "\n")
+	}
+	return (&Heuristic{Counter: counter, Omission: "-"}).Compress(ctx, out, maxTokens)
+}
+
+// complete asks the model to compress text to maxTokens.
+func (l *LLM) complete(ctx context.Context, text string, maxTokens int) (string, error) {
+	system := l.Instructions
+	if system == "" {
+		system = DefaultInstructions
+	}
+	resp, err := l.Provider.Complete(ctx, provider.CompletionRequest{
+		Model:     l.Model,
+		System:    system,
+		Prompt:    fmt.Sprintf("Compress the following text to at most %d tokens (about %d words).\n\n%s", maxTokens, maxTokens*3/4, text),
+		MaxTokens: maxTokens,
+	})
+	if err != nil {
+		return "", fmt.Errorf("compress: %w", err)
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// chunk splits text at line boundaries into pieces of at most maxTokens; a longer line is split
+// at word boundaries.
+func chunk(text string, maxTokens int, counter cost.TokenCounter) []string {
+	var chunks []string
+	var cur []string
+	curTokens := 0
+	flush := func() {
+		if len(cur) > 0 {
+			chunks = append(chunks, strings.Join(cur, "\n"))
+			cur, curTokens = nil, 0
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		n := counter.CountTokens(line)
+		if n > maxTokens {
+			flush()
+			for rest := line; rest != ""; {
+				head := truncate(rest, maxTokens, counter)
+				if head == "" {
+					head = strings.Fields(rest)[0] // a single word over the budget
+				}
+				chunks = append(chunks, head)
+				rest = strings.TrimSpace(strings.TrimPrefix(strings.Join(strings.Fields(rest), " "), head))
+			}
+			continue
+		}
+		if curTokens+n > maxTokens {
+			flush()
+		}
+		cur = append(cur, line)
+		curTokens += n
+	}
+	flush()
+	return chunks
+}
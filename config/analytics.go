@@ -0,0 +1,48 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/klejdi94/loom/analytics"
+	"github.com/redis/go-redis/v9"
+)
+
+// AnalyticsConfig selects and configures the analytics store.
+type AnalyticsConfig struct {
+	Store string `json:"store"` // memory (default), postgres, or redis
+	Max   int    `json:"max"`   // memory: max records kept (0: unbounded)
+	DSN   string `json:"dsn"`   // postgres
+	Table string `json:"table"` // postgres: table name (default prompt_runs)
+	Redis string `json:"redis"` // redis: address
+	Key   string `json:"key"`   // redis: sorted set key (default loom:analytics:runs)
+}
+
+// Open connects to the store, creating the Postgres table if needed.
+func (a AnalyticsConfig) Open(ctx context.Context) (analytics.Store, error) {
+	switch a.Store {
+	case "", "memory":
+		return analytics.NewMemoryStore(a.Max), nil
+	case "postgres":
+		if a.DSN == "" {
+			return nil, fmt.Errorf("postgres store requires a dsn")
+		}
+		db, err := sql.Open("postgres", a.DSN)
+		if err != nil {
+			return nil, err
+		}
+		pg, err := analytics.NewPostgresStore(db, orDefault(a.Table, "prompt_runs"))
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return pg, nil
+	case "redis":
+		if a.Redis == "" {
+			return nil, fmt.Errorf("redis store requires a redis address")
+		}
+		return analytics.NewRedisStore(redis.NewClient(&redis.Options{Addr: a.Redis}), a.Key), nil
+	}
+	return nil, fmt.Errorf("unknown analytics store %q", a.Store)
+}
@@ -0,0 +1,149 @@
+// Package config loads a single YAML (or JSON) file describing LLM providers, the middleware
+// chain wrapped around them, the registry backend, and the analytics store, so that loom,
+// loom-server, analytics-server, and services built on loom can be composed without recompiling.
+//
+//	providers:
+//	  openai:
+//	    api_key: ${OPENAI_API_KEY}   # ${VAR} references are expanded from the environment
+//	  local:
+//	    type: ollama                 # defaults to the provider's name
+//	    base_url: http://localhost:11434
+//	middleware:                      # applied in order; the first is outermost
+//	  - type: logging
+//	  - type: cache
+//	    ttl: 1h
+//	  - type: rate_limit
+//	    limit: 100
+//	    window: 1m
+//	  - type: circuit_breaker
+//	    threshold: 0.5
+//	    timeout: 30s
+//	registry:
+//	  backend: postgres              # memory, file, postgres, redis, dynamodb, or http
+//	  dsn: ${LOOM_DSN}
+//	analytics:
+//	  store: redis                   # memory, postgres, or redis
+//	  redis: localhost:6379
+//	offline: false                   # true: render prompts without calling providers (CI, air-gapped)
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/klejdi94/loom/registry"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the root of a config file.
+type Config struct {
+	Providers  map[string]ProviderConfig `json:"providers"`
+	Middleware []MiddlewareConfig        `json:"middleware"`
+	Registry   RegistryConfig            `json:"registry"`
+	Analytics  AnalyticsConfig           `json:"analytics"`
+	// Offline makes executors built by the cmd binaries render prompts without calling providers
+	// (see executor.WithOffline), so CI and air-gapped environments can run everything else.
+	Offline bool `json:"offline"`
+}
+
+// Load reads a config file. ${VAR} and $VAR references are replaced with environment
+// variables before the file is parsed, so secrets can stay out of it.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse([]byte(os.ExpandEnv(string(data))))
+}
+
+// Parse decodes YAML or JSON config data and validates it. Unlike Load, it does not expand
+// environment variables.
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks provider types, middleware types and settings, and backend names, so that a
+// bad file fails at startup rather than on the first request.
+func (c *Config) Validate() error {
+	for name, p := range c.Providers {
+		if !knownProvider(p.kind(name)) {
+			return fmt.Errorf("config: provider %q: unknown type %q", name, p.kind(name))
+		}
+	}
+	for i, m := range c.Middleware {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("config: middleware %d: %w", i, err)
+		}
+	}
+	switch c.Registry.Backend {
+	case "", "memory", "file", "postgres", "redis", "dynamodb", "http":
+	default:
+		return fmt.Errorf("config: unknown registry backend %q", c.Registry.Backend)
+	}
+	if c.Registry.Codec != "" {
+		if _, err := registry.CodecByName(c.Registry.Codec); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	for i, r := range c.Registry.Promotion {
+		if r.Stage == "" {
+			return fmt.Errorf("config: registry promotion rule %d: stage is required", i)
+		}
+		if len(r.From) == 0 && len(r.Tags) == 0 {
+			return fmt.Errorf("config: registry promotion rule %d: set from or tags", i)
+		}
+	}
+	if c.Registry.Signing != nil {
+		if _, err := c.Registry.Signing.options(); err != nil {
+			return fmt.Errorf("config: registry signing: %w", err)
+		}
+	}
+	if c.Registry.Authz != nil {
+		if _, err := c.Registry.Authz.policy(); err != nil {
+			return fmt.Errorf("config: registry authz: %w", err)
+		}
+	}
+	switch c.Analytics.Store {
+	case "", "memory", "postgres", "redis":
+	default:
+		return fmt.Errorf("config: unknown analytics store %q", c.Analytics.Store)
+	}
+	return nil
+}
+
+// Duration is a time.Duration written as a string such as "30s" or "1h", or as a number of seconds.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		secs, nerr := strconv.ParseFloat(string(b), 64)
+		if nerr != nil {
+			return fmt.Errorf("invalid duration %s", b)
+		}
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/middleware"
+	"github.com/klejdi94/loom/provider"
+)
+
+// ProviderConfig configures one provider. An empty APIKey falls back to the conventional env var
+// for the type (OPENAI_API_KEY, ANTHROPIC_API_KEY, GEMINI_API_KEY, COHERE_API_KEY, CEREBRAS_API_KEY,
+// HF_TOKEN), or to the one named by APIKeyEnv; an empty BaseURL for ollama falls back to OLLAMA_HOST.
+// For huggingface, BaseURL is an Inference Endpoint or TGI server (ending in /v1; default: the
+// Hugging Face router, which needs a key).
+type ProviderConfig struct {
+	Type      string `json:"type"` // openai, anthropic, gemini, cohere, cerebras, huggingface, ollama; default: the provider's name
+	APIKey    string `json:"api_key"`
+	APIKeyEnv string `json:"api_key_env"`
+	BaseURL   string `json:"base_url"`
+}
+
+func (p ProviderConfig) kind(name string) string {
+	if p.Type != "" {
+		return p.Type
+	}
+	return name
+}
+
+var providerKeyEnv = map[string]string{
+	"openai":      "OPENAI_API_KEY",
+	"anthropic":   "ANTHROPIC_API_KEY",
+	"gemini":      "GEMINI_API_KEY",
+	"cohere":      "COHERE_API_KEY",
+	"cerebras":    "CEREBRAS_API_KEY",
+	"huggingface": "HF_TOKEN",
+	"ollama":      "",
+}
+
+func knownProvider(kind string) bool {
+	_, ok := providerKeyEnv[kind]
+	return ok
+}
+
+// New builds the provider (without middleware); name is used as its type if Type is empty.
+func (p ProviderConfig) New(name string) (provider.Provider, error) {
+	kind := p.kind(name)
+	key := p.APIKey
+	if key == "" {
+		env := p.APIKeyEnv
+		if env == "" {
+			env = providerKeyEnv[kind]
+		}
+		if env != "" {
+			key = os.Getenv(env)
+		}
+	}
+	switch kind {
+	case "openai":
+		return provider.NewOpenAI(provider.OpenAIConfig{APIKey: key, BaseURL: p.BaseURL})
+	case "anthropic":
+		return provider.NewAnthropic(provider.AnthropicConfig{APIKey: key, BaseURL: p.BaseURL})
+	case "gemini":
+		return provider.NewGemini(provider.GeminiConfig{APIKey: key, BaseURL: p.BaseURL})
+	case "cohere":
+		return provider.NewCohere(provider.CohereConfig{APIKey: key, BaseURL: p.BaseURL})
+	case "cerebras":
+		return provider.NewCerebras(provider.CerebrasConfig{APIKey: key, BaseURL: p.BaseURL})
+	case "huggingface":
+		return provider.NewHuggingFace(provider.HuggingFaceConfig{APIKey: key, BaseURL: p.BaseURL})
+	case "ollama":
+		base := p.BaseURL
+		if base == "" {
+			base = os.Getenv("OLLAMA_HOST")
+		}
+		return provider.NewOllama(provider.OllamaConfig{BaseURL: base}), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q", kind)
+}
+
+// NewProvider builds the named provider wrapped in the middleware chain. A name missing from
+// Providers is built as that type with keys from the environment, so "openai" works without
+// a providers section.
+func (c *Config) NewProvider(name string) (provider.Provider, error) {
+	p, err := c.Providers[name].New(name)
+	if err != nil {
+		return nil, err
+	}
+	p, _ = c.Chain(p)
+	return p, nil
+}
+
+// NewExecutor returns an executor with opts for the named provider (see NewProvider). When the
+// config is Offline the provider is not built, so no keys or network are needed, and the executor
+// renders prompts without calling one (see executor.WithOffline).
+func (c *Config) NewExecutor(name string, opts ...executor.ExecutorOption) (*executor.Executor, error) {
+	if c.Offline {
+		return executor.New(nil, append(opts, executor.WithOffline(true))...), nil
+	}
+	p, err := c.NewProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	return executor.New(p, opts...), nil
+}
+
+// Chain wraps p in the configured middleware, first entry outermost. The counters are nil unless
+// the chain includes metrics. Each call creates fresh middleware state (cache, rate limit window,
+// breaker), so providers built separately do not share it.
+func (c *Config) Chain(p provider.Provider) (provider.Provider, *middleware.MetricsCounters) {
+	var counters *middleware.MetricsCounters
+	mws := make([]middleware.Middleware, 0, len(c.Middleware))
+	for _, m := range c.Middleware {
+		switch m.Type {
+		case "logging":
+			mws = append(mws, middleware.Logging(log.Printf))
+		case "metrics":
+			var mw middleware.Middleware
+			mw, counters = middleware.Metrics()
+			mws = append(mws, mw)
+		case "cache":
+			mws = append(mws, middleware.CacheMiddleware(middleware.NewInMemoryCache(), time.Duration(m.TTL)))
+		case "rate_limit":
+			mws = append(mws, middleware.RateLimit(m.Limit, time.Duration(m.Window)))
+		case "circuit_breaker":
+			var opts []middleware.CircuitBreakerOption
+			if m.PerPrompt {
+				opts = append(opts, middleware.PerPrompt())
+			}
+			mws = append(mws, middleware.CircuitBreaker(m.Threshold, time.Duration(m.Timeout), opts...))
+		case "moderation":
+			res := make([]*regexp.Regexp, len(m.Patterns))
+			for i, pat := range m.Patterns {
+				res[i] = regexp.MustCompile(pat) // checked by validate
+			}
+			opts := []middleware.ModerationOption{middleware.OnViolation(func(_ context.Context, v middleware.Violation) {
+				log.Printf("moderation: response cut: %s", v.Reason)
+			})}
+			if m.Replacement != "" {
+				opts = append(opts, middleware.WithReplacement(m.Replacement))
+			}
+			mws = append(mws, middleware.Moderation(middleware.Patterns(res...), opts...))
+		}
+	}
+	return middleware.Chain(p, mws...), counters
+}
+
+// MiddlewareConfig is one entry of the middleware chain. Type selects the middleware; the other
+// fields are its settings:
+//
+//	logging                          (logs through the standard logger)
+//	metrics
+//	cache            ttl             (in memory; ttl defaults to 1h)
+//	rate_limit       limit, window
+//	circuit_breaker  threshold, timeout, per_prompt   (per_prompt: one circuit per prompt id)
+//	moderation       patterns, replacement   (regexps; flagged responses are cut and logged)
+type MiddlewareConfig struct {
+	Type        string   `json:"type"`
+	TTL         Duration `json:"ttl,omitempty"`
+	Limit       int      `json:"limit,omitempty"`
+	Window      Duration `json:"window,omitempty"`
+	Threshold   float64  `json:"threshold,omitempty"`
+	Timeout     Duration `json:"timeout,omitempty"`
+	Patterns    []string `json:"patterns,omitempty"`
+	Replacement string   `json:"replacement,omitempty"`
+	PerPrompt   bool     `json:"per_prompt,omitempty"`
+}
+
+func (m MiddlewareConfig) validate() error {
+	switch m.Type {
+	case "logging", "metrics":
+	case "cache":
+		if m.TTL < 0 {
+			return fmt.Errorf("cache ttl must not be negative")
+		}
+	case "rate_limit":
+		if m.Limit <= 0 || m.Window <= 0 {
+			return fmt.Errorf("rate_limit requires a positive limit and window")
+		}
+	case "circuit_breaker":
+		if m.Threshold <= 0 || m.Threshold > 1 || m.Timeout <= 0 {
+			return fmt.Errorf("circuit_breaker requires a threshold in (0, 1] and a positive timeout")
+		}
+	case "moderation":
+		if len(m.Patterns) == 0 {
+			return fmt.Errorf("moderation requires at least one pattern")
+		}
+		for _, pat := range m.Patterns {
+			if _, err := regexp.Compile(pat); err != nil {
+				return fmt.Errorf("moderation pattern: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %q", m.Type)
+	}
+	return nil
+}
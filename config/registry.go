@@ -0,0 +1,238 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"fmt"
+
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/dynamoregistry"
+	_ "github.com/klejdi94/loom/registry/grpcregistry" // registers the protobuf codec
+	"github.com/klejdi94/loom/registry/prommetrics"
+	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// RegistryConfig selects and configures the registry backend. Empty fields take the same
+// defaults as the loom-server flags.
+type RegistryConfig struct {
+	Backend     string `json:"backend"`      // memory, file (default), postgres, redis, dynamodb, or http (a loom-server)
+	Dir         string `json:"dir"`          // file: directory (default .loom)
+	DSN         string `json:"dsn"`          // postgres
+	Table       string `json:"table"`        // postgres: table name (default prompts)
+	Redis       string `json:"redis"`        // redis: address
+	RedisPrefix string `json:"redis_prefix"` // redis: key prefix (default loom:prompts)
+	DynamoTable string `json:"dynamo_table"` // dynamodb: table name (default loom-prompts); AWS config from env
+	URL         string `json:"url"`          // http: server URL
+	APIKey      string `json:"api_key"`      // http: API key sent as a bearer token
+	TrackUsage  bool   `json:"track_usage"`  // count reads per prompt id in memory (see registry.NewTracked)
+	Codec       string `json:"codec"`        // file, redis: json (default), yaml, protobuf, or gzip+ one of them (see registry.WithCodec)
+	Metrics     bool   `json:"metrics"`      // export operation latency and outcomes to Prometheus (see registry/prommetrics)
+	// Promotion lists rules checked before each promote (see registry.NewGuarded).
+	Promotion []PromotionRuleConfig `json:"promotion"`
+	// Signing signs stored prompts and verifies read ones (see registry.NewSigned).
+	Signing *SigningConfig `json:"signing"`
+	// Authz checks every operation against the caller's role (see registry.WithAuthz).
+	Authz *AuthzConfig `json:"authz"`
+}
+
+// AuthzConfig gives principals (API key principals on a loom-server, the -actor of the loom CLI)
+// a role: reader, editor, or releaser. Principals not listed get Default, or nothing if it is empty:
+//
+//	authz:
+//	  default: reader
+//	  roles:
+//	    alice: releaser
+//	    ci: editor
+type AuthzConfig struct {
+	Default string            `json:"default"`
+	Roles   map[string]string `json:"roles"` // principal -> role
+}
+
+// policy returns the registry policy for c.
+func (c *AuthzConfig) policy() (registry.RolePolicy, error) {
+	p := registry.RolePolicy{Default: registry.Role(c.Default), Roles: make(map[string]registry.Role, len(c.Roles))}
+	if c.Default != "" && !registry.ValidRole(p.Default) {
+		return p, fmt.Errorf("unknown default role %q (want reader, editor, or releaser)", c.Default)
+	}
+	for principal, role := range c.Roles {
+		if !registry.ValidRole(registry.Role(role)) {
+			return p, fmt.Errorf("principal %q: unknown role %q (want reader, editor, or releaser)", principal, role)
+		}
+		p.Roles[principal] = registry.Role(role)
+	}
+	return p, nil
+}
+
+// SigningConfig signs prompts on store with PrivateKey and, if TrustedKeys is set, refuses to
+// read prompts not signed by one of them. Keys are base64, as printed by loom keygen:
+//
+//	signing:
+//	  key_id: review
+//	  private_key: ${LOOM_SIGNING_KEY}
+//	  trusted_keys:
+//	    review: 3q2+7w...
+type SigningConfig struct {
+	KeyID       string            `json:"key_id"`       // recorded with each signature
+	PrivateKey  string            `json:"private_key"`  // ed25519 seed or private key
+	TrustedKeys map[string]string `json:"trusted_keys"` // key id -> ed25519 public key
+}
+
+// options returns the registry options for c.
+func (c *SigningConfig) options() ([]registry.SignOption, error) {
+	var opts []registry.SignOption
+	if c.PrivateKey != "" {
+		key, err := registry.ParsePrivateKey(c.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, registry.WithSigningKey(c.KeyID, key))
+	}
+	if len(c.TrustedKeys) > 0 {
+		trusted := make(map[string]ed25519.PublicKey, len(c.TrustedKeys))
+		for id, s := range c.TrustedKeys {
+			pub, err := registry.ParsePublicKey(s)
+			if err != nil {
+				return nil, fmt.Errorf("trusted key %q: %w", id, err)
+			}
+			trusted[id] = pub
+		}
+		opts = append(opts, registry.WithTrustedKeys(trusted))
+	}
+	return opts, nil
+}
+
+// PromotionRuleConfig requires versions promoted to Stage to be in one of the From stages and to
+// carry all of Tags:
+//
+//	promotion:
+//	  - stage: production
+//	    from: [staging]
+//	    tags: [approved]
+type PromotionRuleConfig struct {
+	Stage string   `json:"stage"` // target stage, or * for every promotion
+	From  []string `json:"from"`
+	Tags  []string `json:"tags"`
+}
+
+// rules returns the registry rules for c.
+func (c PromotionRuleConfig) rules() []registry.PromotionRule {
+	var rules []registry.PromotionRule
+	if len(c.From) > 0 {
+		from := make([]registry.Stage, len(c.From))
+		for i, st := range c.From {
+			from[i] = registry.Stage(st)
+		}
+		rules = append(rules, registry.RequireStage(registry.Stage(c.Stage), from...))
+	}
+	if len(c.Tags) > 0 {
+		rules = append(rules, registry.RequireTag(registry.Stage(c.Stage), c.Tags...))
+	}
+	return rules
+}
+
+// Open connects to the backend, creating the Postgres table or DynamoDB table if needed.
+// Database connections stay open for the life of the process.
+func (r RegistryConfig) Open(ctx context.Context) (registry.Registry, error) {
+	reg, err := r.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.Metrics {
+		inst, err := prommetrics.New(prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+		reg = registry.NewInstrumented(reg, orDefault(r.Backend, "file"), inst)
+	}
+	if r.Signing != nil {
+		opts, err := r.Signing.options()
+		if err != nil {
+			return nil, err
+		}
+		reg = registry.NewSigned(reg, opts...)
+	}
+	if len(r.Promotion) > 0 {
+		var rules []registry.PromotionRule
+		for _, c := range r.Promotion {
+			rules = append(rules, c.rules()...)
+		}
+		reg = registry.NewGuarded(reg, rules...)
+	}
+	if r.TrackUsage {
+		reg = registry.NewTracked(reg, registry.NewMemoryUsageStore())
+	}
+	if r.Authz != nil {
+		policy, err := r.Authz.policy()
+		if err != nil {
+			return nil, err
+		}
+		reg = registry.WithAuthz(reg, policy)
+	}
+	return reg, nil
+}
+
+func (r RegistryConfig) open(ctx context.Context) (registry.Registry, error) {
+	var opts []registry.StorageOption
+	if r.Codec != "" {
+		c, err := registry.CodecByName(r.Codec)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, registry.WithCodec(c))
+	}
+	switch r.Backend {
+	case "memory":
+		return registry.NewMemoryRegistry(), nil
+	case "", "file":
+		dir := r.Dir
+		if dir == "" {
+			dir = ".loom"
+		}
+		return registry.NewFileRegistry(dir, opts...)
+	case "postgres":
+		if r.DSN == "" {
+			return nil, fmt.Errorf("postgres backend requires a dsn")
+		}
+		db, err := sql.Open("postgres", r.DSN)
+		if err != nil {
+			return nil, err
+		}
+		pg, err := registry.NewPostgresRegistry(db, orDefault(r.Table, "prompts"), true)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return pg, nil
+	case "redis":
+		if r.Redis == "" {
+			return nil, fmt.Errorf("redis backend requires a redis address")
+		}
+		rdb := redis.NewClient(&redis.Options{Addr: r.Redis})
+		return registry.NewRedisRegistry(rdb, orDefault(r.RedisPrefix, "loom:prompts"), opts...), nil
+	case "dynamodb":
+		dr, err := dynamoregistry.NewFromConfig(ctx, orDefault(r.DynamoTable, "loom-prompts"))
+		if err != nil {
+			return nil, err
+		}
+		if err := dr.CreateTable(ctx); err != nil {
+			return nil, err
+		}
+		return dr, nil
+	case "http":
+		if r.URL == "" {
+			return nil, fmt.Errorf("http backend requires a url")
+		}
+		return registry.NewHTTPClient(r.URL, nil).WithAPIKey(r.APIKey), nil
+	}
+	return nil, fmt.Errorf("unknown registry backend %q", r.Backend)
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Attachment is an image or file sent to the model with a message (see VariableTypeImage,
+// VariableTypeFile, and Message.Attachments). Either URL or Data is set; providers need MIMEType
+// with Data, and with URL for files.
+type Attachment struct {
+	MIMEType string `json:",omitempty"`
+	URL      string `json:",omitempty"`
+	Data     []byte `json:",omitempty"`
+	// Name is the file name, for providers that show it to the model.
+	Name string `json:",omitempty"`
+}
+
+// IsImage reports whether a is an image, by its MIME type.
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MIMEType, "image/")
+}
+
+// DataURL returns a's URL, or its data as a base64 data URL if it has no URL.
+func (a Attachment) DataURL() string {
+	if a.URL != "" {
+		return a.URL
+	}
+	return "data:" + a.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(a.Data)
+}
+
+// ToAttachment converts the value of an image or file variable to an Attachment. It accepts an
+// Attachment or *Attachment, an http(s) or base64 data URL, or an object with "url", "data"
+// (base64), "mime_type", and "name" keys, as decoded from JSON. The MIME type of a URL without one
+// is guessed from its extension.
+func ToAttachment(v interface{}) (Attachment, error) {
+	var a Attachment
+	switch x := v.(type) {
+	case Attachment:
+		a = x
+	case *Attachment:
+		if x == nil {
+			return a, fmt.Errorf("expected an attachment, got nil")
+		}
+		a = *x
+	case string:
+		var err error
+		if a, err = parseAttachmentURL(x); err != nil {
+			return a, err
+		}
+	case map[string]interface{}:
+		a.MIMEType, _ = x["mime_type"].(string)
+		a.Name, _ = x["name"].(string)
+		if u, _ := x["url"].(string); u != "" {
+			parsed, err := parseAttachmentURL(u)
+			if err != nil {
+				return a, err
+			}
+			a.URL, a.Data = parsed.URL, parsed.Data
+			if a.MIMEType == "" {
+				a.MIMEType = parsed.MIMEType
+			}
+		} else if data, _ := x["data"].(string); data != "" {
+			b, err := base64.StdEncoding.DecodeString(data)
+			if err != nil {
+				return a, fmt.Errorf("attachment data: %w", err)
+			}
+			a.Data = b
+		}
+	default:
+		return a, fmt.Errorf("expected an attachment or URL, got %T", v)
+	}
+	if a.URL == "" && len(a.Data) == 0 {
+		return a, fmt.Errorf("attachment has no URL or data")
+	}
+	if a.URL == "" && a.MIMEType == "" {
+		return a, fmt.Errorf("attachment data has no MIME type")
+	}
+	return a, nil
+}
+
+// parseAttachmentURL returns the attachment for an http(s) or base64 data URL.
+func parseAttachmentURL(s string) (Attachment, error) {
+	if rest, ok := strings.CutPrefix(s, "data:"); ok {
+		meta, data, ok := strings.Cut(rest, ",")
+		mimeType, isBase64 := strings.CutSuffix(meta, ";base64")
+		if !ok || !isBase64 || mimeType == "" {
+			return Attachment{}, fmt.Errorf("expected a base64 data URL with a MIME type")
+		}
+		b, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return Attachment{}, fmt.Errorf("data URL: %w", err)
+		}
+		return Attachment{MIMEType: mimeType, Data: b}, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Attachment{}, fmt.Errorf("expected an http(s) or data URL, got %q", s)
+	}
+	mimeType, _, _ := strings.Cut(mime.TypeByExtension(path.Ext(u.Path)), ";")
+	return Attachment{MIMEType: mimeType, URL: s}, nil
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToAttachment(t *testing.T) {
+	a, err := ToAttachment("https://example.com/cat.png")
+	require.NoError(t, err)
+	assert.Equal(t, Attachment{MIMEType: "image/png", URL: "https://example.com/cat.png"}, a)
+
+	a, err = ToAttachment("data:image/jpeg;base64,aGk=")
+	require.NoError(t, err)
+	assert.Equal(t, Attachment{MIMEType: "image/jpeg", Data: []byte("hi")}, a)
+	assert.Equal(t, "data:image/jpeg;base64,aGk=", a.DataURL())
+
+	a, err = ToAttachment(map[string]interface{}{"data": "aGk=", "mime_type": "application/pdf", "name": "a.pdf"})
+	require.NoError(t, err)
+	assert.Equal(t, Attachment{MIMEType: "application/pdf", Data: []byte("hi"), Name: "a.pdf"}, a)
+
+	for _, v := range []interface{}{
+		"cat.png",
+		"data:image/png,hi",
+		map[string]interface{}{"data": "aGk="},
+		Attachment{},
+		42,
+	} {
+		_, err := ToAttachment(v)
+		assert.Error(t, err, "%v", v)
+	}
+}
+
+func TestImage_Validate(t *testing.T) {
+	v := Image(Required())
+	assert.NoError(t, v.Validate("https://example.com/cat.png"))
+	assert.NoError(t, v.Validate(Attachment{MIMEType: "image/png", Data: []byte{1}}))
+	assert.Error(t, v.Validate("https://example.com/report.pdf"), "not an image")
+	assert.Error(t, v.Validate("not a url"))
+	file := File()
+	assert.NoError(t, file.Validate("https://example.com/report.pdf"))
+	assert.Equal(t, Attachment{MIMEType: "image/png", URL: "https://example.com/cat.png"}, v.Coerce("https://example.com/cat.png"))
+
+	list := List(WithItems(VariableTypeImage))
+	assert.Equal(t, []Attachment{{MIMEType: "image/png", URL: "https://example.com/a.png"}}, list.Coerce([]interface{}{"https://example.com/a.png"}))
+}
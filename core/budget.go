@@ -0,0 +1,53 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TokenBudgetKey is the Prompt.Metadata key holding a prompt's TokenBudget.
+const TokenBudgetKey = "loom_token_budget"
+
+// TokenBudget limits the tokens of a rendered prompt, counted over its messages. When a render is
+// over MaxPromptTokens, the template engine first drops the lowest-ranked examples (if
+// DropExamples), then trims the string variable named Truncate from the end; if it still does not
+// fit, Render fails with ErrTokenBudgetExceeded.
+type TokenBudget struct {
+	MaxPromptTokens int    `json:"max_prompt_tokens"`
+	DropExamples    bool   `json:"drop_examples,omitempty"`
+	Truncate        string `json:"truncate,omitempty"`
+}
+
+// Metadata returns b in the form stored under TokenBudgetKey, matching what a registry returns
+// after a JSON round trip.
+func (b TokenBudget) Metadata() map[string]interface{} {
+	m := map[string]interface{}{"max_prompt_tokens": float64(b.MaxPromptTokens)}
+	if b.DropExamples {
+		m["drop_examples"] = true
+	}
+	if b.Truncate != "" {
+		m["truncate"] = b.Truncate
+	}
+	return m
+}
+
+// TokenBudgetOf returns the budget stored in p's metadata, or the zero TokenBudget (no limit) if
+// it has none.
+func TokenBudgetOf(p *Prompt) (TokenBudget, error) {
+	var b TokenBudget
+	v, ok := p.Metadata[TokenBudgetKey]
+	if !ok || v == nil {
+		return b, nil
+	}
+	if budget, ok := v.(TokenBudget); ok {
+		return budget, nil
+	}
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &b)
+	}
+	if err != nil {
+		return b, fmt.Errorf("%s metadata: %w", TokenBudgetKey, err)
+	}
+	return b, nil
+}
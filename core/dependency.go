@@ -0,0 +1,69 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DependenciesKey is the Prompt.Metadata key listing the prompts a prompt depends on, such as
+// shared system preambles or partials (see registry.Resolver).
+const DependenciesKey = "loom_dependencies"
+
+// Dependency refers to another prompt in the same registry. Its template is made available to
+// the dependent prompt as the named template Name (the ID if empty), e.g. {{template "preamble" .}}.
+type Dependency struct {
+	ID string `json:"id"`
+	// Version is a version, an "@alias", or empty for the production version.
+	Version string `json:"version,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// TemplateName returns the name under which d's template is defined.
+func (d Dependency) TemplateName() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.ID
+}
+
+// DependencyMetadata returns deps in the form stored under DependenciesKey, matching what a
+// registry returns after a JSON round trip.
+func DependencyMetadata(deps []Dependency) []interface{} {
+	out := make([]interface{}, len(deps))
+	for i, d := range deps {
+		m := map[string]interface{}{"id": d.ID}
+		if d.Version != "" {
+			m["version"] = d.Version
+		}
+		if d.Name != "" {
+			m["name"] = d.Name
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// DependenciesOf returns the dependencies stored in p's metadata, or nil if it has none.
+func DependenciesOf(p *Prompt) ([]Dependency, error) {
+	v, ok := p.Metadata[DependenciesKey]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	if deps, ok := v.([]Dependency); ok {
+		return deps, nil
+	}
+	var deps []Dependency
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &deps)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s metadata: %w", DependenciesKey, err)
+	}
+	for i, d := range deps {
+		if d.ID == "" {
+			return nil, fmt.Errorf("%s metadata: dependency %d has no id", DependenciesKey, i)
+		}
+	}
+	return deps, nil
+}
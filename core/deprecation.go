@@ -0,0 +1,105 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeprecationKey is the Prompt.Metadata key holding a deprecated prompt version's Deprecation.
+// Versions without it are not deprecated.
+const DeprecationKey = "loom_deprecation"
+
+// Deprecation marks a prompt version as deprecated: it still works, but consumers should move to
+// DeprecatedBy, the replacing prompt as "id@version" (or just "id" for its production version),
+// before Sunset, the date after which the version may be archived or deleted. All fields are
+// optional.
+type Deprecation struct {
+	DeprecatedBy string    `json:"deprecated_by,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	Sunset       time.Time `json:"sunset"`
+}
+
+// Metadata returns d in the form stored under DeprecationKey, matching what a registry returns
+// after a JSON round trip.
+func (d Deprecation) Metadata() map[string]interface{} {
+	m := map[string]interface{}{}
+	if d.DeprecatedBy != "" {
+		m["deprecated_by"] = d.DeprecatedBy
+	}
+	if d.Reason != "" {
+		m["reason"] = d.Reason
+	}
+	if !d.Sunset.IsZero() {
+		m["sunset"] = d.Sunset.UTC().Format(time.RFC3339)
+	}
+	return m
+}
+
+// Replacement splits DeprecatedBy into the replacing prompt's id and version ("" for its
+// production version).
+func (d Deprecation) Replacement() (id, version string) {
+	if i := strings.LastIndex(d.DeprecatedBy, "@"); i >= 0 {
+		return d.DeprecatedBy[:i], d.DeprecatedBy[i+1:]
+	}
+	return d.DeprecatedBy, ""
+}
+
+// DeprecationOf returns the deprecation stored in p's metadata, or nil if p is not deprecated.
+func DeprecationOf(p *Prompt) (*Deprecation, error) {
+	v, ok := p.Metadata[DeprecationKey]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	if d, ok := v.(Deprecation); ok {
+		return &d, nil
+	}
+	var d Deprecation
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &d)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s metadata: %w", DeprecationKey, err)
+	}
+	return &d, nil
+}
+
+// DeprecationWarning reports that a prompt version in use is deprecated. It implements error so it
+// can be logged or returned like one, but it does not mean the prompt failed to load.
+type DeprecationWarning struct {
+	ID      string
+	Version string
+	Deprecation
+}
+
+// Error describes the deprecation, e.g. "prompt summarize@1.0.0 is deprecated (too verbose); use
+// summarize@2.0.0 instead; sunset 2026-12-31".
+func (w *DeprecationWarning) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "prompt %s@%s is deprecated", w.ID, w.Version)
+	if w.Reason != "" {
+		fmt.Fprintf(&sb, " (%s)", w.Reason)
+	}
+	if w.DeprecatedBy != "" {
+		fmt.Fprintf(&sb, "; use %s instead", w.DeprecatedBy)
+	}
+	if !w.Sunset.IsZero() {
+		fmt.Fprintf(&sb, "; sunset %s", w.Sunset.Format(time.DateOnly))
+	}
+	return sb.String()
+}
+
+// CheckDeprecated returns a warning if p is deprecated, and nil otherwise. A deprecation whose
+// metadata cannot be read is reported without details.
+func CheckDeprecated(p *Prompt) *DeprecationWarning {
+	if _, ok := p.Metadata[DeprecationKey]; !ok {
+		return nil
+	}
+	w := &DeprecationWarning{ID: p.ID, Version: p.Version}
+	if d, err := DeprecationOf(p); err == nil && d != nil {
+		w.Deprecation = *d
+	}
+	return w
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecation(t *testing.T) {
+	p := &Prompt{ID: "p", Version: "1.0.0"}
+	d, err := DeprecationOf(p)
+	require.NoError(t, err)
+	assert.Nil(t, d)
+	assert.Nil(t, CheckDeprecated(p))
+
+	// After a JSON round trip, as from a registry.
+	dep := Deprecation{DeprecatedBy: "q@2.0.0", Sunset: time.Date(2027, 1, 15, 0, 0, 0, 0, time.UTC)}
+	data, err := json.Marshal(dep.Metadata())
+	require.NoError(t, err)
+	var md interface{}
+	require.NoError(t, json.Unmarshal(data, &md))
+	p.Metadata = map[string]interface{}{DeprecationKey: md}
+	d, err = DeprecationOf(p)
+	require.NoError(t, err)
+	assert.Equal(t, &dep, d)
+	id, version := d.Replacement()
+	assert.Equal(t, []string{"q", "2.0.0"}, []string{id, version})
+	assert.Equal(t, "prompt p@1.0.0 is deprecated; use q@2.0.0 instead; sunset 2027-01-15", CheckDeprecated(p).Error())
+
+	id, version = Deprecation{DeprecatedBy: "q"}.Replacement()
+	assert.Equal(t, []string{"q", ""}, []string{id, version})
+
+	p.Metadata[DeprecationKey] = map[string]interface{}{}
+	assert.Equal(t, "prompt p@1.0.0 is deprecated", CheckDeprecated(p).Error())
+	p.Metadata[DeprecationKey] = "soon"
+	_, err = DeprecationOf(p)
+	assert.Error(t, err)
+	assert.NotNil(t, CheckDeprecated(p), "unreadable details still mark the prompt deprecated")
+}
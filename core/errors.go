@@ -5,10 +5,12 @@ import "errors"
 
 // Sentinel errors for prompt operations.
 var (
-	ErrPromptNotFound   = errors.New("prompt not found")
-	ErrInvalidVersion   = errors.New("invalid version format")
-	ErrValidationFailed = errors.New("validation failed")
-	ErrRenderFailed     = errors.New("template render failed")
+	ErrPromptNotFound      = errors.New("prompt not found")
+	ErrInvalidVersion      = errors.New("invalid version format")
+	ErrValidationFailed    = errors.New("validation failed")
+	ErrRenderFailed        = errors.New("template render failed")
+	ErrConflict            = errors.New("revision conflict")
+	ErrTokenBudgetExceeded = errors.New("prompt exceeds its token budget")
 )
 
 // ValidationError carries field-level validation context.
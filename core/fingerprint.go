@@ -0,0 +1,41 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// fingerprintContent is the part of a prompt covered by Fingerprint. Empty and nil collections
+// are treated alike so that registry backends which round-trip them differently agree.
+type fingerprintContent struct {
+	Name         string `json:",omitempty"`
+	Description  string `json:",omitempty"`
+	System       string `json:",omitempty"`
+	Template     string
+	Messages     []Message              `json:",omitempty"`
+	Variables    []Variable             `json:",omitempty"`
+	Examples     []Example              `json:",omitempty"`
+	Tools        []Tool                 `json:",omitempty"`
+	Metadata     map[string]interface{} `json:",omitempty"`
+	OutputSchema map[string]interface{} `json:",omitempty"`
+}
+
+// Fingerprint returns a stable hash of p's content as "sha256:<hex>": equal for any two prompts
+// with the same name, description, system and user templates, messages, variables, examples,
+// tools, metadata, and output schema, however they were built or stored. It is computed over
+// canonical JSON, so map order does not matter, and leaves out the id and version (so a copy or
+// fork of a version has the same fingerprint), Revision, and the timestamps.
+//
+// Registries record it as VersionInfo.Checksum and use it to skip storing unchanged versions
+// (see registry.StoreNext); record it with analytics runs to tell apart runs of different
+// content under the same version.
+func (p *Prompt) Fingerprint() string {
+	data, _ := json.Marshal(fingerprintContent{
+		Name: p.Name, Description: p.Description, System: p.System, Template: p.Template, Messages: p.Messages,
+		Variables: p.Variables, Examples: p.Examples, Tools: p.Tools, Metadata: p.Metadata,
+		OutputSchema: p.OutputSchema,
+	})
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrompt_Fingerprint(t *testing.T) {
+	p := &Prompt{
+		ID: "p", Version: "1.0.0", System: "sys", Template: "Hi {{.name}}",
+		Variables: []Variable{{Name: "name", Type: VariableTypeString}},
+		Metadata:  map[string]interface{}{"a": 1, "b": "x"},
+		CreatedAt: time.Now(), Revision: 3,
+	}
+	fp := p.Fingerprint()
+	assert.True(t, strings.HasPrefix(fp, "sha256:"))
+	assert.Len(t, fp, len("sha256:")+64)
+
+	same := p.Copy()
+	same.ID, same.Version, same.Revision = "q", "2.0.0", 0
+	same.CreatedAt, same.UpdatedAt = time.Time{}, time.Now()
+	same.Metadata = map[string]interface{}{"b": "x", "a": 1}
+	same.Messages, same.Examples = []Message{}, nil
+	assert.Equal(t, fp, same.Fingerprint(), "id, version, revision, timestamps, and empty collections are ignored")
+
+	changed := p.Copy()
+	changed.Template = "Hello {{.name}}"
+	assert.NotEqual(t, fp, changed.Fingerprint())
+	changed = p.Copy()
+	changed.Metadata["a"] = 2
+	assert.NotEqual(t, fp, changed.Fingerprint())
+}
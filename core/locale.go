@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LocalesKey is the Prompt.Metadata key holding a prompt's Locales. Kept in metadata, the variants
+// are stored, versioned, and fingerprinted with the prompt by every registry backend.
+const LocalesKey = "loom_locales"
+
+// LocaleInputKey is the input variable selecting a prompt's locale variant when rendering. It takes
+// precedence over a locale set with WithLocale.
+const LocaleInputKey = "locale"
+
+// Localization is a prompt's text in one language. Empty fields keep the prompt's own, so a variant
+// may translate only the system prompt; Messages, if set, replace all of the prompt's messages.
+// Variables, examples, and tools are shared by every variant.
+type Localization struct {
+	System   string    `json:"system,omitempty"`
+	Template string    `json:"template,omitempty"`
+	Messages []Message `json:"messages,omitempty"`
+}
+
+// Locales maps locale tags (e.g. "fr", "pt-BR") to a prompt's variants in those languages. The
+// prompt's own System, Template, and Messages are the default, used for any other locale.
+type Locales map[string]Localization
+
+// Metadata returns l in the form stored under LocalesKey, matching what a registry returns after a
+// JSON round trip.
+func (l Locales) Metadata() map[string]interface{} {
+	m := map[string]interface{}{}
+	data, _ := json.Marshal(l)
+	_ = json.Unmarshal(data, &m)
+	return m
+}
+
+// Match returns the tag of l's variant for locale: the same tag, compared without case and with
+// "_" read as "-", or else its language alone ("fr" for "fr-CA"). It returns "" if l has neither.
+func (l Locales) Match(locale string) string {
+	want := normalizeLocale(locale)
+	if want == "" {
+		return ""
+	}
+	lang, _, _ := strings.Cut(want, "-")
+	match := ""
+	for tag := range l {
+		switch normalizeLocale(tag) {
+		case want:
+			return tag
+		case lang:
+			match = tag
+		}
+	}
+	return match
+}
+
+func normalizeLocale(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// LocalesOf returns the variants stored in p's metadata, or nil if it has none.
+func LocalesOf(p *Prompt) (Locales, error) {
+	v, ok := p.Metadata[LocalesKey]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	if locales, ok := v.(Locales); ok {
+		return locales, nil
+	}
+	var l Locales
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &l)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s metadata: %w", LocalesKey, err)
+	}
+	return l, nil
+}
+
+// Localize returns p in locale: a copy with the System, Template, and Messages of its matching
+// variant (see Locales.Match), or p itself if locale is empty or p has no variant for it.
+func (p *Prompt) Localize(locale string) (*Prompt, error) {
+	if locale == "" {
+		return p, nil
+	}
+	locales, err := LocalesOf(p)
+	if err != nil {
+		return nil, err
+	}
+	tag := locales.Match(locale)
+	if tag == "" {
+		return p, nil
+	}
+	variant := locales[tag]
+	q := p.Copy()
+	q.renderer = p.renderer
+	if variant.System != "" {
+		q.System = variant.System
+	}
+	if variant.Template != "" {
+		q.Template = variant.Template
+	}
+	if len(variant.Messages) > 0 {
+		q.Messages = append([]Message(nil), variant.Messages...)
+	}
+	return q, nil
+}
+
+// localeKey is the context key for the caller's locale.
+type localeKey struct{}
+
+// WithLocale returns a context in which prompts render their variant for locale (see Locales),
+// unless the input sets LocaleInputKey.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext returns the locale set with WithLocale, or "".
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
+
+// LocaleOf returns the locale a render of input in ctx uses: input's LocaleInputKey if it is a
+// non-empty string, else the context's.
+func LocaleOf(ctx context.Context, input Input) string {
+	if locale, _ := input[LocaleInputKey].(string); locale != "" {
+		return locale
+	}
+	return LocaleFromContext(ctx)
+}
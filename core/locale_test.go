@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocales_Match(t *testing.T) {
+	l := Locales{"fr": {}, "pt-BR": {}, "en_GB": {}}
+	assert.Equal(t, "fr", l.Match("fr"))
+	assert.Equal(t, "fr", l.Match("fr-CA"))
+	assert.Equal(t, "pt-BR", l.Match("pt_br"))
+	assert.Equal(t, "en_GB", l.Match("en-GB"))
+	assert.Equal(t, "", l.Match("pt"), "a language does not match its regional variants")
+	assert.Equal(t, "", l.Match("de"))
+	assert.Equal(t, "", l.Match(""))
+}
+
+func TestPrompt_Localize(t *testing.T) {
+	p := &Prompt{
+		System:   "sys",
+		Template: "tpl",
+		Messages: []Message{{Role: RoleAssistant, Content: "hi"}},
+		Metadata: map[string]interface{}{
+			LocalesKey: Locales{"fr": {Template: "fr tpl", Messages: []Message{{Role: RoleAssistant, Content: "salut"}}}}.Metadata(),
+		},
+	}
+	fr, err := p.Localize("fr")
+	require.NoError(t, err)
+	assert.Equal(t, "sys", fr.System)
+	assert.Equal(t, "fr tpl", fr.Template)
+	assert.Equal(t, []Message{{Role: RoleAssistant, Content: "salut"}}, fr.Messages)
+	assert.Equal(t, "tpl", p.Template, "the prompt is not modified")
+
+	same, err := p.Localize("de")
+	require.NoError(t, err)
+	assert.Same(t, p, same)
+
+	p.Metadata[LocalesKey] = "not a map"
+	_, err = p.Localize("fr")
+	assert.Error(t, err)
+}
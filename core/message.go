@@ -0,0 +1,74 @@
+package core
+
+import "fmt"
+
+// Message roles.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a conversation-style prompt (see Prompt.Messages). Content is a template,
+// rendered like Prompt.Template. A message with History set is a placeholder instead: it expands to
+// the turns held by the input variable of that name (see HistoryMessages), which are not rendered.
+// Attachments are images and files sent with the turn.
+type Message struct {
+	Role        string
+	Content     string
+	History     string
+	Attachments []Attachment `json:",omitempty"`
+}
+
+// Validate checks that the message is a placeholder or has a known role.
+func (m Message) Validate() error {
+	if m.History != "" {
+		if m.Role != "" || m.Content != "" || len(m.Attachments) > 0 {
+			return fmt.Errorf("history placeholder %q must not have a role, content, or attachments", m.History)
+		}
+		return nil
+	}
+	switch m.Role {
+	case RoleSystem, RoleUser, RoleAssistant:
+		return nil
+	case "":
+		return fmt.Errorf("message role is required")
+	}
+	return fmt.Errorf("unknown message role %q", m.Role)
+}
+
+// HistoryMessages converts the value of a history variable to messages. It accepts []Message and
+// lists of maps with "role" and "content" keys, as decoded from JSON; nil means no turns.
+func HistoryMessages(v interface{}) ([]Message, error) {
+	switch h := v.(type) {
+	case nil:
+		return nil, nil
+	case []Message:
+		return h, nil
+	case []map[string]string:
+		out := make([]Message, len(h))
+		for i, m := range h {
+			out[i] = Message{Role: m["role"], Content: m["content"]}
+		}
+		return out, nil
+	case []map[string]interface{}:
+		items := make([]interface{}, len(h))
+		for i, m := range h {
+			items[i] = m
+		}
+		return HistoryMessages(items)
+	case []interface{}:
+		out := make([]Message, len(h))
+		for i, item := range h {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("turn %d: expected an object with role and content, got %T", i, item)
+			}
+			role, _ := m["role"].(string)
+			content, _ := m["content"].(string)
+			out[i] = Message{Role: role, Content: content}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("expected a list of messages, got %T", v)
+}
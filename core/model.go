@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ModelConfigKey is the Prompt.Metadata key holding a prompt's ModelConfig.
+const ModelConfigKey = "loom_model"
+
+// ResponseFormat is the kind of output a prompt asks the model for. Providers map it to their JSON
+// mode; the zero value means ResponseFormatJSONSchema for prompts with an OutputSchema and
+// ResponseFormatText for others.
+type ResponseFormat string
+
+const (
+	// ResponseFormatText asks for free text, without a JSON mode even if the prompt has an
+	// OutputSchema.
+	ResponseFormatText ResponseFormat = "text"
+	// ResponseFormatJSONObject asks for a valid JSON object of any shape.
+	ResponseFormatJSONObject ResponseFormat = "json_object"
+	// ResponseFormatJSONSchema asks for JSON matching the prompt's OutputSchema (a JSON object if
+	// it has none).
+	ResponseFormatJSONSchema ResponseFormat = "json_schema"
+)
+
+// Valid reports whether f is empty or one of the ResponseFormat constants.
+func (f ResponseFormat) Valid() bool {
+	switch f {
+	case "", ResponseFormatText, ResponseFormatJSONObject, ResponseFormatJSONSchema:
+		return true
+	}
+	return false
+}
+
+// ModelConfig holds a prompt's default generation parameters, versioned with its template. The
+// executor uses each one that is set unless the request sets it too; zero values are unset.
+type ModelConfig struct {
+	Model          string         `json:"model,omitempty"`
+	Temperature    float64        `json:"temperature,omitempty"`
+	MaxTokens      int            `json:"max_tokens,omitempty"`
+	StopTokens     []string       `json:"stop_tokens,omitempty"`
+	ResponseFormat ResponseFormat `json:"response_format,omitempty"`
+}
+
+// Metadata returns c in the form stored under ModelConfigKey, matching what a registry returns
+// after a JSON round trip.
+func (c ModelConfig) Metadata() map[string]interface{} {
+	m := map[string]interface{}{}
+	if c.Model != "" {
+		m["model"] = c.Model
+	}
+	if c.Temperature != 0 {
+		m["temperature"] = c.Temperature
+	}
+	if c.MaxTokens != 0 {
+		m["max_tokens"] = float64(c.MaxTokens)
+	}
+	if len(c.StopTokens) > 0 {
+		stop := make([]interface{}, len(c.StopTokens))
+		for i, s := range c.StopTokens {
+			stop[i] = s
+		}
+		m["stop_tokens"] = stop
+	}
+	if c.ResponseFormat != "" {
+		m["response_format"] = string(c.ResponseFormat)
+	}
+	return m
+}
+
+// ModelConfigOf returns the model config stored in p's metadata, or the zero ModelConfig if it
+// has none.
+func ModelConfigOf(p *Prompt) (ModelConfig, error) {
+	var c ModelConfig
+	v, ok := p.Metadata[ModelConfigKey]
+	if !ok || v == nil {
+		return c, nil
+	}
+	if config, ok := v.(ModelConfig); ok {
+		return config, nil
+	}
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err == nil && !c.ResponseFormat.Valid() {
+		err = fmt.Errorf("unknown response format %q", c.ResponseFormat)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%s metadata: %w", ModelConfigKey, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelConfigOf(t *testing.T) {
+	config := ModelConfig{Model: "gpt-4o", Temperature: 0.3, MaxTokens: 512, StopTokens: []string{"\n\n"}, ResponseFormat: ResponseFormatJSONObject}
+	p := &Prompt{Metadata: map[string]interface{}{ModelConfigKey: config.Metadata()}}
+	got, err := ModelConfigOf(p)
+	require.NoError(t, err)
+	assert.Equal(t, config, got)
+
+	// As returned by a registry.
+	data, err := json.Marshal(p.Metadata)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &p.Metadata))
+	assert.Equal(t, config.Metadata(), p.Metadata[ModelConfigKey])
+	got, err = ModelConfigOf(p)
+	require.NoError(t, err)
+	assert.Equal(t, config, got)
+
+	got, err = ModelConfigOf(&Prompt{})
+	require.NoError(t, err)
+	assert.Zero(t, got)
+	_, err = ModelConfigOf(&Prompt{Metadata: map[string]interface{}{ModelConfigKey: "gpt-4o"}})
+	assert.Error(t, err)
+	_, err = ModelConfigOf(&Prompt{Metadata: map[string]interface{}{ModelConfigKey: map[string]interface{}{"response_format": "xml"}}})
+	assert.EqualError(t, err, `loom_model metadata: unknown response format "xml"`)
+}
@@ -16,6 +16,10 @@ type Example struct {
 
 // Prompt represents a versioned prompt template.
 // Renderer is set by the builder when using the template engine.
+//
+// Messages are conversation turns (e.g. assistant few-shot turns or a history placeholder) sent
+// between System and Template; either may be empty for a prompt made only of messages. The values
+// of image and file variables are attached to the last user message when rendered.
 type Prompt struct {
 	ID          string
 	Version     string
@@ -23,12 +27,20 @@ type Prompt struct {
 	Description string
 	System      string
 	Template    string
+	Messages    []Message
 	Variables   []Variable
 	Examples    []Example
+	Tools       []Tool
 	Metadata    map[string]interface{}
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
-	renderer    Renderer // optional; set by builder for Render()
+	// OutputSchema is a JSON Schema object the model's output must match, if set. Providers with a
+	// JSON mode are asked for matching output, and the executor can check it (see ValidateSchema).
+	OutputSchema map[string]interface{}
+	// Revision is set by the registry on each Store (1 for a new version, then incremented).
+	// Pass it back to StoreIfMatch to detect concurrent edits; zero means not yet stored.
+	Revision int64
+	renderer Renderer // optional; set by builder for Render()
 }
 
 // ErrNoRenderer is returned when Render is called without a renderer configured.
@@ -38,10 +50,36 @@ var ErrNoRenderer = errors.New("no renderer configured on prompt")
 type Input map[string]interface{}
 
 // Rendered holds the result of rendering a prompt (system + user message).
+// Examples are the few-shot examples selected for this input (see package fewshot).
+//
+// Messages is the whole conversation: System as a system message (if not empty), the prompt's
+// Messages with history placeholders expanded, then the rendered Template as a user message (if
+// not empty). User is the rendered Template or, if the prompt has none, the last user message.
+// Tools are the prompt's tool definitions, which the executor offers to the model. Tokens are
+// counted by the renderer, so callers can check the prompt against a model's context window
+// without tokenizing it again.
 type Rendered struct {
-	System string
-	User   string
-	Input  Input
+	System   string
+	User     string
+	Messages []Message
+	Tools    []Tool
+	Input    Input
+	Examples []Example
+	Tokens   TokenCounts
+	// Sensitive names the variables marked Variable.Sensitive; the Redacted methods remove their
+	// values from the render for logging.
+	Sensitive []string
+}
+
+// TokenCounts are the token counts of a Rendered prompt, by the renderer's counter (for the
+// template engine, see template.WithTokenCounter). Examples counts the selected examples as the
+// engine's example formatter writes them; they are part of System or User (or both) when the
+// prompt renders them. Total counts every message, and is what a token budget limits.
+type TokenCounts struct {
+	System   int
+	User     int
+	Examples int
+	Total    int
 }
 
 // Renderer is implemented by the template package to render prompts.
@@ -92,12 +130,20 @@ func (p *Prompt) VariableMap() map[string]Variable {
 // Copy returns a deep copy of the prompt with no renderer set.
 func (p *Prompt) Copy() *Prompt {
 	q := *p
+	q.Messages = append([]Message(nil), p.Messages...)
 	q.Variables = append([]Variable(nil), p.Variables...)
 	q.Examples = append([]Example(nil), p.Examples...)
+	q.Tools = append([]Tool(nil), p.Tools...)
 	q.Metadata = make(map[string]interface{})
 	for k, v := range p.Metadata {
 		q.Metadata[k] = v
 	}
+	if p.OutputSchema != nil {
+		q.OutputSchema = make(map[string]interface{}, len(p.OutputSchema))
+		for k, v := range p.OutputSchema {
+			q.OutputSchema[k] = v
+		}
+	}
 	q.renderer = nil
 	return &q
 }
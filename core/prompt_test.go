@@ -29,16 +29,59 @@ func TestPrompt_Render_NoRenderer(t *testing.T) {
 func TestPrompt_Copy(t *testing.T) {
 	p := &Prompt{
 		ID: "x", Version: "1",
+		Messages:  []Message{{Role: RoleAssistant, Content: "ok"}},
 		Variables: []Variable{{Name: "a", Type: VariableTypeString}},
 		Examples:  []Example{{Output: "out"}},
+		Tools:     []Tool{{Name: "lookup"}},
 		Metadata:  map[string]interface{}{"k": "v"},
 	}
 	q := p.Copy()
 	require.NotSame(t, p, q)
 	assert.Equal(t, p.ID, q.ID)
 	assert.Equal(t, p.Version, q.Version)
+	assert.Equal(t, p.Messages, q.Messages)
+	q.Messages[0].Content = "changed"
+	assert.Equal(t, "ok", p.Messages[0].Content)
 	assert.NotSame(t, p.Variables, q.Variables)
 	assert.NotSame(t, p.Examples, q.Examples)
+	assert.Equal(t, p.Tools, q.Tools)
 	assert.NotSame(t, p.Metadata, q.Metadata)
 	assert.Nil(t, q.renderer)
 }
+
+func TestTool_Validate(t *testing.T) {
+	assert.Error(t, Tool{}.Validate())
+	assert.NoError(t, Tool{Name: "lookup"}.Validate())
+	assert.NoError(t, Tool{Name: "lookup", Parameters: map[string]interface{}{"type": "object"}}.Validate())
+	assert.Error(t, Tool{Name: "lookup", Parameters: map[string]interface{}{"type": "string"}}.Validate())
+}
+
+func TestMessage_Validate(t *testing.T) {
+	assert.NoError(t, Message{Role: RoleUser, Content: "hi"}.Validate())
+	assert.NoError(t, Message{Role: RoleAssistant}.Validate())
+	assert.NoError(t, Message{History: "history"}.Validate())
+	assert.Error(t, Message{Content: "hi"}.Validate())
+	assert.Error(t, Message{Role: "bot", Content: "hi"}.Validate())
+	assert.Error(t, Message{Role: RoleUser, History: "history"}.Validate())
+}
+
+func TestHistoryMessages(t *testing.T) {
+	want := []Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}}
+	for _, v := range []interface{}{
+		want,
+		[]map[string]string{{"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}},
+		[]map[string]interface{}{{"role": "user", "content": "hi"}, {"role": "assistant", "content": "hello"}},
+		[]interface{}{map[string]interface{}{"role": "user", "content": "hi"}, map[string]interface{}{"role": "assistant", "content": "hello"}},
+	} {
+		got, err := HistoryMessages(v)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	got, err := HistoryMessages(nil)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+	_, err = HistoryMessages("hi")
+	assert.Error(t, err)
+	_, err = HistoryMessages([]interface{}{"hi"})
+	assert.Error(t, err)
+}
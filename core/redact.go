@@ -0,0 +1,106 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces the values of sensitive variables (see Variable.Sensitive) in redacted views
+// of a render and in validation errors.
+const Redacted = "[REDACTED]"
+
+// Redact returns text with the values of r's sensitive variables (see Rendered.Sensitive)
+// replaced by Redacted. Values are matched as they are formatted into templates, so a value a
+// template transforms (e.g. with upper) is not found; keep such variables out of logs entirely.
+func (r *Rendered) Redact(text string) string {
+	if len(r.Sensitive) == 0 {
+		return text
+	}
+	var values []string
+	for _, name := range r.Sensitive {
+		values = append(values, sensitiveStrings(r.Input[name])...)
+	}
+	return redactStrings(text, values)
+}
+
+// RedactedUser returns User with sensitive values redacted (see Redact), for logging.
+func (r *Rendered) RedactedUser() string {
+	return r.Redact(r.User)
+}
+
+// RedactedSystem returns System with sensitive values redacted (see Redact), for logging.
+func (r *Rendered) RedactedSystem() string {
+	return r.Redact(r.System)
+}
+
+// RedactedMessages returns a copy of Messages with sensitive values redacted from their content
+// (see Redact), for logging.
+func (r *Rendered) RedactedMessages() []Message {
+	out := make([]Message, len(r.Messages))
+	for i, m := range r.Messages {
+		m.Content = r.Redact(m.Content)
+		out[i] = m
+	}
+	return out
+}
+
+// RedactedInput returns a copy of Input with the values of sensitive variables replaced by
+// Redacted, for logging.
+func (r *Rendered) RedactedInput() Input {
+	if r.Input == nil {
+		return nil
+	}
+	out := make(Input, len(r.Input))
+	for k, v := range r.Input {
+		out[k] = v
+	}
+	for _, name := range r.Sensitive {
+		if _, ok := out[name]; ok {
+			out[name] = Redacted
+		}
+	}
+	return out
+}
+
+// redactError returns err, a validation error for value, without value in it.
+func redactError(name string, value interface{}, err error) error {
+	msg := err.Error()
+	if ve, ok := err.(*ValidationError); ok {
+		msg = ve.Message
+	}
+	return &ValidationError{Field: name, Value: Redacted, Message: redactStrings(msg, sensitiveStrings(value))}
+}
+
+// sensitiveStrings returns the forms in which value may appear in rendered text: itself for
+// strings and numbers, and its elements' forms for lists. Booleans are left out.
+func sensitiveStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case nil, bool:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var out []string
+		for _, e := range v {
+			out = append(out, sensitiveStrings(e)...)
+		}
+		return out
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+// redactStrings replaces each of values in text by Redacted, longest first so that a value
+// containing another is replaced whole.
+func redactStrings(text string, values []string) string {
+	values = append([]string(nil), values...)
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		if v != "" {
+			text = strings.ReplaceAll(text, v, Redacted)
+		}
+	}
+	return text
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRendered_Redact(t *testing.T) {
+	r := &Rendered{
+		System:    "Customer: Ada Lovelace",
+		User:      "Email ada@example.com about order 42, cc Ada",
+		Messages:  []Message{{Role: RoleUser, Content: "Email ada@example.com about order 42, cc Ada"}},
+		Input:     Input{"name": "Ada Lovelace", "email": "ada@example.com", "order": 42, "aliases": []interface{}{"Ada"}},
+		Sensitive: []string{"name", "email", "aliases"},
+	}
+	assert.Equal(t, "Customer: [REDACTED]", r.RedactedSystem(), "longer values are replaced first")
+	assert.Equal(t, "Email [REDACTED] about order 42, cc [REDACTED]", r.RedactedUser())
+	assert.Equal(t, "Email [REDACTED] about order 42, cc [REDACTED]", r.RedactedMessages()[0].Content)
+	assert.Equal(t, "Email ada@example.com about order 42, cc Ada", r.User, "the render is not modified")
+	assert.Equal(t, Input{"name": Redacted, "email": Redacted, "order": 42, "aliases": Redacted}, r.RedactedInput())
+	assert.Equal(t, "Ada Lovelace", r.Input["name"])
+
+	plain := &Rendered{User: "hi Ada", Input: Input{"name": "Ada"}}
+	assert.Equal(t, "hi Ada", plain.RedactedUser())
+}
+
+func TestVariable_Validate_Sensitive(t *testing.T) {
+	max := 10.0
+	v := Int(Max(max), Sensitive())
+	v.Name = "salary"
+	err := v.Validate(12345)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "12345")
+	var ve *ValidationError
+	require.ErrorAs(t, err, &ve)
+	assert.Equal(t, Redacted, ve.Value)
+
+	v.Sensitive = false
+	assert.Contains(t, v.Validate(12345).Error(), "12345")
+}
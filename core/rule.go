@@ -0,0 +1,169 @@
+package core
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ValidationRule is a serializable validation check on a variable (see Variable.Rules). Rule names
+// a check registered with RegisterRule or built in, and Args are its parameters. Unlike a
+// ValidationFunc, a rule survives registry round-trips: it is stored as data and turned back into
+// a check by Compile when the variable is validated.
+//
+// Built-in rules:
+//
+//	min_len n, max_len n  string characters, or list and object elements
+//	min x, max x          numeric bounds
+//	pattern expr          regular expression the string must match
+//	one_of v...           allowed values, compared with the value's default formatting
+//	not_blank             a string with non-space characters
+//	email                 an email address
+//	url                   an absolute URL
+type ValidationRule struct {
+	Rule string
+	Args []string `json:",omitempty"`
+	// Message replaces the check's error message if set.
+	Message string `json:",omitempty"`
+}
+
+// RuleFunc builds the check for a rule from its arguments. It returns an error if the arguments
+// are invalid.
+type RuleFunc func(args []string) (ValidationFunc, error)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{
+		"min_len":   lengthRule(func(v *Variable, n int) { v.MinLen = n }),
+		"max_len":   lengthRule(func(v *Variable, n int) { v.MaxLen = n }),
+		"min":       boundRule(func(v *Variable, x float64) { v.Min = &x }),
+		"max":       boundRule(func(v *Variable, x float64) { v.Max = &x }),
+		"pattern":   patternRule,
+		"one_of":    oneOfRule,
+		"not_blank": stringRule(func(s string) bool { return strings.TrimSpace(s) != "" }, "must not be blank"),
+		"email":     stringRule(isEmail, "expected an email address"),
+		"url":       stringRule(isURL, "expected an absolute URL"),
+	}
+)
+
+// RegisterRule makes a custom check available to rules named name, replacing any registered
+// before. Register rules in init, in every process that validates prompts using them; validating
+// a variable with an unregistered rule fails.
+func RegisterRule(name string, fn RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = fn
+}
+
+// Compile returns the check r describes.
+func (r ValidationRule) Compile() (ValidationFunc, error) {
+	rulesMu.RLock()
+	fn, ok := rules[r.Rule]
+	rulesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown validation rule %q (is it registered?)", r.Rule)
+	}
+	check, err := fn(r.Args)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", r.Rule, err)
+	}
+	return check, nil
+}
+
+// checkRules validates value against each of v's rules.
+func (v *Variable) checkRules(value interface{}) error {
+	for _, r := range v.Rules {
+		check, err := r.Compile()
+		if err != nil {
+			return &ValidationError{Field: v.Name, Value: value, Message: err.Error()}
+		}
+		if err := check(value); err != nil {
+			msg := r.Message
+			if msg == "" {
+				msg = err.Error()
+				if ve, ok := err.(*ValidationError); ok {
+					msg = ve.Message
+				}
+			}
+			return &ValidationError{Field: v.Name, Value: value, Message: msg}
+		}
+	}
+	return nil
+}
+
+// constraintCheck returns a check applying the constraints set on a Variable by set.
+func constraintCheck(set func(v *Variable)) ValidationFunc {
+	v := &Variable{}
+	set(v)
+	return v.checkConstraints
+}
+
+func lengthRule(set func(v *Variable, n int)) RuleFunc {
+	return func(args []string) (ValidationFunc, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid length %q", args[0])
+		}
+		return constraintCheck(func(v *Variable) { set(v, n) }), nil
+	}
+}
+
+func boundRule(set func(v *Variable, x float64)) RuleFunc {
+	return func(args []string) (ValidationFunc, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		x, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", args[0])
+		}
+		return constraintCheck(func(v *Variable) { set(v, x) }), nil
+	}
+}
+
+func patternRule(args []string) (ValidationFunc, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	if _, err := compilePattern(args[0]); err != nil {
+		return nil, err
+	}
+	return constraintCheck(func(v *Variable) { v.Pattern = args[0] }), nil
+}
+
+func oneOfRule(args []string) (ValidationFunc, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 argument")
+	}
+	return constraintCheck(func(v *Variable) { v.Enum = args }), nil
+}
+
+func stringRule(ok func(s string) bool, message string) RuleFunc {
+	return func(args []string) (ValidationFunc, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("expected no arguments, got %d", len(args))
+		}
+		return func(value interface{}) error {
+			if s, isString := value.(string); !isString || !ok(s) {
+				return fmt.Errorf("%s", message)
+			}
+			return nil
+		}, nil
+	}
+}
+
+func isEmail(s string) bool {
+	a, err := mail.ParseAddress(s)
+	return err == nil && a.Address == s
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationRule_BuiltIn(t *testing.T) {
+	v := String(WithRule("min_len", "2"), WithRule("pattern", `^[a-z]+$`), WithRule("not_blank"))
+	assert.NoError(t, v.Validate("ok"))
+	assert.Error(t, v.Validate("a"))
+	assert.Error(t, v.Validate("OK"))
+
+	n := Int(WithRule("min", "1"), WithRule("max", "10"), WithRule("one_of", "1", "5", "10"))
+	assert.NoError(t, n.Validate(5))
+	assert.Error(t, n.Validate(4))
+	assert.Error(t, n.Validate(11))
+
+	email := String(WithRule("email"))
+	assert.NoError(t, email.Validate("ann@example.com"))
+	assert.Error(t, email.Validate("Ann <ann@example.com>"))
+	site := String(WithRule("url"))
+	assert.NoError(t, site.Validate("https://example.com/a"))
+	assert.Error(t, site.Validate("example.com"))
+}
+
+func TestValidationRule_Message(t *testing.T) {
+	v := Variable{Name: "q", Rules: []ValidationRule{{Rule: "not_blank", Message: "ask a question"}}}
+	err := v.Validate("  ")
+	require.Error(t, err)
+	assert.Equal(t, "q: ask a question", err.Error())
+}
+
+func TestValidationRule_Invalid(t *testing.T) {
+	for _, r := range []ValidationRule{
+		{Rule: "no_such_rule"},
+		{Rule: "min_len"},
+		{Rule: "max", Args: []string{"lots"}},
+		{Rule: "pattern", Args: []string{"("}},
+	} {
+		_, err := r.Compile()
+		assert.Error(t, err, r.Rule)
+		v := Variable{Name: "x", Rules: []ValidationRule{r}}
+		assert.Error(t, v.Validate("x"), "%s fails validation", r.Rule)
+	}
+}
+
+func TestRegisterRule_RoundTrip(t *testing.T) {
+	RegisterRule("test_even", func(args []string) (ValidationFunc, error) {
+		return func(value interface{}) error {
+			if n, ok := value.(int); !ok || n%2 != 0 {
+				return fmt.Errorf("expected an even number")
+			}
+			return nil
+		}, nil
+	})
+	b, err := json.Marshal(Int(WithRule("test_even")))
+	require.NoError(t, err)
+	var v Variable
+	require.NoError(t, json.Unmarshal(b, &v))
+	assert.NoError(t, v.Validate(4))
+	assert.ErrorContains(t, v.Validate(3), "expected an even number")
+}
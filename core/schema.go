@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StripCodeFence returns s without surrounding whitespace and, if s is wrapped in a markdown code
+// fence (```json ... ```), without the fence, as models often wrap JSON output.
+func StripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
+
+// ValidateSchema checks v, a value decoded from JSON, against schema, a subset of JSON Schema: the
+// keywords type, properties, required, additionalProperties (false only), items, and enum. Other
+// keywords are ignored. The error locates the first violation, e.g. "$.items[2]: expected string,
+// got number".
+func ValidateSchema(schema map[string]interface{}, v interface{}) error {
+	return validateSchema(schema, v, "$")
+}
+
+// validateSchema checks v against schema; path locates the value in error messages.
+func validateSchema(schema map[string]interface{}, v interface{}, path string) error {
+	if typ, ok := schema["type"].(string); ok && !matchesType(typ, v) {
+		return fmt.Errorf("%s: expected %s, got %s", path, typ, jsonType(v))
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v not in enum %v", path, v, enum)
+		}
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, r := range toStrings(schema["required"]) {
+			if _, ok := val[r]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, r)
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for k, pv := range val {
+			ps, ok := props[k].(map[string]interface{})
+			if !ok {
+				if ap, isBool := schema["additionalProperties"].(bool); isBool && !ap {
+					return fmt.Errorf("%s: unexpected property %q", path, k)
+				}
+				continue
+			}
+			if err := validateSchema(ps, pv, path+"."+k); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(typ string, v interface{}) bool {
+	switch typ {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return jsonType(v) == typ
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// toStrings accepts []string or []interface{} (as produced by decoding a schema from JSON).
+func toStrings(v interface{}) []string {
+	switch s := v.(type) {
+	case []string:
+		return s
+	case []interface{}:
+		out := make([]string, 0, len(s))
+		for _, x := range s {
+			if str, ok := x.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"label", "score"},
+		"properties": map[string]interface{}{
+			"label": map[string]interface{}{"type": "string", "enum": []interface{}{"positive", "negative"}},
+			"score": map[string]interface{}{"type": "number"},
+			"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+		"additionalProperties": false,
+	}
+	decode := func(s string) interface{} {
+		var v interface{}
+		require.NoError(t, json.Unmarshal([]byte(s), &v))
+		return v
+	}
+	assert.NoError(t, ValidateSchema(schema, decode(`{"label": "positive", "score": 0.9, "tags": ["a"]}`)))
+	for in, want := range map[string]string{
+		`[]`:                                             "$: expected object, got array",
+		`{"label": "positive"}`:                          `$: missing required property "score"`,
+		`{"label": "meh", "score": 1}`:                   "$.label: value meh not in enum",
+		`{"label": "positive", "score": "high"}`:         "$.score: expected number, got string",
+		`{"label": "positive", "score": 1, "x": 1}`:      `$: unexpected property "x"`,
+		`{"label": "positive", "score": 1, "tags": [1]}`: "$.tags[0]: expected string, got number",
+	} {
+		err := ValidateSchema(schema, decode(in))
+		if assert.Error(t, err, in) {
+			assert.Contains(t, err.Error(), want, in)
+		}
+	}
+}
+
+func TestStripCodeFence(t *testing.T) {
+	assert.Equal(t, `{"a": 1}`, StripCodeFence(" {\"a\": 1}\n"))
+	assert.Equal(t, `{"a": 1}`, StripCodeFence("```json\n{\"a\": 1}\n```"))
+	assert.Equal(t, `{"a": 1}`, StripCodeFence("```\n{\"a\": 1}\n```\n"))
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SyntaxKey is the Prompt.Metadata key holding the TemplateSyntax of a prompt's system prompt,
+// template, and messages. Prompts without it use their engine's default, SyntaxGo unless
+// configured otherwise.
+const SyntaxKey = "loom_syntax"
+
+// TemplateSyntax is a language for prompt templates.
+type TemplateSyntax string
+
+const (
+	// SyntaxGo is Go's text/template, with the engine's functions: {{.name}}, {{if}}, {{range}}.
+	SyntaxGo TemplateSyntax = "go"
+	// SyntaxMustache is logic-less Mustache: {{name}}, {{#section}}...{{/section}}, and
+	// {{^inverted}}...{{/inverted}}, without functions, so prompts can be edited by people who
+	// should not write template logic.
+	SyntaxMustache TemplateSyntax = "mustache"
+)
+
+// SyntaxOf returns the template syntax stored in p's metadata, or "" if it has none.
+func SyntaxOf(p *Prompt) (TemplateSyntax, error) {
+	v, ok := p.Metadata[SyntaxKey]
+	if !ok || v == nil {
+		return "", nil
+	}
+	var syntax TemplateSyntax
+	switch s := v.(type) {
+	case string:
+		syntax = TemplateSyntax(s)
+	case TemplateSyntax:
+		syntax = s
+	}
+	switch syntax {
+	case SyntaxGo, SyntaxMustache:
+		return syntax, nil
+	}
+	return "", fmt.Errorf("%s metadata: unknown template syntax %v", SyntaxKey, v)
+}
+
+// DelimsKey is the Prompt.Metadata key holding a prompt's Delims.
+const DelimsKey = "loom_delims"
+
+// Delims are the delimiters of the actions or tags in a prompt's system prompt, template, and
+// messages, for prompts whose text contains a literal "{{" or "}}", e.g. Delims{Left: "[[",
+// Right: "]]"}. Prompts without them use their engine's, "{{" and "}}" unless configured
+// otherwise.
+type Delims struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// Metadata returns d in the form stored under DelimsKey.
+func (d Delims) Metadata() map[string]interface{} {
+	return map[string]interface{}{"left": d.Left, "right": d.Right}
+}
+
+// DelimsOf returns the delimiters stored in p's metadata, or the zero Delims if it has none.
+func DelimsOf(p *Prompt) (Delims, error) {
+	var d Delims
+	v, ok := p.Metadata[DelimsKey]
+	if !ok || v == nil {
+		return d, nil
+	}
+	if delims, ok := v.(Delims); ok {
+		d = delims
+	} else {
+		data, err := json.Marshal(v)
+		if err == nil {
+			err = json.Unmarshal(data, &d)
+		}
+		if err != nil {
+			return Delims{}, fmt.Errorf("%s metadata: %w", DelimsKey, err)
+		}
+	}
+	if d.Left == "" || d.Right == "" {
+		return Delims{}, fmt.Errorf("%s metadata: both left and right delimiters are required", DelimsKey)
+	}
+	return d, nil
+}
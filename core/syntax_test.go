@@ -0,0 +1,37 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyntaxOf(t *testing.T) {
+	syntax, err := SyntaxOf(&Prompt{})
+	require.NoError(t, err)
+	assert.Empty(t, syntax)
+	for _, v := range []interface{}{"mustache", SyntaxMustache} {
+		syntax, err = SyntaxOf(&Prompt{Metadata: map[string]interface{}{SyntaxKey: v}})
+		require.NoError(t, err)
+		assert.Equal(t, SyntaxMustache, syntax)
+	}
+	_, err = SyntaxOf(&Prompt{Metadata: map[string]interface{}{SyntaxKey: "jinja"}})
+	assert.EqualError(t, err, "loom_syntax metadata: unknown template syntax jinja")
+}
+
+func TestDelimsOf(t *testing.T) {
+	d, err := DelimsOf(&Prompt{})
+	require.NoError(t, err)
+	assert.Zero(t, d)
+	want := Delims{Left: "[[", Right: "]]"}
+	for _, v := range []interface{}{want, want.Metadata(), map[string]string{"left": "[[", "right": "]]"}} {
+		d, err = DelimsOf(&Prompt{Metadata: map[string]interface{}{DelimsKey: v}})
+		require.NoError(t, err)
+		assert.Equal(t, want, d)
+	}
+	_, err = DelimsOf(&Prompt{Metadata: map[string]interface{}{DelimsKey: map[string]interface{}{"left": "[["}}})
+	assert.EqualError(t, err, "loom_delims metadata: both left and right delimiters are required")
+	_, err = DelimsOf(&Prompt{Metadata: map[string]interface{}{DelimsKey: "[["}})
+	assert.Error(t, err)
+}
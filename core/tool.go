@@ -0,0 +1,24 @@
+package core
+
+import "fmt"
+
+// Tool declares a function the model may call, versioned together with the prompt that uses it.
+// Parameters is a JSON Schema object describing the arguments (e.g. {"type": "object", "properties": {...}}).
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// Validate checks that the tool has a name and, if set, an object-typed parameter schema.
+func (t Tool) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if t.Parameters != nil {
+		if typ, ok := t.Parameters["type"]; ok && typ != "object" {
+			return fmt.Errorf("tool %q: parameters schema must have type \"object\"", t.Name)
+		}
+	}
+	return nil
+}
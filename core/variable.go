@@ -1,6 +1,14 @@
 package core
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
 
 // VariableType represents the type of a template variable.
 type VariableType string
@@ -11,6 +19,19 @@ const (
 	VariableTypeFloat   VariableType = "float"
 	VariableTypeBool    VariableType = "bool"
 	VariableTypeAny     VariableType = "any"
+	// VariableTypeList accepts a slice, with elements of type Variable.Items if set.
+	VariableTypeList VariableType = "list"
+	// VariableTypeObject accepts a map with string keys (e.g. a decoded JSON object) or a struct.
+	VariableTypeObject VariableType = "object"
+	// VariableTypeEnum accepts one of the strings in Variable.Enum.
+	VariableTypeEnum VariableType = "enum"
+	// VariableTypeTimestamp accepts a time.Time or an RFC 3339 string, which templates receive as
+	// a time.Time (e.g. {{.when.Format "Jan 2"}}).
+	VariableTypeTimestamp VariableType = "timestamp"
+	// VariableTypeImage and VariableTypeFile accept an attachment (see ToAttachment), which is sent
+	// with the prompt's user message rather than rendered into text (see Prompt).
+	VariableTypeImage VariableType = "image"
+	VariableTypeFile  VariableType = "file"
 )
 
 // ValidationFunc validates a variable value. Returns nil if valid.
@@ -22,12 +43,42 @@ type Variable struct {
 	Type        VariableType
 	Required    bool
 	Default     interface{}
-	Validation  ValidationFunc
+	Validation  ValidationFunc `json:"-"` // not serialized; lost on registry round-trips (see Rules)
 	Description string
+	// Enum lists the allowed values of an enum variable, or of another type's variable (see OneOf).
+	Enum []string `json:",omitempty"`
+	// Items is the element type of a list variable; elements are not checked if empty.
+	Items VariableType `json:",omitempty"`
+	// Fields declares fields of an object variable, or of the elements of a list of objects, by
+	// map key or struct field name; they are validated and coerced like variables, and fields
+	// not declared are not checked (see WithField). Templates reach them with dotted access, e.g.
+	// {{.user.profile.name}}.
+	Fields []Variable `json:",omitempty"`
+	// Constraints checked after the type (see MinLen, MaxLen, Min, Max, Pattern, and OneOf).
+	// Unlike Validation they are serialized, so they survive registry round-trips.
+	MinLen  int      `json:",omitempty"`
+	MaxLen  int      `json:",omitempty"`
+	Min     *float64 `json:",omitempty"`
+	Max     *float64 `json:",omitempty"`
+	Pattern string   `json:",omitempty"`
+	// Rules are serializable checks run after the constraints (see ValidationRule and WithRule).
+	Rules []ValidationRule `json:",omitempty"`
+	// Sensitive marks values that must stay out of logs, such as personal data: validation errors
+	// leave them out, and renders offer redacted views (see Rendered.RedactedUser).
+	Sensitive bool `json:",omitempty"`
 }
 
-// Validate checks the variable value against type and custom validation.
+// Validate checks the variable value against type and custom validation. Errors for sensitive
+// variables do not contain the value.
 func (v *Variable) Validate(value interface{}) error {
+	err := v.validate(value)
+	if err != nil && v.Sensitive {
+		return redactError(v.Name, value, err)
+	}
+	return err
+}
+
+func (v *Variable) validate(value interface{}) error {
 	if value == nil {
 		if v.Required {
 			return &ValidationError{Field: v.Name, Value: value, Message: "required field is missing"}
@@ -57,15 +108,272 @@ func (v *Variable) Validate(value interface{}) error {
 		if _, ok := value.(bool); !ok {
 			return &ValidationError{Field: v.Name, Value: value, Message: "expected bool"}
 		}
+	case VariableTypeList:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return &ValidationError{Field: v.Name, Value: value, Message: "expected list"}
+		}
+		if v.Items != "" {
+			for i := 0; i < rv.Len(); i++ {
+				item := Variable{Name: fmt.Sprintf("%s[%d]", v.Name, i), Type: v.Items, Required: true, Fields: v.Fields}
+				if err := item.Validate(rv.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+		}
+	case VariableTypeObject:
+		rv := reflect.Indirect(reflect.ValueOf(value))
+		isMap := rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String
+		if !isMap && rv.Kind() != reflect.Struct {
+			return &ValidationError{Field: v.Name, Value: value, Message: "expected object"}
+		}
+		for _, f := range v.Fields {
+			val, _ := fieldValue(rv, f.Name)
+			f.Name = v.Name + "." + f.Name
+			if err := f.Validate(val); err != nil {
+				return err
+			}
+		}
+	case VariableTypeEnum:
+		s, ok := value.(string)
+		if !ok || !contains(v.Enum, s) {
+			return &ValidationError{Field: v.Name, Value: value, Message: "expected one of " + strings.Join(v.Enum, ", ")}
+		}
+	case VariableTypeTimestamp:
+		if _, ok := toTime(value); !ok {
+			return &ValidationError{Field: v.Name, Value: value, Message: "expected RFC 3339 timestamp"}
+		}
+	case VariableTypeImage, VariableTypeFile:
+		a, err := ToAttachment(value)
+		if err != nil {
+			return &ValidationError{Field: v.Name, Value: value, Message: err.Error()}
+		}
+		if v.Type == VariableTypeImage && a.MIMEType != "" && !a.IsImage() {
+			return &ValidationError{Field: v.Name, Value: value, Message: "expected an image, got " + a.MIMEType}
+		}
 	case VariableTypeAny:
 		// no type check
 	}
+	if err := v.checkConstraints(value); err != nil {
+		return err
+	}
+	if err := v.checkRules(value); err != nil {
+		return err
+	}
 	if v.Validation != nil {
 		return v.Validation(value)
 	}
 	return nil
 }
 
+// checkConstraints checks value against v's MinLen, MaxLen, Min, Max, Pattern, and (for
+// variables other than enums, which check it by type) Enum.
+func (v *Variable) checkConstraints(value interface{}) error {
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Field: v.Name, Value: value, Message: fmt.Sprintf(format, args...)}
+	}
+	if v.MinLen > 0 || v.MaxLen > 0 {
+		n, ok := length(value)
+		switch {
+		case !ok:
+			return fail("length constraint on a value without a length")
+		case n < v.MinLen:
+			return fail("length %d is below the minimum of %d", n, v.MinLen)
+		case v.MaxLen > 0 && n > v.MaxLen:
+			return fail("length %d exceeds the maximum of %d", n, v.MaxLen)
+		}
+	}
+	if v.Min != nil || v.Max != nil {
+		f, ok := number(value)
+		switch {
+		case !ok:
+			return fail("range constraint on a non-numeric value")
+		case v.Min != nil && f < *v.Min:
+			return fail("%v is below the minimum of %v", value, *v.Min)
+		case v.Max != nil && f > *v.Max:
+			return fail("%v exceeds the maximum of %v", value, *v.Max)
+		}
+	}
+	if v.Pattern != "" {
+		re, err := compilePattern(v.Pattern)
+		if err != nil {
+			return fail("invalid pattern: %v", err)
+		}
+		s, ok := value.(string)
+		if !ok || !re.MatchString(s) {
+			return fail("does not match pattern %s", v.Pattern)
+		}
+	}
+	if len(v.Enum) > 0 && v.Type != VariableTypeEnum && !contains(v.Enum, fmt.Sprint(value)) {
+		return fail("expected one of %s", strings.Join(v.Enum, ", "))
+	}
+	return nil
+}
+
+// fieldValue returns the value of key in rv, a map with string keys or a struct (whose field key
+// must be exported).
+func fieldValue(rv reflect.Value, key string) (interface{}, bool) {
+	switch rv.Kind() {
+	case reflect.Map:
+		val := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+		if !val.IsValid() {
+			return nil, false
+		}
+		return val.Interface(), true
+	case reflect.Struct:
+		f, ok := rv.Type().FieldByName(key)
+		if !ok || !f.IsExported() {
+			return nil, false
+		}
+		return rv.FieldByIndex(f.Index).Interface(), true
+	}
+	return nil, false
+}
+
+// length returns the number of characters of a string or elements of a slice, array, or map.
+func length(value interface{}) (int, bool) {
+	if s, ok := value.(string); ok {
+		return utf8.RuneCountInString(s), true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), true
+	}
+	return 0, false
+}
+
+// number returns an integer or floating-point value as a float64.
+func number(value interface{}) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// patterns caches compiled Variable.Pattern expressions, as inputs are validated on every render.
+var patterns sync.Map // string -> *regexp.Regexp
+
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(expr, re)
+	return re, nil
+}
+
+// Coerce converts a valid value to the form templates receive: timestamps become time.Time,
+// images and files become Attachment (lists of them []Attachment), and lists of strings (e.g.
+// []interface{} decoded from JSON) become []string when Items is string, so functions such as join
+// accept them. Declared Fields of objects decoded from JSON (and of lists of them) are coerced
+// the same way, in a copy. Other values are returned unchanged.
+func (v *Variable) Coerce(value interface{}) interface{} {
+	switch v.Type {
+	case VariableTypeObject:
+		return v.coerceFields(value)
+	case VariableTypeTimestamp:
+		if t, ok := toTime(value); ok {
+			return t
+		}
+	case VariableTypeImage, VariableTypeFile:
+		if a, err := ToAttachment(value); err == nil {
+			return a
+		}
+	case VariableTypeList:
+		if v.Items == VariableTypeImage || v.Items == VariableTypeFile {
+			rv := reflect.ValueOf(value)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				return value
+			}
+			out := make([]Attachment, rv.Len())
+			for i := range out {
+				a, err := ToAttachment(rv.Index(i).Interface())
+				if err != nil {
+					return value
+				}
+				out[i] = a
+			}
+			return out
+		}
+		items, ok := value.([]interface{})
+		if ok && v.Items == VariableTypeObject && len(v.Fields) > 0 {
+			out := make([]interface{}, len(items))
+			for i, item := range items {
+				out[i] = v.coerceFields(item)
+			}
+			return out
+		}
+		if !ok || v.Items != VariableTypeString {
+			return value
+		}
+		out := make([]string, len(items))
+		for i, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return value
+			}
+			out[i] = s
+		}
+		return out
+	}
+	return value
+}
+
+// coerceFields returns a copy of value, a map decoded from JSON, with its declared Fields
+// coerced; other values are returned unchanged.
+func (v *Variable) coerceFields(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(v.Fields) == 0 {
+		return value
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, val := range m {
+		out[k] = val
+	}
+	for _, f := range v.Fields {
+		if val, ok := m[f.Name]; ok && val != nil {
+			out[f.Name] = f.Coerce(val)
+		}
+	}
+	return out
+}
+
+// toTime returns value as a time.Time if it is one (or a pointer to one) or an RFC 3339 string.
+func toTime(value interface{}) (time.Time, bool) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t != nil {
+			return *t, true
+		}
+	case string:
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // VariableOption configures a Variable (functional option).
 type VariableOption func(*Variable)
 
@@ -83,6 +391,21 @@ func Default(val interface{}) VariableOption {
 	}
 }
 
+// Sensitive marks the variable's values as sensitive (see Variable.Sensitive).
+func Sensitive() VariableOption {
+	return func(v *Variable) {
+		v.Sensitive = true
+	}
+}
+
+// WithRule adds the validation rule named rule with args (see ValidationRule). Prefer it to
+// WithValidation for prompts stored in a registry; custom checks can be added with RegisterRule.
+func WithRule(rule string, args ...string) VariableOption {
+	return func(v *Variable) {
+		v.Rules = append(v.Rules, ValidationRule{Rule: rule, Args: args})
+	}
+}
+
 // WithValidation sets a custom validation function.
 func WithValidation(fn ValidationFunc) VariableOption {
 	return func(v *Variable) {
@@ -97,6 +420,66 @@ func WithDescription(desc string) VariableOption {
 	}
 }
 
+// MinLen requires strings to have at least n characters, and lists and objects at least n elements.
+func MinLen(n int) VariableOption {
+	return func(v *Variable) {
+		v.MinLen = n
+	}
+}
+
+// MaxLen requires strings to have at most n characters, and lists and objects at most n elements.
+func MaxLen(n int) VariableOption {
+	return func(v *Variable) {
+		v.MaxLen = n
+	}
+}
+
+// Min requires numeric values to be at least min.
+func Min(min float64) VariableOption {
+	return func(v *Variable) {
+		v.Min = &min
+	}
+}
+
+// Max requires numeric values to be at most max.
+func Max(max float64) VariableOption {
+	return func(v *Variable) {
+		v.Max = &max
+	}
+}
+
+// Pattern requires string values to match the regular expression expr (RE2 syntax, see package
+// regexp); anchor it with ^ and $ to match the whole value. An invalid expression fails validation.
+func Pattern(expr string) VariableOption {
+	return func(v *Variable) {
+		v.Pattern = expr
+	}
+}
+
+// OneOf restricts the variable to values, compared with the value's default formatting (so
+// OneOf("1", "2") works for an int variable too). Use Enum for a categorical string variable.
+func OneOf(values ...string) VariableOption {
+	return func(v *Variable) {
+		v.Enum = values
+	}
+}
+
+// WithField declares the field name of an object variable, or of the elements of a list of
+// objects, e.g. Object(WithField("name", String(Required()))).
+func WithField(name string, field Variable) VariableOption {
+	return func(v *Variable) {
+		field.Name = name
+		v.Fields = append(v.Fields, field)
+	}
+}
+
+// WithItems sets the element type of a list variable.
+func WithItems(t VariableType) VariableOption {
+	return func(v *Variable) {
+		v.Items = t
+	}
+}
+
 // String returns a Variable configured as string type.
 func String(opts ...VariableOption) Variable {
 	v := Variable{Name: "", Type: VariableTypeString}
@@ -142,6 +525,60 @@ func Any(opts ...VariableOption) Variable {
 	return v
 }
 
+// List returns a Variable configured as list type; use WithItems to check its elements.
+func List(opts ...VariableOption) Variable {
+	v := Variable{Name: "", Type: VariableTypeList}
+	for _, o := range opts {
+		o(&v)
+	}
+	return v
+}
+
+// Object returns a Variable configured as object type.
+func Object(opts ...VariableOption) Variable {
+	v := Variable{Name: "", Type: VariableTypeObject}
+	for _, o := range opts {
+		o(&v)
+	}
+	return v
+}
+
+// Enum returns a Variable that accepts one of values.
+func Enum(values []string, opts ...VariableOption) Variable {
+	v := Variable{Name: "", Type: VariableTypeEnum, Enum: values}
+	for _, o := range opts {
+		o(&v)
+	}
+	return v
+}
+
+// Timestamp returns a Variable configured as timestamp type.
+func Timestamp(opts ...VariableOption) Variable {
+	v := Variable{Name: "", Type: VariableTypeTimestamp}
+	for _, o := range opts {
+		o(&v)
+	}
+	return v
+}
+
+// Image returns a Variable configured as image type.
+func Image(opts ...VariableOption) Variable {
+	v := Variable{Name: "", Type: VariableTypeImage}
+	for _, o := range opts {
+		o(&v)
+	}
+	return v
+}
+
+// File returns a Variable configured as file type.
+func File(opts ...VariableOption) Variable {
+	v := Variable{Name: "", Type: VariableTypeFile}
+	for _, o := range opts {
+		o(&v)
+	}
+	return v
+}
+
 // variableWithName is used by the builder to set Name after creation.
 func variableWithName(name string, base Variable) Variable {
 	base.Name = name
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -50,3 +52,119 @@ func TestVariable_Validate_CustomFunc(t *testing.T) {
 	assert.Error(t, v.Validate("ab"))
 	assert.NoError(t, v.Validate("abc"))
 }
+
+func TestList_Validate(t *testing.T) {
+	v := List(WithItems(VariableTypeString))
+	v.Name = "docs"
+	assert.NoError(t, v.Validate([]string{"a", "b"}))
+	assert.NoError(t, v.Validate([]interface{}{"a", "b"}))
+	assert.Error(t, v.Validate("a"))
+	err := v.Validate([]interface{}{"a", 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "docs[1]")
+	untyped := List()
+	assert.NoError(t, untyped.Validate([]interface{}{"a", 1}), "elements unchecked without Items")
+	assert.Equal(t, []string{"a", "b"}, v.Coerce([]interface{}{"a", "b"}))
+}
+
+func TestObject_Validate(t *testing.T) {
+	v := Object()
+	assert.NoError(t, v.Validate(map[string]interface{}{"a": 1}))
+	assert.NoError(t, v.Validate(struct{ A int }{1}))
+	assert.NoError(t, v.Validate(&struct{ A int }{1}))
+	assert.Error(t, v.Validate(map[int]string{1: "a"}))
+	assert.Error(t, v.Validate([]string{"a"}))
+}
+
+func TestObject_Fields(t *testing.T) {
+	user := Object(Required(), WithField("name", String(Required())), WithField("profile", Object(
+		WithField("joined", Timestamp()),
+		WithField("tier", Enum([]string{"free", "pro"})),
+	)))
+	user.Name = "user"
+	assert.NoError(t, user.Validate(map[string]interface{}{"name": "Ann", "extra": 1}))
+	assert.NoError(t, user.Validate(map[string]interface{}{"name": "Ann", "profile": nil}))
+	named := Object(WithField("Name", String(Required())))
+	assert.NoError(t, named.Validate(struct{ Name string }{"Ann"}))
+	assert.Error(t, named.Validate(struct{ name string }{"Ann"}), "unexported fields are missing")
+	err := user.Validate(map[string]interface{}{"profile": map[string]interface{}{}})
+	assert.EqualError(t, err, "user.name: required field is missing")
+	err = user.Validate(map[string]interface{}{"name": "Ann", "profile": map[string]interface{}{"tier": "gold"}})
+	assert.EqualError(t, err, "user.profile.tier: expected one of free, pro")
+	assert.Error(t, user.Validate(map[string]interface{}{"name": "Ann", "profile": "pro"}))
+
+	in := map[string]interface{}{"name": "Ann", "profile": map[string]interface{}{"joined": "2026-03-01T12:00:00Z"}}
+	out := user.Coerce(in).(map[string]interface{})
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), out["profile"].(map[string]interface{})["joined"])
+	assert.Equal(t, "2026-03-01T12:00:00Z", in["profile"].(map[string]interface{})["joined"], "input is not modified")
+
+	users := List(WithItems(VariableTypeObject), WithField("name", String(Required())))
+	users.Name = "users"
+	err = users.Validate([]interface{}{map[string]interface{}{"name": "Ann"}, map[string]interface{}{}})
+	assert.EqualError(t, err, "users[1].name: required field is missing")
+}
+
+func TestEnum_Validate(t *testing.T) {
+	v := Enum([]string{"formal", "casual"}, Required())
+	assert.NoError(t, v.Validate("casual"))
+	err := v.Validate("rude")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "formal, casual")
+	assert.Error(t, v.Validate(1))
+}
+
+func TestTimestamp_Validate(t *testing.T) {
+	v := Timestamp()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, v.Validate(now))
+	assert.NoError(t, v.Validate("2026-03-01T12:00:00Z"))
+	assert.Error(t, v.Validate("yesterday"))
+	assert.Error(t, v.Validate(1700000000))
+	assert.Equal(t, now, v.Coerce("2026-03-01T12:00:00Z"))
+	assert.Equal(t, "x", v.Coerce("x"), "invalid values are left alone")
+}
+
+func TestVariable_Validate_Constraints(t *testing.T) {
+	name := String(MinLen(2), MaxLen(5), Pattern(`^[a-z]+$`))
+	assert.NoError(t, name.Validate("ab"))
+	assert.NoError(t, name.Validate("hello"))
+	assert.Error(t, name.Validate("a"))
+	assert.Error(t, name.Validate("abcdef"))
+	assert.Error(t, name.Validate("Ab"))
+
+	age := Int(Min(0), Max(150))
+	assert.NoError(t, age.Validate(0))
+	assert.NoError(t, age.Validate(150))
+	err := age.Validate(151)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "maximum of 150")
+	assert.Error(t, age.Validate(-1))
+
+	docs := List(MaxLen(1))
+	assert.NoError(t, docs.Validate([]string{"a"}))
+	assert.Error(t, docs.Validate([]string{"a", "b"}))
+
+	level := Int(OneOf("1", "2"))
+	assert.NoError(t, level.Validate(2))
+	assert.Error(t, level.Validate(3))
+
+	bad := String(Pattern(`(`))
+	err = bad.Validate("x")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid pattern")
+}
+
+func TestVariable_Constraints_Serializable(t *testing.T) {
+	v := Float(Min(0.5), Pattern(`^x`))
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	var got Variable
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, 0.5, *got.Min)
+	assert.Nil(t, got.Max)
+	assert.Equal(t, `^x`, got.Pattern)
+
+	b, err = json.Marshal(String())
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "Min", "unset constraints are omitted")
+}
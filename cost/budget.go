@@ -0,0 +1,59 @@
+package cost
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned when a request's estimated cost does not fit in the remaining budget.
+var ErrBudgetExceeded = errors.New("cost budget exceeded")
+
+// Budget caps total spend across concurrent requests. Reserve an estimate (from Estimator.Estimate)
+// before each request and Settle it with the actual cost afterwards, so requests in flight count
+// against the limit before their usage is known.
+type Budget struct {
+	mu       sync.Mutex
+	limitUSD float64
+	spent    float64
+	reserved float64
+}
+
+// NewBudget creates a budget of limitUSD. A limit <= 0 means unlimited (spend is still tracked).
+func NewBudget(limitUSD float64) *Budget {
+	return &Budget{limitUSD: limitUSD}
+}
+
+// Reserve sets aside estimateUSD and reports whether it fits in the remaining budget.
+// Nothing is reserved if it does not fit.
+func (b *Budget) Reserve(estimateUSD float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limitUSD > 0 && b.spent+b.reserved+estimateUSD > b.limitUSD {
+		return false
+	}
+	b.reserved += estimateUSD
+	return true
+}
+
+// Settle releases a reservation made with Reserve and records the actual cost.
+func (b *Budget) Settle(reservedUSD, actualUSD float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserved -= reservedUSD
+	if b.reserved < 0 {
+		b.reserved = 0
+	}
+	b.spent += actualUSD
+}
+
+// Limit returns the budget limit in USD (0 if unlimited).
+func (b *Budget) Limit() float64 {
+	return b.limitUSD
+}
+
+// Spent returns the settled spend in USD.
+func (b *Budget) Spent() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
@@ -65,7 +65,12 @@ func NewEstimator(model string, inputPer1K, outputPer1K float64, opts ...Estimat
 func (e *Estimator) Estimate(ctx context.Context, rendered *core.Rendered, expectedOutputTokens int) (inputCost, outputCost, totalUSD float64) {
 	inputTokens := 0
 	if e.tokenCounter != nil {
-		inputTokens = e.tokenCounter.CountTokens(rendered.System) + e.tokenCounter.CountTokens(rendered.User)
+		for _, m := range rendered.Messages {
+			inputTokens += e.tokenCounter.CountTokens(m.Content)
+		}
+		if len(rendered.Messages) == 0 {
+			inputTokens = e.tokenCounter.CountTokens(rendered.System) + e.tokenCounter.CountTokens(rendered.User)
+		}
 	}
 	inputCost = (float64(inputTokens) / 1000) * e.inputPer1K
 	outputCost = (float64(expectedOutputTokens) / 1000) * e.outputPer1K
@@ -73,6 +78,11 @@ func (e *Estimator) Estimate(ctx context.Context, rendered *core.Rendered, expec
 	return inputCost, outputCost, totalUSD
 }
 
+// Cost returns the cost in USD of actual usage at the estimator's pricing.
+func (e *Estimator) Cost(usage provider.TokenUsage) float64 {
+	return (float64(usage.PromptTokens)/1000)*e.inputPer1K + (float64(usage.CompletionTokens)/1000)*e.outputPer1K
+}
+
 // Tracker records cost per request (e.g. from actual usage in CompletionResponse).
 type Tracker struct {
 	totalInputTokens  atomic.Uint64
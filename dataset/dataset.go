@@ -0,0 +1,217 @@
+// Package dataset stores named, versioned evaluation datasets: the cases (inputs and expected
+// outputs) that suites and matrices evaluate prompts against. Keeping them apart from suite code
+// lets test data grow, be reviewed, and be shared by several suites, which pin a version or
+// follow a range. A stored version is immutable, so results against it stay comparable.
+//
+//	store := dataset.NewFileStore(".loom/_datasets")
+//	_ = store.Put(ctx, &dataset.Dataset{Name: "support-qa", Version: "1.0.0", Cases: cases})
+//	ds, _ := dataset.Resolve(ctx, store, "support-qa", "^1.0")
+//	suite := evaluator.NewTestSuite("qa").WithPrompt(p, "").AddCases(ds.EvalCases()...)
+package dataset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klejdi94/loom/evaluator"
+	"github.com/klejdi94/loom/registry"
+)
+
+var (
+	// ErrNotFound is returned when a dataset or version does not exist.
+	ErrNotFound = errors.New("dataset not found")
+	// ErrExists is returned by Put when the version is already stored.
+	ErrExists = errors.New("dataset version already exists")
+	// ErrInvalid is returned by Put for a dataset that fails Validate.
+	ErrInvalid = errors.New("invalid dataset")
+)
+
+// Dataset is one version of a named set of evaluation cases.
+type Dataset struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Description string    `json:"description,omitempty"`
+	Cases       []Case    `json:"cases"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Case is a serializable evaluator.Case.
+type Case struct {
+	Name     string                 `json:"name"`
+	Input    map[string]interface{} `json:"input"`
+	Expected Expected               `json:"expected"`
+	// Tags group cases (e.g. "regression", "edge-case") for filtering with Dataset.Tagged.
+	Tags []string `json:"tags,omitempty"`
+	// Actual and Pass label a case for judge calibration (see LabeledCases): a candidate output and
+	// whether a person judged it to meet Expected.
+	Actual string `json:"actual,omitempty"`
+	Pass   *bool  `json:"pass,omitempty"`
+}
+
+// Expected mirrors evaluator.Expected without its evaluators, which are configured on the suite.
+type Expected struct {
+	Output      string   `json:"output,omitempty"`
+	Contains    []string `json:"contains,omitempty"`
+	NotContains []string `json:"not_contains,omitempty"`
+}
+
+// Validate checks that the dataset has a name and version usable as keys and that its cases have
+// unique, non-empty names.
+func (d *Dataset) Validate() error {
+	if d == nil {
+		return fmt.Errorf("%w: nil", ErrInvalid)
+	}
+	if err := validKey("name", d.Name); err != nil {
+		return err
+	}
+	if err := validKey("version", d.Version); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(d.Cases))
+	for i, c := range d.Cases {
+		if c.Name == "" {
+			return fmt.Errorf("%w: case %d has no name", ErrInvalid, i)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("%w: duplicate case %q", ErrInvalid, c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
+func validKey(field, s string) error {
+	if s == "" || s == "." || s == ".." || strings.ContainsAny(s, `/\@`) {
+		return fmt.Errorf("%w: %s %q must be non-empty and not contain /, \\ or @", ErrInvalid, field, s)
+	}
+	return nil
+}
+
+// Ref returns "name@version", the form accepted by ParseRef.
+func (d *Dataset) Ref() string {
+	return d.Name + "@" + d.Version
+}
+
+// EvalCases converts the dataset's cases for evaluator.Suite.AddCases or evaluator.Matrix.
+func (d *Dataset) EvalCases() []evaluator.Case {
+	out := make([]evaluator.Case, len(d.Cases))
+	for i, c := range d.Cases {
+		out[i] = evaluator.Case{
+			Name:  c.Name,
+			Input: c.Input,
+			Expected: evaluator.Expected{
+				Output:      c.Expected.Output,
+				Contains:    c.Expected.Contains,
+				NotContains: c.Expected.NotContains,
+			},
+		}
+	}
+	return out
+}
+
+// LabeledCases converts the cases that have a Pass label for evaluator.Calibration.
+func (d *Dataset) LabeledCases() []evaluator.LabeledCase {
+	var out []evaluator.LabeledCase
+	for i, c := range d.EvalCases() {
+		if label := d.Cases[i].Pass; label != nil {
+			out = append(out, evaluator.LabeledCase{Name: c.Name, Actual: d.Cases[i].Actual, Expected: c.Expected, Pass: *label})
+		}
+	}
+	return out
+}
+
+// Tagged returns a copy of the dataset holding only the cases with at least one of tags.
+func (d *Dataset) Tagged(tags ...string) *Dataset {
+	out := *d
+	out.Cases = nil
+	for _, c := range d.Cases {
+		for _, t := range c.Tags {
+			if contains(tags, t) {
+				out.Cases = append(out.Cases, c)
+				break
+			}
+		}
+	}
+	return &out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRef splits a reference like "support-qa@^1.2" into name and version constraint. Without
+// "@" the constraint is "latest".
+func ParseRef(ref string) (name, constraint string) {
+	name, constraint, ok := strings.Cut(ref, "@")
+	if !ok || constraint == "" {
+		constraint = "latest"
+	}
+	return name, constraint
+}
+
+// Store holds versioned datasets. Get takes an exact version; use Resolve for "latest" and ranges.
+type Store interface {
+	// Put stores a new version. It returns ErrInvalid if d fails Validate and ErrExists if the
+	// version is already stored. A zero CreatedAt is set to the current time.
+	Put(ctx context.Context, d *Dataset) error
+	Get(ctx context.Context, name, version string) (*Dataset, error)
+	// Versions returns the stored versions of name, lowest first by semver precedence.
+	Versions(ctx context.Context, name string) ([]string, error)
+	// Names returns the names of all stored datasets, sorted.
+	Names(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name, version string) error
+}
+
+// Resolve returns the highest version of name that satisfies constraint, a range like those of
+// registry.Resolve ("^1.2", "1.x", ...). An empty constraint or "latest" selects the highest
+// release, or the highest pre-release if there is none. Versions that are not semver are only
+// matched exactly. It returns ErrNotFound if no version matches.
+func Resolve(ctx context.Context, s Store, name, constraint string) (*Dataset, error) {
+	versions, err := s.Versions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	latest := constraint == "" || constraint == "latest"
+	if !latest && contains(versions, constraint) {
+		return s.Get(ctx, name, constraint)
+	}
+	match := func(v string) (bool, error) { return registry.MatchVersion(constraint, v) }
+	if latest {
+		match = func(v string) (bool, error) { return registry.MatchVersion(">=0.0.0", v) }
+	}
+	found, err := highest(versions, match)
+	if err != nil {
+		return nil, err
+	}
+	if found == "" && latest {
+		// A version matches itself exactly, pre-release or not, iff it is semver.
+		found, _ = highest(versions, func(v string) (bool, error) { ok, _ := registry.MatchVersion(v, v); return ok, nil })
+	}
+	if found == "" {
+		return nil, fmt.Errorf("%w: no version of %s matches %q", ErrNotFound, name, constraint)
+	}
+	return s.Get(ctx, name, found)
+}
+
+// highest returns the highest of versions accepted by match, or "" if none is.
+func highest(versions []string, match func(string) (bool, error)) (string, error) {
+	found := ""
+	for _, v := range versions {
+		ok, err := match(v)
+		if err != nil {
+			return "", err
+		}
+		if ok && (found == "" || registry.CompareVersions(v, found) > 0) {
+			found = v
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,341 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klejdi94/loom/registry"
+)
+
+// prepare validates d and sets its creation time before it is stored.
+func prepare(d *Dataset) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now().UTC()
+	}
+	return nil
+}
+
+func sortVersions(versions []string) []string {
+	sort.Slice(versions, func(i, j int) bool { return registry.CompareVersions(versions[i], versions[j]) < 0 })
+	return versions
+}
+
+func notFound(name, version string) error {
+	return fmt.Errorf("%w: %s@%s", ErrNotFound, name, version)
+}
+
+// MemoryStore is an in-memory Store, for tests and short-lived processes.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	sets map[string]map[string][]byte // name -> version -> JSON, so callers cannot mutate stored data
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sets: make(map[string]map[string][]byte)}
+}
+
+// Put implements Store.
+func (m *MemoryStore) Put(ctx context.Context, d *Dataset) error {
+	if err := prepare(d); err != nil {
+		return err
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sets[d.Name][d.Version]; ok {
+		return fmt.Errorf("%w: %s", ErrExists, d.Ref())
+	}
+	if m.sets[d.Name] == nil {
+		m.sets[d.Name] = make(map[string][]byte)
+	}
+	m.sets[d.Name][d.Version] = data
+	return nil
+}
+
+// Get implements Store.
+func (m *MemoryStore) Get(ctx context.Context, name, version string) (*Dataset, error) {
+	m.mu.RLock()
+	data, ok := m.sets[name][version]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, notFound(name, version)
+	}
+	var d Dataset
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Versions implements Store.
+func (m *MemoryStore) Versions(ctx context.Context, name string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	versions := make([]string, 0, len(m.sets[name]))
+	for v := range m.sets[name] {
+		versions = append(versions, v)
+	}
+	return sortVersions(versions), nil
+}
+
+// Names implements Store.
+func (m *MemoryStore) Names(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.sets))
+	for name := range m.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete implements Store.
+func (m *MemoryStore) Delete(ctx context.Context, name, version string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sets[name][version]; !ok {
+		return notFound(name, version)
+	}
+	delete(m.sets[name], version)
+	if len(m.sets[name]) == 0 {
+		delete(m.sets, name)
+	}
+	return nil
+}
+
+// FileStore stores datasets as JSON files, dir/name/version.json, so they can be reviewed and
+// versioned alongside code.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore creates a store in dir, which is created on the first Put.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (f *FileStore) path(name, version string) string {
+	return filepath.Join(f.dir, name, version+".json")
+}
+
+// Put implements Store.
+func (f *FileStore) Put(ctx context.Context, d *Dataset) error {
+	if err := prepare(d); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := f.path(d.Name, d.Version)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%w: %s", ErrExists, d.Ref())
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Get implements Store.
+func (f *FileStore) Get(ctx context.Context, name, version string) (*Dataset, error) {
+	if validKey("name", name) != nil || validKey("version", version) != nil {
+		return nil, notFound(name, version)
+	}
+	data, err := os.ReadFile(f.path(name, version))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, notFound(name, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var d Dataset
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("dataset %s@%s: %w", name, version, err)
+	}
+	return &d, nil
+}
+
+// Versions implements Store.
+func (f *FileStore) Versions(ctx context.Context, name string) ([]string, error) {
+	if validKey("name", name) != nil {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(filepath.Join(f.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		if v, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			versions = append(versions, v)
+		}
+	}
+	return sortVersions(versions), nil
+}
+
+// Names implements Store.
+func (f *FileStore) Names(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// Delete implements Store. The dataset's directory is removed with its last version.
+func (f *FileStore) Delete(ctx context.Context, name, version string) error {
+	if validKey("name", name) != nil || validKey("version", version) != nil {
+		return notFound(name, version)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := os.Remove(f.path(name, version))
+	if errors.Is(err, os.ErrNotExist) {
+		return notFound(name, version)
+	}
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(filepath.Join(f.dir, name)) // fails, harmlessly, while other versions remain
+	return nil
+}
+
+// BlobStore stores datasets in a registry.BlobStore (e.g. from registry/s3blob, gcsblob, or
+// azureblob) under prefix/name/version.json, so they can live next to an S3Registry's prompts.
+// Put checks for an existing version before writing, which is not atomic across writers.
+type BlobStore struct {
+	blobs  registry.BlobStore
+	prefix string
+}
+
+// NewBlobStore creates a store using blobs and key prefix (e.g. "datasets").
+func NewBlobStore(blobs registry.BlobStore, prefix string) *BlobStore {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &BlobStore{blobs: blobs, prefix: prefix}
+}
+
+func (b *BlobStore) key(name, version string) string {
+	return b.prefix + name + "/" + version + ".json"
+}
+
+// Put implements Store.
+func (b *BlobStore) Put(ctx context.Context, d *Dataset) error {
+	if err := prepare(d); err != nil {
+		return err
+	}
+	versions, err := b.Versions(ctx, d.Name)
+	if err != nil {
+		return err
+	}
+	if contains(versions, d.Version) {
+		return fmt.Errorf("%w: %s", ErrExists, d.Ref())
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return b.blobs.Put(ctx, b.key(d.Name, d.Version), data)
+}
+
+// Get implements Store. As with S3Registry, any read error is reported as not found.
+func (b *BlobStore) Get(ctx context.Context, name, version string) (*Dataset, error) {
+	if validKey("name", name) != nil || validKey("version", version) != nil {
+		return nil, notFound(name, version)
+	}
+	data, err := b.blobs.Get(ctx, b.key(name, version))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s@%s: %v", ErrNotFound, name, version, err)
+	}
+	var d Dataset
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("dataset %s@%s: %w", name, version, err)
+	}
+	return &d, nil
+}
+
+// Versions implements Store.
+func (b *BlobStore) Versions(ctx context.Context, name string) ([]string, error) {
+	if validKey("name", name) != nil {
+		return nil, nil
+	}
+	keys, err := b.blobs.List(ctx, b.prefix+name+"/")
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, k := range keys {
+		rest := strings.TrimPrefix(k, b.prefix+name+"/")
+		if v, ok := strings.CutSuffix(rest, ".json"); ok && !strings.Contains(v, "/") {
+			versions = append(versions, v)
+		}
+	}
+	return sortVersions(versions), nil
+}
+
+// Names implements Store.
+func (b *BlobStore) Names(ctx context.Context) ([]string, error) {
+	keys, err := b.blobs.List(ctx, b.prefix)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, k := range keys {
+		name, _, ok := strings.Cut(strings.TrimPrefix(k, b.prefix), "/")
+		if ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete implements Store.
+func (b *BlobStore) Delete(ctx context.Context, name, version string) error {
+	if _, err := b.Get(ctx, name, version); err != nil {
+		return err
+	}
+	return b.blobs.Delete(ctx, b.key(name, version))
+}
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*FileStore)(nil)
+	_ Store = (*BlobStore)(nil)
+)
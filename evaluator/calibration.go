@@ -0,0 +1,255 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LabeledCase is an output with a human verdict, used to calibrate judges: Pass says whether a
+// person judged Actual to meet Expected.
+type LabeledCase struct {
+	Name     string
+	Actual   string
+	Expected Expected
+	Pass     bool
+}
+
+// CalibrationJudge is one judge under calibration, typically an *LLMJudge with a given model and
+// rubric (Criteria or Prompt).
+type CalibrationJudge struct {
+	Name  string
+	Judge Evaluator
+}
+
+// Calibration runs judges over human-labeled cases and measures how often they agree with the
+// labels, so a judge can be trusted (or its model, rubric, or threshold tuned) before it gates
+// promotions.
+type Calibration struct {
+	Judges []CalibrationJudge
+	Cases  []LabeledCase
+	// Concurrency limits how many judge calls run at once (default 4).
+	Concurrency int
+}
+
+// CalibrationReport is the result of a Calibration run, one entry per judge in order.
+type CalibrationReport struct {
+	Judges   []JudgeAgreement `json:"judges"`
+	Duration time.Duration    `json:"duration_ns"`
+}
+
+// JudgeAgreement measures one judge against the human labels. Cases the judge returned an error
+// for count in Errors and as disagreements.
+type JudgeAgreement struct {
+	Judge string `json:"judge"`
+	Total int    `json:"total"`
+	// Agreed counts cases where the judge's verdict matches the label.
+	Agreed   int     `json:"agreed"`
+	Accuracy float64 `json:"accuracy"`
+	// Kappa is Cohen's kappa over the cases judged without error: agreement corrected for chance
+	// (1 perfect, 0 chance, < 0 worse).
+	Kappa float64 `json:"kappa"`
+	// TruePass, FalsePass, TrueFail, and FalseFail are the confusion matrix: the judge's verdict
+	// (pass/fail) and whether it agrees with the label (true/false).
+	TruePass  int `json:"true_pass"`
+	FalsePass int `json:"false_pass"`
+	TrueFail  int `json:"true_fail"`
+	FalseFail int `json:"false_fail"`
+	Errors    int `json:"errors,omitempty"`
+	// Threshold is the score cutoff (pass if Score.Value >= Threshold) with the highest kappa
+	// against the labels, and ThresholdKappa that kappa; use it to tune a judge that reports scores.
+	Threshold      float64             `json:"threshold"`
+	ThresholdKappa float64             `json:"threshold_kappa"`
+	Disagreements  []CalibrationResult `json:"disagreements,omitempty"`
+}
+
+// CalibrationResult is a judge's verdict on one labeled case.
+type CalibrationResult struct {
+	Case   string  `json:"case"`
+	Label  bool    `json:"label"`
+	Pass   bool    `json:"pass"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Run judges every case with every judge and returns the agreement per judge. Run only fails if
+// the calibration is misconfigured.
+func (c *Calibration) Run(ctx context.Context) (*CalibrationReport, error) {
+	if len(c.Judges) == 0 || len(c.Cases) == 0 {
+		return nil, fmt.Errorf("evaluator calibration: at least one judge and one labeled case are required")
+	}
+	for _, j := range c.Judges {
+		if j.Judge == nil {
+			return nil, fmt.Errorf("evaluator calibration: judge %q has no evaluator", j.Name)
+		}
+	}
+	start := time.Now()
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	results := make([][]CalibrationResult, len(c.Judges))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, j := range c.Judges {
+		results[i] = make([]CalibrationResult, len(c.Cases))
+		for k, lc := range c.Cases {
+			wg.Add(1)
+			go func(out *CalibrationResult, judge Evaluator, lc LabeledCase) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				*out = CalibrationResult{Case: lc.Name, Label: lc.Pass}
+				score, err := judge.Evaluate(ctx, lc.Actual, lc.Expected)
+				if err != nil {
+					out.Error = err.Error()
+					return
+				}
+				out.Pass, out.Score, out.Reason = score.Pass, score.Value, score.Reason
+			}(&results[i][k], j.Judge, lc)
+		}
+	}
+	wg.Wait()
+	report := &CalibrationReport{Judges: make([]JudgeAgreement, len(c.Judges))}
+	for i, j := range c.Judges {
+		report.Judges[i] = agreement(j.Name, results[i])
+	}
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+// agreement summarizes a judge's results against their labels.
+func agreement(name string, results []CalibrationResult) JudgeAgreement {
+	a := JudgeAgreement{Judge: name, Total: len(results)}
+	for _, r := range results {
+		if r.Error != "" {
+			a.Errors++
+		}
+		switch {
+		case r.Error != "":
+			// Neither verdict: a disagreement, outside the confusion matrix.
+		case r.Pass && r.Label:
+			a.TruePass++
+		case r.Pass:
+			a.FalsePass++
+		case r.Label:
+			a.FalseFail++
+		default:
+			a.TrueFail++
+		}
+		if r.Error != "" || r.Pass != r.Label {
+			a.Disagreements = append(a.Disagreements, r)
+		}
+	}
+	a.Agreed = a.TruePass + a.TrueFail
+	if a.Total > 0 {
+		a.Accuracy = float64(a.Agreed) / float64(a.Total)
+	}
+	a.Kappa = CohenKappa(a.TruePass, a.FalsePass, a.TrueFail, a.FalseFail)
+	a.Threshold, a.ThresholdKappa = bestThreshold(results)
+	return a
+}
+
+// bestThreshold returns the score cutoff that maximizes kappa against the labels, trying each
+// distinct score of the results that did not fail and a cutoff just above the highest, which fails
+// every case. Ties go to the lowest cutoff.
+func bestThreshold(results []CalibrationResult) (threshold, kappa float64) {
+	var scores []float64
+	for _, r := range results {
+		if r.Error == "" {
+			scores = append(scores, r.Score)
+		}
+	}
+	if len(scores) == 0 {
+		return 0, 0
+	}
+	sort.Float64s(scores)
+	scores = append(scores, math.Nextafter(scores[len(scores)-1], math.Inf(1)))
+	kappa = -2 // below any kappa
+	for i, t := range scores {
+		if i > 0 && t == scores[i-1] {
+			continue
+		}
+		var tp, fp, tn, fn int
+		for _, r := range results {
+			switch {
+			case r.Error != "":
+			case r.Score >= t && r.Label:
+				tp++
+			case r.Score >= t:
+				fp++
+			case r.Label:
+				fn++
+			default:
+				tn++
+			}
+		}
+		if k := CohenKappa(tp, fp, tn, fn); k > kappa {
+			threshold, kappa = t, k
+		}
+	}
+	return threshold, kappa
+}
+
+// CohenKappa returns Cohen's kappa for two raters' pass/fail verdicts given the confusion matrix:
+// truePass and trueFail where they agree, falsePass and falseFail where the first passes or fails
+// a case the second does not. When chance agreement is total (both raters always give the same
+// single verdict), it returns 1 if they agree on every case and 0 otherwise.
+func CohenKappa(truePass, falsePass, trueFail, falseFail int) float64 {
+	n := float64(truePass + falsePass + trueFail + falseFail)
+	if n == 0 {
+		return 0
+	}
+	observed := float64(truePass+trueFail) / n
+	firstPass, secondPass := float64(truePass+falsePass)/n, float64(truePass+falseFail)/n
+	chance := firstPass*secondPass + (1-firstPass)*(1-secondPass)
+	if chance == 1 {
+		if observed == 1 {
+			return 1
+		}
+		return 0
+	}
+	return (observed - chance) / (1 - chance)
+}
+
+// Markdown renders the report as a markdown table of agreement per judge followed by the
+// disagreements, suitable for a pull request comment.
+func (r *CalibrationReport) Markdown() string {
+	var b strings.Builder
+	b.WriteString("## Judge calibration\n\n")
+	b.WriteString("| Judge | Agreed | Accuracy | Kappa | False pass | False fail | Errors | Best threshold |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, j := range r.Judges {
+		fmt.Fprintf(&b, "| %s | %d/%d | %.0f%% | %.2f | %d | %d | %d | %.2f (kappa %.2f) |\n",
+			j.Judge, j.Agreed, j.Total, j.Accuracy*100, j.Kappa, j.FalsePass, j.FalseFail, j.Errors, j.Threshold, j.ThresholdKappa)
+	}
+	var lines []string
+	for _, j := range r.Judges {
+		for _, d := range j.Disagreements {
+			verdict := "fail"
+			if d.Pass {
+				verdict = "pass"
+			}
+			label := "fail"
+			if d.Label {
+				label = "pass"
+			}
+			detail := oneLine(d.Reason, 120)
+			if d.Error != "" {
+				verdict, detail = "error", d.Error
+			}
+			lines = append(lines, strings.TrimSpace(fmt.Sprintf("- `%s` — **%s**: judged %s, labeled %s. %s", j.Judge, d.Case, verdict, label, detail)))
+		}
+	}
+	if len(lines) > 0 {
+		b.WriteString("\n<details><summary>Disagreements</summary>\n\n")
+		b.WriteString(strings.Join(lines, "\n"))
+		b.WriteString("\n\n</details>\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package evaluator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCohenKappa(t *testing.T) {
+	tests := []struct {
+		name                                     string
+		truePass, falsePass, trueFail, falseFail int
+		want                                     float64
+	}{
+		{"empty", 0, 0, 0, 0, 0},
+		{"perfect", 10, 0, 10, 0, 1},
+		{"opposite", 0, 5, 0, 5, -1},
+		{"chance", 5, 5, 5, 5, 0},
+		{"moderate", 20, 5, 15, 10, 0.4},
+		{"slight", 45, 15, 15, 25, 0.06 / 0.46},
+		{"always pass, chance agreement 1", 4, 0, 0, 0, 1},
+		{"always fail, chance agreement 1", 0, 0, 4, 0, 1},
+		{"one rater constant", 3, 1, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, CohenKappa(tt.truePass, tt.falsePass, tt.trueFail, tt.falseFail), 1e-9)
+		})
+	}
+}
+
+func TestBestThreshold(t *testing.T) {
+	res := func(score float64, label bool) CalibrationResult {
+		return CalibrationResult{Score: score, Label: label}
+	}
+	failed := CalibrationResult{Label: true, Error: "timeout"}
+	tests := []struct {
+		name          string
+		results       []CalibrationResult
+		wantThreshold float64
+		wantKappa     float64
+	}{
+		{"none", nil, 0, 0},
+		{"only errors", []CalibrationResult{failed}, 0, 0},
+		{"separable", []CalibrationResult{res(0.2, false), res(0.6, true), res(0.9, true)}, 0.6, 1},
+		{"ties go to the lowest cutoff", []CalibrationResult{res(0.5, true), res(0.7, true)}, 0.5, 1},
+		{"errors are left out", []CalibrationResult{res(0.2, false), failed, res(0.8, true)}, 0.8, 1},
+		{"all fail: a cutoff above every score", []CalibrationResult{res(0.3, false), res(0.9, false)}, math.Nextafter(0.9, 2), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			threshold, kappa := bestThreshold(tt.results)
+			assert.Equal(t, tt.wantThreshold, threshold)
+			assert.InDelta(t, tt.wantKappa, kappa, 1e-9)
+		})
+	}
+}
+
+func TestAgreement_Errors(t *testing.T) {
+	a := agreement("judge", []CalibrationResult{
+		{Case: "a", Label: true, Pass: true, Score: 0.9},
+		{Case: "b", Label: false, Pass: false, Score: 0.1},
+		{Case: "c", Label: true, Error: "timeout"},
+		{Case: "d", Label: false, Pass: true, Score: 0.6},
+	})
+	assert.Equal(t, 4, a.Total)
+	assert.Equal(t, 2, a.Agreed)
+	assert.Equal(t, 0.5, a.Accuracy, "errors count as disagreements")
+	assert.Equal(t, 1, a.Errors)
+	assert.Equal(t, 1, a.FalsePass)
+	assert.InDelta(t, 0.4, a.Kappa, 1e-9, "kappa is over the cases judged without error")
+	assert.Equal(t, 0.9, a.Threshold)
+	assert.InDelta(t, 1, a.ThresholdKappa, 1e-9)
+	assert.Len(t, a.Disagreements, 2)
+}
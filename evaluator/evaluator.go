@@ -26,6 +26,14 @@ type Evaluator interface {
 	Evaluate(ctx context.Context, actual string, expected Expected) (Score, error)
 }
 
+// ProviderDependent is implemented by evaluators that call a model or another remote service to
+// score an output (LLMJudge, Similarity). Suites skip them when their executor is offline (see
+// executor.WithOffline).
+type ProviderDependent interface {
+	Evaluator
+	RequiresProvider() bool
+}
+
 // Score represents an evaluation score (0-1 or pass/fail).
 type Score struct {
 	Pass  bool
@@ -8,16 +8,26 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/executor"
 	"github.com/klejdi94/loom/provider"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/template"
 )
 
 // LLMJudge is an evaluator that calls an LLM to judge whether actual output meets the expected/criteria.
+// Set either Provider (raw call) or Executor (retry, middleware, budgets); Executor takes precedence.
 type LLMJudge struct {
 	Provider provider.Provider
 	Model    string
 	Criteria string
-	// System prompt for the judge; if empty, a default is used.
+	// System prompt for the judge; if empty, a default is used. Ignored when Prompt is set.
 	System string
+	// Executor runs the judge prompt when set.
+	Executor *executor.Executor
+	// Prompt is the judge prompt used with Executor, rendered with the variables "expected",
+	// "actual", and "criteria". If nil, DefaultJudgeTemplate is used.
+	Prompt *core.Prompt
 }
 
 // DefaultJudgeSystem is the default system prompt for the judge model.
@@ -26,6 +36,34 @@ Line 1: SCORE: <number from 0.0 to 1.0>
 Line 2: PASS or FAIL
 Then optionally a brief reason on the next line.`
 
+// DefaultJudgeTemplate is the judge user template used with an Executor when no Prompt is set.
+const DefaultJudgeTemplate = `Expected output:
+{{.expected}}
+
+Actual output:
+{{.actual}}
+
+Criteria: {{.criteria}}
+
+Provide SCORE (0.0-1.0) and PASS or FAIL.`
+
+// NewRegistryJudge creates a judge whose prompt is the production version of promptID in reg,
+// executed via exec. The prompt is rendered with eng (template.NewEngine() if nil).
+func NewRegistryJudge(ctx context.Context, reg registry.Registry, promptID string, exec *executor.Executor, eng *template.Engine) (*LLMJudge, error) {
+	p, err := reg.GetProduction(ctx, promptID)
+	if err != nil {
+		return nil, fmt.Errorf("judge prompt %q: %w", promptID, err)
+	}
+	if eng == nil {
+		eng = template.NewEngine()
+	}
+	p.SetRenderer(eng)
+	return &LLMJudge{Executor: exec, Prompt: p}, nil
+}
+
+// RequiresProvider implements ProviderDependent.
+func (j *LLMJudge) RequiresProvider() bool { return true }
+
 // Evaluate implements Evaluator. It calls the provider with a prompt containing expected, actual, and criteria, then parses SCORE and PASS/FAIL.
 func (j *LLMJudge) Evaluate(ctx context.Context, actual string, expected Expected) (Score, error) {
 	system := j.System
@@ -42,20 +80,48 @@ func (j *LLMJudge) Evaluate(ctx context.Context, actual string, expected Expecte
 	if model == "" {
 		model = "gpt-4o-mini"
 	}
-	req := provider.CompletionRequest{
-		Model:  model,
-		System: system,
-		Prompt: prompt,
-	}
-	resp, err := j.Provider.Complete(ctx, req)
-	if err != nil {
-		return Score{Pass: false, Value: 0, Reason: "judge call failed: " + err.Error()}, nil
+	var content string
+	if j.Executor != nil {
+		res, err := j.executeJudge(ctx, model, system, actual, expected.Output, criteria)
+		if err != nil {
+			return Score{Pass: false, Value: 0, Reason: "judge call failed: " + err.Error()}, nil
+		}
+		content = res
+	} else {
+		req := provider.CompletionRequest{
+			Model:  model,
+			System: system,
+			Prompt: prompt,
+		}
+		resp, err := j.Provider.Complete(ctx, req)
+		if err != nil {
+			return Score{Pass: false, Value: 0, Reason: "judge call failed: " + err.Error()}, nil
+		}
+		content = resp.Content
 	}
-	content := strings.TrimSpace(resp.Content)
+	content = strings.TrimSpace(content)
 	score, pass, reason := parseJudgeResponse(content)
 	return Score{Pass: pass, Value: score, Reason: reason}, nil
 }
 
+// executeJudge runs the judge prompt (or the default one) through the executor and returns its output.
+func (j *LLMJudge) executeJudge(ctx context.Context, model, system, actual, expected, criteria string) (string, error) {
+	p := j.Prompt
+	if p == nil {
+		p = &core.Prompt{ID: "loom-judge", Version: "1.0.0", System: system, Template: DefaultJudgeTemplate}
+		p.SetRenderer(template.NewEngine())
+	}
+	res, err := j.Executor.Execute(ctx, executor.ExecuteRequest{
+		Prompt: p,
+		Input:  core.Input{"expected": expected, "actual": actual, "criteria": criteria},
+		Model:  model,
+	})
+	if err != nil {
+		return "", err
+	}
+	return res.Content, nil
+}
+
 var (
 	scoreLineRe = regexp.MustCompile(`(?i)score:\s*([0-9.]+)`)
 	passFailRe  = regexp.MustCompile(`(?i)\b(PASS|FAIL)\b`)
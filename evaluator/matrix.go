@@ -0,0 +1,219 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/cost"
+	"github.com/klejdi94/loom/executor"
+)
+
+// MatrixModel is one model column of a Matrix.
+type MatrixModel struct {
+	// Name labels the model in reports (defaults to Model).
+	Name     string
+	Executor *executor.Executor
+	// Model is passed to the executor for each case.
+	Model string
+	// Estimator prices the model's requests; without it the model's cost is not tracked or budgeted.
+	Estimator *cost.Estimator
+}
+
+// Matrix runs the same cases against every combination of prompt version and model, in parallel.
+type Matrix struct {
+	// Prompts are the versions under test (with a renderer set).
+	Prompts    []*core.Prompt
+	Models     []MatrixModel
+	Cases      []Case
+	Evaluators []Evaluator // ExactMatch if empty
+	// Budget caps the combined spend of all cells; cases that no longer fit are skipped.
+	Budget *cost.Budget
+	// ExpectedOutputTokens is used to estimate each case's cost before running it.
+	ExpectedOutputTokens int
+	// Concurrency limits how many cells run at once (default 4).
+	Concurrency int
+	// Streaming runs cases via Provider.Stream (see Suite.WithStreaming).
+	Streaming    bool
+	ChunkTimeout time.Duration
+}
+
+// MatrixReport is the comparative result of a Matrix run.
+type MatrixReport struct {
+	PromptID  string        `json:"prompt_id"`
+	Cells     []MatrixCell  `json:"cells"`
+	BudgetUSD float64       `json:"budget_usd,omitempty"`
+	SpentUSD  float64       `json:"spent_usd"`
+	Duration  time.Duration `json:"duration_ns"`
+}
+
+// MatrixCell is the result for one prompt version and model.
+type MatrixCell struct {
+	Version  string          `json:"version"`
+	Model    string          `json:"model"`
+	Total    int             `json:"total"`
+	Passed   int             `json:"passed"`
+	Failed   int             `json:"failed"`
+	Skipped  int             `json:"skipped,omitempty"`
+	PassRate float64         `json:"pass_rate"`
+	CostUSD  float64         `json:"cost_usd"`
+	Duration time.Duration   `json:"duration_ns"`
+	Cases    []MatrixCaseRun `json:"cases"`
+	// Offline marks a cell run with an offline executor (see Report.Offline).
+	Offline bool `json:"offline,omitempty"`
+}
+
+// MatrixCaseRun summarizes one case in a cell.
+type MatrixCaseRun struct {
+	Name    string  `json:"name"`
+	Pass    bool    `json:"pass"`
+	Actual  string  `json:"actual,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	CostUSD float64 `json:"cost_usd,omitempty"`
+}
+
+// Run executes every cell and returns the report in version-major order. Cell failures are
+// recorded in the report; Run only fails if the matrix is misconfigured.
+func (m *Matrix) Run(ctx context.Context) (*MatrixReport, error) {
+	if len(m.Prompts) == 0 || len(m.Models) == 0 {
+		return nil, fmt.Errorf("evaluator matrix: at least one prompt version and one model are required")
+	}
+	for _, mod := range m.Models {
+		if mod.Executor == nil {
+			return nil, fmt.Errorf("evaluator matrix: model %q has no executor", mod.label())
+		}
+	}
+	start := time.Now()
+	report := &MatrixReport{PromptID: m.Prompts[0].ID, Cells: make([]MatrixCell, len(m.Prompts)*len(m.Models))}
+	concurrency := m.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, p := range m.Prompts {
+		for j, mod := range m.Models {
+			idx := i*len(m.Models) + j
+			wg.Add(1)
+			go func(p *core.Prompt, mod MatrixModel) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				report.Cells[idx] = m.runCell(ctx, p, mod)
+			}(p, mod)
+		}
+	}
+	wg.Wait()
+	if m.Budget != nil {
+		report.BudgetUSD = m.Budget.Limit()
+	}
+	for _, c := range report.Cells {
+		report.SpentUSD += c.CostUSD
+	}
+	report.Duration = time.Since(start)
+	return report, nil
+}
+
+func (m *Matrix) runCell(ctx context.Context, p *core.Prompt, mod MatrixModel) MatrixCell {
+	suite := NewTestSuite(p.ID).
+		WithPrompt(p, p.Version).
+		WithExecutor(mod.Executor).
+		WithModel(mod.Model)
+	if len(m.Evaluators) > 0 {
+		suite.WithEvaluators(m.Evaluators...)
+	}
+	if mod.Estimator != nil {
+		suite.WithBudget(mod.Estimator, m.Budget, m.ExpectedOutputTokens)
+	}
+	if m.Streaming {
+		suite.WithStreaming(m.ChunkTimeout)
+	}
+	for _, c := range m.Cases {
+		suite.AddCase(c.Name, c.Input, c.Expected)
+	}
+	cell := MatrixCell{Version: p.Version, Model: mod.label()}
+	rep, err := suite.Run(ctx)
+	if err != nil {
+		cell.Cases = []MatrixCaseRun{{Name: "(suite)", Error: err.Error()}}
+		return cell
+	}
+	cell.Total, cell.Passed, cell.Failed, cell.Skipped = rep.Total, rep.Passed, rep.Failed, rep.Skipped
+	cell.CostUSD = rep.CostUSD
+	cell.Offline = rep.Offline
+	cell.Duration = rep.Duration
+	if rep.Total > 0 {
+		cell.PassRate = float64(rep.Passed) / float64(rep.Total)
+	}
+	for _, r := range rep.Results {
+		run := MatrixCaseRun{Name: r.CaseName, Pass: r.Pass, Actual: r.Actual, CostUSD: r.CostUSD}
+		if r.Error != nil {
+			run.Error = r.Error.Error()
+		}
+		cell.Cases = append(cell.Cases, run)
+	}
+	return cell
+}
+
+func (mod MatrixModel) label() string {
+	if mod.Name != "" {
+		return mod.Name
+	}
+	return mod.Model
+}
+
+// Markdown renders the report as a markdown summary table followed by failing cases, suitable
+// for a pull request comment.
+func (r *MatrixReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Evaluation matrix: %s\n\n", r.PromptID)
+	b.WriteString("| Version | Model | Passed | Pass rate | Cost (USD) | Duration |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, c := range r.Cells {
+		passed := fmt.Sprintf("%d/%d", c.Passed, c.Total)
+		if c.Skipped > 0 {
+			passed += fmt.Sprintf(" (%d skipped)", c.Skipped)
+		}
+		if c.Offline {
+			passed += " (offline: rendered only)"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %.0f%% | $%.4f | %s |\n",
+			c.Version, c.Model, passed, c.PassRate*100, c.CostUSD, c.Duration.Round(time.Millisecond))
+	}
+	b.WriteString("\n")
+	if r.BudgetUSD > 0 {
+		fmt.Fprintf(&b, "Spent $%.4f of $%.2f budget.\n", r.SpentUSD, r.BudgetUSD)
+	} else {
+		fmt.Fprintf(&b, "Spent $%.4f.\n", r.SpentUSD)
+	}
+	var failures []string
+	for _, c := range r.Cells {
+		for _, run := range c.Cases {
+			if run.Pass {
+				continue
+			}
+			reason := run.Error
+			if reason == "" {
+				reason = "output: " + oneLine(run.Actual, 120)
+			}
+			failures = append(failures, fmt.Sprintf("- `%s` × `%s` — **%s**: %s", c.Version, c.Model, run.Name, reason))
+		}
+	}
+	if len(failures) > 0 {
+		b.WriteString("\n<details><summary>Failures</summary>\n\n")
+		b.WriteString(strings.Join(failures, "\n"))
+		b.WriteString("\n\n</details>\n")
+	}
+	return b.String()
+}
+
+// oneLine collapses whitespace in s and truncates it to max runes.
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > max {
+		return string(r[:max]) + "…"
+	}
+	return s
+}
@@ -12,6 +12,9 @@ type Similarity struct {
 	Threshold float64
 }
 
+// RequiresProvider implements ProviderDependent: the embedder is a remote model.
+func (s *Similarity) RequiresProvider() bool { return true }
+
 // Evaluate implements Evaluator.
 func (s *Similarity) Evaluate(ctx context.Context, actual string, expected Expected) (Score, error) {
 	threshold := s.Threshold
@@ -2,21 +2,30 @@ package evaluator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/cost"
 	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/provider"
 )
 
 // Suite runs a set of test cases against a prompt (or executor).
 type Suite struct {
-	name    string
-	prompt  *core.Prompt
-	exec    *executor.Executor
-	cases   []Case
-	evals   []Evaluator
-	version string
+	name         string
+	prompt       *core.Prompt
+	exec         *executor.Executor
+	cases        []Case
+	evals        []Evaluator
+	version      string
+	stream       bool
+	chunkTimeout time.Duration
+	model        string
+	estimator    *cost.Estimator
+	budget       *cost.Budget
+	outputTokens int
 }
 
 // NewTestSuite creates a new test suite with the given name.
@@ -37,18 +46,54 @@ func (s *Suite) WithExecutor(e *executor.Executor) *Suite {
 	return s
 }
 
+// WithStreaming makes the suite run cases via Provider.Stream and evaluate the aggregated text.
+// If chunkTimeout > 0, a case fails when no chunk arrives within it.
+func (s *Suite) WithStreaming(chunkTimeout time.Duration) *Suite {
+	s.stream = true
+	s.chunkTimeout = chunkTimeout
+	return s
+}
+
+// WithModel sets the model passed to the executor for each case.
+func (s *Suite) WithModel(model string) *Suite {
+	s.model = model
+	return s
+}
+
+// WithBudget prices executed cases with est and, if budget is non-nil, skips cases whose estimated
+// cost (rendered input plus expectedOutputTokens) no longer fits in it. Skipped cases fail with
+// cost.ErrBudgetExceeded. Share one budget between suites to cap their combined spend.
+func (s *Suite) WithBudget(est *cost.Estimator, budget *cost.Budget, expectedOutputTokens int) *Suite {
+	s.estimator = est
+	s.budget = budget
+	s.outputTokens = expectedOutputTokens
+	return s
+}
+
 // AddCase adds a test case.
 func (s *Suite) AddCase(name string, input map[string]interface{}, expected Expected) *Suite {
 	s.cases = append(s.cases, Case{Name: name, Input: input, Expected: expected})
 	return s
 }
 
+// AddCases adds test cases, e.g. those of a stored dataset (see package dataset).
+func (s *Suite) AddCases(cases ...Case) *Suite {
+	s.cases = append(s.cases, cases...)
+	return s
+}
+
 // WithEvaluator adds an evaluator (e.g. ExactMatch, ContainsAll).
 func (s *Suite) WithEvaluator(ev Evaluator) *Suite {
 	s.evals = append(s.evals, ev)
 	return s
 }
 
+// WithEvaluators replaces the suite's evaluators, including the default ExactMatch.
+func (s *Suite) WithEvaluators(evs ...Evaluator) *Suite {
+	s.evals = append([]Evaluator(nil), evs...)
+	return s
+}
+
 // Report holds the results of running a suite.
 type Report struct {
 	Suite    string
@@ -57,8 +102,15 @@ type Report struct {
 	Total    int
 	Passed   int
 	Failed   int
+	// Skipped counts cases not run because the budget was exhausted (also counted in Failed).
+	Skipped  int
 	Results  []CaseResult
 	Duration time.Duration
+	// CostUSD is the total cost of executed cases (requires WithBudget).
+	CostUSD float64
+	// Offline marks a run with an offline executor: cases were only rendered and evaluators that
+	// need a provider (see ProviderDependent) were skipped.
+	Offline bool
 }
 
 // CaseResult is the result of one test case.
@@ -69,9 +121,14 @@ type CaseResult struct {
 	Expected Expected
 	Scores   []Score
 	Error    error
+	Usage    provider.TokenUsage
+	CostUSD  float64
+	// Offline marks a case whose Actual is the rendered prompt from an offline executor.
+	Offline bool
 }
 
-// Run executes all cases and returns a report. If no executor is set, only rendering is tested.
+// Run executes all cases and returns a report. If no executor is set, only rendering is tested; if
+// it is offline, rendering is tested and evaluators that need a provider are skipped.
 func (s *Suite) Run(ctx context.Context) (*Report, error) {
 	if s.prompt == nil {
 		return nil, fmt.Errorf("evaluator: prompt is required")
@@ -83,6 +140,7 @@ func (s *Suite) Run(ctx context.Context) (*Report, error) {
 		Version:  s.version,
 		Total:    len(s.cases),
 		Results:  make([]CaseResult, 0, len(s.cases)),
+		Offline:  s.offline(),
 	}
 	for _, c := range s.cases {
 		res := s.runCase(ctx, c)
@@ -92,25 +150,53 @@ func (s *Suite) Run(ctx context.Context) (*Report, error) {
 		} else {
 			report.Failed++
 		}
+		if errors.Is(res.Error, cost.ErrBudgetExceeded) {
+			report.Skipped++
+		}
+		report.CostUSD += res.CostUSD
 	}
 	report.Duration = time.Since(start)
 	return report, nil
 }
 
+// offline reports whether the suite's executor is offline.
+func (s *Suite) offline() bool {
+	return s.exec != nil && s.exec.Offline
+}
+
 func (s *Suite) runCase(ctx context.Context, c Case) CaseResult {
 	out := CaseResult{CaseName: c.Name, Expected: c.Expected}
 	var actual string
 	if s.exec != nil {
+		reserved, err := s.reserve(ctx, c)
+		if err != nil {
+			out.Error = err
+			out.Pass = false
+			return out
+		}
 		result, err := s.exec.Execute(ctx, executor.ExecuteRequest{
-			Prompt: s.prompt,
-			Input:  c.Input,
+			Prompt:       s.prompt,
+			Input:        c.Input,
+			Model:        s.model,
+			Stream:       s.stream,
+			ChunkTimeout: s.chunkTimeout,
 		})
+		if result != nil {
+			out.Usage = result.Usage
+			if s.estimator != nil {
+				out.CostUSD = s.estimator.Cost(result.Usage)
+			}
+		}
+		if s.budget != nil {
+			s.budget.Settle(reserved, out.CostUSD)
+		}
 		if err != nil {
 			out.Error = err
 			out.Pass = false
 			return out
 		}
 		actual = result.Content
+		out.Offline = result.Offline
 	} else {
 		rendered, err := s.prompt.Render(ctx, c.Input)
 		if err != nil {
@@ -123,6 +209,9 @@ func (s *Suite) runCase(ctx context.Context, c Case) CaseResult {
 	out.Actual = actual
 	allPass := true
 	for _, ev := range s.evals {
+		if pd, ok := ev.(ProviderDependent); ok && out.Offline && pd.RequiresProvider() {
+			continue
+		}
 		score, err := ev.Evaluate(ctx, actual, c.Expected)
 		if err != nil {
 			out.Error = err
@@ -137,3 +226,19 @@ func (s *Suite) runCase(ctx context.Context, c Case) CaseResult {
 	out.Pass = allPass
 	return out
 }
+
+// reserve reserves the estimated cost of case c in the budget, returning the amount reserved.
+func (s *Suite) reserve(ctx context.Context, c Case) (float64, error) {
+	if s.budget == nil || s.estimator == nil || s.offline() {
+		return 0, nil
+	}
+	rendered, err := s.prompt.Render(ctx, c.Input)
+	if err != nil {
+		return 0, err
+	}
+	_, _, estimate := s.estimator.Estimate(ctx, rendered, s.outputTokens)
+	if !s.budget.Reserve(estimate) {
+		return 0, fmt.Errorf("case %q (estimated $%.4f): %w", c.Name, estimate, cost.ErrBudgetExceeded)
+	}
+	return estimate, nil
+}
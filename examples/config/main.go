@@ -0,0 +1,69 @@
+// Example: build the registry, provider, and middleware chain from a YAML config file.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/klejdi94/loom"
+	"github.com/klejdi94/loom/config"
+	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/registry"
+)
+
+func main() {
+	path := flag.String("config", "loom.yaml", "Config file")
+	providerName := flag.String("provider", "local", "Provider from the config")
+	model := flag.String("model", "llama3", "Model to request")
+	run := flag.Bool("run", false, "Call the provider (requires it to be reachable)")
+	flag.Parse()
+
+	cfg, err := config.Load(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+	reg, err := cfg.Registry.Open(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	eng := loom.DefaultEngine()
+	prompt := loom.New("greeter").
+		WithVersion("1.0.0").
+		WithTemplate("Say hello to {{.name}} in one sentence.").
+		WithVariable("name", loom.String(loom.Required())).
+		Build(eng)
+	if err := reg.Store(ctx, prompt); err != nil {
+		log.Fatal(err)
+	}
+	if err := reg.Promote(ctx, "greeter", "1.0.0", registry.StageProduction); err != nil {
+		log.Fatal(err)
+	}
+	prod, err := reg.GetProduction(ctx, "greeter")
+	if err != nil {
+		log.Fatal(err)
+	}
+	prod.SetRenderer(eng)
+	rendered, err := prod.Render(ctx, loom.Input{"name": "World"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("User:", rendered.User)
+	if !*run {
+		return
+	}
+
+	// NewProvider wraps the provider in the config's middleware (logging, metrics, cache, ...).
+	p, err := cfg.NewProvider(*providerName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	res, err := executor.New(p).Execute(ctx, executor.ExecuteRequest{Prompt: prod, Input: loom.Input{"name": "World"}, Model: *model})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Response:", res.Content)
+}
@@ -0,0 +1,128 @@
+// Example: the whole stack against real services. Prompts are versioned in Postgres, run on
+// Ollama through the config's middleware, recorded in Redis analytics, evaluated, and backed up
+// to MinIO (S3). Start the services first (see docker-compose.yml), then:
+//
+//	go run . -config loom.yaml
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klejdi94/loom"
+	"github.com/klejdi94/loom/analytics"
+	"github.com/klejdi94/loom/config"
+	"github.com/klejdi94/loom/evaluator"
+	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/s3blob"
+)
+
+func main() {
+	path := flag.String("config", "loom.yaml", "Config file")
+	model := flag.String("model", "qwen2.5:0.5b", "Ollama model (pull it first)")
+	s3URL := flag.String("s3", "http://localhost:9000", "S3-compatible endpoint for the backup (MinIO)")
+	bucket := flag.String("bucket", "loom", "Backup bucket (created if needed)")
+	flag.Parse()
+
+	ctx := context.Background()
+	cfg, err := config.Load(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reg, err := cfg.Registry.Open(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := cfg.Analytics.Open(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 1. Version the prompt: StoreNext stores a new version only when the content changed, so
+	// running the example again reuses the stored one.
+	eng := loom.DefaultEngine()
+	p := loom.New("capital").
+		WithSystem("Answer with the city name only.").
+		WithTemplate("What is the capital of {{.country}}?").
+		WithVariable("country", loom.String(loom.Required())).
+		Build(eng)
+	stored, err := registry.StoreNext(ctx, reg, p)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("prompt %s@%s (new version: %v)\n", p.ID, p.Version, stored)
+	if err := reg.Promote(ctx, p.ID, p.Version, registry.StageProduction); err != nil {
+		log.Fatal(err)
+	}
+	prod, err := reg.GetProduction(ctx, p.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	prod.SetRenderer(eng)
+
+	// 2. Run it through the config's middleware (logging, cache, metrics, circuit breaker) and
+	// record each run.
+	exec, err := cfg.NewExecutor("local", executor.WithRetry(2, executor.ExponentialBackoff(time.Second, 5*time.Second)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, country := range []string{"France", "Japan", "France"} {
+		start := time.Now()
+		res, err := exec.Execute(ctx, executor.ExecuteRequest{Prompt: prod, Input: loom.Input{"country": country}, Model: *model, MaxTokens: 20})
+		rec := analytics.RunRecord{PromptID: prod.ID, Version: prod.Version, Fingerprint: prod.Fingerprint(), LatencyMs: time.Since(start).Milliseconds(), Success: err == nil, At: time.Now()}
+		if err == nil {
+			rec.InputTokens, rec.OutputTokens = res.Usage.PromptTokens, res.Usage.CompletionTokens
+			fmt.Printf("%s: %s\n", country, res.Content)
+		} else {
+			fmt.Printf("%s: error: %v\n", country, err)
+		}
+		if err := store.Record(ctx, rec); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// 3. Evaluate the production version.
+	report, err := evaluator.NewTestSuite("capitals").
+		WithPrompt(prod, prod.Version).
+		WithExecutor(exec).
+		WithModel(*model).
+		AddCase("italy", map[string]interface{}{"country": "Italy"}, evaluator.Expected{Contains: []string{"Rome"}}).
+		AddCase("kenya", map[string]interface{}{"country": "Kenya"}, evaluator.Expected{Contains: []string{"Nairobi"}}).
+		WithEvaluator(evaluator.ContainsAll{}).
+		Run(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("eval: %d/%d passed\n", report.Passed, report.Total)
+
+	// 4. Read the runs back from analytics.
+	aggs, err := store.Query(ctx, analytics.Query{PromptID: prod.ID, GroupBy: "version"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, a := range aggs {
+		fmt.Printf("analytics %s: %d runs, %d ok, avg %.0fms\n", a.Key, a.Runs, a.SuccessCount, a.AvgLatencyMs)
+	}
+
+	// 5. Back the registry up to MinIO.
+	client := s3.New(s3.Options{Region: "us-east-1", BaseEndpoint: aws.String(*s3URL), UsePathStyle: true,
+		Credentials: credentials.NewStaticCredentialsProvider("minioadmin", "minioadmin", "")})
+	if _, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: bucket}); err != nil {
+		if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: bucket}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	backup := registry.NewS3Registry(s3blob.New(client, *bucket, "fullstack/"), "prompts/")
+	res, err := registry.NewSyncer(reg, backup).Sync(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("backup: %d stored, %d updated\n", res.Stored, res.Updated)
+}
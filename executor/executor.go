@@ -3,11 +3,16 @@ package executor
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"time"
 
 	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/fewshot"
 	"github.com/klejdi94/loom/provider"
 )
 
@@ -17,8 +22,18 @@ type Executor struct {
 	MaxRetries  int
 	Backoff     BackoffFunc
 	BaseTimeout time.Duration
+	// Offline makes Execute and Stream return the rendered prompt instead of calling Provider,
+	// which may then be nil (see WithOffline).
+	Offline bool
+	// ValidateOutput makes Execute check responses against the prompt's output schema (see
+	// WithOutputValidation).
+	ValidateOutput bool
 }
 
+// ErrInvalidOutput is returned by Execute, with output validation enabled, when the response is
+// not JSON matching the prompt's output schema on any attempt.
+var ErrInvalidOutput = errors.New("executor: output does not match schema")
+
 // BackoffFunc returns delay before the next retry (attempt is 0-based).
 type BackoffFunc func(attempt int) time.Duration
 
@@ -51,6 +66,28 @@ func WithTimeout(d time.Duration) ExecutorOption {
 	}
 }
 
+// WithOffline puts the executor in offline mode when offline is true: prompts are rendered and
+// validated as usual, but the provider is never called and results are marked Offline, with the
+// rendered user prompt as their content. Use it where no provider is reachable, such as CI or
+// air-gapped environments, to exercise everything but the model call; evaluator suites skip
+// evaluators that need a model (see evaluator.ProviderDependent) on an offline executor.
+func WithOffline(offline bool) ExecutorOption {
+	return func(e *Executor) {
+		e.Offline = offline
+	}
+}
+
+// WithOutputValidation makes Execute check responses to prompts with an output schema when
+// validate is true: the content, without a surrounding markdown code fence, must be JSON matching
+// the schema (see core.ValidateSchema). A response that does not match counts as a failed attempt
+// and is retried like a provider error; the decoded value is returned in ExecuteResult.Output.
+// Streams and offline results are not checked.
+func WithOutputValidation(validate bool) ExecutorOption {
+	return func(e *Executor) {
+		e.ValidateOutput = validate
+	}
+}
+
 // New creates an executor that uses the given provider.
 func New(p provider.Provider, opts ...ExecutorOption) *Executor {
 	e := &Executor{
@@ -64,7 +101,8 @@ func New(p provider.Provider, opts ...ExecutorOption) *Executor {
 	return e
 }
 
-// ExecuteRequest holds options for a single completion.
+// ExecuteRequest holds options for a single completion. Model, Temperature, MaxTokens,
+// StopTokens, and ResponseFormat default to the prompt's core.ModelConfig when zero.
 type ExecuteRequest struct {
 	Prompt      *core.Prompt
 	Input       core.Input
@@ -72,7 +110,114 @@ type ExecuteRequest struct {
 	Temperature float64
 	MaxTokens   int
 	StopTokens  []string
-	Timeout     time.Duration
+	// ResponseFormat selects the provider's JSON mode (see core.ResponseFormat).
+	ResponseFormat core.ResponseFormat
+	Timeout        time.Duration
+	// Stream makes the executor call Provider.Stream and aggregate the chunks instead of calling Complete.
+	Stream bool
+	// ChunkTimeout, when streaming, fails the attempt if no chunk arrives within this duration.
+	ChunkTimeout time.Duration
+}
+
+// defaultModel is used when ExecuteRequest.Model is empty.
+const defaultModel = "gpt-3.5-turbo"
+
+// CacheKey returns a deterministic response cache key for the request: CacheKeyPrefix of the
+// prompt's id and version followed by a hash of the prompt's content (including its examples,
+// their fewshot.Spec and placement, its token budget, its locale variants, and its template syntax
+// and delimiters), the input (map keys sorted), and the model and sampling parameters. Equal
+// requests get equal keys however the input map was built, and editing a stored version changes
+// its keys. It fails if the input cannot be encoded as JSON.
+func (r ExecuteRequest) CacheKey() (string, error) {
+	if r.Prompt == nil {
+		return "", fmt.Errorf("executor: prompt is required")
+	}
+	r, err := r.withPromptDefaults()
+	if err != nil {
+		return "", err
+	}
+	model := r.Model
+	if model == "" {
+		model = defaultModel
+	}
+	data, err := json.Marshal(struct {
+		System      string
+		Template    string
+		Messages    []core.Message `json:",omitempty"`
+		Variables   []core.Variable
+		Tools       []core.Tool
+		Examples    []core.Example
+		Schema      map[string]interface{} `json:",omitempty"`
+		Selection   interface{}
+		Placement   interface{} `json:",omitempty"`
+		Budget      interface{} `json:",omitempty"`
+		Locales     interface{} `json:",omitempty"`
+		Syntax      interface{} `json:",omitempty"`
+		Delims      interface{} `json:",omitempty"`
+		Input       core.Input
+		Model       string
+		Temperature float64
+		MaxTokens   int
+		StopTokens  []string
+		Format      core.ResponseFormat `json:",omitempty"`
+	}{r.Prompt.System, r.Prompt.Template, r.Prompt.Messages, r.Prompt.Variables, r.Prompt.Tools, r.Prompt.Examples, r.Prompt.OutputSchema, r.Prompt.Metadata[fewshot.MetadataKey],
+		r.Prompt.Metadata[fewshot.FormatKey], r.Prompt.Metadata[core.TokenBudgetKey], r.Prompt.Metadata[core.LocalesKey], r.Prompt.Metadata[core.SyntaxKey], r.Prompt.Metadata[core.DelimsKey], r.Input, model, r.Temperature, r.MaxTokens, r.StopTokens, r.ResponseFormat})
+	if err != nil {
+		return "", fmt.Errorf("executor cache key: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return CacheKeyPrefix(r.Prompt.ID, r.Prompt.Version) + hex.EncodeToString(sum[:]), nil
+}
+
+// withContextLocale returns r with the context's locale (see core.WithLocale) in its input if the
+// prompt has locale variants and the input does not choose one, so that the cache key tells the
+// variants apart.
+func (r ExecuteRequest) withContextLocale(ctx context.Context) ExecuteRequest {
+	locale := core.LocaleFromContext(ctx)
+	if _, ok := r.Prompt.Metadata[core.LocalesKey]; !ok || locale == "" || r.Input[core.LocaleInputKey] != nil {
+		return r
+	}
+	input := make(core.Input, len(r.Input)+1)
+	for k, v := range r.Input {
+		input[k] = v
+	}
+	input[core.LocaleInputKey] = locale
+	r.Input = input
+	return r
+}
+
+// withPromptDefaults returns r with its unset generation parameters taken from the prompt's
+// core.ModelConfig.
+func (r ExecuteRequest) withPromptDefaults() (ExecuteRequest, error) {
+	config, err := core.ModelConfigOf(r.Prompt)
+	if err != nil {
+		return r, fmt.Errorf("executor: %w", err)
+	}
+	if r.Model == "" {
+		r.Model = config.Model
+	}
+	if r.Temperature == 0 {
+		r.Temperature = config.Temperature
+	}
+	if r.MaxTokens == 0 {
+		r.MaxTokens = config.MaxTokens
+	}
+	if r.StopTokens == nil {
+		r.StopTokens = config.StopTokens
+	}
+	if r.ResponseFormat == "" {
+		r.ResponseFormat = config.ResponseFormat
+	}
+	if !r.ResponseFormat.Valid() {
+		return r, fmt.Errorf("executor: unknown response format %q", r.ResponseFormat)
+	}
+	return r, nil
+}
+
+// CacheKeyPrefix returns the prefix shared by the cache keys of a prompt version, for caches that
+// can invalidate by prefix.
+func CacheKeyPrefix(id, version string) string {
+	return "loom:" + id + "@" + version + ":"
 }
 
 // ExecuteResult is the result of executing a prompt.
@@ -82,50 +227,59 @@ type ExecuteResult struct {
 	Model     string
 	Rendered  *core.Rendered
 	Attempts  int
+	ToolCalls []provider.ToolCall
+	// TimeToFirstToken and TokensPerSecond are measured when the request streamed (see
+	// provider.StreamStats); they are zero otherwise.
+	TimeToFirstToken time.Duration
+	TokensPerSecond  float64
+	// Offline marks a render-only result from an offline executor: Content is the rendered user
+	// prompt, and no model was called.
+	Offline bool
+	// Output is Content decoded from JSON when the executor validated it against the prompt's
+	// output schema (see WithOutputValidation), and nil otherwise.
+	Output interface{}
+}
+
+// Unmarshal decodes the JSON content of the result into v, ignoring a surrounding markdown code
+// fence, e.g. into a struct matching the prompt's output schema.
+func (r *ExecuteResult) Unmarshal(v interface{}) error {
+	if err := json.Unmarshal([]byte(core.StripCodeFence(r.Content)), v); err != nil {
+		return fmt.Errorf("executor: decode output: %w", err)
+	}
+	return nil
 }
 
 // Execute renders the prompt and calls the provider, with retries on failure.
 func (e *Executor) Execute(ctx context.Context, req ExecuteRequest) (*ExecuteResult, error) {
-	if req.Prompt == nil {
-		return nil, fmt.Errorf("executor: prompt is required")
-	}
-	rendered, err := req.Prompt.Render(ctx, req.Input)
+	creq, rendered, err := e.request(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("executor render: %w", err)
-	}
-	timeout := req.Timeout
-	if timeout == 0 {
-		timeout = e.BaseTimeout
-	}
-	if timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, timeout)
-		defer cancel()
+		return nil, err
 	}
-	creq := provider.CompletionRequest{
-		Prompt:      rendered.User,
-		System:      rendered.System,
-		Model:       req.Model,
-		Temperature: req.Temperature,
-		MaxTokens:   req.MaxTokens,
-		StopTokens:  req.StopTokens,
-		Metadata:    req.Prompt.Metadata,
-	}
-	if creq.Model == "" {
-		creq.Model = "gpt-3.5-turbo"
+	if e.Offline {
+		return &ExecuteResult{Content: rendered.User, Model: creq.Model, Rendered: rendered, Offline: true}, nil
 	}
+	ctx, cancel := e.withTimeout(ctx, req)
+	defer cancel()
 	var lastErr error
 	attempts := 0
 	for attempt := 0; attempt <= e.MaxRetries; attempt++ {
 		attempts++
-		resp, err := e.Provider.Complete(ctx, creq)
+		resp, stats, err := e.complete(ctx, creq, req)
+		var output interface{}
+		if err == nil {
+			output, err = e.checkOutput(req.Prompt, resp)
+		}
 		if err == nil {
 			return &ExecuteResult{
-				Content:  resp.Content,
-				Usage:    resp.Usage,
-				Model:    resp.Model,
-				Rendered: rendered,
-				Attempts: attempts,
+				Content:          resp.Content,
+				Usage:            resp.Usage,
+				Model:            resp.Model,
+				Rendered:         rendered,
+				Attempts:         attempts,
+				ToolCalls:        resp.ToolCalls,
+				TimeToFirstToken: stats.TimeToFirstToken,
+				TokensPerSecond:  stats.TokensPerSecond(),
+				Output:           output,
 			}, nil
 		}
 		lastErr = err
@@ -138,3 +292,180 @@ func (e *Executor) Execute(ctx context.Context, req ExecuteRequest) (*ExecuteRes
 	}
 	return nil, fmt.Errorf("executor after %d attempts: %w", attempts, lastErr)
 }
+
+// checkOutput decodes resp's content and checks it against p's output schema, if the executor
+// validates output and p has one. Responses with tool calls are not checked.
+func (e *Executor) checkOutput(p *core.Prompt, resp *provider.CompletionResponse) (interface{}, error) {
+	if !e.ValidateOutput || p.OutputSchema == nil || len(resp.ToolCalls) > 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(core.StripCodeFence(resp.Content)), &v); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON: %w", ErrInvalidOutput, err)
+	}
+	if err := core.ValidateSchema(p.OutputSchema, v); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOutput, err)
+	}
+	return v, nil
+}
+
+// Stream renders the prompt and streams the provider's response. Unlike Execute it does not retry,
+// since the caller may already have used part of the response; the timeout, if any, covers the
+// whole stream. The channel is closed after the last chunk or when ctx is done. To measure the
+// stream's time to first token and throughput, wrap the channel with provider.TimeStream. An
+// offline executor streams the rendered user prompt as a single Done chunk.
+func (e *Executor) Stream(ctx context.Context, req ExecuteRequest) (<-chan provider.StreamChunk, *core.Rendered, error) {
+	creq, rendered, err := e.request(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.Offline {
+		out := make(chan provider.StreamChunk, 1)
+		out <- provider.StreamChunk{Content: rendered.User, Done: true}
+		close(out)
+		return out, rendered, nil
+	}
+	ctx, cancel := e.withTimeout(ctx, req)
+	ch, err := e.Provider.Stream(ctx, creq)
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("executor stream: %w", err)
+	}
+	out := make(chan provider.StreamChunk)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for chunk := range ch {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				go func() {
+					for range ch {
+					}
+				}()
+				return
+			}
+		}
+	}()
+	return out, rendered, nil
+}
+
+// request renders req's prompt and builds the provider request for it.
+func (e *Executor) request(ctx context.Context, req ExecuteRequest) (provider.CompletionRequest, *core.Rendered, error) {
+	if req.Prompt == nil {
+		return provider.CompletionRequest{}, nil, fmt.Errorf("executor: prompt is required")
+	}
+	req, err := req.withPromptDefaults()
+	if err != nil {
+		return provider.CompletionRequest{}, nil, err
+	}
+	req = req.withContextLocale(ctx)
+	rendered, err := req.Prompt.Render(ctx, req.Input)
+	if err != nil {
+		return provider.CompletionRequest{}, nil, fmt.Errorf("executor render: %w", err)
+	}
+	creq := provider.CompletionRequest{
+		Prompt:         rendered.User,
+		System:         rendered.System,
+		Model:          req.Model,
+		Temperature:    req.Temperature,
+		MaxTokens:      req.MaxTokens,
+		StopTokens:     req.StopTokens,
+		Tools:          toProviderTools(rendered.Tools),
+		Metadata:       req.Prompt.Metadata,
+		OutputSchema:   req.Prompt.OutputSchema,
+		ResponseFormat: provider.ResponseFormat(req.ResponseFormat),
+	}
+	if len(req.Prompt.Messages) > 0 || hasAttachments(rendered.Messages) {
+		creq.Messages = toProviderMessages(rendered.Messages)
+	}
+	if creq.Model == "" {
+		creq.Model = defaultModel
+	}
+	md := make(map[string]interface{}, len(creq.Metadata)+2)
+	for k, v := range creq.Metadata {
+		md[k] = v
+	}
+	if req.Prompt.ID != "" {
+		md[provider.PromptIDMetadata] = req.Prompt.ID
+	}
+	if key, err := req.CacheKey(); err == nil {
+		md[provider.CacheKeyMetadata] = key
+	}
+	md[provider.RedactedPromptMetadata] = rendered.RedactedUser()
+	creq.Metadata = md
+	return creq, rendered, nil
+}
+
+// withTimeout applies the request's timeout, or the executor's default.
+func (e *Executor) withTimeout(ctx context.Context, req ExecuteRequest) (context.Context, context.CancelFunc) {
+	timeout := req.Timeout
+	if timeout == 0 {
+		timeout = e.BaseTimeout
+	}
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// complete calls the provider, streaming and aggregating the response when req.Stream is set, in
+// which case it also returns the stream's stats.
+func (e *Executor) complete(ctx context.Context, creq provider.CompletionRequest, req ExecuteRequest) (*provider.CompletionResponse, provider.StreamStats, error) {
+	var stats provider.StreamStats
+	if !req.Stream {
+		resp, err := e.Provider.Complete(ctx, creq)
+		return resp, stats, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	start := time.Now()
+	ch, err := e.Provider.Stream(ctx, creq)
+	if err != nil {
+		return nil, stats, err
+	}
+	ch = provider.TimeStream(ch, start, func(s provider.StreamStats) { stats = s })
+	content, usage, err := provider.CollectStream(ctx, ch, req.ChunkTimeout)
+	if err != nil {
+		return nil, provider.StreamStats{}, fmt.Errorf("stream: %w", err)
+	}
+	resp := &provider.CompletionResponse{Content: content, Model: creq.Model}
+	if usage != nil {
+		resp.Usage = *usage
+	}
+	return resp, stats, nil
+}
+
+// toProviderTools converts the prompt's tool definitions for the provider request.
+func toProviderTools(tools []core.Tool) []provider.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]provider.Tool, len(tools))
+	for i, t := range tools {
+		out[i] = provider.Tool{Name: t.Name, Description: t.Description, Parameters: t.Parameters}
+	}
+	return out
+}
+
+// toProviderMessages maps a rendered conversation to provider messages.
+func toProviderMessages(msgs []core.Message) []provider.Message {
+	out := make([]provider.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = provider.Message{Role: m.Role, Content: m.Content}
+		for _, a := range m.Attachments {
+			out[i].Attachments = append(out[i].Attachments, provider.Attachment{MIMEType: a.MIMEType, URL: a.URL, Data: a.Data, Name: a.Name})
+		}
+	}
+	return out
+}
+
+// hasAttachments reports whether any of msgs has attachments, which only Messages can carry.
+func hasAttachments(msgs []core.Message) bool {
+	for _, m := range msgs {
+		if len(m.Attachments) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+// Package fewshot selects which of a prompt's stored examples are included when it is rendered:
+// all of them, a random k, the k most similar to the input (via an Embedder), or as many as fit a
+// token budget. Selection is configured per prompt with a Spec stored in the prompt's metadata,
+// so it travels with the prompt through the registry; the template engine applies it at render
+// time and exposes the chosen examples to templates as .examples, or writes them into the system
+// or user message with a Formatter (see FormatKey).
+package fewshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/cost"
+)
+
+// MetadataKey is the Prompt.Metadata key holding a prompt's Spec.
+const MetadataKey = "loom_examples"
+
+// Strategies accepted in Spec.Strategy.
+const (
+	StrategyAll        = "all"
+	StrategyTopK       = "top_k"
+	StrategyRandom     = "random"
+	StrategySimilarity = "similarity"
+	StrategyBudget     = "budget"
+)
+
+// Selector picks the examples to include for an input. It must not modify examples.
+type Selector interface {
+	Select(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error)
+}
+
+// SelectorFunc adapts a function to Selector.
+type SelectorFunc func(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error)
+
+// Select implements Selector.
+func (f SelectorFunc) Select(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error) {
+	return f(ctx, input, examples)
+}
+
+// Embedder produces a vector embedding for text; evaluator.OpenAIEmbedder implements it.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// Spec configures example selection for one prompt (see MetadataKey):
+//
+//	all         every example, in order (the default)
+//	top_k       the K examples with the highest Weight
+//	random      K examples sampled by Weight
+//	similarity  the K examples whose inputs are most similar to the input (needs an Embedder)
+//	budget      the highest-Weight examples that fit MaxTokens
+//
+// A MaxTokens limit also applies to the other strategies, dropping their lowest-ranked picks
+// until the rest fit.
+type Spec struct {
+	Strategy  string `json:"strategy,omitempty"`
+	K         int    `json:"k,omitempty"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
+}
+
+// Metadata returns s in the form stored under MetadataKey, matching what a registry returns
+// after a JSON round trip.
+func (s Spec) Metadata() map[string]interface{} {
+	m := map[string]interface{}{}
+	if s.Strategy != "" {
+		m["strategy"] = s.Strategy
+	}
+	if s.K != 0 {
+		m["k"] = float64(s.K)
+	}
+	if s.MaxTokens != 0 {
+		m["max_tokens"] = float64(s.MaxTokens)
+	}
+	return m
+}
+
+// SpecOf returns the Spec stored in p's metadata, or the zero Spec (all examples) if it has none.
+func SpecOf(p *core.Prompt) (Spec, error) {
+	var s Spec
+	v, ok := p.Metadata[MetadataKey]
+	if !ok || v == nil {
+		return s, nil
+	}
+	if spec, ok := v.(Spec); ok {
+		return spec, nil
+	}
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &s)
+	}
+	if err != nil {
+		return s, fmt.Errorf("fewshot: %s metadata: %w", MetadataKey, err)
+	}
+	return s, nil
+}
+
+// Selector returns the Selector described by s. emb is required for the similarity strategy;
+// cache, if not nil, keeps example embeddings between calls; counter counts tokens for MaxTokens
+// (default cost.SimpleCounter), and should be the one the prompt's renderer counts with.
+func (s Spec) Selector(emb Embedder, cache *EmbeddingCache, counter cost.TokenCounter) (Selector, error) {
+	var sel Selector
+	switch s.Strategy {
+	case "", StrategyAll:
+		sel = All{}
+	case StrategyTopK:
+		if s.K <= 0 {
+			return nil, fmt.Errorf("fewshot: top_k strategy requires k > 0")
+		}
+		sel = Chain(ByWeight{}, TopK{K: s.K})
+	case StrategyRandom:
+		if s.K <= 0 {
+			return nil, fmt.Errorf("fewshot: random strategy requires k > 0")
+		}
+		sel = &RandomK{K: s.K}
+	case StrategySimilarity:
+		if s.K <= 0 {
+			return nil, fmt.Errorf("fewshot: similarity strategy requires k > 0")
+		}
+		if emb == nil {
+			return nil, fmt.Errorf("fewshot: similarity strategy requires an Embedder")
+		}
+		sel = &Similarity{K: s.K, Embedder: emb, Cache: cache}
+	case StrategyBudget:
+		if s.MaxTokens <= 0 {
+			return nil, fmt.Errorf("fewshot: budget strategy requires max_tokens > 0")
+		}
+		return Chain(ByWeight{}, &TokenBudget{MaxTokens: s.MaxTokens, Counter: counter}), nil
+	default:
+		return nil, fmt.Errorf("fewshot: unknown strategy %q", s.Strategy)
+	}
+	if s.MaxTokens > 0 {
+		sel = Chain(sel, &TokenBudget{MaxTokens: s.MaxTokens, Counter: counter})
+	}
+	return sel, nil
+}
+
+// Chain applies selectors in order, each to the previous one's result.
+func Chain(selectors ...Selector) Selector {
+	return SelectorFunc(func(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error) {
+		var err error
+		for _, s := range selectors {
+			if examples, err = s.Select(ctx, input, examples); err != nil {
+				return nil, err
+			}
+		}
+		return examples, nil
+	})
+}
+
+// All selects every example.
+type All struct{}
+
+// Select implements Selector.
+func (All) Select(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error) {
+	return examples, nil
+}
+
+// ByWeight orders examples by descending Weight, keeping the original order among equal weights.
+type ByWeight struct{}
+
+// Select implements Selector.
+func (ByWeight) Select(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error) {
+	out := append([]core.Example(nil), examples...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Weight > out[j].Weight })
+	return out, nil
+}
+
+// TopK selects the first K examples; after ByWeight, the K with the highest Weight.
+type TopK struct {
+	K int
+}
+
+// Select implements Selector.
+func (t TopK) Select(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error) {
+	if t.K < len(examples) {
+		examples = examples[:t.K]
+	}
+	return examples, nil
+}
+
+// RandomK selects K examples at random without replacement, each draw weighted by Weight
+// (examples with Weight <= 0 count as 1). The picks keep their original order. Rand defaults to
+// a source seeded from the clock; set it for reproducible selections.
+type RandomK struct {
+	K    int
+	Rand *rand.Rand
+
+	mu sync.Mutex
+}
+
+// Select implements Selector.
+func (r *RandomK) Select(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error) {
+	if r.K >= len(examples) {
+		return examples, nil
+	}
+	weights := make([]float64, len(examples))
+	total := 0.0
+	for i, ex := range examples {
+		w := ex.Weight
+		if w <= 0 {
+			w = 1
+		}
+		weights[i] = w
+		total += w
+	}
+	r.mu.Lock()
+	if r.Rand == nil {
+		r.Rand = rand.New(rand.NewSource(rand.Int63()))
+	}
+	picked := make([]bool, len(examples))
+	for n := 0; n < r.K; n++ {
+		x := r.Rand.Float64() * total
+		for i, w := range weights {
+			if picked[i] {
+				continue
+			}
+			if x < w || i == len(weights)-1 {
+				picked[i] = true
+				total -= w
+				break
+			}
+			x -= w
+		}
+	}
+	r.mu.Unlock()
+	out := make([]core.Example, 0, r.K)
+	for i, ex := range examples {
+		if picked[i] {
+			out = append(out, ex)
+		}
+	}
+	return out, nil
+}
+
+// TokenBudget keeps examples in order while they fit in MaxTokens, skipping any that would not
+// fit. Tokens are counted over each example's input values and output with Counter (default
+// cost.SimpleCounter).
+type TokenBudget struct {
+	MaxTokens int
+	Counter   cost.TokenCounter
+}
+
+// Select implements Selector.
+func (b *TokenBudget) Select(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error) {
+	var counter cost.TokenCounter = cost.SimpleCounter{}
+	if b.Counter != nil {
+		counter = b.Counter
+	}
+	var out []core.Example
+	used := 0
+	for _, ex := range examples {
+		n := counter.CountTokens(Text(ex.Input)) + counter.CountTokens(ex.Output)
+		if used+n > b.MaxTokens {
+			continue
+		}
+		used += n
+		out = append(out, ex)
+	}
+	return out, nil
+}
+
+// Text is the text of an input used for similarity and token counting: "key: value" lines in key order.
+func Text(input map[string]interface{}) string {
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s: %v\n", k, input[k])
+	}
+	return sb.String()
+}
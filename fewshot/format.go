@@ -0,0 +1,72 @@
+package fewshot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// FormatKey is the Prompt.Metadata key holding where a prompt's selected examples are written
+// (one of the Placement constants). Without it, examples are only available to templates as
+// .examples.
+const FormatKey = "loom_example_format"
+
+// Placements accepted under FormatKey.
+const (
+	// PlacementSystem appends the examples to the system message.
+	PlacementSystem = "system"
+	// PlacementUser puts the examples before the rendered template in the user message. Prompts
+	// without a template get them in the system message instead.
+	PlacementUser = "user"
+)
+
+// Formatter writes selected examples as text for a prompt's system or user message.
+type Formatter interface {
+	FormatExamples(examples []core.Example) string
+}
+
+// FormatterFunc adapts a function to Formatter.
+type FormatterFunc func(examples []core.Example) string
+
+// FormatExamples implements Formatter.
+func (f FormatterFunc) FormatExamples(examples []core.Example) string {
+	return f(examples)
+}
+
+// TextFormatter is the default Formatter. It writes Header (default "Examples:") and then each
+// example as its input (see Text) followed by "Output: " and its output, separated by blank lines.
+type TextFormatter struct {
+	Header string
+}
+
+// FormatExamples implements Formatter.
+func (f TextFormatter) FormatExamples(examples []core.Example) string {
+	header := f.Header
+	if header == "" {
+		header = "Examples:"
+	}
+	var sb strings.Builder
+	sb.WriteString(header)
+	for _, ex := range examples {
+		sb.WriteString("\n\n")
+		sb.WriteString(Text(ex.Input))
+		sb.WriteString("Output: ")
+		sb.WriteString(ex.Output)
+	}
+	return sb.String()
+}
+
+// PlacementOf returns the placement stored in p's metadata, or "" if examples are not written
+// into its messages.
+func PlacementOf(p *core.Prompt) (string, error) {
+	v, ok := p.Metadata[FormatKey]
+	if !ok || v == nil {
+		return "", nil
+	}
+	switch placement, _ := v.(string); placement {
+	case PlacementSystem, PlacementUser:
+		return placement, nil
+	}
+	return "", fmt.Errorf("fewshot: %s metadata: unknown placement %v", FormatKey, v)
+}
@@ -0,0 +1,106 @@
+package fewshot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// Similarity selects the K examples whose inputs are most similar to the input by cosine
+// similarity of their embeddings (see Text), most similar first.
+type Similarity struct {
+	K        int
+	Embedder Embedder
+	// Cache keeps example embeddings between calls; nil embeds every example on every call.
+	Cache *EmbeddingCache
+}
+
+// Select implements Selector.
+func (s *Similarity) Select(ctx context.Context, input core.Input, examples []core.Example) ([]core.Example, error) {
+	if len(examples) == 0 {
+		return nil, nil
+	}
+	target, err := s.Embedder.Embed(ctx, Text(input))
+	if err != nil {
+		return nil, fmt.Errorf("fewshot: embed input: %w", err)
+	}
+	type scored struct {
+		ex    core.Example
+		score float64
+	}
+	ranked := make([]scored, len(examples))
+	for i, ex := range examples {
+		emb, err := s.Cache.embed(ctx, s.Embedder, Text(ex.Input))
+		if err != nil {
+			return nil, fmt.Errorf("fewshot: embed example %d: %w", i, err)
+		}
+		ranked[i] = scored{ex: ex, score: cosine(target, emb)}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	k := s.K
+	if k > len(ranked) {
+		k = len(ranked)
+	}
+	out := make([]core.Example, k)
+	for i := range out {
+		out[i] = ranked[i].ex
+	}
+	return out, nil
+}
+
+// EmbeddingCache memoizes embeddings by text. The zero value is not usable; use NewEmbeddingCache.
+// A nil *EmbeddingCache embeds without caching.
+type EmbeddingCache struct {
+	mu   sync.Mutex
+	max  int
+	embs map[string][]float32
+}
+
+// NewEmbeddingCache returns a cache holding up to max embeddings (0: unbounded); when full, new
+// texts are embedded but not cached.
+func NewEmbeddingCache(max int) *EmbeddingCache {
+	return &EmbeddingCache{max: max, embs: make(map[string][]float32)}
+}
+
+func (c *EmbeddingCache) embed(ctx context.Context, emb Embedder, text string) ([]float32, error) {
+	if c == nil {
+		return emb.Embed(ctx, text)
+	}
+	c.mu.Lock()
+	v, ok := c.embs[text]
+	c.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := emb.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.max == 0 || len(c.embs) < c.max {
+		c.embs[text] = v
+	}
+	c.mu.Unlock()
+	return v, nil
+}
+
+// cosine returns the cosine similarity of a and b (0 if their lengths differ or one is zero).
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,291 @@
+package loom
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/fewshot"
+	"github.com/klejdi94/loom/template"
+	"sigs.k8s.io/yaml"
+)
+
+// Document is the on-disk form of a prompt, read from YAML or JSON by LoadFile and LoadDir:
+//
+//	id: support-answer            # default: the file name without extension
+//	version: 1.2.0                # default: 1.0.0
+//	name: Support answer
+//	system: You are a support agent for {{.product}}.
+//	messages:
+//	  - {role: assistant, content: "How can I help?"}
+//	  - {history: history}
+//	template: "{{.question}}"
+//	variables:
+//	  - {name: product, default: Acme}
+//	  - {name: question, required: true, max_len: 2000}
+//	  - {name: tone, type: enum, enum: [formal, casual], default: formal}
+//	  - {name: user, type: object, fields: [{name: name, required: true}]}
+//	examples:
+//	  - input: {question: Where is my order?}
+//	    output: Could you share the order number?
+//	    weight: 2
+//	example_selection: {strategy: top_k, k: 3}
+//	model: {model: gpt-4o-mini, temperature: 0.2, max_tokens: 500}
+//	locales:
+//	  fr: {system: "Vous êtes un agent du support de {{.product}}."}
+//	metadata: {team: support}
+//
+// Templates are Go templates unless syntax is mustache (see core.SyntaxMustache), and use "{{" and
+// "}}" unless delims sets others, e.g. delims: {left: "[[", right: "]]"} for text with literal
+// braces. Unknown fields are errors, so typos do not go unnoticed.
+type Document struct {
+	ID               string                    `json:"id,omitempty"`
+	Version          string                    `json:"version,omitempty"`
+	Name             string                    `json:"name,omitempty"`
+	Description      string                    `json:"description,omitempty"`
+	Syntax           core.TemplateSyntax       `json:"syntax,omitempty"`
+	Delims           *core.Delims              `json:"delims,omitempty"`
+	System           string                    `json:"system,omitempty"`
+	Template         string                    `json:"template,omitempty"`
+	Messages         []DocumentMessage         `json:"messages,omitempty"`
+	Variables        []DocumentVariable        `json:"variables,omitempty"`
+	Examples         []DocumentExample         `json:"examples,omitempty"`
+	ExampleSelection *fewshot.Spec             `json:"example_selection,omitempty"`
+	ExampleFormat    string                    `json:"example_format,omitempty"`
+	Tools            []DocumentTool            `json:"tools,omitempty"`
+	OutputSchema     map[string]interface{}    `json:"output_schema,omitempty"`
+	Model            *core.ModelConfig         `json:"model,omitempty"`
+	TokenBudget      *core.TokenBudget         `json:"token_budget,omitempty"`
+	Dependencies     []core.Dependency         `json:"dependencies,omitempty"`
+	Locales          map[string]DocumentLocale `json:"locales,omitempty"`
+	Metadata         map[string]interface{}    `json:"metadata,omitempty"`
+}
+
+// DocumentMessage is a conversation turn of a Document (see core.Message).
+type DocumentMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+	History string `json:"history,omitempty"`
+}
+
+// DocumentLocale is a Document's variant in one language (see core.Localization).
+type DocumentLocale struct {
+	System   string            `json:"system,omitempty"`
+	Template string            `json:"template,omitempty"`
+	Messages []DocumentMessage `json:"messages,omitempty"`
+}
+
+// DocumentVariable is a variable of a Document (see core.Variable), or a field of an object
+// variable. Type defaults to string.
+type DocumentVariable struct {
+	Name        string             `json:"name"`
+	Type        core.VariableType  `json:"type,omitempty"`
+	Required    bool               `json:"required,omitempty"`
+	Default     interface{}        `json:"default,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Items       core.VariableType  `json:"items,omitempty"`
+	Fields      []DocumentVariable `json:"fields,omitempty"`
+	MinLen      int                `json:"min_len,omitempty"`
+	MaxLen      int                `json:"max_len,omitempty"`
+	Min         *float64           `json:"min,omitempty"`
+	Max         *float64           `json:"max,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Rules       []DocumentRule     `json:"rules,omitempty"`
+	Sensitive   bool               `json:"sensitive,omitempty"`
+}
+
+// DocumentRule is a validation rule of a DocumentVariable (see core.ValidationRule).
+type DocumentRule struct {
+	Rule    string   `json:"rule"`
+	Args    []string `json:"args,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+// DocumentExample is a few-shot example of a Document.
+type DocumentExample struct {
+	Input  map[string]interface{} `json:"input"`
+	Output string                 `json:"output"`
+	Weight float64                `json:"weight,omitempty"`
+}
+
+// DocumentTool is a tool definition of a Document; Parameters is a JSON Schema object.
+type DocumentTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// ParseDocument parses a YAML or JSON prompt document.
+func ParseDocument(data []byte) (*Document, error) {
+	var d Document
+	if err := yaml.UnmarshalStrict(data, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Builder returns a builder for the prompt d describes.
+func (d *Document) Builder() *Builder {
+	b := New(d.ID).WithName(d.Name).WithDescription(d.Description).WithSystem(d.System).WithTemplate(d.Template)
+	if d.Version != "" {
+		b.WithVersion(d.Version)
+	}
+	if d.Syntax != "" {
+		b.WithSyntax(d.Syntax)
+	}
+	if d.Delims != nil {
+		b.WithDelims(d.Delims.Left, d.Delims.Right)
+	}
+	b.messages = append(b.messages, documentMessages(d.Messages)...)
+	for _, v := range d.Variables {
+		b.variables = append(b.variables, v.variable())
+	}
+	for _, ex := range d.Examples {
+		b.WithExampleWeight(ex.Input, ex.Output, ex.Weight)
+	}
+	if d.ExampleSelection != nil {
+		b.WithExampleSelection(*d.ExampleSelection)
+	}
+	if d.ExampleFormat != "" {
+		b.WithExampleFormat(d.ExampleFormat)
+	}
+	for _, t := range d.Tools {
+		b.WithTool(t.Name, t.Description, t.Parameters)
+	}
+	if d.OutputSchema != nil {
+		b.WithOutputSchema(d.OutputSchema)
+	}
+	if d.Model != nil {
+		b.WithModelConfig(*d.Model)
+	}
+	if d.TokenBudget != nil {
+		b.WithTokenBudget(*d.TokenBudget)
+	}
+	if len(d.Dependencies) > 0 {
+		b.WithDependencies(d.Dependencies...)
+	}
+	for locale, l := range d.Locales {
+		b.WithLocale(locale, core.Localization{System: l.System, Template: l.Template, Messages: documentMessages(l.Messages)})
+	}
+	return b.WithMetadata(d.Metadata)
+}
+
+// documentMessages returns the core.Messages msgs describe.
+func documentMessages(msgs []DocumentMessage) []core.Message {
+	var out []core.Message
+	for _, m := range msgs {
+		out = append(out, core.Message{Role: m.Role, Content: m.Content, History: m.History})
+	}
+	return out
+}
+
+// variable returns the core.Variable v describes.
+func (v DocumentVariable) variable() core.Variable {
+	out := core.Variable{
+		Name:        v.Name,
+		Type:        v.Type,
+		Required:    v.Required,
+		Default:     v.Default,
+		Description: v.Description,
+		Enum:        v.Enum,
+		Items:       v.Items,
+		MinLen:      v.MinLen,
+		MaxLen:      v.MaxLen,
+		Min:         v.Min,
+		Max:         v.Max,
+		Pattern:     v.Pattern,
+		Sensitive:   v.Sensitive,
+	}
+	if out.Type == "" {
+		out.Type = core.VariableTypeString
+	}
+	// YAML and JSON numbers decode as float64; keep whole defaults of int variables ints.
+	if f, ok := out.Default.(float64); ok && out.Type == core.VariableTypeInt && f == math.Trunc(f) {
+		out.Default = int(f)
+	}
+	for _, r := range v.Rules {
+		out.Rules = append(out.Rules, core.ValidationRule{Rule: r.Rule, Args: r.Args, Message: r.Message})
+	}
+	for _, f := range v.Fields {
+		out.Fields = append(out.Fields, f.variable())
+	}
+	return out
+}
+
+// ReadFile reads the prompt document (YAML or JSON) at path and returns its prompt without
+// compiling it, e.g. for linting; it renders with DefaultEngine. Its ID defaults to the file name without extension.
+func ReadFile(path string) (*core.Prompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ParseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if d.ID == "" {
+		d.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return d.Builder().Build(nil), nil
+}
+
+// LoadFile reads the prompt document (YAML or JSON) at path and builds it with eng (DefaultEngine
+// if nil). Its templates are compiled, so syntax errors are reported here rather than at the first
+// render.
+func LoadFile(path string, eng *template.Engine) (*core.Prompt, error) {
+	p, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if eng == nil {
+		eng = defaultEngine
+	}
+	p.SetRenderer(eng)
+	if err := eng.Compile(p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// LoadDir loads every .yaml, .yml, and .json file under dir (recursively, in lexical order) with
+// LoadFile. It fails if two files define the same id and version.
+func LoadDir(dir string, eng *template.Engine) ([]*core.Prompt, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	prompts := make([]*core.Prompt, 0, len(paths))
+	seen := make(map[string]string, len(paths))
+	for _, path := range paths {
+		p, err := LoadFile(path, eng)
+		if err != nil {
+			return nil, err
+		}
+		key := p.ID + "@" + p.Version
+		if prev, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%s: %s is also defined in %s", path, key, prev)
+		}
+		seen[key] = path
+		prompts = append(prompts, p)
+	}
+	return prompts, nil
+}
@@ -0,0 +1,382 @@
+// Package lint statically checks prompts for common mistakes before they are stored or shipped:
+// templates that do not parse, template variables without a declaration, declared variables no
+// template uses, a missing system message, and suspiciously long templates. Findings are plain
+// values (with JSON tags) for the CLI and CI to report:
+//
+//	findings := lint.New(lint.Disable(lint.RuleMissingSystem)).Lint(prompt)
+//	if lint.HasErrors(findings) { ... }
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/cost"
+	"github.com/klejdi94/loom/template"
+)
+
+// Severity says whether a finding should fail a check (SeverityError) or only be reported.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Rules reported in Finding.Rule.
+const (
+	// RuleParseError: a system prompt, template, or message does not parse (error).
+	RuleParseError = "parse-error"
+	// RuleUndeclaredVariable: a template refers to a variable the prompt does not declare; it
+	// renders as "<no value>" unless the caller happens to pass it (error).
+	RuleUndeclaredVariable = "undeclared-variable"
+	// RuleUnusedVariable: a declared variable is not used by any template, message, or
+	// attachment (warning).
+	RuleUnusedVariable = "unused-variable"
+	// RuleMissingSystem: the prompt has no system prompt or system message (warning).
+	RuleMissingSystem = "missing-system"
+	// RuleLongTemplate: a system prompt, template, or message is longer than the maximum (warning).
+	RuleLongTemplate = "long-template"
+)
+
+// DefaultMaxTokens is the template length above which RuleLongTemplate reports a finding.
+const DefaultMaxTokens = 4000
+
+// Finding is a problem found in a prompt. Field is where: "system", "template", "messages[i]",
+// "variables[name]", or one of the first three in a locale variant, e.g. "locales[fr].template".
+type Finding struct {
+	ID       string   `json:"id,omitempty"`
+	Version  string   `json:"version,omitempty"`
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Field    string   `json:"field,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// String formats f as "id@version field: severity: message (rule)".
+func (f Finding) String() string {
+	where := f.ID
+	if f.Version != "" {
+		where += "@" + f.Version
+	}
+	if f.Field != "" {
+		if where != "" {
+			where += " "
+		}
+		where += f.Field
+	}
+	if where != "" {
+		where += ": "
+	}
+	return fmt.Sprintf("%s%s: %s (%s)", where, f.Severity, f.Message, f.Rule)
+}
+
+// HasErrors reports whether any of findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Linter checks prompts. The zero value is not usable; create one with New.
+type Linter struct {
+	maxTokens  int
+	counter    cost.TokenCounter
+	leftDelim  string
+	rightDelim string
+	syntax     core.TemplateSyntax
+	disabled   map[string]bool
+}
+
+// Option configures a Linter.
+type Option func(*Linter)
+
+// WithMaxTokens sets the length above which templates are reported as too long (default
+// DefaultMaxTokens).
+func WithMaxTokens(n int) Option {
+	return func(l *Linter) {
+		l.maxTokens = n
+	}
+}
+
+// WithTokenCounter sets the counter for template lengths (default cost.SimpleCounter).
+func WithTokenCounter(c cost.TokenCounter) Option {
+	return func(l *Linter) {
+		l.counter = c
+	}
+}
+
+// WithDelims sets the template delimiters of prompts without their own under core.DelimsKey,
+// matching template.WithDelims (default "{{" and "}}").
+func WithDelims(left, right string) Option {
+	return func(l *Linter) {
+		l.leftDelim = left
+		l.rightDelim = right
+	}
+}
+
+// WithSyntax sets the template syntax of prompts without one under core.SyntaxKey, matching
+// template.WithSyntax (default core.SyntaxGo).
+func WithSyntax(syntax core.TemplateSyntax) Option {
+	return func(l *Linter) {
+		l.syntax = syntax
+	}
+}
+
+// Disable turns off the given rules.
+func Disable(rules ...string) Option {
+	return func(l *Linter) {
+		for _, r := range rules {
+			l.disabled[r] = true
+		}
+	}
+}
+
+// New returns a linter with all rules enabled, configured by opts.
+func New(opts ...Option) *Linter {
+	l := &Linter{
+		maxTokens:  DefaultMaxTokens,
+		counter:    cost.SimpleCounter{},
+		leftDelim:  "{{",
+		rightDelim: "}}",
+		syntax:     core.SyntaxGo,
+		disabled:   make(map[string]bool),
+	}
+	for _, o := range opts {
+		o(l)
+	}
+	return l
+}
+
+// Lint checks p with a linter configured by opts.
+func Lint(p *core.Prompt, opts ...Option) []Finding {
+	return New(opts...).Lint(p)
+}
+
+// Lint checks p and returns its findings, ordered by field.
+func (l *Linter) Lint(p *core.Prompt) []Finding {
+	var findings []Finding
+	report := func(rule string, severity Severity, field, format string, args ...interface{}) {
+		if !l.disabled[rule] {
+			findings = append(findings, Finding{ID: p.ID, Version: p.Version, Rule: rule, Severity: severity, Field: field, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	type source struct{ field, text string }
+	sources := []source{{"system", p.System}, {"template", p.Template}}
+	hasSystem := p.System != ""
+	declared := make(map[string]bool, len(p.Variables)+1)
+	used := make(map[string]bool)
+	addMessages := func(prefix string, msgs []core.Message) {
+		for i, m := range msgs {
+			if m.History != "" {
+				declared[m.History], used[m.History] = true, true
+				continue
+			}
+			sources = append(sources, source{fmt.Sprintf("%smessages[%d]", prefix, i), m.Content})
+			hasSystem = hasSystem || m.Role == core.RoleSystem
+		}
+	}
+	addMessages("", p.Messages)
+	syntax, err := core.SyntaxOf(p)
+	if err != nil {
+		report(RuleParseError, SeverityError, "syntax", "%v", err)
+	}
+	if syntax == "" {
+		syntax = l.syntax
+	}
+	delims, err := core.DelimsOf(p)
+	if err != nil {
+		report(RuleParseError, SeverityError, "delims", "%v", err)
+	}
+	if delims.Left == "" {
+		delims = core.Delims{Left: l.leftDelim, Right: l.rightDelim}
+	}
+	// Locale variants are checked like the prompt's own text; they share its variables.
+	locales, err := core.LocalesOf(p)
+	if err != nil {
+		report(RuleParseError, SeverityError, "locales", "%v", err)
+	}
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		l, prefix := locales[tag], "locales["+tag+"]."
+		sources = append(sources, source{prefix + "system", l.System}, source{prefix + "template", l.Template})
+		addMessages(prefix, l.Messages)
+	}
+	for _, v := range p.Variables {
+		declared[v.Name] = true
+		if isAttachment(v) {
+			used[v.Name] = true
+		}
+	}
+	if len(p.Examples) > 0 {
+		declared["examples"] = true
+	}
+
+	for _, src := range sources {
+		if src.text == "" {
+			continue
+		}
+		if n := l.counter.CountTokens(src.text); n > l.maxTokens {
+			report(RuleLongTemplate, SeverityWarning, src.field, "%d tokens, more than %d", n, l.maxTokens)
+		}
+		names, nested, err := variables(syntax, delims, src.text)
+		if err != nil {
+			report(RuleParseError, SeverityError, src.field, "%v", err)
+			continue
+		}
+		for _, name := range nested {
+			used[name] = true
+		}
+		for _, name := range names {
+			used[name] = true
+			if !declared[name] {
+				report(RuleUndeclaredVariable, SeverityError, src.field, "variable %q is not declared", name)
+			}
+		}
+	}
+	for _, v := range p.Variables {
+		if !used[v.Name] {
+			report(RuleUnusedVariable, SeverityWarning, "variables["+v.Name+"]", "variable %q is declared but not used", v.Name)
+		}
+	}
+	if !hasSystem && p.Template != "" {
+		report(RuleMissingSystem, SeverityWarning, "system", "prompt has no system message")
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return fieldOrder(findings[i].Field) < fieldOrder(findings[j].Field) })
+	return findings
+}
+
+// fieldOrder sorts findings about the system prompt first, then messages, the template, locale
+// variants, and variables.
+func fieldOrder(field string) int {
+	switch {
+	case field == "system":
+		return 0
+	case field == "template":
+		return 2
+	case strings.HasPrefix(field, "locales"):
+		return 3
+	case strings.HasPrefix(field, "variables"):
+		return 4
+	}
+	return 1
+}
+
+// isAttachment reports whether v's values are sent as attachments rather than rendered.
+func isAttachment(v core.Variable) bool {
+	t := v.Type
+	if t == core.VariableTypeList {
+		t = v.Items
+	}
+	return t == core.VariableTypeImage || t == core.VariableTypeFile
+}
+
+// variables parses text with delims and returns the names of the input variables it refers to
+// (.name or $.name at the top level, or a name passed to isset or anyset), in order of first use.
+// For Mustache it also returns the names used inside sections, which may be input variables or
+// fields of the section's value.
+func variables(syntax core.TemplateSyntax, delims core.Delims, text string) (names, nested []string, err error) {
+	if syntax == core.SyntaxMustache {
+		return template.MustacheNames(text, delims.Left, delims.Right)
+	}
+	trees := make(map[string]*parse.Tree)
+	t := parse.New("")
+	t.Mode = parse.SkipFuncCheck
+	if _, err := t.Parse(text, delims.Left, delims.Right, trees); err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	keys := make([]string, 0, len(trees))
+	for k := range trees {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if trees[k].Root != nil {
+			walk(trees[k].Root, true, add)
+		}
+	}
+	return names, nil, nil
+}
+
+// walk reports the top-level variables referred to under n. root is false where dot has been
+// rebound by range or with, so .name there is a field of the element rather than an input.
+func walk(n parse.Node, root bool, add func(string)) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walk(c, root, add)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, root, add)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			walk(n.Pipe, root, add)
+		}
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, root, root, add)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, root, false, add)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, root, false, add)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			walk(c, root, add)
+		}
+	case *parse.CommandNode:
+		// isset and anyset name input variables wherever dot is (see template.NewEngine).
+		if fn, ok := n.Args[0].(*parse.IdentifierNode); ok && (fn.Ident == "isset" || fn.Ident == "anyset") {
+			for _, a := range n.Args[1:] {
+				if s, ok := a.(*parse.StringNode); ok {
+					add(s.Text)
+				}
+			}
+		}
+		for _, a := range n.Args {
+			walk(a, root, add)
+		}
+	case *parse.ChainNode:
+		walk(n.Node, root, add)
+	case *parse.FieldNode:
+		if root {
+			add(n.Ident[0])
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			add(n.Ident[1])
+		}
+	}
+}
+
+// walkBranch walks an if, range, or with: its pipeline with the enclosing dot, its body with
+// bodyRoot, and its else branch with the enclosing dot.
+func walkBranch(b *parse.BranchNode, root, bodyRoot bool, add func(string)) {
+	walk(b.Pipe, root, add)
+	walk(b.List, bodyRoot, add)
+	if b.ElseList != nil {
+		walk(b.ElseList, root, add)
+	}
+}
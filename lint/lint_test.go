@@ -0,0 +1,151 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+)
+
+func rules(findings []Finding) []string {
+	out := make([]string, len(findings))
+	for i, f := range findings {
+		out[i] = f.Rule + " " + f.Field
+	}
+	return out
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt *core.Prompt
+		opts   []Option
+		want   []string
+	}{
+		{
+			name: "clean",
+			prompt: &core.Prompt{
+				ID:        "p",
+				System:    "You help with {{.product}}.",
+				Template:  "{{range .items}}{{.name}}{{end}}{{if .q}}{{$.q}}{{end}}",
+				Variables: []core.Variable{{Name: "product"}, {Name: "items"}, {Name: "q"}, {Name: "img", Type: core.VariableTypeImage}},
+			},
+		},
+		{
+			name: "undeclared and unused",
+			prompt: &core.Prompt{
+				ID:        "p",
+				System:    "sys",
+				Template:  "{{.question}} {{with .ctx}}{{.inner}}{{else}}{{.fallback}}{{end}}",
+				Variables: []core.Variable{{Name: "question"}, {Name: "ctx"}, {Name: "extra"}},
+			},
+			want: []string{"undeclared-variable template", "unused-variable variables[extra]"},
+		},
+		{
+			name:   "parse error and missing system",
+			prompt: &core.Prompt{ID: "p", Template: "{{.a"},
+			want:   []string{"missing-system system", "parse-error template"},
+		},
+		{
+			name: "messages, history, and examples",
+			prompt: &core.Prompt{
+				ID: "p",
+				Messages: []core.Message{
+					{Role: core.RoleSystem, Content: "{{range .examples}}{{.Output}}{{end}}"},
+					{History: "history"},
+					{Role: core.RoleUser, Content: "{{.missing}}"},
+				},
+				Examples: []core.Example{{Output: "x"}},
+			},
+			want: []string{"undeclared-variable messages[2]"},
+		},
+		{
+			name: "locales",
+			prompt: &core.Prompt{
+				ID:        "p",
+				System:    "sys",
+				Template:  "{{.q}}",
+				Variables: []core.Variable{{Name: "q"}},
+				Metadata: map[string]interface{}{
+					core.LocalesKey: core.Locales{"fr": {Template: "{{.q}} {{.r}}"}, "de": {System: "{{"}}.Metadata(),
+				},
+			},
+			want: []string{"parse-error locales[de].system", "undeclared-variable locales[fr].template"},
+		},
+		{
+			name: "isset",
+			prompt: &core.Prompt{
+				ID:        "p",
+				System:    "sys",
+				Template:  `{{range .items}}{{if isset "context"}}x{{end}}{{end}}{{if anyset "email" "phnoe"}}y{{end}}`,
+				Variables: []core.Variable{{Name: "items"}, {Name: "context"}, {Name: "email"}, {Name: "phone"}},
+			},
+			want: []string{"undeclared-variable template", "unused-variable variables[phone]"},
+		},
+		{
+			name:   "long template",
+			prompt: &core.Prompt{ID: "p", System: "s", Template: strings.Repeat("word ", 20)},
+			opts:   []Option{WithMaxTokens(10)},
+			want:   []string{"long-template template"},
+		},
+		{
+			name: "mustache",
+			prompt: &core.Prompt{
+				ID:        "p",
+				System:    "You help with {{#open}}",
+				Template:  "{{#items}}{{name}} {{product}}{{/items}} {{question}}",
+				Variables: []core.Variable{{Name: "product"}, {Name: "items"}, {Name: "unused"}},
+			},
+			opts: []Option{WithSyntax(core.SyntaxMustache)},
+			want: []string{"parse-error system", "undeclared-variable template", "unused-variable variables[unused]"},
+		},
+		{
+			name: "mustache from metadata",
+			prompt: &core.Prompt{
+				ID:       "p",
+				System:   "s",
+				Template: "{{#items}}{{name}}{{/items}} {{question}}",
+				Metadata: map[string]interface{}{core.SyntaxKey: "mustache"},
+			},
+			want: []string{"undeclared-variable template", "undeclared-variable template"},
+		},
+		{
+			name:   "disabled and delims",
+			prompt: &core.Prompt{ID: "p", Template: "<<.a>>"},
+			opts:   []Option{Disable(RuleMissingSystem), WithDelims("<<", ">>")},
+			want:   []string{"undeclared-variable template"},
+		},
+		{
+			name: "delims from metadata",
+			prompt: &core.Prompt{
+				ID:       "p",
+				System:   "Reply with {{literal}} braces.",
+				Template: "[[.a]] [[#b]]",
+				Metadata: map[string]interface{}{core.DelimsKey: core.Delims{Left: "[[", Right: "]]"}.Metadata()},
+			},
+			want: []string{"parse-error template"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(Lint(tt.prompt, tt.opts...))
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinding(t *testing.T) {
+	f := Finding{ID: "p", Version: "1.0.0", Rule: RuleUnusedVariable, Severity: SeverityWarning, Field: "variables[x]", Message: "unused"}
+	if got, want := f.String(), "p@1.0.0 variables[x]: warning: unused (unused-variable)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if HasErrors([]Finding{f}) {
+		t.Error("HasErrors with only warnings")
+	}
+	f.Severity = SeverityError
+	if !HasErrors([]Finding{f}) {
+		t.Error("HasErrors with an error")
+	}
+}
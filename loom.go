@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/fewshot"
 	"github.com/klejdi94/loom/template"
 )
 
@@ -42,9 +43,12 @@ type Builder struct {
 	description string
 	system      string
 	tpl         string
+	messages    []core.Message
 	variables   []core.Variable
 	examples    []core.Example
+	tools       []core.Tool
 	metadata    map[string]interface{}
+	outputSchema map[string]interface{}
 }
 
 // New starts a new prompt builder with the given id.
@@ -58,6 +62,32 @@ func New(id string) *Builder {
 	}
 }
 
+// ToBuilder returns a builder holding everything in p, so a stored prompt can be changed and built
+// again without copying its fields by hand:
+//
+//	p, _ := reg.Get(ctx, "summarize", "1.2.0")
+//	next := loom.ToBuilder(p).WithVersion("1.3.0").WithSystem("Be brief.").Build(nil)
+//
+// p itself is not modified. The built prompt gets new timestamps and no Revision, as for a new
+// version; to pick the next version automatically, store it with registry.StoreNext.
+func ToBuilder(p *core.Prompt) *Builder {
+	q := p.Copy()
+	return &Builder{
+		id:           q.ID,
+		version:      q.Version,
+		name:         q.Name,
+		description:  q.Description,
+		system:       q.System,
+		tpl:          q.Template,
+		messages:     q.Messages,
+		variables:    q.Variables,
+		examples:     q.Examples,
+		tools:        q.Tools,
+		metadata:     q.Metadata,
+		outputSchema: q.OutputSchema,
+	}
+}
+
 // WithVersion sets the prompt version (semantic versioning).
 func (b *Builder) WithVersion(v string) *Builder {
 	b.version = v
@@ -88,9 +118,32 @@ func (b *Builder) WithTemplate(tpl string) *Builder {
 	return b
 }
 
+// WithMessage adds a conversation turn (RoleUser, RoleAssistant, or RoleSystem)
+// whose content is a template. Messages are sent in order between the system message and the
+// template, e.g. an assistant turn showing the expected answer style.
+func (b *Builder) WithMessage(role, content string) *Builder {
+	b.messages = append(b.messages, core.Message{Role: role, Content: content})
+	return b
+}
+
+// WithHistory adds a placeholder for earlier conversation turns, taken at render time from the
+// input variable name (a []core.Message, or a list of {"role", "content"} objects).
+func (b *Builder) WithHistory(name string) *Builder {
+	b.messages = append(b.messages, core.Message{History: name})
+	return b
+}
+
 // WithVariable adds a variable definition. Use core.String(), core.Int(), etc. with options.
+// A variable already defined under name is replaced in place, e.g. to change one variable of a
+// prompt from ToBuilder.
 func (b *Builder) WithVariable(name string, v core.Variable) *Builder {
 	v.Name = name
+	for i := range b.variables {
+		if b.variables[i].Name == name {
+			b.variables[i] = v
+			return b
+		}
+	}
 	b.variables = append(b.variables, v)
 	return b
 }
@@ -107,6 +160,100 @@ func (b *Builder) WithExampleWeight(input map[string]interface{}, output string,
 	return b
 }
 
+// WithExampleSelection stores how examples are chosen at render time (see fewshot.Spec), e.g.
+// fewshot.Spec{Strategy: fewshot.StrategySimilarity, K: 3, MaxTokens: 500}.
+func (b *Builder) WithExampleSelection(spec fewshot.Spec) *Builder {
+	b.metadata[fewshot.MetadataKey] = spec.Metadata()
+	return b
+}
+
+// WithExampleFormat writes the selected examples into the rendered system or user message
+// (fewshot.PlacementSystem or fewshot.PlacementUser) instead of leaving them to the template; see
+// template.WithExampleFormatter to change their layout.
+func (b *Builder) WithExampleFormat(placement string) *Builder {
+	b.metadata[fewshot.FormatKey] = placement
+	return b
+}
+
+// WithModelConfig sets the prompt's default model and generation parameters (see
+// core.ModelConfig), which the executor uses unless a request sets them.
+func (b *Builder) WithModelConfig(config core.ModelConfig) *Builder {
+	b.metadata[core.ModelConfigKey] = config.Metadata()
+	return b
+}
+
+// WithSyntax sets the prompt's template syntax, e.g. core.SyntaxMustache for logic-less templates
+// that people who should not write template logic can edit (default: the engine's, see
+// template.WithSyntax).
+func (b *Builder) WithSyntax(syntax core.TemplateSyntax) *Builder {
+	b.metadata[core.SyntaxKey] = string(syntax)
+	return b
+}
+
+// WithDelims sets the delimiters of the prompt's templates (see core.Delims), for text that
+// contains a literal "{{" or "}}", e.g. WithDelims("[[", "]]") and then "[[.name]]" (default: the
+// engine's, see template.WithDelims).
+func (b *Builder) WithDelims(left, right string) *Builder {
+	b.metadata[core.DelimsKey] = core.Delims{Left: left, Right: right}.Metadata()
+	return b
+}
+
+// WithResponseFormat sets the kind of output the prompt asks for (see core.ResponseFormat), e.g.
+// core.ResponseFormatJSONObject for JSON without a schema, keeping the rest of its model config.
+func (b *Builder) WithResponseFormat(f core.ResponseFormat) *Builder {
+	config, _ := core.ModelConfigOf(&core.Prompt{Metadata: b.metadata})
+	config.ResponseFormat = f
+	b.metadata[core.ModelConfigKey] = config.Metadata()
+	return b
+}
+
+// WithTokenBudget limits the tokens of the rendered prompt (see core.TokenBudget), e.g.
+// core.TokenBudget{MaxPromptTokens: 4000, DropExamples: true, Truncate: "context"}.
+func (b *Builder) WithTokenBudget(budget core.TokenBudget) *Builder {
+	b.metadata[core.TokenBudgetKey] = budget.Metadata()
+	return b
+}
+
+// WithLocale adds the prompt's variant for locale (e.g. "fr" or "pt-BR"; see core.Locales), which
+// Render uses when the input's "locale" or the context's locale (core.WithLocale) selects it.
+func (b *Builder) WithLocale(locale string, l core.Localization) *Builder {
+	locales, _ := core.LocalesOf(&core.Prompt{Metadata: b.metadata})
+	if locales == nil {
+		locales = core.Locales{}
+	}
+	locales[locale] = l
+	b.metadata[core.LocalesKey] = locales.Metadata()
+	return b
+}
+
+// WithDeprecation marks the prompt deprecated (see core.Deprecation), e.g. when storing a last
+// version that points to its replacement; to deprecate a stored version use registry.Deprecate.
+func (b *Builder) WithDeprecation(d core.Deprecation) *Builder {
+	b.metadata[core.DeprecationKey] = d.Metadata()
+	return b
+}
+
+// WithDependencies declares prompts in the registry whose templates this prompt uses as named
+// templates (see core.Dependency); resolve it with registry.Resolver before rendering.
+func (b *Builder) WithDependencies(deps ...core.Dependency) *Builder {
+	b.metadata[core.DependenciesKey] = core.DependencyMetadata(deps)
+	return b
+}
+
+// WithTool attaches a tool definition; params is the JSON Schema for the tool's arguments.
+func (b *Builder) WithTool(name, description string, params map[string]interface{}) *Builder {
+	b.tools = append(b.tools, core.Tool{Name: name, Description: description, Parameters: params})
+	return b
+}
+
+// WithOutputSchema declares the JSON Schema the model's output must match. It is versioned with the
+// prompt, sent to providers with a JSON mode, and checked by executors with output validation
+// (see executor.WithOutputValidation).
+func (b *Builder) WithOutputSchema(schema map[string]interface{}) *Builder {
+	b.outputSchema = schema
+	return b
+}
+
 // WithMetadata sets or merges metadata key-value pairs.
 func (b *Builder) WithMetadata(m map[string]interface{}) *Builder {
 	for k, v := range m {
@@ -129,11 +276,14 @@ func (b *Builder) Build(eng *template.Engine) *core.Prompt {
 		Description: b.description,
 		System:      b.system,
 		Template:    b.tpl,
+		Messages:    append([]core.Message(nil), b.messages...),
 		Variables:   append([]core.Variable(nil), b.variables...),
 		Examples:    append([]core.Example(nil), b.examples...),
+		Tools:       append([]core.Tool(nil), b.tools...),
 		Metadata:    make(map[string]interface{}),
 		CreatedAt:   now,
 		UpdatedAt:   now,
+		OutputSchema: b.outputSchema,
 	}
 	for k, v := range b.metadata {
 		p.Metadata[k] = v
@@ -152,6 +302,19 @@ type (
 	Variable = core.Variable
 	// Example is a few-shot example.
 	Example = core.Example
+	// Message is a conversation turn (see Builder.WithMessage).
+	Message = core.Message
+	// Attachment is an image or file sent with a message.
+	Attachment = core.Attachment
+	// Dependency refers to another prompt in the registry (see Builder.WithDependencies).
+	Dependency = core.Dependency
+)
+
+// Message roles (re-export from core).
+const (
+	RoleSystem    = core.RoleSystem
+	RoleUser      = core.RoleUser
+	RoleAssistant = core.RoleAssistant
 )
 
 // Variable constructors (re-export from core).
@@ -165,4 +328,20 @@ var (
 	Default        = core.Default
 	WithValidation = core.WithValidation
 	WithDescription = core.WithDescription
+	List           = core.List
+	Object         = core.Object
+	Enum           = core.Enum
+	Timestamp      = core.Timestamp
+	WithItems      = core.WithItems
+	WithField      = core.WithField
+	Image          = core.Image
+	File           = core.File
+	MinLen         = core.MinLen
+	MaxLen         = core.MaxLen
+	Min            = core.Min
+	Max            = core.Max
+	Pattern        = core.Pattern
+	OneOf          = core.OneOf
+	WithRule       = core.WithRule
+	Sensitive      = core.Sensitive
 )
@@ -0,0 +1,37 @@
+package loom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToBuilder_OverrideVariable(t *testing.T) {
+	p := New("summarize").
+		WithVersion("1.2.0").
+		WithSystem("Summarize.").
+		WithTemplate("{{.text}} in {{.words}} words").
+		WithVariable("text", String(Required())).
+		WithVariable("words", Int(Default(50))).
+		WithExample(map[string]interface{}{"text": "a"}, "b").
+		Build(nil)
+
+	same := ToBuilder(p).Build(nil)
+	assert.Equal(t, p.Variables, same.Variables)
+	assert.Equal(t, p.Examples, same.Examples)
+	assert.Equal(t, p.System, same.System)
+	assert.Equal(t, p.Template, same.Template)
+
+	next := ToBuilder(p).WithVersion("1.3.0").WithVariable("words", Int(Default(20))).Build(nil)
+	require.Len(t, next.Variables, 2, "overriding a variable replaces it")
+	assert.Equal(t, "text", next.Variables[0].Name)
+	assert.Equal(t, "words", next.Variables[1].Name)
+	assert.Equal(t, 20, next.Variables[1].Default)
+	assert.Equal(t, 50, p.Variables[1].Default, "the source prompt is not modified")
+
+	r, err := next.Render(context.Background(), Input{"text": "Go"})
+	require.NoError(t, err)
+	assert.Equal(t, "Go in 20 words", r.User)
+}
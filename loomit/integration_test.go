@@ -0,0 +1,184 @@
+//go:build integration
+
+package loomit_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom"
+	"github.com/klejdi94/loom/analytics"
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/evaluator"
+	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/loomit"
+	"github.com/klejdi94/loom/middleware"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistries(t *testing.T) {
+	backends := map[string]func(t *testing.T) registry.Registry{
+		"postgres": func(t *testing.T) registry.Registry {
+			reg, err := registry.NewPostgresRegistry(loomit.Postgres(t), loomit.Name(t, "prompts"), true)
+			require.NoError(t, err)
+			return reg
+		},
+		"redis": func(t *testing.T) registry.Registry {
+			return registry.NewRedisRegistry(loomit.Redis(t), loomit.Name(t, "loom")+":")
+		},
+		"s3": func(t *testing.T) registry.Registry {
+			return registry.NewS3Registry(loomit.S3(t), "prompts/")
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			exerciseRegistry(t, open(t))
+			registrytest.Run(t, open)
+		})
+	}
+}
+
+// exerciseRegistry runs a prompt through its lifecycle on reg.
+func exerciseRegistry(t *testing.T, reg registry.Registry) {
+	ctx := registry.WithActor(context.Background(), "loomit")
+	p := &core.Prompt{ID: "greet", Version: "1.0.0", Template: "Hi {{.name}}",
+		Variables: []core.Variable{{Name: "name", Type: core.VariableTypeString, Required: true}}}
+	require.NoError(t, reg.Store(ctx, p))
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "greet", Version: "1.1.0", Template: "Hello {{.name}}"}))
+	require.NoError(t, reg.Promote(ctx, "greet", "1.0.0", registry.StageProduction))
+	require.NoError(t, reg.Tag(ctx, "greet", "1.0.0", []string{"approved"}))
+
+	prod, err := reg.GetProduction(ctx, "greet")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", prod.Version)
+	assert.Equal(t, p.Variables, prod.Variables)
+	latest, err := registry.GetLatest(ctx, reg, "greet")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", latest.Version)
+	require.NoError(t, registry.Verify(ctx, reg, "greet", "1.0.0"))
+
+	prod.Template = "Hi there {{.name}}"
+	require.NoError(t, registry.StoreIfMatch(ctx, reg, prod, prod.Revision))
+	prod.Template = "stale"
+	assert.ErrorIs(t, registry.StoreIfMatch(ctx, reg, prod, 1), core.ErrConflict)
+
+	require.NoError(t, reg.Archive(ctx, "greet", "1.1.0"))
+	latest, err = registry.GetLatest(ctx, reg, "greet")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", latest.Version)
+	require.NoError(t, reg.Restore(ctx, "greet", "1.1.0"))
+
+	infos, err := reg.ListVersions(ctx, "greet")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, registry.StageProduction, infos[0].Stage)
+	assert.Equal(t, []string{"approved"}, infos[0].Tags)
+
+	if _, ok := reg.(registry.Auditor); ok {
+		entries, err := registry.History(ctx, reg, "greet")
+		require.NoError(t, err)
+		require.NotEmpty(t, entries)
+		assert.Equal(t, "loomit", entries[0].Actor)
+	}
+	require.NoError(t, reg.Delete(ctx, "greet", "1.1.0"))
+	_, err = reg.Get(ctx, "greet", "1.1.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+}
+
+func TestAnalyticsStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) analytics.Store{
+		"postgres": func(t *testing.T) analytics.Store {
+			s, err := analytics.NewPostgresStore(loomit.Postgres(t), loomit.Name(t, "runs"))
+			require.NoError(t, err)
+			return s
+		},
+		"redis": func(t *testing.T) analytics.Store {
+			return analytics.NewRedisStore(loomit.Redis(t), loomit.Name(t, "runs"))
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			s := open(t)
+			id := loomit.Name(t, "prompt")
+			now := time.Now()
+			for i, v := range []string{"1.0.0", "1.0.0", "1.1.0"} {
+				require.NoError(t, s.Record(ctx, analytics.RunRecord{PromptID: id, Version: v, LatencyMs: int64(100 * (i + 1)),
+					InputTokens: 10, OutputTokens: 5, Success: i != 1, At: now}))
+			}
+			aggs, err := s.Query(ctx, analytics.Query{PromptID: id, GroupBy: "version", From: now.Add(-time.Minute), To: now.Add(time.Minute)})
+			require.NoError(t, err)
+			byVersion := map[string]analytics.Aggregate{}
+			for _, a := range aggs {
+				byVersion[a.Key] = a
+			}
+			assert.Equal(t, int64(2), byVersion[id+"@1.0.0"].Runs)
+			assert.Equal(t, int64(1), byVersion[id+"@1.0.0"].SuccessCount)
+			assert.Equal(t, int64(20), byVersion[id+"@1.0.0"].TotalInputTokens)
+			assert.Equal(t, int64(1), byVersion[id+"@1.1.0"].Runs)
+		})
+	}
+}
+
+// TestFullStack stores a prompt in Postgres, runs it on Ollama through the middleware chain,
+// records the runs in Redis analytics, and evaluates it, as examples/fullstack does.
+func TestFullStack(t *testing.T) {
+	ctx := context.Background()
+	reg, err := registry.NewPostgresRegistry(loomit.Postgres(t), loomit.Name(t, "prompts"), true)
+	require.NoError(t, err)
+	store := analytics.NewRedisStore(loomit.Redis(t), loomit.Name(t, "runs"))
+	llm, model := loomit.Ollama(t)
+
+	eng := loom.DefaultEngine()
+	p := loom.New("capital").
+		WithVersion("1.0.0").
+		WithSystem("Answer with the city name only.").
+		WithTemplate("What is the capital of {{.country}}?").
+		WithVariable("country", loom.String(loom.Required())).
+		Build(eng)
+	require.NoError(t, reg.Store(ctx, p))
+	require.NoError(t, reg.Promote(ctx, "capital", "1.0.0", registry.StageProduction))
+	prod, err := reg.GetProduction(ctx, "capital")
+	require.NoError(t, err)
+	prod.SetRenderer(eng)
+
+	metrics, counters := middleware.Metrics()
+	chained := middleware.Chain(llm, middleware.CacheMiddleware(middleware.NewInMemoryCache(), time.Hour), metrics)
+	exec := executor.New(chained, executor.WithRetry(2, executor.ExponentialBackoff(time.Second, 5*time.Second)),
+		executor.WithTimeout(2*time.Minute))
+
+	req := executor.ExecuteRequest{Prompt: prod, Input: loom.Input{"country": "France"}, Model: model, MaxTokens: 20}
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		res, err := exec.Execute(ctx, req)
+		require.NoError(t, err)
+		assert.Contains(t, strings.ToLower(res.Content), "paris")
+		require.NoError(t, store.Record(ctx, analytics.RunRecord{PromptID: prod.ID, Version: prod.Version,
+			LatencyMs: time.Since(start).Milliseconds(), InputTokens: res.Usage.PromptTokens,
+			OutputTokens: res.Usage.CompletionTokens, Success: true, At: time.Now()}))
+	}
+	assert.Equal(t, uint64(1), counters.Requests(), "the second call is served from the cache")
+
+	aggs, err := store.Query(ctx, analytics.Query{PromptID: prod.ID, GroupBy: "version"})
+	require.NoError(t, err)
+	require.Len(t, aggs, 1)
+	assert.Equal(t, int64(2), aggs[0].Runs)
+
+	report, err := evaluator.NewTestSuite("capitals").
+		WithPrompt(prod, prod.Version).
+		WithExecutor(exec).
+		WithModel(model).
+		AddCase("france", map[string]interface{}{"country": "France"}, evaluator.Expected{Contains: []string{"Paris"}}).
+		AddCase("japan", map[string]interface{}{"country": "Japan"}, evaluator.Expected{Contains: []string{"Tokyo"}}).
+		WithEvaluator(evaluator.ContainsAll{}).
+		Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, report.Total, report.Passed, "%+v", report.Results)
+}
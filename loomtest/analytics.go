@@ -0,0 +1,64 @@
+package loomtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/klejdi94/loom/analytics"
+)
+
+// AnalyticsStore is an analytics.Store that keeps every record for inspection and aggregates
+// them like analytics.MemoryStore. SetError makes Record and Query fail.
+type AnalyticsStore struct {
+	mem *analytics.MemoryStore
+
+	mu      sync.Mutex
+	records []analytics.RunRecord
+	err     error
+}
+
+// NewAnalyticsStore returns an empty AnalyticsStore.
+func NewAnalyticsStore() *AnalyticsStore {
+	return &AnalyticsStore{mem: analytics.NewMemoryStore(0)}
+}
+
+// SetError makes Record and Query return err (nil: succeed). Failed records are not kept.
+func (s *AnalyticsStore) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Records returns the recorded runs, oldest first.
+func (s *AnalyticsStore) Records() []analytics.RunRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]analytics.RunRecord(nil), s.records...)
+}
+
+// Record implements analytics.Store.
+func (s *AnalyticsStore) Record(ctx context.Context, r analytics.RunRecord) error {
+	s.mu.Lock()
+	err := s.err
+	if err == nil {
+		s.records = append(s.records, r)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.mem.Record(ctx, r)
+}
+
+// Query implements analytics.Store.
+func (s *AnalyticsStore) Query(ctx context.Context, q analytics.Query) ([]analytics.Aggregate, error) {
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.mem.Query(ctx, q)
+}
+
+var _ analytics.Store = (*AnalyticsStore)(nil)
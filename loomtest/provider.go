@@ -0,0 +1,251 @@
+// Package loomtest provides deterministic fakes for unit-testing code built on loom: a scripted
+// Provider with canned responses per prompt pattern and programmable latencies and failures, a
+// Registry with injectable errors, and an analytics Store that keeps every record.
+//
+//	p := loomtest.NewProvider().
+//		On(`(?i)sentiment`, loomtest.Reply{Content: "positive"}).
+//		On(`summar`, loomtest.Reply{Err: errors.New("overloaded")}, loomtest.Reply{Content: "short", Latency: 50 * time.Millisecond})
+//	exec := executor.New(p)
+//	// ... run the code under test, then inspect p.Calls()
+package loomtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klejdi94/loom/cost"
+	"github.com/klejdi94/loom/provider"
+)
+
+// ErrUnscripted is returned by Provider for requests that match no rule and have no default reply.
+var ErrUnscripted = errors.New("loomtest: no scripted reply")
+
+// Reply is one scripted provider response. If Err is set the call fails with it (after Latency);
+// otherwise it returns Content. Usage defaults to token counts estimated from the prompt and
+// Content, and Model to the request's model.
+type Reply struct {
+	Content      string
+	ToolCalls    []provider.ToolCall
+	FinishReason string
+	Usage        provider.TokenUsage
+	Model        string
+	Latency      time.Duration
+	Err          error
+}
+
+type rule struct {
+	pattern *regexp.Regexp
+	replies []Reply
+	next    int
+}
+
+// reply returns the rule's next reply; the last one repeats once the others are used up.
+func (r *rule) reply() Reply {
+	rep := r.replies[r.next]
+	if r.next < len(r.replies)-1 {
+		r.next++
+	}
+	return rep
+}
+
+// Provider is a scripted provider.Provider. Requests are matched against rules in the order they
+// were added by On; each rule returns its replies in turn and then repeats the last one. It is
+// safe for concurrent use, but with concurrent calls the order in which a rule's replies are
+// handed out follows the order the calls arrive.
+type Provider struct {
+	mu        sync.Mutex
+	rules     []*rule
+	fallback  *rule
+	calls     []provider.CompletionRequest
+	healthErr error
+}
+
+// NewProvider returns a Provider with no rules; until some are added every call fails with ErrUnscripted.
+func NewProvider() *Provider {
+	return &Provider{}
+}
+
+// On adds a rule answering requests whose rendered prompt (CompletionRequest.Prompt) matches the
+// regular expression pattern. It panics if pattern does not compile or no replies are given.
+func (p *Provider) On(pattern string, replies ...Reply) *Provider {
+	if len(replies) == 0 {
+		panic("loomtest: On requires at least one reply")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = append(p.rules, &rule{pattern: regexp.MustCompile(pattern), replies: replies})
+	return p
+}
+
+// Default sets the replies for requests that match no rule.
+func (p *Provider) Default(replies ...Reply) *Provider {
+	if len(replies) == 0 {
+		panic("loomtest: Default requires at least one reply")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fallback = &rule{replies: replies}
+	return p
+}
+
+// SetHealth sets the error HealthCheck returns (nil: healthy).
+func (p *Provider) SetHealth(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.healthErr = err
+}
+
+// Calls returns the requests received by Complete and Stream, oldest first.
+func (p *Provider) Calls() []provider.CompletionRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]provider.CompletionRequest(nil), p.calls...)
+}
+
+// Reset forgets recorded calls and restarts every rule at its first reply.
+func (p *Provider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = nil
+	for _, r := range p.rules {
+		r.next = 0
+	}
+	if p.fallback != nil {
+		p.fallback.next = 0
+	}
+}
+
+// take records req and returns its scripted reply.
+func (p *Provider) take(req provider.CompletionRequest) (Reply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, req)
+	for _, r := range p.rules {
+		if r.pattern.MatchString(req.Prompt) {
+			return r.reply(), nil
+		}
+	}
+	if p.fallback != nil {
+		return p.fallback.reply(), nil
+	}
+	return Reply{}, fmt.Errorf("%w for prompt %q", ErrUnscripted, req.Prompt)
+}
+
+// wait sleeps for d or until ctx is done.
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (r Reply) response(req provider.CompletionRequest) *provider.CompletionResponse {
+	usage := r.Usage
+	if usage == (provider.TokenUsage{}) {
+		var tc cost.SimpleCounter
+		for _, m := range req.Conversation() {
+			usage.PromptTokens += tc.CountTokens(m.Content)
+		}
+		usage.CompletionTokens = tc.CountTokens(r.Content)
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	model := r.Model
+	if model == "" {
+		model = req.Model
+	}
+	finish := r.FinishReason
+	if finish == "" {
+		finish = "stop"
+		if len(r.ToolCalls) > 0 {
+			finish = "tool_calls"
+		}
+	}
+	return &provider.CompletionResponse{
+		Content:      r.Content,
+		Model:        model,
+		Usage:        usage,
+		FinishReason: finish,
+		ToolCalls:    append([]provider.ToolCall(nil), r.ToolCalls...),
+	}
+}
+
+// Complete implements provider.Provider.
+func (p *Provider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	r, err := p.take(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx, r.Latency); err != nil {
+		return nil, err
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return r.response(req), nil
+}
+
+// Stream implements provider.Provider. The reply's Content is sent word by word after its
+// Latency, followed by a Done chunk with the usage; a reply with Err sends it as the only chunk.
+func (p *Provider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+	r, err := p.take(req)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan provider.StreamChunk)
+	go func() {
+		defer close(ch)
+		send := func(c provider.StreamChunk) bool {
+			select {
+			case ch <- c:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if err := wait(ctx, r.Latency); err != nil {
+			send(provider.StreamChunk{Err: err})
+			return
+		}
+		if r.Err != nil {
+			send(provider.StreamChunk{Err: r.Err})
+			return
+		}
+		for _, word := range strings.SplitAfter(r.Content, " ") {
+			if word != "" && !send(provider.StreamChunk{Content: word}) {
+				return
+			}
+		}
+		usage := r.response(req).Usage
+		send(provider.StreamChunk{Done: true, Usage: &usage})
+	}()
+	return ch, nil
+}
+
+// GetModelInfo implements provider.Provider; every model is reported with a 128k context and streaming.
+func (p *Provider) GetModelInfo(model string) (*provider.ModelInfo, error) {
+	return &provider.ModelInfo{ID: model, ContextSize: 128000, SupportsStreaming: true}, nil
+}
+
+// HealthCheck implements provider.HealthChecker with the error set by SetHealth.
+func (p *Provider) HealthCheck(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.healthErr
+}
+
+var (
+	_ provider.Provider      = (*Provider)(nil)
+	_ provider.HealthChecker = (*Provider)(nil)
+)
@@ -0,0 +1,171 @@
+package loomtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+)
+
+// Call is one call made to a Registry; Version is empty for methods that take none.
+type Call struct {
+	Method  string
+	ID      string
+	Version string
+}
+
+// Registry is an in-memory registry.Registry that records calls and fails on demand. Method names
+// passed to Fail and FailNext are those of registry.Registry ("Get", "Store", ...). Optional
+// interfaces (aliases, batches, audit history, conditional stores) are served by the embedded
+// MemoryRegistry and are neither recorded nor failed.
+type Registry struct {
+	*registry.MemoryRegistry
+
+	mu    sync.Mutex
+	calls []Call
+	fail  map[string]error
+	next  map[string][]error
+}
+
+// NewRegistry returns a Registry holding prompts; use Promote to give them a production version.
+func NewRegistry(prompts ...*core.Prompt) *Registry {
+	r := &Registry{
+		MemoryRegistry: registry.NewMemoryRegistry(),
+		fail:           make(map[string]error),
+		next:           make(map[string][]error),
+	}
+	for _, p := range prompts {
+		if err := r.MemoryRegistry.Store(context.Background(), p); err != nil {
+			panic("loomtest: NewRegistry: " + err.Error())
+		}
+	}
+	return r
+}
+
+// Fail makes every call to method return err until Fail is called again with nil.
+func (r *Registry) Fail(method string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		delete(r.fail, method)
+		return
+	}
+	r.fail[method] = err
+}
+
+// FailNext makes the next len(errs) calls to method return errs in order; later calls behave normally.
+func (r *Registry) FailNext(method string, errs ...error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next[method] = append(r.next[method], errs...)
+}
+
+// Calls returns the calls made so far, oldest first, including failed ones.
+func (r *Registry) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// call records a call and returns the error it should fail with, if any.
+func (r *Registry) call(method, id, version string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{Method: method, ID: id, Version: version})
+	if errs := r.next[method]; len(errs) > 0 {
+		r.next[method] = errs[1:]
+		return errs[0]
+	}
+	return r.fail[method]
+}
+
+// Store implements registry.Registry.
+func (r *Registry) Store(ctx context.Context, prompt *core.Prompt) error {
+	if err := r.call("Store", prompt.ID, prompt.Version); err != nil {
+		return err
+	}
+	return r.MemoryRegistry.Store(ctx, prompt)
+}
+
+// Get implements registry.Registry.
+func (r *Registry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	if err := r.call("Get", id, version); err != nil {
+		return nil, err
+	}
+	return r.MemoryRegistry.Get(ctx, id, version)
+}
+
+// GetProduction implements registry.Registry.
+func (r *Registry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	if err := r.call("GetProduction", id, ""); err != nil {
+		return nil, err
+	}
+	return r.MemoryRegistry.GetProduction(ctx, id)
+}
+
+// List implements registry.Registry.
+func (r *Registry) List(ctx context.Context, filter registry.Filter) ([]*core.Prompt, error) {
+	if err := r.call("List", "", ""); err != nil {
+		return nil, err
+	}
+	return r.MemoryRegistry.List(ctx, filter)
+}
+
+// ListVersions implements registry.Registry.
+func (r *Registry) ListVersions(ctx context.Context, id string) ([]registry.VersionInfo, error) {
+	if err := r.call("ListVersions", id, ""); err != nil {
+		return nil, err
+	}
+	return r.MemoryRegistry.ListVersions(ctx, id)
+}
+
+// Promote implements registry.Registry.
+func (r *Registry) Promote(ctx context.Context, id, version string, stage registry.Stage) error {
+	if err := r.call("Promote", id, version); err != nil {
+		return err
+	}
+	return r.MemoryRegistry.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements registry.ProductionRollbacker.
+func (r *Registry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	if err := r.call("RollbackProduction", id, version); err != nil {
+		return err
+	}
+	return r.MemoryRegistry.RollbackProduction(ctx, id, from, version)
+}
+
+// Delete implements registry.Registry.
+func (r *Registry) Delete(ctx context.Context, id, version string) error {
+	if err := r.call("Delete", id, version); err != nil {
+		return err
+	}
+	return r.MemoryRegistry.Delete(ctx, id, version)
+}
+
+// Tag implements registry.Registry.
+func (r *Registry) Tag(ctx context.Context, id, version string, tags []string) error {
+	if err := r.call("Tag", id, version); err != nil {
+		return err
+	}
+	return r.MemoryRegistry.Tag(ctx, id, version, tags)
+}
+
+// Archive implements registry.Registry.
+func (r *Registry) Archive(ctx context.Context, id, version string) error {
+	if err := r.call("Archive", id, version); err != nil {
+		return err
+	}
+	return r.MemoryRegistry.Archive(ctx, id, version)
+}
+
+// Restore implements registry.Registry.
+func (r *Registry) Restore(ctx context.Context, id, version string) error {
+	if err := r.call("Restore", id, version); err != nil {
+		return err
+	}
+	return r.MemoryRegistry.Restore(ctx, id, version)
+}
+
+var _ registry.Registry = (*Registry)(nil)
@@ -3,7 +3,9 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,7 +30,9 @@ type loggingProvider struct {
 	logf func(format string, args ...interface{})
 }
 
-// Logging returns a middleware that logs each Complete call (prompt snippet, model, error).
+// Logging returns a middleware that logs each Complete call (prompt snippet, model, error). The
+// snippet is taken from the redacted prompt the executor sets (provider.RedactedPromptMetadata), so
+// sensitive inputs are not logged; requests without one are logged by prompt length only.
 func Logging(logf func(format string, args ...interface{})) Middleware {
 	if logf == nil {
 		logf = func(string, ...interface{}) {}
@@ -39,7 +43,11 @@ func Logging(logf func(format string, args ...interface{})) Middleware {
 }
 
 func (l *loggingProvider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
-	l.logf("complete model=%s prompt_len=%d", req.Model, len(req.Prompt))
+	if redacted, ok := req.Metadata[provider.RedactedPromptMetadata].(string); ok {
+		l.logf("complete model=%s prompt_len=%d prompt=%q", req.Model, len(req.Prompt), snippet(redacted, 80))
+	} else {
+		l.logf("complete model=%s prompt_len=%d", req.Model, len(req.Prompt))
+	}
 	resp, err := l.next.Complete(ctx, req)
 	if err != nil {
 		l.logf("complete error: %v", err)
@@ -49,6 +57,15 @@ func (l *loggingProvider) Complete(ctx context.Context, req provider.CompletionR
 	return resp, nil
 }
 
+// snippet returns the first n runes of s, followed by "..." if s is longer.
+func snippet(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
+
 func (l *loggingProvider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
 	return l.next.Stream(ctx, req)
 }
@@ -57,6 +74,11 @@ func (l *loggingProvider) GetModelInfo(model string) (*provider.ModelInfo, error
 	return l.next.GetModelInfo(model)
 }
 
+// HealthCheck forwards to the wrapped provider (see provider.HealthChecker).
+func (l *loggingProvider) HealthCheck(ctx context.Context) error {
+	return provider.CheckHealth(ctx, l.next)
+}
+
 // metricsProvider counts requests and token usage.
 type metricsProvider struct {
 	next       provider.Provider
@@ -64,10 +86,17 @@ type metricsProvider struct {
 	errors     atomic.Uint64
 	promptTok  atomic.Uint64
 	completeTok atomic.Uint64
+
+	mu         sync.Mutex
+	streams    uint64
+	ttft       time.Duration
+	throughput float64 // sum of tokens/sec over the streams that reported usage
+	measured   uint64
 }
 
-// Metrics returns a middleware that counts requests, errors, and token usage.
-// Counters are exposed via Requests, Errors, PromptTokens, CompletionTokens.
+// Metrics returns a middleware that counts requests, errors, and token usage, and measures the
+// time to first token and throughput of streams. Counters are exposed via Requests, Errors,
+// PromptTokens, CompletionTokens, Streams, AvgTimeToFirstToken, and AvgTokensPerSecond.
 func Metrics() (Middleware, *MetricsCounters) {
 	m := &metricsProvider{}
 	return func(p provider.Provider) provider.Provider {
@@ -86,6 +115,33 @@ func (c *MetricsCounters) Errors() uint64     { return c.m.errors.Load() }
 func (c *MetricsCounters) PromptTokens() uint64   { return c.m.promptTok.Load() }
 func (c *MetricsCounters) CompletionTokens() uint64 { return c.m.completeTok.Load() }
 
+// Streams returns the number of streams that completed without error.
+func (c *MetricsCounters) Streams() uint64 {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	return c.m.streams
+}
+
+// AvgTimeToFirstToken returns the mean time to first token of completed streams.
+func (c *MetricsCounters) AvgTimeToFirstToken() time.Duration {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	if c.m.streams == 0 {
+		return 0
+	}
+	return c.m.ttft / time.Duration(c.m.streams)
+}
+
+// AvgTokensPerSecond returns the mean throughput of completed streams that reported token usage.
+func (c *MetricsCounters) AvgTokensPerSecond() float64 {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	if c.m.measured == 0 {
+		return 0
+	}
+	return c.m.throughput / float64(c.m.measured)
+}
+
 func (m *metricsProvider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
 	m.requests.Add(1)
 	resp, err := m.next.Complete(ctx, req)
@@ -99,14 +155,40 @@ func (m *metricsProvider) Complete(ctx context.Context, req provider.CompletionR
 }
 
 func (m *metricsProvider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
-	return m.next.Stream(ctx, req)
+	start := time.Now()
+	ch, err := m.next.Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return provider.TimeStream(ch, start, m.observe), nil
+}
+
+// observe records the stats of a finished stream.
+func (m *metricsProvider) observe(s provider.StreamStats) {
+	if s.Err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.streams++
+	m.ttft += s.TimeToFirstToken
+	if tps := s.TokensPerSecond(); tps > 0 {
+		m.throughput += tps
+		m.measured++
+	}
 }
 
 func (m *metricsProvider) GetModelInfo(model string) (*provider.ModelInfo, error) {
 	return m.next.GetModelInfo(model)
 }
 
-// cacheProvider caches Complete responses by (model + system + prompt) key.
+// HealthCheck forwards to the wrapped provider (see provider.HealthChecker).
+func (m *metricsProvider) HealthCheck(ctx context.Context) error {
+	return provider.CheckHealth(ctx, m.next)
+}
+
+// cacheProvider caches Complete responses by the request's provider.CacheKeyMetadata, or by
+// (model + system + prompt) if it has none.
 type cacheProvider struct {
 	next  provider.Provider
 	cache Cache
@@ -120,6 +202,9 @@ type Cache interface {
 }
 
 // CacheMiddleware returns a middleware that caches Complete responses. Stream is not cached.
+// Requests made by executor.Executor are keyed by their cache key (see provider.CacheKeyMetadata),
+// which starts with executor.CacheKeyPrefix(id, version) so a prompt version's entries can be
+// found and deleted; other requests are keyed by their model and rendered text.
 func CacheMiddleware(cache Cache, ttl time.Duration) Middleware {
 	if ttl <= 0 {
 		ttl = time.Hour
@@ -130,7 +215,16 @@ func CacheMiddleware(cache Cache, ttl time.Duration) Middleware {
 }
 
 func (c *cacheProvider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
-	key := req.Model + "\x00" + req.System + "\x00" + req.Prompt
+	key, _ := req.Metadata[provider.CacheKeyMetadata].(string)
+	if key == "" {
+		key = req.Model + "\x00" + req.System + "\x00" + req.Prompt
+		for _, m := range req.Messages {
+			key += "\x00" + m.Role + "\x00" + m.Content
+			for _, a := range m.Attachments {
+				key += fmt.Sprintf("\x00%s\x00%s\x00%x", a.MIMEType, a.URL, sha256.Sum256(a.Data))
+			}
+		}
+	}
 	if c.cache != nil {
 		if raw, ok := c.cache.Get(ctx, key); ok {
 			var resp provider.CompletionResponse
@@ -159,6 +253,11 @@ func (c *cacheProvider) GetModelInfo(model string) (*provider.ModelInfo, error)
 	return c.next.GetModelInfo(model)
 }
 
+// HealthCheck forwards to the wrapped provider (see provider.HealthChecker).
+func (c *cacheProvider) HealthCheck(ctx context.Context) error {
+	return provider.CheckHealth(ctx, c.next)
+}
+
 // InMemoryCache is a simple in-memory cache (for testing/single process).
 type InMemoryCache struct {
 	mu    sync.RWMutex
@@ -246,11 +345,13 @@ func (r *rateLimitProvider) GetModelInfo(model string) (*provider.ModelInfo, err
 	return r.next.GetModelInfo(model)
 }
 
-// circuitBreakerProvider fails fast when error rate is high.
-type circuitBreakerProvider struct {
-	next      provider.Provider
-	threshold float64
-	timeout   time.Duration
+// HealthCheck forwards to the wrapped provider (see provider.HealthChecker).
+func (r *rateLimitProvider) HealthCheck(ctx context.Context) error {
+	return provider.CheckHealth(ctx, r.next)
+}
+
+// circuit is one circuit breaker's state.
+type circuit struct {
 	requests  atomic.Uint64
 	failures  atomic.Uint64
 	state     atomic.Uint32 // 0 closed, 1 open, 2 half-open
@@ -264,44 +365,94 @@ const (
 	cbHalfOpen
 )
 
+// circuitBreakerProvider fails fast when error rate is high.
+type circuitBreakerProvider struct {
+	next      provider.Provider
+	threshold float64
+	timeout   time.Duration
+	key       func(req provider.CompletionRequest) string
+	global    circuit
+	keyed     sync.Map // key -> *circuit
+}
+
+// CircuitBreakerOption configures CircuitBreaker.
+type CircuitBreakerOption func(*circuitBreakerProvider)
+
+// PerKey gives each distinct key(req) its own circuit, so failures of one kind of request do not
+// fail fast every other; requests with an empty key share the default circuit.
+func PerKey(key func(req provider.CompletionRequest) string) CircuitBreakerOption {
+	return func(c *circuitBreakerProvider) { c.key = key }
+}
+
+// PerPrompt keys circuits by the prompt id in the request's provider.PromptIDMetadata (set by the
+// executor), so one misbehaving prompt (e.g. one that keeps triggering content errors) does not
+// open the circuit for the whole application.
+func PerPrompt() CircuitBreakerOption {
+	return PerKey(func(req provider.CompletionRequest) string {
+		id, _ := req.Metadata[provider.PromptIDMetadata].(string)
+		return id
+	})
+}
+
 // CircuitBreaker returns a middleware that opens (fails fast) when failure rate exceeds threshold (e.g. 0.5).
 // After timeout it allows one request (half-open); success closes the circuit.
-func CircuitBreaker(threshold float64, timeout time.Duration) Middleware {
+func CircuitBreaker(threshold float64, timeout time.Duration, opts ...CircuitBreakerOption) Middleware {
 	return func(p provider.Provider) provider.Provider {
-		return &circuitBreakerProvider{next: p, threshold: threshold, timeout: timeout}
+		c := &circuitBreakerProvider{next: p, threshold: threshold, timeout: timeout}
+		for _, o := range opts {
+			o(c)
+		}
+		return c
+	}
+}
+
+// circuitFor returns the circuit req is counted in.
+func (c *circuitBreakerProvider) circuitFor(req provider.CompletionRequest) *circuit {
+	if c.key == nil {
+		return &c.global
+	}
+	k := c.key(req)
+	if k == "" {
+		return &c.global
 	}
+	if cb, ok := c.keyed.Load(k); ok {
+		return cb.(*circuit)
+	}
+	cb, _ := c.keyed.LoadOrStore(k, &circuit{})
+	return cb.(*circuit)
 }
 
 func (c *circuitBreakerProvider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
-	if c.state.Load() == cbOpen {
-		c.mu.Lock()
-		if time.Now().Before(c.openUntil) {
-			c.mu.Unlock()
+	cb := c.circuitFor(req)
+	if cb.state.Load() == cbOpen {
+		cb.mu.Lock()
+		if time.Now().Before(cb.openUntil) {
+			cb.mu.Unlock()
 			return nil, context.DeadlineExceeded
 		}
-		c.state.Store(cbHalfOpen)
-		c.mu.Unlock()
+		cb.state.Store(cbHalfOpen)
+		cb.mu.Unlock()
 	}
-	c.requests.Add(1)
+	cb.requests.Add(1)
 	resp, err := c.next.Complete(ctx, req)
 	if err != nil {
-		c.failures.Add(1)
-		c.mu.Lock()
-		if c.state.Load() == cbHalfOpen {
-			c.state.Store(cbOpen)
-			c.openUntil = time.Now().Add(c.timeout)
-		} else if c.requests.Load() >= 10 {
-			rate := float64(c.failures.Load()) / float64(c.requests.Load())
+		cb.failures.Add(1)
+		cb.mu.Lock()
+		if cb.state.Load() == cbHalfOpen {
+			cb.state.Store(cbOpen)
+			cb.openUntil = time.Now().Add(c.timeout)
+		} else if cb.requests.Load() >= 10 {
+			rate := float64(cb.failures.Load()) / float64(cb.requests.Load())
 			if rate >= c.threshold {
-				c.state.Store(cbOpen)
-				c.openUntil = time.Now().Add(c.timeout)
+				cb.state.Store(cbOpen)
+				cb.openUntil = time.Now().Add(c.timeout)
 			}
 		}
-		c.mu.Unlock()
+		cb.mu.Unlock()
 		return nil, err
 	}
-	if c.state.Load() == cbHalfOpen {
-		c.state.Store(cbClosed)
+	if cb.state.Load() == cbHalfOpen {
+		cb.state.Store(cbClosed)
 	}
 	return resp, nil
 }
@@ -313,3 +464,17 @@ func (c *circuitBreakerProvider) Stream(ctx context.Context, req provider.Comple
 func (c *circuitBreakerProvider) GetModelInfo(model string) (*provider.ModelInfo, error) {
 	return c.next.GetModelInfo(model)
 }
+
+// HealthCheck reports an error while the default circuit is open (per-key circuits do not affect
+// it); otherwise it forwards to the wrapped provider.
+func (c *circuitBreakerProvider) HealthCheck(ctx context.Context) error {
+	if c.global.state.Load() == cbOpen {
+		c.global.mu.Lock()
+		open := time.Now().Before(c.global.openUntil)
+		c.global.mu.Unlock()
+		if open {
+			return fmt.Errorf("circuit breaker open")
+		}
+	}
+	return provider.CheckHealth(ctx, c.next)
+}
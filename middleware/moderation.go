@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/klejdi94/loom/provider"
+)
+
+// DefaultModerationReplacement is what Moderation sends in place of a flagged response.
+const DefaultModerationReplacement = "[This response was stopped because it may violate the content policy.]"
+
+// Classifier decides whether text violates a content policy. It returns a non-empty reason for a
+// violation and "" for clean text.
+type Classifier interface {
+	Classify(ctx context.Context, text string) (reason string, err error)
+}
+
+// ClassifierFunc adapts a function to Classifier.
+type ClassifierFunc func(ctx context.Context, text string) (string, error)
+
+// Classify implements Classifier.
+func (f ClassifierFunc) Classify(ctx context.Context, text string) (string, error) {
+	return f(ctx, text)
+}
+
+// Patterns returns a Classifier that flags text matching any of patterns; the reason names the pattern.
+func Patterns(patterns ...*regexp.Regexp) Classifier {
+	return ClassifierFunc(func(_ context.Context, text string) (string, error) {
+		for _, re := range patterns {
+			if re.MatchString(text) {
+				return "matched " + re.String(), nil
+			}
+		}
+		return "", nil
+	})
+}
+
+// Violation describes a response Moderation cut or replaced.
+type Violation struct {
+	Reason string
+	// Text is the response up to the point it was flagged (the full content for Complete).
+	Text string
+	// Delivered is how much of Text the caller had already received (0 for Complete).
+	Delivered int
+}
+
+// ModerationOption configures Moderation.
+type ModerationOption func(*moderationProvider)
+
+// WithReplacement sets the message sent in place of a flagged response ("" sends nothing and just
+// ends the stream). Default DefaultModerationReplacement.
+func WithReplacement(msg string) ModerationOption {
+	return func(m *moderationProvider) { m.replacement = msg }
+}
+
+// WithHoldback sets how many trailing characters of a stream are held back until more text
+// arrives, so that a match spanning chunk boundaries is caught before any of it is delivered. It is
+// also how many characters of already-classified text are classified again with the new text.
+// Size it to the longest phrase the classifier must catch whole. Default 64.
+func WithHoldback(n int) ModerationOption {
+	return func(m *moderationProvider) { m.holdback = n }
+}
+
+// WithCheckEvery makes Stream classify only after at least n new characters have arrived (and at
+// the end of the stream) instead of on every chunk, for classifiers that are expensive to call.
+// Text is never delivered unchecked; a larger n just delivers it in larger pieces.
+func WithCheckEvery(n int) ModerationOption {
+	return func(m *moderationProvider) { m.every = n }
+}
+
+// OnViolation registers a function called for each flagged response, e.g. for logging or audit.
+func OnViolation(fn func(ctx context.Context, v Violation)) ModerationOption {
+	return func(m *moderationProvider) { m.onViolation = fn }
+}
+
+// moderationProvider filters responses through a Classifier.
+type moderationProvider struct {
+	next        provider.Provider
+	classifier  Classifier
+	replacement string
+	holdback    int
+	every       int
+	onViolation func(ctx context.Context, v Violation)
+}
+
+// Moderation returns a middleware that checks responses with c before the caller sees them.
+// Streamed chunks are buffered and classified as they arrive, each new piece together with the
+// held-back tail (WithHoldback) of the text before it, so a stream costs time linear in its length;
+// clean text is delivered except for that tail. On a violation the stream is cut: the
+// upstream request is cancelled, the replacement message is sent as a final chunk, and the stream
+// ends with Done. Complete responses that are flagged have their Content replaced and FinishReason
+// set to "content_filter". A classifier error ends the stream (or fails Complete) rather than
+// letting unchecked text through.
+func Moderation(c Classifier, opts ...ModerationOption) Middleware {
+	return func(p provider.Provider) provider.Provider {
+		m := &moderationProvider{next: p, classifier: c, replacement: DefaultModerationReplacement, holdback: 64}
+		for _, o := range opts {
+			o(m)
+		}
+		return m
+	}
+}
+
+func (m *moderationProvider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	resp, err := m.next.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	reason, err := m.classifier.Classify(ctx, resp.Content)
+	if err != nil {
+		return nil, fmt.Errorf("moderation: %w", err)
+	}
+	if reason != "" {
+		m.violation(ctx, Violation{Reason: reason, Text: resp.Content})
+		out := *resp
+		out.Content = m.replacement
+		out.FinishReason = "content_filter"
+		out.ToolCalls = nil
+		return &out, nil
+	}
+	return resp, nil
+}
+
+func (m *moderationProvider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+	upCtx, cancel := context.WithCancel(ctx)
+	in, err := m.next.Stream(upCtx, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	out := make(chan provider.StreamChunk, 8)
+	go func() {
+		defer close(out)
+		defer func() {
+			cancel()
+			// Providers send without watching ctx; drain so they can finish.
+			for range in {
+			}
+		}()
+		send := func(c provider.StreamChunk) bool {
+			select {
+			case out <- c:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		var text []byte
+		sent, checked := 0, 0 // bytes of text delivered and classified
+		// check classifies the text since the last check, with the last holdback characters
+		// before it, and if it is clean, delivers all but the text's last keep characters.
+		check := func(keep int) bool {
+			from := holdbackStart(text[:checked], m.holdback)
+			reason, err := m.classifier.Classify(ctx, string(text[from:]))
+			if err != nil {
+				send(provider.StreamChunk{Err: fmt.Errorf("moderation: %w", err)})
+				return false
+			}
+			checked = len(text)
+			if reason != "" {
+				m.violation(ctx, Violation{Reason: reason, Text: string(text), Delivered: sent})
+				if m.replacement != "" && !send(provider.StreamChunk{Content: m.replacement}) {
+					return false
+				}
+				send(provider.StreamChunk{Done: true})
+				return false
+			}
+			end := holdbackStart(text, keep)
+			if end > sent {
+				if !send(provider.StreamChunk{Content: string(text[sent:end])}) {
+					return false
+				}
+				sent = end
+			}
+			return true
+		}
+		// flush checks any unclassified text and delivers everything that is left.
+		flush := func() bool {
+			if checked < len(text) {
+				return check(0)
+			}
+			if len(text) > sent {
+				return send(provider.StreamChunk{Content: string(text[sent:])})
+			}
+			return true
+		}
+		for chunk := range in {
+			if chunk.Err != nil {
+				send(chunk)
+				return
+			}
+			text = append(text, chunk.Content...)
+			if chunk.Done {
+				if !flush() {
+					return
+				}
+				send(provider.StreamChunk{Done: true, Usage: chunk.Usage})
+				return
+			}
+			if utf8.RuneCount(text[checked:]) >= m.every && len(text) > checked && !check(m.holdback) {
+				return
+			}
+		}
+		// The provider closed the stream without Done; deliver what is left if it is clean.
+		flush()
+	}()
+	return out, nil
+}
+
+func (m *moderationProvider) GetModelInfo(model string) (*provider.ModelInfo, error) {
+	return m.next.GetModelInfo(model)
+}
+
+// HealthCheck forwards to the wrapped provider (see provider.HealthChecker).
+func (m *moderationProvider) HealthCheck(ctx context.Context) error {
+	return provider.CheckHealth(ctx, m.next)
+}
+
+func (m *moderationProvider) violation(ctx context.Context, v Violation) {
+	if m.onViolation != nil {
+		m.onViolation(ctx, v)
+	}
+}
+
+// holdbackStart returns the byte offset in text where its last n runes begin.
+func holdbackStart(text []byte, n int) int {
+	end := len(text)
+	for ; n > 0 && end > 0; n-- {
+		_, size := utf8.DecodeLastRune(text[:end])
+		end -= size
+	}
+	return end
+}
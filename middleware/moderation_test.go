@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/klejdi94/loom/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkProvider streams its chunks and then Done.
+type chunkProvider []string
+
+func (p chunkProvider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	return &provider.CompletionResponse{Content: strings.Join(p, "")}, nil
+}
+
+func (p chunkProvider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+	ch := make(chan provider.StreamChunk, len(p)+1)
+	for _, c := range p {
+		ch <- provider.StreamChunk{Content: c}
+	}
+	ch <- provider.StreamChunk{Done: true}
+	close(ch)
+	return ch, nil
+}
+
+func (p chunkProvider) GetModelInfo(model string) (*provider.ModelInfo, error) {
+	return &provider.ModelInfo{ID: model}, nil
+}
+
+func TestModeration_Stream(t *testing.T) {
+	ctx := context.Background()
+	var classified []string
+	flagBad := ClassifierFunc(func(_ context.Context, text string) (string, error) {
+		classified = append(classified, text)
+		if strings.Contains(text, "BAD") {
+			return "bad word", nil
+		}
+		return "", nil
+	})
+	var violations []Violation
+	p := Moderation(flagBad, WithHoldback(4), WithReplacement("[cut]"), OnViolation(func(_ context.Context, v Violation) {
+		violations = append(violations, v)
+	}))(chunkProvider{"Hello ", "there, ", "this is BA", "D news ", "and more"})
+
+	ch, err := p.Stream(ctx, provider.CompletionRequest{})
+	require.NoError(t, err)
+	text, _, err := provider.CollectStream(ctx, ch, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there, this i[cut]", text, "the flag spanning two chunks was held back")
+	// Each check sees the new text and the last 4 characters before it, not the whole stream.
+	assert.Equal(t, []string{"Hello ", "llo there, ", "re, this is BA", "s BAD news "}, classified)
+	require.Len(t, violations, 1)
+	assert.Equal(t, Violation{Reason: "bad word", Text: "Hello there, this is BAD news ", Delivered: 19}, violations[0])
+
+	classified = nil
+	clean := Moderation(flagBad, WithHoldback(4))(chunkProvider{"Hello ", "there, ", "all ", "good"})
+	ch, err = clean.Stream(ctx, provider.CompletionRequest{})
+	require.NoError(t, err)
+	text, _, err = provider.CollectStream(ctx, ch, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there, all good", text)
+	assert.Len(t, classified, 4)
+}
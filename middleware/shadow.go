@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klejdi94/loom/provider"
+)
+
+// ShadowResult is a request mirrored by Shadow with the outcome of both providers.
+type ShadowResult struct {
+	// Request is the request as sent to the secondary provider (see WithShadowModel).
+	Request provider.CompletionRequest
+	// Stream is set when the caller streamed the primary response; the secondary is always
+	// called with Complete.
+	Stream           bool
+	Primary          *provider.CompletionResponse
+	PrimaryErr       error
+	PrimaryLatency   time.Duration
+	Secondary        *provider.CompletionResponse
+	SecondaryErr     error
+	SecondaryLatency time.Duration
+}
+
+// ShadowOption configures Shadow.
+type ShadowOption func(*shadowProvider)
+
+// WithShadowModel sends mirrored requests to model instead of the caller's model, e.g. to try a
+// new model on the same provider.
+func WithShadowModel(model string) ShadowOption {
+	return func(s *shadowProvider) { s.model = model }
+}
+
+// WithShadowTimeout bounds each mirrored request. Default 2m.
+func WithShadowTimeout(d time.Duration) ShadowOption {
+	return func(s *shadowProvider) { s.timeout = d }
+}
+
+// WithShadowConcurrency caps the mirrored requests in flight; sampled requests beyond it are not
+// mirrored, so a slow secondary cannot pile up goroutines. Default 16.
+func WithShadowConcurrency(n int) ShadowOption {
+	return func(s *shadowProvider) { s.slots = make(chan struct{}, n) }
+}
+
+// OnShadow registers a function called with each mirrored request once both providers have
+// answered, e.g. ShadowLog.Record or a function writing both outputs to a trace store. It runs
+// on the mirroring goroutine, never on the caller's.
+func OnShadow(fn func(ctx context.Context, r ShadowResult)) ShadowOption {
+	return func(s *shadowProvider) { s.onResult = fn }
+}
+
+// shadowProvider mirrors a sample of requests to a secondary provider.
+type shadowProvider struct {
+	next      provider.Provider
+	secondary provider.Provider
+	rate      float64
+	model     string
+	timeout   time.Duration
+	slots     chan struct{}
+	onResult  func(ctx context.Context, r ShadowResult)
+}
+
+// Shadow returns a middleware that mirrors sampleRate (0 to 1) of requests to secondary in the
+// background and reports both outputs to OnShadow, for evaluating a model migration on
+// production traffic. Callers always get the wrapped provider's response: the mirrored request
+// runs on its own goroutine with a context that is not cancelled with the caller's (but keeps
+// its values), and its errors and latency never reach the caller.
+func Shadow(secondary provider.Provider, sampleRate float64, opts ...ShadowOption) Middleware {
+	return func(p provider.Provider) provider.Provider {
+		s := &shadowProvider{next: p, secondary: secondary, rate: sampleRate, timeout: 2 * time.Minute,
+			slots: make(chan struct{}, 16)}
+		for _, o := range opts {
+			o(s)
+		}
+		return s
+	}
+}
+
+func (s *shadowProvider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	primary := s.mirror(ctx, req, false)
+	start := time.Now()
+	resp, err := s.next.Complete(ctx, req)
+	if primary != nil {
+		primary <- ShadowResult{Primary: resp, PrimaryErr: err, PrimaryLatency: time.Since(start)}
+	}
+	return resp, err
+}
+
+func (s *shadowProvider) Stream(ctx context.Context, req provider.CompletionRequest) (<-chan provider.StreamChunk, error) {
+	primary := s.mirror(ctx, req, true)
+	start := time.Now()
+	in, err := s.next.Stream(ctx, req)
+	if primary == nil {
+		return in, err
+	}
+	if err != nil {
+		primary <- ShadowResult{PrimaryErr: err, PrimaryLatency: time.Since(start)}
+		return nil, err
+	}
+	out := make(chan provider.StreamChunk)
+	go func() {
+		defer close(out)
+		resp := &provider.CompletionResponse{Model: req.Model}
+		var content strings.Builder
+		var streamErr error
+		for chunk := range in {
+			content.WriteString(chunk.Content)
+			if chunk.Usage != nil {
+				resp.Usage = *chunk.Usage
+			}
+			if chunk.Err != nil {
+				streamErr = chunk.Err
+			}
+			select {
+			case out <- chunk:
+				continue
+			case <-ctx.Done():
+			}
+			// The caller stopped reading. Providers send without watching ctx; drain so they can
+			// finish, and still report the primary so the mirror releases its slot.
+			streamErr = ctx.Err()
+			for range in {
+			}
+			break
+		}
+		resp.Content = content.String()
+		if streamErr != nil {
+			resp = nil
+		}
+		primary <- ShadowResult{Primary: resp, PrimaryErr: streamErr, PrimaryLatency: time.Since(start)}
+	}()
+	return out, nil
+}
+
+// mirror starts the secondary request for a sampled req and returns the channel on which the
+// caller sends the primary outcome, or nil if req is not mirrored.
+func (s *shadowProvider) mirror(ctx context.Context, req provider.CompletionRequest, stream bool) chan<- ShadowResult {
+	if s.rate <= 0 || rand.Float64() >= s.rate {
+		return nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return nil
+	}
+	if s.model != "" {
+		req.Model = s.model
+	}
+	primary := make(chan ShadowResult, 1)
+	go func() {
+		defer func() { <-s.slots }()
+		sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.timeout)
+		defer cancel()
+		start := time.Now()
+		resp, err := s.secondary.Complete(sctx, req)
+		r := <-primary
+		r.Request, r.Stream = req, stream
+		r.Secondary, r.SecondaryErr, r.SecondaryLatency = resp, err, time.Since(start)
+		if s.onResult != nil {
+			s.onResult(sctx, r)
+		}
+	}()
+	return primary
+}
+
+func (s *shadowProvider) GetModelInfo(model string) (*provider.ModelInfo, error) {
+	return s.next.GetModelInfo(model)
+}
+
+// HealthCheck forwards to the wrapped provider (see provider.HealthChecker); the secondary's
+// health does not affect it.
+func (s *shadowProvider) HealthCheck(ctx context.Context) error {
+	return provider.CheckHealth(ctx, s.next)
+}
+
+// ShadowLog keeps the most recent results of Shadow in memory for comparison, e.g. by a report
+// endpoint: pass OnShadow(log.Record).
+type ShadowLog struct {
+	mu      sync.Mutex
+	max     int
+	results []ShadowResult
+}
+
+// NewShadowLog returns a log that keeps the last max results (1000 if max <= 0).
+func NewShadowLog(max int) *ShadowLog {
+	if max <= 0 {
+		max = 1000
+	}
+	return &ShadowLog{max: max}
+}
+
+// Record adds r, dropping the oldest result when the log is full.
+func (l *ShadowLog) Record(_ context.Context, r ShadowResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.results) >= l.max {
+		l.results = append(l.results[:0], l.results[1:]...)
+	}
+	l.results = append(l.results, r)
+}
+
+// Results returns a copy of the recorded results, oldest first.
+func (l *ShadowLog) Results() []ShadowResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]ShadowResult(nil), l.results...)
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingProvider answers Complete once release is closed.
+type blockingProvider struct {
+	chunkProvider
+	release chan struct{}
+}
+
+func (p blockingProvider) Complete(ctx context.Context, req provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	<-p.release
+	return p.chunkProvider.Complete(ctx, req)
+}
+
+func TestShadow_Sampling(t *testing.T) {
+	ctx := context.Background()
+	never := Shadow(chunkProvider{"b"}, 0)(chunkProvider{"a"}).(*shadowProvider)
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, never.mirror(ctx, provider.CompletionRequest{}, false))
+	}
+
+	release := make(chan struct{})
+	always := Shadow(blockingProvider{chunkProvider{"b"}, release}, 1, WithShadowConcurrency(1))(chunkProvider{"a"}).(*shadowProvider)
+	primary := always.mirror(ctx, provider.CompletionRequest{}, false)
+	require.NotNil(t, primary)
+	assert.Nil(t, always.mirror(ctx, provider.CompletionRequest{}, false), "beyond the concurrency cap")
+	primary <- ShadowResult{}
+	close(release)
+	require.Eventually(t, func() bool { return len(always.slots) == 0 }, time.Second, time.Millisecond)
+	primary = always.mirror(ctx, provider.CompletionRequest{}, false)
+	assert.NotNil(t, primary, "the slot is free again")
+	primary <- ShadowResult{}
+}
+
+func TestShadow_OnShadow(t *testing.T) {
+	ctx := context.Background()
+	results := make(chan ShadowResult, 2)
+	p := Shadow(chunkProvider{"new ", "answer"}, 1, WithShadowModel("next"), OnShadow(func(_ context.Context, r ShadowResult) {
+		results <- r
+	}))(chunkProvider{"old ", "answer"})
+
+	resp, err := p.Complete(ctx, provider.CompletionRequest{Model: "current"})
+	require.NoError(t, err)
+	assert.Equal(t, "old answer", resp.Content, "the caller gets the primary")
+	r := <-results
+	assert.False(t, r.Stream)
+	assert.Equal(t, "next", r.Request.Model)
+	assert.Equal(t, "old answer", r.Primary.Content)
+	assert.Equal(t, "new answer", r.Secondary.Content)
+
+	ch, err := p.Stream(ctx, provider.CompletionRequest{Model: "current"})
+	require.NoError(t, err)
+	text, _, err := provider.CollectStream(ctx, ch, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "old answer", text)
+	r = <-results
+	assert.True(t, r.Stream)
+	assert.Equal(t, "old answer", r.Primary.Content)
+	assert.Equal(t, "new answer", r.Secondary.Content)
+}
+
+func TestShadow_AbandonedStream(t *testing.T) {
+	results := make(chan ShadowResult, 2)
+	p := Shadow(chunkProvider{"b"}, 1, WithShadowConcurrency(1), OnShadow(func(_ context.Context, r ShadowResult) {
+		results <- r
+	}))(chunkProvider{"a", "b", "c"})
+	sp := p.(*shadowProvider)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := p.Stream(ctx, provider.CompletionRequest{})
+	require.NoError(t, err)
+	cancel() // the caller walks away without reading
+
+	select {
+	case r := <-results:
+		assert.ErrorIs(t, r.PrimaryErr, context.Canceled)
+		assert.Nil(t, r.Primary)
+	case <-time.After(time.Second):
+		t.Fatal("the mirror never got the primary outcome")
+	}
+	require.Eventually(t, func() bool { return len(sp.slots) == 0 }, time.Second, time.Millisecond, "the slot is released")
+	_, err = p.Complete(context.Background(), provider.CompletionRequest{})
+	require.NoError(t, err)
+	select {
+	case <-results:
+	case <-time.After(time.Second):
+		t.Fatal("mirroring stopped after an abandoned stream")
+	}
+}
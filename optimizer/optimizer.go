@@ -3,16 +3,28 @@ package optimizer
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"math/rand"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/executor"
 )
 
 // OnWinnerFunc is called when an experiment has a statistically significant winner (once).
 type OnWinnerFunc func(winnerName string, prompt *core.Prompt)
 
+// SuccessFunc decides whether an executed variant counts as a success for the experiment.
+type SuccessFunc func(res *executor.ExecuteResult, err error) bool
+
+// DefaultSuccess treats a non-error result with non-empty content as a success.
+func DefaultSuccess(res *executor.ExecuteResult, err error) bool {
+	return err == nil && res != nil && strings.TrimSpace(res.Content) != ""
+}
+
 // Experiment represents an A/B test over prompt variants.
 type Experiment struct {
 	mu               sync.RWMutex
@@ -24,6 +36,9 @@ type Experiment struct {
 	confidenceLevel  float64
 	onWinner         OnWinnerFunc
 	winnerFired      bool
+	latencies        []time.Duration // sum of ExecuteWith latencies per variant
+	timed            []int64
+	success          SuccessFunc
 }
 
 // Variant is one prompt variant in an experiment.
@@ -45,6 +60,8 @@ func (e *Experiment) Variant(name string, p *core.Prompt, weight float64) *Exper
 	e.variants = append(e.variants, Variant{Name: name, Prompt: p, Weight: weight})
 	e.successes = append(e.successes, 0)
 	e.totals = append(e.totals, 0)
+	e.latencies = append(e.latencies, 0)
+	e.timed = append(e.timed, 0)
 	return e
 }
 
@@ -72,22 +89,34 @@ func (e *Experiment) WithOnWinner(cb OnWinnerFunc) *Experiment {
 	return e
 }
 
-// Execute runs one variant (selected by weight) and returns the rendered prompt and chosen variant name.
-func (e *Experiment) Execute(ctx context.Context, input core.Input) (*core.Rendered, string, error) {
+// WithSuccessPredicate sets how ExecuteWith decides success (default DefaultSuccess).
+func (e *Experiment) WithSuccessPredicate(fn SuccessFunc) *Experiment {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.success = fn
+	return e
+}
+
+// selectVariant picks a variant by weight. ok is false if the experiment has no variants.
+func (e *Experiment) selectVariant() (v Variant, ok bool) {
 	e.mu.RLock()
+	defer e.mu.RUnlock()
 	if len(e.variants) == 0 {
-		e.mu.RUnlock()
-		return nil, "", nil
+		return Variant{}, false
 	}
 	weights := make([]float64, len(e.variants))
 	for i := range e.variants {
 		weights[i] = e.variants[i].Weight
 	}
-	e.mu.RUnlock()
-	idx := selectWeightedIndex(weights)
-	e.mu.RLock()
-	v := e.variants[idx]
-	e.mu.RUnlock()
+	return e.variants[selectWeightedIndex(weights)], true
+}
+
+// Execute runs one variant (selected by weight) and returns the rendered prompt and chosen variant name.
+func (e *Experiment) Execute(ctx context.Context, input core.Input) (*core.Rendered, string, error) {
+	v, ok := e.selectVariant()
+	if !ok {
+		return nil, "", nil
+	}
 	rendered, err := v.Prompt.Render(ctx, input)
 	if err != nil {
 		return nil, "", err
@@ -95,6 +124,28 @@ func (e *Experiment) Execute(ctx context.Context, input core.Input) (*core.Rende
 	return rendered, v.Name, nil
 }
 
+// ExecuteWith runs the selected variant through exec (req.Prompt is replaced by the variant's prompt)
+// and records the outcome and latency automatically using the success predicate.
+// It returns the result, the chosen variant name, and the execution error, if any.
+func (e *Experiment) ExecuteWith(ctx context.Context, exec *executor.Executor, req executor.ExecuteRequest) (*executor.ExecuteResult, string, error) {
+	v, ok := e.selectVariant()
+	if !ok {
+		return nil, "", fmt.Errorf("experiment %q: no variants", e.name)
+	}
+	req.Prompt = v.Prompt
+	start := time.Now()
+	res, err := exec.Execute(ctx, req)
+	latency := time.Since(start)
+	e.mu.RLock()
+	success := e.success
+	e.mu.RUnlock()
+	if success == nil {
+		success = DefaultSuccess
+	}
+	e.record(v.Name, success(res, err), latency)
+	return res, v.Name, err
+}
+
 func selectWeightedIndex(weights []float64) int {
 	sum := 0.0
 	for _, w := range weights {
@@ -116,11 +167,20 @@ func selectWeightedIndex(weights []float64) int {
 // RecordSuccess records an outcome for a variant (e.g. after measuring conversion). success is true for a positive outcome.
 // If HasWinner becomes true and WithOnWinner was set, the callback is invoked once.
 func (e *Experiment) RecordSuccess(ctx context.Context, variantName string, success bool) {
+	e.record(variantName, success, 0)
+}
+
+// record counts an outcome (and latency, if non-zero) for the named variant and fires OnWinner once.
+func (e *Experiment) record(variantName string, success bool, latency time.Duration) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	for i := range e.variants {
 		if e.variants[i].Name == variantName {
 			e.totals[i]++
+			if latency > 0 {
+				e.latencies[i] += latency
+				e.timed[i]++
+			}
 			if success {
 				e.successes[i]++
 			}
@@ -208,6 +268,18 @@ func (e *Experiment) Promote(winnerName string) *core.Prompt {
 	return nil
 }
 
+// AvgLatency returns the mean latency recorded by ExecuteWith for a variant (zero if none).
+func (e *Experiment) AvgLatency(variantName string) time.Duration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for i := range e.variants {
+		if e.variants[i].Name == variantName && e.timed[i] > 0 {
+			return e.latencies[i] / time.Duration(e.timed[i])
+		}
+	}
+	return 0
+}
+
 // Stats returns per-variant success counts and totals.
 func (e *Experiment) Stats() (names []string, successes, totals []int64) {
 	e.mu.RLock()
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -52,17 +53,42 @@ type anthropicReq struct {
 	System      string             `json:"system,omitempty"`
 	Messages    []anthropicMsg     `json:"messages"`
 	Temperature float64            `json:"temperature,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
 }
 
 type anthropicMsg struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"` // string, or []anthropicBlock with attachments
+}
+
+// anthropicBlock is a text, image, or document content block.
+type anthropicBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicBlockSource `json:"source,omitempty"`
+}
+
+type anthropicBlockSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
 }
 
 type anthropicResp struct {
 	Content []struct {
-		Type string `json:"type"`
-		Text string `json:"text"`
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		ID    string          `json:"id"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
 	} `json:"content"`
 	StopReason  string `json:"stop_reason"`
 	Model       string `json:"model"`
@@ -72,14 +98,23 @@ type anthropicResp struct {
 	} `json:"usage"`
 }
 
-// Complete implements Provider.
-func (c *AnthropicClient) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+// post sends a Messages API request for req and returns the response, which has status 200.
+func (c *AnthropicClient) post(ctx context.Context, req CompletionRequest, stream bool) (*http.Response, error) {
+	system, messages := anthropicMessages(req)
 	body := anthropicReq{
 		Model:     req.Model,
 		MaxTokens: req.MaxTokens,
-		System:    req.System,
-		Messages:  []anthropicMsg{{Role: "user", Content: req.Prompt}},
+		System:    system,
+		Messages:  messages,
 		Temperature: req.Temperature,
+		Stream:    stream,
+	}
+	for _, t := range req.Tools {
+		schema := t.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		body.Tools = append(body.Tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: schema})
 	}
 	if body.Model == "" {
 		body.Model = "claude-3-5-sonnet-20241022"
@@ -102,19 +137,33 @@ func (c *AnthropicClient) Complete(ctx context.Context, req CompletionRequest) (
 	if err != nil {
 		return nil, fmt.Errorf("anthropic request: %w", err)
 	}
-	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		bs, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("anthropic api error %d: %s", resp.StatusCode, string(bs))
 	}
+	return resp, nil
+}
+
+// Complete implements Provider.
+func (c *AnthropicClient) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	resp, err := c.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 	var out anthropicResp
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
 		return nil, fmt.Errorf("anthropic decode: %w", err)
 	}
 	var text string
+	var calls []ToolCall
 	for _, block := range out.Content {
-		if block.Type == "text" {
+		switch block.Type {
+		case "text":
 			text += block.Text
+		case "tool_use":
+			calls = append(calls, ToolCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
 		}
 	}
 	usage := TokenUsage{}
@@ -128,22 +177,105 @@ func (c *AnthropicClient) Complete(ctx context.Context, req CompletionRequest) (
 		Model:        out.Model,
 		Usage:        usage,
 		FinishReason: out.StopReason,
+		ToolCalls:    calls,
 		Metadata:     req.Metadata,
 	}, nil
 }
 
-// Stream implements Provider (non-streaming fallback for simplicity).
+// anthropicEvent is a server-sent event of a streamed response. message_start carries the input
+// token count, content_block_start the type of the next content block, content_block_delta the
+// text, message_delta the output token count so far, and error an error that ended the stream.
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Stream implements Provider with server-sent events: text arrives as it is generated, and the
+// Done chunk carries the usage. A stream that ends before message_stop fails with
+// io.ErrUnexpectedEOF. Chunks cannot carry tool calls, so a stream in which the model calls one of
+// req's tools fails when the call starts; use Complete for requests that expect tool calls.
 func (c *AnthropicClient) Stream(ctx context.Context, req CompletionRequest) (<-chan StreamChunk, error) {
-	resp, err := c.Complete(ctx, req)
+	resp, err := c.post(ctx, req, true)
 	if err != nil {
 		return nil, err
 	}
-	ch := make(chan StreamChunk, 1)
-	ch <- StreamChunk{Content: resp.Content, Done: true, Usage: &resp.Usage}
-	close(ch)
+	ch := make(chan StreamChunk, 8)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+		var usage TokenUsage
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			var event anthropicEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &event); err != nil {
+				ch <- StreamChunk{Err: fmt.Errorf("anthropic decode: %w", err)}
+				return
+			}
+			switch event.Type {
+			case "message_start":
+				usage.PromptTokens = event.Message.Usage.InputTokens
+				usage.CompletionTokens = event.Message.Usage.OutputTokens
+			case "content_block_start":
+				if event.ContentBlock.Type == "tool_use" {
+					ch <- StreamChunk{Err: fmt.Errorf("anthropic stream: the model called tool %q; tool calls are only returned by Complete", event.ContentBlock.Name)}
+					return
+				}
+			case "content_block_delta":
+				if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+					ch <- StreamChunk{Content: event.Delta.Text}
+				}
+			case "message_delta":
+				usage.CompletionTokens = event.Usage.OutputTokens
+			case "message_stop":
+				usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+				ch <- StreamChunk{Done: true, Usage: &usage}
+				return
+			case "error":
+				ch <- StreamChunk{Err: fmt.Errorf("anthropic stream error: %s: %s", event.Error.Type, event.Error.Message)}
+				return
+			}
+		}
+		err := scanner.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		ch <- StreamChunk{Err: fmt.Errorf("anthropic stream: %w", err)}
+	}()
 	return ch, nil
 }
 
+// HealthCheck implements HealthChecker by listing models.
+func (c *AnthropicClient) HealthCheck(ctx context.Context) error {
+	return healthGET(ctx, c.HTTPClient, "anthropic", c.BaseURL+"/models", map[string]string{
+		"x-api-key": c.APIKey, "anthropic-version": "2023-06-01",
+	})
+}
+
 // GetModelInfo implements Provider.
 func (c *AnthropicClient) GetModelInfo(model string) (*ModelInfo, error) {
 	if model == "" {
@@ -151,3 +283,44 @@ func (c *AnthropicClient) GetModelInfo(model string) (*ModelInfo, error) {
 	}
 	return &ModelInfo{ID: model, ContextSize: 200000, SupportsStreaming: true}, nil
 }
+
+// anthropicMessages splits req's conversation into the system prompt, which Anthropic takes
+// separately (system turns are joined by blank lines), and the user and assistant turns. Turns
+// with attachments are sent as content blocks.
+func anthropicMessages(req CompletionRequest) (string, []anthropicMsg) {
+	var system []string
+	var messages []anthropicMsg
+	for _, m := range req.Conversation() {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		if len(m.Attachments) == 0 {
+			messages = append(messages, anthropicMsg{Role: m.Role, Content: m.Content})
+			continue
+		}
+		var blocks []anthropicBlock
+		if m.Content != "" {
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+		}
+		for _, a := range m.Attachments {
+			blocks = append(blocks, anthropicAttachment(a))
+		}
+		messages = append(messages, anthropicMsg{Role: m.Role, Content: blocks})
+	}
+	return strings.Join(system, "\n\n"), messages
+}
+
+// anthropicAttachment returns an image block for images and a document block for other files.
+func anthropicAttachment(a Attachment) anthropicBlock {
+	block := anthropicBlock{Type: "document"}
+	if a.IsImage() || (a.URL != "" && a.MIMEType == "") {
+		block.Type = "image"
+	}
+	if a.URL != "" {
+		block.Source = &anthropicBlockSource{Type: "url", URL: a.URL}
+	} else {
+		block.Source = &anthropicBlockSource{Type: "base64", MediaType: a.MIMEType, Data: a.Data}
+	}
+	return block
+}
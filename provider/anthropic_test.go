@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropic_Stream(t *testing.T) {
+	const start = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":10,\"output_tokens\":1}}}\n\n" +
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n"
+	events := map[string]string{
+		"complete": start +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"lo\"}}\n\n" +
+			"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n" +
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":5}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		"error": start +
+			"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+		"eof": start,
+		"tool": start +
+			"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"search\",\"input\":{}}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"q\\\"\"}}\n\n",
+	}
+	var got anthropicReq
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/messages", r.URL.Path)
+		assert.Equal(t, "sk-test", r.Header.Get("x-api-key"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, events[got.Model])
+	}))
+	defer srv.Close()
+	c, err := NewAnthropic(AnthropicConfig{APIKey: "sk-test", BaseURL: srv.URL})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	ch, err := c.Stream(ctx, CompletionRequest{System: "Be brief.", Prompt: "hi", Model: "complete"})
+	require.NoError(t, err)
+	text, usage, err := CollectStream(ctx, ch, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", text)
+	assert.Equal(t, &TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, usage)
+	assert.True(t, got.Stream)
+	assert.Equal(t, "Be brief.", got.System)
+	assert.Equal(t, 1024, got.MaxTokens)
+
+	ch, err = c.Stream(ctx, CompletionRequest{Prompt: "hi", Model: "error"})
+	require.NoError(t, err)
+	text, _, err = CollectStream(ctx, ch, 0)
+	assert.ErrorContains(t, err, "overloaded_error: Overloaded")
+	assert.Equal(t, "Hel", text)
+
+	ch, err = c.Stream(ctx, CompletionRequest{Prompt: "hi", Model: "eof"})
+	require.NoError(t, err)
+	_, _, err = CollectStream(ctx, ch, 0)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "a stream must end with message_stop")
+
+	ch, err = c.Stream(ctx, CompletionRequest{Prompt: "hi", Model: "tool", Tools: []Tool{{Name: "search"}}})
+	require.NoError(t, err)
+	_, _, err = CollectStream(ctx, ch, 0)
+	assert.ErrorContains(t, err, `tool "search"`, "tool calls are not dropped silently")
+	require.Len(t, got.Tools, 1)
+	assert.Equal(t, map[string]interface{}{"type": "object"}, got.Tools[0].InputSchema)
+}
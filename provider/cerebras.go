@@ -53,12 +53,15 @@ type cerebrasReq struct {
 	Temperature float64       `json:"temperature,omitempty"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
+	Tools       []openAITool  `json:"tools,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
 }
 
 type cerebrasResp struct {
 	Choices []struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
@@ -71,13 +74,21 @@ type cerebrasResp struct {
 
 // Complete implements Provider.
 func (c *CerebrasClient) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
-	messages := buildMessages(req)
+	if err := checkNoAttachments("cerebras", req); err != nil {
+		return nil, err
+	}
+	messages, err := buildMessages(req)
+	if err != nil {
+		return nil, err
+	}
 	body := cerebrasReq{
 		Model:       req.Model,
 		Messages:    messages,
 		Temperature: req.Temperature,
 		MaxTokens:   req.MaxTokens,
 		Stream:      false,
+		Tools:       buildOpenAITools(req.Tools),
+		ResponseFormat: buildResponseFormat(req),
 	}
 	if body.Model == "" {
 		body.Model = "llama-3.1-70b"
@@ -119,6 +130,7 @@ func (c *CerebrasClient) Complete(ctx context.Context, req CompletionRequest) (*
 		Model:        body.Model,
 		Usage:        usage,
 		FinishReason: out.Choices[0].FinishReason,
+		ToolCalls:    parseOpenAIToolCalls(out.Choices[0].Message.ToolCalls),
 		Metadata:     req.Metadata,
 	}, nil
 }
@@ -135,6 +147,11 @@ func (c *CerebrasClient) Stream(ctx context.Context, req CompletionRequest) (<-c
 	return ch, nil
 }
 
+// HealthCheck implements HealthChecker by listing models.
+func (c *CerebrasClient) HealthCheck(ctx context.Context) error {
+	return healthGET(ctx, c.HTTPClient, "cerebras", c.BaseURL+"/models", map[string]string{"Authorization": "Bearer " + c.APIKey})
+}
+
 // GetModelInfo implements Provider.
 func (c *CerebrasClient) GetModelInfo(model string) (*ModelInfo, error) {
 	if model == "" {
@@ -52,11 +52,18 @@ type cohereMsg struct {
 }
 
 type cohereReq struct {
-	Model       string      `json:"model"`
-	Messages    []cohereMsg `json:"messages"`
-	Temperature float64     `json:"temperature,omitempty"`
-	MaxTokens   int         `json:"max_tokens,omitempty"`
-	Stream      bool        `json:"stream,omitempty"`
+	Model          string                `json:"model"`
+	Messages       []cohereMsg           `json:"messages"`
+	Temperature    float64               `json:"temperature,omitempty"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	ResponseFormat *cohereResponseFormat `json:"response_format,omitempty"`
+}
+
+// cohereResponseFormat asks for JSON output, matching a schema if JSONSchema is set.
+type cohereResponseFormat struct {
+	Type       string                 `json:"type"`
+	JSONSchema map[string]interface{} `json:"json_schema,omitempty"`
 }
 
 type cohereResp struct {
@@ -74,11 +81,14 @@ type cohereResp struct {
 
 // Complete implements Provider.
 func (c *CohereClient) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
-	messages := make([]cohereMsg, 0, 2)
-	if req.System != "" {
-		messages = append(messages, cohereMsg{Role: "system", Content: req.System})
+	if err := checkNoAttachments("cohere", req); err != nil {
+		return nil, err
+	}
+	conv := req.Conversation()
+	messages := make([]cohereMsg, 0, len(conv))
+	for _, m := range conv {
+		messages = append(messages, cohereMsg{Role: m.Role, Content: m.Content})
 	}
-	messages = append(messages, cohereMsg{Role: "user", Content: req.Prompt})
 	body := cohereReq{
 		Model:       req.Model,
 		Messages:    messages,
@@ -86,6 +96,12 @@ func (c *CohereClient) Complete(ctx context.Context, req CompletionRequest) (*Co
 		MaxTokens:   req.MaxTokens,
 		Stream:      false,
 	}
+	switch req.Format() {
+	case ResponseFormatJSONObject:
+		body.ResponseFormat = &cohereResponseFormat{Type: "json_object"}
+	case ResponseFormatJSONSchema:
+		body.ResponseFormat = &cohereResponseFormat{Type: "json_object", JSONSchema: req.OutputSchema}
+	}
 	if body.Model == "" {
 		body.Model = "command-r-plus"
 	}
@@ -142,6 +158,12 @@ func (c *CohereClient) Stream(ctx context.Context, req CompletionRequest) (<-cha
 	return ch, nil
 }
 
+// HealthCheck implements HealthChecker by listing models (a v1 endpoint, also valid for v2 keys).
+func (c *CohereClient) HealthCheck(ctx context.Context) error {
+	url := strings.TrimSuffix(c.BaseURL, "/v2") + "/v1/models"
+	return healthGET(ctx, c.HTTPClient, "cohere", url, map[string]string{"Authorization": "Bearer " + c.APIKey})
+}
+
 // GetModelInfo implements Provider.
 func (c *CohereClient) GetModelInfo(model string) (*ModelInfo, error) {
 	if model == "" {
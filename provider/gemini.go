@@ -55,6 +55,8 @@ type geminiReq struct {
 		Temperature     float64  `json:"temperature,omitempty"`
 		MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
 		StopSequences   []string `json:"stopSequences,omitempty"`
+		ResponseMimeType   string                 `json:"responseMimeType,omitempty"`
+		ResponseJSONSchema map[string]interface{} `json:"responseJsonSchema,omitempty"`
 	} `json:"generationConfig,omitempty"`
 }
 
@@ -64,7 +66,15 @@ type geminiContent struct {
 }
 
 type geminiPart struct {
-	Text string `json:"text"`
+	Text       string `json:"text,omitempty"`
+	InlineData *struct {
+		MimeType string `json:"mimeType"`
+		Data     []byte `json:"data"`
+	} `json:"inlineData,omitempty"`
+	FileData *struct {
+		MimeType string `json:"mimeType,omitempty"`
+		FileURI  string `json:"fileUri"`
+	} `json:"fileData,omitempty"`
 }
 
 type geminiResp struct {
@@ -88,23 +98,33 @@ func (c *GeminiClient) Complete(ctx context.Context, req CompletionRequest) (*Co
 	if model == "" {
 		model = "gemini-1.5-flash"
 	}
+	system, contents := geminiContents(req)
 	body := geminiReq{
-		Contents: []geminiContent{{Parts: []geminiPart{{Text: req.Prompt}}}},
+		Contents: contents,
 	}
-	if req.System != "" {
+	if system != "" {
 		body.SystemInstruction = &struct {
 			Parts []geminiPart `json:"parts"`
-		}{Parts: []geminiPart{{Text: req.System}}}
+		}{Parts: []geminiPart{{Text: system}}}
 	}
 	body.GenerationConfig = &struct {
 		Temperature     float64  `json:"temperature,omitempty"`
 		MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
 		StopSequences   []string `json:"stopSequences,omitempty"`
+		ResponseMimeType   string                 `json:"responseMimeType,omitempty"`
+		ResponseJSONSchema map[string]interface{} `json:"responseJsonSchema,omitempty"`
 	}{
 		Temperature:     req.Temperature,
 		MaxOutputTokens: req.MaxTokens,
 		StopSequences:   req.StopTokens,
 	}
+	switch req.Format() {
+	case ResponseFormatJSONObject:
+		body.GenerationConfig.ResponseMimeType = "application/json"
+	case ResponseFormatJSONSchema:
+		body.GenerationConfig.ResponseMimeType = "application/json"
+		body.GenerationConfig.ResponseJSONSchema = req.OutputSchema
+	}
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(body); err != nil {
 		return nil, fmt.Errorf("gemini encode: %w", err)
@@ -163,6 +183,11 @@ func (c *GeminiClient) Stream(ctx context.Context, req CompletionRequest) (<-cha
 	return ch, nil
 }
 
+// HealthCheck implements HealthChecker by listing models.
+func (c *GeminiClient) HealthCheck(ctx context.Context) error {
+	return healthGET(ctx, c.HTTPClient, "gemini", c.BaseURL+"/models", map[string]string{"x-goog-api-key": c.APIKey})
+}
+
 // GetModelInfo implements Provider.
 func (c *GeminiClient) GetModelInfo(model string) (*ModelInfo, error) {
 	if model == "" {
@@ -170,3 +195,52 @@ func (c *GeminiClient) GetModelInfo(model string) (*ModelInfo, error) {
 	}
 	return &ModelInfo{ID: model, ContextSize: 1000000, SupportsStreaming: true}, nil
 }
+
+// geminiContents splits req's conversation into the system instruction (system turns joined by
+// blank lines) and the contents, with assistant turns in Gemini's "model" role and attachments as
+// inline or file data parts. A request without Messages keeps sending its prompt without a role,
+// as before.
+func geminiContents(req CompletionRequest) (string, []geminiContent) {
+	if len(req.Messages) == 0 {
+		return req.System, []geminiContent{{Parts: []geminiPart{{Text: req.Prompt}}}}
+	}
+	var system []string
+	var contents []geminiContent
+	for _, m := range req.Messages {
+		role := m.Role
+		switch role {
+		case "system":
+			system = append(system, m.Content)
+			continue
+		case "assistant":
+			role = "model"
+		}
+		var parts []geminiPart
+		if m.Content != "" || len(m.Attachments) == 0 {
+			parts = append(parts, geminiPart{Text: m.Content})
+		}
+		for _, a := range m.Attachments {
+			parts = append(parts, geminiAttachment(a))
+		}
+		contents = append(contents, geminiContent{Parts: parts, Role: role})
+	}
+	return strings.Join(system, "\n\n"), contents
+}
+
+// geminiAttachment returns an inline data part for a's data, or a file data part for its URL
+// (e.g. a Gemini Files API URI).
+func geminiAttachment(a Attachment) geminiPart {
+	var part geminiPart
+	if a.URL != "" {
+		part.FileData = &struct {
+			MimeType string `json:"mimeType,omitempty"`
+			FileURI  string `json:"fileUri"`
+		}{MimeType: a.MIMEType, FileURI: a.URL}
+		return part
+	}
+	part.InlineData = &struct {
+		MimeType string `json:"mimeType"`
+		Data     []byte `json:"data"`
+	}{MimeType: a.MIMEType, Data: a.Data}
+	return part
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HealthChecker is implemented by providers that can verify connectivity and credentials
+// with a cheap request (e.g. listing models). All built-in clients implement it.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// CheckHealth runs p's health check if it implements HealthChecker; otherwise it returns nil.
+func CheckHealth(ctx context.Context, p Provider) error {
+	if hc, ok := p.(HealthChecker); ok {
+		return hc.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// healthGET issues a GET to url with the given headers and returns an error for non-2xx responses.
+func healthGET(ctx context.Context, client *http.Client, name, url string, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s health: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bs, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s health %d: %s", name, resp.StatusCode, string(bs))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
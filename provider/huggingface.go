@@ -0,0 +1,281 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultHuggingFaceBase = "https://router.huggingface.co/v1"
+
+// defaultHuggingFaceModel is sent to a custom BaseURL when a request names no model: Text
+// Generation Inference serves a single model and ignores the name. The Hugging Face router needs
+// a real model id, so requests to it must name one.
+const defaultHuggingFaceModel = "tgi"
+
+// HuggingFaceClient is an HTTP client for the Messages API of Hugging Face Inference Endpoints,
+// the Hugging Face Inference router, and self-hosted text-generation-inference (TGI) servers.
+type HuggingFaceClient struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// HuggingFaceConfig configures the Hugging Face client. BaseURL is the API root ending in /v1:
+// the router by default, or e.g. https://xyz.endpoints.huggingface.cloud/v1 for an Inference
+// Endpoint or http://localhost:8080/v1 for a TGI server. APIKey (a Hugging Face access token)
+// is required for the router and may be empty for a server without authentication.
+type HuggingFaceConfig struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewHuggingFace creates a Hugging Face provider. Requests to the router must name a model; a
+// TGI server or Inference Endpoint serves its own whatever the request names.
+func NewHuggingFace(cfg HuggingFaceConfig) (*HuggingFaceClient, error) {
+	base := cfg.BaseURL
+	if base == "" {
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("huggingface: API key is required for the Hugging Face router")
+		}
+		base = defaultHuggingFaceBase
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HuggingFaceClient{
+		BaseURL:    strings.TrimSuffix(base, "/"),
+		APIKey:     cfg.APIKey,
+		HTTPClient: client,
+	}, nil
+}
+
+// hfChatReq is an OpenAI-compatible chat request as TGI accepts it.
+type hfChatReq struct {
+	Model          string        `json:"model"`
+	Messages       []openAIMsg   `json:"messages"`
+	Temperature    float64       `json:"temperature,omitempty"`
+	MaxTokens      int           `json:"max_tokens,omitempty"`
+	Stop           []string      `json:"stop,omitempty"`
+	Stream         bool          `json:"stream,omitempty"`
+	StreamOptions  *hfStreamOpts `json:"stream_options,omitempty"`
+	ResponseFormat *hfGrammar    `json:"response_format,omitempty"`
+}
+
+type hfStreamOpts struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// hfGrammar constrains TGI's output to JSON matching Value, a JSON Schema.
+type hfGrammar struct {
+	Type  string                 `json:"type"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type hfUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *hfUsage) tokenUsage() TokenUsage {
+	if u == nil {
+		return TokenUsage{}
+	}
+	return TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+type hfChatResp struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *hfUsage `json:"usage"`
+}
+
+// hfStreamEvent is one server-sent event of a streamed response: a delta, the usage (in a last
+// event without choices), or an error reported mid-stream.
+type hfStreamEvent struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *hfUsage `json:"usage"`
+	Error string   `json:"error"`
+}
+
+// hfResponseFormat returns the grammar for the response format req asks for, or nil for text.
+func hfResponseFormat(req CompletionRequest) *hfGrammar {
+	switch req.Format() {
+	case ResponseFormatJSONObject:
+		return &hfGrammar{Type: "json", Value: map[string]interface{}{"type": "object"}}
+	case ResponseFormatJSONSchema:
+		return &hfGrammar{Type: "json", Value: req.OutputSchema}
+	}
+	return nil
+}
+
+// model returns the model to request for req: its own, or for a TGI server, defaultHuggingFaceModel.
+func (c *HuggingFaceClient) model(req CompletionRequest) (string, error) {
+	switch {
+	case req.Model != "":
+		return req.Model, nil
+	case c.BaseURL == defaultHuggingFaceBase:
+		return "", fmt.Errorf("huggingface: a model id (e.g. meta-llama/Llama-3.1-8B-Instruct) is required with the Hugging Face router")
+	}
+	return defaultHuggingFaceModel, nil
+}
+
+// post sends a chat request for req and returns the response, which has status 200.
+func (c *HuggingFaceClient) post(ctx context.Context, req CompletionRequest, stream bool) (*http.Response, error) {
+	if err := checkNoAttachments("huggingface", req); err != nil {
+		return nil, err
+	}
+	model, err := c.model(req)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := buildMessages(req)
+	if err != nil {
+		return nil, err
+	}
+	body := hfChatReq{
+		Model:          model,
+		Messages:       messages,
+		Temperature:    req.Temperature,
+		MaxTokens:      req.MaxTokens,
+		Stop:           req.StopTokens,
+		Stream:         stream,
+		ResponseFormat: hfResponseFormat(req),
+	}
+	if stream {
+		body.StreamOptions = &hfStreamOpts{IncludeUsage: true}
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		return nil, fmt.Errorf("huggingface encode: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/chat/completions", &buf)
+	if err != nil {
+		return nil, err
+	}
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("huggingface request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bs, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("huggingface api error %d: %s", resp.StatusCode, string(bs))
+	}
+	return resp, nil
+}
+
+// Complete implements Provider.
+func (c *HuggingFaceClient) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	resp, err := c.post(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out hfChatResp
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("huggingface decode: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("huggingface: no choices")
+	}
+	model, _ := c.model(req)
+	return &CompletionResponse{
+		Content:      out.Choices[0].Message.Content,
+		Model:        model,
+		Usage:        out.Usage.tokenUsage(),
+		FinishReason: out.Choices[0].FinishReason,
+		Metadata:     req.Metadata,
+	}, nil
+}
+
+// Stream implements Provider with server-sent events. The Done chunk carries the usage if the
+// server reported it; servers that end the stream without a [DONE] event are handled too.
+func (c *HuggingFaceClient) Stream(ctx context.Context, req CompletionRequest) (<-chan StreamChunk, error) {
+	resp, err := c.post(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan StreamChunk, 8)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+		var usage *TokenUsage
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				break
+			}
+			var event hfStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				ch <- StreamChunk{Err: fmt.Errorf("huggingface decode: %w", err)}
+				return
+			}
+			if event.Error != "" {
+				ch <- StreamChunk{Err: fmt.Errorf("huggingface stream error: %s", event.Error)}
+				return
+			}
+			if event.Usage != nil {
+				u := event.Usage.tokenUsage()
+				usage = &u
+			}
+			if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
+				ch <- StreamChunk{Content: event.Choices[0].Delta.Content}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			ch <- StreamChunk{Err: fmt.Errorf("huggingface stream: %w", err)}
+			return
+		}
+		ch <- StreamChunk{Done: true, Usage: usage}
+	}()
+	return ch, nil
+}
+
+// HealthCheck implements HealthChecker by listing models.
+func (c *HuggingFaceClient) HealthCheck(ctx context.Context) error {
+	var headers map[string]string
+	if c.APIKey != "" {
+		headers = map[string]string{"Authorization": "Bearer " + c.APIKey}
+	}
+	return healthGET(ctx, c.HTTPClient, "huggingface", c.BaseURL+"/models", headers)
+}
+
+// GetModelInfo implements Provider. Context sizes vary by model and deployment; 8192 is a common
+// default for open models.
+func (c *HuggingFaceClient) GetModelInfo(model string) (*ModelInfo, error) {
+	model, err := c.model(CompletionRequest{Model: model})
+	if err != nil {
+		return nil, err
+	}
+	return &ModelInfo{ID: model, ContextSize: 8192, SupportsStreaming: true}, nil
+}
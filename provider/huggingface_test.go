@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHuggingFace_Complete(t *testing.T) {
+	var got hfChatReq
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"{\"ok\":true}"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":4,"total_tokens":16}}`)
+	}))
+	defer srv.Close()
+
+	c, err := NewHuggingFace(HuggingFaceConfig{BaseURL: srv.URL + "/v1/"})
+	require.NoError(t, err)
+	resp, err := c.Complete(context.Background(), CompletionRequest{
+		System:       "Answer in JSON.",
+		Prompt:       "Is it up?",
+		MaxTokens:    50,
+		OutputSchema: map[string]interface{}{"type": "object"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"ok":true}`, resp.Content)
+	assert.Equal(t, "tgi", resp.Model, "TGI servers get a placeholder model")
+	assert.Equal(t, "stop", resp.FinishReason)
+	assert.Equal(t, TokenUsage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16}, resp.Usage)
+	assert.Empty(t, auth, "no key, no Authorization header")
+	assert.Equal(t, "tgi", got.Model)
+	assert.Equal(t, 50, got.MaxTokens)
+	assert.False(t, got.Stream)
+	require.Len(t, got.Messages, 2)
+	assert.Equal(t, "system", got.Messages[0].Role)
+	assert.Equal(t, &hfGrammar{Type: "json", Value: map[string]interface{}{"type": "object"}}, got.ResponseFormat)
+}
+
+func TestHuggingFace_RouterNeedsModel(t *testing.T) {
+	_, err := NewHuggingFace(HuggingFaceConfig{})
+	assert.Error(t, err, "the router needs a key")
+
+	c, err := NewHuggingFace(HuggingFaceConfig{APIKey: "hf_x"})
+	require.NoError(t, err)
+	_, err = c.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	assert.ErrorContains(t, err, "model id")
+	_, err = c.Stream(context.Background(), CompletionRequest{Prompt: "hi"})
+	assert.ErrorContains(t, err, "model id")
+	_, err = c.GetModelInfo("")
+	assert.Error(t, err)
+}
+
+func TestHuggingFace_Stream(t *testing.T) {
+	events := map[string]string{
+		"done": "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+			"data:{\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2,\"total_tokens\":5}}\n\n" +
+			"data: [DONE]\n\n",
+		"no-done": ": keep-alive\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n",
+		"error":   "data: {\"choices\":[{\"delta\":{\"content\":\"He\"}}]}\n\ndata: {\"error\":\"Input validation error\",\"error_type\":\"validation\"}\n\n",
+	}
+	var got hfChatReq
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		assert.Equal(t, "Bearer hf_x", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, events[got.Model])
+	}))
+	defer srv.Close()
+	c, err := NewHuggingFace(HuggingFaceConfig{APIKey: "hf_x", BaseURL: srv.URL})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	ch, err := c.Stream(ctx, CompletionRequest{Prompt: "hi", Model: "done"})
+	require.NoError(t, err)
+	text, usage, err := CollectStream(ctx, ch, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", text)
+	assert.Equal(t, &TokenUsage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}, usage)
+	assert.True(t, got.Stream)
+	require.NotNil(t, got.StreamOptions)
+	assert.True(t, got.StreamOptions.IncludeUsage)
+
+	ch, err = c.Stream(ctx, CompletionRequest{Prompt: "hi", Model: "no-done"})
+	require.NoError(t, err)
+	text, usage, err = CollectStream(ctx, ch, 0)
+	require.NoError(t, err, "a stream may end without [DONE]")
+	assert.Equal(t, "Hello", text)
+	assert.Nil(t, usage)
+
+	ch, err = c.Stream(ctx, CompletionRequest{Prompt: "hi", Model: "error"})
+	require.NoError(t, err)
+	text, _, err = CollectStream(ctx, ch, 0)
+	assert.ErrorContains(t, err, "Input validation error")
+	assert.Equal(t, "He", text)
+}
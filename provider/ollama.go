@@ -45,6 +45,8 @@ type ollamaReq struct {
 	Model       string     `json:"model"`
 	Messages    []ollamaMsg `json:"messages"`
 	Stream      bool       `json:"stream"`
+	// Format is "json" for JSON mode or a JSON Schema for structured outputs (see ollamaFormat).
+	Format      interface{} `json:"format,omitempty"`
 	Options     *struct {
 		Temperature float64 `json:"temperature,omitempty"`
 		NumPredict  int     `json:"num_predict,omitempty"`
@@ -68,20 +70,23 @@ type ollamaResp struct {
 
 func buildOllamaMessages(req CompletionRequest) []ollamaMsg {
 	var out []ollamaMsg
-	if req.System != "" {
-		out = append(out, ollamaMsg{Role: "system", Content: req.System})
+	for _, m := range req.Conversation() {
+		out = append(out, ollamaMsg{Role: m.Role, Content: m.Content})
 	}
-	out = append(out, ollamaMsg{Role: "user", Content: req.Prompt})
 	return out
 }
 
 // Complete implements Provider.
 func (c *OllamaClient) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	if err := checkNoAttachments("ollama", req); err != nil {
+		return nil, err
+	}
 	messages := buildOllamaMessages(req)
 	body := ollamaReq{
 		Model:    req.Model,
 		Messages: messages,
 		Stream:   false,
+		Format:   ollamaFormat(req),
 	}
 	if body.Model == "" {
 		body.Model = "llama2"
@@ -131,11 +136,15 @@ func (c *OllamaClient) Complete(ctx context.Context, req CompletionRequest) (*Co
 
 // Stream implements Provider.
 func (c *OllamaClient) Stream(ctx context.Context, req CompletionRequest) (<-chan StreamChunk, error) {
+	if err := checkNoAttachments("ollama", req); err != nil {
+		return nil, err
+	}
 	messages := buildOllamaMessages(req)
 	body := ollamaReq{
 		Model:    req.Model,
 		Messages: messages,
 		Stream:   true,
+		Format:   ollamaFormat(req),
 	}
 	if body.Model == "" {
 		body.Model = "llama2"
@@ -181,6 +190,11 @@ func (c *OllamaClient) Stream(ctx context.Context, req CompletionRequest) (<-cha
 	return ch, nil
 }
 
+// HealthCheck implements HealthChecker by listing local models.
+func (c *OllamaClient) HealthCheck(ctx context.Context) error {
+	return healthGET(ctx, c.HTTPClient, "ollama", c.BaseURL+"/api/tags", nil)
+}
+
 // GetModelInfo implements Provider.
 func (c *OllamaClient) GetModelInfo(model string) (*ModelInfo, error) {
 	if model == "" {
@@ -192,3 +206,14 @@ func (c *OllamaClient) GetModelInfo(model string) (*ModelInfo, error) {
 		SupportsStreaming:  true,
 	}, nil
 }
+
+// ollamaFormat returns the format field for the response format req asks for, or nil for text.
+func ollamaFormat(req CompletionRequest) interface{} {
+	switch req.Format() {
+	case ResponseFormatJSONObject:
+		return "json"
+	case ResponseFormatJSONSchema:
+		return req.OutputSchema
+	}
+	return nil
+}
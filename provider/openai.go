@@ -55,11 +55,87 @@ type openAIChatReq struct {
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	Stop        []string      `json:"stop,omitempty"`
 	Stream      bool          `json:"stream,omitempty"`
+	Tools       []openAITool  `json:"tools,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+// openAIResponseFormat asks for JSON output (JSON mode), matching a schema if JSONSchema is set
+// (structured outputs).
+type openAIResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema *struct {
+		Name   string                 `json:"name"`
+		Schema map[string]interface{} `json:"schema"`
+	} `json:"json_schema,omitempty"`
+}
+
+// buildResponseFormat returns the response format req asks for (see CompletionRequest.Format), or
+// nil for text (shared by OpenAI-compatible APIs).
+func buildResponseFormat(req CompletionRequest) *openAIResponseFormat {
+	switch req.Format() {
+	case ResponseFormatJSONObject:
+		return &openAIResponseFormat{Type: "json_object"}
+	case ResponseFormatJSONSchema:
+		f := &openAIResponseFormat{Type: "json_schema"}
+		f.JSONSchema = &struct {
+			Name   string                 `json:"name"`
+			Schema map[string]interface{} `json:"schema"`
+		}{Name: "output", Schema: req.OutputSchema}
+		return f
+	}
+	return nil
 }
 
 type openAIMsg struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+	// Parts, if set, are sent as the content instead of Content (for attachments).
+	Parts []openAIPart `json:"-"`
+}
+
+// MarshalJSON sends Parts, if set, as the message content.
+func (m openAIMsg) MarshalJSON() ([]byte, error) {
+	type plain openAIMsg
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		Role      string           `json:"role"`
+		Content   []openAIPart     `json:"content"`
+		ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+	}{m.Role, m.Parts, m.ToolCalls})
+}
+
+// openAIPart is a text, image, or file part of a message's content.
+type openAIPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+	File *struct {
+		Filename string `json:"filename,omitempty"`
+		FileData string `json:"file_data"`
+	} `json:"file,omitempty"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description,omitempty"`
+		Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type openAIChatResp struct {
@@ -79,7 +155,10 @@ type openAIChatResp struct {
 
 // Complete implements Provider.
 func (c *OpenAIClient) Complete(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
-	messages := buildMessages(req)
+	messages, err := buildMessages(req)
+	if err != nil {
+		return nil, err
+	}
 	body := openAIChatReq{
 		Model:       req.Model,
 		Messages:    messages,
@@ -87,6 +166,8 @@ func (c *OpenAIClient) Complete(ctx context.Context, req CompletionRequest) (*Co
 		MaxTokens:   req.MaxTokens,
 		Stop:        req.StopTokens,
 		Stream:      false,
+		Tools:       buildOpenAITools(req.Tools),
+		ResponseFormat: buildResponseFormat(req),
 	}
 	if body.Model == "" {
 		body.Model = "gpt-3.5-turbo"
@@ -128,13 +209,17 @@ func (c *OpenAIClient) Complete(ctx context.Context, req CompletionRequest) (*Co
 		Model:        out.Model,
 		Usage:        usage,
 		FinishReason: out.Choices[0].FinishReason,
+		ToolCalls:    parseOpenAIToolCalls(out.Choices[0].Message.ToolCalls),
 		Metadata:     req.Metadata,
 	}, nil
 }
 
 // Stream implements Provider.
 func (c *OpenAIClient) Stream(ctx context.Context, req CompletionRequest) (<-chan StreamChunk, error) {
-	messages := buildMessages(req)
+	messages, err := buildMessages(req)
+	if err != nil {
+		return nil, err
+	}
 	body := openAIChatReq{
 		Model:       req.Model,
 		Messages:    messages,
@@ -142,6 +227,8 @@ func (c *OpenAIClient) Stream(ctx context.Context, req CompletionRequest) (<-cha
 		MaxTokens:   req.MaxTokens,
 		Stop:        req.StopTokens,
 		Stream:      true,
+		Tools:       buildOpenAITools(req.Tools),
+		ResponseFormat: buildResponseFormat(req),
 	}
 	if body.Model == "" {
 		body.Model = "gpt-3.5-turbo"
@@ -202,6 +289,11 @@ func (c *OpenAIClient) Stream(ctx context.Context, req CompletionRequest) (<-cha
 	return ch, nil
 }
 
+// HealthCheck implements HealthChecker by listing models.
+func (c *OpenAIClient) HealthCheck(ctx context.Context) error {
+	return healthGET(ctx, c.HTTPClient, "openai", c.BaseURL+"/models", map[string]string{"Authorization": "Bearer " + c.APIKey})
+}
+
 // GetModelInfo implements Provider (returns a minimal info).
 func (c *OpenAIClient) GetModelInfo(model string) (*ModelInfo, error) {
 	// Common models; could be extended with an API call.
@@ -217,11 +309,69 @@ func (c *OpenAIClient) GetModelInfo(model string) (*ModelInfo, error) {
 	return info, nil
 }
 
-func buildMessages(req CompletionRequest) []openAIMsg {
+func buildMessages(req CompletionRequest) ([]openAIMsg, error) {
 	var messages []openAIMsg
-	if req.System != "" {
-		messages = append(messages, openAIMsg{Role: "system", Content: req.System})
+	for _, m := range req.Conversation() {
+		msg := openAIMsg{Role: m.Role, Content: m.Content}
+		if len(m.Attachments) > 0 {
+			if m.Content != "" {
+				msg.Parts = append(msg.Parts, openAIPart{Type: "text", Text: m.Content})
+			}
+			for _, a := range m.Attachments {
+				part, err := openAIAttachment(a)
+				if err != nil {
+					return nil, err
+				}
+				msg.Parts = append(msg.Parts, part)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// openAIAttachment returns the content part for a: images by URL or data URL, other files by data.
+func openAIAttachment(a Attachment) (openAIPart, error) {
+	if a.IsImage() || (a.URL != "" && a.MIMEType == "") {
+		part := openAIPart{Type: "image_url"}
+		part.ImageURL = &struct {
+			URL string `json:"url"`
+		}{URL: a.DataURL()}
+		return part, nil
+	}
+	if a.URL != "" {
+		return openAIPart{}, fmt.Errorf("openai: %w: files must be sent as data, not by URL", ErrUnsupportedAttachment)
+	}
+	part := openAIPart{Type: "file"}
+	part.File = &struct {
+		Filename string `json:"filename,omitempty"`
+		FileData string `json:"file_data"`
+	}{Filename: a.Name, FileData: a.DataURL()}
+	return part, nil
+}
+
+// buildOpenAITools maps tool definitions to the OpenAI "function" tool format (shared by OpenAI-compatible APIs).
+func buildOpenAITools(tools []Tool) []openAITool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]openAITool, len(tools))
+	for i, t := range tools {
+		out[i].Type = "function"
+		out[i].Function.Name = t.Name
+		out[i].Function.Description = t.Description
+		out[i].Function.Parameters = t.Parameters
+	}
+	return out
+}
+
+func parseOpenAIToolCalls(calls []openAIToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]ToolCall, len(calls))
+	for i, c := range calls {
+		out[i] = ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments}
 	}
-	messages = append(messages, openAIMsg{Role: "user", Content: req.Prompt})
-	return messages
+	return out
 }
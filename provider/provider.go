@@ -3,19 +3,150 @@ package provider
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 // CompletionRequest is the unified request for LLM completion.
+// Tools are sent to providers that support tool calling (OpenAI, Anthropic, Cerebras) and ignored by others.
+//
+// Messages, if set, is the whole conversation (system, user, and assistant turns) and providers
+// send it instead of System and Prompt; Prompt and System then still hold the system prompt and
+// last user turn for middleware that only looks at them. See Conversation.
 type CompletionRequest struct {
 	Prompt      string
 	System      string
+	Messages    []Message
 	Model       string
 	Temperature float64
 	MaxTokens   int
 	StopTokens  []string
 	TopP        float64
+	Tools       []Tool
 	Metadata    map[string]interface{}
+	// OutputSchema, if set, is a JSON Schema object the response should match. Providers with a
+	// JSON mode (OpenAI, Cerebras, Cohere, Gemini, Ollama, Hugging Face)
+	// request matching output; others ignore it.
+	OutputSchema map[string]interface{}
+	// ResponseFormat selects the JSON mode of providers that have one (see Format); Anthropic has
+	// none and ignores it.
+	ResponseFormat ResponseFormat
+}
+
+// ResponseFormat is the kind of output a CompletionRequest asks for (see core.ResponseFormat).
+type ResponseFormat string
+
+const (
+	// ResponseFormatText asks for free text, without a JSON mode even if OutputSchema is set.
+	ResponseFormatText ResponseFormat = "text"
+	// ResponseFormatJSONObject asks for a valid JSON object of any shape.
+	ResponseFormatJSONObject ResponseFormat = "json_object"
+	// ResponseFormatJSONSchema asks for JSON matching OutputSchema.
+	ResponseFormatJSONSchema ResponseFormat = "json_schema"
+)
+
+// CacheKeyMetadata is the CompletionRequest.Metadata key for a deterministic response cache key
+// (a string, see executor.ExecuteRequest.CacheKey). Caches use it instead of the rendered text.
+const CacheKeyMetadata = "loom_cache_key"
+
+// PromptIDMetadata is the CompletionRequest.Metadata key for the id of the prompt the request was
+// rendered from (a string, set by the executor), for middleware that treats prompts separately.
+const PromptIDMetadata = "loom_prompt_id"
+
+// RedactedPromptMetadata is the CompletionRequest.Metadata key for the rendered user prompt with
+// the values of sensitive variables redacted (a string, set by the executor; see
+// core.Rendered.RedactedUser), for middleware that logs prompts.
+const RedactedPromptMetadata = "loom_redacted_prompt"
+
+// Message is one turn of a conversation; Role is "system", "user", or "assistant".
+// Attachments are sent by providers that accept images and files (OpenAI, Anthropic, Gemini);
+// others return ErrUnsupportedAttachment.
+type Message struct {
+	Role        string
+	Content     string
+	Attachments []Attachment
+}
+
+// Attachment is an image or file sent with a message. Either URL or Data is set.
+type Attachment struct {
+	MIMEType string
+	URL      string
+	Data     []byte
+	Name     string
+}
+
+// IsImage reports whether a is an image, by its MIME type.
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MIMEType, "image/")
+}
+
+// DataURL returns a's URL, or its data as a base64 data URL if it has no URL.
+func (a Attachment) DataURL() string {
+	if a.URL != "" {
+		return a.URL
+	}
+	return "data:" + a.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(a.Data)
+}
+
+// ErrUnsupportedAttachment is returned by providers asked to send an attachment they cannot.
+var ErrUnsupportedAttachment = errors.New("unsupported attachment")
+
+// checkNoAttachments returns ErrUnsupportedAttachment if any of req's messages has attachments,
+// for providers without image or file input.
+func checkNoAttachments(name string, req CompletionRequest) error {
+	for _, m := range req.Messages {
+		if len(m.Attachments) > 0 {
+			return fmt.Errorf("%s: %w: attachments are not supported", name, ErrUnsupportedAttachment)
+		}
+	}
+	return nil
+}
+
+// Conversation returns the messages to send for r: Messages if set, else System (if not empty) as
+// a system message followed by Prompt as a user message.
+func (r CompletionRequest) Conversation() []Message {
+	if len(r.Messages) > 0 {
+		return r.Messages
+	}
+	var out []Message
+	if r.System != "" {
+		out = append(out, Message{Role: "system", Content: r.System})
+	}
+	return append(out, Message{Role: "user", Content: r.Prompt})
+}
+
+// Format returns the response format r asks for: ResponseFormat if set, else
+// ResponseFormatJSONSchema if r has an OutputSchema and ResponseFormatText if not.
+// ResponseFormatJSONSchema without an OutputSchema is ResponseFormatJSONObject.
+func (r CompletionRequest) Format() ResponseFormat {
+	switch {
+	case r.ResponseFormat == ResponseFormatJSONSchema && r.OutputSchema == nil:
+		return ResponseFormatJSONObject
+	case r.ResponseFormat != "":
+		return r.ResponseFormat
+	case r.OutputSchema != nil:
+		return ResponseFormatJSONSchema
+	}
+	return ResponseFormatText
+}
+
+// Tool is a function definition offered to the model for tool calling.
+// Parameters is a JSON Schema object describing the arguments.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// ToolCall is a tool invocation requested by the model. Arguments is the raw JSON argument object.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
 }
 
 // CompletionResponse is the unified completion response.
@@ -24,6 +155,7 @@ type CompletionResponse struct {
 	Model     string
 	Usage     TokenUsage
 	FinishReason string
+	ToolCalls []ToolCall
 	Metadata  map[string]interface{}
 }
 
@@ -78,3 +210,115 @@ func StreamToWriter(ctx context.Context, ch <-chan StreamChunk, w io.Writer) err
 		}
 	}
 }
+
+// ErrChunkTimeout is returned by CollectStream when no chunk arrives within the chunk timeout.
+var ErrChunkTimeout = errors.New("stream chunk timeout")
+
+// CollectStream reads ch until it is closed or a Done chunk arrives and returns the aggregated text
+// and the last reported usage (nil if the provider reported none). If chunkTimeout > 0, it fails
+// with ErrChunkTimeout when the gap between chunks exceeds it. On early return the channel is
+// drained in the background so the producer can exit; callers should also cancel the stream's context.
+func CollectStream(ctx context.Context, ch <-chan StreamChunk, chunkTimeout time.Duration) (string, *TokenUsage, error) {
+	var sb strings.Builder
+	var usage *TokenUsage
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	if chunkTimeout > 0 {
+		timer = time.NewTimer(chunkTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	abort := func(err error) (string, *TokenUsage, error) {
+		go func() {
+			for range ch {
+			}
+		}()
+		return sb.String(), usage, err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return abort(ctx.Err())
+		case <-timeout:
+			return abort(ErrChunkTimeout)
+		case chunk, ok := <-ch:
+			if !ok {
+				return sb.String(), usage, nil
+			}
+			if chunk.Err != nil {
+				return abort(chunk.Err)
+			}
+			sb.WriteString(chunk.Content)
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+			if chunk.Done {
+				return sb.String(), usage, nil
+			}
+			if timer != nil {
+				timer.Reset(chunkTimeout)
+			}
+		}
+	}
+}
+
+// StreamStats are the latency metrics of one streamed response, the ones users of a streaming UI
+// notice: how long until text appears, and how fast it arrives after that.
+type StreamStats struct {
+	// TimeToFirstToken is the time from the request to the first chunk with content (0 if none).
+	TimeToFirstToken time.Duration
+	// Duration is the time from the request to the end of the stream.
+	Duration time.Duration
+	// OutputTokens is the completion token count the provider reported (0 if it reported none).
+	OutputTokens int
+	// Err is the error the stream ended with, if any.
+	Err error
+}
+
+// TokensPerSecond returns the output tokens generated per second after the first token, or over
+// the whole stream if all content arrived in one chunk; 0 if the provider reported no usage.
+func (s StreamStats) TokensPerSecond() float64 {
+	d := s.Duration - s.TimeToFirstToken
+	if d <= 0 || s.OutputTokens <= 1 {
+		d = s.Duration
+	}
+	if s.OutputTokens == 0 || d <= 0 {
+		return 0
+	}
+	return float64(s.OutputTokens) / d.Seconds()
+}
+
+// TimeStream forwards the chunks of ch, a stream requested at start, and calls done with its
+// StreamStats when it ends: before forwarding the Done or error chunk, or before closing the
+// returned channel, so a reader that has seen the end of the stream also sees what done recorded.
+// The reader must drain the returned channel (as CollectStream does) for done to be called.
+func TimeStream(ch <-chan StreamChunk, start time.Time, done func(StreamStats)) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		var stats StreamStats
+		finish := func() {
+			stats.Duration = time.Since(start)
+			done(stats)
+		}
+		for chunk := range ch {
+			if stats.TimeToFirstToken == 0 && chunk.Content != "" {
+				stats.TimeToFirstToken = time.Since(start)
+			}
+			if chunk.Usage != nil {
+				stats.OutputTokens = chunk.Usage.CompletionTokens
+			}
+			if chunk.Done || chunk.Err != nil {
+				stats.Err = chunk.Err
+				finish()
+				out <- chunk
+				for range ch {
+				}
+				return
+			}
+			out <- chunk
+		}
+		finish()
+	}()
+	return out
+}
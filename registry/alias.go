@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrInvalidAlias is returned by SetAlias for alias names that ValidateAlias rejects.
+var ErrInvalidAlias = errors.New("invalid alias")
+
+// AliasLatest is resolved to GetLatest when a prompt id has no explicit "latest" alias.
+const AliasLatest = "latest"
+
+// Aliaser is implemented by registries that support named aliases per prompt id (e.g. "stable",
+// "canary") pointing to a version. Get(ctx, id, "@stable") returns the version the alias points to.
+//
+// SetAlias points alias at version, which must exist; an empty version removes the alias. Aliases
+// returns id's aliases (alias -> version). An alias whose version was deleted resolves to
+// core.ErrPromptNotFound until it is set again.
+type Aliaser interface {
+	SetAlias(ctx context.Context, id, alias, version string) error
+	Aliases(ctx context.Context, id string) (map[string]string, error)
+}
+
+// SetAlias points alias of id at version via reg's SetAlias. It returns an error if reg does not
+// implement Aliaser.
+func SetAlias(ctx context.Context, reg Registry, id, alias, version string) error {
+	a, ok := reg.(Aliaser)
+	if !ok {
+		return fmt.Errorf("registry: %T does not support aliases", reg)
+	}
+	return a.SetAlias(ctx, id, alias, version)
+}
+
+// Aliases returns the aliases of id via reg's Aliases. It returns an error if reg does not
+// implement Aliaser.
+func Aliases(ctx context.Context, reg Registry, id string) (map[string]string, error) {
+	a, ok := reg.(Aliaser)
+	if !ok {
+		return nil, fmt.Errorf("registry: %T does not support aliases", reg)
+	}
+	return a.Aliases(ctx, id)
+}
+
+// ParseAlias returns the alias name of an "@alias" version reference.
+func ParseAlias(version string) (alias string, ok bool) {
+	if !strings.HasPrefix(version, "@") {
+		return "", false
+	}
+	return version[1:], true
+}
+
+var aliasPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+// ValidateAlias reports whether alias is a valid alias name: a letter followed by letters, digits,
+// '.', '_', or '-'.
+func ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("%w %q: must be a letter followed by letters, digits, '.', '_', or '-'", ErrInvalidAlias, alias)
+	}
+	return nil
+}
+
+// GetByAlias returns the version of id that alias points to, for backends implementing Aliaser.
+// target is the stored version of the alias, or "" if it is not set; an unset "latest" alias
+// resolves to GetLatest.
+func GetByAlias(ctx context.Context, reg Registry, id, alias, target string) (*core.Prompt, error) {
+	if target == "" {
+		if alias == AliasLatest {
+			return GetLatest(ctx, reg, id)
+		}
+		return nil, fmt.Errorf("%w: %s has no alias %q", core.ErrPromptNotFound, id, alias)
+	}
+	if _, ok := ParseAlias(target); ok {
+		return nil, core.ErrPromptNotFound
+	}
+	return reg.Get(ctx, id, target)
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// AuditAction is the kind of registry change recorded in an AuditEntry.
+type AuditAction string
+
+const (
+	AuditStore   AuditAction = "store"
+	AuditPromote AuditAction = "promote"
+	AuditTag     AuditAction = "tag"
+	AuditDelete  AuditAction = "delete"
+	AuditArchive AuditAction = "archive"
+	AuditRestore AuditAction = "restore"
+	AuditAlias   AuditAction = "alias"
+	// AuditRollback records a rollback (see Rollback): Version went back to production (Stage).
+	AuditRollback AuditAction = "rollback"
+)
+
+// AuditEntry records who changed which prompt version, when, and how.
+type AuditEntry struct {
+	ID      string      `json:"id"`
+	Version string      `json:"version"`
+	Action  AuditAction `json:"action"`
+	// Actor is the caller set with WithActor; empty if unknown.
+	Actor string    `json:"actor,omitempty"`
+	Time  time.Time `json:"time"`
+	// Stage is the target stage of a promote.
+	Stage Stage `json:"stage,omitempty"`
+	// Tags are the tags set by a tag action.
+	Tags []string `json:"tags,omitempty"`
+	// Revision is the revision written by a store.
+	Revision int64 `json:"revision,omitempty"`
+	// Alias is the alias set (to Version) or removed (Version empty) by an alias action.
+	Alias string `json:"alias,omitempty"`
+}
+
+// Auditor is implemented by registries that keep an audit log of Store, Promote, Tag, Delete,
+// Archive, Restore, SetAlias, and RollbackProduction. Backends append an entry after each
+// successful change; History returns the entries for id, oldest first, including those of deleted
+// versions.
+type Auditor interface {
+	History(ctx context.Context, id string) ([]AuditEntry, error)
+}
+
+// History returns the audit log for id via reg's History. It returns an error if reg does not
+// implement Auditor.
+func History(ctx context.Context, reg Registry, id string) ([]AuditEntry, error) {
+	a, ok := reg.(Auditor)
+	if !ok {
+		return nil, fmt.Errorf("registry: %T does not keep an audit log", reg)
+	}
+	return a.History(ctx, id)
+}
+
+// actorKey is the context key for the caller recorded in audit entries.
+type actorKey struct{}
+
+// WithActor returns a context whose registry changes are attributed to actor (a user, service, or key name).
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor, or "".
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// KeyActor identifies an API key in audit entries without revealing it: "key:" followed by the
+// first 8 hex digits of the key's SHA-256. If claimed is set (e.g. a user name sent by the client),
+// it is kept in front: "alice (key:1a2b3c4d)".
+func KeyActor(key, claimed string) string {
+	sum := sha256.Sum256([]byte(key))
+	id := "key:" + hex.EncodeToString(sum[:4])
+	if claimed == "" {
+		return id
+	}
+	return claimed + " (" + id + ")"
+}
+
+// NewAuditEntry returns an entry for action on id@version attributed to ctx's actor, for backends
+// implementing Auditor.
+func NewAuditEntry(ctx context.Context, action AuditAction, id, version string) AuditEntry {
+	return AuditEntry{ID: id, Version: version, Action: action, Actor: ActorFromContext(ctx), Time: time.Now().UTC()}
+}
@@ -0,0 +1,370 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// principalKey is the context key for the caller checked by AuthzRegistry.
+type principalKey struct{}
+
+// WithPrincipal returns a context whose registry operations are authorized as principal (see
+// WithAuthz). Unlike the actor (WithActor), which clients may claim, the principal must come from
+// something the process trusts: registry servers set it from the API key (Scope.Principal), and
+// the loom CLI uses its -actor for a registry it opens itself.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal set with WithPrincipal, or "".
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// KeyPrincipal returns the principal of requests made with an API key: scope.Principal, or
+// KeyActor(key, "") if it is empty.
+func KeyPrincipal(key string, scope Scope) string {
+	if scope.Principal != "" {
+		return scope.Principal
+	}
+	return KeyActor(key, "")
+}
+
+// Action is a kind of registry operation checked by an AuthzPolicy.
+type Action string
+
+const (
+	// ActionRead covers Get, GetProduction, GetMany, List, ListVersions, Aliases, History, usage
+	// reports, and watching for changes.
+	ActionRead Action = "read"
+	// ActionStore covers Store, StoreIfMatch, and StoreBatch.
+	ActionStore Action = "store"
+	// ActionTag covers Tag.
+	ActionTag Action = "tag"
+	// ActionArchive covers Archive and Restore.
+	ActionArchive Action = "archive"
+	// ActionAlias covers SetAlias, except for environment production pointers (ActionPromote).
+	ActionAlias Action = "alias"
+	// ActionPromote covers Promote and environment production pointers (see PromoteFor).
+	ActionPromote Action = "promote"
+	// ActionDelete covers Delete and DeleteBatch.
+	ActionDelete Action = "delete"
+)
+
+// AuthzRequest is an operation as seen by an AuthzPolicy.
+type AuthzRequest struct {
+	// Principal is the caller (see WithPrincipal), "" if none was set.
+	Principal string
+	Action    Action
+	// ID and Version name the prompt version, if the operation is on one ("" for List and the
+	// like; Version is "" for operations on a whole id).
+	ID, Version string
+	// Stage is the target stage of ActionPromote (StageProduction for environment pointers).
+	Stage Stage
+}
+
+// AuthzPolicy decides whether a principal may perform an operation. Authorize returns nil to allow
+// it; a denial should wrap ErrForbidden so servers answer 403 (see Deny).
+type AuthzPolicy interface {
+	Authorize(ctx context.Context, req AuthzRequest) error
+}
+
+// AuthzPolicyFunc adapts a function to AuthzPolicy.
+type AuthzPolicyFunc func(ctx context.Context, req AuthzRequest) error
+
+// Authorize implements AuthzPolicy.
+func (f AuthzPolicyFunc) Authorize(ctx context.Context, req AuthzRequest) error {
+	return f(ctx, req)
+}
+
+// Deny returns the ErrForbidden error for req, naming the principal and the operation.
+func Deny(req AuthzRequest) error {
+	who := req.Principal
+	if who == "" {
+		who = "anonymous"
+	}
+	what := string(req.Action)
+	switch {
+	case req.Version != "":
+		what += " " + req.ID + "@" + req.Version
+	case req.ID != "":
+		what += " " + req.ID
+	}
+	if req.Action == ActionPromote {
+		what += " to " + string(req.Stage)
+	}
+	return fmt.Errorf("%w: %s may not %s", ErrForbidden, who, what)
+}
+
+// Role is a set of allowed actions for RolePolicy.
+type Role string
+
+const (
+	// RoleReader may read.
+	RoleReader Role = "reader"
+	// RoleEditor may also store, tag, archive, restore, set aliases, and promote to dev and staging.
+	RoleEditor Role = "editor"
+	// RoleReleaser may do everything: also promote to production, set environment production
+	// pointers, and delete.
+	RoleReleaser Role = "releaser"
+)
+
+// Allows reports whether r allows req.
+func (r Role) Allows(req AuthzRequest) bool {
+	switch r {
+	case RoleReleaser:
+		return true
+	case RoleEditor:
+		switch req.Action {
+		case ActionRead, ActionStore, ActionTag, ActionArchive, ActionAlias:
+			return true
+		case ActionPromote:
+			return req.Stage == StageDev || req.Stage == StageStaging
+		}
+	case RoleReader:
+		return req.Action == ActionRead
+	}
+	return false
+}
+
+// ValidRole reports whether r is RoleReader, RoleEditor, or RoleReleaser.
+func ValidRole(r Role) bool {
+	return r == RoleReader || r == RoleEditor || r == RoleReleaser
+}
+
+// RolePolicy is an AuthzPolicy that gives each principal a Role.
+type RolePolicy struct {
+	// Roles maps principals to their role.
+	Roles map[string]Role
+	// Default is the role of principals not in Roles, including callers without one; "" denies
+	// them everything.
+	Default Role
+}
+
+// Authorize implements AuthzPolicy.
+func (p RolePolicy) Authorize(_ context.Context, req AuthzRequest) error {
+	role, ok := p.Roles[req.Principal]
+	if !ok {
+		role = p.Default
+	}
+	if !role.Allows(req) {
+		return Deny(req)
+	}
+	return nil
+}
+
+// AuthzRegistry checks every operation on another Registry against an AuthzPolicy for the
+// principal in the context (see WithPrincipal). Denied operations return the policy's error,
+// which wraps ErrForbidden, without reaching inner.
+type AuthzRegistry struct {
+	inner  Registry
+	policy AuthzPolicy
+}
+
+// WithAuthz returns inner with every operation authorized by policy.
+func WithAuthz(inner Registry, policy AuthzPolicy) *AuthzRegistry {
+	return &AuthzRegistry{inner: inner, policy: policy}
+}
+
+// authorize checks action on id@version (and target stage, for promotions) for ctx's principal.
+func (a *AuthzRegistry) authorize(ctx context.Context, action Action, id, version string, stage Stage) error {
+	return a.policy.Authorize(ctx, AuthzRequest{Principal: PrincipalFromContext(ctx), Action: action, ID: id, Version: version, Stage: stage})
+}
+
+// Store implements Registry.
+func (a *AuthzRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	if err := a.authorize(ctx, ActionStore, prompt.ID, prompt.Version, ""); err != nil {
+		return err
+	}
+	return a.inner.Store(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does).
+func (a *AuthzRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if err := a.authorize(ctx, ActionStore, prompt.ID, prompt.Version, ""); err != nil {
+		return err
+	}
+	return StoreIfMatch(ctx, a.inner, prompt, revision)
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time). Every prompt is authorized
+// before any is stored.
+func (a *AuthzRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	for _, p := range prompts {
+		if err := a.authorize(ctx, ActionStore, p.ID, p.Version, ""); err != nil {
+			return err
+		}
+	}
+	return StoreBatch(ctx, a.inner, prompts)
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time). Every version is
+// authorized before any is deleted.
+func (a *AuthzRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	for _, ref := range refs {
+		if err := a.authorize(ctx, ActionDelete, ref.ID, ref.Version, ""); err != nil {
+			return err
+		}
+	}
+	return DeleteBatch(ctx, a.inner, refs)
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time).
+func (a *AuthzRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	for _, ref := range refs {
+		if err := a.authorize(ctx, ActionRead, ref.ID, ref.Version, ""); err != nil {
+			return nil, err
+		}
+	}
+	return GetMany(ctx, a.inner, refs)
+}
+
+// Get implements Registry.
+func (a *AuthzRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	if err := a.authorize(ctx, ActionRead, id, version, ""); err != nil {
+		return nil, err
+	}
+	return a.inner.Get(ctx, id, version)
+}
+
+// GetProduction implements Registry.
+func (a *AuthzRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	if err := a.authorize(ctx, ActionRead, id, "", ""); err != nil {
+		return nil, err
+	}
+	return a.inner.GetProduction(ctx, id)
+}
+
+// List implements Registry.
+func (a *AuthzRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	if err := a.authorize(ctx, ActionRead, "", "", ""); err != nil {
+		return nil, err
+	}
+	return a.inner.List(ctx, filter)
+}
+
+// ListVersions implements Registry.
+func (a *AuthzRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	if err := a.authorize(ctx, ActionRead, id, "", ""); err != nil {
+		return nil, err
+	}
+	return a.inner.ListVersions(ctx, id)
+}
+
+// Promote implements Registry.
+func (a *AuthzRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	if err := a.authorize(ctx, ActionPromote, id, version, stage); err != nil {
+		return err
+	}
+	return a.inner.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does); it is authorized as a
+// promotion of version to production.
+func (a *AuthzRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	if err := a.authorize(ctx, ActionPromote, id, version, StageProduction); err != nil {
+		return err
+	}
+	return RollbackProduction(ctx, a.inner, id, from, version)
+}
+
+// Delete implements Registry.
+func (a *AuthzRegistry) Delete(ctx context.Context, id, version string) error {
+	if err := a.authorize(ctx, ActionDelete, id, version, ""); err != nil {
+		return err
+	}
+	return a.inner.Delete(ctx, id, version)
+}
+
+// Tag implements Registry.
+func (a *AuthzRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	if err := a.authorize(ctx, ActionTag, id, version, ""); err != nil {
+		return err
+	}
+	return a.inner.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry.
+func (a *AuthzRegistry) Archive(ctx context.Context, id, version string) error {
+	if err := a.authorize(ctx, ActionArchive, id, version, ""); err != nil {
+		return err
+	}
+	return a.inner.Archive(ctx, id, version)
+}
+
+// Restore implements Registry.
+func (a *AuthzRegistry) Restore(ctx context.Context, id, version string) error {
+	if err := a.authorize(ctx, ActionArchive, id, version, ""); err != nil {
+		return err
+	}
+	return a.inner.Restore(ctx, id, version)
+}
+
+// SetAlias implements Aliaser (if inner does). Environment production pointers (see PromoteFor)
+// are authorized as promotions to StageProduction.
+func (a *AuthzRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	action, stage := ActionAlias, Stage("")
+	if _, ok := ParseEnvironmentAlias(alias); ok {
+		action, stage = ActionPromote, StageProduction
+	}
+	if err := a.authorize(ctx, action, id, version, stage); err != nil {
+		return err
+	}
+	return SetAlias(ctx, a.inner, id, alias, version)
+}
+
+// Aliases implements Aliaser (if inner does).
+func (a *AuthzRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	if err := a.authorize(ctx, ActionRead, id, "", ""); err != nil {
+		return nil, err
+	}
+	return Aliases(ctx, a.inner, id)
+}
+
+// History implements Auditor (if inner does).
+func (a *AuthzRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	if err := a.authorize(ctx, ActionRead, id, "", ""); err != nil {
+		return nil, err
+	}
+	return History(ctx, a.inner, id)
+}
+
+// Usage implements UsageReporter (if inner does).
+func (a *AuthzRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	if err := a.authorize(ctx, ActionRead, id, "", ""); err != nil {
+		return UsageStats{}, err
+	}
+	return Usage(ctx, a.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does).
+func (a *AuthzRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	if err := a.authorize(ctx, ActionRead, "", "", ""); err != nil {
+		return nil, err
+	}
+	return ListUsage(ctx, a.inner)
+}
+
+// WatchChanges implements ChangeWatcher (if inner does); it runs until ctx ends.
+func (a *AuthzRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	if err := a.authorize(ctx, ActionRead, "", "", ""); err != nil {
+		return err
+	}
+	return WatchChanges(ctx, a.inner, fn)
+}
+
+// Ensure AuthzRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*AuthzRegistry)(nil)
+	_ ConditionalStorer    = (*AuthzRegistry)(nil)
+	_ Auditor              = (*AuthzRegistry)(nil)
+	_ UsageReporter        = (*AuthzRegistry)(nil)
+	_ ChangeWatcher        = (*AuthzRegistry)(nil)
+	_ Aliaser              = (*AuthzRegistry)(nil)
+	_ Batcher              = (*AuthzRegistry)(nil)
+	_ ProductionRollbacker = (*AuthzRegistry)(nil)
+	_ AuthzPolicy          = RolePolicy{}
+)
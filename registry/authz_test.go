@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthzRegistry(t *testing.T) {
+	ctx := context.Background()
+	reg := WithAuthz(NewMemoryRegistry(), RolePolicy{
+		Roles:   map[string]Role{"alice": RoleReleaser, "bob": RoleEditor},
+		Default: RoleReader,
+	})
+	alice, bob, eve := WithPrincipal(ctx, "alice"), WithPrincipal(ctx, "bob"), WithPrincipal(ctx, "eve")
+
+	require.NoError(t, reg.Store(bob, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, reg.Tag(bob, "p", "1.0.0", []string{"reviewed"}))
+	require.NoError(t, reg.Promote(bob, "p", "1.0.0", StageStaging))
+	err := reg.Promote(bob, "p", "1.0.0", StageProduction)
+	require.ErrorIs(t, err, ErrForbidden)
+	assert.Equal(t, "forbidden for this scope: bob may not promote p@1.0.0 to production", err.Error())
+	assert.ErrorIs(t, PromoteFor(bob, reg, "p", "1.0.0", "eu-prod"), ErrForbidden, "environment pointers need a releaser")
+	assert.ErrorIs(t, reg.Delete(bob, "p", "1.0.0"), ErrForbidden)
+	require.NoError(t, SetAlias(bob, reg, "p", "stable", "1.0.0"))
+
+	require.NoError(t, reg.Promote(alice, "p", "1.0.0", StageProduction))
+	require.NoError(t, PromoteFor(alice, reg, "p", "1.0.0", "eu-prod"))
+
+	p, err := reg.GetProduction(eve, "p")
+	require.NoError(t, err, "the default role may read")
+	assert.Equal(t, "1.0.0", p.Version)
+	_, err = reg.List(eve, Filter{})
+	require.NoError(t, err)
+	err = reg.Store(eve, &core.Prompt{ID: "p", Version: "1.1.0", Template: "v2"})
+	require.ErrorIs(t, err, ErrForbidden)
+	assert.Contains(t, err.Error(), "eve may not store p@1.1.0")
+	err = StoreBatch(bob, reg, []*core.Prompt{{ID: "q", Version: "1.0.0", Template: "q"}})
+	require.NoError(t, err)
+	assert.ErrorIs(t, DeleteBatch(bob, reg, []VersionRef{{ID: "q", Version: "1.0.0"}}), ErrForbidden)
+	_, err = reg.Get(bob, "q", "1.0.0")
+	assert.NoError(t, err, "a denied batch deletes nothing")
+
+	locked := WithAuthz(reg, RolePolicy{})
+	_, err = locked.Get(ctx, "p", "1.0.0")
+	require.ErrorIs(t, err, ErrForbidden)
+	assert.Contains(t, err.Error(), "anonymous may not read p@1.0.0")
+}
@@ -0,0 +1,93 @@
+// Package azureblob provides an Azure Blob Storage BlobStore for use with registry.NewS3Registry.
+// Use: go get github.com/Azure/azure-sdk-for-go/sdk/storage/azblob
+package azureblob
+
+import (
+	"context"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/klejdi94/loom/registry"
+)
+
+// Store implements registry.BlobStore using Azure Blob Storage.
+type Store struct {
+	client    *azblob.Client
+	container string
+	prefix    string
+}
+
+// New creates a BlobStore that uses the given Azure client, container, and blob name prefix.
+func New(client *azblob.Client, container, prefix string) *Store {
+	return &Store{client: client, container: container, prefix: prefix}
+}
+
+// NewFromConnectionString creates a BlobStore from a storage account connection string (e.g. the
+// AZURE_STORAGE_CONNECTION_STRING of the account, or of Azurite for local development).
+func NewFromConnectionString(connectionString, container, prefix string) (*Store, error) {
+	client, err := azblob.NewClientFromConnectionString(connectionString, nil)
+	if err != nil {
+		return nil, err
+	}
+	return New(client, container, prefix), nil
+}
+
+func (s *Store) fullKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + key
+}
+
+// Get implements registry.BlobStore.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.client.DownloadStream(ctx, s.container, s.fullKey(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Put implements registry.BlobStore.
+func (s *Store) Put(ctx context.Context, key string, body []byte) error {
+	_, err := s.client.UploadBuffer(ctx, s.container, s.fullKey(key), body, nil)
+	return err
+}
+
+// List implements registry.BlobStore. Returns blob names (with prefix stripped if using prefix).
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	fullPrefix := s.fullKey(prefix)
+	var keys []string
+	pager := s.client.NewListBlobsFlatPager(s.container, &azblob.ListBlobsFlatOptions{Prefix: &fullPrefix})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+			k := *item.Name
+			if s.prefix != "" && len(k) >= len(s.prefix) {
+				k = k[len(s.prefix):]
+			}
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// Delete implements registry.BlobStore. Like S3, deleting a blob that does not exist succeeds.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteBlob(ctx, s.container, s.fullKey(key), nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Ensure Store implements registry.BlobStore at compile time.
+var _ registry.BlobStore = (*Store)(nil)
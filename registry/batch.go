@@ -0,0 +1,152 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrInvalidBatch is returned by batch operations for batches that ValidateBatch or ValidateRefs reject.
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// VersionRef identifies one version of a prompt in batch operations.
+type VersionRef struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
+// Batcher is implemented by registries that read and write many versions in a few round trips,
+// for migrations and seeding.
+//
+// StoreBatch stores every prompt like Store and sets each prompt's Revision. DeleteBatch deletes
+// every version like Delete and returns core.ErrPromptNotFound if one does not exist. Memory,
+// Postgres, and Redis apply a batch entirely or not at all, DynamoDB in transactions of up to 50
+// versions; other backends may apply part of a batch that fails.
+//
+// GetMany returns one prompt per ref, in order, with nil for versions that do not exist or are
+// archived. Refs name exact versions; aliases are not resolved.
+type Batcher interface {
+	StoreBatch(ctx context.Context, prompts []*core.Prompt) error
+	DeleteBatch(ctx context.Context, refs []VersionRef) error
+	GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error)
+}
+
+// StoreBatch stores prompts via reg's StoreBatch, or one at a time with Store if reg does not
+// implement Batcher.
+func StoreBatch(ctx context.Context, reg Registry, prompts []*core.Prompt) error {
+	if b, ok := reg.(Batcher); ok {
+		return b.StoreBatch(ctx, prompts)
+	}
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	for _, p := range prompts {
+		if err := reg.Store(ctx, p); err != nil {
+			return fmt.Errorf("store %s@%s: %w", p.ID, p.Version, err)
+		}
+	}
+	return nil
+}
+
+// DeleteBatch deletes refs via reg's DeleteBatch, or one at a time with Delete if reg does not
+// implement Batcher.
+func DeleteBatch(ctx context.Context, reg Registry, refs []VersionRef) error {
+	if b, ok := reg.(Batcher); ok {
+		return b.DeleteBatch(ctx, refs)
+	}
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := reg.Delete(ctx, ref.ID, ref.Version); err != nil {
+			return fmt.Errorf("delete %s@%s: %w", ref.ID, ref.Version, err)
+		}
+	}
+	return nil
+}
+
+// GetMany returns the prompts for refs via reg's GetMany, or one at a time with Get if reg does not
+// implement Batcher.
+func GetMany(ctx context.Context, reg Registry, refs []VersionRef) ([]*core.Prompt, error) {
+	if b, ok := reg.(Batcher); ok {
+		return b.GetMany(ctx, refs)
+	}
+	out := make([]*core.Prompt, len(refs))
+	for i, ref := range refs {
+		if _, ok := ParseAlias(ref.Version); ok {
+			continue
+		}
+		p, err := reg.Get(ctx, ref.ID, ref.Version)
+		if errors.Is(err, core.ErrPromptNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[i] = p
+	}
+	return out, nil
+}
+
+// ValidateBatch checks that every prompt is non-nil, has an id and version, and appears once.
+func ValidateBatch(prompts []*core.Prompt) error {
+	seen := make(map[VersionRef]bool, len(prompts))
+	for i, p := range prompts {
+		if p == nil {
+			return fmt.Errorf("%w: prompt %d is nil", ErrInvalidBatch, i)
+		}
+		ref := VersionRef{ID: p.ID, Version: p.Version}
+		if err := validateRef(ref, i, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRefs checks that every ref has an id and version and appears once.
+func ValidateRefs(refs []VersionRef) error {
+	seen := make(map[VersionRef]bool, len(refs))
+	for i, ref := range refs {
+		if err := validateRef(ref, i, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRef(ref VersionRef, i int, seen map[VersionRef]bool) error {
+	if ref.ID == "" || ref.Version == "" {
+		return fmt.Errorf("%w: item %d: id and version required", ErrInvalidBatch, i)
+	}
+	if seen[ref] {
+		return fmt.Errorf("%w: %s@%s appears more than once", ErrInvalidBatch, ref.ID, ref.Version)
+	}
+	seen[ref] = true
+	return nil
+}
+
+// refIDs returns the distinct ids of refs in order of first appearance.
+func refIDs(refs []VersionRef) []string {
+	seen := make(map[string]bool, len(refs))
+	var ids []string
+	for _, ref := range refs {
+		if !seen[ref.ID] {
+			seen[ref.ID] = true
+			ids = append(ids, ref.ID)
+		}
+	}
+	return ids
+}
+
+// promptRefs returns the refs of the non-nil prompts.
+func promptRefs(prompts []*core.Prompt) []VersionRef {
+	refs := make([]VersionRef, 0, len(prompts))
+	for _, p := range prompts {
+		if p != nil {
+			refs = append(refs, VersionRef{ID: p.ID, Version: p.Version})
+		}
+	}
+	return refs
+}
@@ -0,0 +1,336 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// CachedRegistry wraps a Registry and caches Get and GetProduction results in memory.
+// Entries older than the TTL are served stale while a single background refresh runs
+// (stale-while-revalidate). Writes made through the cache invalidate affected entries;
+// use Invalidate or InvalidateAll when the backend is changed by another process.
+type CachedRegistry struct {
+	inner    Registry
+	ttl      time.Duration
+	maxStale time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cachedEntry
+}
+
+type cachedEntry struct {
+	id         string
+	prompt     *core.Prompt
+	fetched    time.Time
+	refreshing bool
+}
+
+// CachedOption configures a CachedRegistry.
+type CachedOption func(*CachedRegistry)
+
+// WithMaxStale bounds how long past the TTL a stale entry may be served while refreshing.
+// Older entries are fetched synchronously. Zero (default) serves stale entries indefinitely
+// until a refresh succeeds, which keeps reads working while the backend is down.
+func WithMaxStale(d time.Duration) CachedOption {
+	return func(c *CachedRegistry) {
+		c.maxStale = d
+	}
+}
+
+// NewCached returns a caching decorator for inner. ttl is how long an entry is served without refreshing.
+func NewCached(inner Registry, ttl time.Duration, opts ...CachedOption) *CachedRegistry {
+	c := &CachedRegistry{
+		inner:   inner,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*cachedEntry),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+func versionCacheKey(id, version string) string { return "v:" + id + ":" + version }
+func productionCacheKey(id string) string       { return "p:" + id }
+
+// Get implements Registry, serving from cache when possible.
+func (c *CachedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	return c.get(ctx, versionCacheKey(id, version), id, func(ctx context.Context) (*core.Prompt, error) {
+		return c.inner.Get(ctx, id, version)
+	})
+}
+
+// GetProduction implements Registry, serving from cache when possible.
+func (c *CachedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	return c.get(ctx, productionCacheKey(id), id, func(ctx context.Context) (*core.Prompt, error) {
+		return c.inner.GetProduction(ctx, id)
+	})
+}
+
+func (c *CachedRegistry) get(ctx context.Context, key, id string, fetch func(context.Context) (*core.Prompt, error)) (*core.Prompt, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		age := c.now().Sub(e.fetched)
+		if age < c.ttl {
+			p := copyPrompt(e.prompt)
+			c.mu.Unlock()
+			return p, nil
+		}
+		if c.maxStale == 0 || age < c.ttl+c.maxStale {
+			if !e.refreshing {
+				e.refreshing = true
+				go c.refresh(context.WithoutCancel(ctx), key, e, fetch)
+			}
+			p := copyPrompt(e.prompt)
+			c.mu.Unlock()
+			return p, nil
+		}
+	}
+	c.mu.Unlock()
+	p, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, id, p)
+	return copyPrompt(p), nil
+}
+
+// refresh re-fetches a stale entry. On error the stale entry is kept (and retried on a later read);
+// a not-found result drops it.
+func (c *CachedRegistry) refresh(ctx context.Context, key string, e *cachedEntry, fetch func(context.Context) (*core.Prompt, error)) {
+	p, err := fetch(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.refreshing = false
+	if c.entries[key] != e {
+		return // invalidated or replaced meanwhile
+	}
+	switch {
+	case err == nil:
+		c.entries[key] = &cachedEntry{id: e.id, prompt: copyPrompt(p), fetched: c.now()}
+	case errors.Is(err, core.ErrPromptNotFound):
+		delete(c.entries, key)
+	}
+}
+
+func (c *CachedRegistry) put(key, id string, p *core.Prompt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = &cachedEntry{id: id, prompt: copyPrompt(p), fetched: c.now()}
+}
+
+// Invalidate drops cached entries for id. If version is empty, all versions and the production
+// entry for id are dropped; otherwise that version, the production entry, and every read by
+// alias ("@latest", "@stable", environment aliases) are dropped, since any of them may now point
+// elsewhere.
+func (c *CachedRegistry) Invalidate(id, version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, productionCacheKey(id))
+	if version != "" {
+		delete(c.entries, versionCacheKey(id, version))
+	}
+	selectors := versionCacheKey(id, "@")
+	for k, e := range c.entries {
+		if e.id == id && (version == "" || strings.HasPrefix(k, selectors)) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// InvalidateAll empties the cache.
+func (c *CachedRegistry) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*cachedEntry)
+}
+
+// Store implements Registry and invalidates the stored version.
+func (c *CachedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	if err := c.inner.Store(ctx, prompt); err != nil {
+		return err
+	}
+	c.Invalidate(prompt.ID, prompt.Version)
+	return nil
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does) and invalidates the stored version.
+func (c *CachedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if err := StoreIfMatch(ctx, c.inner, prompt, revision); err != nil {
+		return err
+	}
+	c.Invalidate(prompt.ID, prompt.Version)
+	return nil
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time) and invalidates the stored
+// versions, also if the batch fails.
+func (c *CachedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	err := StoreBatch(ctx, c.inner, prompts)
+	for _, p := range prompts {
+		if p != nil {
+			c.Invalidate(p.ID, p.Version)
+		}
+	}
+	return err
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time) and invalidates the
+// deleted versions, also if the batch fails.
+func (c *CachedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	err := DeleteBatch(ctx, c.inner, refs)
+	for _, ref := range refs {
+		c.Invalidate(ref.ID, ref.Version)
+	}
+	return err
+}
+
+// GetMany implements Batcher. Fresh cached versions are served from the cache and the rest are
+// read from inner in one batch and cached.
+func (c *CachedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	out := make([]*core.Prompt, len(refs))
+	var missing []VersionRef
+	var missingAt []int
+	c.mu.Lock()
+	for i, ref := range refs {
+		if e, ok := c.entries[versionCacheKey(ref.ID, ref.Version)]; ok && c.now().Sub(e.fetched) < c.ttl {
+			out[i] = copyPrompt(e.prompt)
+			continue
+		}
+		missing = append(missing, ref)
+		missingAt = append(missingAt, i)
+	}
+	c.mu.Unlock()
+	if len(missing) == 0 {
+		return out, nil
+	}
+	fetched, err := GetMany(ctx, c.inner, missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, p := range fetched {
+		if p == nil {
+			continue
+		}
+		c.put(versionCacheKey(missing[j].ID, missing[j].Version), missing[j].ID, p)
+		out[missingAt[j]] = copyPrompt(p)
+	}
+	return out, nil
+}
+
+// List implements Registry (not cached).
+func (c *CachedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	return c.inner.List(ctx, filter)
+}
+
+// ListVersions implements Registry (not cached).
+func (c *CachedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	return c.inner.ListVersions(ctx, id)
+}
+
+// Promote implements Registry and invalidates the production entry for id.
+func (c *CachedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	if err := c.inner.Promote(ctx, id, version, stage); err != nil {
+		return err
+	}
+	c.Invalidate(id, version)
+	return nil
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does) and invalidates version.
+func (c *CachedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	if err := RollbackProduction(ctx, c.inner, id, from, version); err != nil {
+		return err
+	}
+	c.Invalidate(id, version)
+	return nil
+}
+
+// Delete implements Registry and invalidates the deleted version.
+func (c *CachedRegistry) Delete(ctx context.Context, id, version string) error {
+	if err := c.inner.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	c.Invalidate(id, version)
+	return nil
+}
+
+// Tag implements Registry.
+func (c *CachedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	return c.inner.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry and invalidates the archived version.
+func (c *CachedRegistry) Archive(ctx context.Context, id, version string) error {
+	if err := c.inner.Archive(ctx, id, version); err != nil {
+		return err
+	}
+	c.Invalidate(id, version)
+	return nil
+}
+
+// Restore implements Registry and invalidates the restored version.
+func (c *CachedRegistry) Restore(ctx context.Context, id, version string) error {
+	if err := c.inner.Restore(ctx, id, version); err != nil {
+		return err
+	}
+	c.Invalidate(id, version)
+	return nil
+}
+
+// History implements Auditor (if inner does; not cached).
+func (c *CachedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	return History(ctx, c.inner, id)
+}
+
+// Usage implements UsageReporter (if inner does; reads served from the cache are not seen by inner).
+func (c *CachedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, c.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does; reads served from the cache are not seen by inner).
+func (c *CachedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, c.inner)
+}
+
+// SetAlias implements Aliaser (if inner does) and invalidates id, including cached "@alias" reads.
+func (c *CachedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if err := SetAlias(ctx, c.inner, id, alias, version); err != nil {
+		return err
+	}
+	c.Invalidate(id, "")
+	return nil
+}
+
+// Aliases implements Aliaser (if inner does; not cached).
+func (c *CachedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return Aliases(ctx, c.inner, id)
+}
+
+// WatchChanges implements ChangeWatcher (if inner does), invalidating each changed id before calling fn.
+func (c *CachedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, c.inner, func(id string) {
+		c.Invalidate(id, "")
+		fn(id)
+	})
+}
+
+// Ensure CachedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*CachedRegistry)(nil)
+	_ ConditionalStorer    = (*CachedRegistry)(nil)
+	_ Auditor              = (*CachedRegistry)(nil)
+	_ UsageReporter        = (*CachedRegistry)(nil)
+	_ ChangeWatcher        = (*CachedRegistry)(nil)
+	_ Aliaser              = (*CachedRegistry)(nil)
+	_ Batcher              = (*CachedRegistry)(nil)
+	_ ProductionRollbacker = (*CachedRegistry)(nil)
+)
@@ -0,0 +1,165 @@
+package registry
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRegistry counts Get/GetProduction calls reaching the backend.
+type countingRegistry struct {
+	*MemoryRegistry
+	gets atomic.Int64
+}
+
+func (c *countingRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	c.gets.Add(1)
+	return c.MemoryRegistry.Get(ctx, id, version)
+}
+
+func (c *countingRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	c.gets.Add(1)
+	return c.MemoryRegistry.GetProduction(ctx, id)
+}
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.t
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.t = f.t.Add(d)
+}
+
+func newCachedForTest(t *testing.T) (*CachedRegistry, *countingRegistry, *fakeClock) {
+	t.Helper()
+	inner := &countingRegistry{MemoryRegistry: NewMemoryRegistry()}
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	c := NewCached(inner, time.Minute)
+	c.now = clock.Now
+	require.NoError(t, c.Store(context.Background(), &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, c.Promote(context.Background(), "p", "1.0.0", StageProduction))
+	return c, inner, clock
+}
+
+func TestCachedRegistry_ServesFromCache(t *testing.T) {
+	ctx := context.Background()
+	c, inner, _ := newCachedForTest(t)
+	for i := 0; i < 5; i++ {
+		p, err := c.GetProduction(ctx, "p")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", p.Template)
+	}
+	assert.Equal(t, int64(1), inner.gets.Load())
+}
+
+func TestCachedRegistry_StaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	c, inner, clock := newCachedForTest(t)
+	_, err := c.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+
+	// Change the backend directly so the cache does not see the write.
+	require.NoError(t, inner.MemoryRegistry.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v2"}))
+	clock.Advance(2 * time.Minute)
+
+	p, err := c.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", p.Template, "stale value served while refreshing")
+	assert.Eventually(t, func() bool {
+		p, _ := c.Get(ctx, "p", "1.0.0")
+		return p.Template == "v2"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestCachedRegistry_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	c, inner, _ := newCachedForTest(t)
+	_, err := c.GetProduction(ctx, "p")
+	require.NoError(t, err)
+
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v2"}))
+	require.NoError(t, c.Promote(ctx, "p", "2.0.0", StageProduction))
+	p, err := c.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", p.Version, "promote through the cache invalidates production")
+
+	require.NoError(t, inner.MemoryRegistry.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v3"}))
+	c.Invalidate("p", "")
+	p, err = c.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "v3", p.Template)
+
+	require.NoError(t, c.Delete(ctx, "p", "2.0.0"))
+	_, err = c.Get(ctx, "p", "2.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+}
+
+func TestCachedRegistry_InvalidatesAliasReads(t *testing.T) {
+	ctx := context.Background()
+	c, _, _ := newCachedForTest(t)
+	require.NoError(t, c.SetAlias(ctx, "p", "stable", "1.0.0"))
+	require.NoError(t, c.SetAlias(ctx, "p", EnvironmentAlias("eu"), "1.0.0"))
+	for _, sel := range []string{"@latest", "@stable", "@" + EnvironmentAlias("eu")} {
+		p, err := c.Get(ctx, "p", sel)
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.0", p.Version, sel)
+	}
+
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.1", Template: "v1.0.1"}))
+	p, err := c.Get(ctx, "p", "@latest")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.1", p.Version, "a store drops cached @latest")
+
+	require.NoError(t, c.Archive(ctx, "p", "1.0.0"))
+	for _, sel := range []string{"@stable", "@" + EnvironmentAlias("eu")} {
+		_, err := c.Get(ctx, "p", sel)
+		assert.ErrorIs(t, err, core.ErrPromptNotFound, "%s points at an archived version", sel)
+	}
+}
+
+func TestCachedRegistry_Batch(t *testing.T) {
+	ctx := context.Background()
+	c, inner, clock := newCachedForTest(t)
+	_, err := c.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+
+	require.NoError(t, c.StoreBatch(ctx, []*core.Prompt{
+		{ID: "p", Version: "1.0.0", Template: "v1b"},
+		{ID: "p", Version: "2.0.0", Template: "v2"},
+	}))
+	refs := []VersionRef{{ID: "p", Version: "1.0.0"}, {ID: "p", Version: "2.0.0"}, {ID: "p", Version: "3.0.0"}}
+	got, err := c.GetMany(ctx, refs)
+	require.NoError(t, err)
+	assert.Equal(t, "v1b", got[0].Template, "StoreBatch invalidates")
+	assert.Equal(t, "v2", got[1].Template)
+	assert.Nil(t, got[2])
+
+	require.NoError(t, inner.MemoryRegistry.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "changed"}))
+	got, err = c.GetMany(ctx, refs)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", got[1].Template, "served from cache")
+	clock.Advance(2 * time.Minute)
+	got, err = c.GetMany(ctx, refs)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", got[1].Template, "expired entries are read again")
+
+	require.NoError(t, c.DeleteBatch(ctx, refs[:2]))
+	got, err = c.GetMany(ctx, refs)
+	require.NoError(t, err)
+	assert.Equal(t, []*core.Prompt{nil, nil, nil}, got)
+}
@@ -0,0 +1,443 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ChainedRegistry reads from a fast local backend (memory, file) and falls back to a remote
+// backend (HTTP, Postgres, ...) when the local one misses or fails. Prompts found remotely are
+// copied into the local backend so later reads, including while the remote is unreachable, are
+// served locally. Writes go to the remote, which is the source of truth; with WithWriteThrough
+// they are applied to the local backend as well.
+//
+// Local copies are not refreshed automatically: call Sync to pull the remote state (for example
+// on startup and periodically) so promotions made elsewhere become visible.
+type ChainedRegistry struct {
+	local        Registry
+	remote       Registry
+	writeThrough bool
+}
+
+// ChainedOption configures a ChainedRegistry.
+type ChainedOption func(*ChainedRegistry)
+
+// WithWriteThrough applies successful remote writes (Store, Promote, Delete, Tag) to the local backend too.
+func WithWriteThrough() ChainedOption {
+	return func(c *ChainedRegistry) {
+		c.writeThrough = true
+	}
+}
+
+// NewChained returns a registry that reads local first and falls back to remote.
+func NewChained(local, remote Registry, opts ...ChainedOption) *ChainedRegistry {
+	c := &ChainedRegistry{local: local, remote: remote}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Get implements Registry. A local miss is fetched from the remote and copied locally. Aliases
+// ("@stable") can move, so they are resolved by the remote first and locally only if it fails.
+func (c *ChainedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	if alias, ok := ParseAlias(version); ok {
+		return c.getAlias(ctx, id, alias)
+	}
+	if p, err := c.local.Get(ctx, id, version); err == nil {
+		return p, nil
+	}
+	p, err := c.remote.Get(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	_ = c.local.Store(ctx, p.Copy())
+	return p, nil
+}
+
+func (c *ChainedRegistry) getAlias(ctx context.Context, id, alias string) (*core.Prompt, error) {
+	p, err := c.remote.Get(ctx, id, "@"+alias)
+	if err == nil {
+		if err := c.local.Store(ctx, p.Copy()); err == nil {
+			_ = SetAlias(ctx, c.local, id, alias, p.Version)
+		}
+		return p, nil
+	}
+	if errors.Is(err, core.ErrPromptNotFound) {
+		return nil, err
+	}
+	if lp, lerr := c.local.Get(ctx, id, "@"+alias); lerr == nil {
+		return lp, nil
+	}
+	return nil, err
+}
+
+// GetProduction implements Registry. A local miss is fetched from the remote, copied locally,
+// and promoted to production there.
+func (c *ChainedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	if p, err := c.local.GetProduction(ctx, id); err == nil {
+		return p, nil
+	}
+	p, err := c.remote.GetProduction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.local.Store(ctx, p.Copy()); err == nil {
+		_ = c.local.Promote(ctx, p.ID, p.Version, StageProduction)
+	}
+	return p, nil
+}
+
+// List implements Registry. It lists the remote, falling back to the local backend if the remote fails.
+func (c *ChainedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	out, err := c.remote.List(ctx, filter)
+	if err == nil {
+		return out, nil
+	}
+	if local, lerr := c.local.List(ctx, filter); lerr == nil {
+		return local, nil
+	}
+	return nil, err
+}
+
+// ListVersions implements Registry. It lists the remote, falling back to the local backend if the remote fails.
+func (c *ChainedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	out, err := c.remote.ListVersions(ctx, id)
+	if err == nil {
+		return out, nil
+	}
+	if local, lerr := c.local.ListVersions(ctx, id); lerr == nil {
+		return local, nil
+	}
+	return nil, err
+}
+
+// Store implements Registry.
+func (c *ChainedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	if err := c.remote.Store(ctx, prompt); err != nil {
+		return err
+	}
+	return c.through(func() error { return c.local.Store(ctx, prompt.Copy()) })
+}
+
+// StoreIfMatch implements ConditionalStorer against the remote (which must support it).
+// Prompts served from the local backend carry its revisions, so take revision from a remote read.
+func (c *ChainedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if err := StoreIfMatch(ctx, c.remote, prompt, revision); err != nil {
+		return err
+	}
+	return c.through(func() error { return c.local.Store(ctx, prompt.Copy()) })
+}
+
+// StoreBatch implements Batcher against the remote (via its StoreBatch, or one Store at a time).
+func (c *ChainedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := StoreBatch(ctx, c.remote, prompts); err != nil {
+		return err
+	}
+	return c.through(func() error { return StoreBatch(ctx, c.local, copyPrompts(prompts)) })
+}
+
+// DeleteBatch implements Batcher against the remote (via its DeleteBatch, or one Delete at a time).
+// With write-through, versions missing locally are skipped.
+func (c *ChainedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := DeleteBatch(ctx, c.remote, refs); err != nil {
+		return err
+	}
+	return c.through(func() error {
+		for _, ref := range refs {
+			if err := c.local.Delete(ctx, ref.ID, ref.Version); err != nil && !errors.Is(err, core.ErrPromptNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetMany implements Batcher. Versions missing locally (or all of them, if the local read fails)
+// are read from the remote in one batch and copied locally.
+func (c *ChainedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	out, err := GetMany(ctx, c.local, refs)
+	if err != nil {
+		out = make([]*core.Prompt, len(refs))
+	}
+	var missing []VersionRef
+	var missingAt []int
+	for i, p := range out {
+		if p == nil {
+			missing = append(missing, refs[i])
+			missingAt = append(missingAt, i)
+		}
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+	fetched, err := GetMany(ctx, c.remote, missing)
+	if err != nil {
+		return nil, err
+	}
+	var found []*core.Prompt
+	for j, p := range fetched {
+		if p != nil {
+			out[missingAt[j]] = p
+			found = append(found, p.Copy())
+		}
+	}
+	if len(found) > 0 {
+		_ = StoreBatch(ctx, c.local, found)
+	}
+	return out, nil
+}
+
+func copyPrompts(prompts []*core.Prompt) []*core.Prompt {
+	out := make([]*core.Prompt, len(prompts))
+	for i, p := range prompts {
+		out[i] = p.Copy()
+	}
+	return out
+}
+
+// Promote implements Registry. With write-through, a version missing locally is copied from the remote first.
+func (c *ChainedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	if err := c.remote.Promote(ctx, id, version, stage); err != nil {
+		return err
+	}
+	return c.through(func() error { return c.promoteLocal(ctx, id, version, stage) })
+}
+
+// promoteLocal promotes version in the local backend, copying it from the remote if it is missing.
+func (c *ChainedRegistry) promoteLocal(ctx context.Context, id, version string, stage Stage) error {
+	err := c.local.Promote(ctx, id, version, stage)
+	if !errors.Is(err, core.ErrPromptNotFound) {
+		return err
+	}
+	p, err := c.remote.Get(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	if err := c.local.Store(ctx, p.Copy()); err != nil {
+		return err
+	}
+	return c.local.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker against the remote (which must support it),
+// then promotes version locally.
+func (c *ChainedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	if err := RollbackProduction(ctx, c.remote, id, from, version); err != nil {
+		return err
+	}
+	return c.through(func() error { return c.promoteLocal(ctx, id, version, StageProduction) })
+}
+
+// Delete implements Registry.
+func (c *ChainedRegistry) Delete(ctx context.Context, id, version string) error {
+	if err := c.remote.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	return c.through(func() error {
+		if err := c.local.Delete(ctx, id, version); err != nil && !errors.Is(err, core.ErrPromptNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Tag implements Registry.
+func (c *ChainedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	if err := c.remote.Tag(ctx, id, version, tags); err != nil {
+		return err
+	}
+	return c.through(func() error {
+		if err := c.local.Tag(ctx, id, version, tags); err != nil && !errors.Is(err, core.ErrPromptNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Archive implements Registry.
+func (c *ChainedRegistry) Archive(ctx context.Context, id, version string) error {
+	if err := c.remote.Archive(ctx, id, version); err != nil {
+		return err
+	}
+	return c.through(func() error {
+		if err := c.local.Archive(ctx, id, version); err != nil && !errors.Is(err, core.ErrPromptNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Restore implements Registry.
+func (c *ChainedRegistry) Restore(ctx context.Context, id, version string) error {
+	if err := c.remote.Restore(ctx, id, version); err != nil {
+		return err
+	}
+	return c.through(func() error {
+		if err := c.local.Restore(ctx, id, version); err != nil && !errors.Is(err, core.ErrPromptNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// SetAlias implements Aliaser against the remote (which must support it). With write-through, a
+// version missing locally is copied from the remote first; a local backend without alias support is skipped.
+func (c *ChainedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if err := SetAlias(ctx, c.remote, id, alias, version); err != nil {
+		return err
+	}
+	if _, ok := c.local.(Aliaser); !ok {
+		return nil
+	}
+	return c.through(func() error {
+		err := SetAlias(ctx, c.local, id, alias, version)
+		if !errors.Is(err, core.ErrPromptNotFound) {
+			return err
+		}
+		p, gerr := c.remote.Get(ctx, id, version)
+		if gerr != nil {
+			return gerr
+		}
+		if err := c.local.Store(ctx, p.Copy()); err != nil {
+			return err
+		}
+		return SetAlias(ctx, c.local, id, alias, version)
+	})
+}
+
+// Aliases implements Aliaser from the remote, falling back to the local backend if the remote fails.
+func (c *ChainedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	out, err := Aliases(ctx, c.remote, id)
+	if err == nil {
+		return out, nil
+	}
+	if local, lerr := Aliases(ctx, c.local, id); lerr == nil {
+		return local, nil
+	}
+	return nil, err
+}
+
+// History implements Auditor from the remote, which records every write.
+func (c *ChainedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	return History(ctx, c.remote, id)
+}
+
+// Usage implements UsageReporter (if remote does; reads served locally are not seen by the remote).
+func (c *ChainedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, c.remote, id)
+}
+
+// ListUsage implements UsageReporter (if remote does; reads served locally are not seen by the remote).
+func (c *ChainedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, c.remote)
+}
+
+// WatchChanges implements ChangeWatcher (if remote does).
+func (c *ChainedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, c.remote, fn)
+}
+
+// through applies a local write when write-through is enabled. The remote write has already
+// succeeded, so a local failure is reported but leaves the remote change in place.
+func (c *ChainedRegistry) through(fn func() error) error {
+	if !c.writeThrough {
+		return nil
+	}
+	if err := fn(); err != nil {
+		return fmt.Errorf("chained registry: remote write succeeded, local write failed: %w", err)
+	}
+	return nil
+}
+
+// Sync copies every prompt version from the remote into the local backend, along with its
+// stage, tags, and archived state, and the aliases if both backends support them. Call it on startup and periodically to pick up changes made elsewhere.
+func (c *ChainedRegistry) Sync(ctx context.Context) error {
+	seen := make(map[string]bool)
+	err := eachPage(ctx, c.remote, Filter{Limit: 1000, IncludeArchived: true}, func(prompts []*core.Prompt) (bool, error) {
+		for _, p := range prompts {
+			seen[p.ID] = true
+		}
+		return false, StoreBatch(ctx, c.local, copyPrompts(prompts))
+	})
+	if err != nil {
+		return fmt.Errorf("chained registry sync: %w", err)
+	}
+	for id := range seen {
+		infos, err := c.remote.ListVersions(ctx, id)
+		if err != nil {
+			return fmt.Errorf("chained registry sync %s: %w", id, err)
+		}
+		for _, info := range infos {
+			if info.Stage != "" {
+				if err := c.local.Promote(ctx, id, info.Version, info.Stage); err != nil {
+					return fmt.Errorf("chained registry sync %s@%s: %w", id, info.Version, err)
+				}
+			}
+			if len(info.Tags) > 0 {
+				if err := c.local.Tag(ctx, id, info.Version, info.Tags); err != nil {
+					return fmt.Errorf("chained registry sync %s@%s: %w", id, info.Version, err)
+				}
+			}
+			setArchived := c.local.Restore
+			if info.Archived {
+				setArchived = c.local.Archive
+			}
+			if err := setArchived(ctx, id, info.Version); err != nil {
+				return fmt.Errorf("chained registry sync %s@%s: %w", id, info.Version, err)
+			}
+		}
+		if err := c.syncAliases(ctx, id); err != nil {
+			return fmt.Errorf("chained registry sync %s aliases: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// syncAliases makes the local aliases of id match the remote ones.
+func (c *ChainedRegistry) syncAliases(ctx context.Context, id string) error {
+	_, remoteOK := c.remote.(Aliaser)
+	_, localOK := c.local.(Aliaser)
+	if !remoteOK || !localOK {
+		return nil
+	}
+	remote, err := Aliases(ctx, c.remote, id)
+	if err != nil {
+		return err
+	}
+	local, err := Aliases(ctx, c.local, id)
+	if err != nil {
+		return err
+	}
+	for alias := range local {
+		if _, ok := remote[alias]; !ok {
+			if err := SetAlias(ctx, c.local, id, alias, ""); err != nil {
+				return err
+			}
+		}
+	}
+	for alias, version := range remote {
+		if local[alias] == version {
+			continue
+		}
+		if err := SetAlias(ctx, c.local, id, alias, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ensure ChainedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*ChainedRegistry)(nil)
+	_ ConditionalStorer    = (*ChainedRegistry)(nil)
+	_ Auditor              = (*ChainedRegistry)(nil)
+	_ UsageReporter        = (*ChainedRegistry)(nil)
+	_ ChangeWatcher        = (*ChainedRegistry)(nil)
+	_ Aliaser              = (*ChainedRegistry)(nil)
+	_ Batcher              = (*ChainedRegistry)(nil)
+	_ ProductionRollbacker = (*ChainedRegistry)(nil)
+)
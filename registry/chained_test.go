@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyRegistry fails every call while down is set.
+type flakyRegistry struct {
+	*MemoryRegistry
+	down bool
+}
+
+var errDown = errors.New("remote unavailable")
+
+func (f *flakyRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	if f.down {
+		return nil, errDown
+	}
+	return f.MemoryRegistry.Get(ctx, id, version)
+}
+
+func (f *flakyRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	if f.down {
+		return nil, errDown
+	}
+	return f.MemoryRegistry.GetProduction(ctx, id)
+}
+
+func (f *flakyRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	if f.down {
+		return nil, errDown
+	}
+	return f.MemoryRegistry.List(ctx, filter)
+}
+
+func TestChainedRegistry_FallbackAndFill(t *testing.T) {
+	ctx := context.Background()
+	local := NewMemoryRegistry()
+	remote := &flakyRegistry{MemoryRegistry: NewMemoryRegistry()}
+	require.NoError(t, remote.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "hi"}))
+	require.NoError(t, remote.Promote(ctx, "p", "1.0.0", StageProduction))
+	c := NewChained(local, remote)
+
+	p, err := c.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "hi", p.Template)
+
+	// Served from the local copy while the remote is down.
+	remote.down = true
+	p, err = c.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", p.Version)
+	list, err := c.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	_, err = c.Get(ctx, "p", "2.0.0")
+	assert.ErrorIs(t, err, errDown)
+}
+
+func TestChainedRegistry_WriteThroughAndSync(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRegistry()
+
+	local := NewMemoryRegistry()
+	c := NewChained(local, remote, WithWriteThrough())
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, c.Promote(ctx, "p", "1.0.0", StageProduction))
+	p, err := local.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", p.Template)
+
+	// Without write-through, Sync brings the local copy up to date.
+	local2 := NewMemoryRegistry()
+	c2 := NewChained(local2, remote)
+	require.NoError(t, c2.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v2"}))
+	require.NoError(t, c2.Tag(ctx, "p", "2.0.0", []string{"new"}))
+	_, err = local2.Get(ctx, "p", "2.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	require.NoError(t, c2.Sync(ctx))
+	p, err = local2.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", p.Version)
+	infos, err := local2.ListVersions(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, []string{"new"}, infos[1].Tags)
+}
@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/klejdi94/loom/core"
+)
+
+var (
+	// ErrChecksumMismatch is returned by Verify when a stored prompt no longer matches the
+	// checksum recorded when it was stored (e.g. a prompt file edited by hand or corrupted).
+	ErrChecksumMismatch = errors.New("registry: checksum mismatch")
+	// ErrNoChecksum is returned by Verify for a version stored without a checksum, e.g. before
+	// checksums were recorded. Storing the version again records one.
+	ErrNoChecksum = errors.New("registry: no checksum recorded")
+)
+
+// Checksum returns p.Fingerprint(): the SHA-256 hash of p's content as "sha256:<hex>", covering
+// the name, description, system and user templates, messages, variables, examples, tools,
+// metadata, and output schema, but not the id and version, which decorators such as
+// NamespacedRegistry rewrite, nor Revision and the timestamps, which the registry sets. Backends
+// record it on Store and report it in VersionInfo.Checksum. Prompts without messages or an output
+// schema keep the checksum they had before those were added.
+func Checksum(p *core.Prompt) string {
+	return p.Fingerprint()
+}
+
+// Verify checks that id@version as read from reg still has the checksum recorded when it was
+// stored (VersionInfo.Checksum). It returns ErrChecksumMismatch if the content changed outside
+// the registry, ErrNoChecksum if none was recorded, and core.ErrPromptNotFound if the version
+// does not exist. Archived versions are verified too.
+func Verify(ctx context.Context, reg Registry, id, version string) error {
+	infos, err := reg.ListVersions(ctx, id)
+	if err != nil {
+		return err
+	}
+	var info *VersionInfo
+	for i := range infos {
+		if infos[i].Version == version {
+			info = &infos[i]
+		}
+	}
+	if info == nil {
+		return core.ErrPromptNotFound
+	}
+	if info.Checksum == "" {
+		return fmt.Errorf("%w for %s@%s", ErrNoChecksum, id, version)
+	}
+	p, err := reg.Get(ctx, id, version)
+	if errors.Is(err, core.ErrPromptNotFound) && info.Archived {
+		p, err = findVersion(ctx, reg, id, version)
+	}
+	if err != nil {
+		return err
+	}
+	if got := Checksum(p); got != info.Checksum {
+		return fmt.Errorf("%w: %s@%s has %s, recorded %s", ErrChecksumMismatch, id, version, got, info.Checksum)
+	}
+	return nil
+}
+
+// findVersion returns id@version from reg.List, including archived versions.
+func findVersion(ctx context.Context, reg Registry, id, version string) (*core.Prompt, error) {
+	var found *core.Prompt
+	err := eachPage(ctx, reg, Filter{IDs: []string{id}, IncludeArchived: true}, func(page []*core.Prompt) (bool, error) {
+		for _, p := range page {
+			if p.Version == version {
+				found = p
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err == nil && found == nil {
+		err = core.ErrPromptNotFound
+	}
+	return found, err
+}
@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksum(t *testing.T) {
+	a := &core.Prompt{ID: "p", Version: "1.0.0", Template: "hi", Metadata: map[string]interface{}{"a": 1, "b": "x"}}
+	b := &core.Prompt{ID: "q", Version: "2.0.0", Template: "hi", Metadata: map[string]interface{}{"b": "x", "a": 1.0}, Revision: 3}
+	assert.True(t, strings.HasPrefix(Checksum(a), "sha256:"))
+	assert.Equal(t, Checksum(a), Checksum(b), "id, version, revision, and key order are not content")
+	assert.Equal(t, Checksum(&core.Prompt{Template: "hi"}), Checksum(&core.Prompt{Template: "hi", Tools: []core.Tool{}}))
+	b.Template = "hello"
+	assert.NotEqual(t, Checksum(a), Checksum(b))
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fr, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr, "namespaced": NewNamespaced(NewMemoryRegistry())} {
+		t.Run(name, func(t *testing.T) {
+			ctx := ctx
+			if name == "namespaced" {
+				ctx = WithNamespace(ctx, "team")
+			}
+			p := &core.Prompt{ID: "p", Version: "1.0.0", Template: "hi {{.name}}", Variables: []core.Variable{{Name: "name", Required: true}}}
+			require.NoError(t, reg.Store(ctx, p))
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "1.1.0", Template: "bye"}))
+			require.NoError(t, reg.Archive(ctx, "p", "1.1.0"))
+
+			infos, err := reg.ListVersions(ctx, "p")
+			require.NoError(t, err)
+			require.Len(t, infos, 2)
+			assert.Equal(t, Checksum(p), infos[0].Checksum)
+			assert.NoError(t, Verify(ctx, reg, "p", "1.0.0"))
+			assert.NoError(t, Verify(ctx, reg, "p", "1.1.0"), "archived versions are verified")
+			assert.ErrorIs(t, Verify(ctx, reg, "p", "9.9.9"), core.ErrPromptNotFound)
+
+			require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+			require.NoError(t, reg.Tag(ctx, "p", "1.0.0", []string{"stable"}))
+			assert.NoError(t, Verify(ctx, reg, "p", "1.0.0"), "promote and tag keep the checksum")
+		})
+	}
+
+	t.Run("file edited by hand", func(t *testing.T) {
+		path := filepath.Join(dir, "p", "1.0.0", "prompt.json")
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), "hi {{.name}}", "hi {{.name}}!", 1)), 0644))
+		reopened, err := NewFileRegistry(dir)
+		require.NoError(t, err)
+		assert.ErrorIs(t, Verify(ctx, reopened, "p", "1.0.0"), ErrChecksumMismatch)
+		assert.NoError(t, Verify(ctx, reopened, "p", "1.1.0"))
+
+		// Storing the version again records the new content.
+		got, err := reopened.Get(ctx, "p", "1.0.0")
+		require.NoError(t, err)
+		require.NoError(t, reopened.Store(ctx, got))
+		assert.NoError(t, Verify(ctx, reopened, "p", "1.0.0"))
+	})
+
+	t.Run("no checksum", func(t *testing.T) {
+		path := filepath.Join(dir, "p", "1.0.0", "meta.json")
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, []byte(strings.ReplaceAll(string(data), `"checksum"`, `"old_checksum"`)), 0644))
+		reopened, err := NewFileRegistry(dir)
+		require.NoError(t, err)
+		assert.ErrorIs(t, Verify(ctx, reopened, "p", "1.0.0"), ErrNoChecksum)
+	})
+}
@@ -0,0 +1,178 @@
+package registry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klejdi94/loom/core"
+	"sigs.k8s.io/yaml"
+)
+
+// Codec encodes prompts for the registries that persist them as bytes: FileRegistry,
+// RedisRegistry, and S3Registry (see WithCodec).
+type Codec interface {
+	// Name identifies the codec in stored data; it must be unique among registered codecs.
+	Name() string
+	Marshal(p *core.Prompt) ([]byte, error)
+	Unmarshal(data []byte, p *core.Prompt) error
+}
+
+var (
+	// JSONCodec stores prompts as JSON. It is the default, and the only codec whose data has no
+	// header, so registries written before codecs existed read as JSON.
+	JSONCodec Codec = jsonCodec{}
+	// YAMLCodec stores prompts as YAML, which is easier to review by hand than JSON.
+	YAMLCodec Codec = yamlCodec{}
+)
+
+// StorageOption configures how a FileRegistry, RedisRegistry, or S3Registry persists prompts.
+type StorageOption func(*storage)
+
+// storage holds the settings shared by the registries that accept StorageOptions.
+type storage struct {
+	codec Codec
+}
+
+func newStorage(def Codec, opts []StorageOption) storage {
+	s := storage{codec: def}
+	for _, o := range opts {
+		o(&s)
+	}
+	return s
+}
+
+// WithCodec encodes newly written prompts with c instead of JSON. Every stored prompt records its
+// codec, so versions written with another registered codec stay readable and a registry can
+// switch codecs without migrating; file and key names do not change.
+func WithCodec(c Codec) StorageOption {
+	return func(s *storage) {
+		if c != nil {
+			s.codec = c
+		}
+	}
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{JSONCodec.Name(): JSONCodec, YAMLCodec.Name(): YAMLCodec}
+)
+
+// RegisterCodec makes c available to CodecByName and to registries reading data it wrote.
+// Packages providing codecs register them in init (e.g. grpcregistry.ProtoCodec).
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+// CodecByName returns the registered codec called name; "gzip+<name>" returns that codec wrapped
+// in GzipCodec.
+func CodecByName(name string) (Codec, error) {
+	if inner, ok := strings.CutPrefix(name, gzipPrefix); ok {
+		c, err := CodecByName(inner)
+		if err != nil {
+			return nil, err
+		}
+		return GzipCodec(c), nil
+	}
+	codecsMu.RLock()
+	c, ok := codecs[name]
+	codecsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("registry: unknown codec %q (is the package providing it imported?)", name)
+	}
+	return c, nil
+}
+
+// codecHeader starts every encoding except JSON's, followed by the codec name and a newline. JSON
+// documents cannot start with '#', and YAML reads the line as a comment.
+const codecHeader = "#loom-codec:"
+
+// encodePrompt encodes p with c, prefixed with c's header unless c is JSONCodec.
+func encodePrompt(c Codec, p *core.Prompt) ([]byte, error) {
+	data, err := c.Marshal(p)
+	if err != nil || c.Name() == JSONCodec.Name() {
+		return data, err
+	}
+	return append([]byte(codecHeader+c.Name()+"\n"), data...), nil
+}
+
+// decodePrompt decodes data written by encodePrompt with any registered codec.
+func decodePrompt(data []byte, p *core.Prompt) error {
+	if !bytes.HasPrefix(data, []byte(codecHeader)) {
+		return JSONCodec.Unmarshal(data, p)
+	}
+	line, rest, _ := bytes.Cut(data[len(codecHeader):], []byte("\n"))
+	c, err := CodecByName(string(line))
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(rest, p)
+}
+
+type jsonCodec struct{ indent bool }
+
+func (jsonCodec) Name() string { return "json" }
+
+func (c jsonCodec) Marshal(p *core.Prompt) ([]byte, error) {
+	if c.indent {
+		return json.MarshalIndent(p, "", "  ")
+	}
+	return json.Marshal(p)
+}
+
+func (jsonCodec) Unmarshal(data []byte, p *core.Prompt) error { return json.Unmarshal(data, p) }
+
+type yamlCodec struct{}
+
+func (yamlCodec) Name() string { return "yaml" }
+
+func (yamlCodec) Marshal(p *core.Prompt) ([]byte, error) { return yaml.Marshal(p) }
+
+func (yamlCodec) Unmarshal(data []byte, p *core.Prompt) error { return yaml.Unmarshal(data, p) }
+
+const gzipPrefix = "gzip+"
+
+// GzipCodec compresses the output of c with gzip; its name is "gzip+" and c's name. Large
+// templates and example sets typically shrink several times, e.g. GzipCodec(JSONCodec).
+func GzipCodec(c Codec) Codec {
+	return gzipCodec{inner: c}
+}
+
+type gzipCodec struct{ inner Codec }
+
+func (c gzipCodec) Name() string { return gzipPrefix + c.inner.Name() }
+
+func (c gzipCodec) Marshal(p *core.Prompt) ([]byte, error) {
+	data, err := c.inner.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gzipCodec) Unmarshal(data []byte, p *core.Prompt) error {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+	return c.inner.Unmarshal(raw, p)
+}
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecs(t *testing.T) {
+	p := &core.Prompt{
+		ID: "p", Version: "1.0.0", Name: "greeting", Template: "Hi {{.name}}",
+		Variables: []core.Variable{{Name: "name", Type: core.VariableTypeString, Required: true, Default: "you"}},
+		Examples:  []core.Example{{Input: map[string]interface{}{"name": "Ada"}, Output: "Hi Ada"}},
+		Metadata:  map[string]interface{}{"owner": "team"},
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Revision:  3,
+	}
+	for _, c := range []Codec{JSONCodec, YAMLCodec, GzipCodec(JSONCodec), GzipCodec(YAMLCodec)} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := encodePrompt(c, p)
+			require.NoError(t, err)
+			assert.Equal(t, c.Name() != "json", strings.HasPrefix(string(data), codecHeader+c.Name()+"\n"))
+			var got core.Prompt
+			require.NoError(t, decodePrompt(data, &got))
+			assert.Equal(t, p.Template, got.Template)
+			assert.Equal(t, p.Variables[0].Default, got.Variables[0].Default)
+			assert.Equal(t, p.Examples, got.Examples)
+			assert.Equal(t, p.Metadata, got.Metadata)
+			assert.True(t, p.CreatedAt.Equal(got.CreatedAt))
+			assert.Equal(t, p.Revision, got.Revision)
+			assert.Equal(t, Checksum(p), Checksum(&got))
+
+			byName, err := CodecByName(c.Name())
+			require.NoError(t, err)
+			assert.Equal(t, c.Name(), byName.Name())
+		})
+	}
+
+	_, err := CodecByName("gzip+nope")
+	assert.ErrorContains(t, err, `unknown codec "nope"`)
+	var got core.Prompt
+	assert.Error(t, decodePrompt([]byte(codecHeader+"nope\n{}"), &got))
+}
+
+func TestFileRegistryCodec(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	reg, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "old"}))
+
+	// Switching codecs leaves existing versions readable and rewrites them in the new codec.
+	reg, err = NewFileRegistry(dir, WithCodec(GzipCodec(YAMLCodec)))
+	require.NoError(t, err)
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: strings.Repeat("big ", 1000)}))
+	got, err := reg.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "old", got.Template)
+	data, err := os.ReadFile(filepath.Join(dir, "p", "2.0.0", filePrompt))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), codecHeader+"gzip+yaml\n"))
+	assert.Less(t, len(data), 1000)
+
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "new"}))
+	got, err = reg.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "new", got.Template)
+	assert.Equal(t, int64(2), got.Revision)
+	require.NoError(t, Verify(ctx, reg, "p", "2.0.0"))
+
+	reg, err = NewFileRegistry(dir)
+	require.NoError(t, err)
+	all, err := reg.List(ctx, Filter{IDs: []string{"p"}})
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
@@ -0,0 +1,29 @@
+package registry_test
+
+import (
+	"testing"
+
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/fsblob"
+	"github.com/klejdi94/loom/registry/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	backends := map[string]func(t *testing.T) registry.Registry{
+		"memory": func(t *testing.T) registry.Registry { return registry.NewMemoryRegistry() },
+		"file": func(t *testing.T) registry.Registry {
+			reg, err := registry.NewFileRegistry(t.TempDir())
+			require.NoError(t, err)
+			return reg
+		},
+		"s3": func(t *testing.T) registry.Registry {
+			store, err := fsblob.New(t.TempDir())
+			require.NoError(t, err)
+			return registry.NewS3Registry(store, "prompts/")
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) { registrytest.Run(t, open) })
+	}
+}
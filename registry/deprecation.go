@@ -0,0 +1,191 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// Deprecate marks id@version as deprecated with d (see core.Deprecation), or clears its deprecation
+// if d is nil, by storing the version again with its metadata changed. The version keeps working;
+// readers through a DeprecationWarner are told to migrate. Like any other metadata change it
+// changes the version's Checksum, so a signed version must be re-signed.
+func Deprecate(ctx context.Context, reg Registry, id, version string, d *core.Deprecation) error {
+	p, err := reg.Get(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	p = p.Copy()
+	if d == nil {
+		if _, ok := p.Metadata[core.DeprecationKey]; !ok {
+			return nil
+		}
+		delete(p.Metadata, core.DeprecationKey)
+	} else {
+		p.Metadata[core.DeprecationKey] = d.Metadata()
+	}
+	if cs, ok := reg.(ConditionalStorer); ok {
+		return cs.StoreIfMatch(ctx, p, p.Revision)
+	}
+	return reg.Store(ctx, p)
+}
+
+// DeprecationWarner reports reads of deprecated prompt versions (see Deprecate) on top of another
+// Registry, so services learn which old prompts they still use:
+//
+//	reg = registry.NewDeprecationWarner(reg, func(ctx context.Context, w *core.DeprecationWarning) {
+//		log.Printf("warning: %v", w)
+//	})
+//
+// Get, GetProduction, and GetMany call the function for each deprecated prompt they return and
+// return it as usual; List and other operations pass through unchanged.
+type DeprecationWarner struct {
+	inner Registry
+	warn  func(ctx context.Context, w *core.DeprecationWarning)
+}
+
+// NewDeprecationWarner returns inner with warn called for every deprecated prompt read.
+func NewDeprecationWarner(inner Registry, warn func(ctx context.Context, w *core.DeprecationWarning)) *DeprecationWarner {
+	return &DeprecationWarner{inner: inner, warn: warn}
+}
+
+// check calls the warning function if p is deprecated.
+func (d *DeprecationWarner) check(ctx context.Context, p *core.Prompt) {
+	if p == nil {
+		return
+	}
+	if w := core.CheckDeprecated(p); w != nil {
+		d.warn(ctx, w)
+	}
+}
+
+// Get implements Registry.
+func (d *DeprecationWarner) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	p, err := d.inner.Get(ctx, id, version)
+	if err == nil {
+		d.check(ctx, p)
+	}
+	return p, err
+}
+
+// GetProduction implements Registry.
+func (d *DeprecationWarner) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	p, err := d.inner.GetProduction(ctx, id)
+	if err == nil {
+		d.check(ctx, p)
+	}
+	return p, err
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time).
+func (d *DeprecationWarner) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	prompts, err := GetMany(ctx, d.inner, refs)
+	if err == nil {
+		for _, p := range prompts {
+			d.check(ctx, p)
+		}
+	}
+	return prompts, err
+}
+
+// Store implements Registry.
+func (d *DeprecationWarner) Store(ctx context.Context, prompt *core.Prompt) error {
+	return d.inner.Store(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does).
+func (d *DeprecationWarner) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	return StoreIfMatch(ctx, d.inner, prompt, revision)
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time).
+func (d *DeprecationWarner) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	return StoreBatch(ctx, d.inner, prompts)
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time).
+func (d *DeprecationWarner) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	return DeleteBatch(ctx, d.inner, refs)
+}
+
+// List implements Registry.
+func (d *DeprecationWarner) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	return d.inner.List(ctx, filter)
+}
+
+// ListVersions implements Registry.
+func (d *DeprecationWarner) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	return d.inner.ListVersions(ctx, id)
+}
+
+// Promote implements Registry.
+func (d *DeprecationWarner) Promote(ctx context.Context, id, version string, stage Stage) error {
+	return d.inner.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does).
+func (d *DeprecationWarner) RollbackProduction(ctx context.Context, id, from, version string) error {
+	return RollbackProduction(ctx, d.inner, id, from, version)
+}
+
+// Delete implements Registry.
+func (d *DeprecationWarner) Delete(ctx context.Context, id, version string) error {
+	return d.inner.Delete(ctx, id, version)
+}
+
+// Tag implements Registry.
+func (d *DeprecationWarner) Tag(ctx context.Context, id, version string, tags []string) error {
+	return d.inner.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry.
+func (d *DeprecationWarner) Archive(ctx context.Context, id, version string) error {
+	return d.inner.Archive(ctx, id, version)
+}
+
+// Restore implements Registry.
+func (d *DeprecationWarner) Restore(ctx context.Context, id, version string) error {
+	return d.inner.Restore(ctx, id, version)
+}
+
+// SetAlias implements Aliaser (if inner does).
+func (d *DeprecationWarner) SetAlias(ctx context.Context, id, alias, version string) error {
+	return SetAlias(ctx, d.inner, id, alias, version)
+}
+
+// Aliases implements Aliaser (if inner does).
+func (d *DeprecationWarner) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return Aliases(ctx, d.inner, id)
+}
+
+// History implements Auditor (if inner does).
+func (d *DeprecationWarner) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	return History(ctx, d.inner, id)
+}
+
+// Usage implements UsageReporter (if inner does).
+func (d *DeprecationWarner) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, d.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does).
+func (d *DeprecationWarner) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, d.inner)
+}
+
+// WatchChanges implements ChangeWatcher (if inner does).
+func (d *DeprecationWarner) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, d.inner, fn)
+}
+
+// Ensure DeprecationWarner implements Registry at compile time.
+var (
+	_ Registry             = (*DeprecationWarner)(nil)
+	_ ConditionalStorer    = (*DeprecationWarner)(nil)
+	_ Auditor              = (*DeprecationWarner)(nil)
+	_ UsageReporter        = (*DeprecationWarner)(nil)
+	_ ChangeWatcher        = (*DeprecationWarner)(nil)
+	_ Aliaser              = (*DeprecationWarner)(nil)
+	_ Batcher              = (*DeprecationWarner)(nil)
+	_ ProductionRollbacker = (*DeprecationWarner)(nil)
+)
@@ -0,0 +1,47 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecate(t *testing.T) {
+	ctx := context.Background()
+	var warnings []string
+	reg := NewDeprecationWarner(NewMemoryRegistry(), func(_ context.Context, w *core.DeprecationWarning) {
+		warnings = append(warnings, w.Error())
+	})
+	for _, v := range []string{"1.0.0", "2.0.0"} {
+		require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: v, Template: v}))
+	}
+	require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+
+	sunset := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, Deprecate(ctx, reg, "p", "1.0.0", &core.Deprecation{DeprecatedBy: "p@2.0.0", Reason: "too verbose", Sunset: sunset}))
+	assert.ErrorIs(t, Deprecate(ctx, reg, "p", "9.9.9", &core.Deprecation{}), core.ErrPromptNotFound)
+
+	p, err := reg.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", p.Template, "a deprecated version keeps working")
+	d, err := core.DeprecationOf(p)
+	require.NoError(t, err)
+	assert.Equal(t, &core.Deprecation{DeprecatedBy: "p@2.0.0", Reason: "too verbose", Sunset: sunset}, d)
+	_, err = reg.Get(ctx, "p", "2.0.0")
+	require.NoError(t, err)
+	_, err = reg.GetMany(ctx, []VersionRef{{ID: "p", Version: "1.0.0"}, {ID: "p", Version: "2.0.0"}})
+	require.NoError(t, err)
+	want := "prompt p@1.0.0 is deprecated (too verbose); use p@2.0.0 instead; sunset 2026-12-31"
+	assert.Equal(t, []string{want, want}, warnings)
+
+	require.NoError(t, Deprecate(ctx, reg, "p", "1.0.0", nil))
+	warnings = nil
+	p, err = reg.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.NotContains(t, p.Metadata, core.DeprecationKey)
+	assert.Empty(t, warnings)
+}
@@ -0,0 +1,954 @@
+// Package dynamoregistry provides a DynamoDB-backed registry.Registry.
+// Use: go get github.com/aws/aws-sdk-go-v2/config github.com/aws/aws-sdk-go-v2/service/dynamodb
+//
+// Single-table layout:
+//
+//	pk = "PROMPT#<id>", sk = "VERSION#<version>"
+//	attributes: id, version, stage, tags (list), prompt (JSON), created_at, updated_at, revision (number), archived (bool), checksum
+//	GSI "stage-index": hash key stage, range key id (projection ALL)
+//	pk = "AUDIT#<id>", sk = "<unix nanos>#<action>": entry (registry.AuditEntry JSON)
+//	pk = "ALIAS#<id>", sk = "<alias>": target (version)
+//
+// GetProduction is a single query on the stage index, so Lambda functions can resolve
+// production prompts without scanning.
+package dynamoregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+)
+
+// StageIndex is the name of the global secondary index on stage.
+const StageIndex = "stage-index"
+
+// API is the subset of *dynamodb.Client used by the registry (allows custom clients in tests).
+type API interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+}
+
+// Registry stores prompts in a single DynamoDB table.
+type Registry struct {
+	client API
+	table  string
+}
+
+// New creates a registry on the given table. table defaults to "loom-prompts".
+func New(client API, table string) *Registry {
+	if table == "" {
+		table = "loom-prompts"
+	}
+	return &Registry{client: client, table: table}
+}
+
+// NewFromConfig creates a registry using default AWS config (credentials, region from env).
+func NewFromConfig(ctx context.Context, table string) (*Registry, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return New(dynamodb.NewFromConfig(cfg), table), nil
+}
+
+// CreateTable creates the table and stage index (on-demand billing). An existing table is not an error.
+func (r *Registry) CreateTable(ctx context.Context) error {
+	_, err := r.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(r.table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("stage"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sk"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String(StageIndex),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("stage"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("id"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+	var inUse *types.ResourceInUseException
+	if errors.As(err, &inUse) {
+		return nil
+	}
+	return err
+}
+
+func pk(id string) string      { return "PROMPT#" + id }
+func sk(version string) string { return "VERSION#" + version }
+func auditPK(id string) string { return "AUDIT#" + id }
+func aliasPK(id string) string { return "ALIAS#" + id }
+
+func aliasKey(id, alias string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: aliasPK(id)},
+		"sk": &types.AttributeValueMemberS{Value: alias},
+	}
+}
+
+func (r *Registry) key(id, version string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: pk(id)},
+		"sk": &types.AttributeValueMemberS{Value: sk(version)},
+	}
+}
+
+// Store saves a prompt. Overwriting a version keeps its stage, tags, and created_at.
+func (r *Registry) Store(ctx context.Context, prompt *core.Prompt) error {
+	if err := r.store(ctx, prompt, -1); err != nil {
+		return err
+	}
+	return r.recordStore(ctx, prompt)
+}
+
+// StoreIfMatch implements registry.ConditionalStorer with a condition on the revision attribute.
+func (r *Registry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	err := r.store(ctx, prompt, revision)
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return core.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	return r.recordStore(ctx, prompt)
+}
+
+// store updates the item and increments its revision; if revision >= 0 the update is conditional on it.
+func (r *Registry) store(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
+		return fmt.Errorf("dynamodb registry: prompt id and version required")
+	}
+	in, err := r.storeInput(prompt, revision)
+	if err != nil {
+		return err
+	}
+	out, err := r.client.UpdateItem(ctx, in)
+	if err != nil {
+		return err
+	}
+	prompt.Revision = attrInt(out.Attributes, "revision")
+	return nil
+}
+
+// storeInput returns the update that stores prompt; see store.
+func (r *Registry) storeInput(prompt *core.Prompt, revision int64) (*dynamodb.UpdateItemInput, error) {
+	now := time.Now()
+	if prompt.CreatedAt.IsZero() {
+		prompt.CreatedAt = now
+	}
+	prompt.UpdatedAt = now
+	data, err := json.Marshal(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb registry encode: %w", err)
+	}
+	in := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.table),
+		Key:       r.key(prompt.ID, prompt.Version),
+		UpdateExpression: aws.String("SET #id = :id, #ver = :ver, #p = :p, #u = :u, #sum = :sum, " +
+			"#c = if_not_exists(#c, :c), #st = if_not_exists(#st, :dev), #tags = if_not_exists(#tags, :tags), " +
+			"#rev = if_not_exists(#rev, :zero) + :one"),
+		ExpressionAttributeNames: map[string]string{
+			"#id": "id", "#ver": "version", "#p": "prompt", "#u": "updated_at",
+			"#c": "created_at", "#st": "stage", "#tags": "tags", "#rev": "revision", "#sum": "checksum",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":   &types.AttributeValueMemberS{Value: prompt.ID},
+			":ver":  &types.AttributeValueMemberS{Value: prompt.Version},
+			":p":    &types.AttributeValueMemberS{Value: string(data)},
+			":u":    &types.AttributeValueMemberS{Value: formatTime(prompt.UpdatedAt)},
+			":c":    &types.AttributeValueMemberS{Value: formatTime(prompt.CreatedAt)},
+			":sum":  &types.AttributeValueMemberS{Value: registry.Checksum(prompt)},
+			":dev":  &types.AttributeValueMemberS{Value: string(registry.StageDev)},
+			":tags": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+			":one":  &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+	switch {
+	case revision == 0:
+		in.ConditionExpression = aws.String("attribute_not_exists(pk)")
+	case revision > 0:
+		in.ConditionExpression = aws.String("#rev = :expected")
+		in.ExpressionAttributeValues[":expected"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(revision, 10)}
+	}
+	return in, nil
+}
+
+// transactBatchSize is the number of versions per transaction in StoreBatch and DeleteBatch; each
+// version takes two of the 100 actions a transaction allows (the write and its audit entry).
+const transactBatchSize = 50
+
+// batchStoreRetries bounds the attempts of a StoreBatch transaction that conflicts with concurrent writes.
+const batchStoreRetries = 3
+
+// StoreBatch implements registry.Batcher. Each group of up to 50 prompts is stored in one
+// transaction together with its audit entries; a failed batch may leave earlier groups stored.
+func (r *Registry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := registry.ValidateBatch(prompts); err != nil {
+		return err
+	}
+	for start := 0; start < len(prompts); start += transactBatchSize {
+		chunk := prompts[start:min(start+transactBatchSize, len(prompts))]
+		var err error
+		for attempt := 0; attempt < batchStoreRetries; attempt++ {
+			if err = r.storeChunk(ctx, chunk); !conditionFailed(err) {
+				break
+			}
+		}
+		if conditionFailed(err) {
+			return core.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeChunk stores prompts in one transaction, conditional on the revisions read beforehand so
+// the new revisions are known without reading them back.
+func (r *Registry) storeChunk(ctx context.Context, prompts []*core.Prompt) error {
+	keys := make([]map[string]types.AttributeValue, len(prompts))
+	for i, p := range prompts {
+		keys[i] = r.key(p.ID, p.Version)
+	}
+	items, err := r.batchGet(ctx, keys, "#id, #ver, #rev", map[string]string{"#id": "id", "#ver": "version", "#rev": "revision"})
+	if err != nil {
+		return err
+	}
+	current := make(map[registry.VersionRef]int64, len(items))
+	for _, item := range items {
+		current[registry.VersionRef{ID: attrString(item, "id"), Version: attrString(item, "version")}] = attrInt(item, "revision")
+	}
+	tx := make([]types.TransactWriteItem, 0, 2*len(prompts))
+	revisions := make([]int64, len(prompts))
+	for i, p := range prompts {
+		rev := current[registry.VersionRef{ID: p.ID, Version: p.Version}]
+		in, err := r.storeInput(p, rev)
+		if err != nil {
+			return err
+		}
+		revisions[i] = rev + 1
+		tx = append(tx, types.TransactWriteItem{Update: &types.Update{
+			TableName:                 in.TableName,
+			Key:                       in.Key,
+			UpdateExpression:          in.UpdateExpression,
+			ConditionExpression:       in.ConditionExpression,
+			ExpressionAttributeNames:  in.ExpressionAttributeNames,
+			ExpressionAttributeValues: in.ExpressionAttributeValues,
+		}})
+		e := registry.NewAuditEntry(ctx, registry.AuditStore, p.ID, p.Version)
+		e.Revision = revisions[i]
+		tx = append(tx, types.TransactWriteItem{Put: &types.Put{TableName: aws.String(r.table), Item: r.auditItem(e)}})
+	}
+	if _, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: tx}); err != nil {
+		return err
+	}
+	for i, p := range prompts {
+		p.Revision = revisions[i]
+	}
+	return nil
+}
+
+// DeleteBatch implements registry.Batcher. Each group of up to 50 versions is deleted in one
+// transaction together with its audit entries; a missing version cancels its group with
+// core.ErrPromptNotFound, leaving earlier groups deleted.
+func (r *Registry) DeleteBatch(ctx context.Context, refs []registry.VersionRef) error {
+	if err := registry.ValidateRefs(refs); err != nil {
+		return err
+	}
+	for start := 0; start < len(refs); start += transactBatchSize {
+		chunk := refs[start:min(start+transactBatchSize, len(refs))]
+		tx := make([]types.TransactWriteItem, 0, 2*len(chunk))
+		for _, ref := range chunk {
+			tx = append(tx,
+				types.TransactWriteItem{Delete: &types.Delete{
+					TableName:           aws.String(r.table),
+					Key:                 r.key(ref.ID, ref.Version),
+					ConditionExpression: aws.String("attribute_exists(pk)"),
+				}},
+				types.TransactWriteItem{Put: &types.Put{
+					TableName: aws.String(r.table),
+					Item:      r.auditItem(registry.NewAuditEntry(ctx, registry.AuditDelete, ref.ID, ref.Version)),
+				}},
+			)
+		}
+		_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: tx})
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			for i, reason := range canceled.CancellationReasons {
+				if aws.ToString(reason.Code) == "ConditionalCheckFailed" && i/2 < len(chunk) {
+					return fmt.Errorf("%w: %s@%s", core.ErrPromptNotFound, chunk[i/2].ID, chunk[i/2].Version)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMany implements registry.Batcher with BatchGetItem requests of up to 100 versions.
+func (r *Registry) GetMany(ctx context.Context, refs []registry.VersionRef) ([]*core.Prompt, error) {
+	seen := make(map[registry.VersionRef]bool, len(refs))
+	var keys []map[string]types.AttributeValue
+	for _, ref := range refs {
+		if _, ok := registry.ParseAlias(ref.Version); ok || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		keys = append(keys, r.key(ref.ID, ref.Version))
+	}
+	items, err := r.batchGet(ctx, keys, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[registry.VersionRef]*core.Prompt, len(items))
+	for _, item := range items {
+		if attrBool(item, "archived") {
+			continue
+		}
+		p, err := decodePrompt(item)
+		if err != nil {
+			return nil, err
+		}
+		found[registry.VersionRef{ID: attrString(item, "id"), Version: attrString(item, "version")}] = p
+	}
+	out := make([]*core.Prompt, len(refs))
+	for i, ref := range refs {
+		if p := found[ref]; p != nil {
+			out[i] = p.Copy()
+		}
+	}
+	return out, nil
+}
+
+// batchGetLimit is the maximum number of keys per BatchGetItem request.
+const batchGetLimit = 100
+
+// batchGet returns the existing items for keys, in no particular order, retrying unprocessed keys.
+// projection and names are optional.
+func (r *Registry) batchGet(ctx context.Context, keys []map[string]types.AttributeValue, projection string, names map[string]string) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	for start := 0; start < len(keys); start += batchGetLimit {
+		ka := types.KeysAndAttributes{Keys: keys[start:min(start+batchGetLimit, len(keys))], ExpressionAttributeNames: names}
+		if projection != "" {
+			ka.ProjectionExpression = aws.String(projection)
+		}
+		req := map[string]types.KeysAndAttributes{r.table: ka}
+		for backoff := 50 * time.Millisecond; len(req) > 0; backoff *= 2 {
+			out, err := r.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: req})
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, out.Responses[r.table]...)
+			req = out.UnprocessedKeys
+			if len(req) > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(min(backoff, 2*time.Second)):
+				}
+			}
+		}
+	}
+	return items, nil
+}
+
+// conditionFailed reports whether err is a transaction canceled by a failed condition.
+func conditionFailed(err error) bool {
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return false
+	}
+	for _, reason := range canceled.CancellationReasons {
+		if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns a prompt by id and version (or "@alias").
+func (r *Registry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	if alias, ok := registry.ParseAlias(version); ok {
+		out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String(r.table), Key: aliasKey(id, alias)})
+		if err != nil {
+			return nil, err
+		}
+		return registry.GetByAlias(ctx, r, id, alias, attrString(out.Item, "target"))
+	}
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.table),
+		Key:       r.key(id, version),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil || attrBool(out.Item, "archived") {
+		return nil, core.ErrPromptNotFound
+	}
+	return decodePrompt(out.Item)
+}
+
+// GetProduction returns the production version of id via the stage index.
+func (r *Registry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	items, err := r.productionItems(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if !attrBool(item, "archived") {
+			return decodePrompt(item)
+		}
+	}
+	return nil, core.ErrPromptNotFound
+}
+
+func (r *Registry) productionItems(ctx context.Context, id string) ([]map[string]types.AttributeValue, error) {
+	return r.query(ctx, &dynamodb.QueryInput{
+		TableName:                aws.String(r.table),
+		IndexName:                aws.String(StageIndex),
+		KeyConditionExpression:   aws.String("#st = :st AND #id = :id"),
+		ExpressionAttributeNames: map[string]string{"#st": "stage", "#id": "id"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":st": &types.AttributeValueMemberS{Value: string(registry.StageProduction)},
+			":id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+}
+
+// List returns prompts matching the filter in the filter's order (by default id, then semantic
+// version); items are decoded only for the returned page. ID filters query each partition; a stage filter queries the stage index; otherwise the table is scanned.
+func (r *Registry) List(ctx context.Context, filter registry.Filter) ([]*core.Prompt, error) {
+	var items []map[string]types.AttributeValue
+	switch {
+	case len(filter.IDs) > 0:
+		for _, id := range filter.IDs {
+			got, err := r.query(ctx, r.partitionQuery(id, false))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, got...)
+		}
+	case filter.Stage != "":
+		got, err := r.query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(r.table),
+			IndexName:                 aws.String(StageIndex),
+			KeyConditionExpression:    aws.String("#st = :st"),
+			ExpressionAttributeNames:  map[string]string{"#st": "stage"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":st": &types.AttributeValueMemberS{Value: string(filter.Stage)}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = got
+	default:
+		p := dynamodb.NewScanPaginator(r.client, &dynamodb.ScanInput{
+			TableName:                 aws.String(r.table),
+			FilterExpression:          aws.String("begins_with(pk, :prefix)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":prefix": &types.AttributeValueMemberS{Value: "PROMPT#"}},
+		})
+		for p.HasMorePages() {
+			page, err := p.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, page.Items...)
+		}
+	}
+	entries := make([]registry.ListEntry, 0, len(items))
+	byRef := make(map[registry.VersionRef]map[string]types.AttributeValue, len(items))
+	for _, item := range items {
+		if !filter.IncludeArchived && attrBool(item, "archived") {
+			continue
+		}
+		if filter.Stage != "" && attrString(item, "stage") != string(filter.Stage) {
+			continue
+		}
+		if len(filter.Tags) > 0 && !hasAll(attrStrings(item, "tags"), filter.Tags) {
+			continue
+		}
+		ref := registry.VersionRef{ID: attrString(item, "id"), Version: attrString(item, "version")}
+		byRef[ref] = item
+		entries = append(entries, registry.ListEntry{
+			ID:        ref.ID,
+			Version:   ref.Version,
+			CreatedAt: parseTime(attrString(item, "created_at")),
+			UpdatedAt: parseTime(attrString(item, "updated_at")),
+		})
+	}
+	return filter.Page(entries, func(batch []registry.ListEntry) ([]*core.Prompt, error) {
+		out := make([]*core.Prompt, len(batch))
+		for i, e := range batch {
+			p, err := decodePrompt(byRef[registry.VersionRef{ID: e.ID, Version: e.Version}])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = p
+		}
+		return out, nil
+	})
+}
+
+// ListVersions returns version info for an id in ascending semantic version order.
+func (r *Registry) ListVersions(ctx context.Context, id string) ([]registry.VersionInfo, error) {
+	items, err := r.query(ctx, r.partitionQuery(id, true))
+	if err != nil {
+		return nil, err
+	}
+	sortItems(items)
+	infos := make([]registry.VersionInfo, 0, len(items))
+	for _, item := range items {
+		infos = append(infos, registry.VersionInfo{
+			ID:        attrString(item, "id"),
+			Version:   attrString(item, "version"),
+			Stage:     registry.Stage(attrString(item, "stage")),
+			Tags:      attrStrings(item, "tags"),
+			CreatedAt: parseTime(attrString(item, "created_at")),
+			UpdatedAt: parseTime(attrString(item, "updated_at")),
+			Archived:  attrBool(item, "archived"),
+			Checksum:  attrString(item, "checksum"),
+		})
+	}
+	return infos, nil
+}
+
+// Promote sets the stage of a version. Promoting to production demotes the current production
+// version to dev in the same transaction.
+func (r *Registry) Promote(ctx context.Context, id, version string, stage registry.Stage) error {
+	var demote []map[string]types.AttributeValue
+	if stage == registry.StageProduction {
+		items, err := r.productionItems(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if attrString(item, "version") != version {
+				demote = append(demote, item)
+			}
+		}
+	}
+	// The target update is conditional so a missing version cancels the whole transaction.
+	setStage := func(v string, st registry.Stage, mustExist bool) *types.Update {
+		u := &types.Update{
+			TableName:                 aws.String(r.table),
+			Key:                       r.key(id, v),
+			UpdateExpression:          aws.String("SET #st = :st"),
+			ExpressionAttributeNames:  map[string]string{"#st": "stage"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":st": &types.AttributeValueMemberS{Value: string(st)}},
+		}
+		if mustExist {
+			u.ConditionExpression = aws.String("attribute_exists(pk)")
+		}
+		return u
+	}
+	tx := make([]types.TransactWriteItem, 0, len(demote)+1)
+	for _, item := range demote {
+		tx = append(tx, types.TransactWriteItem{Update: setStage(attrString(item, "version"), registry.StageDev, false)})
+	}
+	tx = append(tx, types.TransactWriteItem{Update: setStage(version, stage, true)})
+	_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: tx})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for _, reason := range canceled.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return core.ErrPromptNotFound
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	e := registry.NewAuditEntry(ctx, registry.AuditPromote, id, version)
+	e.Stage = stage
+	return r.record(ctx, e)
+}
+
+// RollbackProduction implements registry.ProductionRollbacker. One transaction promotes version
+// and demotes from on the condition that from is still in production, so a promotion committed
+// meanwhile cancels it with core.ErrConflict.
+func (r *Registry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	items, err := r.productionItems(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Demotions require the version to still be in production; the promotion requires it to exist.
+	setStage := func(v string, st registry.Stage, cond string) *types.Update {
+		return &types.Update{
+			TableName:                 aws.String(r.table),
+			Key:                       r.key(id, v),
+			UpdateExpression:          aws.String("SET #st = :st"),
+			ConditionExpression:       aws.String(cond),
+			ExpressionAttributeNames:  map[string]string{"#st": "stage"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":st": &types.AttributeValueMemberS{Value: string(st)}},
+		}
+	}
+	var tx []types.TransactWriteItem
+	found := false
+	for _, item := range items {
+		if v := attrString(item, "version"); v != version {
+			found = found || v == from
+			u := setStage(v, registry.StageDev, "#st = :prod")
+			u.ExpressionAttributeValues[":prod"] = &types.AttributeValueMemberS{Value: string(registry.StageProduction)}
+			tx = append(tx, types.TransactWriteItem{Update: u})
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s production is not %q", core.ErrConflict, id, from)
+	}
+	tx = append(tx, types.TransactWriteItem{Update: setStage(version, registry.StageProduction, "attribute_exists(pk)")})
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: tx})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		last := len(canceled.CancellationReasons) - 1
+		for i, reason := range canceled.CancellationReasons {
+			if aws.ToString(reason.Code) != "ConditionalCheckFailed" {
+				continue
+			}
+			if i == last {
+				return core.ErrPromptNotFound
+			}
+			return fmt.Errorf("%w: %s production moved from %q", core.ErrConflict, id, from)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	e := registry.NewAuditEntry(ctx, registry.AuditRollback, id, version)
+	e.Stage = registry.StageProduction
+	return r.record(ctx, e)
+}
+
+// Delete removes a version. Returns core.ErrPromptNotFound if it does not exist.
+func (r *Registry) Delete(ctx context.Context, id, version string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.table),
+		Key:                 r.key(id, version),
+		ConditionExpression: aws.String("attribute_exists(pk)"),
+	})
+	if err != nil {
+		return notFoundIfConditionFailed(err)
+	}
+	return r.record(ctx, registry.NewAuditEntry(ctx, registry.AuditDelete, id, version))
+}
+
+// Tag replaces the tags of a version.
+func (r *Registry) Tag(ctx context.Context, id, version string, tags []string) error {
+	list := make([]types.AttributeValue, len(tags))
+	for i, t := range tags {
+		list[i] = &types.AttributeValueMemberS{Value: t}
+	}
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.table),
+		Key:                       r.key(id, version),
+		UpdateExpression:          aws.String("SET #tags = :tags"),
+		ConditionExpression:       aws.String("attribute_exists(pk)"),
+		ExpressionAttributeNames:  map[string]string{"#tags": "tags"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":tags": &types.AttributeValueMemberL{Value: list}},
+	})
+	if err != nil {
+		return notFoundIfConditionFailed(err)
+	}
+	e := registry.NewAuditEntry(ctx, registry.AuditTag, id, version)
+	e.Tags = append([]string(nil), tags...)
+	return r.record(ctx, e)
+}
+
+// Archive hides a version from Get, GetProduction, and List until it is restored.
+func (r *Registry) Archive(ctx context.Context, id, version string) error {
+	return r.setArchived(ctx, id, version, true)
+}
+
+// Restore makes an archived version visible again.
+func (r *Registry) Restore(ctx context.Context, id, version string) error {
+	return r.setArchived(ctx, id, version, false)
+}
+
+func (r *Registry) setArchived(ctx context.Context, id, version string, archived bool) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.table),
+		Key:                       r.key(id, version),
+		UpdateExpression:          aws.String("SET #ar = :ar"),
+		ConditionExpression:       aws.String("attribute_exists(pk)"),
+		ExpressionAttributeNames:  map[string]string{"#ar": "archived"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":ar": &types.AttributeValueMemberBOOL{Value: archived}},
+	})
+	if err != nil {
+		return notFoundIfConditionFailed(err)
+	}
+	action := registry.AuditArchive
+	if !archived {
+		action = registry.AuditRestore
+	}
+	return r.record(ctx, registry.NewAuditEntry(ctx, action, id, version))
+}
+
+// SetAlias implements registry.Aliaser. Setting an alias checks in the same transaction that the
+// version exists.
+func (r *Registry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if err := registry.ValidateAlias(alias); err != nil {
+		return err
+	}
+	var err error
+	if version == "" {
+		_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: aws.String(r.table), Key: aliasKey(id, alias)})
+	} else {
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+			{ConditionCheck: &types.ConditionCheck{
+				TableName:           aws.String(r.table),
+				Key:                 r.key(id, version),
+				ConditionExpression: aws.String("attribute_exists(pk)"),
+			}},
+			{Update: &types.Update{
+				TableName:                 aws.String(r.table),
+				Key:                       aliasKey(id, alias),
+				UpdateExpression:          aws.String("SET #t = :t"),
+				ExpressionAttributeNames:  map[string]string{"#t": "target"},
+				ExpressionAttributeValues: map[string]types.AttributeValue{":t": &types.AttributeValueMemberS{Value: version}},
+			}},
+		}})
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+			aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return core.ErrPromptNotFound
+		}
+	}
+	if err != nil {
+		return err
+	}
+	e := registry.NewAuditEntry(ctx, registry.AuditAlias, id, version)
+	e.Alias = alias
+	return r.record(ctx, e)
+}
+
+// Aliases implements registry.Aliaser with a query on the id's alias partition.
+func (r *Registry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	items, err := r.query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.table),
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": &types.AttributeValueMemberS{Value: aliasPK(id)}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(items))
+	for _, item := range items {
+		out[attrString(item, "sk")] = attrString(item, "target")
+	}
+	return out, nil
+}
+
+func (r *Registry) recordStore(ctx context.Context, prompt *core.Prompt) error {
+	e := registry.NewAuditEntry(ctx, registry.AuditStore, prompt.ID, prompt.Version)
+	e.Revision = prompt.Revision
+	return r.record(ctx, e)
+}
+
+// record writes e as an item in the id's audit partition. Audit items have no stage attribute,
+// so they stay out of the stage index.
+func (r *Registry) record(ctx context.Context, e registry.AuditEntry) error {
+	item := r.auditItem(e)
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.table),
+		Key:                       map[string]types.AttributeValue{"pk": item["pk"], "sk": item["sk"]},
+		UpdateExpression:          aws.String("SET #e = :e"),
+		ExpressionAttributeNames:  map[string]string{"#e": "entry"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":e": item["entry"]},
+	})
+	if err != nil {
+		return fmt.Errorf("dynamodb registry audit: %w", err)
+	}
+	return nil
+}
+
+// auditItem returns the audit partition item for e.
+func (r *Registry) auditItem(e registry.AuditEntry) map[string]types.AttributeValue {
+	data, _ := json.Marshal(e)
+	return map[string]types.AttributeValue{
+		"pk":    &types.AttributeValueMemberS{Value: auditPK(e.ID)},
+		"sk":    &types.AttributeValueMemberS{Value: fmt.Sprintf("%020d#%s", e.Time.UnixNano(), e.Action)},
+		"entry": &types.AttributeValueMemberS{Value: string(data)},
+	}
+}
+
+// History implements registry.Auditor with a query on the id's audit partition.
+func (r *Registry) History(ctx context.Context, id string) ([]registry.AuditEntry, error) {
+	items, err := r.query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.table),
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": &types.AttributeValueMemberS{Value: auditPK(id)}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]registry.AuditEntry, 0, len(items))
+	for _, item := range items {
+		var e registry.AuditEntry
+		if err := json.Unmarshal([]byte(attrString(item, "entry")), &e); err != nil {
+			return nil, fmt.Errorf("dynamodb registry audit decode: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// partitionQuery returns a query for all versions of id; metaOnly skips the prompt body.
+func (r *Registry) partitionQuery(id string, metaOnly bool) *dynamodb.QueryInput {
+	in := &dynamodb.QueryInput{
+		TableName:                 aws.String(r.table),
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": &types.AttributeValueMemberS{Value: pk(id)}},
+	}
+	if metaOnly {
+		in.ProjectionExpression = aws.String("#id, #ver, #st, #tags, #c, #u, #ar")
+		in.ExpressionAttributeNames = map[string]string{
+			"#id": "id", "#ver": "version", "#st": "stage", "#tags": "tags", "#c": "created_at", "#u": "updated_at",
+			"#ar": "archived",
+		}
+	}
+	return in
+}
+
+func (r *Registry) query(ctx context.Context, in *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	p := dynamodb.NewQueryPaginator(r.client, in)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+	}
+	return items, nil
+}
+
+func decodePrompt(item map[string]types.AttributeValue) (*core.Prompt, error) {
+	var p core.Prompt
+	if err := json.Unmarshal([]byte(attrString(item, "prompt")), &p); err != nil {
+		return nil, fmt.Errorf("dynamodb registry decode: %w", err)
+	}
+	if t := parseTime(attrString(item, "created_at")); !t.IsZero() {
+		p.CreatedAt = t
+	}
+	if t := parseTime(attrString(item, "updated_at")); !t.IsZero() {
+		p.UpdatedAt = t
+	}
+	p.Revision = attrInt(item, "revision")
+	return &p, nil
+}
+
+func sortItems(items []map[string]types.AttributeValue) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := attrString(items[i], "id"), attrString(items[j], "id")
+		if a != b {
+			return a < b
+		}
+		return registry.CompareVersions(attrString(items[i], "version"), attrString(items[j], "version")) < 0
+	})
+}
+
+// notFoundIfConditionFailed maps a failed attribute_exists condition to core.ErrPromptNotFound.
+func notFoundIfConditionFailed(err error) error {
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return core.ErrPromptNotFound
+	}
+	return err
+}
+
+func attrString(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func attrInt(item map[string]types.AttributeValue, name string) int64 {
+	if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	}
+	return 0
+}
+
+func attrBool(item map[string]types.AttributeValue, name string) bool {
+	v, ok := item[name].(*types.AttributeValueMemberBOOL)
+	return ok && v.Value
+}
+
+func attrStrings(item map[string]types.AttributeValue, name string) []string {
+	l, ok := item[name].(*types.AttributeValueMemberL)
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(l.Value))
+	for _, v := range l.Value {
+		if s, ok := v.(*types.AttributeValueMemberS); ok {
+			out = append(out, s.Value)
+		}
+	}
+	return out
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+func hasAll(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Ensure Registry implements registry.Registry, registry.Auditor, registry.Batcher, and
+// registry.ProductionRollbacker at compile time.
+var (
+	_ registry.Registry             = (*Registry)(nil)
+	_ registry.Auditor              = (*Registry)(nil)
+	_ registry.Batcher              = (*Registry)(nil)
+	_ registry.ProductionRollbacker = (*Registry)(nil)
+)
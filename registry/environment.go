@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// environmentPrefix starts the aliases that hold per-environment production pointers.
+const environmentPrefix = "production."
+
+// EnvironmentAlias returns the alias that holds env's production pointer, e.g. "production.eu-prod".
+// Environment pointers are ordinary aliases, so every Aliaser stores them and Export, Sync, and
+// Prune keep them; avoid naming other aliases with this prefix.
+func EnvironmentAlias(env string) string {
+	return environmentPrefix + env
+}
+
+// ParseEnvironmentAlias returns the environment of an alias made by EnvironmentAlias.
+func ParseEnvironmentAlias(alias string) (env string, ok bool) {
+	env, ok = strings.CutPrefix(alias, environmentPrefix)
+	return env, ok && env != ""
+}
+
+// PromoteFor points env's production pointer for id at version, so GetProductionFor(ctx, reg, id,
+// env) returns it while other environments keep the global production version. An empty version
+// removes the pointer and env falls back to the global one. The version's stage is not changed.
+// It returns an error if reg does not implement Aliaser.
+func PromoteFor(ctx context.Context, reg Registry, id, version, env string) error {
+	if env == "" {
+		return fmt.Errorf("registry: empty environment (use Promote for the global production version)")
+	}
+	return SetAlias(ctx, reg, id, EnvironmentAlias(env), version)
+}
+
+// GetProductionFor returns the production version of id for env: the version PromoteFor pointed
+// env at, or the global production version (GetProduction) if env has no pointer or is empty. A
+// pointer whose version was deleted is reported as core.ErrPromptNotFound rather than falling back.
+func GetProductionFor(ctx context.Context, reg Registry, id, env string) (*core.Prompt, error) {
+	if env == "" {
+		return reg.GetProduction(ctx, id)
+	}
+	p, err := reg.Get(ctx, id, "@"+EnvironmentAlias(env))
+	if !errors.Is(err, core.ErrPromptNotFound) {
+		return p, err
+	}
+	aliases, aerr := Aliases(ctx, reg, id)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if aliases[EnvironmentAlias(env)] != "" {
+		return nil, err
+	}
+	return reg.GetProduction(ctx, id)
+}
+
+// Environments returns the environment production pointers of id (environment -> version). It
+// returns an error if reg does not implement Aliaser.
+func Environments(ctx context.Context, reg Registry, id string) (map[string]string, error) {
+	aliases, err := Aliases(ctx, reg, id)
+	if err != nil {
+		return nil, err
+	}
+	envs := make(map[string]string)
+	for alias, version := range aliases {
+		if env, ok := ParseEnvironmentAlias(alias); ok {
+			envs[env] = version
+		}
+	}
+	return envs, nil
+}
@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductionFor(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemoryRegistry()
+	for _, v := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: v, Template: v}))
+	}
+	require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+	prodFor := func(env string) string {
+		t.Helper()
+		p, err := GetProductionFor(ctx, reg, "p", env)
+		require.NoError(t, err)
+		return p.Version
+	}
+
+	assert.Equal(t, "1.0.0", prodFor("eu-prod"), "environments without a pointer use the global production version")
+	require.NoError(t, PromoteFor(ctx, reg, "p", "1.1.0", "eu-prod"))
+	require.NoError(t, PromoteFor(ctx, reg, "p", "1.2.0", "us-canary"))
+	assert.Equal(t, "1.1.0", prodFor("eu-prod"))
+	assert.Equal(t, "1.2.0", prodFor("us-canary"))
+	assert.Equal(t, "1.0.0", prodFor(""))
+	assert.Equal(t, "1.0.0", prodFor("us-prod"))
+	prod, err := reg.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", prod.Version, "the global pointer is unchanged")
+
+	envs, err := Environments(ctx, reg, "p")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"eu-prod": "1.1.0", "us-canary": "1.2.0"}, envs)
+
+	require.NoError(t, PromoteFor(ctx, reg, "p", "", "us-canary"))
+	assert.Equal(t, "1.0.0", prodFor("us-canary"), "removing the pointer falls back to the global version")
+	require.NoError(t, reg.Delete(ctx, "p", "1.1.0"))
+	_, err = GetProductionFor(ctx, reg, "p", "eu-prod")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound, "a dangling pointer does not silently fall back")
+
+	assert.Error(t, PromoteFor(ctx, reg, "p", "1.2.0", ""))
+	assert.ErrorIs(t, PromoteFor(ctx, reg, "p", "1.2.0", "bad env"), ErrInvalidAlias)
+	assert.ErrorIs(t, PromoteFor(ctx, reg, "p", "9.9.9", "eu-prod"), core.ErrPromptNotFound)
+}
@@ -0,0 +1,283 @@
+package registry
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrInvalidBundle is returned by Import for input that is not a bundle written by Export.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// BundleFormat and BundleVersion identify the manifest of a bundle written by Export.
+const (
+	BundleFormat  = "loom-bundle"
+	BundleVersion = 1
+)
+
+// bundleManifestName is the first entry of a bundle; prompt versions follow as bundlePromptName entries.
+const bundleManifestName = "manifest.json"
+
+// Manifest describes the contents of a bundle: every version with its stage, tags, and archived flag,
+// and per prompt id the production version and aliases.
+type Manifest struct {
+	Format     string                       `json:"format"`
+	Version    int                          `json:"version"`
+	ExportedAt time.Time                    `json:"exported_at"`
+	Prompts    []ManifestEntry              `json:"prompts"`
+	Production map[string]string            `json:"production,omitempty"`
+	Aliases    map[string]map[string]string `json:"aliases,omitempty"`
+}
+
+// ManifestEntry describes one prompt version in a bundle; File is its tar entry.
+type ManifestEntry struct {
+	ID       string   `json:"id"`
+	Version  string   `json:"version"`
+	Stage    Stage    `json:"stage,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Archived bool     `json:"archived,omitempty"`
+	File     string   `json:"file"`
+}
+
+func bundlePromptName(id, version string) string {
+	return "prompts/" + id + "/" + version + ".json"
+}
+
+// Export writes every prompt version in reg, including archived ones, to w as a tar bundle: a
+// manifest.json entry (see Manifest) followed by one JSON file per version. Aliases are included
+// if reg implements Aliaser. The bundle can be restored into any registry with Import, for
+// backups or to move prompts between environments.
+func Export(ctx context.Context, reg Registry, w io.Writer) error {
+	var prompts []*core.Prompt
+	err := eachPage(ctx, reg, Filter{Limit: 1000, IncludeArchived: true}, func(batch []*core.Prompt) (bool, error) {
+		prompts = append(prompts, batch...)
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		if prompts[i].ID != prompts[j].ID {
+			return prompts[i].ID < prompts[j].ID
+		}
+		return CompareVersions(prompts[i].Version, prompts[j].Version) < 0
+	})
+
+	m := Manifest{Format: BundleFormat, Version: BundleVersion, ExportedAt: time.Now().UTC()}
+	_, aliaser := reg.(Aliaser)
+	infos := make(map[string]map[string]VersionInfo)
+	for _, p := range prompts {
+		if _, ok := infos[p.ID]; ok {
+			continue
+		}
+		list, err := reg.ListVersions(ctx, p.ID)
+		if err != nil {
+			return fmt.Errorf("export %s: %w", p.ID, err)
+		}
+		infos[p.ID] = make(map[string]VersionInfo, len(list))
+		for _, info := range list {
+			infos[p.ID][info.Version] = info
+		}
+		prod, err := reg.GetProduction(ctx, p.ID)
+		switch {
+		case err == nil:
+			if m.Production == nil {
+				m.Production = make(map[string]string)
+			}
+			m.Production[p.ID] = prod.Version
+		case !errors.Is(err, core.ErrPromptNotFound):
+			return fmt.Errorf("export %s: %w", p.ID, err)
+		}
+		if aliaser {
+			aliases, err := Aliases(ctx, reg, p.ID)
+			if err != nil {
+				return fmt.Errorf("export %s: %w", p.ID, err)
+			}
+			for alias, version := range aliases {
+				if _, ok := infos[p.ID][version]; !ok {
+					delete(aliases, alias) // its version was deleted
+				}
+			}
+			if len(aliases) > 0 {
+				if m.Aliases == nil {
+					m.Aliases = make(map[string]map[string]string)
+				}
+				m.Aliases[p.ID] = aliases
+			}
+		}
+	}
+	files := make([][]byte, len(prompts))
+	for i, p := range prompts {
+		info := infos[p.ID][p.Version]
+		m.Prompts = append(m.Prompts, ManifestEntry{
+			ID:       p.ID,
+			Version:  p.Version,
+			Stage:    info.Stage,
+			Tags:     info.Tags,
+			Archived: info.Archived,
+			File:     bundlePromptName(p.ID, p.Version),
+		})
+		data, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return fmt.Errorf("export %s@%s: %w", p.ID, p.Version, err)
+		}
+		files[i] = data
+	}
+
+	tw := tar.NewWriter(w)
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := writeTarFile(tw, bundleManifestName, manifest, m.ExportedAt); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	for i, e := range m.Prompts {
+		if err := writeTarFile(tw, e.File, files[i], m.ExportedAt); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Import reads a bundle written by Export from r and stores its versions in reg, then applies
+// their stages, tags, archived flags, production versions, and aliases. Versions already in reg
+// with the same id and version are overwritten; other versions are left alone. The whole bundle
+// is read and checked before reg is changed; an error while applying it may leave it partly imported.
+func Import(ctx context.Context, reg Registry, r io.Reader) error {
+	var m *Manifest
+	files := make(map[string]*core.Prompt)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Name == bundleManifestName {
+			m = new(Manifest)
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return fmt.Errorf("%w: manifest: %v", ErrInvalidBundle, err)
+			}
+			continue
+		}
+		var p core.Prompt
+		if err := json.NewDecoder(tr).Decode(&p); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidBundle, hdr.Name, err)
+		}
+		files[hdr.Name] = &p
+	}
+	if m == nil {
+		return fmt.Errorf("%w: missing %s", ErrInvalidBundle, bundleManifestName)
+	}
+	if m.Format != BundleFormat || m.Version != BundleVersion {
+		return fmt.Errorf("%w: unsupported format %q version %d", ErrInvalidBundle, m.Format, m.Version)
+	}
+	prompts := make([]*core.Prompt, 0, len(m.Prompts))
+	for _, e := range m.Prompts {
+		p, ok := files[e.File]
+		if !ok {
+			return fmt.Errorf("%w: missing %s", ErrInvalidBundle, e.File)
+		}
+		if p.ID != e.ID || p.Version != e.Version {
+			return fmt.Errorf("%w: %s holds %s@%s, want %s@%s", ErrInvalidBundle, e.File, p.ID, p.Version, e.ID, e.Version)
+		}
+		p.Revision = 0
+		prompts = append(prompts, p)
+	}
+	if err := ValidateBatch(prompts); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	inBundle := make(map[VersionRef]bool, len(prompts))
+	for _, p := range prompts {
+		inBundle[VersionRef{ID: p.ID, Version: p.Version}] = true
+	}
+	for id, version := range m.Production {
+		if !inBundle[VersionRef{ID: id, Version: version}] {
+			return fmt.Errorf("%w: production version %s@%s is not in the bundle", ErrInvalidBundle, id, version)
+		}
+	}
+	for id, aliases := range m.Aliases {
+		for alias, version := range aliases {
+			if !inBundle[VersionRef{ID: id, Version: version}] {
+				return fmt.Errorf("%w: alias %s of %s points to %s, which is not in the bundle", ErrInvalidBundle, alias, id, version)
+			}
+		}
+	}
+
+	const page = 100
+	for start := 0; start < len(prompts); start += page {
+		end := start + page
+		if end > len(prompts) {
+			end = len(prompts)
+		}
+		if err := StoreBatch(ctx, reg, prompts[start:end]); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+	}
+	// Promote each id's production version after the others, so it ends up as the production
+	// pointer even if older versions still have the production stage.
+	for _, e := range m.Prompts {
+		if e.Stage != "" && m.Production[e.ID] != e.Version {
+			if err := reg.Promote(ctx, e.ID, e.Version, e.Stage); err != nil {
+				return fmt.Errorf("import %s@%s: %w", e.ID, e.Version, err)
+			}
+		}
+	}
+	for id, version := range m.Production {
+		if err := reg.Promote(ctx, id, version, StageProduction); err != nil {
+			return fmt.Errorf("import %s@%s: %w", id, version, err)
+		}
+	}
+	for _, e := range m.Prompts {
+		if m.Production[e.ID] == e.Version && e.Stage != "" && e.Stage != StageProduction {
+			if err := reg.Promote(ctx, e.ID, e.Version, e.Stage); err != nil {
+				return fmt.Errorf("import %s@%s: %w", e.ID, e.Version, err)
+			}
+		}
+		if len(e.Tags) > 0 {
+			if err := reg.Tag(ctx, e.ID, e.Version, e.Tags); err != nil {
+				return fmt.Errorf("import %s@%s: %w", e.ID, e.Version, err)
+			}
+		}
+		setArchived := reg.Restore
+		if e.Archived {
+			setArchived = reg.Archive
+		}
+		if err := setArchived(ctx, e.ID, e.Version); err != nil {
+			return fmt.Errorf("import %s@%s: %w", e.ID, e.Version, err)
+		}
+	}
+	for id, aliases := range m.Aliases {
+		for alias, version := range aliases {
+			if err := SetAlias(ctx, reg, id, alias, version); err != nil {
+				return fmt.Errorf("import %s alias %s: %w", id, alias, err)
+			}
+		}
+	}
+	return nil
+}
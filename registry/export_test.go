@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemoryRegistry()
+	for _, p := range []*core.Prompt{
+		{ID: "p", Version: "1.0.0", Template: "a"},
+		{ID: "p", Version: "1.1.0", Template: "b"},
+		{ID: "p", Version: "2.0.0", Template: "c"},
+		{ID: "q", Version: "1.0.0", Template: "d"},
+	} {
+		require.NoError(t, src.Store(ctx, p))
+	}
+	require.NoError(t, src.Promote(ctx, "p", "1.1.0", StageProduction))
+	require.NoError(t, src.Promote(ctx, "p", "1.0.0", StageProduction))
+	require.NoError(t, src.Promote(ctx, "p", "2.0.0", StageStaging))
+	require.NoError(t, src.Tag(ctx, "p", "1.0.0", []string{"stable"}))
+	require.NoError(t, src.Archive(ctx, "q", "1.0.0"))
+	require.NoError(t, SetAlias(ctx, src, "p", "canary", "2.0.0"))
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(ctx, src, &buf))
+
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	for name, dst := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, dst.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "old"}))
+			require.NoError(t, Import(ctx, dst, bytes.NewReader(buf.Bytes())))
+
+			prod, err := dst.GetProduction(ctx, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "1.0.0", prod.Version)
+			assert.Equal(t, "a", prod.Template, "existing versions are overwritten")
+			infos, err := dst.ListVersions(ctx, "p")
+			require.NoError(t, err)
+			require.Len(t, infos, 3)
+			assert.Equal(t, StageProduction, infos[0].Stage)
+			assert.Equal(t, []string{"stable"}, infos[0].Tags)
+			assert.Equal(t, StageProduction, infos[1].Stage)
+			assert.Equal(t, StageStaging, infos[2].Stage)
+			canary, err := dst.Get(ctx, "p", "@canary")
+			require.NoError(t, err)
+			assert.Equal(t, "c", canary.Template)
+
+			_, err = dst.Get(ctx, "q", "1.0.0")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound, "archived")
+			qs, err := dst.ListVersions(ctx, "q")
+			require.NoError(t, err)
+			require.Len(t, qs, 1)
+			assert.True(t, qs[0].Archived)
+		})
+	}
+
+	empty := NewMemoryRegistry()
+	assert.ErrorIs(t, Import(ctx, empty, bytes.NewReader([]byte("not a tar"))), ErrInvalidBundle)
+	var noManifest bytes.Buffer
+	tw := tar.NewWriter(&noManifest)
+	require.NoError(t, writeTarFile(tw, "prompts/p/1.0.0.json", []byte(`{"id":"p","version":"1.0.0"}`), time.Now()))
+	require.NoError(t, tw.Close())
+	assert.ErrorIs(t, Import(ctx, empty, &noManifest), ErrInvalidBundle)
+	list, err := empty.List(ctx, Filter{IncludeArchived: true})
+	require.NoError(t, err)
+	assert.Empty(t, list, "invalid bundles import nothing")
+}
@@ -0,0 +1,422 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrNoRoute is returned by FederatedRegistry writes for prompt ids that no route covers. Reads of
+// such ids report core.ErrPromptNotFound.
+var ErrNoRoute = errors.New("registry: no registry for prompt id")
+
+// FederatedRegistry serves several registries as one, for organizations where teams own separate
+// backends but services need a single lookup surface. Each prompt id belongs to the registry whose
+// route is its longest prefix: with routes "search/" and "billing::" (a namespace, see
+// NamespaceSeparator), "search/rank" is served by the first; the route "" catches every other id.
+//
+// Calls for one id go to its registry. List queries every registry and merges the results in the
+// filter's order, so Offset, Limit, and Cursor work across them; prompts a registry holds under an
+// id that routes elsewhere are left out, so every id has exactly one source.
+type FederatedRegistry struct {
+	routes   map[string]Registry
+	prefixes []string // longest first
+}
+
+// NewFederated returns a registry serving routes, a map from id prefix to the registry that owns
+// the ids starting with it.
+func NewFederated(routes map[string]Registry) *FederatedRegistry {
+	f := &FederatedRegistry{routes: make(map[string]Registry, len(routes))}
+	for prefix, reg := range routes {
+		f.routes[prefix] = reg
+		f.prefixes = append(f.prefixes, prefix)
+	}
+	sort.Slice(f.prefixes, func(i, j int) bool {
+		if len(f.prefixes[i]) != len(f.prefixes[j]) {
+			return len(f.prefixes[i]) > len(f.prefixes[j])
+		}
+		return f.prefixes[i] < f.prefixes[j]
+	})
+	return f
+}
+
+// Route returns the prefix of the route that owns id and its registry; ok is false if none does.
+func (f *FederatedRegistry) Route(id string) (prefix string, reg Registry, ok bool) {
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(id, prefix) {
+			return prefix, f.routes[prefix], true
+		}
+	}
+	return "", nil, false
+}
+
+// owns reports whether reg is the registry that owns id.
+func (f *FederatedRegistry) owns(reg Registry, id string) bool {
+	_, owner, ok := f.Route(id)
+	return ok && owner == reg
+}
+
+// read returns the registry that owns id, or core.ErrPromptNotFound.
+func (f *FederatedRegistry) read(id string) (Registry, error) {
+	_, reg, ok := f.Route(id)
+	if !ok {
+		return nil, core.ErrPromptNotFound
+	}
+	return reg, nil
+}
+
+// write returns the registry that owns id, or ErrNoRoute.
+func (f *FederatedRegistry) write(id string) (Registry, error) {
+	_, reg, ok := f.Route(id)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrNoRoute, id)
+	}
+	return reg, nil
+}
+
+// registries returns each distinct registry once, in route order.
+func (f *FederatedRegistry) registries() []Registry {
+	var out []Registry
+	for _, prefix := range f.prefixes {
+		reg := f.routes[prefix]
+		dup := false
+		for _, r := range out {
+			dup = dup || r == reg
+		}
+		if !dup {
+			out = append(out, reg)
+		}
+	}
+	return out
+}
+
+// Get implements Registry.
+func (f *FederatedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	reg, err := f.read(id)
+	if err != nil {
+		return nil, err
+	}
+	return reg.Get(ctx, id, version)
+}
+
+// GetProduction implements Registry.
+func (f *FederatedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	reg, err := f.read(id)
+	if err != nil {
+		return nil, err
+	}
+	return reg.GetProduction(ctx, id)
+}
+
+// List implements Registry. Each registry is paged until it has returned the first Offset+Limit
+// matches after the Cursor that it owns (only for the filter's IDs it owns, if any are given), and
+// the merged results are paged in the filter's order.
+func (f *FederatedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	if _, _, err := filter.cursorKey(); err != nil {
+		return nil, err
+	}
+	want := filter.Offset + filter.limit()
+	var entries []ListEntry
+	for _, reg := range f.registries() {
+		sub := filter
+		sub.Offset, sub.Limit = 0, want
+		if len(filter.IDs) > 0 {
+			sub.IDs = nil
+			for _, id := range filter.IDs {
+				if f.owns(reg, id) {
+					sub.IDs = append(sub.IDs, id)
+				}
+			}
+			if len(sub.IDs) == 0 {
+				continue
+			}
+		}
+		// Rows held under ids that route elsewhere do not count towards the registry's share.
+		owned := 0
+		err := eachPage(ctx, reg, sub, func(page []*core.Prompt) (bool, error) {
+			for _, p := range page {
+				if f.owns(reg, p.ID) && owned < want {
+					entries = append(entries, ListEntry{ID: p.ID, Version: p.Version, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt, Prompt: p})
+					owned++
+				}
+			}
+			return owned >= want, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Each registry has applied the search, in its own way (e.g. Postgres full-text search).
+	merged := filter
+	merged.Query, merged.Metadata = "", nil
+	return merged.Page(entries, nil)
+}
+
+// ListVersions implements Registry.
+func (f *FederatedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	reg, err := f.read(id)
+	if err != nil {
+		return nil, err
+	}
+	return reg.ListVersions(ctx, id)
+}
+
+// Store implements Registry. It returns ErrNoRoute if no route owns the prompt's id.
+func (f *FederatedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	reg, err := f.write(prompt.ID)
+	if err != nil {
+		return err
+	}
+	return reg.Store(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer (if the owning registry does).
+func (f *FederatedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	reg, err := f.write(prompt.ID)
+	if err != nil {
+		return err
+	}
+	return StoreIfMatch(ctx, reg, prompt, revision)
+}
+
+// StoreBatch implements Batcher, with one batch per owning registry. A batch spanning registries
+// is not atomic: an error from one leaves the batches already stored in others in place.
+func (f *FederatedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	groups := make(map[Registry][]*core.Prompt)
+	for _, p := range prompts {
+		reg, err := f.write(p.ID)
+		if err != nil {
+			return err
+		}
+		groups[reg] = append(groups[reg], p)
+	}
+	for _, reg := range f.registries() {
+		if group := groups[reg]; len(group) > 0 {
+			if err := StoreBatch(ctx, reg, group); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteBatch implements Batcher, with one batch per owning registry (see StoreBatch).
+func (f *FederatedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	groups := make(map[Registry][]VersionRef)
+	for _, ref := range refs {
+		reg, err := f.read(ref.ID)
+		if err != nil {
+			return err
+		}
+		groups[reg] = append(groups[reg], ref)
+	}
+	for _, reg := range f.registries() {
+		if group := groups[reg]; len(group) > 0 {
+			if err := DeleteBatch(ctx, reg, group); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetMany implements Batcher, with one batch per owning registry. Refs no route owns are nil.
+func (f *FederatedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	out := make([]*core.Prompt, len(refs))
+	groups := make(map[Registry][]int)
+	for i, ref := range refs {
+		if _, reg, ok := f.Route(ref.ID); ok {
+			groups[reg] = append(groups[reg], i)
+		}
+	}
+	for reg, at := range groups {
+		group := make([]VersionRef, len(at))
+		for j, i := range at {
+			group[j] = refs[i]
+		}
+		prompts, err := GetMany(ctx, reg, group)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range at {
+			out[i] = prompts[j]
+		}
+	}
+	return out, nil
+}
+
+// Promote implements Registry.
+func (f *FederatedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	reg, err := f.read(id)
+	if err != nil {
+		return err
+	}
+	return reg.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if the owning registry does).
+func (f *FederatedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	reg, err := f.read(id)
+	if err != nil {
+		return err
+	}
+	return RollbackProduction(ctx, reg, id, from, version)
+}
+
+// Delete implements Registry.
+func (f *FederatedRegistry) Delete(ctx context.Context, id, version string) error {
+	reg, err := f.read(id)
+	if err != nil {
+		return err
+	}
+	return reg.Delete(ctx, id, version)
+}
+
+// Tag implements Registry.
+func (f *FederatedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	reg, err := f.read(id)
+	if err != nil {
+		return err
+	}
+	return reg.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry.
+func (f *FederatedRegistry) Archive(ctx context.Context, id, version string) error {
+	reg, err := f.read(id)
+	if err != nil {
+		return err
+	}
+	return reg.Archive(ctx, id, version)
+}
+
+// Restore implements Registry.
+func (f *FederatedRegistry) Restore(ctx context.Context, id, version string) error {
+	reg, err := f.read(id)
+	if err != nil {
+		return err
+	}
+	return reg.Restore(ctx, id, version)
+}
+
+// SetAlias implements Aliaser (if the owning registry does).
+func (f *FederatedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	reg, err := f.read(id)
+	if err != nil {
+		return err
+	}
+	return SetAlias(ctx, reg, id, alias, version)
+}
+
+// Aliases implements Aliaser (if the owning registry does).
+func (f *FederatedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	reg, err := f.read(id)
+	if err != nil {
+		return nil, err
+	}
+	return Aliases(ctx, reg, id)
+}
+
+// History implements Auditor (if the owning registry does).
+func (f *FederatedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	reg, err := f.read(id)
+	if err != nil {
+		return nil, err
+	}
+	return History(ctx, reg, id)
+}
+
+// Usage implements UsageReporter (if the owning registry does).
+func (f *FederatedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	reg, err := f.read(id)
+	if err != nil {
+		return UsageStats{}, err
+	}
+	return Usage(ctx, reg, id)
+}
+
+// ListUsage implements UsageReporter, merging the registries that track usage. It returns an error
+// if none does.
+func (f *FederatedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	var out []UsageStats
+	tracked := false
+	for _, reg := range f.registries() {
+		if _, ok := reg.(UsageReporter); !ok {
+			continue
+		}
+		tracked = true
+		stats, err := ListUsage(ctx, reg)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range stats {
+			if f.owns(reg, s.ID) {
+				out = append(out, s)
+			}
+		}
+	}
+	if !tracked {
+		return nil, fmt.Errorf("registry: %T does not track usage", f)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].LastRead.Before(out[j].LastRead) })
+	return out, nil
+}
+
+// WatchChanges implements ChangeWatcher, watching every registry that supports it; fn is called
+// for one change at a time. It returns an error if none does, and otherwise when ctx is done or a
+// watch fails.
+func (f *FederatedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	var watchers []Registry
+	for _, reg := range f.registries() {
+		if _, ok := reg.(ChangeWatcher); ok {
+			watchers = append(watchers, reg)
+		}
+	}
+	if len(watchers) == 0 {
+		return fmt.Errorf("registry: %T does not support watching for changes", f)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	errc := make(chan error, len(watchers))
+	for _, reg := range watchers {
+		go func(reg Registry) {
+			errc <- WatchChanges(ctx, reg, func(id string) {
+				if f.owns(reg, id) {
+					mu.Lock()
+					defer mu.Unlock()
+					fn(id)
+				}
+			})
+		}(reg)
+	}
+	err := <-errc
+	cancel()
+	for range watchers[1:] {
+		<-errc
+	}
+	return err
+}
+
+// Ensure FederatedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*FederatedRegistry)(nil)
+	_ ConditionalStorer    = (*FederatedRegistry)(nil)
+	_ Auditor              = (*FederatedRegistry)(nil)
+	_ UsageReporter        = (*FederatedRegistry)(nil)
+	_ ChangeWatcher        = (*FederatedRegistry)(nil)
+	_ Aliaser              = (*FederatedRegistry)(nil)
+	_ Batcher              = (*FederatedRegistry)(nil)
+	_ ProductionRollbacker = (*FederatedRegistry)(nil)
+)
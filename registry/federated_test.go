@@ -0,0 +1,119 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederatedRegistry(t *testing.T) {
+	ctx := context.Background()
+	search, billing := NewMemoryRegistry(), NewMemoryRegistry()
+	f := NewFederated(map[string]Registry{"search/": search, "billing/": billing})
+
+	for _, id := range []string{"search/rank", "search/expand", "billing/invoice"} {
+		require.NoError(t, f.Store(ctx, &core.Prompt{ID: id, Version: "1.0.0", Template: id}))
+		require.NoError(t, f.Promote(ctx, id, "1.0.0", StageProduction))
+	}
+	assert.ErrorIs(t, f.Store(ctx, &core.Prompt{ID: "other", Version: "1.0.0"}), ErrNoRoute)
+	// Held by billing under an id that routes to search: not visible through the federation.
+	require.NoError(t, billing.Store(ctx, &core.Prompt{ID: "search/stray", Version: "1.0.0"}))
+
+	p, err := f.GetProduction(ctx, "billing/invoice")
+	require.NoError(t, err)
+	assert.Equal(t, "billing/invoice", p.Template)
+	_, err = search.Get(ctx, "billing/invoice", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound, "stored in the owning registry only")
+	_, err = f.Get(ctx, "other", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	_, err = f.Get(ctx, "search/stray", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+	all, err := f.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"billing/invoice", "search/expand", "search/rank"}, promptIDs(all))
+
+	// Pages follow the merged order across registries.
+	var paged []string
+	filter := Filter{Limit: 2, Descending: true}
+	for {
+		page, next, err := ListPage(ctx, f, filter)
+		require.NoError(t, err)
+		paged = append(paged, promptIDs(page)...)
+		if next == "" {
+			break
+		}
+		filter.Cursor = next
+	}
+	assert.Equal(t, []string{"search/rank", "search/expand", "billing/invoice"}, paged)
+	page, err := f.List(ctx, Filter{Offset: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search/expand"}, promptIDs(page))
+
+	only, err := f.List(ctx, Filter{IDs: []string{"billing/invoice", "other"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"billing/invoice"}, promptIDs(only))
+
+	got, err := f.GetMany(ctx, []VersionRef{{ID: "search/rank", Version: "1.0.0"}, {ID: "other", Version: "1.0.0"}, {ID: "billing/invoice", Version: "1.0.0"}})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.NotNil(t, got[0])
+	assert.Nil(t, got[1])
+	assert.NotNil(t, got[2])
+}
+
+func TestFederatedRegistry_Route(t *testing.T) {
+	def, team := NewMemoryRegistry(), NewMemoryRegistry()
+	f := NewFederated(map[string]Registry{"": def, "team" + NamespaceSeparator: team})
+	prefix, reg, ok := f.Route(QualifyID("team", "summarize"))
+	assert.True(t, ok)
+	assert.Equal(t, "team::", prefix)
+	assert.Same(t, team, reg)
+	_, reg, ok = f.Route("summarize")
+	assert.True(t, ok)
+	assert.Same(t, def, reg, "the empty prefix catches every other id")
+}
+
+func TestFederatedRegistry_ListSkipsStrays(t *testing.T) {
+	ctx := context.Background()
+	def, team := NewMemoryRegistry(), NewMemoryRegistry()
+	f := NewFederated(map[string]Registry{"": def, "team/": team})
+	// The catch-all holds ids that the team route owns, sorting before its own.
+	for _, id := range []string{"team/a", "team/b", "team/c"} {
+		require.NoError(t, def.Store(ctx, &core.Prompt{ID: id, Version: "1.0.0"}))
+	}
+	for _, id := range []string{"x", "y", "team/z"} {
+		require.NoError(t, f.Store(ctx, &core.Prompt{ID: id, Version: "1.0.0"}))
+	}
+
+	page, err := f.List(ctx, Filter{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team/z", "x"}, promptIDs(page))
+	page, err = f.List(ctx, Filter{Offset: 1, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x", "y"}, promptIDs(page))
+
+	var paged []string
+	filter := Filter{Limit: 1}
+	for {
+		page, next, err := ListPage(ctx, f, filter)
+		require.NoError(t, err)
+		paged = append(paged, promptIDs(page)...)
+		if next == "" {
+			break
+		}
+		filter.Cursor = next
+	}
+	assert.Equal(t, []string{"team/z", "x", "y"}, paged)
+}
+
+func promptIDs(prompts []*core.Prompt) []string {
+	ids := make([]string, len(prompts))
+	for i, p := range prompts {
+		ids[i] = p.ID
+	}
+	return ids
+}
@@ -2,140 +2,324 @@
 package registry
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"sync"
 
 	"github.com/klejdi94/loom/core"
 )
 
-// FileRegistry stores prompts as JSON files in a directory.
-// File names: {id}_{version}.json (sanitized). Stage and tags in a separate meta file or embedded in filename is not used; stage/tags kept in memory for compatibility with interface.
+// FileRegistry stores prompts as JSON files (or in another format, see WithCodec), one directory
+// per prompt:
+//
+//	<dir>/<id>/<version>/prompt.json  the prompt
+//	<dir>/<id>/<version>/meta.json    stage, tags, archived flag, checksum
+//	<dir>/<id>/_production            the production version
+//	<dir>/<id>/_aliases.json          alias -> version
+//	<dir>/<id>/_audit.jsonl           audit log, one AuditEntry per line
+//
+// Ids and versions are encoded as single path segments (see fileSegment); names starting with "_"
+// are the registry's own. Nothing is cached in memory, so several processes can share the
+// directory, e.g. the CLI and a running loom-server, or replicas on an NFS volume: writes to a
+// prompt hold an exclusive lock on <dir>/<id>/_lock (flock(2) on Unix, released by the kernel if
+// the writer dies) and replace files by renaming a synced temporary file, so readers never see a
+// partial write and writers to different prompts never contend.
 type FileRegistry struct {
-	dir    string
-	mu     sync.RWMutex
-	stages map[string]string            // id -> version for production
-	tags   map[string][]string         // id:version -> tags
-	meta   map[string]map[string]stageMeta // id -> version -> meta
+	dir string
+	storage
 }
 
 type stageMeta struct {
-	Stage Stage   `json:"stage"`
-	Tags  []string `json:"tags,omitempty"`
+	Stage    Stage    `json:"stage"`
+	Tags     []string `json:"tags,omitempty"`
+	Archived bool     `json:"archived,omitempty"`
+	Checksum string   `json:"checksum,omitempty"`
 }
 
-// NewFileRegistry creates a file-based registry rooted at dir.
-func NewFileRegistry(dir string) (*FileRegistry, error) {
+const (
+	filePrompt     = "prompt.json"
+	fileMeta       = "meta.json"
+	fileProduction = "_production"
+	fileAliases    = "_aliases.json"
+	fileAudit      = "_audit.jsonl"
+	fileLock       = "_lock"
+)
+
+// NewFileRegistry creates a file-based registry rooted at dir. A directory in the earlier flat
+// layout ({id}_{version}.json files with a shared _meta.json) is migrated on open, holding the
+// lock <dir>/_lock so processes opening it at once migrate it once.
+func NewFileRegistry(dir string, opts ...StorageOption) (*FileRegistry, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("file registry: %w", err)
 	}
-	r := &FileRegistry{
-		dir:    dir,
-		stages: make(map[string]string),
-		tags:   make(map[string][]string),
-		meta:   make(map[string]map[string]stageMeta),
+	r := &FileRegistry{dir: dir, storage: newStorage(jsonCodec{indent: true}, opts)}
+	unlock, err := lockFile(context.Background(), filepath.Join(dir, fileLock))
+	if err != nil {
+		return nil, fmt.Errorf("file registry lock: %w", err)
 	}
-	if err := r.loadMeta(); err != nil {
-		return nil, err
+	defer unlock()
+	if err := r.migrate(); err != nil {
+		return nil, fmt.Errorf("file registry migrate: %w", err)
 	}
 	return r, nil
 }
 
-func (f *FileRegistry) filename(id, version string) string {
-	safeID := strings.ReplaceAll(strings.ReplaceAll(id, string(filepath.Separator), "_"), ":", "_")
-	safeVer := strings.ReplaceAll(strings.ReplaceAll(version, string(filepath.Separator), "_"), ":", "_")
-	return filepath.Join(f.dir, safeID+"_"+safeVer+".json")
+// fileSegment encodes an id or version as one path segment: ASCII letters, digits, '-', '.', and
+// '_' are kept and other bytes are percent-encoded, as is a leading '.' or '_' so segments never
+// clash with the registry's files, hidden files, or "." and "..".
+func fileSegment(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		keep := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' ||
+			(c == '.' || c == '_') && i > 0
+		if keep {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (f *FileRegistry) promptDir(id string) string {
+	return filepath.Join(f.dir, fileSegment(id))
+}
+
+func (f *FileRegistry) versionDir(id, version string) string {
+	return filepath.Join(f.promptDir(id), fileSegment(version))
 }
 
-func (f *FileRegistry) metaPath() string {
-	return filepath.Join(f.dir, "_meta.json")
+func (f *FileRegistry) path(id, version, name string) string {
+	return filepath.Join(f.versionDir(id, version), name)
 }
 
-func (f *FileRegistry) loadMeta() error {
-	path := f.metaPath()
-	data, err := os.ReadFile(path)
+// writeFile replaces path atomically with data, creating its directory if needed.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
 		return err
 	}
-	var out struct {
-		Production map[string]string                `json:"production"`
-		Meta       map[string]map[string]stageMeta `json:"meta"`
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
 	}
-	if err := json.Unmarshal(data, &out); err != nil {
-		return err
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
 	}
-	if out.Production != nil {
-		f.stages = out.Production
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
 	}
-	if out.Meta != nil {
-		f.meta = out.Meta
-		for id, vers := range f.meta {
-			for v, m := range vers {
-				f.tags[f.key(id, v)] = append([]string(nil), m.Tags...)
-			}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// lock takes the write lock of id (see lockFile), waiting while another writer holds it. The
+// returned func releases the lock and removes the prompt's directory if nothing else is in it.
+func (f *FileRegistry) lock(ctx context.Context, id string) (func(), error) {
+	dir := f.promptDir(id)
+	for {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		unlock, err := lockFile(ctx, filepath.Join(dir, fileLock))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // another writer removed the empty directory in between
+		}
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			unlock()
+			os.Remove(dir) // fails, harmlessly, unless the prompt has no files
+		}, nil
+	}
+}
+
+// lockAll locks the ids of refs in sorted order, so concurrent batches cannot deadlock.
+func (f *FileRegistry) lockAll(ctx context.Context, ids []string) (func(), error) {
+	ids = append([]string(nil), ids...)
+	sort.Strings(ids)
+	var unlocks []func()
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, id := range ids {
+		unlock, err := f.lock(ctx, id)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
+
+// exists returns core.ErrPromptNotFound if id@version has no prompt file.
+func (f *FileRegistry) exists(id, version string) error {
+	if _, err := os.Stat(f.path(id, version, filePrompt)); err != nil {
+		if os.IsNotExist(err) {
+			return core.ErrPromptNotFound
 		}
+		return err
 	}
 	return nil
 }
 
-func (f *FileRegistry) key(id, version string) string {
-	return id + ":" + version
+// readMeta returns the meta of id@version; a version without a meta file is in dev.
+func (f *FileRegistry) readMeta(id, version string) (stageMeta, error) {
+	m := stageMeta{Stage: StageDev}
+	data, err := os.ReadFile(f.path(id, version, fileMeta))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("file registry decode: %w", err)
+	}
+	return m, nil
 }
 
-func (f *FileRegistry) saveMeta() error {
-	path := f.metaPath()
-	out := struct {
-		Production map[string]string                `json:"production"`
-		Meta       map[string]map[string]stageMeta `json:"meta"`
-	}{
-		Production: f.stages,
-		Meta:       f.meta,
+func (f *FileRegistry) writeMeta(id, version string, m stageMeta) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
 	}
-	data, err := json.MarshalIndent(out, "", "  ")
+	return writeFile(f.path(id, version, fileMeta), data)
+}
+
+// updateMeta applies update to the meta of an existing version. Caller must hold id's lock.
+func (f *FileRegistry) updateMeta(id, version string, update func(*stageMeta)) error {
+	if err := f.exists(id, version); err != nil {
+		return err
+	}
+	m, err := f.readMeta(id, version)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	update(&m)
+	return f.writeMeta(id, version, m)
+}
+
+func (f *FileRegistry) production(id string) string {
+	data, _ := os.ReadFile(filepath.Join(f.promptDir(id), fileProduction))
+	return strings.TrimSpace(string(data))
+}
+
+func (f *FileRegistry) readAliases(id string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(f.promptDir(id), fileAliases))
+	if os.IsNotExist(err) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("file registry decode: %w", err)
+	}
+	return aliases, nil
 }
 
-// Store saves a prompt as a JSON file.
+// Store saves a prompt file, encoded with the registry's codec.
 func (f *FileRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	return f.StoreIfMatch(ctx, prompt, -1)
+}
+
+// StoreIfMatch implements ConditionalStorer. A negative revision stores unconditionally.
+func (f *FileRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
 	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
 		return fmt.Errorf("file registry: prompt id and version required")
 	}
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	path := f.filename(prompt.ID, prompt.Version)
+	unlock, err := f.lock(ctx, prompt.ID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := f.write(prompt, revision); err != nil {
+		return err
+	}
+	e := NewAuditEntry(ctx, AuditStore, prompt.ID, prompt.Version)
+	e.Revision = prompt.Revision
+	return f.record(prompt.ID, e)
+}
+
+// write stores prompt with the next revision and updates its meta; if revision >= 0 it must match
+// the stored one. Caller must hold the prompt's lock.
+func (f *FileRegistry) write(prompt *core.Prompt, revision int64) error {
+	path := f.path(prompt.ID, prompt.Version, filePrompt)
+	var current int64
+	if data, err := os.ReadFile(path); err == nil {
+		var old core.Prompt
+		if err := decodePrompt(data, &old); err != nil {
+			return fmt.Errorf("file registry decode: %w", err)
+		}
+		current = old.Revision
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if revision >= 0 && revision != current {
+		return core.ErrConflict
+	}
+	prompt.Revision = current + 1
 	// Marshal prompt; Validation funcs will be omitted
-	payload, err := json.MarshalIndent(prompt, "", "  ")
+	payload, err := encodePrompt(f.codec, prompt)
 	if err != nil {
 		return fmt.Errorf("file registry encode: %w", err)
 	}
-	if err := os.WriteFile(path, payload, 0644); err != nil {
+	if err := writeFile(path, payload); err != nil {
 		return err
 	}
-	if f.meta[prompt.ID] == nil {
-		f.meta[prompt.ID] = make(map[string]stageMeta)
-	}
-	if _, ok := f.meta[prompt.ID][prompt.Version]; !ok {
-		f.meta[prompt.ID][prompt.Version] = stageMeta{Stage: StageDev}
+	m, err := f.readMeta(prompt.ID, prompt.Version)
+	if err != nil {
+		return err
 	}
-	return f.saveMeta()
+	m.Checksum = Checksum(prompt)
+	return f.writeMeta(prompt.ID, prompt.Version, m)
 }
 
-// Get reads a prompt from disk.
+// Get reads a prompt (or "@alias") from disk. Archived versions are reported as not found.
 func (f *FileRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
-	f.mu.RLock()
-	path := f.filename(id, version)
-	f.mu.RUnlock()
-	data, err := os.ReadFile(path)
+	if alias, ok := ParseAlias(version); ok {
+		aliases, err := f.readAliases(id)
+		if err != nil {
+			return nil, err
+		}
+		return GetByAlias(ctx, f, id, alias, aliases[alias])
+	}
+	m, err := f.readMeta(id, version)
+	if err != nil {
+		return nil, err
+	}
+	if m.Archived {
+		return nil, core.ErrPromptNotFound
+	}
+	return f.read(id, version)
+}
+
+// read loads a prompt file regardless of its archived state.
+func (f *FileRegistry) read(id, version string) (*core.Prompt, error) {
+	data, err := os.ReadFile(f.path(id, version, filePrompt))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, core.ErrPromptNotFound
@@ -143,169 +327,514 @@ func (f *FileRegistry) Get(ctx context.Context, id, version string) (*core.Promp
 		return nil, err
 	}
 	var p core.Prompt
-	if err := json.Unmarshal(data, &p); err != nil {
+	if err := decodePrompt(data, &p); err != nil {
 		return nil, fmt.Errorf("file registry decode: %w", err)
 	}
-	if p.ID != id || p.Version != version {
-		p.ID = id
-		p.Version = version
-	}
-	return p.Copy(), nil
+	p.ID, p.Version = id, version
+	return &p, nil
 }
 
 // GetProduction returns the promoted production version for id.
 func (f *FileRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
-	f.mu.RLock()
-	version, ok := f.stages[id]
-	f.mu.RUnlock()
-	if !ok || version == "" {
+	version := f.production(id)
+	if version == "" {
 		return nil, core.ErrPromptNotFound
 	}
 	return f.Get(ctx, id, version)
 }
 
-// List lists prompts matching the filter (scans directory).
-func (f *FileRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
-	f.mu.RLock()
+// ids returns the ids with a directory in the registry.
+func (f *FileRegistry) ids() ([]string, error) {
 	entries, err := os.ReadDir(f.dir)
-	f.mu.RUnlock()
 	if err != nil {
 		return nil, err
 	}
-	var out []*core.Prompt
-	offset := filter.Offset
-	limit := filter.Limit
-	if limit <= 0 {
-		limit = 1000
-	}
+	var ids []string
 	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") || e.Name() == "_meta.json" {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), "_") || strings.HasPrefix(e.Name(), ".") {
 			continue
 		}
-		path := filepath.Join(f.dir, e.Name())
-		data, err := os.ReadFile(path)
-		if err != nil {
-			continue
-		}
-		var p core.Prompt
-		if err := json.Unmarshal(data, &p); err != nil {
-			continue
+		if id, err := url.PathUnescape(e.Name()); err == nil {
+			ids = append(ids, id)
 		}
-		if len(filter.IDs) > 0 && !contains(filter.IDs, p.ID) {
+	}
+	return ids, nil
+}
+
+// versions returns the versions of id with a prompt file, unordered.
+func (f *FileRegistry) versions(id string) ([]string, error) {
+	entries, err := os.ReadDir(f.promptDir(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), "_") || strings.HasPrefix(e.Name(), ".") {
 			continue
 		}
-		f.mu.RLock()
-		st := f.meta[p.ID][p.Version].Stage
-		tags := f.tags[f.key(p.ID, p.Version)]
-		f.mu.RUnlock()
-		if filter.Stage != "" && st != filter.Stage {
+		v, err := url.PathUnescape(e.Name())
+		if err != nil || f.exists(id, v) != nil {
 			continue
 		}
-		if len(filter.Tags) > 0 && !hasAll(tags, filter.Tags) {
-			continue
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// List lists prompts matching the filter (scans the prompt directories) in the filter's order.
+func (f *FileRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	ids := filter.IDs
+	if len(ids) == 0 {
+		var err error
+		if ids, err = f.ids(); err != nil {
+			return nil, err
 		}
-		if offset > 0 {
-			offset--
-			continue
+	}
+	var entries []ListEntry
+	for _, id := range ids {
+		versions, err := f.versions(id)
+		if err != nil {
+			return nil, err
 		}
-		out = append(out, p.Copy())
-		if len(out) >= limit {
-			break
+		for _, v := range versions {
+			m, err := f.readMeta(id, v)
+			if err != nil {
+				continue
+			}
+			if m.Archived && !filter.IncludeArchived {
+				continue
+			}
+			if filter.Stage != "" && m.Stage != filter.Stage {
+				continue
+			}
+			if len(filter.Tags) > 0 && !hasAll(m.Tags, filter.Tags) {
+				continue
+			}
+			p, err := f.read(id, v)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, ListEntry{ID: id, Version: v, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt, Prompt: p})
 		}
 	}
-	return out, nil
+	return filter.Page(entries, nil)
 }
 
-// ListVersions returns version info for an id (from meta and existing files).
+// ListVersions returns version info for an id.
 func (f *FileRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
-	f.mu.RLock()
-	versMeta := f.meta[id]
-	f.mu.RUnlock()
-	if versMeta == nil {
-		return nil, nil
+	versions, err := f.versions(id)
+	if err != nil {
+		return nil, err
 	}
 	var infos []VersionInfo
-	for version := range versMeta {
-		p, err := f.Get(ctx, id, version)
+	for _, version := range versions {
+		p, err := f.read(id, version)
 		if err != nil {
 			continue
 		}
-		f.mu.RLock()
-		st := versMeta[version].Stage
-		tags := f.tags[f.key(id, version)]
-		f.mu.RUnlock()
+		m, err := f.readMeta(id, version)
+		if err != nil {
+			return nil, err
+		}
 		infos = append(infos, VersionInfo{
 			ID:        id,
 			Version:   version,
-			Stage:     st,
-			Tags:      append([]string(nil), tags...),
+			Stage:     m.Stage,
+			Tags:      append([]string(nil), m.Tags...),
 			CreatedAt: p.CreatedAt,
 			UpdatedAt: p.UpdatedAt,
+			Archived:  m.Archived,
+			Checksum:  m.Checksum,
 		})
 	}
+	sortVersionInfos(infos)
 	return infos, nil
 }
 
 // Promote sets the stage for id+version and updates production pointer.
 func (f *FileRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	path := f.filename(id, version)
-	if _, err := os.Stat(path); err != nil {
-		if os.IsNotExist(err) {
-			return core.ErrPromptNotFound
-		}
+	unlock, err := f.lock(ctx, id)
+	if err != nil {
 		return err
 	}
-	if f.meta[id] == nil {
-		f.meta[id] = make(map[string]stageMeta)
+	defer unlock()
+	if err := f.updateMeta(id, version, func(m *stageMeta) { m.Stage = stage }); err != nil {
+		return err
 	}
-	f.meta[id][version] = stageMeta{Stage: stage, Tags: f.tags[f.key(id, version)]}
 	if stage == StageProduction {
-		f.stages[id] = version
+		if err := writeFile(filepath.Join(f.promptDir(id), fileProduction), []byte(version+"\n")); err != nil {
+			return err
+		}
+	}
+	e := NewAuditEntry(ctx, AuditPromote, id, version)
+	e.Stage = stage
+	return f.record(id, e)
+}
+
+// RollbackProduction implements ProductionRollbacker under id's file lock.
+func (f *FileRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	unlock, err := f.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := f.exists(id, version); err != nil {
+		return err
+	}
+	if current := f.production(id); current != from {
+		return fmt.Errorf("%w: %s production is %q, not %q", core.ErrConflict, id, current, from)
+	}
+	if err := f.updateMeta(id, version, func(m *stageMeta) { m.Stage = StageProduction }); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(f.promptDir(id), fileProduction), []byte(version+"\n")); err != nil {
+		return err
 	}
-	return f.saveMeta()
+	e := NewAuditEntry(ctx, AuditRollback, id, version)
+	e.Stage = StageProduction
+	return f.record(id, e)
 }
 
 // Delete removes the prompt file and meta.
 func (f *FileRegistry) Delete(ctx context.Context, id, version string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	path := f.filename(id, version)
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+	unlock, err := f.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := f.exists(id, version); err != nil {
+		return err
+	}
+	if err := f.remove(id, version); err != nil {
+		return err
+	}
+	return f.record(id, NewAuditEntry(ctx, AuditDelete, id, version))
+}
+
+// remove deletes the version's directory and, if it was in production, the production pointer.
+// Caller must hold id's lock.
+func (f *FileRegistry) remove(id, version string) error {
+	if err := os.RemoveAll(f.versionDir(id, version)); err != nil {
 		return err
 	}
-	if f.stages[id] == version {
-		delete(f.stages, id)
+	if f.production(id) == version {
+		if err := os.Remove(filepath.Join(f.promptDir(id), fileProduction)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// StoreBatch implements Batcher, locking every prompt of the batch before writing.
+func (f *FileRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	unlock, err := f.lockAll(ctx, refIDs(promptRefs(prompts)))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for _, p := range prompts {
+		if err := f.write(p, -1); err != nil {
+			return err
+		}
+		e := NewAuditEntry(ctx, AuditStore, p.ID, p.Version)
+		e.Revision = p.Revision
+		if err := f.record(p.ID, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteBatch implements Batcher. Nothing is deleted if one of the versions does not exist.
+func (f *FileRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	unlock, err := f.lockAll(ctx, refIDs(refs))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for _, ref := range refs {
+		if err := f.exists(ref.ID, ref.Version); err != nil {
+			if errors.Is(err, core.ErrPromptNotFound) {
+				return fmt.Errorf("%w: %s@%s", core.ErrPromptNotFound, ref.ID, ref.Version)
+			}
+			return err
+		}
 	}
-	if f.meta[id] != nil {
-		delete(f.meta[id], version)
+	for _, ref := range refs {
+		if err := f.remove(ref.ID, ref.Version); err != nil {
+			return err
+		}
+		if err := f.record(ref.ID, NewAuditEntry(ctx, AuditDelete, ref.ID, ref.Version)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMany implements Batcher.
+func (f *FileRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	out := make([]*core.Prompt, len(refs))
+	for i, ref := range refs {
+		if _, ok := ParseAlias(ref.Version); ok {
+			continue
+		}
+		p, err := f.Get(ctx, ref.ID, ref.Version)
+		if errors.Is(err, core.ErrPromptNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[i] = p
 	}
-	delete(f.tags, f.key(id, version))
-	return f.saveMeta()
+	return out, nil
 }
 
 // Tag sets tags for a prompt version.
 func (f *FileRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	if f.meta[id] == nil || f.meta[id][version].Stage == "" {
-		path := f.filename(id, version)
-		if _, err := os.Stat(path); err != nil {
-			if os.IsNotExist(err) {
-				return core.ErrPromptNotFound
-			}
+	unlock, err := f.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := f.updateMeta(id, version, func(m *stageMeta) { m.Tags = append([]string(nil), tags...) }); err != nil {
+		return err
+	}
+	e := NewAuditEntry(ctx, AuditTag, id, version)
+	e.Tags = append([]string(nil), tags...)
+	return f.record(id, e)
+}
+
+// Archive hides a prompt version from Get, GetProduction, and List until it is restored.
+func (f *FileRegistry) Archive(ctx context.Context, id, version string) error {
+	return f.setArchived(ctx, id, version, true)
+}
+
+// Restore makes an archived prompt version visible again.
+func (f *FileRegistry) Restore(ctx context.Context, id, version string) error {
+	return f.setArchived(ctx, id, version, false)
+}
+
+func (f *FileRegistry) setArchived(ctx context.Context, id, version string, archived bool) error {
+	unlock, err := f.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := f.updateMeta(id, version, func(m *stageMeta) { m.Archived = archived }); err != nil {
+		return err
+	}
+	action := AuditArchive
+	if !archived {
+		action = AuditRestore
+	}
+	return f.record(id, NewAuditEntry(ctx, action, id, version))
+}
+
+// SetAlias implements Aliaser; aliases are kept in the prompt's _aliases.json.
+func (f *FileRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if err := ValidateAlias(alias); err != nil {
+		return err
+	}
+	unlock, err := f.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	aliases, err := f.readAliases(id)
+	if err != nil {
+		return err
+	}
+	if version == "" {
+		delete(aliases, alias)
+	} else {
+		if err := f.exists(id, version); err != nil {
 			return err
 		}
-		if f.meta[id] == nil {
-			f.meta[id] = make(map[string]stageMeta)
+		aliases[alias] = version
+	}
+	path := filepath.Join(f.promptDir(id), fileAliases)
+	if len(aliases) == 0 {
+		err = os.Remove(path)
+		if os.IsNotExist(err) {
+			err = nil
 		}
-		f.meta[id][version] = stageMeta{Stage: StageDev, Tags: tags}
 	} else {
-		m := f.meta[id][version]
-		m.Tags = append([]string(nil), tags...)
-		f.meta[id][version] = m
+		data, _ := json.MarshalIndent(aliases, "", "  ")
+		err = writeFile(path, data)
+	}
+	if err != nil {
+		return err
 	}
-	f.tags[f.key(id, version)] = append([]string(nil), tags...)
-	return f.saveMeta()
+	e := NewAuditEntry(ctx, AuditAlias, id, version)
+	e.Alias = alias
+	return f.record(id, e)
+}
+
+// Aliases implements Aliaser.
+func (f *FileRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return f.readAliases(id)
+}
+
+// record appends entries as JSON lines to id's audit file. Caller must hold id's lock.
+func (f *FileRegistry) record(id string, entries ...AuditEntry) error {
+	var buf []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("file registry audit: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	fh, err := os.OpenFile(filepath.Join(f.promptDir(id), fileAudit), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("file registry audit: %w", err)
+	}
+	if _, err := fh.Write(buf); err != nil {
+		fh.Close()
+		return fmt.Errorf("file registry audit: %w", err)
+	}
+	return fh.Close()
+}
+
+// History implements Auditor by reading id's audit file.
+func (f *FileRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	fh, err := os.Open(filepath.Join(f.promptDir(id), fileAudit))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer fh.Close()
+	var out []AuditEntry
+	sc := bufio.NewScanner(fh)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("file registry audit decode: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
+
+// migrate moves a registry in the flat layout of earlier releases ({id}_{version}.json files, with
+// stages, tags, and aliases in _meta.json and every audit entry in _audit.jsonl) into per-prompt
+// directories. The old files are removed once everything has been written, so an interrupted
+// migration is completed on the next open.
+func (f *FileRegistry) migrate() error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	var old []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") && e.Name() != "_meta.json" {
+			old = append(old, filepath.Join(f.dir, e.Name()))
+		}
+	}
+	metaPath, auditPath := filepath.Join(f.dir, "_meta.json"), filepath.Join(f.dir, fileAudit)
+	var legacy struct {
+		Production map[string]string               `json:"production"`
+		Meta       map[string]map[string]stageMeta `json:"meta"`
+		Aliases    map[string]map[string]string    `json:"aliases"`
+	}
+	data, err := os.ReadFile(metaPath)
+	switch {
+	case os.IsNotExist(err):
+		if len(old) == 0 {
+			return nil
+		}
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("%s: %w", metaPath, err)
+		}
+	}
+	for _, path := range old {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var p core.Prompt
+		if err := json.Unmarshal(data, &p); err != nil || p.ID == "" || p.Version == "" {
+			continue // not a prompt file
+		}
+		if err := writeFile(f.path(p.ID, p.Version, filePrompt), data); err != nil {
+			return err
+		}
+		m, ok := legacy.Meta[p.ID][p.Version]
+		if !ok {
+			m.Stage = StageDev
+		}
+		if err := f.writeMeta(p.ID, p.Version, m); err != nil {
+			return err
+		}
+	}
+	for id, version := range legacy.Production {
+		if err := writeFile(filepath.Join(f.promptDir(id), fileProduction), []byte(version+"\n")); err != nil {
+			return err
+		}
+	}
+	for id, aliases := range legacy.Aliases {
+		if len(aliases) == 0 {
+			continue
+		}
+		data, _ := json.MarshalIndent(aliases, "", "  ")
+		if err := writeFile(filepath.Join(f.promptDir(id), fileAliases), data); err != nil {
+			return err
+		}
+	}
+	if err := f.migrateAudit(auditPath); err != nil {
+		return err
+	}
+	for _, path := range append(old, metaPath, auditPath) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateAudit splits the shared audit file at path into per-prompt audit files, replacing them.
+func (f *FileRegistry) migrateAudit(path string) error {
+	fh, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	lines := make(map[string][]byte)
+	sc := bufio.NewScanner(fh)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		lines[e.ID] = append(append(lines[e.ID], sc.Bytes()...), '\n')
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	for id, data := range lines {
+		if err := writeFile(filepath.Join(f.promptDir(id), fileAudit), data); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,145 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRegistryLayout(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	reg, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "greet", Version: "1.0.0", Template: "hi"}))
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "_team/x", Version: "1.0.0+b", Template: "yo"}))
+	require.NoError(t, reg.Promote(ctx, "greet", "1.0.0", StageProduction))
+	require.NoError(t, reg.SetAlias(ctx, "greet", "stable", "1.0.0"))
+	for _, name := range []string{"greet/1.0.0/prompt.json", "greet/1.0.0/meta.json", "greet/_production", "greet/_aliases.json", "greet/_audit.jsonl", "%5Fteam%2Fx/1.0.0%2Bb/prompt.json"} {
+		assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "greet", "_lock"))
+
+	got, err := reg.Get(ctx, "_team/x", "1.0.0+b")
+	require.NoError(t, err)
+	assert.Equal(t, "yo", got.Template)
+	all, err := reg.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+	history, err := reg.History(ctx, "_team/x")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	// A failed write leaves no directory behind for a prompt that does not exist.
+	assert.ErrorIs(t, reg.Tag(ctx, "nope", "1.0.0", nil), core.ErrPromptNotFound)
+	assert.NoDirExists(t, filepath.Join(dir, "nope"))
+}
+
+func TestFileRegistryConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// Two registries on one directory stand in for two processes sharing a volume.
+	a, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	b, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reg := a
+			if i%2 == 1 {
+				reg = b
+			}
+			p := &core.Prompt{ID: fmt.Sprintf("p%d", i%4), Version: "1.0.0", Template: "t"}
+			assert.NoError(t, reg.Store(ctx, p))
+			assert.NoError(t, reg.Tag(ctx, p.ID, p.Version, []string{fmt.Sprint(i)}))
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 4; i++ {
+		got, err := a.Get(ctx, fmt.Sprintf("p%d", i), "1.0.0")
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), got.Revision, "no store was lost")
+		history, err := b.History(ctx, got.ID)
+		require.NoError(t, err)
+		assert.Len(t, history, 10)
+	}
+}
+
+func TestFileRegistryMigrate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	write := func(name, data string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+	}
+	write("greet_1.0.0.json", `{"id":"greet","version":"1.0.0","template":"hi","revision":2}`)
+	write("greet_1.1.0.json", `{"id":"greet","version":"1.1.0","template":"hello","revision":1}`)
+	write("_meta.json", `{"production":{"greet":"1.0.0"},"meta":{"greet":{"1.0.0":{"stage":"production","tags":["stable"]},"1.1.0":{"stage":"dev","archived":true}}},"aliases":{"greet":{"stable":"1.0.0"}}}`)
+	write("_audit.jsonl", `{"action":"store","id":"greet","version":"1.0.0"}`+"\n"+`{"action":"promote","id":"greet","version":"1.0.0","stage":"production"}`+"\n")
+
+	reg, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	for _, name := range []string{"greet_1.0.0.json", "greet_1.1.0.json", "_meta.json", "_audit.jsonl"} {
+		assert.NoFileExists(t, filepath.Join(dir, name))
+	}
+	prod, err := reg.GetProduction(ctx, "greet")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", prod.Version)
+	assert.Equal(t, int64(2), prod.Revision)
+	_, err = reg.Get(ctx, "greet", "1.1.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound, "archived")
+	infos, err := reg.ListVersions(ctx, "greet")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, []string{"stable"}, infos[0].Tags)
+	aliased, err := reg.Get(ctx, "greet", "@stable")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", aliased.Version)
+	history, err := reg.History(ctx, "greet")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	// Opening a migrated registry again is a no-op.
+	_, err = NewFileRegistry(dir)
+	require.NoError(t, err)
+	history, err = reg.History(ctx, "greet")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestFileRegistryLock(t *testing.T) {
+	dir := t.TempDir()
+	reg, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	p := &core.Prompt{ID: "greet", Version: "1.0.0", Template: "hi"}
+	require.NoError(t, reg.Store(context.Background(), p))
+
+	unlock, err := reg.lock(context.Background(), "greet")
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, reg.Store(ctx, p), context.DeadlineExceeded, "a held lock blocks writers")
+	unlock()
+	require.NoError(t, reg.Store(context.Background(), p))
+	assert.NoFileExists(t, filepath.Join(dir, "greet", "_lock"))
+
+	if runtime.GOOS != "windows" {
+		// A lock file left by a writer that crashed does not block anyone.
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "greet", "_lock"), nil, 0644))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, reg.Store(ctx, p))
+	}
+}
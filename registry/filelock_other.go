@@ -0,0 +1,40 @@
+//go:build !unix
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// fileLockStale is the age after which a lock file is assumed to belong to a writer that crashed,
+// and is broken.
+const fileLockStale = 30 * time.Second
+
+// lockFile takes the lock at path by creating the file exclusively, waiting while another process
+// or goroutine holds it. Without flock a crashed writer's lock file stays behind, so one older
+// than fileLockStale is broken. The returned func removes the file, releasing the lock.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	for {
+		fh, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fh.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if st, err := os.Stat(path); err == nil && time.Since(st.ModTime()) > fileLockStale {
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
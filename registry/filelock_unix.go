@@ -0,0 +1,51 @@
+//go:build unix
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockFile takes an exclusive flock(2) on the file at path, creating it, and waits while another
+// process or goroutine holds it. The kernel releases the lock if its holder dies, so a crashed
+// writer never blocks the registry; Linux NFS clients map flock to NFS byte-range locks. The
+// returned func removes the file and releases the lock.
+func lockFile(ctx context.Context, path string) (func(), error) {
+	for {
+		fh, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		err = syscall.Flock(int(fh.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			// The writer we waited for removes the file when it is done, so the lock we got may be on
+			// an unlinked file that excludes nobody; only a lock on the file at path counts.
+			if held, err := os.Stat(path); err == nil && sameFile(fh, held) {
+				return func() {
+					os.Remove(path)
+					fh.Close() // releases the lock
+				}, nil
+			}
+			fh.Close()
+			continue
+		}
+		fh.Close()
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func sameFile(fh *os.File, fi os.FileInfo) bool {
+	st, err := fh.Stat()
+	return err == nil && os.SameFile(st, fi)
+}
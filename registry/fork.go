@@ -0,0 +1,160 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// LineageKey is the Prompt.Metadata key recording the version a prompt was forked from (see
+// WithLineage and ForkedFrom).
+const LineageKey = "loom_forked_from"
+
+// ForkOption configures Fork.
+type ForkOption func(*forkOptions)
+
+type forkOptions struct {
+	version string
+	lineage bool
+}
+
+// WithForkVersion stores the fork as version instead of the source's version.
+func WithForkVersion(version string) ForkOption {
+	return func(o *forkOptions) {
+		o.version = version
+	}
+}
+
+// WithLineage records the source id and version in the fork's metadata under LineageKey.
+func WithLineage() ForkOption {
+	return func(o *forkOptions) {
+		o.lineage = true
+	}
+}
+
+// Fork copies srcID@srcVersion (the production version if srcVersion is "", or an "@alias") to
+// dstID, so a prompt can be branched for experiments without re-entering it. The copy has the
+// source's content, variables, examples, tools, and metadata, and starts in StageDev with new
+// timestamps and no tags or aliases. It fails with core.ErrConflict if dstID already has that
+// version, and returns the stored fork.
+func Fork(ctx context.Context, reg Registry, srcID, srcVersion, dstID string, opts ...ForkOption) (*core.Prompt, error) {
+	var o forkOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if dstID == "" {
+		return nil, fmt.Errorf("registry: fork requires a destination id")
+	}
+	var src *core.Prompt
+	var err error
+	if srcVersion == "" {
+		src, err = reg.GetProduction(ctx, srcID)
+	} else {
+		src, err = reg.Get(ctx, srcID, srcVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := deepCopy(src)
+	p.ID = dstID
+	if o.version != "" {
+		p.Version = o.version
+	}
+	if o.lineage {
+		p.Metadata[LineageKey] = map[string]interface{}{"id": src.ID, "version": src.Version}
+	}
+	p.Revision = 0
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	if cs, ok := reg.(ConditionalStorer); ok {
+		err = cs.StoreIfMatch(ctx, p, 0)
+	} else {
+		err = storeNew(ctx, reg, p)
+	}
+	if errors.Is(err, core.ErrConflict) {
+		return nil, fmt.Errorf("%w: %s@%s already exists", core.ErrConflict, p.ID, p.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// storeNew stores p unless its version already exists (even archived), for registries without
+// ConditionalStorer; the check and the store are not atomic.
+func storeNew(ctx context.Context, reg Registry, p *core.Prompt) error {
+	infos, err := reg.ListVersions(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	for _, vi := range infos {
+		if vi.Version == p.Version {
+			return core.ErrConflict
+		}
+	}
+	return reg.Store(ctx, p)
+}
+
+// ForkedFrom returns the version p was forked from, if it was forked WithLineage.
+func ForkedFrom(p *core.Prompt) (VersionRef, bool) {
+	m, ok := p.Metadata[LineageKey].(map[string]interface{})
+	if !ok {
+		return VersionRef{}, false
+	}
+	id, _ := m["id"].(string)
+	version, _ := m["version"].(string)
+	return VersionRef{ID: id, Version: version}, id != ""
+}
+
+// deepCopy returns a copy of p that shares no maps or slices with it, including example inputs,
+// tool and output schemas, variable defaults, and nested metadata.
+func deepCopy(p *core.Prompt) *core.Prompt {
+	q := p.Copy()
+	for i := range q.Variables {
+		q.Variables[i].Default = copyValue(q.Variables[i].Default)
+	}
+	for i := range q.Examples {
+		q.Examples[i].Input = copyMap(q.Examples[i].Input)
+	}
+	for i := range q.Tools {
+		q.Tools[i].Parameters = copyMap(q.Tools[i].Parameters)
+	}
+	q.OutputSchema = copyMap(q.OutputSchema)
+	q.Metadata = copyMap(q.Metadata)
+	if q.Metadata == nil {
+		q.Metadata = make(map[string]interface{})
+	}
+	return q
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = copyValue(v)
+	}
+	return out
+}
+
+// copyValue copies the maps and slices of JSON-like values; other values are returned as is.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyMap(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = copyValue(e)
+		}
+		return out
+	case []string:
+		return append([]string(nil), v...)
+	default:
+		return v
+	}
+}
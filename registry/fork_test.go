@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFork(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemoryRegistry()
+	src := &core.Prompt{ID: "support", Version: "2.0.0", System: "Be kind.", Template: "{{.q}}",
+		Variables: []core.Variable{{Name: "q", Type: core.VariableTypeString, Required: true}},
+		Examples:  []core.Example{{Input: map[string]interface{}{"q": "hi"}, Output: "hello"}},
+		Metadata:  map[string]interface{}{"team": map[string]interface{}{"name": "cx"}}}
+	require.NoError(t, reg.Store(ctx, src))
+	require.NoError(t, reg.Promote(ctx, "support", "2.0.0", StageProduction))
+	require.NoError(t, reg.Tag(ctx, "support", "2.0.0", []string{"approved"}))
+
+	fork, err := Fork(ctx, reg, "support", "", "support-exp", WithLineage())
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", fork.Version)
+	assert.Equal(t, int64(1), fork.Revision)
+	ref, ok := ForkedFrom(fork)
+	assert.True(t, ok)
+	assert.Equal(t, VersionRef{ID: "support", Version: "2.0.0"}, ref)
+
+	got, err := reg.Get(ctx, "support-exp", "2.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, src.System, got.System)
+	assert.Equal(t, src.Variables, got.Variables)
+	assert.Equal(t, src.Examples, got.Examples)
+	ref, ok = ForkedFrom(got)
+	assert.True(t, ok)
+	assert.Equal(t, "support", ref.ID)
+	infos, err := reg.ListVersions(ctx, "support-exp")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, StageDev, infos[0].Stage)
+	assert.Empty(t, infos[0].Tags)
+
+	// The fork shares nothing with the source.
+	fork.Examples[0].Input["q"] = "changed"
+	fork.Metadata["team"].(map[string]interface{})["name"] = "changed"
+	orig, err := reg.Get(ctx, "support", "2.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "hi", orig.Examples[0].Input["q"])
+	assert.Equal(t, "cx", orig.Metadata["team"].(map[string]interface{})["name"])
+	_, ok = ForkedFrom(orig)
+	assert.False(t, ok)
+
+	_, err = Fork(ctx, reg, "support", "2.0.0", "support-exp")
+	assert.ErrorIs(t, err, core.ErrConflict)
+	fork, err = Fork(ctx, reg, "support", "2.0.0", "support-exp", WithForkVersion("0.1.0"))
+	require.NoError(t, err)
+	assert.Equal(t, "0.1.0", fork.Version)
+	_, ok = ForkedFrom(fork)
+	assert.False(t, ok)
+	_, err = Fork(ctx, reg, "nope", "", "x")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+}
@@ -0,0 +1,145 @@
+// Package fsblob provides a local filesystem BlobStore for use with registry.NewS3Registry, so the
+// S3 key layout can be used without object storage: in tests, on a developer machine, or in
+// air-gapped deployments. Each key is a file under the store's directory, with "/" in keys as
+// directory separators.
+package fsblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klejdi94/loom/registry"
+)
+
+// Store implements registry.BlobStore on a local directory. Writes go to a temporary file that is
+// renamed into place, so readers never see a partial object.
+type Store struct {
+	dir string
+}
+
+// New creates a BlobStore rooted at dir, creating the directory if needed.
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// path maps key to a file under the store's directory, rejecting keys that would escape it.
+func (s *Store) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return "", fmt.Errorf("fsblob: invalid key %q", key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", fmt.Errorf("fsblob: invalid key %q", key)
+		}
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Get implements registry.BlobStore. A missing key is an error wrapping fs.ErrNotExist.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Put implements registry.BlobStore.
+func (s *Store) Put(ctx context.Context, key string, body []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// List implements registry.BlobStore. Like S3, it returns every key starting with prefix, in
+// lexical order; prefix need not end at a "/".
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	// Walk only the deepest directory that prefix names in full.
+	root := s.dir
+	if i := strings.LastIndexByte(prefix, '/'); i >= 0 {
+		if dir, err := s.path(prefix[:i]); err == nil {
+			root = dir
+		}
+	}
+	var keys []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete implements registry.BlobStore. Like S3, deleting a key that does not exist succeeds.
+// Directories left empty are removed.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(path); dir != filepath.Clean(s.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Ensure Store implements registry.BlobStore at compile time.
+var _ registry.BlobStore = (*Store)(nil)
@@ -0,0 +1,98 @@
+// Package gcsblob provides a Google Cloud Storage BlobStore for use with registry.NewS3Registry.
+// Use: go get cloud.google.com/go/storage
+package gcsblob
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/klejdi94/loom/registry"
+	"google.golang.org/api/iterator"
+)
+
+// Store implements registry.BlobStore using Google Cloud Storage.
+type Store struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// New creates a BlobStore that uses the given GCS client, bucket, and object name prefix.
+func New(client *storage.Client, bucket, prefix string) *Store {
+	return &Store{client: client, bucket: bucket, prefix: prefix}
+}
+
+// NewDefault creates a BlobStore using Application Default Credentials (e.g.
+// GOOGLE_APPLICATION_CREDENTIALS or the metadata server).
+func NewDefault(ctx context.Context, bucket, prefix string) (*Store, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return New(client, bucket, prefix), nil
+}
+
+func (s *Store) fullKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + key
+}
+
+func (s *Store) object(key string) *storage.ObjectHandle {
+	return s.client.Bucket(s.bucket).Object(s.fullKey(key))
+}
+
+// Get implements registry.BlobStore.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.object(key).NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Put implements registry.BlobStore.
+func (s *Store) Put(ctx context.Context, key string, body []byte) error {
+	w := s.object(key).NewWriter(ctx)
+	if _, err := w.Write(body); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// List implements registry.BlobStore. Returns object names (with prefix stripped if using prefix).
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: s.fullKey(prefix)})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		k := attrs.Name
+		if s.prefix != "" && len(k) >= len(s.prefix) {
+			k = k[len(s.prefix):]
+		}
+		keys = append(keys, k)
+	}
+}
+
+// Delete implements registry.BlobStore. Like S3, deleting an object that does not exist succeeds.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	err := s.object(key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Ensure Store implements registry.BlobStore at compile time.
+var _ registry.BlobStore = (*Store)(nil)
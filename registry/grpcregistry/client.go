@@ -0,0 +1,348 @@
+package grpcregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/grpcregistry/registrypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Client implements registry.Registry against a RegistryService. The actor set on a write's
+// context with registry.WithActor is sent as "x-loom-actor" metadata for the server's audit log,
+// and the namespace set with registry.WithNamespace as "x-loom-namespace" on every call.
+type Client struct {
+	rpc registrypb.RegistryServiceClient
+}
+
+// NewClient creates a registry client on an existing connection (e.g. from grpc.NewClient).
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{rpc: registrypb.NewRegistryServiceClient(conn)}
+}
+
+// apiKeyCredentials attaches an API key to each call as "authorization: Bearer <key>".
+type apiKeyCredentials string
+
+// APIKey returns per-RPC credentials for a server configured with Server.WithAPIKeys:
+//
+//	grpc.NewClient(addr, grpc.WithTransportCredentials(creds), grpc.WithPerRPCCredentials(grpcregistry.APIKey(key)))
+//
+// The key is also sent over insecure connections, so use TLS outside trusted networks.
+func APIKey(key string) credentials.PerRPCCredentials {
+	return apiKeyCredentials(key)
+}
+
+func (k apiKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(k)}, nil
+}
+
+func (k apiKeyCredentials) RequireTransportSecurity() bool { return false }
+
+// Store implements registry.Registry.
+func (c *Client) Store(ctx context.Context, prompt *core.Prompt) error {
+	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
+		return fmt.Errorf("grpc registry: prompt id and version required")
+	}
+	msg, err := toProto(prompt)
+	if err != nil {
+		return fmt.Errorf("grpc registry encode: %w", err)
+	}
+	resp, err := c.rpc.Store(withActor(ctx), &registrypb.StoreRequest{Prompt: msg})
+	if err != nil {
+		return fromStatus(err)
+	}
+	prompt.Revision = resp.GetRevision()
+	return nil
+}
+
+// StoreIfMatch implements registry.ConditionalStorer; a revision mismatch is returned as core.ErrConflict.
+func (c *Client) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
+		return fmt.Errorf("grpc registry: prompt id and version required")
+	}
+	msg, err := toProto(prompt)
+	if err != nil {
+		return fmt.Errorf("grpc registry encode: %w", err)
+	}
+	resp, err := c.rpc.Store(withActor(ctx), &registrypb.StoreRequest{Prompt: msg, Conditional: true, Revision: revision})
+	if err != nil {
+		return fromStatus(err)
+	}
+	prompt.Revision = resp.GetRevision()
+	return nil
+}
+
+// Get implements registry.Registry.
+func (c *Client) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	msg, err := c.rpc.Get(withNamespace(ctx), &registrypb.GetRequest{Id: id, Version: version})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return fromProto(msg), nil
+}
+
+// GetProduction implements registry.Registry.
+func (c *Client) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	msg, err := c.rpc.GetProduction(withNamespace(ctx), &registrypb.GetProductionRequest{Id: id})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return fromProto(msg), nil
+}
+
+// List implements registry.Registry by draining the server stream.
+func (c *Client) List(ctx context.Context, filter registry.Filter) ([]*core.Prompt, error) {
+	var out []*core.Prompt
+	err := c.ListStream(ctx, filter, func(p *core.Prompt) error {
+		out = append(out, p)
+		return nil
+	})
+	return out, err
+}
+
+// ListStream calls fn for each matching prompt as it arrives, without buffering the whole result.
+// Returning an error from fn cancels the stream and is returned.
+func (c *Client) ListStream(ctx context.Context, filter registry.Filter, fn func(*core.Prompt) error) error {
+	if filter.Namespace != "" {
+		ctx = registry.WithNamespace(ctx, filter.Namespace)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.rpc.List(withNamespace(ctx), &registrypb.ListRequest{
+		Ids:             filter.IDs,
+		Stage:           string(filter.Stage),
+		Tags:            filter.Tags,
+		Limit:           int32(filter.Limit),
+		Offset:          int32(filter.Offset),
+		IncludeArchived: filter.IncludeArchived,
+		Query:           filter.Query,
+		Metadata:        filter.Metadata,
+		SortBy:          string(filter.SortBy),
+		Descending:      filter.Descending,
+		Cursor:          filter.Cursor,
+	})
+	if err != nil {
+		return fromStatus(err)
+	}
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fromStatus(err)
+		}
+		if err := fn(fromProto(msg)); err != nil {
+			return err
+		}
+	}
+}
+
+// ListVersions implements registry.Registry.
+func (c *Client) ListVersions(ctx context.Context, id string) ([]registry.VersionInfo, error) {
+	stream, err := c.rpc.ListVersions(withNamespace(ctx), &registrypb.ListVersionsRequest{Id: id})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	var out []registry.VersionInfo
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fromStatus(err)
+		}
+		out = append(out, fromVersionInfo(msg))
+	}
+}
+
+// Promote implements registry.Registry.
+func (c *Client) Promote(ctx context.Context, id, version string, stage registry.Stage) error {
+	_, err := c.rpc.Promote(withActor(ctx), &registrypb.PromoteRequest{Id: id, Version: version, Stage: string(stage)})
+	return fromStatus(err)
+}
+
+// Delete implements registry.Registry.
+func (c *Client) Delete(ctx context.Context, id, version string) error {
+	_, err := c.rpc.Delete(withActor(ctx), &registrypb.DeleteRequest{Id: id, Version: version})
+	return fromStatus(err)
+}
+
+// Tag implements registry.Registry.
+func (c *Client) Tag(ctx context.Context, id, version string, tags []string) error {
+	_, err := c.rpc.Tag(withActor(ctx), &registrypb.TagRequest{Id: id, Version: version, Tags: tags})
+	return fromStatus(err)
+}
+
+// Archive implements registry.Registry.
+func (c *Client) Archive(ctx context.Context, id, version string) error {
+	_, err := c.rpc.Archive(withActor(ctx), &registrypb.ArchiveRequest{Id: id, Version: version})
+	return fromStatus(err)
+}
+
+// Restore implements registry.Registry.
+func (c *Client) Restore(ctx context.Context, id, version string) error {
+	_, err := c.rpc.Restore(withActor(ctx), &registrypb.RestoreRequest{Id: id, Version: version})
+	return fromStatus(err)
+}
+
+// History implements registry.Auditor by draining the server stream.
+func (c *Client) History(ctx context.Context, id string) ([]registry.AuditEntry, error) {
+	stream, err := c.rpc.History(withNamespace(ctx), &registrypb.HistoryRequest{Id: id})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	var out []registry.AuditEntry
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fromStatus(err)
+		}
+		out = append(out, fromAuditEntry(msg))
+	}
+}
+
+// SetAlias implements registry.Aliaser.
+func (c *Client) SetAlias(ctx context.Context, id, alias, version string) error {
+	_, err := c.rpc.SetAlias(withActor(ctx), &registrypb.SetAliasRequest{Id: id, Alias: alias, Version: version})
+	return fromStatus(err)
+}
+
+// Aliases implements registry.Aliaser.
+func (c *Client) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	resp, err := c.rpc.Aliases(withNamespace(ctx), &registrypb.AliasesRequest{Id: id})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	aliases := resp.GetAliases()
+	if aliases == nil {
+		aliases = map[string]string{}
+	}
+	return aliases, nil
+}
+
+// StoreBatch implements registry.Batcher with one call.
+func (c *Client) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := registry.ValidateBatch(prompts); err != nil {
+		return err
+	}
+	req := &registrypb.StoreBatchRequest{Prompts: make([]*registrypb.Prompt, len(prompts))}
+	for i, p := range prompts {
+		msg, err := toProto(p)
+		if err != nil {
+			return fmt.Errorf("grpc registry encode %s@%s: %w", p.ID, p.Version, err)
+		}
+		req.Prompts[i] = msg
+	}
+	resp, err := c.rpc.StoreBatch(withActor(ctx), req)
+	if err != nil {
+		return fromStatus(err)
+	}
+	if revisions := resp.GetRevisions(); len(revisions) == len(prompts) {
+		for i, p := range prompts {
+			p.Revision = revisions[i]
+		}
+	}
+	return nil
+}
+
+// DeleteBatch implements registry.Batcher with one call.
+func (c *Client) DeleteBatch(ctx context.Context, refs []registry.VersionRef) error {
+	if err := registry.ValidateRefs(refs); err != nil {
+		return err
+	}
+	_, err := c.rpc.DeleteBatch(withActor(ctx), &registrypb.DeleteBatchRequest{Refs: toVersionRefs(refs)})
+	return fromStatus(err)
+}
+
+// GetMany implements registry.Batcher with one call.
+func (c *Client) GetMany(ctx context.Context, refs []registry.VersionRef) ([]*core.Prompt, error) {
+	resp, err := c.rpc.GetMany(withNamespace(ctx), &registrypb.GetManyRequest{Refs: toVersionRefs(refs)})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	if len(resp.GetResults()) != len(refs) {
+		return nil, fmt.Errorf("grpc registry: got %d results for %d refs", len(resp.GetResults()), len(refs))
+	}
+	out := make([]*core.Prompt, len(refs))
+	for i, r := range resp.GetResults() {
+		if r.GetPrompt() != nil {
+			out[i] = fromProto(r.GetPrompt())
+		}
+	}
+	return out, nil
+}
+
+// withActor forwards ctx's audit actor and namespace as outgoing metadata.
+func withActor(ctx context.Context) context.Context {
+	if actor := registry.ActorFromContext(ctx); actor != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, actorMetadata, actor)
+	}
+	return withNamespace(ctx)
+}
+
+// withNamespace forwards ctx's namespace as outgoing metadata.
+func withNamespace(ctx context.Context) context.Context {
+	if ns := registry.NamespaceFromContext(ctx); ns != "" {
+		return metadata.AppendToOutgoingContext(ctx, namespaceMetadata, ns)
+	}
+	return ctx
+}
+
+// fromStatus maps gRPC status codes back to registry errors.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		return core.ErrPromptNotFound
+	case codes.Aborted:
+		return core.ErrConflict
+	case codes.PermissionDenied:
+		return fmt.Errorf("%w%s", registry.ErrForbidden, strings.TrimPrefix(st.Message(), registry.ErrForbidden.Error()))
+	case codes.FailedPrecondition:
+		return fmt.Errorf("%w%s", registry.ErrPromotionDenied, strings.TrimPrefix(st.Message(), registry.ErrPromotionDenied.Error()))
+	case codes.InvalidArgument:
+		for _, sentinel := range []error{registry.ErrInvalidAlias, registry.ErrInvalidBatch, registry.ErrInvalidNamespace, registry.ErrInvalidSort, registry.ErrInvalidCursor} {
+			if msg, ok := strings.CutPrefix(st.Message(), sentinel.Error()); ok {
+				return fmt.Errorf("%w%s", sentinel, msg)
+			}
+		}
+	case codes.ResourceExhausted:
+		if strings.HasPrefix(st.Message(), registry.ErrQuotaExceeded.Error()) {
+			return fmt.Errorf("%w%s", registry.ErrQuotaExceeded, strings.TrimPrefix(st.Message(), registry.ErrQuotaExceeded.Error()))
+		}
+		return registry.ErrRateLimited
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	}
+	return fmt.Errorf("grpc registry: %s", st.Message())
+}
+
+// Ensure Client implements registry.Registry, registry.Auditor, registry.Aliaser and registry.Batcher at compile time.
+var (
+	_ registry.Registry = (*Client)(nil)
+	_ registry.Auditor  = (*Client)(nil)
+	_ registry.Aliaser  = (*Client)(nil)
+	_ registry.Batcher  = (*Client)(nil)
+)
@@ -0,0 +1,41 @@
+package grpcregistry
+
+import (
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/grpcregistry/registrypb"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtoCodec is a registry.Codec that stores prompts as registrypb.Prompt messages, the compact
+// binary form served over gRPC; fields added to the message later are skipped by older readers.
+// Values must be JSON-compatible as for the gRPC API, and timestamps are read back in UTC.
+// Importing this package registers it as "protobuf":
+//
+//	reg, err := registry.NewFileRegistry(dir, registry.WithCodec(grpcregistry.ProtoCodec))
+var ProtoCodec registry.Codec = protoCodec{}
+
+func init() {
+	registry.RegisterCodec(ProtoCodec)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string { return "protobuf" }
+
+func (protoCodec) Marshal(p *core.Prompt) ([]byte, error) {
+	pb, err := toProto(p)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(pb)
+}
+
+func (protoCodec) Unmarshal(data []byte, p *core.Prompt) error {
+	var pb registrypb.Prompt
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	*p = *fromProto(&pb)
+	return nil
+}
@@ -0,0 +1,300 @@
+package grpcregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/grpcregistry/registrypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toProto converts a core.Prompt to its wire form. Values in defaults, example inputs, tool and
+// output schemas, and metadata must be JSON-compatible (strings, numbers, bools, slices, maps).
+func toProto(p *core.Prompt) (*registrypb.Prompt, error) {
+	out := &registrypb.Prompt{
+		Id:          p.ID,
+		Version:     p.Version,
+		Name:        p.Name,
+		Description: p.Description,
+		System:      p.System,
+		Template:    p.Template,
+		CreatedAt:   toTimestamp(p.CreatedAt),
+		UpdatedAt:   toTimestamp(p.UpdatedAt),
+		Revision:    p.Revision,
+	}
+	for _, m := range p.Messages {
+		pm := &registrypb.Message{Role: m.Role, Content: m.Content, History: m.History}
+		for _, a := range m.Attachments {
+			pm.Attachments = append(pm.Attachments, &registrypb.Attachment{MimeType: a.MIMEType, Url: a.URL, Data: a.Data, Name: a.Name})
+		}
+		out.Messages = append(out.Messages, pm)
+	}
+	for _, v := range p.Variables {
+		pv, err := toProtoVariable(v)
+		if err != nil {
+			return nil, err
+		}
+		out.Variables = append(out.Variables, pv)
+	}
+	for i, e := range p.Examples {
+		pe := &registrypb.Example{Output: e.Output, Weight: e.Weight}
+		if e.Input != nil {
+			in, err := newStruct(e.Input)
+			if err != nil {
+				return nil, fmt.Errorf("example %d input: %w", i, err)
+			}
+			pe.Input = in
+		}
+		out.Examples = append(out.Examples, pe)
+	}
+	for _, t := range p.Tools {
+		pt := &registrypb.Tool{Name: t.Name, Description: t.Description}
+		if t.Parameters != nil {
+			params, err := newStruct(t.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("tool %q parameters: %w", t.Name, err)
+			}
+			pt.Parameters = params
+		}
+		out.Tools = append(out.Tools, pt)
+	}
+	if p.Metadata != nil {
+		md, err := newStruct(p.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("metadata: %w", err)
+		}
+		out.Metadata = md
+	}
+	if p.OutputSchema != nil {
+		schema, err := newStruct(p.OutputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("output schema: %w", err)
+		}
+		out.OutputSchema = schema
+	}
+	return out, nil
+}
+
+// fromProto converts a wire prompt back to a core.Prompt (no renderer set).
+func fromProto(p *registrypb.Prompt) *core.Prompt {
+	out := &core.Prompt{
+		ID:          p.GetId(),
+		Version:     p.GetVersion(),
+		Name:        p.GetName(),
+		Description: p.GetDescription(),
+		System:      p.GetSystem(),
+		Template:    p.GetTemplate(),
+		CreatedAt:   fromTimestamp(p.GetCreatedAt()),
+		UpdatedAt:   fromTimestamp(p.GetUpdatedAt()),
+		Revision:    p.GetRevision(),
+	}
+	for _, m := range p.GetMessages() {
+		cm := core.Message{Role: m.GetRole(), Content: m.GetContent(), History: m.GetHistory()}
+		for _, a := range m.GetAttachments() {
+			cm.Attachments = append(cm.Attachments, core.Attachment{MIMEType: a.GetMimeType(), URL: a.GetUrl(), Data: a.GetData(), Name: a.GetName()})
+		}
+		out.Messages = append(out.Messages, cm)
+	}
+	for _, v := range p.GetVariables() {
+		out.Variables = append(out.Variables, fromProtoVariable(v))
+	}
+	for _, e := range p.GetExamples() {
+		ce := core.Example{Output: e.GetOutput(), Weight: e.GetWeight()}
+		if e.Input != nil {
+			ce.Input = e.Input.AsMap()
+		}
+		out.Examples = append(out.Examples, ce)
+	}
+	for _, t := range p.GetTools() {
+		ct := core.Tool{Name: t.GetName(), Description: t.GetDescription()}
+		if t.Parameters != nil {
+			ct.Parameters = t.Parameters.AsMap()
+		}
+		out.Tools = append(out.Tools, ct)
+	}
+	if p.Metadata != nil {
+		out.Metadata = p.Metadata.AsMap()
+	}
+	if p.OutputSchema != nil {
+		out.OutputSchema = p.OutputSchema.AsMap()
+	}
+	return out
+}
+
+// toProtoVariable converts a variable, and the fields it declares, to its wire form.
+func toProtoVariable(v core.Variable) (*registrypb.Variable, error) {
+	pv := &registrypb.Variable{
+		Name:        v.Name,
+		Type:        string(v.Type),
+		Required:    v.Required,
+		Description: v.Description,
+		Enum:        v.Enum,
+		Items:       string(v.Items),
+		MinLen:      int32(v.MinLen),
+		MaxLen:      int32(v.MaxLen),
+		Min:         v.Min,
+		Max:         v.Max,
+		Pattern:     v.Pattern,
+		Sensitive:   v.Sensitive,
+	}
+	for _, r := range v.Rules {
+		pv.Rules = append(pv.Rules, &registrypb.ValidationRule{Rule: r.Rule, Args: r.Args, Message: r.Message})
+	}
+	if v.Default != nil {
+		def, err := newValue(v.Default)
+		if err != nil {
+			return nil, fmt.Errorf("variable %q default: %w", v.Name, err)
+		}
+		pv.Default = def
+	}
+	for _, f := range v.Fields {
+		pf, err := toProtoVariable(f)
+		if err != nil {
+			return nil, fmt.Errorf("variable %q field: %w", v.Name, err)
+		}
+		pv.Fields = append(pv.Fields, pf)
+	}
+	return pv, nil
+}
+
+// fromProtoVariable converts a wire variable, and the fields it declares, back to a core.Variable.
+func fromProtoVariable(v *registrypb.Variable) core.Variable {
+	cv := core.Variable{
+		Name:        v.GetName(),
+		Type:        core.VariableType(v.GetType()),
+		Required:    v.GetRequired(),
+		Description: v.GetDescription(),
+		Enum:        v.GetEnum(),
+		Items:       core.VariableType(v.GetItems()),
+		MinLen:      int(v.GetMinLen()),
+		MaxLen:      int(v.GetMaxLen()),
+		Min:         v.Min,
+		Max:         v.Max,
+		Pattern:     v.GetPattern(),
+		Sensitive:   v.GetSensitive(),
+	}
+	for _, r := range v.GetRules() {
+		cv.Rules = append(cv.Rules, core.ValidationRule{Rule: r.GetRule(), Args: r.GetArgs(), Message: r.GetMessage()})
+	}
+	if v.Default != nil {
+		cv.Default = v.Default.AsInterface()
+	}
+	for _, f := range v.GetFields() {
+		cv.Fields = append(cv.Fields, fromProtoVariable(f))
+	}
+	return cv
+}
+
+// newValue is structpb.NewValue, falling back to a JSON round-trip for typed values such as
+// []string or map[string]string that structpb does not accept directly.
+func newValue(v interface{}) (*structpb.Value, error) {
+	if pv, err := structpb.NewValue(v); err == nil {
+		return pv, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(generic)
+}
+
+// newStruct is structpb.NewStruct with the same fallback as newValue.
+func newStruct(m map[string]interface{}) (*structpb.Struct, error) {
+	v, err := newValue(m)
+	if err != nil {
+		return nil, err
+	}
+	return v.GetStructValue(), nil
+}
+
+func toVersionInfo(vi registry.VersionInfo) *registrypb.VersionInfo {
+	return &registrypb.VersionInfo{
+		Id:        vi.ID,
+		Version:   vi.Version,
+		Stage:     string(vi.Stage),
+		Tags:      vi.Tags,
+		CreatedAt: toTimestamp(vi.CreatedAt),
+		UpdatedAt: toTimestamp(vi.UpdatedAt),
+		Archived:  vi.Archived,
+		Checksum:  vi.Checksum,
+	}
+}
+
+func fromVersionInfo(vi *registrypb.VersionInfo) registry.VersionInfo {
+	return registry.VersionInfo{
+		ID:        vi.GetId(),
+		Version:   vi.GetVersion(),
+		Stage:     registry.Stage(vi.GetStage()),
+		Tags:      vi.GetTags(),
+		CreatedAt: fromTimestamp(vi.GetCreatedAt()),
+		UpdatedAt: fromTimestamp(vi.GetUpdatedAt()),
+		Archived:  vi.GetArchived(),
+		Checksum:  vi.GetChecksum(),
+	}
+}
+
+func toAuditEntry(e registry.AuditEntry) *registrypb.AuditEntry {
+	return &registrypb.AuditEntry{
+		Id:       e.ID,
+		Version:  e.Version,
+		Action:   string(e.Action),
+		Actor:    e.Actor,
+		Time:     toTimestamp(e.Time),
+		Stage:    string(e.Stage),
+		Tags:     e.Tags,
+		Revision: e.Revision,
+		Alias:    e.Alias,
+	}
+}
+
+func fromAuditEntry(e *registrypb.AuditEntry) registry.AuditEntry {
+	return registry.AuditEntry{
+		ID:       e.GetId(),
+		Version:  e.GetVersion(),
+		Action:   registry.AuditAction(e.GetAction()),
+		Actor:    e.GetActor(),
+		Time:     fromTimestamp(e.GetTime()),
+		Stage:    registry.Stage(e.GetStage()),
+		Tags:     e.GetTags(),
+		Revision: e.GetRevision(),
+		Alias:    e.GetAlias(),
+	}
+}
+
+func toVersionRefs(refs []registry.VersionRef) []*registrypb.VersionRef {
+	out := make([]*registrypb.VersionRef, len(refs))
+	for i, ref := range refs {
+		out[i] = &registrypb.VersionRef{Id: ref.ID, Version: ref.Version}
+	}
+	return out
+}
+
+func fromVersionRefs(refs []*registrypb.VersionRef) []registry.VersionRef {
+	out := make([]registry.VersionRef, len(refs))
+	for i, ref := range refs {
+		out[i] = registry.VersionRef{ID: ref.GetId(), Version: ref.GetVersion()}
+	}
+	return out
+}
+
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
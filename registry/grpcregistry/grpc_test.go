@@ -0,0 +1,353 @@
+package grpcregistry
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/grpcregistry/registrypb"
+	"github.com/klejdi94/loom/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	return newClientFor(t, registry.NewMemoryRegistry())
+}
+
+// newClientFor returns a client of a server for reg.
+func newClientFor(t *testing.T, reg registry.Registry) *Client {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s, reg)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+func TestClient_Conformance(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) registry.Registry { return newTestClient(t) })
+}
+
+func TestClient_StoreGet(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	p := &core.Prompt{
+		ID: "p1", Version: "1.0.0", Template: "Hi {{.name}}",
+		Variables: []core.Variable{{Name: "name", Type: core.VariableTypeString, Required: true, Default: "there"}},
+		Examples:  []core.Example{{Input: map[string]interface{}{"name": "Bob"}, Output: "Hi Bob", Weight: 1}},
+		Tools: []core.Tool{{Name: "lookup", Parameters: map[string]interface{}{
+			"type": "object", "properties": map[string]interface{}{"q": map[string]interface{}{"type": "string"}},
+			"required": []string{"q"},
+		}}},
+		Metadata: map[string]interface{}{"owner": "team-a"},
+	}
+	require.NoError(t, c.Store(ctx, p))
+	got, err := c.Get(ctx, "p1", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi {{.name}}", got.Template)
+	require.Len(t, got.Variables, 1)
+	assert.Equal(t, "there", got.Variables[0].Default)
+	require.Len(t, got.Examples, 1)
+	assert.Equal(t, "Bob", got.Examples[0].Input["name"])
+	require.Len(t, got.Tools, 1)
+	assert.Equal(t, "lookup", got.Tools[0].Name)
+	assert.Equal(t, "object", got.Tools[0].Parameters["type"])
+	assert.Equal(t, "team-a", got.Metadata["owner"])
+}
+
+func TestClient_NotFound(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	_, err := c.Get(ctx, "missing", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	_, err = c.GetProduction(ctx, "missing")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	assert.ErrorIs(t, c.Delete(ctx, "missing", "1.0.0"), core.ErrPromptNotFound)
+}
+
+func TestClient_PromoteTagList(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p1", Version: "1.0.0"}))
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p1", Version: "2.0.0"}))
+	require.NoError(t, c.Promote(ctx, "p1", "2.0.0", registry.StageProduction))
+	require.NoError(t, c.Tag(ctx, "p1", "1.0.0", []string{"legacy"}))
+
+	prod, err := c.GetProduction(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", prod.Version)
+
+	tagged, err := c.List(ctx, registry.Filter{Tags: []string{"legacy"}})
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	assert.Equal(t, "1.0.0", tagged[0].Version)
+
+	vers, err := c.ListVersions(ctx, "p1")
+	require.NoError(t, err)
+	require.Len(t, vers, 2)
+	assert.Equal(t, registry.StageProduction, vers[1].Stage)
+}
+
+func TestClient_StoreIfMatch(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	p := &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}
+	require.NoError(t, c.StoreIfMatch(ctx, p, 0))
+	assert.Equal(t, int64(1), p.Revision)
+
+	got, err := c.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got.Revision)
+	require.NoError(t, c.StoreIfMatch(ctx, got, 1))
+	assert.Equal(t, int64(2), got.Revision)
+	assert.ErrorIs(t, c.StoreIfMatch(ctx, got.Copy(), 1), core.ErrConflict)
+}
+
+func TestServer_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	inner := registry.NewMemoryRegistry()
+	require.NoError(t, inner.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	registrypb.RegisterRegistryServiceServer(s, NewServer(inner).WithAPIKeys(map[string]registry.Scope{
+		"ci":    {Read: []registry.Stage{registry.StageDev}, Write: []registry.Stage{registry.StageDev}},
+		"slow":  {Read: []registry.Stage{registry.StageAny}},
+		"small": {Read: []registry.Stage{registry.StageAny}, Write: []registry.Stage{registry.StageAny}},
+	}).WithLimits(map[string]registry.Limits{
+		"slow":  {RequestsPerMinute: 1},
+		"small": {MaxVersions: 1},
+	}))
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	dial := func(opts ...grpc.DialOption) *Client {
+		opts = append(opts,
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return NewClient(conn)
+	}
+
+	ci := dial(grpc.WithPerRPCCredentials(APIKey("ci")))
+	_, err := ci.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.ErrorIs(t, ci.Promote(ctx, "p", "1.0.0", registry.StageProduction), registry.ErrForbidden)
+
+	_, err = dial().Get(ctx, "p", "1.0.0")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "API key")
+
+	slow := dial(grpc.WithPerRPCCredentials(APIKey("slow")))
+	_, err = slow.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	_, err = slow.Get(ctx, "p", "1.0.0")
+	assert.ErrorIs(t, err, registry.ErrRateLimited)
+
+	small := dial(grpc.WithPerRPCCredentials(APIKey("small")))
+	assert.ErrorIs(t, small.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v2"}), registry.ErrQuotaExceeded)
+}
+
+func TestClient_ArchiveRestore(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, c.Archive(ctx, "p", "1.0.0"))
+	_, err := c.Get(ctx, "p", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	list, err := c.List(ctx, registry.Filter{IncludeArchived: true})
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+	infos, err := c.ListVersions(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.True(t, infos[0].Archived)
+	require.NoError(t, c.Restore(ctx, "p", "1.0.0"))
+	_, err = c.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+}
+
+func TestClient_History(t *testing.T) {
+	ctx := registry.WithActor(context.Background(), "alice")
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, c.Tag(ctx, "p", "1.0.0", []string{"a", "b"}))
+	require.NoError(t, c.Delete(ctx, "p", "1.0.0"))
+	entries, err := c.History(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, registry.AuditStore, entries[0].Action)
+	assert.Equal(t, int64(1), entries[0].Revision)
+	assert.Equal(t, []string{"a", "b"}, entries[1].Tags)
+	assert.Equal(t, registry.AuditDelete, entries[2].Action)
+	for _, e := range entries {
+		assert.Equal(t, "alice", e.Actor)
+		assert.False(t, e.Time.IsZero())
+	}
+}
+
+func TestClient_Aliases(t *testing.T) {
+	ctx := registry.WithActor(context.Background(), "alice")
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, c.SetAlias(ctx, "p", "stable", "1.0.0"))
+	got, err := c.Get(ctx, "p", "@stable")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", got.Template)
+	aliases, err := c.Aliases(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"stable": "1.0.0"}, aliases)
+	assert.ErrorIs(t, c.SetAlias(ctx, "p", "-bad", "1.0.0"), registry.ErrInvalidAlias)
+	entries, err := c.History(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, registry.AuditAlias, entries[1].Action)
+	assert.Equal(t, "stable", entries[1].Alias)
+	assert.Equal(t, "alice", entries[1].Actor)
+}
+
+func TestClient_Batch(t *testing.T) {
+	ctx := registry.WithActor(context.Background(), "alice")
+	c := newTestClient(t)
+	prompts := []*core.Prompt{
+		{ID: "p", Version: "1.0.0", Template: "v1"},
+		{ID: "p", Version: "1.1.0", Template: "v2"},
+	}
+	require.NoError(t, c.StoreBatch(ctx, prompts))
+	assert.Equal(t, int64(1), prompts[1].Revision)
+	got, err := c.GetMany(ctx, []registry.VersionRef{{ID: "p", Version: "1.1.0"}, {ID: "x", Version: "1.0.0"}})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "v2", got[0].Template)
+	assert.Nil(t, got[1])
+
+	srv := NewServer(registry.NewMemoryRegistry())
+	_, err = srv.StoreBatch(ctx, &registrypb.StoreBatchRequest{Prompts: []*registrypb.Prompt{{Id: "p"}}})
+	assert.ErrorIs(t, fromStatus(err), registry.ErrInvalidBatch)
+
+	require.NoError(t, c.DeleteBatch(ctx, []registry.VersionRef{{ID: "p", Version: "1.0.0"}, {ID: "p", Version: "1.1.0"}}))
+	assert.ErrorIs(t, c.DeleteBatch(ctx, []registry.VersionRef{{ID: "p", Version: "1.0.0"}}), core.ErrPromptNotFound)
+	entries, err := c.History(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, registry.AuditDelete, entries[3].Action)
+	assert.Equal(t, "alice", entries[3].Actor)
+}
+
+func TestClient_Namespaces(t *testing.T) {
+	ctx := context.Background()
+	search := registry.WithNamespace(ctx, "search")
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "default"}))
+	require.NoError(t, c.Store(search, &core.Prompt{ID: "p", Version: "1.0.0", Template: "search"}))
+	require.NoError(t, c.Promote(search, "p", "1.0.0", registry.StageProduction))
+
+	got, err := c.GetProduction(search, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "search", got.Template)
+	_, err = c.GetProduction(ctx, "p")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	list, err := c.List(ctx, registry.Filter{Namespace: "search"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "p", list[0].ID)
+	assert.Equal(t, "search", list[0].Template)
+
+	_, err = c.Get(registry.WithNamespace(ctx, "bad ns"), "p", "1.0.0")
+	assert.ErrorIs(t, err, registry.ErrInvalidNamespace)
+}
+
+func TestClient_ListSearch(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "sum", Version: "1.0.0", Name: "Summarizer", Template: "Summarize {{.text}}",
+		Metadata: map[string]interface{}{"team": "search"}}))
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "tr", Version: "1.0.0", Template: "Translate {{.text}}"}))
+
+	list, err := c.List(ctx, registry.Filter{Query: "summarizer", Metadata: map[string]string{"team": "search"}})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "sum", list[0].ID)
+	list, err = c.List(ctx, registry.Filter{Metadata: map[string]string{"team": "i18n"}})
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestClient_ListCursor(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	for _, id := range []string{"c", "a", "b"} {
+		require.NoError(t, c.Store(ctx, &core.Prompt{ID: id, Version: "1.0.0", Template: "t"}))
+	}
+
+	var got []string
+	filter := registry.Filter{Limit: 2, Descending: true}
+	for {
+		page, next, err := registry.ListPage(ctx, c, filter)
+		require.NoError(t, err)
+		for _, p := range page {
+			got = append(got, p.ID)
+		}
+		if next == "" {
+			break
+		}
+		filter.Cursor = next
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, got)
+
+	_, err := c.List(ctx, registry.Filter{Cursor: "bogus"})
+	assert.ErrorIs(t, err, registry.ErrInvalidCursor)
+}
+
+func TestClient_PromotionDenied(t *testing.T) {
+	ctx := context.Background()
+	c := newClientFor(t, registry.NewGuarded(registry.NewMemoryRegistry(), registry.RequireStage(registry.StageProduction, registry.StageStaging)))
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "t"}))
+	err := c.Promote(ctx, "p", "1.0.0", registry.StageProduction)
+	assert.ErrorIs(t, err, registry.ErrPromotionDenied)
+	assert.Contains(t, err.Error(), "must be in staging first")
+	require.NoError(t, c.Promote(ctx, "p", "1.0.0", registry.StageStaging))
+	assert.NoError(t, c.Promote(ctx, "p", "1.0.0", registry.StageProduction))
+}
+
+func TestProtoCodec(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	reg, err := registry.NewFileRegistry(dir)
+	require.NoError(t, err)
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "json"}))
+
+	c, err := registry.CodecByName("gzip+protobuf")
+	require.NoError(t, err)
+	reg, err = registry.NewFileRegistry(dir, registry.WithCodec(c))
+	require.NoError(t, err)
+	p := &core.Prompt{ID: "p", Version: "2.0.0", Template: "Hi {{.name}}",
+		Variables: []core.Variable{{Name: "name", Type: core.VariableTypeString, Default: "you"},
+			{Name: "user", Type: core.VariableTypeObject, Fields: []core.Variable{{Name: "email", Type: core.VariableTypeString, Required: true}}}},
+		Metadata: map[string]interface{}{"owner": "team", "n": 2.0}}
+	require.NoError(t, reg.Store(ctx, p))
+
+	got, err := reg.Get(ctx, "p", "2.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, p.Variables, got.Variables)
+	assert.Equal(t, p.Metadata, got.Metadata)
+	assert.Equal(t, int64(1), got.Revision)
+	assert.NoError(t, registry.Verify(ctx, reg, "p", "2.0.0"))
+	got, err = reg.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "json", got.Template)
+}
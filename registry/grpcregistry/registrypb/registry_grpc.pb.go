@@ -0,0 +1,783 @@
+// gRPC definition of the loom prompt registry (mirrors registry.Registry).
+//
+// Regenerate with protoc (or buf) using protoc-gen-go and protoc-gen-go-grpc:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative registry.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: registry.proto
+
+package registrypb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	RegistryService_Store_FullMethodName         = "/loom.registry.v1.RegistryService/Store"
+	RegistryService_Get_FullMethodName           = "/loom.registry.v1.RegistryService/Get"
+	RegistryService_GetProduction_FullMethodName = "/loom.registry.v1.RegistryService/GetProduction"
+	RegistryService_List_FullMethodName          = "/loom.registry.v1.RegistryService/List"
+	RegistryService_ListVersions_FullMethodName  = "/loom.registry.v1.RegistryService/ListVersions"
+	RegistryService_Promote_FullMethodName       = "/loom.registry.v1.RegistryService/Promote"
+	RegistryService_Delete_FullMethodName        = "/loom.registry.v1.RegistryService/Delete"
+	RegistryService_Tag_FullMethodName           = "/loom.registry.v1.RegistryService/Tag"
+	RegistryService_Archive_FullMethodName       = "/loom.registry.v1.RegistryService/Archive"
+	RegistryService_Restore_FullMethodName       = "/loom.registry.v1.RegistryService/Restore"
+	RegistryService_History_FullMethodName       = "/loom.registry.v1.RegistryService/History"
+	RegistryService_SetAlias_FullMethodName      = "/loom.registry.v1.RegistryService/SetAlias"
+	RegistryService_Aliases_FullMethodName       = "/loom.registry.v1.RegistryService/Aliases"
+	RegistryService_StoreBatch_FullMethodName    = "/loom.registry.v1.RegistryService/StoreBatch"
+	RegistryService_DeleteBatch_FullMethodName   = "/loom.registry.v1.RegistryService/DeleteBatch"
+	RegistryService_GetMany_FullMethodName       = "/loom.registry.v1.RegistryService/GetMany"
+)
+
+// RegistryServiceClient is the client API for RegistryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RegistryServiceClient interface {
+	Store(ctx context.Context, in *StoreRequest, opts ...grpc.CallOption) (*StoreResponse, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Prompt, error)
+	GetProduction(ctx context.Context, in *GetProductionRequest, opts ...grpc.CallOption) (*Prompt, error)
+	// List streams matching prompts as they are read from the backend.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (RegistryService_ListClient, error)
+	ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (RegistryService_ListVersionsClient, error)
+	Promote(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*PromoteResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Tag(ctx context.Context, in *TagRequest, opts ...grpc.CallOption) (*TagResponse, error)
+	// Archive hides a version until Restore (soft delete).
+	Archive(ctx context.Context, in *ArchiveRequest, opts ...grpc.CallOption) (*ArchiveResponse, error)
+	Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*RestoreResponse, error)
+	// History streams the audit log for an id, oldest first.
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (RegistryService_HistoryClient, error)
+	// SetAlias points a named alias (e.g. "stable") at a version; an empty version removes it.
+	// Get resolves "@alias" versions.
+	SetAlias(ctx context.Context, in *SetAliasRequest, opts ...grpc.CallOption) (*SetAliasResponse, error)
+	Aliases(ctx context.Context, in *AliasesRequest, opts ...grpc.CallOption) (*AliasesResponse, error)
+	// StoreBatch, DeleteBatch, and GetMany read and write many versions in one call
+	// (see registry.Batcher). DeleteBatch fails with NOT_FOUND if a version does not exist.
+	StoreBatch(ctx context.Context, in *StoreBatchRequest, opts ...grpc.CallOption) (*StoreBatchResponse, error)
+	DeleteBatch(ctx context.Context, in *DeleteBatchRequest, opts ...grpc.CallOption) (*DeleteBatchResponse, error)
+	GetMany(ctx context.Context, in *GetManyRequest, opts ...grpc.CallOption) (*GetManyResponse, error)
+}
+
+type registryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRegistryServiceClient(cc grpc.ClientConnInterface) RegistryServiceClient {
+	return &registryServiceClient{cc}
+}
+
+func (c *registryServiceClient) Store(ctx context.Context, in *StoreRequest, opts ...grpc.CallOption) (*StoreResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StoreResponse)
+	err := c.cc.Invoke(ctx, RegistryService_Store_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Prompt, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Prompt)
+	err := c.cc.Invoke(ctx, RegistryService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) GetProduction(ctx context.Context, in *GetProductionRequest, opts ...grpc.CallOption) (*Prompt, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Prompt)
+	err := c.cc.Invoke(ctx, RegistryService_GetProduction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (RegistryService_ListClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RegistryService_ServiceDesc.Streams[0], RegistryService_List_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &registryServiceListClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RegistryService_ListClient interface {
+	Recv() (*Prompt, error)
+	grpc.ClientStream
+}
+
+type registryServiceListClient struct {
+	grpc.ClientStream
+}
+
+func (x *registryServiceListClient) Recv() (*Prompt, error) {
+	m := new(Prompt)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *registryServiceClient) ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (RegistryService_ListVersionsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RegistryService_ServiceDesc.Streams[1], RegistryService_ListVersions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &registryServiceListVersionsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RegistryService_ListVersionsClient interface {
+	Recv() (*VersionInfo, error)
+	grpc.ClientStream
+}
+
+type registryServiceListVersionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *registryServiceListVersionsClient) Recv() (*VersionInfo, error) {
+	m := new(VersionInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *registryServiceClient) Promote(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*PromoteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PromoteResponse)
+	err := c.cc.Invoke(ctx, RegistryService_Promote_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, RegistryService_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) Tag(ctx context.Context, in *TagRequest, opts ...grpc.CallOption) (*TagResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TagResponse)
+	err := c.cc.Invoke(ctx, RegistryService_Tag_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) Archive(ctx context.Context, in *ArchiveRequest, opts ...grpc.CallOption) (*ArchiveResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ArchiveResponse)
+	err := c.cc.Invoke(ctx, RegistryService_Archive_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*RestoreResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreResponse)
+	err := c.cc.Invoke(ctx, RegistryService_Restore_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (RegistryService_HistoryClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RegistryService_ServiceDesc.Streams[2], RegistryService_History_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &registryServiceHistoryClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RegistryService_HistoryClient interface {
+	Recv() (*AuditEntry, error)
+	grpc.ClientStream
+}
+
+type registryServiceHistoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *registryServiceHistoryClient) Recv() (*AuditEntry, error) {
+	m := new(AuditEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *registryServiceClient) SetAlias(ctx context.Context, in *SetAliasRequest, opts ...grpc.CallOption) (*SetAliasResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetAliasResponse)
+	err := c.cc.Invoke(ctx, RegistryService_SetAlias_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) Aliases(ctx context.Context, in *AliasesRequest, opts ...grpc.CallOption) (*AliasesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AliasesResponse)
+	err := c.cc.Invoke(ctx, RegistryService_Aliases_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) StoreBatch(ctx context.Context, in *StoreBatchRequest, opts ...grpc.CallOption) (*StoreBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StoreBatchResponse)
+	err := c.cc.Invoke(ctx, RegistryService_StoreBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) DeleteBatch(ctx context.Context, in *DeleteBatchRequest, opts ...grpc.CallOption) (*DeleteBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteBatchResponse)
+	err := c.cc.Invoke(ctx, RegistryService_DeleteBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *registryServiceClient) GetMany(ctx context.Context, in *GetManyRequest, opts ...grpc.CallOption) (*GetManyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetManyResponse)
+	err := c.cc.Invoke(ctx, RegistryService_GetMany_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RegistryServiceServer is the server API for RegistryService service.
+// All implementations must embed UnimplementedRegistryServiceServer
+// for forward compatibility
+type RegistryServiceServer interface {
+	Store(context.Context, *StoreRequest) (*StoreResponse, error)
+	Get(context.Context, *GetRequest) (*Prompt, error)
+	GetProduction(context.Context, *GetProductionRequest) (*Prompt, error)
+	// List streams matching prompts as they are read from the backend.
+	List(*ListRequest, RegistryService_ListServer) error
+	ListVersions(*ListVersionsRequest, RegistryService_ListVersionsServer) error
+	Promote(context.Context, *PromoteRequest) (*PromoteResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Tag(context.Context, *TagRequest) (*TagResponse, error)
+	// Archive hides a version until Restore (soft delete).
+	Archive(context.Context, *ArchiveRequest) (*ArchiveResponse, error)
+	Restore(context.Context, *RestoreRequest) (*RestoreResponse, error)
+	// History streams the audit log for an id, oldest first.
+	History(*HistoryRequest, RegistryService_HistoryServer) error
+	// SetAlias points a named alias (e.g. "stable") at a version; an empty version removes it.
+	// Get resolves "@alias" versions.
+	SetAlias(context.Context, *SetAliasRequest) (*SetAliasResponse, error)
+	Aliases(context.Context, *AliasesRequest) (*AliasesResponse, error)
+	// StoreBatch, DeleteBatch, and GetMany read and write many versions in one call
+	// (see registry.Batcher). DeleteBatch fails with NOT_FOUND if a version does not exist.
+	StoreBatch(context.Context, *StoreBatchRequest) (*StoreBatchResponse, error)
+	DeleteBatch(context.Context, *DeleteBatchRequest) (*DeleteBatchResponse, error)
+	GetMany(context.Context, *GetManyRequest) (*GetManyResponse, error)
+	mustEmbedUnimplementedRegistryServiceServer()
+}
+
+// UnimplementedRegistryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRegistryServiceServer struct {
+}
+
+func (UnimplementedRegistryServiceServer) Store(context.Context, *StoreRequest) (*StoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Store not implemented")
+}
+func (UnimplementedRegistryServiceServer) Get(context.Context, *GetRequest) (*Prompt, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedRegistryServiceServer) GetProduction(context.Context, *GetProductionRequest) (*Prompt, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProduction not implemented")
+}
+func (UnimplementedRegistryServiceServer) List(*ListRequest, RegistryService_ListServer) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedRegistryServiceServer) ListVersions(*ListVersionsRequest, RegistryService_ListVersionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (UnimplementedRegistryServiceServer) Promote(context.Context, *PromoteRequest) (*PromoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Promote not implemented")
+}
+func (UnimplementedRegistryServiceServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedRegistryServiceServer) Tag(context.Context, *TagRequest) (*TagResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Tag not implemented")
+}
+func (UnimplementedRegistryServiceServer) Archive(context.Context, *ArchiveRequest) (*ArchiveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Archive not implemented")
+}
+func (UnimplementedRegistryServiceServer) Restore(context.Context, *RestoreRequest) (*RestoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restore not implemented")
+}
+func (UnimplementedRegistryServiceServer) History(*HistoryRequest, RegistryService_HistoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedRegistryServiceServer) SetAlias(context.Context, *SetAliasRequest) (*SetAliasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetAlias not implemented")
+}
+func (UnimplementedRegistryServiceServer) Aliases(context.Context, *AliasesRequest) (*AliasesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Aliases not implemented")
+}
+func (UnimplementedRegistryServiceServer) StoreBatch(context.Context, *StoreBatchRequest) (*StoreBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StoreBatch not implemented")
+}
+func (UnimplementedRegistryServiceServer) DeleteBatch(context.Context, *DeleteBatchRequest) (*DeleteBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteBatch not implemented")
+}
+func (UnimplementedRegistryServiceServer) GetMany(context.Context, *GetManyRequest) (*GetManyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMany not implemented")
+}
+func (UnimplementedRegistryServiceServer) mustEmbedUnimplementedRegistryServiceServer() {}
+
+// UnsafeRegistryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RegistryServiceServer will
+// result in compilation errors.
+type UnsafeRegistryServiceServer interface {
+	mustEmbedUnimplementedRegistryServiceServer()
+}
+
+func RegisterRegistryServiceServer(s grpc.ServiceRegistrar, srv RegistryServiceServer) {
+	s.RegisterService(&RegistryService_ServiceDesc, srv)
+}
+
+func _RegistryService_Store_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).Store(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_Store_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).Store(ctx, req.(*StoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_GetProduction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProductionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).GetProduction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_GetProduction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).GetProduction(ctx, req.(*GetProductionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RegistryServiceServer).List(m, &registryServiceListServer{ServerStream: stream})
+}
+
+type RegistryService_ListServer interface {
+	Send(*Prompt) error
+	grpc.ServerStream
+}
+
+type registryServiceListServer struct {
+	grpc.ServerStream
+}
+
+func (x *registryServiceListServer) Send(m *Prompt) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RegistryService_ListVersions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListVersionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RegistryServiceServer).ListVersions(m, &registryServiceListVersionsServer{ServerStream: stream})
+}
+
+type RegistryService_ListVersionsServer interface {
+	Send(*VersionInfo) error
+	grpc.ServerStream
+}
+
+type registryServiceListVersionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *registryServiceListVersionsServer) Send(m *VersionInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RegistryService_Promote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PromoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).Promote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_Promote_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).Promote(ctx, req.(*PromoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_Tag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TagRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).Tag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_Tag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).Tag(ctx, req.(*TagRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_Archive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ArchiveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).Archive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_Archive_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).Archive(ctx, req.(*ArchiveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_Restore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).Restore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_Restore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).Restore(ctx, req.(*RestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_History_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(HistoryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RegistryServiceServer).History(m, &registryServiceHistoryServer{ServerStream: stream})
+}
+
+type RegistryService_HistoryServer interface {
+	Send(*AuditEntry) error
+	grpc.ServerStream
+}
+
+type registryServiceHistoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *registryServiceHistoryServer) Send(m *AuditEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RegistryService_SetAlias_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetAliasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).SetAlias(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_SetAlias_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).SetAlias(ctx, req.(*SetAliasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_Aliases_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AliasesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).Aliases(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_Aliases_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).Aliases(ctx, req.(*AliasesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_StoreBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StoreBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).StoreBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_StoreBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).StoreBatch(ctx, req.(*StoreBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_DeleteBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).DeleteBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_DeleteBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).DeleteBatch(ctx, req.(*DeleteBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RegistryService_GetMany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetManyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RegistryServiceServer).GetMany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RegistryService_GetMany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RegistryServiceServer).GetMany(ctx, req.(*GetManyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RegistryService_ServiceDesc is the grpc.ServiceDesc for RegistryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RegistryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "loom.registry.v1.RegistryService",
+	HandlerType: (*RegistryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Store",
+			Handler:    _RegistryService_Store_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _RegistryService_Get_Handler,
+		},
+		{
+			MethodName: "GetProduction",
+			Handler:    _RegistryService_GetProduction_Handler,
+		},
+		{
+			MethodName: "Promote",
+			Handler:    _RegistryService_Promote_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _RegistryService_Delete_Handler,
+		},
+		{
+			MethodName: "Tag",
+			Handler:    _RegistryService_Tag_Handler,
+		},
+		{
+			MethodName: "Archive",
+			Handler:    _RegistryService_Archive_Handler,
+		},
+		{
+			MethodName: "Restore",
+			Handler:    _RegistryService_Restore_Handler,
+		},
+		{
+			MethodName: "SetAlias",
+			Handler:    _RegistryService_SetAlias_Handler,
+		},
+		{
+			MethodName: "Aliases",
+			Handler:    _RegistryService_Aliases_Handler,
+		},
+		{
+			MethodName: "StoreBatch",
+			Handler:    _RegistryService_StoreBatch_Handler,
+		},
+		{
+			MethodName: "DeleteBatch",
+			Handler:    _RegistryService_DeleteBatch_Handler,
+		},
+		{
+			MethodName: "GetMany",
+			Handler:    _RegistryService_GetMany_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "List",
+			Handler:       _RegistryService_List_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListVersions",
+			Handler:       _RegistryService_ListVersions_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "History",
+			Handler:       _RegistryService_History_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "registry.proto",
+}
@@ -0,0 +1,410 @@
+// Package grpcregistry serves a registry.Registry over gRPC and provides a matching client.
+//
+// Server:
+//
+//	s := grpc.NewServer()
+//	registrypb.RegisterRegistryServiceServer(s, grpcregistry.NewServer(reg))
+//
+// Client:
+//
+//	conn, _ := grpc.NewClient("localhost:9090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	reg := grpcregistry.NewClient(conn) // implements registry.Registry
+package grpcregistry
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/grpcregistry/registrypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements registrypb.RegistryServiceServer on top of a Registry.
+type Server struct {
+	registrypb.UnimplementedRegistryServiceServer
+	reg     registry.Registry
+	apiKeys map[string]registry.Scope
+	limits  map[string]registry.Limits
+	limiter registry.RateLimiter
+}
+
+// NewServer creates a gRPC service backed by reg.
+func NewServer(reg registry.Registry) *Server {
+	return &Server{reg: reg}
+}
+
+// WithAPIKeys requires every call to carry "authorization: Bearer <key>" metadata (see APIKey)
+// for one of keys, and restricts it to that key's scope.
+func (s *Server) WithAPIKeys(keys map[string]registry.Scope) *Server {
+	s.apiKeys = keys
+	return s
+}
+
+// WithLimits sets per-key rate limits and storage quotas, applied to keys from WithAPIKeys.
+// Exceeding either fails the call with RESOURCE_EXHAUSTED.
+func (s *Server) WithLimits(limits map[string]registry.Limits) *Server {
+	s.limits = limits
+	return s
+}
+
+// actorMetadata is the metadata key carrying the caller's audit actor (see registry.WithActor).
+const actorMetadata = "x-loom-actor"
+
+// namespaceMetadata is the metadata key carrying the caller's namespace (see registry.WithNamespace).
+const namespaceMetadata = "x-loom-namespace"
+
+// registryFor returns the registry for the call in its namespace, scoped to its API key when
+// keys are configured, and ctx with the audit actor and namespace set from the call's metadata
+// and key. A key whose scope has a Namespace may only use that namespace.
+func (s *Server) registryFor(ctx context.Context) (context.Context, registry.Registry, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var actor, ns string
+	if v := md.Get(actorMetadata); len(v) > 0 {
+		actor = v[0]
+	}
+	if v := md.Get(namespaceMetadata); len(v) > 0 && v[0] != "" {
+		ns = v[0]
+		if err := registry.ValidateNamespace(ns); err != nil {
+			return ctx, nil, toStatus(err)
+		}
+	}
+	reg := registry.Registry(registry.NewNamespaced(s.reg))
+	if len(s.apiKeys) == 0 {
+		if actor != "" {
+			ctx = registry.WithActor(ctx, actor)
+		}
+		return registry.WithNamespace(ctx, ns), reg, nil
+	}
+	for _, v := range md.Get("authorization") {
+		if key, ok := strings.CutPrefix(v, "Bearer "); ok {
+			key = strings.TrimSpace(key)
+			scope, ok := s.apiKeys[key]
+			if !ok {
+				continue
+			}
+			if scope.Namespace != "" {
+				if ns != "" && ns != scope.Namespace {
+					return ctx, nil, toStatus(registry.ErrForbidden)
+				}
+				ns = scope.Namespace
+			}
+			lim := s.limits[key]
+			if ok, _ := s.limiter.Allow(key, lim.RequestsPerMinute); !ok {
+				return ctx, nil, toStatus(registry.ErrRateLimited)
+			}
+			if lim != (registry.Limits{}) {
+				reg = registry.NewQuota(reg, lim)
+			}
+			ctx = registry.WithActor(ctx, registry.KeyActor(key, actor))
+			ctx = registry.WithPrincipal(ctx, registry.KeyPrincipal(key, scope))
+			return registry.WithNamespace(ctx, ns), registry.NewScoped(reg, scope), nil
+		}
+	}
+	return ctx, nil, status.Error(codes.Unauthenticated, "missing or unknown API key")
+}
+
+// Store implements registrypb.RegistryServiceServer.
+func (s *Server) Store(ctx context.Context, req *registrypb.StoreRequest) (*registrypb.StoreResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetPrompt() == nil || req.GetPrompt().GetId() == "" || req.GetPrompt().GetVersion() == "" {
+		return nil, status.Error(codes.InvalidArgument, "prompt id and version required")
+	}
+	p := fromProto(req.GetPrompt())
+	if req.GetConditional() {
+		err = registry.StoreIfMatch(ctx, reg, p, req.GetRevision())
+	} else {
+		err = reg.Store(ctx, p)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.StoreResponse{Revision: p.Revision}, nil
+}
+
+// Get implements registrypb.RegistryServiceServer.
+func (s *Server) Get(ctx context.Context, req *registrypb.GetRequest) (*registrypb.Prompt, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := reg.Get(ctx, req.GetId(), req.GetVersion())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return promptResponse(p)
+}
+
+// GetProduction implements registrypb.RegistryServiceServer.
+func (s *Server) GetProduction(ctx context.Context, req *registrypb.GetProductionRequest) (*registrypb.Prompt, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := reg.GetProduction(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return promptResponse(p)
+}
+
+// List implements registrypb.RegistryServiceServer, streaming one message per prompt.
+func (s *Server) List(req *registrypb.ListRequest, stream registrypb.RegistryService_ListServer) error {
+	ctx, reg, err := s.registryFor(stream.Context())
+	if err != nil {
+		return err
+	}
+	prompts, err := reg.List(ctx, registry.Filter{
+		IDs:             req.GetIds(),
+		Stage:           registry.Stage(req.GetStage()),
+		Tags:            req.GetTags(),
+		Limit:           int(req.GetLimit()),
+		Offset:          int(req.GetOffset()),
+		IncludeArchived: req.GetIncludeArchived(),
+		Query:           req.GetQuery(),
+		Metadata:        req.GetMetadata(),
+		SortBy:          registry.SortField(req.GetSortBy()),
+		Descending:      req.GetDescending(),
+		Cursor:          req.GetCursor(),
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+	for _, p := range prompts {
+		msg, err := promptResponse(p)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListVersions implements registrypb.RegistryServiceServer.
+func (s *Server) ListVersions(req *registrypb.ListVersionsRequest, stream registrypb.RegistryService_ListVersionsServer) error {
+	ctx, reg, err := s.registryFor(stream.Context())
+	if err != nil {
+		return err
+	}
+	infos, err := reg.ListVersions(ctx, req.GetId())
+	if err != nil {
+		return toStatus(err)
+	}
+	for _, vi := range infos {
+		if err := stream.Send(toVersionInfo(vi)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Promote implements registrypb.RegistryServiceServer.
+func (s *Server) Promote(ctx context.Context, req *registrypb.PromoteRequest) (*registrypb.PromoteResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stage := registry.Stage(req.GetStage())
+	switch stage {
+	case registry.StageDev, registry.StageStaging, registry.StageProduction:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "stage must be dev|staging|production")
+	}
+	if err := reg.Promote(ctx, req.GetId(), req.GetVersion(), stage); err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.PromoteResponse{}, nil
+}
+
+// Delete implements registrypb.RegistryServiceServer.
+func (s *Server) Delete(ctx context.Context, req *registrypb.DeleteRequest) (*registrypb.DeleteResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.Delete(ctx, req.GetId(), req.GetVersion()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.DeleteResponse{}, nil
+}
+
+// Tag implements registrypb.RegistryServiceServer.
+func (s *Server) Tag(ctx context.Context, req *registrypb.TagRequest) (*registrypb.TagResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.Tag(ctx, req.GetId(), req.GetVersion(), req.GetTags()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.TagResponse{}, nil
+}
+
+// Archive implements registrypb.RegistryServiceServer.
+func (s *Server) Archive(ctx context.Context, req *registrypb.ArchiveRequest) (*registrypb.ArchiveResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.Archive(ctx, req.GetId(), req.GetVersion()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.ArchiveResponse{}, nil
+}
+
+// Restore implements registrypb.RegistryServiceServer.
+func (s *Server) Restore(ctx context.Context, req *registrypb.RestoreRequest) (*registrypb.RestoreResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := reg.Restore(ctx, req.GetId(), req.GetVersion()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.RestoreResponse{}, nil
+}
+
+// History implements registrypb.RegistryServiceServer, streaming the audit log for an id.
+func (s *Server) History(req *registrypb.HistoryRequest, stream registrypb.RegistryService_HistoryServer) error {
+	ctx, reg, err := s.registryFor(stream.Context())
+	if err != nil {
+		return err
+	}
+	entries, err := registry.History(ctx, reg, req.GetId())
+	if err != nil {
+		return toStatus(err)
+	}
+	for _, e := range entries {
+		if err := stream.Send(toAuditEntry(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAlias implements registrypb.RegistryServiceServer.
+func (s *Server) SetAlias(ctx context.Context, req *registrypb.SetAliasRequest) (*registrypb.SetAliasResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := registry.SetAlias(ctx, reg, req.GetId(), req.GetAlias(), req.GetVersion()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.SetAliasResponse{}, nil
+}
+
+// Aliases implements registrypb.RegistryServiceServer.
+func (s *Server) Aliases(ctx context.Context, req *registrypb.AliasesRequest) (*registrypb.AliasesResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := registry.Aliases(ctx, reg, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.AliasesResponse{Aliases: aliases}, nil
+}
+
+// StoreBatch implements registrypb.RegistryServiceServer.
+func (s *Server) StoreBatch(ctx context.Context, req *registrypb.StoreBatchRequest) (*registrypb.StoreBatchResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prompts := make([]*core.Prompt, len(req.GetPrompts()))
+	for i, msg := range req.GetPrompts() {
+		prompts[i] = fromProto(msg)
+	}
+	if err := registry.StoreBatch(ctx, reg, prompts); err != nil {
+		return nil, toStatus(err)
+	}
+	revisions := make([]int64, len(prompts))
+	for i, p := range prompts {
+		revisions[i] = p.Revision
+	}
+	return &registrypb.StoreBatchResponse{Revisions: revisions}, nil
+}
+
+// DeleteBatch implements registrypb.RegistryServiceServer.
+func (s *Server) DeleteBatch(ctx context.Context, req *registrypb.DeleteBatchRequest) (*registrypb.DeleteBatchResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := registry.DeleteBatch(ctx, reg, fromVersionRefs(req.GetRefs())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &registrypb.DeleteBatchResponse{}, nil
+}
+
+// GetMany implements registrypb.RegistryServiceServer.
+func (s *Server) GetMany(ctx context.Context, req *registrypb.GetManyRequest) (*registrypb.GetManyResponse, error) {
+	ctx, reg, err := s.registryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := registry.GetMany(ctx, reg, fromVersionRefs(req.GetRefs()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &registrypb.GetManyResponse{Results: make([]*registrypb.GetManyResult, len(prompts))}
+	for i, p := range prompts {
+		resp.Results[i] = &registrypb.GetManyResult{}
+		if p != nil {
+			if resp.Results[i].Prompt, err = promptResponse(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return resp, nil
+}
+
+// Register registers a Server for reg on the given gRPC server.
+func Register(s *grpc.Server, reg registry.Registry) {
+	registrypb.RegisterRegistryServiceServer(s, NewServer(reg))
+}
+
+func promptResponse(p *core.Prompt) (*registrypb.Prompt, error) {
+	msg, err := toProto(p)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return msg, nil
+}
+
+// toStatus maps registry errors to gRPC status codes.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, core.ErrPromptNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, core.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, registry.ErrInvalidAlias), errors.Is(err, registry.ErrInvalidBatch), errors.Is(err, registry.ErrInvalidNamespace),
+		errors.Is(err, registry.ErrInvalidSort), errors.Is(err, registry.ErrInvalidCursor):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, registry.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, registry.ErrPromotionDenied):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, registry.ErrRateLimited), errors.Is(err, registry.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
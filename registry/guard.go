@@ -0,0 +1,306 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrPromotionDenied is returned by GuardedRegistry.Promote when a promotion breaks a PromotionRule.
+// The message names the version, the target stage, and every rule it failed.
+var ErrPromotionDenied = errors.New("registry: promotion denied")
+
+// Promotion is a requested Promote, as seen by PromotionRules.
+type Promotion struct {
+	ID      string
+	Version string
+	// From is the version's current stage (StageDev if it has none); To is the requested stage.
+	From, To Stage
+	// Tags are the version's current tags.
+	Tags []string
+	// Environment is set for environment production pointers (see PromoteFor); To is then
+	// StageProduction.
+	Environment string
+	// Reached lists the stages the version was promoted to before, per the audit log (nil if the
+	// registry keeps none). RequireStage lets a version return to a stage it already reached,
+	// e.g. when rolling back to an earlier production version.
+	Reached []Stage
+}
+
+// PromotionRule decides whether a promotion is allowed. CheckPromotion returns nil to allow it, or
+// an error saying what is missing ("must be tagged approved").
+type PromotionRule interface {
+	CheckPromotion(ctx context.Context, p Promotion) error
+}
+
+// PromotionRuleFunc adapts a function to PromotionRule.
+type PromotionRuleFunc func(ctx context.Context, p Promotion) error
+
+// CheckPromotion implements PromotionRule.
+func (f PromotionRuleFunc) CheckPromotion(ctx context.Context, p Promotion) error {
+	return f(ctx, p)
+}
+
+// RequireStage requires versions promoted to stage to be in one of from first, e.g.
+// RequireStage(StageProduction, StageStaging) so nothing goes straight from dev to production.
+// Versions that were in stage before (Promotion.Reached) may return to it. Stage StageAny applies
+// the rule to every promotion.
+func RequireStage(stage Stage, from ...Stage) PromotionRule {
+	return PromotionRuleFunc(func(_ context.Context, p Promotion) error {
+		if !appliesTo(stage, p) || hasStage(from, p.From) || hasStage(p.Reached, p.To) {
+			return nil
+		}
+		names := make([]string, len(from))
+		for i, st := range from {
+			names[i] = string(st)
+		}
+		return fmt.Errorf("must be in %s first (it is in %s)", strings.Join(names, " or "), p.From)
+	})
+}
+
+// RequireTag requires versions promoted to stage to carry every one of tags (see Registry.Tag),
+// e.g. RequireTag(StageProduction, "approved").
+func RequireTag(stage Stage, tags ...string) PromotionRule {
+	return PromotionRuleFunc(func(_ context.Context, p Promotion) error {
+		if !appliesTo(stage, p) {
+			return nil
+		}
+		var missing []string
+		for _, tag := range tags {
+			if !hasTag(p.Tags, tag) {
+				missing = append(missing, tag)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("must be tagged %s", strings.Join(missing, ", "))
+		}
+		return nil
+	})
+}
+
+// RequireEval requires versions promoted to stage to have a passing eval report, as reported by
+// passed (e.g. a lookup of evaluator.Report results your CI stored for id@version). An error from
+// passed denies the promotion.
+func RequireEval(stage Stage, passed func(ctx context.Context, id, version string) (bool, error)) PromotionRule {
+	return PromotionRuleFunc(func(ctx context.Context, p Promotion) error {
+		if !appliesTo(stage, p) {
+			return nil
+		}
+		ok, err := passed(ctx, p.ID, p.Version)
+		if err != nil {
+			return fmt.Errorf("checking eval report: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("needs a passing eval report")
+		}
+		return nil
+	})
+}
+
+// appliesTo reports whether a rule for stage covers p.
+func appliesTo(stage Stage, p Promotion) bool {
+	return stage == StageAny || stage == p.To
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// GuardedRegistry enforces PromotionRules on Promote and on environment production pointers (see
+// PromoteFor) on top of another Registry. Other operations, including storing over a version that
+// is already promoted, pass through unchanged.
+type GuardedRegistry struct {
+	inner Registry
+	rules []PromotionRule
+}
+
+// NewGuarded returns inner with rules checked before each Promote.
+func NewGuarded(inner Registry, rules ...PromotionRule) *GuardedRegistry {
+	return &GuardedRegistry{inner: inner, rules: rules}
+}
+
+// Promote implements Registry. It returns ErrPromotionDenied, listing every rule the promotion
+// breaks, without promoting; versions that do not exist are reported as not found.
+func (g *GuardedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	if err := g.check(ctx, Promotion{ID: id, Version: version, To: stage}); err != nil {
+		return err
+	}
+	return g.inner.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does), checking the rules for a
+// promotion of version to production first.
+func (g *GuardedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	if err := g.check(ctx, Promotion{ID: id, Version: version, To: StageProduction}); err != nil {
+		return err
+	}
+	return RollbackProduction(ctx, g.inner, id, from, version)
+}
+
+// check fills in p's current stage, tags, and reached stages and checks it against the rules.
+func (g *GuardedRegistry) check(ctx context.Context, p Promotion) error {
+	id, version := p.ID, p.Version
+	infos, err := g.inner.ListVersions(ctx, id)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, info := range infos {
+		if info.Version == version {
+			found = true
+			p.From, p.Tags = info.Stage, info.Tags
+		}
+	}
+	if !found {
+		return core.ErrPromptNotFound
+	}
+	if p.From == "" {
+		p.From = StageDev
+	}
+	if _, ok := g.inner.(Auditor); ok {
+		entries, err := History(ctx, g.inner, id)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Version == version && e.Action == AuditPromote && !hasStage(p.Reached, e.Stage) {
+				p.Reached = append(p.Reached, e.Stage)
+			}
+		}
+	}
+	var denied []string
+	for _, rule := range g.rules {
+		if err := rule.CheckPromotion(ctx, p); err != nil {
+			denied = append(denied, err.Error())
+		}
+	}
+	if len(denied) > 0 {
+		to := string(p.To)
+		if p.Environment != "" {
+			to += " in " + p.Environment
+		}
+		return fmt.Errorf("%w: %s@%s to %s: %s", ErrPromotionDenied, id, version, to, strings.Join(denied, "; "))
+	}
+	return nil
+}
+
+// Store implements Registry.
+func (g *GuardedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	return g.inner.Store(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does).
+func (g *GuardedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	return StoreIfMatch(ctx, g.inner, prompt, revision)
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time).
+func (g *GuardedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	return StoreBatch(ctx, g.inner, prompts)
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time).
+func (g *GuardedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	return DeleteBatch(ctx, g.inner, refs)
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time).
+func (g *GuardedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	return GetMany(ctx, g.inner, refs)
+}
+
+// Get implements Registry.
+func (g *GuardedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	return g.inner.Get(ctx, id, version)
+}
+
+// GetProduction implements Registry.
+func (g *GuardedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	return g.inner.GetProduction(ctx, id)
+}
+
+// List implements Registry.
+func (g *GuardedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	return g.inner.List(ctx, filter)
+}
+
+// ListVersions implements Registry.
+func (g *GuardedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	return g.inner.ListVersions(ctx, id)
+}
+
+// Delete implements Registry.
+func (g *GuardedRegistry) Delete(ctx context.Context, id, version string) error {
+	return g.inner.Delete(ctx, id, version)
+}
+
+// Tag implements Registry.
+func (g *GuardedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	return g.inner.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry.
+func (g *GuardedRegistry) Archive(ctx context.Context, id, version string) error {
+	return g.inner.Archive(ctx, id, version)
+}
+
+// Restore implements Registry.
+func (g *GuardedRegistry) Restore(ctx context.Context, id, version string) error {
+	return g.inner.Restore(ctx, id, version)
+}
+
+// SetAlias implements Aliaser (if inner does). Pointing an environment's production pointer at a
+// version (see PromoteFor) is checked like a promotion to StageProduction.
+func (g *GuardedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if env, ok := ParseEnvironmentAlias(alias); ok && version != "" {
+		if err := g.check(ctx, Promotion{ID: id, Version: version, To: StageProduction, Environment: env}); err != nil {
+			return err
+		}
+	}
+	return SetAlias(ctx, g.inner, id, alias, version)
+}
+
+// Aliases implements Aliaser (if inner does).
+func (g *GuardedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return Aliases(ctx, g.inner, id)
+}
+
+// History implements Auditor (if inner does).
+func (g *GuardedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	return History(ctx, g.inner, id)
+}
+
+// Usage implements UsageReporter (if inner does).
+func (g *GuardedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, g.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does).
+func (g *GuardedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, g.inner)
+}
+
+// WatchChanges implements ChangeWatcher (if inner does).
+func (g *GuardedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, g.inner, fn)
+}
+
+// Ensure GuardedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*GuardedRegistry)(nil)
+	_ ConditionalStorer    = (*GuardedRegistry)(nil)
+	_ Auditor              = (*GuardedRegistry)(nil)
+	_ UsageReporter        = (*GuardedRegistry)(nil)
+	_ ChangeWatcher        = (*GuardedRegistry)(nil)
+	_ Aliaser              = (*GuardedRegistry)(nil)
+	_ Batcher              = (*GuardedRegistry)(nil)
+	_ ProductionRollbacker = (*GuardedRegistry)(nil)
+)
@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardedRegistry(t *testing.T) {
+	ctx := context.Background()
+	evals := map[string]bool{"1.1.0": true}
+	g := NewGuarded(NewMemoryRegistry(),
+		RequireStage(StageProduction, StageStaging),
+		RequireTag(StageProduction, "approved"),
+		RequireEval(StageProduction, func(_ context.Context, id, version string) (bool, error) { return evals[version], nil }),
+	)
+	for _, v := range []string{"1.0.0", "1.1.0"} {
+		require.NoError(t, g.Store(ctx, &core.Prompt{ID: "p", Version: v, Template: v}))
+	}
+
+	err := g.Promote(ctx, "p", "1.0.0", StageProduction)
+	require.ErrorIs(t, err, ErrPromotionDenied)
+	assert.Equal(t, "registry: promotion denied: p@1.0.0 to production: must be in staging first (it is in dev); "+
+		"must be tagged approved; needs a passing eval report", err.Error())
+	assert.ErrorIs(t, g.Promote(ctx, "p", "9.9.9", StageProduction), core.ErrPromptNotFound)
+	require.NoError(t, g.Promote(ctx, "p", "1.0.0", StageStaging), "rules for production do not apply")
+
+	require.NoError(t, g.Promote(ctx, "p", "1.1.0", StageStaging))
+	require.NoError(t, g.Tag(ctx, "p", "1.1.0", []string{"approved", "reviewed"}))
+	require.NoError(t, g.Promote(ctx, "p", "1.1.0", StageProduction))
+	p, err := g.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", p.Version)
+
+	// A version that reached production before may go back without passing through staging again.
+	require.NoError(t, g.Promote(ctx, "p", "1.1.0", StageDev))
+	assert.NoError(t, g.Promote(ctx, "p", "1.1.0", StageProduction))
+
+	// Environment production pointers are checked like promotions to production.
+	err = PromoteFor(ctx, g, "p", "1.0.0", "eu-prod")
+	require.ErrorIs(t, err, ErrPromotionDenied)
+	assert.Contains(t, err.Error(), "p@1.0.0 to production in eu-prod: must be tagged approved")
+	require.NoError(t, PromoteFor(ctx, g, "p", "1.1.0", "eu-prod"))
+	assert.NoError(t, PromoteFor(ctx, g, "p", "", "eu-prod"), "removing a pointer is not checked")
+
+	everyStage := NewGuarded(NewMemoryRegistry(), RequireTag(StageAny, "ok"))
+	require.NoError(t, everyStage.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "t"}))
+	assert.ErrorIs(t, everyStage.Promote(ctx, "p", "1.0.0", StageStaging), ErrPromotionDenied)
+}
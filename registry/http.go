@@ -0,0 +1,421 @@
+// Package registry HTTP client for a registry served by registry/httpserver.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ActorHeader carries the caller's actor (see WithActor) to registry servers.
+const ActorHeader = "X-Loom-Actor"
+
+// NamespaceHeader carries the caller's namespace (see WithNamespace) to registry servers.
+const NamespaceHeader = "X-Loom-Namespace"
+
+// NextCursorHeader is set by registry servers on a List response that is not the last page, to the
+// Cursor for the next one (see NextCursor).
+const NextCursorHeader = "X-Loom-Next-Cursor"
+
+// HTTPClient implements Registry against the REST API exposed by registry/httpserver.
+// The actor set on a request's context with WithActor is sent in ActorHeader, and the namespace
+// set with WithNamespace in NamespaceHeader.
+type HTTPClient struct {
+	baseURL string
+	client  *http.Client
+	apiKey  string
+}
+
+// NewHTTPClient creates a registry client for the server at baseURL (e.g. "http://localhost:8090").
+// If httpClient is nil, http.DefaultClient is used.
+func NewHTTPClient(baseURL string, httpClient *http.Client) *HTTPClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &HTTPClient{baseURL: strings.TrimSuffix(baseURL, "/"), client: httpClient}
+}
+
+// WithAPIKey sends key as a bearer token on every request (see httpserver.Server.APIKeys).
+func (c *HTTPClient) WithAPIKey(key string) *HTTPClient {
+	c.apiKey = key
+	return c
+}
+
+func (c *HTTPClient) promptPath(id string, rest ...string) string {
+	p := "/prompts/" + url.PathEscape(id)
+	for _, r := range rest {
+		p += "/" + url.PathEscape(r)
+	}
+	return p
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response into out (if non-nil).
+func (c *HTTPClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.doHeader(ctx, method, path, nil, body, out)
+}
+
+// doHeader is do with extra request headers.
+func (c *HTTPClient) doHeader(ctx context.Context, method, path string, header http.Header, body, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return fmt.Errorf("http registry encode: %w", err)
+		}
+		rd = &buf
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, rd)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if actor := ActorFromContext(ctx); actor != "" {
+		req.Header.Set(ActorHeader, actor)
+	}
+	if ns := NamespaceFromContext(ctx); ns != "" {
+		req.Header.Set(NamespaceHeader, ns)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http registry request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return core.ErrPromptNotFound
+	}
+	switch resp.StatusCode {
+	case http.StatusForbidden:
+		bs, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w%s", ErrForbidden, strings.TrimPrefix(strings.TrimSpace(string(bs)), ErrForbidden.Error()))
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusInsufficientStorage:
+		bs, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, strings.TrimSpace(strings.TrimPrefix(string(bs), ErrQuotaExceeded.Error()+":")))
+	case http.StatusUnprocessableEntity:
+		bs, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w%s", ErrPromotionDenied, strings.TrimPrefix(strings.TrimSpace(string(bs)), ErrPromotionDenied.Error()))
+	case http.StatusBadRequest:
+		bs, _ := io.ReadAll(resp.Body)
+		for _, sentinel := range []error{ErrInvalidAlias, ErrInvalidBatch, ErrInvalidNamespace, ErrInvalidSort, ErrInvalidCursor} {
+			if msg, ok := strings.CutPrefix(strings.TrimSpace(string(bs)), sentinel.Error()); ok {
+				return fmt.Errorf("%w%s", sentinel, msg)
+			}
+		}
+		return fmt.Errorf("http registry error %d: %s", resp.StatusCode, strings.TrimSpace(string(bs)))
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict {
+		return core.ErrConflict
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bs, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("http registry error %d: %s", resp.StatusCode, strings.TrimSpace(string(bs)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("http registry decode: %w", err)
+	}
+	return nil
+}
+
+// Store implements Registry.
+func (c *HTTPClient) Store(ctx context.Context, prompt *core.Prompt) error {
+	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
+		return fmt.Errorf("http registry: prompt id and version required")
+	}
+	var out struct{ Revision int64 }
+	if err := c.do(ctx, http.MethodPost, "/prompts", prompt, &out); err != nil {
+		return err
+	}
+	prompt.Revision = out.Revision
+	return nil
+}
+
+// StoreIfMatch implements ConditionalStorer by sending If-Match (or If-None-Match: * for revision 0).
+func (c *HTTPClient) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
+		return fmt.Errorf("http registry: prompt id and version required")
+	}
+	header := http.Header{}
+	if revision == 0 {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", strconv.Quote(strconv.FormatInt(revision, 10)))
+	}
+	var out struct{ Revision int64 }
+	if err := c.doHeader(ctx, http.MethodPost, "/prompts", header, prompt, &out); err != nil {
+		return err
+	}
+	prompt.Revision = out.Revision
+	return nil
+}
+
+// Get implements Registry.
+func (c *HTTPClient) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	var p core.Prompt
+	if err := c.do(ctx, http.MethodGet, c.promptPath(id, version), nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetProduction implements Registry.
+func (c *HTTPClient) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	var p core.Prompt
+	if err := c.do(ctx, http.MethodGet, c.promptPath(id, "production"), nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// List implements Registry.
+func (c *HTTPClient) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	if filter.Namespace != "" {
+		ctx = WithNamespace(ctx, filter.Namespace)
+	}
+	q := url.Values{}
+	for _, id := range filter.IDs {
+		q.Add("id", id)
+	}
+	if filter.Stage != "" {
+		q.Set("stage", string(filter.Stage))
+	}
+	for _, t := range filter.Tags {
+		q.Add("tag", t)
+	}
+	if filter.Limit > 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		q.Set("offset", strconv.Itoa(filter.Offset))
+	}
+	if filter.IncludeArchived {
+		q.Set("archived", "true")
+	}
+	if filter.Query != "" {
+		q.Set("q", filter.Query)
+	}
+	for k, v := range filter.Metadata {
+		q.Add("meta", k+":"+v)
+	}
+	if filter.SortBy != "" {
+		q.Set("sort", string(filter.SortBy))
+	}
+	if filter.Descending {
+		q.Set("desc", "true")
+	}
+	if filter.Cursor != "" {
+		q.Set("cursor", filter.Cursor)
+	}
+	path := "/prompts"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var out []*core.Prompt
+	if err := c.do(ctx, http.MethodGet, path, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListVersions implements Registry.
+func (c *HTTPClient) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	var out []VersionInfo
+	if err := c.do(ctx, http.MethodGet, c.promptPath(id, "versions"), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Promote implements Registry.
+func (c *HTTPClient) Promote(ctx context.Context, id, version string, stage Stage) error {
+	body := struct {
+		Stage Stage `json:"stage"`
+	}{Stage: stage}
+	return c.do(ctx, http.MethodPost, c.promptPath(id, version, "promote"), body, nil)
+}
+
+// RollbackProduction implements ProductionRollbacker; the server swaps production atomically and
+// answers 409 (core.ErrConflict) when from is no longer production.
+func (c *HTTPClient) RollbackProduction(ctx context.Context, id, from, version string) error {
+	body := struct {
+		From string `json:"from"`
+	}{From: from}
+	return c.do(ctx, http.MethodPost, c.promptPath(id, version, "rollback"), body, nil)
+}
+
+// Delete implements Registry.
+func (c *HTTPClient) Delete(ctx context.Context, id, version string) error {
+	return c.do(ctx, http.MethodDelete, c.promptPath(id, version), nil, nil)
+}
+
+// Tag implements Registry.
+func (c *HTTPClient) Tag(ctx context.Context, id, version string, tags []string) error {
+	body := struct {
+		Tags []string `json:"tags"`
+	}{Tags: tags}
+	return c.do(ctx, http.MethodPut, c.promptPath(id, version, "tags"), body, nil)
+}
+
+// Archive implements Registry.
+func (c *HTTPClient) Archive(ctx context.Context, id, version string) error {
+	return c.do(ctx, http.MethodPost, c.promptPath(id, version, "archive"), nil, nil)
+}
+
+// Restore implements Registry.
+func (c *HTTPClient) Restore(ctx context.Context, id, version string) error {
+	return c.do(ctx, http.MethodPost, c.promptPath(id, version, "restore"), nil, nil)
+}
+
+// SetAlias implements Aliaser.
+func (c *HTTPClient) SetAlias(ctx context.Context, id, alias, version string) error {
+	body := struct {
+		Alias   string `json:"alias"`
+		Version string `json:"version"`
+	}{Alias: alias, Version: version}
+	return c.do(ctx, http.MethodPut, c.promptPath(id, "aliases"), body, nil)
+}
+
+// Aliases implements Aliaser.
+func (c *HTTPClient) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	var out map[string]string
+	if err := c.do(ctx, http.MethodGet, c.promptPath(id, "aliases"), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StoreBatch implements Batcher with one request.
+func (c *HTTPClient) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	var out struct {
+		Revisions []int64 `json:"revisions"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/batch/store", prompts, &out); err != nil {
+		return err
+	}
+	if len(out.Revisions) == len(prompts) {
+		for i, p := range prompts {
+			p.Revision = out.Revisions[i]
+		}
+	}
+	return nil
+}
+
+// DeleteBatch implements Batcher with one request.
+func (c *HTTPClient) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, "/batch/delete", refs, nil)
+}
+
+// GetMany implements Batcher with one request.
+func (c *HTTPClient) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	if len(refs) == 0 {
+		return []*core.Prompt{}, nil
+	}
+	var out []*core.Prompt
+	if err := c.do(ctx, http.MethodPost, "/batch/get", refs, &out); err != nil {
+		return nil, err
+	}
+	if len(out) != len(refs) {
+		return nil, fmt.Errorf("http registry: got %d prompts for %d refs", len(out), len(refs))
+	}
+	return out, nil
+}
+
+// History implements Auditor.
+func (c *HTTPClient) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	var out []AuditEntry
+	if err := c.do(ctx, http.MethodGet, c.promptPath(id, "history"), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Usage implements UsageReporter (the server's registry must track usage).
+func (c *HTTPClient) Usage(ctx context.Context, id string) (UsageStats, error) {
+	var out UsageStats
+	err := c.do(ctx, http.MethodGet, c.promptPath(id, "usage"), nil, &out)
+	return out, err
+}
+
+// ListUsage implements UsageReporter.
+func (c *HTTPClient) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	var out []UsageStats
+	if err := c.do(ctx, http.MethodGet, "/usage", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Render asks the server to render a prompt version (or "production") with input, validating it against
+// the prompt's variables server-side. The returned Rendered carries input as given, and the token
+// counts of the server's engine.
+func (c *HTTPClient) Render(ctx context.Context, id, version string, input core.Input) (*core.Rendered, error) {
+	body := struct {
+		Input core.Input `json:"input"`
+	}{Input: input}
+	var out struct {
+		System   string `json:"system"`
+		User     string `json:"user"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+		Tools []struct {
+			Name        string                 `json:"name"`
+			Description string                 `json:"description"`
+			Parameters  map[string]interface{} `json:"parameters"`
+		} `json:"tools"`
+		Tokens struct {
+			System   int `json:"system"`
+			User     int `json:"user"`
+			Examples int `json:"examples"`
+			Total    int `json:"total"`
+		} `json:"tokens"`
+	}
+	if err := c.do(ctx, http.MethodPost, c.promptPath(id, version, "render"), body, &out); err != nil {
+		return nil, err
+	}
+	rendered := &core.Rendered{System: out.System, User: out.User, Input: input, Tokens: core.TokenCounts(out.Tokens)}
+	for _, m := range out.Messages {
+		rendered.Messages = append(rendered.Messages, core.Message{Role: m.Role, Content: m.Content})
+	}
+	for _, t := range out.Tools {
+		rendered.Tools = append(rendered.Tools, core.Tool{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+	}
+	return rendered, nil
+}
+
+// Ensure HTTPClient implements Registry at compile time.
+var (
+	_ Registry             = (*HTTPClient)(nil)
+	_ ConditionalStorer    = (*HTTPClient)(nil)
+	_ Auditor              = (*HTTPClient)(nil)
+	_ UsageReporter        = (*HTTPClient)(nil)
+	_ Aliaser              = (*HTTPClient)(nil)
+	_ Batcher              = (*HTTPClient)(nil)
+	_ ProductionRollbacker = (*HTTPClient)(nil)
+)
@@ -0,0 +1,222 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/provider"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/template"
+	"golang.org/x/net/websocket"
+)
+
+// executeRequest is the JSON body for POST /prompts/{id}/execute, and the first message a client
+// sends on GET /prompts/{id}/execute/ws.
+type executeRequest struct {
+	Input core.Input `json:"input"`
+	// Version defaults to the production version; it may be "@alias".
+	Version     string  `json:"version,omitempty"`
+	Model       string  `json:"model,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+	MaxTokens   int     `json:"max_tokens,omitempty"`
+}
+
+// executeUsage reports token counts in execute responses.
+type executeUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// executeEvent is one server-sent event or WebSocket message of a streamed execution: "chunk"
+// events carry the next part of the content, the final "done" event the whole content and usage,
+// and an "error" event ends a stream that failed. Unstreamed executions respond with the done event.
+// Offline marks the done event of an offline Executor, whose content is the rendered prompt.
+type executeEvent struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id,omitempty"`
+	Version string        `json:"version,omitempty"`
+	Content string        `json:"content,omitempty"`
+	Model   string        `json:"model,omitempty"`
+	Usage   *executeUsage `json:"usage,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Offline bool          `json:"offline,omitempty"`
+}
+
+// handleExecute renders and executes a prompt with the server's Executor, streaming the response
+// as server-sent events when the query has stream=true.
+func (s *Server) handleExecute(w http.ResponseWriter, r *http.Request) {
+	if s.Executor == nil {
+		http.Error(w, "execution not configured on this server", http.StatusNotImplemented)
+		return
+	}
+	stream := false
+	if v := r.URL.Query().Get("stream"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid stream", http.StatusBadRequest)
+			return
+		}
+		stream = b
+	}
+	var req executeRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	ereq, err := s.executeRequest(r.Context(), r, req)
+	if err != nil {
+		writeExecuteError(w, err)
+		return
+	}
+	if !stream {
+		res, err := s.Executor.Execute(r.Context(), ereq)
+		if err != nil {
+			writeExecuteError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, executeEvent{Type: "done", ID: ereq.Prompt.ID, Version: ereq.Prompt.Version,
+			Content: res.Content, Model: res.Model, Usage: toExecuteUsage(&res.Usage), Offline: res.Offline})
+		return
+	}
+	ch, _, err := s.Executor.Stream(r.Context(), ereq)
+	if err != nil {
+		writeExecuteError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	forwardStream(r.Context(), ereq, s.Executor.Offline, ch, func(e executeEvent) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+}
+
+// handleExecuteWS is the WebSocket variant of handleExecute: the client sends one executeRequest
+// message and receives the stream's events as JSON messages, after which the server closes the
+// connection. Any origin is accepted; use APIKeys to restrict access.
+func (s *Server) handleExecuteWS(w http.ResponseWriter, r *http.Request) {
+	if s.Executor == nil {
+		http.Error(w, "execution not configured on this server", http.StatusNotImplemented)
+		return
+	}
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		ctx := r.Context()
+		send := func(e executeEvent) error { return websocket.JSON.Send(ws, e) }
+		var req executeRequest
+		if err := websocket.JSON.Receive(ws, &req); err != nil {
+			_ = send(executeEvent{Type: "error", Error: "invalid JSON: " + err.Error()})
+			return
+		}
+		ereq, err := s.executeRequest(ctx, r, req)
+		if err != nil {
+			_ = send(executeEvent{Type: "error", Error: err.Error()})
+			return
+		}
+		ch, _, err := s.Executor.Stream(ctx, ereq)
+		if err != nil {
+			_ = send(executeEvent{Type: "error", Error: err.Error()})
+			return
+		}
+		forwardStream(ctx, ereq, s.Executor.Offline, ch, send)
+	}}.ServeHTTP(w, r)
+}
+
+// executeRequest loads the prompt named by the request path and req, ready to execute.
+func (s *Server) executeRequest(ctx context.Context, r *http.Request, req executeRequest) (executor.ExecuteRequest, error) {
+	var p *core.Prompt
+	var err error
+	if req.Version == "" || req.Version == string(registry.StageProduction) {
+		p, err = s.registryFor(r).GetProduction(ctx, r.PathValue("id"))
+	} else {
+		p, err = s.registryFor(r).Get(ctx, r.PathValue("id"), req.Version)
+	}
+	if err != nil {
+		return executor.ExecuteRequest{}, err
+	}
+	p.SetRenderer(s.renderer())
+	return executor.ExecuteRequest{Prompt: p, Input: req.Input, Model: req.Model, Temperature: req.Temperature, MaxTokens: req.MaxTokens}, nil
+}
+
+// forwardStream sends a chunk event per stream chunk, then a done event with the whole content,
+// or an error event if the stream fails. It stops early if send fails (the client went away).
+func forwardStream(ctx context.Context, req executor.ExecuteRequest, offline bool, ch <-chan provider.StreamChunk, send func(executeEvent) error) {
+	var content strings.Builder
+	var usage *provider.TokenUsage
+	done := func() {
+		_ = send(executeEvent{Type: "done", ID: req.Prompt.ID, Version: req.Prompt.Version, Content: content.String(),
+			Model: req.Model, Usage: toExecuteUsage(usage), Offline: offline})
+	}
+	for {
+		var chunk provider.StreamChunk
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case chunk, ok = <-ch:
+		}
+		if !ok {
+			done()
+			return
+		}
+		if chunk.Err != nil {
+			_ = send(executeEvent{Type: "error", Error: chunk.Err.Error()})
+			return
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if chunk.Content != "" {
+			content.WriteString(chunk.Content)
+			if err := send(executeEvent{Type: "chunk", Content: chunk.Content}); err != nil {
+				return
+			}
+		}
+		if chunk.Done {
+			done()
+			return
+		}
+	}
+}
+
+func toExecuteUsage(u *provider.TokenUsage) *executeUsage {
+	if u == nil || *u == (provider.TokenUsage{}) {
+		return nil
+	}
+	return &executeUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+// writeExecuteError reports invalid input, templates, and over-budget prompts as 400 and other errors like writeError.
+func writeExecuteError(w http.ResponseWriter, err error) {
+	if errors.Is(err, core.ErrValidationFailed) || errors.Is(err, core.ErrRenderFailed) || errors.Is(err, core.ErrTokenBudgetExceeded) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeError(w, err)
+}
+
+// renderer returns the server's Renderer, or a default template engine.
+func (s *Server) renderer() core.Renderer {
+	if s.Renderer != nil {
+		return s.Renderer
+	}
+	return template.NewEngine()
+}
@@ -0,0 +1,709 @@
+// Package httpserver exposes a registry.Registry over a REST API.
+//
+// Routes:
+//
+//	GET    /prompts                               List (query: id, stage, tag, q, meta=key:value, sort, desc=true, cursor, limit, offset, archived=true;
+//	                                              X-Loom-Next-Cursor response header: cursor for the next page, absent on the last)
+//	POST   /prompts                               Store (body: core.Prompt JSON; If-Match: "<revision>" or If-None-Match: * for conditional store)
+//	GET    /prompts/{id}/production               GetProduction (query env=eu-prod: registry.GetProductionFor that environment)
+//	GET    /prompts/{id}/versions                 ListVersions
+//	GET    /prompts/{id}/history                  Audit log (registry.Auditor), oldest first
+//	GET    /prompts/{id}/aliases                  Aliases (registry.Aliaser): {"stable": "1.2.0", ...}
+//	PUT    /prompts/{id}/aliases                  SetAlias (body: {"alias": "stable", "version": "1.2.0"}; empty version removes)
+//	GET    /prompts/{id}/usage                    Read counts (registry.UsageReporter, e.g. registry.NewTracked)
+//	POST   /prompts/{id}/execute                  Render and run with the server's Executor (body: {"input": {...}, "version": "1.2.0",
+//	                                              "model": "...", "temperature": 0.2, "max_tokens": 256}; version defaults to production;
+//	                                              response: {"type": "done", "content": "...", "usage": {...}, ...};
+//	                                              query stream=true: server-sent events "chunk" ({"content": "..."}) then "done" or "error")
+//	GET    /prompts/{id}/execute/ws               WebSocket variant of execute: send the body as the first message, receive the events as JSON
+//	GET    /usage                                 Read counts for every id, least recently read first
+//	POST   /batch/store                           StoreBatch (body: [core.Prompt, ...]; response: {"revisions": [...]})
+//	POST   /batch/delete                          DeleteBatch (body: [{"id": "a", "version": "1.0.0"}, ...])
+//	POST   /batch/get                             GetMany (body as /batch/delete; response: prompts in order, null if missing)
+//	GET    /prompts/{id}/{version}                Get (ETag is the revision; version may be "@alias")
+//	DELETE /prompts/{id}/{version}                Delete
+//	POST   /prompts/{id}/{version}/archive        Archive (soft delete)
+//	POST   /prompts/{id}/{version}/restore        Restore an archived version
+//	POST   /prompts/{id}/{version}/promote        Promote (body: {"stage": "production"})
+//	POST   /prompts/{id}/{version}/rollback       RollbackProduction (body: {"from": "2.0.0"}; 409 if production is no longer from)
+//	PUT    /prompts/{id}/{version}/tags           Tag (body: {"tags": ["a", "b"]})
+//	POST   /prompts/{id}/{version}/render         Render preview (body: {"input": {...}}; version may be "production")
+//	GET    /health                                Liveness
+//	GET    /ready                                 Readiness (registry and provider health; 503 if any fail)
+//
+// When APIKeys is set, every /prompts and /batch route requires "Authorization: Bearer <key>" (or X-API-Key)
+// and is restricted to the key's registry.Scope: unknown keys get 401, out-of-scope operations 403.
+// Limits adds per-key request rates (429 with Retry-After) and storage quotas (507).
+// Promotions refused by a registry.GuardedRegistry get 422.
+//
+// Changes are attributed in the audit log to the X-Loom-Actor request header and, when APIKeys is
+// set, to a fingerprint of the key (see registry.KeyActor).
+//
+// Every route works in the namespace named by the X-Loom-Namespace header (see
+// registry.NamespacedRegistry); without it, in the default namespace. A key whose Scope has a
+// Namespace is confined to it: the header may be omitted, and naming another namespace gets 403.
+//
+// Use registry.NewHTTPClient to talk to a server from Go.
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/provider"
+	"github.com/klejdi94/loom/registry"
+)
+
+// Server serves a Registry over HTTP.
+type Server struct {
+	Registry registry.Registry
+	Addr     string
+	// Providers are health-checked by GET /ready (see provider.HealthChecker), keyed by name.
+	Providers map[string]provider.Provider
+	// Renderer renders prompts for the render preview and execute routes; template.NewEngine() if nil.
+	Renderer core.Renderer
+	// Executor runs prompts for the execute routes, through its provider (and middleware chain);
+	// they answer 501 Not Implemented if it is nil.
+	Executor *executor.Executor
+	// APIKeys maps API keys to the stages they may read and write. If empty, the API is open.
+	APIKeys map[string]registry.Scope
+	// Limits holds optional rate limits and storage quotas per API key (see registry.Limits).
+	Limits map[string]registry.Limits
+	// Metrics, if set, is served at GET /metrics without an API key, e.g. promhttp.Handler() for
+	// a registry.NewInstrumented registry reporting to registry/prommetrics.
+	Metrics http.Handler
+
+	limiter registry.RateLimiter
+}
+
+// readyTimeout bounds each readiness check.
+const readyTimeout = 5 * time.Second
+
+// New creates a server for the given registry. addr defaults to ":8090".
+func New(reg registry.Registry, addr string) *Server {
+	if addr == "" {
+		addr = ":8090"
+	}
+	return &Server{Registry: reg, Addr: addr}
+}
+
+// promoteRequest is the JSON body for POST /prompts/{id}/{version}/promote.
+type promoteRequest struct {
+	Stage registry.Stage `json:"stage"`
+}
+
+// rollbackRequest is the JSON body for POST /prompts/{id}/{version}/rollback.
+type rollbackRequest struct {
+	From string `json:"from"`
+}
+
+// tagRequest is the JSON body for PUT /prompts/{id}/{version}/tags.
+type tagRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// aliasRequest is the JSON body for PUT /prompts/{id}/aliases.
+type aliasRequest struct {
+	Alias   string `json:"alias"`
+	Version string `json:"version"`
+}
+
+// batchStoreResponse is the JSON response of POST /batch/store.
+type batchStoreResponse struct {
+	Revisions []int64 `json:"revisions"`
+}
+
+// renderRequest is the JSON body for POST /prompts/{id}/{version}/render.
+type renderRequest struct {
+	Input core.Input `json:"input"`
+}
+
+// renderResponse is the JSON response for POST /prompts/{id}/{version}/render.
+type renderResponse struct {
+	ID       string          `json:"id"`
+	Version  string          `json:"version"`
+	System   string          `json:"system"`
+	User     string          `json:"user"`
+	Messages []renderMessage `json:"messages"`
+	Tools    []renderTool    `json:"tools,omitempty"`
+	Tokens   renderTokens    `json:"tokens"`
+}
+
+// renderTokens is renderResponse.Tokens (see core.TokenCounts).
+type renderTokens struct {
+	System   int `json:"system"`
+	User     int `json:"user"`
+	Examples int `json:"examples"`
+	Total    int `json:"total"`
+}
+
+// renderMessage is a turn of renderResponse.Messages.
+type renderMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// renderTool is a tool definition of renderResponse.Tools.
+type renderTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Handler returns the HTTP handler with all routes registered.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /prompts", s.authorize(s.handleList))
+	mux.HandleFunc("POST /prompts", s.authorize(s.handleStore))
+	mux.HandleFunc("GET /prompts/{id}/production", s.authorize(s.handleGetProduction))
+	mux.HandleFunc("GET /prompts/{id}/versions", s.authorize(s.handleListVersions))
+	mux.HandleFunc("GET /prompts/{id}/history", s.authorize(s.handleHistory))
+	mux.HandleFunc("GET /prompts/{id}/aliases", s.authorize(s.handleAliases))
+	mux.HandleFunc("PUT /prompts/{id}/aliases", s.authorize(s.handleSetAlias))
+	mux.HandleFunc("GET /prompts/{id}/usage", s.authorize(s.handleUsage))
+	mux.HandleFunc("POST /prompts/{id}/execute", s.authorize(s.handleExecute))
+	mux.HandleFunc("GET /prompts/{id}/execute/ws", s.authorize(s.handleExecuteWS))
+	mux.HandleFunc("GET /usage", s.authorize(s.handleListUsage))
+	mux.HandleFunc("POST /batch/store", s.authorize(s.handleStoreBatch))
+	mux.HandleFunc("POST /batch/delete", s.authorize(s.handleDeleteBatch))
+	mux.HandleFunc("POST /batch/get", s.authorize(s.handleGetMany))
+	mux.HandleFunc("GET /prompts/{id}/{version}", s.authorize(s.handleGet))
+	mux.HandleFunc("DELETE /prompts/{id}/{version}", s.authorize(s.handleDelete))
+	mux.HandleFunc("POST /prompts/{id}/{version}/archive", s.authorize(s.handleArchive))
+	mux.HandleFunc("POST /prompts/{id}/{version}/restore", s.authorize(s.handleRestore))
+	mux.HandleFunc("POST /prompts/{id}/{version}/promote", s.authorize(s.handlePromote))
+	mux.HandleFunc("POST /prompts/{id}/{version}/rollback", s.authorize(s.handleRollback))
+	mux.HandleFunc("PUT /prompts/{id}/{version}/tags", s.authorize(s.handleTag))
+	mux.HandleFunc("POST /prompts/{id}/{version}/render", s.authorize(s.handleRender))
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /ready", s.handleReady)
+	if s.Metrics != nil {
+		mux.Handle("GET /metrics", s.Metrics)
+	}
+	return mux
+}
+
+// scopedRegistryKey is the request context key for the caller's scoped registry.
+type scopedRegistryKey struct{}
+
+// authorize checks the request's API key when APIKeys is set, scopes the registry used by h,
+// and sets the audit actor and namespace.
+func (s *Server) authorize(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(registry.ActorHeader)
+		ns := r.Header.Get(registry.NamespaceHeader)
+		if ns != "" {
+			if err := registry.ValidateNamespace(ns); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		if len(s.APIKeys) == 0 {
+			ctx := registry.WithNamespace(r.Context(), ns)
+			if actor != "" {
+				ctx = registry.WithActor(ctx, actor)
+			}
+			h(w, r.WithContext(ctx))
+			return
+		}
+		key := apiKey(r)
+		scope, ok := s.APIKeys[key]
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing or unknown API key", http.StatusUnauthorized)
+			return
+		}
+		if scope.Namespace != "" {
+			if ns != "" && ns != scope.Namespace {
+				writeError(w, registry.ErrForbidden)
+				return
+			}
+			ns = scope.Namespace
+		}
+		lim := s.Limits[key]
+		if ok, retry := s.limiter.Allow(key, lim.RequestsPerMinute); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			http.Error(w, registry.ErrRateLimited.Error(), http.StatusTooManyRequests)
+			return
+		}
+		var reg registry.Registry = registry.NewNamespaced(s.Registry)
+		if lim != (registry.Limits{}) {
+			reg = registry.NewQuota(reg, lim)
+		}
+		ctx := context.WithValue(r.Context(), scopedRegistryKey{}, registry.NewScoped(reg, scope))
+		ctx = registry.WithActor(ctx, registry.KeyActor(key, actor))
+		ctx = registry.WithPrincipal(ctx, registry.KeyPrincipal(key, scope))
+		ctx = registry.WithNamespace(ctx, ns)
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// apiKey returns the key from "Authorization: Bearer <key>" or X-API-Key.
+func apiKey(r *http.Request) string {
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// registryFor returns the registry for the request in its namespace, scoped to its API key if any.
+func (s *Server) registryFor(r *http.Request) registry.Registry {
+	if reg, ok := r.Context().Value(scopedRegistryKey{}).(registry.Registry); ok {
+		return reg
+	}
+	return registry.NewNamespaced(s.Registry)
+}
+
+// ListenAndServe starts the HTTP server. Use go s.ListenAndServe() to run in background.
+func (s *Server) ListenAndServe() error {
+	return http.ListenAndServe(s.Addr, s.Handler())
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := registry.Filter{
+		IDs:   q["id"],
+		Stage: registry.Stage(q.Get("stage")),
+		Tags:  q["tag"],
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		filter.Offset = n
+	}
+	if v := q.Get("archived"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid archived", http.StatusBadRequest)
+			return
+		}
+		filter.IncludeArchived = b
+	}
+	filter.Query = q.Get("q")
+	for _, v := range q["meta"] {
+		k, val, ok := strings.Cut(v, ":")
+		if !ok {
+			http.Error(w, "invalid meta: want key:value", http.StatusBadRequest)
+			return
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[k] = val
+	}
+	filter.SortBy = registry.SortField(q.Get("sort"))
+	if v := q.Get("desc"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid desc", http.StatusBadRequest)
+			return
+		}
+		filter.Descending = b
+	}
+	filter.Cursor = q.Get("cursor")
+	prompts, next, err := registry.ListPage(r.Context(), s.registryFor(r), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if prompts == nil {
+		prompts = []*core.Prompt{}
+	}
+	if next != "" {
+		w.Header().Set(registry.NextCursorHeader, next)
+	}
+	writeJSON(w, http.StatusOK, prompts)
+}
+
+func (s *Server) handleStore(w http.ResponseWriter, r *http.Request) {
+	var p core.Prompt
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if p.ID == "" || p.Version == "" {
+		http.Error(w, "id and version required", http.StatusBadRequest)
+		return
+	}
+	revision, conditional, err := storePrecondition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if conditional {
+		if _, ok := s.Registry.(registry.ConditionalStorer); !ok {
+			http.Error(w, "conditional store not supported by this backend", http.StatusNotImplemented)
+			return
+		}
+		err = registry.StoreIfMatch(r.Context(), s.registryFor(r), &p, revision)
+		if errors.Is(err, core.ErrConflict) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+	} else {
+		err = s.registryFor(r).Store(r.Context(), &p)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setETag(w, &p)
+	writeJSON(w, http.StatusCreated, &p)
+}
+
+// storePrecondition parses If-Match ("<revision>") or If-None-Match (*) on a store request.
+func storePrecondition(r *http.Request) (revision int64, conditional bool, err error) {
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		if v != "*" {
+			return 0, false, errors.New("If-None-Match must be *")
+		}
+		return 0, true, nil
+	}
+	v := r.Header.Get("If-Match")
+	if v == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.ParseInt(strings.Trim(v, `"`), 10, 64)
+	if err != nil {
+		return 0, false, errors.New("If-Match must be a quoted revision number")
+	}
+	return n, true, nil
+}
+
+// setETag exposes the prompt revision as a strong ETag.
+func setETag(w http.ResponseWriter, p *core.Prompt) {
+	if p.Revision > 0 {
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(p.Revision, 10)))
+	}
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	p, err := s.registryFor(r).Get(r.Context(), r.PathValue("id"), r.PathValue("version"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setETag(w, p)
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) handleGetProduction(w http.ResponseWriter, r *http.Request) {
+	p, err := registry.GetProductionFor(r.Context(), s.registryFor(r), r.PathValue("id"), r.URL.Query().Get("env"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setETag(w, p)
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) handleListVersions(w http.ResponseWriter, r *http.Request) {
+	infos, err := s.registryFor(r).ListVersions(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if infos == nil {
+		infos = []registry.VersionInfo{}
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	entries, err := registry.History(r.Context(), s.registryFor(r), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if entries == nil {
+		entries = []registry.AuditEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) handleAliases(w http.ResponseWriter, r *http.Request) {
+	aliases, err := registry.Aliases(r.Context(), s.registryFor(r), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if aliases == nil {
+		aliases = map[string]string{}
+	}
+	writeJSON(w, http.StatusOK, aliases)
+}
+
+func (s *Server) handleSetAlias(w http.ResponseWriter, r *http.Request) {
+	var req aliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := registry.SetAlias(r.Context(), s.registryFor(r), r.PathValue("id"), req.Alias, req.Version); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	u, err := registry.Usage(r.Context(), s.registryFor(r), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) handleListUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := registry.ListUsage(r.Context(), s.registryFor(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if usage == nil {
+		usage = []registry.UsageStats{}
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+func (s *Server) handleStoreBatch(w http.ResponseWriter, r *http.Request) {
+	var prompts []*core.Prompt
+	if err := json.NewDecoder(r.Body).Decode(&prompts); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := registry.StoreBatch(r.Context(), s.registryFor(r), prompts); err != nil {
+		writeError(w, err)
+		return
+	}
+	revisions := make([]int64, len(prompts))
+	for i, p := range prompts {
+		revisions[i] = p.Revision
+	}
+	writeJSON(w, http.StatusOK, batchStoreResponse{Revisions: revisions})
+}
+
+func (s *Server) handleDeleteBatch(w http.ResponseWriter, r *http.Request) {
+	var refs []registry.VersionRef
+	if err := json.NewDecoder(r.Body).Decode(&refs); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := registry.DeleteBatch(r.Context(), s.registryFor(r), refs); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetMany(w http.ResponseWriter, r *http.Request) {
+	var refs []registry.VersionRef
+	if err := json.NewDecoder(r.Body).Decode(&refs); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	prompts, err := registry.GetMany(r.Context(), s.registryFor(r), refs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, prompts)
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if err := s.registryFor(r).Delete(r.Context(), r.PathValue("id"), r.PathValue("version")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	if err := s.registryFor(r).Archive(r.Context(), r.PathValue("id"), r.PathValue("version")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if err := s.registryFor(r).Restore(r.Context(), r.PathValue("id"), r.PathValue("version")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePromote(w http.ResponseWriter, r *http.Request) {
+	var req promoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch req.Stage {
+	case registry.StageDev, registry.StageStaging, registry.StageProduction:
+	default:
+		http.Error(w, "stage must be dev|staging|production", http.StatusBadRequest)
+		return
+	}
+	if err := s.registryFor(r).Promote(r.Context(), r.PathValue("id"), r.PathValue("version"), req.Stage); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
+	var req rollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := registry.RollbackProduction(r.Context(), s.registryFor(r), r.PathValue("id"), req.From, r.PathValue("version")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleTag(w http.ResponseWriter, r *http.Request) {
+	var req tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.registryFor(r).Tag(r.Context(), r.PathValue("id"), r.PathValue("version"), req.Tags); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRender validates the input against the prompt's variables and returns the rendered text.
+// Validation and template errors are reported as 400 so callers can show them in a playground.
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
+	var req renderRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	id, version := r.PathValue("id"), r.PathValue("version")
+	var p *core.Prompt
+	var err error
+	if version == string(registry.StageProduction) {
+		p, err = s.registryFor(r).GetProduction(r.Context(), id)
+	} else {
+		p, err = s.registryFor(r).Get(r.Context(), id, version)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rendered, err := s.renderer().Render(r.Context(), p, req.Input)
+	if err != nil {
+		if errors.Is(err, core.ErrValidationFailed) || errors.Is(err, core.ErrRenderFailed) || errors.Is(err, core.ErrTokenBudgetExceeded) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeError(w, err)
+		return
+	}
+	resp := renderResponse{ID: p.ID, Version: p.Version, System: rendered.System, User: rendered.User, Messages: []renderMessage{},
+		Tokens: renderTokens(rendered.Tokens)}
+	for _, m := range rendered.Messages {
+		resp.Messages = append(resp.Messages, renderMessage{Role: m.Role, Content: m.Content})
+	}
+	for _, t := range rendered.Tools {
+		resp.Tools = append(resp.Tools, renderTool{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyResponse is the JSON body for GET /ready; checks maps each component to "ok" or its error.
+type readyResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	resp := readyResponse{Status: "ok", Checks: make(map[string]string)}
+	check := func(name string, fn func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+		if err := fn(ctx); err != nil {
+			resp.Status = "unavailable"
+			resp.Checks[name] = err.Error()
+			return
+		}
+		resp.Checks[name] = "ok"
+	}
+	check("registry", func(ctx context.Context) error {
+		_, err := s.Registry.List(ctx, registry.Filter{Limit: 1})
+		return err
+	})
+	for name, p := range s.Providers {
+		p := p
+		check("provider:"+name, func(ctx context.Context) error { return provider.CheckHealth(ctx, p) })
+	}
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError maps registry errors to HTTP status codes.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, core.ErrPromptNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, core.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, registry.ErrInvalidAlias), errors.Is(err, registry.ErrInvalidBatch), errors.Is(err, registry.ErrInvalidNamespace),
+		errors.Is(err, registry.ErrInvalidSort), errors.Is(err, registry.ErrInvalidCursor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, registry.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, registry.ErrRateLimited):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, registry.ErrQuotaExceeded):
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	case errors.Is(err, registry.ErrPromotionDenied):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
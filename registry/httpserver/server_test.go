@@ -0,0 +1,621 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/executor"
+	"github.com/klejdi94/loom/loomtest"
+	"github.com/klejdi94/loom/provider"
+	"github.com/klejdi94/loom/registry"
+	"github.com/klejdi94/loom/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func newTestClient(t *testing.T) *registry.HTTPClient {
+	t.Helper()
+	srv := httptest.NewServer(New(registry.NewMemoryRegistry(), "").Handler())
+	t.Cleanup(srv.Close)
+	return registry.NewHTTPClient(srv.URL, srv.Client())
+}
+
+func TestHTTPClient_Conformance(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) registry.Registry { return newTestClient(t) })
+}
+
+func TestHTTPClient_StoreGet(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	p := &core.Prompt{
+		ID: "p1", Version: "1.0.0", Template: "Hi {{.name}}",
+		Variables: []core.Variable{{Name: "name", Type: core.VariableTypeString, Required: true}},
+	}
+	require.NoError(t, c.Store(ctx, p))
+	got, err := c.Get(ctx, "p1", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "Hi {{.name}}", got.Template)
+	require.Len(t, got.Variables, 1)
+	assert.Equal(t, "name", got.Variables[0].Name)
+}
+
+func TestHTTPClient_NotFound(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	_, err := c.Get(ctx, "missing", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	_, err = c.GetProduction(ctx, "missing")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	assert.ErrorIs(t, c.Delete(ctx, "missing", "1.0.0"), core.ErrPromptNotFound)
+}
+
+func TestHTTPClient_PromoteTagList(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p1", Version: "1.0.0"}))
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p1", Version: "2.0.0"}))
+	require.NoError(t, c.Promote(ctx, "p1", "2.0.0", registry.StageProduction))
+	require.NoError(t, c.Tag(ctx, "p1", "1.0.0", []string{"legacy"}))
+
+	prod, err := c.GetProduction(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", prod.Version)
+
+	tagged, err := c.List(ctx, registry.Filter{Tags: []string{"legacy"}})
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+	assert.Equal(t, "1.0.0", tagged[0].Version)
+
+	vers, err := c.ListVersions(ctx, "p1")
+	require.NoError(t, err)
+	assert.Len(t, vers, 2)
+
+	require.NoError(t, c.Delete(ctx, "p1", "1.0.0"))
+	vers, err = c.ListVersions(ctx, "p1")
+	require.NoError(t, err)
+	assert.Len(t, vers, 1)
+}
+
+func TestHTTPClient_Rollback(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	for _, v := range []string{"1.0.0", "2.0.0", "3.0.0"} {
+		require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p1", Version: v}))
+		require.NoError(t, c.Promote(ctx, "p1", v, registry.StageProduction))
+	}
+	assert.ErrorIs(t, c.RollbackProduction(ctx, "p1", "2.0.0", "1.0.0"), core.ErrConflict, "production is 3.0.0")
+
+	for _, want := range []string{"2.0.0", "1.0.0"} {
+		v, err := registry.Rollback(ctx, c, "p1")
+		require.NoError(t, err)
+		assert.Equal(t, want, v)
+	}
+	prod, err := c.GetProduction(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", prod.Version)
+}
+
+type healthProvider struct {
+	provider.Provider
+	err error
+}
+
+func (h healthProvider) HealthCheck(ctx context.Context) error { return h.err }
+
+func TestServer_Ready(t *testing.T) {
+	s := New(registry.NewMemoryRegistry(), "")
+	s.Providers = map[string]provider.Provider{"ok": healthProvider{}}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	s.Providers["down"] = healthProvider{err: errors.New("unreachable")}
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unreachable")
+}
+
+func TestServer_Render(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	p := &core.Prompt{
+		ID: "greet", Version: "1.0.0", System: "You are {{.role}}.", Template: "Hi {{.name}}",
+		Messages: []core.Message{{Role: core.RoleAssistant, Content: "Welcome!"}},
+		Variables: []core.Variable{
+			{Name: "name", Type: core.VariableTypeString, Required: true},
+			{Name: "role", Type: core.VariableTypeString, Default: "helpful"},
+		},
+		Tools: []core.Tool{{Name: "lookup", Description: "Finds a user", Parameters: map[string]interface{}{"type": "object"}}},
+	}
+	require.NoError(t, c.Store(ctx, p))
+	require.NoError(t, c.Promote(ctx, "greet", "1.0.0", registry.StageProduction))
+
+	r, err := c.Render(ctx, "greet", "1.0.0", core.Input{"name": "Ada"})
+	require.NoError(t, err)
+	assert.Equal(t, "You are helpful.", r.System)
+	assert.Equal(t, "Hi Ada", r.User)
+	assert.Equal(t, []core.Message{
+		{Role: core.RoleSystem, Content: "You are helpful."},
+		{Role: core.RoleAssistant, Content: "Welcome!"},
+		{Role: core.RoleUser, Content: "Hi Ada"},
+	}, r.Messages)
+	assert.Equal(t, p.Tools, r.Tools)
+	assert.Equal(t, core.TokenCounts{System: 4, User: 2, Total: 8}, r.Tokens)
+
+	r, err = c.Render(ctx, "greet", "production", core.Input{"name": "Bob", "role": "terse"})
+	require.NoError(t, err)
+	assert.Equal(t, "You are terse.", r.System)
+
+	_, err = c.Render(ctx, "greet", "1.0.0", core.Input{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "400")
+
+	_, err = c.Render(ctx, "greet", "9.9.9", core.Input{"name": "Ada"})
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+}
+
+func TestServer_StoreIfMatch(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	p := &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}
+	require.NoError(t, c.StoreIfMatch(ctx, p, 0))
+	assert.Equal(t, int64(1), p.Revision)
+	assert.ErrorIs(t, c.StoreIfMatch(ctx, p.Copy(), 0), core.ErrConflict)
+
+	got, err := c.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	stale := got.Copy()
+	got.Template = "v2"
+	require.NoError(t, c.StoreIfMatch(ctx, got, got.Revision))
+	assert.Equal(t, int64(2), got.Revision)
+	assert.ErrorIs(t, c.StoreIfMatch(ctx, stale, stale.Revision), core.ErrConflict)
+
+	rec := httptest.NewRecorder()
+	s := New(registry.NewMemoryRegistry(), "")
+	require.NoError(t, s.Registry.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "x"}))
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/prompts/p/1.0.0", nil))
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+}
+
+func TestServer_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	s := New(registry.NewMemoryRegistry(), "")
+	s.APIKeys = map[string]registry.Scope{
+		"admin": {Read: []registry.Stage{registry.StageAny}, Write: []registry.Stage{registry.StageAny}},
+		"prod":  {Read: []registry.Stage{registry.StageProduction}},
+	}
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	admin := registry.NewHTTPClient(srv.URL, srv.Client()).WithAPIKey("admin")
+	require.NoError(t, admin.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, admin.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v2"}))
+	require.NoError(t, admin.Promote(ctx, "p", "1.0.0", registry.StageProduction))
+
+	prod := registry.NewHTTPClient(srv.URL, srv.Client()).WithAPIKey("prod")
+	p, err := prod.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", p.Version)
+	_, err = prod.Get(ctx, "p", "2.0.0")
+	assert.ErrorIs(t, err, registry.ErrForbidden)
+	assert.ErrorIs(t, prod.Store(ctx, &core.Prompt{ID: "p", Version: "3.0.0", Template: "x"}), registry.ErrForbidden)
+
+	anon := registry.NewHTTPClient(srv.URL, srv.Client())
+	_, err = anon.GetProduction(ctx, "p")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "no /metrics route without Metrics")
+	s.Metrics = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "loom_up 1\n") })
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "loom_up 1\n", rec.Body.String())
+}
+
+func TestServer_Limits(t *testing.T) {
+	ctx := context.Background()
+	s := New(registry.NewMemoryRegistry(), "")
+	all := registry.Scope{Read: []registry.Stage{registry.StageAny}, Write: []registry.Stage{registry.StageAny}}
+	s.APIKeys = map[string]registry.Scope{"slow": all, "small": all}
+	s.Limits = map[string]registry.Limits{
+		"slow":  {RequestsPerMinute: 1},
+		"small": {Tenant: "small", MaxVersions: 1},
+	}
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	slow := registry.NewHTTPClient(srv.URL, srv.Client()).WithAPIKey("slow")
+	require.NoError(t, slow.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	_, err := slow.Get(ctx, "p", "1.0.0")
+	assert.ErrorIs(t, err, registry.ErrRateLimited)
+
+	req := httptest.NewRequest(http.MethodGet, "/prompts/p/1.0.0", nil)
+	req.Header.Set("Authorization", "Bearer slow")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	small := registry.NewHTTPClient(srv.URL, srv.Client()).WithAPIKey("small")
+	require.NoError(t, small.Store(ctx, &core.Prompt{ID: "q", Version: "1.0.0", Template: "v1"}))
+	err = small.Store(ctx, &core.Prompt{ID: "q", Version: "2.0.0", Template: "v2"})
+	assert.ErrorIs(t, err, registry.ErrQuotaExceeded)
+}
+
+func TestHTTPClient_ArchiveRestore(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(New(registry.NewMemoryRegistry(), "").Handler())
+	t.Cleanup(srv.Close)
+	client := registry.NewHTTPClient(srv.URL, srv.Client())
+
+	require.NoError(t, client.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, client.Archive(ctx, "p", "1.0.0"))
+	_, err := client.Get(ctx, "p", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	list, err := client.List(ctx, registry.Filter{})
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	list, err = client.List(ctx, registry.Filter{IncludeArchived: true})
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+	infos, err := client.ListVersions(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.True(t, infos[0].Archived)
+
+	require.NoError(t, client.Restore(ctx, "p", "1.0.0"))
+	_, err = client.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.ErrorIs(t, client.Archive(ctx, "missing", "1.0.0"), core.ErrPromptNotFound)
+}
+
+func TestServer_History(t *testing.T) {
+	ctx := registry.WithActor(context.Background(), "alice")
+	s := New(registry.NewMemoryRegistry(), "")
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	client := registry.NewHTTPClient(srv.URL, srv.Client())
+
+	require.NoError(t, client.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, client.Promote(ctx, "p", "1.0.0", registry.StageProduction))
+	entries, err := client.History(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, registry.AuditStore, entries[0].Action)
+	assert.Equal(t, registry.AuditPromote, entries[1].Action)
+	assert.Equal(t, "alice", entries[1].Actor)
+	empty, err := client.History(ctx, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	s.APIKeys = map[string]registry.Scope{
+		"ci": {Read: []registry.Stage{registry.StageDev}, Write: []registry.Stage{registry.StageDev}},
+	}
+	ci := registry.NewHTTPClient(srv.URL, srv.Client()).WithAPIKey("ci")
+	require.NoError(t, ci.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v2"}))
+	entries, err = ci.History(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "production entries are hidden from a dev-only key")
+	assert.Equal(t, "2.0.0", entries[0].Version)
+	assert.Equal(t, registry.KeyActor("ci", "alice"), entries[0].Actor)
+}
+
+func TestServer_Usage(t *testing.T) {
+	ctx := context.Background()
+	s := New(registry.NewMemoryRegistry(), "")
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	client := registry.NewHTTPClient(srv.URL, srv.Client())
+	require.NoError(t, client.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	_, err := client.Usage(ctx, "p")
+	assert.Error(t, err, "untracked registry")
+
+	s = New(registry.NewTracked(registry.NewMemoryRegistry(), registry.NewMemoryUsageStore()), "")
+	srv2 := httptest.NewServer(s.Handler())
+	t.Cleanup(srv2.Close)
+	client = registry.NewHTTPClient(srv2.URL, srv2.Client())
+	require.NoError(t, client.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, client.Store(ctx, &core.Prompt{ID: "q", Version: "1.0.0", Template: "v1"}))
+	_, err = client.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	u, err := client.Usage(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "p", u.ID)
+	assert.Equal(t, int64(1), u.Gets)
+	all, err := client.ListUsage(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "q", all[0].ID)
+	assert.Zero(t, all[0].Reads())
+}
+
+func TestServer_Aliases(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.1.0", Template: "v2"}))
+	require.NoError(t, c.SetAlias(ctx, "p", "stable", "1.0.0"))
+	got, err := c.Get(ctx, "p", "@stable")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", got.Template)
+	got, err = c.Get(ctx, "p", "@latest")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", got.Version)
+	aliases, err := c.Aliases(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"stable": "1.0.0"}, aliases)
+	assert.ErrorIs(t, c.SetAlias(ctx, "p", "no spaces", "1.0.0"), registry.ErrInvalidAlias)
+	assert.ErrorIs(t, c.SetAlias(ctx, "p", "canary", "9.9.9"), core.ErrPromptNotFound)
+	require.NoError(t, c.SetAlias(ctx, "p", "stable", ""))
+	_, err = c.Get(ctx, "p", "@stable")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+}
+
+func TestServer_Authz(t *testing.T) {
+	ctx := context.Background()
+	s := New(registry.WithAuthz(registry.NewMemoryRegistry(), registry.RolePolicy{
+		Roles: map[string]registry.Role{"release-bot": registry.RoleReleaser, "ci": registry.RoleEditor},
+	}), "")
+	all := registry.Scope{Read: []registry.Stage{registry.StageAny}, Write: []registry.Stage{registry.StageAny}}
+	release, ci := all, all
+	release.Principal, ci.Principal = "release-bot", "ci"
+	s.APIKeys = map[string]registry.Scope{"k1": release, "k2": ci, "k3": all}
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	client := func(key string) *registry.HTTPClient {
+		return registry.NewHTTPClient(srv.URL, srv.Client()).WithAPIKey(key)
+	}
+
+	require.NoError(t, client("k2").Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	err := client("k2").Promote(ctx, "p", "1.0.0", registry.StageProduction)
+	require.ErrorIs(t, err, registry.ErrForbidden)
+	assert.Contains(t, err.Error(), "ci may not promote p@1.0.0 to production", "the server's reason reaches the client")
+	require.NoError(t, client("k1").Promote(ctx, "p", "1.0.0", registry.StageProduction))
+	_, err = client("k3").GetProduction(ctx, "p")
+	require.ErrorIs(t, err, registry.ErrForbidden)
+	assert.Contains(t, err.Error(), registry.KeyActor("k3", "")+" may not read p", "keys without a principal are named by KeyActor")
+}
+
+func TestServer_ProductionFor(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(New(registry.NewMemoryRegistry(), "").Handler())
+	t.Cleanup(srv.Close)
+	c := registry.NewHTTPClient(srv.URL, srv.Client())
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.1.0", Template: "v2"}))
+	require.NoError(t, c.Promote(ctx, "p", "1.0.0", registry.StageProduction))
+	require.NoError(t, registry.PromoteFor(ctx, c, "p", "1.1.0", "eu-prod"))
+
+	got, err := registry.GetProductionFor(ctx, c, "p", "eu-prod")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", got.Version)
+	for env, want := range map[string]string{"eu-prod": "1.1.0", "us-prod": "1.0.0", "": "1.0.0"} {
+		resp, err := srv.Client().Get(srv.URL + "/prompts/p/production?env=" + env)
+		require.NoError(t, err)
+		var p core.Prompt
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+		resp.Body.Close()
+		assert.Equal(t, want, p.Version, env)
+	}
+}
+
+func TestServer_Batch(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	prompts := []*core.Prompt{
+		{ID: "p", Version: "1.0.0", Template: "v1"},
+		{ID: "q", Version: "1.0.0", Template: "q1"},
+	}
+	require.NoError(t, c.StoreBatch(ctx, prompts))
+	assert.Equal(t, int64(1), prompts[0].Revision)
+	got, err := c.GetMany(ctx, []registry.VersionRef{{ID: "q", Version: "1.0.0"}, {ID: "p", Version: "9.9.9"}, {ID: "p", Version: "1.0.0"}})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, "q1", got[0].Template)
+	assert.Nil(t, got[1])
+	assert.Equal(t, "v1", got[2].Template)
+
+	assert.ErrorIs(t, c.DeleteBatch(ctx, []registry.VersionRef{{ID: "p", Version: "1.0.0"}, {ID: "p", Version: "9.9.9"}}), core.ErrPromptNotFound)
+	require.NoError(t, c.DeleteBatch(ctx, []registry.VersionRef{{ID: "p", Version: "1.0.0"}, {ID: "q", Version: "1.0.0"}}))
+	_, err = c.Get(ctx, "q", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+	rec := httptest.NewRecorder()
+	body := `[{"id": "p", "version": "1.0.0"}, {"id": "p", "version": "1.0.0"}]`
+	New(registry.NewMemoryRegistry(), "").Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/batch/store", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), registry.ErrInvalidBatch.Error())
+}
+
+func TestServer_Namespaces(t *testing.T) {
+	ctx := context.Background()
+	s := New(registry.NewMemoryRegistry(), "")
+	s.APIKeys = map[string]registry.Scope{
+		"admin":  {Read: []registry.Stage{registry.StageAny}, Write: []registry.Stage{registry.StageAny}},
+		"search": {Read: []registry.Stage{registry.StageAny}, Write: []registry.Stage{registry.StageAny}, Namespace: "search"},
+	}
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	admin := registry.NewHTTPClient(srv.URL, srv.Client()).WithAPIKey("admin")
+	require.NoError(t, admin.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "default"}))
+	require.NoError(t, admin.Store(registry.WithNamespace(ctx, "support"), &core.Prompt{ID: "p", Version: "1.0.0", Template: "support"}))
+
+	search := registry.NewHTTPClient(srv.URL, srv.Client()).WithAPIKey("search")
+	require.NoError(t, search.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "search"}))
+	got, err := search.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "search", got.Template)
+	_, err = search.Get(registry.WithNamespace(ctx, "support"), "p", "1.0.0")
+	assert.ErrorIs(t, err, registry.ErrForbidden, "keys cannot leave their namespace")
+
+	got, err = admin.Get(registry.WithNamespace(ctx, "search"), "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "search", got.Template)
+	list, err := admin.List(ctx, registry.Filter{Namespace: "support"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "p", list[0].ID)
+	assert.Equal(t, "support", list[0].Template)
+	list, err = admin.List(ctx, registry.Filter{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "default", list[0].Template)
+
+	_, err = admin.Get(registry.WithNamespace(ctx, "bad ns"), "p", "1.0.0")
+	assert.ErrorIs(t, err, registry.ErrInvalidNamespace)
+}
+
+func TestHTTPClient_ListSearch(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "sum", Version: "1.0.0", Name: "Summarizer", Template: "Summarize {{.text}}",
+		Metadata: map[string]interface{}{"team": "search"}}))
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "tr", Version: "1.0.0", Template: "Translate {{.text}}",
+		Metadata: map[string]interface{}{"team": "i18n"}}))
+
+	list, err := c.List(ctx, registry.Filter{Query: "summarize text"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "sum", list[0].ID)
+	list, err = c.List(ctx, registry.Filter{Metadata: map[string]string{"team": "i18n"}})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "tr", list[0].ID)
+}
+
+func TestHTTPClient_ListCursor(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(New(registry.NewMemoryRegistry(), "").Handler())
+	t.Cleanup(srv.Close)
+	c := registry.NewHTTPClient(srv.URL, srv.Client())
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"b", "c", "a"} {
+		require.NoError(t, c.Store(ctx, &core.Prompt{ID: id, Version: "1.0.0", Template: "t", CreatedAt: base.Add(time.Duration(i) * time.Hour)}))
+	}
+
+	filter := registry.Filter{Limit: 2, SortBy: registry.SortByCreatedAt, Descending: true}
+	page, next, err := registry.ListPage(ctx, c, filter)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, []string{"a", "c"}, []string{page[0].ID, page[1].ID})
+	filter.Cursor = next
+	page, next, err = registry.ListPage(ctx, c, filter)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "b", page[0].ID)
+	assert.Empty(t, next)
+
+	resp, err := srv.Client().Get(srv.URL + "/prompts?limit=1&sort=id")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEmpty(t, resp.Header.Get(registry.NextCursorHeader))
+
+	_, err = c.List(ctx, registry.Filter{Cursor: "bogus"})
+	assert.ErrorIs(t, err, registry.ErrInvalidCursor)
+	_, err = c.List(ctx, registry.Filter{SortBy: "name"})
+	assert.ErrorIs(t, err, registry.ErrInvalidSort)
+}
+
+func TestHTTPClient_PromotionDenied(t *testing.T) {
+	ctx := context.Background()
+	guarded := registry.NewGuarded(registry.NewMemoryRegistry(), registry.RequireTag(registry.StageProduction, "approved"))
+	srv := httptest.NewServer(New(guarded, "").Handler())
+	t.Cleanup(srv.Close)
+	c := registry.NewHTTPClient(srv.URL, srv.Client())
+	require.NoError(t, c.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "t"}))
+	err := c.Promote(ctx, "p", "1.0.0", registry.StageProduction)
+	assert.ErrorIs(t, err, registry.ErrPromotionDenied)
+	assert.Contains(t, err.Error(), "must be tagged approved")
+	require.NoError(t, c.Tag(ctx, "p", "1.0.0", []string{"approved"}))
+	assert.NoError(t, c.Promote(ctx, "p", "1.0.0", registry.StageProduction))
+}
+
+func TestServer_Execute(t *testing.T) {
+	ctx := context.Background()
+	reg := registry.NewMemoryRegistry()
+	p := &core.Prompt{ID: "greet", Version: "1.0.0", Template: "Hi {{.name}}",
+		Variables: []core.Variable{{Name: "name", Type: core.VariableTypeString, Required: true}}}
+	require.NoError(t, reg.Store(ctx, p))
+	require.NoError(t, reg.Promote(ctx, "greet", "1.0.0", registry.StageProduction))
+	s := New(reg, "")
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	post := func(path, body string) (*http.Response, string) {
+		resp, err := srv.Client().Post(srv.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(data)
+	}
+
+	resp, _ := post("/prompts/greet/execute", `{"input": {"name": "Ada"}}`)
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode, "no executor")
+
+	fake := loomtest.NewProvider().On(`Hi Ada`, loomtest.Reply{Content: "Hello there Ada"})
+	s.Executor = executor.New(fake)
+
+	resp, body := post("/prompts/greet/execute", `{"input": {"name": "Ada"}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	var done executeEvent
+	require.NoError(t, json.Unmarshal([]byte(body), &done))
+	assert.Equal(t, executeEvent{Type: "done", ID: "greet", Version: "1.0.0", Content: "Hello there Ada", Model: done.Model, Usage: done.Usage}, done)
+	require.NotNil(t, done.Usage)
+
+	resp, body = post("/prompts/greet/execute?stream=true", `{"input": {"name": "Ada"}, "version": "1.0.0"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, 3, strings.Count(body, "event: chunk\n"))
+	assert.Contains(t, body, `event: chunk`+"\n"+`data: {"type":"chunk","content":"Hello "}`)
+	assert.Contains(t, body, `event: done`+"\n"+`data: {"type":"done","id":"greet","version":"1.0.0","content":"Hello there Ada"`)
+
+	resp, _ = post("/prompts/greet/execute?stream=true", `{"input": {}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "missing variable")
+	resp, _ = post("/prompts/nope/execute", `{"input": {}}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/prompts/greet/execute/ws", "", srv.URL)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, websocket.JSON.Send(ws, executeRequest{Input: core.Input{"name": "Ada"}}))
+	var events []executeEvent
+	for {
+		var e executeEvent
+		if err := websocket.JSON.Receive(ws, &e); err != nil {
+			break
+		}
+		events = append(events, e)
+	}
+	require.Len(t, events, 4)
+	assert.Equal(t, "Hello ", events[0].Content)
+	assert.Equal(t, "done", events[3].Type)
+	assert.Equal(t, "Hello there Ada", events[3].Content)
+
+	s.Executor = executor.New(nil, executor.WithOffline(true))
+	resp, body = post("/prompts/greet/execute", `{"input": {"name": "Ada"}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	done = executeEvent{}
+	require.NoError(t, json.Unmarshal([]byte(body), &done))
+	assert.Equal(t, "Hi Ada", done.Content)
+	assert.True(t, done.Offline)
+	_, body = post("/prompts/greet/execute?stream=true", `{"input": {"name": "Ada"}}`)
+	assert.Contains(t, body, `"offline":true`)
+}
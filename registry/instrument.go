@@ -0,0 +1,250 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// Op names a registry operation reported to an Instrumenter: the method name in snake case, e.g.
+// "get_production" or "store_if_match".
+type Op string
+
+// Operations reported by InstrumentedRegistry.
+const (
+	OpStore              Op = "store"
+	OpStoreIfMatch       Op = "store_if_match"
+	OpStoreBatch         Op = "store_batch"
+	OpGet                Op = "get"
+	OpGetProduction      Op = "get_production"
+	OpGetMany            Op = "get_many"
+	OpList               Op = "list"
+	OpListVersions       Op = "list_versions"
+	OpPromote            Op = "promote"
+	OpRollbackProduction Op = "rollback_production"
+	OpDelete             Op = "delete"
+	OpDeleteBatch        Op = "delete_batch"
+	OpTag                Op = "tag"
+	OpArchive            Op = "archive"
+	OpRestore            Op = "restore"
+	OpSetAlias           Op = "set_alias"
+	OpAliases            Op = "aliases"
+	OpHistory            Op = "history"
+)
+
+// Outcome classifies how an operation ended.
+type Outcome string
+
+const (
+	// OutcomeOK is a successful operation (for reads, a hit).
+	OutcomeOK Outcome = "ok"
+	// OutcomeMiss is an operation that failed with core.ErrPromptNotFound.
+	OutcomeMiss Outcome = "miss"
+	// OutcomeConflict is an operation that failed with core.ErrConflict.
+	OutcomeConflict Outcome = "conflict"
+	// OutcomeError is any other failure.
+	OutcomeError Outcome = "error"
+)
+
+// OutcomeOf classifies err as an Outcome.
+func OutcomeOf(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.Is(err, core.ErrPromptNotFound):
+		return OutcomeMiss
+	case errors.Is(err, core.ErrConflict):
+		return OutcomeConflict
+	default:
+		return OutcomeError
+	}
+}
+
+// Observation is one registry operation as reported to an Instrumenter.
+type Observation struct {
+	// Backend is the name given to NewInstrumented, e.g. "postgres".
+	Backend  string
+	Op       Op
+	Duration time.Duration
+	Outcome  Outcome
+	// Err is the operation's error, nil on success.
+	Err error
+}
+
+// Instrumenter receives an Observation for every operation of an InstrumentedRegistry, e.g. to
+// export latency histograms and hit, miss, and error counters (see registry/prommetrics). Observe
+// runs on the caller's goroutine after the operation, so it should not block.
+type Instrumenter interface {
+	Observe(ctx context.Context, o Observation)
+}
+
+// InstrumenterFunc adapts a function to Instrumenter.
+type InstrumenterFunc func(ctx context.Context, o Observation)
+
+// Observe implements Instrumenter.
+func (f InstrumenterFunc) Observe(ctx context.Context, o Observation) { f(ctx, o) }
+
+// InstrumentedRegistry reports the latency and outcome of every operation on another Registry to
+// an Instrumenter, so a slow or failing backend shows up in metrics. Wrap the backend directly
+// (innermost) to measure it alone, or the outermost decorator to measure what callers see.
+type InstrumentedRegistry struct {
+	inner   Registry
+	backend string
+	inst    Instrumenter
+}
+
+// NewInstrumented returns inner reporting to inst under the given backend name.
+func NewInstrumented(inner Registry, backend string, inst Instrumenter) *InstrumentedRegistry {
+	return &InstrumentedRegistry{inner: inner, backend: backend, inst: inst}
+}
+
+// observe reports op, started at start, with err; it returns err.
+func (r *InstrumentedRegistry) observe(ctx context.Context, op Op, start time.Time, err error) error {
+	r.inst.Observe(ctx, Observation{Backend: r.backend, Op: op, Duration: time.Since(start), Outcome: OutcomeOf(err), Err: err})
+	return err
+}
+
+// Store implements Registry.
+func (r *InstrumentedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	start := time.Now()
+	return r.observe(ctx, OpStore, start, r.inner.Store(ctx, prompt))
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does).
+func (r *InstrumentedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	start := time.Now()
+	return r.observe(ctx, OpStoreIfMatch, start, StoreIfMatch(ctx, r.inner, prompt, revision))
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time).
+func (r *InstrumentedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	start := time.Now()
+	return r.observe(ctx, OpStoreBatch, start, StoreBatch(ctx, r.inner, prompts))
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time).
+func (r *InstrumentedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	start := time.Now()
+	return r.observe(ctx, OpDeleteBatch, start, DeleteBatch(ctx, r.inner, refs))
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time).
+func (r *InstrumentedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	start := time.Now()
+	prompts, err := GetMany(ctx, r.inner, refs)
+	return prompts, r.observe(ctx, OpGetMany, start, err)
+}
+
+// Get implements Registry.
+func (r *InstrumentedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	start := time.Now()
+	p, err := r.inner.Get(ctx, id, version)
+	return p, r.observe(ctx, OpGet, start, err)
+}
+
+// GetProduction implements Registry.
+func (r *InstrumentedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	start := time.Now()
+	p, err := r.inner.GetProduction(ctx, id)
+	return p, r.observe(ctx, OpGetProduction, start, err)
+}
+
+// List implements Registry.
+func (r *InstrumentedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	start := time.Now()
+	prompts, err := r.inner.List(ctx, filter)
+	return prompts, r.observe(ctx, OpList, start, err)
+}
+
+// ListVersions implements Registry.
+func (r *InstrumentedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	start := time.Now()
+	infos, err := r.inner.ListVersions(ctx, id)
+	return infos, r.observe(ctx, OpListVersions, start, err)
+}
+
+// Promote implements Registry.
+func (r *InstrumentedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	start := time.Now()
+	return r.observe(ctx, OpPromote, start, r.inner.Promote(ctx, id, version, stage))
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does).
+func (r *InstrumentedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	start := time.Now()
+	return r.observe(ctx, OpRollbackProduction, start, RollbackProduction(ctx, r.inner, id, from, version))
+}
+
+// Delete implements Registry.
+func (r *InstrumentedRegistry) Delete(ctx context.Context, id, version string) error {
+	start := time.Now()
+	return r.observe(ctx, OpDelete, start, r.inner.Delete(ctx, id, version))
+}
+
+// Tag implements Registry.
+func (r *InstrumentedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	start := time.Now()
+	return r.observe(ctx, OpTag, start, r.inner.Tag(ctx, id, version, tags))
+}
+
+// Archive implements Registry.
+func (r *InstrumentedRegistry) Archive(ctx context.Context, id, version string) error {
+	start := time.Now()
+	return r.observe(ctx, OpArchive, start, r.inner.Archive(ctx, id, version))
+}
+
+// Restore implements Registry.
+func (r *InstrumentedRegistry) Restore(ctx context.Context, id, version string) error {
+	start := time.Now()
+	return r.observe(ctx, OpRestore, start, r.inner.Restore(ctx, id, version))
+}
+
+// SetAlias implements Aliaser (if inner does).
+func (r *InstrumentedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	start := time.Now()
+	return r.observe(ctx, OpSetAlias, start, SetAlias(ctx, r.inner, id, alias, version))
+}
+
+// Aliases implements Aliaser (if inner does).
+func (r *InstrumentedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	start := time.Now()
+	aliases, err := Aliases(ctx, r.inner, id)
+	return aliases, r.observe(ctx, OpAliases, start, err)
+}
+
+// History implements Auditor (if inner does).
+func (r *InstrumentedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	start := time.Now()
+	entries, err := History(ctx, r.inner, id)
+	return entries, r.observe(ctx, OpHistory, start, err)
+}
+
+// Usage implements UsageReporter (if inner does); it is not instrumented.
+func (r *InstrumentedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, r.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does); it is not instrumented.
+func (r *InstrumentedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, r.inner)
+}
+
+// WatchChanges implements ChangeWatcher (if inner does); it runs until ctx ends and is not
+// instrumented.
+func (r *InstrumentedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, r.inner, fn)
+}
+
+// Ensure InstrumentedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*InstrumentedRegistry)(nil)
+	_ ConditionalStorer    = (*InstrumentedRegistry)(nil)
+	_ Auditor              = (*InstrumentedRegistry)(nil)
+	_ UsageReporter        = (*InstrumentedRegistry)(nil)
+	_ ChangeWatcher        = (*InstrumentedRegistry)(nil)
+	_ Aliaser              = (*InstrumentedRegistry)(nil)
+	_ Batcher              = (*InstrumentedRegistry)(nil)
+	_ ProductionRollbacker = (*InstrumentedRegistry)(nil)
+)
@@ -0,0 +1,45 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedRegistry(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var obs []Observation
+	reg := NewInstrumented(NewMemoryRegistry(), "memory", InstrumenterFunc(func(_ context.Context, o Observation) {
+		mu.Lock()
+		defer mu.Unlock()
+		obs = append(obs, o)
+	}))
+
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "greet", Version: "1.0.0", Template: "Hi"}))
+	_, err := reg.Get(ctx, "greet", "1.0.0")
+	require.NoError(t, err)
+	_, err = reg.GetProduction(ctx, "greet")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	err = reg.StoreIfMatch(ctx, &core.Prompt{ID: "greet", Version: "1.0.0", Template: "Hey"}, 0)
+	assert.ErrorIs(t, err, core.ErrConflict)
+	_, err = reg.List(ctx, Filter{Cursor: "not a cursor"})
+	assert.Error(t, err)
+
+	require.Len(t, obs, 5)
+	for _, o := range obs {
+		assert.Equal(t, "memory", o.Backend)
+	}
+	assert.Equal(t, []Op{OpStore, OpGet, OpGetProduction, OpStoreIfMatch, OpList},
+		[]Op{obs[0].Op, obs[1].Op, obs[2].Op, obs[3].Op, obs[4].Op})
+	assert.Equal(t, []Outcome{OutcomeOK, OutcomeOK, OutcomeMiss, OutcomeConflict, OutcomeError},
+		[]Outcome{obs[0].Outcome, obs[1].Outcome, obs[2].Outcome, obs[3].Outcome, obs[4].Outcome})
+	assert.Error(t, obs[4].Err)
+
+	assert.Equal(t, OutcomeMiss, OutcomeOf(errors.Join(errors.New("wrapped"), core.ErrPromptNotFound)))
+}
@@ -0,0 +1,311 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+var (
+	// ErrRateLimited is returned when an API key exceeds its request rate.
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrQuotaExceeded is returned by QuotaRegistry when a store would exceed a storage quota.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// TenantMetadataKey is the prompt metadata key QuotaRegistry uses to record which tenant created a version.
+const TenantMetadataKey = "loom_tenant"
+
+// Limits caps what one API key may do on a shared registry server. Zero values mean unlimited.
+type Limits struct {
+	// Tenant owns the prompts created with this key; keys with the same tenant share MaxPrompts.
+	Tenant string `json:"tenant"`
+	// RequestsPerMinute limits API calls made with the key.
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// MaxPrompts limits the number of prompt IDs owned by Tenant.
+	MaxPrompts int `json:"max_prompts"`
+	// MaxVersions limits how many versions a prompt ID may reach when the key adds one.
+	MaxVersions int `json:"max_versions"`
+}
+
+// RateLimiter counts requests per key in fixed one-minute windows. The zero value is ready to use.
+type RateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+	now     func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// Allow records a request for key and reports whether it is within perMinute. If not, it returns
+// how long until the window resets. perMinute <= 0 always allows.
+func (l *RateLimiter) Allow(key string, perMinute int) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+	if l.windows == nil {
+		l.windows = make(map[string]*rateWindow)
+	}
+	w := l.windows[key]
+	if w == nil || now.Sub(w.start) >= time.Minute {
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= perMinute {
+		return false, w.start.Add(time.Minute).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// QuotaRegistry enforces the storage quotas in Limits on top of another Registry. New versions
+// are stamped with the tenant (TenantMetadataKey) so MaxPrompts can count the tenant's prompt IDs.
+// Overwriting an existing version is not limited.
+type QuotaRegistry struct {
+	inner  Registry
+	limits Limits
+}
+
+// NewQuota returns inner with limits' MaxPrompts and MaxVersions enforced on Store.
+func NewQuota(inner Registry, limits Limits) *QuotaRegistry {
+	return &QuotaRegistry{inner: inner, limits: limits}
+}
+
+// check verifies that storing prompt stays within quota and stamps the tenant on new versions.
+func (q *QuotaRegistry) check(ctx context.Context, prompt *core.Prompt) error {
+	if prompt == nil {
+		return nil
+	}
+	infos, err := q.inner.ListVersions(ctx, prompt.ID)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.Version == prompt.Version {
+			return nil
+		}
+	}
+	if q.limits.MaxVersions > 0 && len(infos) >= q.limits.MaxVersions {
+		return fmt.Errorf("%w: %s already has %d versions (max %d)", ErrQuotaExceeded, prompt.ID, len(infos), q.limits.MaxVersions)
+	}
+	if q.limits.MaxPrompts > 0 && len(infos) == 0 {
+		n, err := q.countOwned(ctx)
+		if err != nil {
+			return err
+		}
+		if n >= q.limits.MaxPrompts {
+			return fmt.Errorf("%w: tenant %q owns %d prompts (max %d)", ErrQuotaExceeded, q.limits.Tenant, n, q.limits.MaxPrompts)
+		}
+	}
+	if q.limits.Tenant != "" {
+		if prompt.Metadata == nil {
+			prompt.Metadata = make(map[string]interface{})
+		}
+		prompt.Metadata[TenantMetadataKey] = q.limits.Tenant
+	}
+	return nil
+}
+
+// checkBatch is check for a batch, counting the batch's own new versions and prompt IDs.
+func (q *QuotaRegistry) checkBatch(ctx context.Context, prompts []*core.Prompt) error {
+	var newPrompts []*core.Prompt
+	newIDs := 0
+	for _, id := range refIDs(promptRefs(prompts)) {
+		infos, err := q.inner.ListVersions(ctx, id)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool, len(infos))
+		for _, info := range infos {
+			existing[info.Version] = true
+		}
+		added := 0
+		for _, p := range prompts {
+			if p.ID == id && !existing[p.Version] {
+				newPrompts = append(newPrompts, p)
+				added++
+			}
+		}
+		if q.limits.MaxVersions > 0 && added > 0 && len(infos)+added > q.limits.MaxVersions {
+			return fmt.Errorf("%w: %s would have %d versions (max %d)", ErrQuotaExceeded, id, len(infos)+added, q.limits.MaxVersions)
+		}
+		if len(infos) == 0 {
+			newIDs++
+		}
+	}
+	if q.limits.MaxPrompts > 0 && newIDs > 0 {
+		n, err := q.countOwned(ctx)
+		if err != nil {
+			return err
+		}
+		if n+newIDs > q.limits.MaxPrompts {
+			return fmt.Errorf("%w: tenant %q would own %d prompts (max %d)", ErrQuotaExceeded, q.limits.Tenant, n+newIDs, q.limits.MaxPrompts)
+		}
+	}
+	if q.limits.Tenant != "" {
+		for _, p := range newPrompts {
+			if p.Metadata == nil {
+				p.Metadata = make(map[string]interface{})
+			}
+			p.Metadata[TenantMetadataKey] = q.limits.Tenant
+		}
+	}
+	return nil
+}
+
+// countOwned counts distinct prompt IDs with a version stamped with the tenant.
+func (q *QuotaRegistry) countOwned(ctx context.Context) (int, error) {
+	owned := make(map[string]bool)
+	err := eachPage(ctx, q.inner, Filter{Limit: 1000}, func(prompts []*core.Prompt) (bool, error) {
+		for _, p := range prompts {
+			if t, _ := p.Metadata[TenantMetadataKey].(string); t != "" && t == q.limits.Tenant {
+				owned[p.ID] = true
+			}
+		}
+		return false, nil
+	})
+	return len(owned), err
+}
+
+// Store implements Registry, enforcing quotas for new versions.
+func (q *QuotaRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	if err := q.check(ctx, prompt); err != nil {
+		return err
+	}
+	return q.inner.Store(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does), enforcing quotas for new versions.
+func (q *QuotaRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if err := q.check(ctx, prompt); err != nil {
+		return err
+	}
+	return StoreIfMatch(ctx, q.inner, prompt, revision)
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time). Nothing is stored if the
+// batch's new versions would exceed a quota.
+func (q *QuotaRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	if err := q.checkBatch(ctx, prompts); err != nil {
+		return err
+	}
+	return StoreBatch(ctx, q.inner, prompts)
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time).
+func (q *QuotaRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	return DeleteBatch(ctx, q.inner, refs)
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time).
+func (q *QuotaRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	return GetMany(ctx, q.inner, refs)
+}
+
+// Get implements Registry.
+func (q *QuotaRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	return q.inner.Get(ctx, id, version)
+}
+
+// GetProduction implements Registry.
+func (q *QuotaRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	return q.inner.GetProduction(ctx, id)
+}
+
+// List implements Registry.
+func (q *QuotaRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	return q.inner.List(ctx, filter)
+}
+
+// ListVersions implements Registry.
+func (q *QuotaRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	return q.inner.ListVersions(ctx, id)
+}
+
+// Promote implements Registry.
+func (q *QuotaRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	return q.inner.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does).
+func (q *QuotaRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	return RollbackProduction(ctx, q.inner, id, from, version)
+}
+
+// Delete implements Registry.
+func (q *QuotaRegistry) Delete(ctx context.Context, id, version string) error {
+	return q.inner.Delete(ctx, id, version)
+}
+
+// Tag implements Registry.
+func (q *QuotaRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	return q.inner.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry. Archived versions still count towards quotas.
+func (q *QuotaRegistry) Archive(ctx context.Context, id, version string) error {
+	return q.inner.Archive(ctx, id, version)
+}
+
+// Restore implements Registry.
+func (q *QuotaRegistry) Restore(ctx context.Context, id, version string) error {
+	return q.inner.Restore(ctx, id, version)
+}
+
+// SetAlias implements Aliaser (if inner does).
+func (q *QuotaRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	return SetAlias(ctx, q.inner, id, alias, version)
+}
+
+// Aliases implements Aliaser (if inner does).
+func (q *QuotaRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return Aliases(ctx, q.inner, id)
+}
+
+// History implements Auditor (if inner does).
+func (q *QuotaRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	return History(ctx, q.inner, id)
+}
+
+// Usage implements UsageReporter (if inner does).
+func (q *QuotaRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, q.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does).
+func (q *QuotaRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, q.inner)
+}
+
+// WatchChanges implements ChangeWatcher (if inner does).
+func (q *QuotaRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, q.inner, fn)
+}
+
+// Ensure QuotaRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*QuotaRegistry)(nil)
+	_ ConditionalStorer    = (*QuotaRegistry)(nil)
+	_ Auditor              = (*QuotaRegistry)(nil)
+	_ UsageReporter        = (*QuotaRegistry)(nil)
+	_ ChangeWatcher        = (*QuotaRegistry)(nil)
+	_ Aliaser              = (*QuotaRegistry)(nil)
+	_ Batcher              = (*QuotaRegistry)(nil)
+	_ ProductionRollbacker = (*QuotaRegistry)(nil)
+)
@@ -0,0 +1,55 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := &RateLimiter{now: func() time.Time { return now }}
+
+	ok, _ := l.Allow("a", 2)
+	assert.True(t, ok)
+	ok, _ = l.Allow("a", 2)
+	assert.True(t, ok)
+	ok, wait := l.Allow("a", 2)
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, wait)
+	ok, _ = l.Allow("b", 2)
+	assert.True(t, ok, "keys are limited independently")
+	ok, _ = l.Allow("a", 0)
+	assert.True(t, ok, "zero means unlimited")
+
+	now = now.Add(time.Minute)
+	ok, _ = l.Allow("a", 2)
+	assert.True(t, ok, "window resets")
+}
+
+func TestQuotaRegistry(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryRegistry()
+	q := NewQuota(inner, Limits{Tenant: "team-a", MaxPrompts: 1, MaxVersions: 2})
+
+	require.NoError(t, q.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, q.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v2"}))
+	err := q.Store(ctx, &core.Prompt{ID: "p", Version: "3.0.0", Template: "v3"})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	require.NoError(t, q.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v2 fixed"}), "overwriting is not limited")
+
+	err = q.Store(ctx, &core.Prompt{ID: "other", Version: "1.0.0", Template: "x"})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	p, err := inner.Get(ctx, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", p.Metadata[TenantMetadataKey])
+
+	// Prompts owned by other tenants do not count.
+	b := NewQuota(inner, Limits{Tenant: "team-b", MaxPrompts: 1})
+	require.NoError(t, b.Store(ctx, &core.Prompt{ID: "other", Version: "1.0.0", Template: "x"}))
+}
@@ -3,6 +3,7 @@ package registry
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/klejdi94/loom/core"
@@ -15,6 +16,9 @@ type MemoryRegistry struct {
 	production map[string]string                 // id -> version
 	stages    map[string]map[string]Stage         // id -> version -> stage
 	tags      map[string][]string // id:version -> tags
+	archived  map[string]bool     // id:version -> archived
+	audit     map[string][]AuditEntry // id -> entries, oldest first
+	aliases   map[string]map[string]string // id -> alias -> version
 }
 
 // NewMemoryRegistry creates an empty in-memory registry.
@@ -24,6 +28,9 @@ func NewMemoryRegistry() *MemoryRegistry {
 		production: make(map[string]string),
 		stages:     make(map[string]map[string]Stage),
 		tags:       make(map[string][]string),
+		archived:   make(map[string]bool),
+		audit:      make(map[string][]AuditEntry),
+		aliases:    make(map[string]map[string]string),
 	}
 }
 
@@ -35,15 +42,62 @@ func (m *MemoryRegistry) key(id, version string) string {
 func (m *MemoryRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err := m.store(prompt, -1); err != nil {
+		return err
+	}
+	m.recordStore(ctx, prompt)
+	return nil
+}
+
+// StoreIfMatch implements ConditionalStorer.
+func (m *MemoryRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.store(prompt, revision); err != nil {
+		return err
+	}
+	m.recordStore(ctx, prompt)
+	return nil
+}
+
+func (m *MemoryRegistry) recordStore(ctx context.Context, prompt *core.Prompt) {
+	e := NewAuditEntry(ctx, AuditStore, prompt.ID, prompt.Version)
+	e.Revision = prompt.Revision
+	m.record(e)
+}
+
+// record appends e to the audit log. Caller must hold m.mu.
+func (m *MemoryRegistry) record(e AuditEntry) {
+	m.audit[e.ID] = append(m.audit[e.ID], e)
+}
+
+// History implements Auditor.
+func (m *MemoryRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]AuditEntry(nil), m.audit[id]...), nil
+}
+
+// store saves prompt with the next revision; if revision >= 0 it must match the stored one.
+// Caller must hold m.mu.
+func (m *MemoryRegistry) store(prompt *core.Prompt, revision int64) error {
 	if prompt == nil {
 		return fmt.Errorf("prompt is nil")
 	}
 	if prompt.ID == "" || prompt.Version == "" {
 		return fmt.Errorf("prompt id and version are required")
 	}
+	var current int64
+	if old, ok := m.prompts[prompt.ID][prompt.Version]; ok {
+		current = old.Revision
+	}
+	if revision >= 0 && revision != current {
+		return core.ErrConflict
+	}
 	if m.prompts[prompt.ID] == nil {
 		m.prompts[prompt.ID] = make(map[string]*core.Prompt)
 	}
+	prompt.Revision = current + 1
 	// Copy so caller cannot mutate stored prompt
 	p := copyPrompt(prompt)
 	m.prompts[prompt.ID][prompt.Version] = p
@@ -56,8 +110,14 @@ func (m *MemoryRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
 	return nil
 }
 
-// Get returns a prompt by id and version.
+// Get returns a prompt by id and version (or "@alias").
 func (m *MemoryRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	if alias, ok := ParseAlias(version); ok {
+		m.mu.RLock()
+		target := m.aliases[id][alias]
+		m.mu.RUnlock()
+		return GetByAlias(ctx, m, id, alias, target)
+	}
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	versions, ok := m.prompts[id]
@@ -65,7 +125,7 @@ func (m *MemoryRegistry) Get(ctx context.Context, id, version string) (*core.Pro
 		return nil, core.ErrPromptNotFound
 	}
 	p, ok := versions[version]
-	if !ok {
+	if !ok || m.archived[m.key(id, version)] {
 		return nil, core.ErrPromptNotFound
 	}
 	return copyPrompt(p), nil
@@ -84,27 +144,27 @@ func (m *MemoryRegistry) GetProduction(ctx context.Context, id string) (*core.Pr
 		return nil, core.ErrPromptNotFound
 	}
 	p, ok := versions[version]
-	if !ok {
+	if !ok || m.archived[m.key(id, version)] {
 		return nil, core.ErrPromptNotFound
 	}
 	return copyPrompt(p), nil
 }
 
-// List returns prompts matching the filter.
+// List returns prompts matching the filter in the filter's order (by default id, then semantic version).
 func (m *MemoryRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	var out []*core.Prompt
-	offset := filter.Offset
-	limit := filter.Limit
-	if limit <= 0 {
-		limit = 1000
-	}
-	for id, versions := range m.prompts {
+	var entries []ListEntry
+	for _, id := range m.sortedIDs() {
 		if len(filter.IDs) > 0 && !contains(filter.IDs, id) {
 			continue
 		}
-		for _, p := range versions {
+		versions := m.prompts[id]
+		for _, v := range m.sortedVersions(id) {
+			p := versions[v]
+			if !filter.IncludeArchived && m.archived[m.key(id, v)] {
+				continue
+			}
 			if filter.Stage != "" {
 				st := m.stages[id]
 				if st == nil || st[p.Version] != filter.Stage {
@@ -117,20 +177,20 @@ func (m *MemoryRegistry) List(ctx context.Context, filter Filter) ([]*core.Promp
 					continue
 				}
 			}
-			if offset > 0 {
-				offset--
-				continue
-			}
-			out = append(out, copyPrompt(p))
-			if len(out) >= limit {
-				return out, nil
-			}
+			entries = append(entries, ListEntry{ID: id, Version: v, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt, Prompt: p})
 		}
 	}
+	out, err := filter.Page(entries, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range out {
+		out[i] = copyPrompt(p)
+	}
 	return out, nil
 }
 
-// ListVersions returns version info for an id.
+// ListVersions returns version info for an id in ascending semantic version order.
 func (m *MemoryRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -139,7 +199,8 @@ func (m *MemoryRegistry) ListVersions(ctx context.Context, id string) ([]Version
 		return nil, nil
 	}
 	var infos []VersionInfo
-	for v, p := range versions {
+	for _, v := range m.sortedVersions(id) {
+		p := versions[v]
 		st := StageDev
 		if s, ok := m.stages[id]; ok {
 			st = s[v]
@@ -152,6 +213,8 @@ func (m *MemoryRegistry) ListVersions(ctx context.Context, id string) ([]Version
 			Tags:      append([]string(nil), tags...),
 			CreatedAt: p.CreatedAt,
 			UpdatedAt: p.UpdatedAt,
+			Archived:  m.archived[m.key(id, v)],
+			Checksum:  Checksum(p),
 		})
 	}
 	return infos, nil
@@ -175,6 +238,30 @@ func (m *MemoryRegistry) Promote(ctx context.Context, id, version string, stage
 	if stage == StageProduction {
 		m.production[id] = version
 	}
+	e := NewAuditEntry(ctx, AuditPromote, id, version)
+	e.Stage = stage
+	m.record(e)
+	return nil
+}
+
+// RollbackProduction implements ProductionRollbacker under the registry's lock.
+func (m *MemoryRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.prompts[id][version]; !ok {
+		return core.ErrPromptNotFound
+	}
+	if m.production[id] != from {
+		return fmt.Errorf("%w: %s production is %q, not %q", core.ErrConflict, id, m.production[id], from)
+	}
+	if m.stages[id] == nil {
+		m.stages[id] = make(map[string]Stage)
+	}
+	m.stages[id][version] = StageProduction
+	m.production[id] = version
+	e := NewAuditEntry(ctx, AuditRollback, id, version)
+	e.Stage = StageProduction
+	m.record(e)
 	return nil
 }
 
@@ -189,7 +276,13 @@ func (m *MemoryRegistry) Delete(ctx context.Context, id, version string) error {
 	if _, ok := versions[version]; !ok {
 		return core.ErrPromptNotFound
 	}
-	delete(versions, version)
+	m.delete(ctx, id, version)
+	return nil
+}
+
+// delete removes an existing prompt version. Caller must hold m.mu.
+func (m *MemoryRegistry) delete(ctx context.Context, id, version string) {
+	delete(m.prompts[id], version)
 	if m.production[id] == version {
 		delete(m.production, id)
 	}
@@ -197,9 +290,57 @@ func (m *MemoryRegistry) Delete(ctx context.Context, id, version string) error {
 		delete(m.stages[id], version)
 	}
 	delete(m.tags, m.key(id, version))
+	delete(m.archived, m.key(id, version))
+	m.record(NewAuditEntry(ctx, AuditDelete, id, version))
+}
+
+// StoreBatch implements Batcher. The batch is validated before anything is stored.
+func (m *MemoryRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range prompts {
+		if err := m.store(p, -1); err != nil {
+			return err
+		}
+		m.recordStore(ctx, p)
+	}
+	return nil
+}
+
+// DeleteBatch implements Batcher. Nothing is deleted if one of the versions does not exist.
+func (m *MemoryRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ref := range refs {
+		if _, ok := m.prompts[ref.ID][ref.Version]; !ok {
+			return fmt.Errorf("%w: %s@%s", core.ErrPromptNotFound, ref.ID, ref.Version)
+		}
+	}
+	for _, ref := range refs {
+		m.delete(ctx, ref.ID, ref.Version)
+	}
 	return nil
 }
 
+// GetMany implements Batcher.
+func (m *MemoryRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]*core.Prompt, len(refs))
+	for i, ref := range refs {
+		if p, ok := m.prompts[ref.ID][ref.Version]; ok && !m.archived[m.key(ref.ID, ref.Version)] {
+			out[i] = copyPrompt(p)
+		}
+	}
+	return out, nil
+}
+
 // Tag sets tags for a prompt version.
 func (m *MemoryRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
 	m.mu.Lock()
@@ -212,6 +353,202 @@ func (m *MemoryRegistry) Tag(ctx context.Context, id, version string, tags []str
 		return core.ErrPromptNotFound
 	}
 	m.tags[m.key(id, version)] = append([]string(nil), tags...)
+	e := NewAuditEntry(ctx, AuditTag, id, version)
+	e.Tags = append([]string(nil), tags...)
+	m.record(e)
+	return nil
+}
+
+// Archive hides a prompt version from Get, GetProduction, and List until it is restored.
+func (m *MemoryRegistry) Archive(ctx context.Context, id, version string) error {
+	return m.setArchived(ctx, id, version, true)
+}
+
+// Restore makes an archived prompt version visible again.
+func (m *MemoryRegistry) Restore(ctx context.Context, id, version string) error {
+	return m.setArchived(ctx, id, version, false)
+}
+
+func (m *MemoryRegistry) setArchived(ctx context.Context, id, version string, archived bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.prompts[id][version]; !ok {
+		return core.ErrPromptNotFound
+	}
+	action := AuditArchive
+	if archived {
+		m.archived[m.key(id, version)] = true
+	} else {
+		delete(m.archived, m.key(id, version))
+		action = AuditRestore
+	}
+	m.record(NewAuditEntry(ctx, action, id, version))
+	return nil
+}
+
+// sortedIDs returns stored ids in lexical order. Caller must hold m.mu.
+func (m *MemoryRegistry) sortedIDs() []string {
+	ids := make([]string, 0, len(m.prompts))
+	for id := range m.prompts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sortedVersions returns the versions of id in ascending semver order. Caller must hold m.mu.
+func (m *MemoryRegistry) sortedVersions(id string) []string {
+	vers := make([]string, 0, len(m.prompts[id]))
+	for v := range m.prompts[id] {
+		vers = append(vers, v)
+	}
+	sortVersions(vers)
+	return vers
+}
+
+// SetAlias implements Aliaser.
+func (m *MemoryRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if err := ValidateAlias(alias); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if version == "" {
+		delete(m.aliases[id], alias)
+	} else {
+		if _, ok := m.prompts[id][version]; !ok {
+			return core.ErrPromptNotFound
+		}
+		if m.aliases[id] == nil {
+			m.aliases[id] = make(map[string]string)
+		}
+		m.aliases[id][alias] = version
+	}
+	e := NewAuditEntry(ctx, AuditAlias, id, version)
+	e.Alias = alias
+	m.record(e)
+	return nil
+}
+
+// Aliases implements Aliaser.
+func (m *MemoryRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]string, len(m.aliases[id]))
+	for a, v := range m.aliases[id] {
+		out[a] = v
+	}
+	return out, nil
+}
+
+// Snapshot is a point-in-time copy of a MemoryRegistry (prompts, stages, tags, production pointers, aliases).
+// It is JSON-serializable, so it can be saved as a test fixture and loaded with RestoreSnapshot.
+type Snapshot struct {
+	Entries    []SnapshotEntry   `json:"entries"`
+	Production map[string]string `json:"production,omitempty"`
+	// Aliases maps id -> alias -> version.
+	Aliases map[string]map[string]string `json:"aliases,omitempty"`
+}
+
+// SnapshotEntry is one stored prompt version with its stage, tags, and archived flag.
+type SnapshotEntry struct {
+	Prompt   *core.Prompt `json:"prompt"`
+	Stage    Stage        `json:"stage"`
+	Tags     []string     `json:"tags,omitempty"`
+	Archived bool         `json:"archived,omitempty"`
+}
+
+// Snapshot returns a deep copy of the registry contents, ordered by id and semantic version.
+func (m *MemoryRegistry) Snapshot() *Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snap := &Snapshot{Production: make(map[string]string, len(m.production))}
+	for id, v := range m.production {
+		snap.Production[id] = v
+	}
+	for id, as := range m.aliases {
+		if len(as) == 0 {
+			continue
+		}
+		if snap.Aliases == nil {
+			snap.Aliases = make(map[string]map[string]string)
+		}
+		snap.Aliases[id] = make(map[string]string, len(as))
+		for a, v := range as {
+			snap.Aliases[id][a] = v
+		}
+	}
+	for _, id := range m.sortedIDs() {
+		for _, v := range m.sortedVersions(id) {
+			snap.Entries = append(snap.Entries, SnapshotEntry{
+				Prompt: copyPrompt(m.prompts[id][v]),
+				Stage:  m.stages[id][v],
+				Tags:     append([]string(nil), m.tags[m.key(id, v)]...),
+				Archived: m.archived[m.key(id, v)],
+			})
+		}
+	}
+	return snap
+}
+
+// RestoreSnapshot replaces the registry contents with the snapshot. Production pointers must
+// reference versions present in the snapshot. It was named Restore until Registry.Restore, which
+// un-archives a version, took that name.
+func (m *MemoryRegistry) RestoreSnapshot(snap *Snapshot) error {
+	if snap == nil {
+		return fmt.Errorf("snapshot is nil")
+	}
+	prompts := make(map[string]map[string]*core.Prompt)
+	stages := make(map[string]map[string]Stage)
+	tags := make(map[string][]string)
+	archived := make(map[string]bool)
+	for _, e := range snap.Entries {
+		if e.Prompt == nil || e.Prompt.ID == "" || e.Prompt.Version == "" {
+			return fmt.Errorf("snapshot entry: prompt id and version are required")
+		}
+		id, v := e.Prompt.ID, e.Prompt.Version
+		if prompts[id] == nil {
+			prompts[id] = make(map[string]*core.Prompt)
+			stages[id] = make(map[string]Stage)
+		}
+		prompts[id][v] = copyPrompt(e.Prompt)
+		st := e.Stage
+		if st == "" {
+			st = StageDev
+		}
+		stages[id][v] = st
+		if len(e.Tags) > 0 {
+			tags[m.key(id, v)] = append([]string(nil), e.Tags...)
+		}
+		if e.Archived {
+			archived[m.key(id, v)] = true
+		}
+	}
+	production := make(map[string]string, len(snap.Production))
+	for id, v := range snap.Production {
+		if _, ok := prompts[id][v]; !ok {
+			return fmt.Errorf("snapshot production %s@%s: %w", id, v, core.ErrPromptNotFound)
+		}
+		production[id] = v
+	}
+	aliases := make(map[string]map[string]string, len(snap.Aliases))
+	for id, as := range snap.Aliases {
+		aliases[id] = make(map[string]string, len(as))
+		for a, v := range as {
+			if _, ok := prompts[id][v]; !ok {
+				return fmt.Errorf("snapshot alias %s@%s: %w", id, a, core.ErrPromptNotFound)
+			}
+			aliases[id][a] = v
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompts = prompts
+	m.stages = stages
+	m.tags = tags
+	m.archived = archived
+	m.production = production
+	m.aliases = aliases
 	return nil
 }
 
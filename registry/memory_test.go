@@ -49,3 +49,412 @@ func TestMemoryRegistry_ListVersions(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, vers, 2)
 }
+
+func TestMemoryRegistry_DeterministicOrder(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemoryRegistry()
+	for _, p := range []*core.Prompt{
+		{ID: "b", Version: "1.0.0"},
+		{ID: "a", Version: "1.10.0"},
+		{ID: "a", Version: "1.2.0"},
+		{ID: "a", Version: "1.2.0-rc.1"},
+	} {
+		require.NoError(t, reg.Store(ctx, p))
+	}
+	list, err := reg.List(ctx, Filter{})
+	require.NoError(t, err)
+	var got []string
+	for _, p := range list {
+		got = append(got, p.ID+"@"+p.Version)
+	}
+	assert.Equal(t, []string{"a@1.2.0-rc.1", "a@1.2.0", "a@1.10.0", "b@1.0.0"}, got)
+
+	vers, err := reg.ListVersions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, vers, 3)
+	assert.Equal(t, "1.10.0", vers[2].Version)
+}
+
+func TestMemoryRegistry_SnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemoryRegistry()
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p1", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p1", Version: "2.0.0", Template: "v2"}))
+	require.NoError(t, reg.Promote(ctx, "p1", "2.0.0", StageProduction))
+	require.NoError(t, reg.Tag(ctx, "p1", "1.0.0", []string{"old"}))
+	snap := reg.Snapshot()
+	require.Len(t, snap.Entries, 2)
+
+	require.NoError(t, reg.Delete(ctx, "p1", "2.0.0"))
+	require.NoError(t, reg.RestoreSnapshot(snap))
+
+	prod, err := reg.GetProduction(ctx, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", prod.Template)
+	tagged, err := reg.List(ctx, Filter{Tags: []string{"old"}})
+	require.NoError(t, err)
+	require.Len(t, tagged, 1)
+
+	other := NewMemoryRegistry()
+	require.NoError(t, other.RestoreSnapshot(snap))
+	assert.Equal(t, snap, other.Snapshot())
+	assert.Error(t, other.RestoreSnapshot(&Snapshot{Production: map[string]string{"x": "1"}}))
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, -1, CompareVersions("1.2.0", "1.10.0"))
+	assert.Equal(t, -1, CompareVersions("1.0.0-alpha", "1.0.0"))
+	assert.Equal(t, -1, CompareVersions("1.0.0-alpha.2", "1.0.0-alpha.10"))
+	assert.Equal(t, 0, CompareVersions("v1.0.0", "v1.0.0"))
+	assert.Equal(t, -1, CompareVersions("2.0.0", "latest"))
+}
+
+func TestMatchVersion(t *testing.T) {
+	for _, tc := range []struct {
+		constraint, version string
+		want                bool
+	}{
+		{"^1.2", "1.2.0", true},
+		{"^1.2", "1.9.3", true},
+		{"^1.2", "2.0.0", false},
+		{"^1.2", "1.1.9", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"1.x", "1.10.0", true},
+		{"1.2", "1.2.7", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{">=1.2 <1.5", "1.4.9", true},
+		{">=1.2 <1.5", "1.5.0", false},
+		{">1.2", "1.2.9", false},
+		{"<=1.2", "1.2.9", true},
+		{"^1 || ^3", "3.1.0", true},
+		{"*", "0.0.1", true},
+		{"^1.2", "1.3.0-rc.1", false},
+		{"^1.3.0-rc.1", "1.3.0-rc.2", true},
+		{"^1.3.0-rc.1", "1.4.0-rc.1", false},
+		{"^1", "latest", false},
+	} {
+		got, err := MatchVersion(tc.constraint, tc.version)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got, "%s matches %s", tc.version, tc.constraint)
+	}
+	_, err := MatchVersion("^one", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrInvalidVersion)
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			for _, v := range []string{"1.2.0", "1.10.0", "1.9.1", "2.0.0-rc.1", "0.9.0", "draft"} {
+				require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: v, Template: v}))
+			}
+			infos, err := reg.ListVersions(ctx, "p")
+			require.NoError(t, err)
+			var versions []string
+			for _, vi := range infos {
+				versions = append(versions, vi.Version)
+			}
+			assert.Equal(t, []string{"0.9.0", "1.2.0", "1.9.1", "1.10.0", "2.0.0-rc.1", "draft"}, versions)
+
+			latest, err := GetLatest(ctx, reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "1.10.0", latest.Version, "pre-releases and non-semver versions are skipped")
+
+			p, err := Resolve(ctx, reg, "p", "~1.9")
+			require.NoError(t, err)
+			assert.Equal(t, "1.9.1", p.Version)
+			p, err = Resolve(ctx, reg, "p", "^2.0.0-rc.0")
+			require.NoError(t, err)
+			assert.Equal(t, "2.0.0-rc.1", p.Version)
+
+			require.NoError(t, reg.Archive(ctx, "p", "1.10.0"))
+			p, err = Resolve(ctx, reg, "p", "^1.2")
+			require.NoError(t, err)
+			assert.Equal(t, "1.9.1", p.Version, "archived versions are skipped")
+
+			_, err = Resolve(ctx, reg, "p", "^3")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+			_, err = GetLatest(ctx, reg, "missing")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+		})
+	}
+}
+
+func TestStoreIfMatch(t *testing.T) {
+	ctx := context.Background()
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			p := &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}
+			require.NoError(t, StoreIfMatch(ctx, reg, p, 0))
+			assert.Equal(t, int64(1), p.Revision)
+			assert.ErrorIs(t, StoreIfMatch(ctx, reg, &core.Prompt{ID: "p", Version: "1.0.0"}, 0), core.ErrConflict)
+
+			// Two editors read revision 1; the second write loses.
+			a, err := reg.Get(ctx, "p", "1.0.0")
+			require.NoError(t, err)
+			b := a.Copy()
+			a.Template = "edit a"
+			require.NoError(t, StoreIfMatch(ctx, reg, a, a.Revision))
+			assert.Equal(t, int64(2), a.Revision)
+			b.Template = "edit b"
+			assert.ErrorIs(t, StoreIfMatch(ctx, reg, b, b.Revision), core.ErrConflict)
+
+			got, err := reg.Get(ctx, "p", "1.0.0")
+			require.NoError(t, err)
+			assert.Equal(t, "edit a", got.Template)
+			assert.Equal(t, int64(2), got.Revision)
+
+			// Unconditional Store still bumps the revision.
+			require.NoError(t, reg.Store(ctx, got))
+			assert.Equal(t, int64(3), got.Revision)
+		})
+	}
+}
+
+func TestArchiveRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fr, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "v2"}))
+			require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+			require.NoError(t, reg.Tag(ctx, "p", "1.0.0", []string{"stable"}))
+
+			require.NoError(t, reg.Archive(ctx, "p", "1.0.0"))
+			_, err := reg.Get(ctx, "p", "1.0.0")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+			_, err = reg.GetProduction(ctx, "p")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+			list, err := reg.List(ctx, Filter{})
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, "2.0.0", list[0].Version)
+			list, err = reg.List(ctx, Filter{IncludeArchived: true})
+			require.NoError(t, err)
+			assert.Len(t, list, 2)
+			infos, err := reg.ListVersions(ctx, "p")
+			require.NoError(t, err)
+			require.Len(t, infos, 2)
+			for _, info := range infos {
+				assert.Equal(t, info.Version == "1.0.0", info.Archived, info.Version)
+			}
+
+			require.NoError(t, reg.Restore(ctx, "p", "1.0.0"))
+			prod, err := reg.GetProduction(ctx, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "1.0.0", prod.Version)
+			list, err = reg.List(ctx, Filter{Tags: []string{"stable"}})
+			require.NoError(t, err)
+			assert.Len(t, list, 1)
+
+			assert.ErrorIs(t, reg.Archive(ctx, "p", "9.9.9"), core.ErrPromptNotFound)
+			assert.ErrorIs(t, reg.Restore(ctx, "p", "9.9.9"), core.ErrPromptNotFound)
+		})
+	}
+
+	// The archived flag survives a reload of the file registry.
+	require.NoError(t, fr.Archive(ctx, "p", "2.0.0"))
+	reopened, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	_, err = reopened.Get(ctx, "p", "2.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+}
+
+func TestHistory(t *testing.T) {
+	ctx := WithActor(context.Background(), "alice")
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+			require.NoError(t, reg.Store(context.Background(), &core.Prompt{ID: "other", Version: "1.0.0", Template: "x"}))
+			require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+			require.NoError(t, reg.Tag(ctx, "p", "1.0.0", []string{"stable"}))
+			require.NoError(t, reg.Archive(ctx, "p", "1.0.0"))
+			require.NoError(t, reg.Restore(ctx, "p", "1.0.0"))
+			require.NoError(t, reg.Delete(ctx, "p", "1.0.0"))
+			assert.Error(t, reg.Promote(ctx, "p", "9.9.9", StageProduction))
+
+			entries, err := History(ctx, reg, "p")
+			require.NoError(t, err)
+			require.Len(t, entries, 6)
+			var actions []AuditAction
+			for _, e := range entries {
+				actions = append(actions, e.Action)
+				assert.Equal(t, "alice", e.Actor)
+				assert.Equal(t, "1.0.0", e.Version)
+				assert.False(t, e.Time.IsZero())
+			}
+			assert.Equal(t, []AuditAction{AuditStore, AuditPromote, AuditTag, AuditArchive, AuditRestore, AuditDelete}, actions)
+			assert.Equal(t, int64(1), entries[0].Revision)
+			assert.Equal(t, StageProduction, entries[1].Stage)
+			assert.Equal(t, []string{"stable"}, entries[2].Tags)
+
+			other, err := History(ctx, reg, "other")
+			require.NoError(t, err)
+			require.Len(t, other, 1)
+			assert.Empty(t, other[0].Actor)
+		})
+	}
+}
+
+func TestAliases(t *testing.T) {
+	ctx := WithActor(context.Background(), "alice")
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			for _, v := range []string{"1.0.0", "1.1.0", "2.0.0-beta.1"} {
+				require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: v, Template: v}))
+			}
+
+			latest, err := reg.Get(ctx, "p", "@latest")
+			require.NoError(t, err)
+			assert.Equal(t, "1.1.0", latest.Version, "unset latest resolves to the highest release")
+			_, err = reg.Get(ctx, "p", "@stable")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+			require.NoError(t, SetAlias(ctx, reg, "p", "stable", "1.0.0"))
+			require.NoError(t, SetAlias(ctx, reg, "p", "latest", "2.0.0-beta.1"))
+			p, err := reg.Get(ctx, "p", "@stable")
+			require.NoError(t, err)
+			assert.Equal(t, "1.0.0", p.Template)
+			p, err = reg.Get(ctx, "p", "@latest")
+			require.NoError(t, err)
+			assert.Equal(t, "2.0.0-beta.1", p.Version)
+
+			assert.ErrorIs(t, SetAlias(ctx, reg, "p", "1bad", "1.0.0"), ErrInvalidAlias)
+			assert.ErrorIs(t, SetAlias(ctx, reg, "p", "canary", "9.9.9"), core.ErrPromptNotFound)
+
+			aliases, err := Aliases(ctx, reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"stable": "1.0.0", "latest": "2.0.0-beta.1"}, aliases)
+
+			require.NoError(t, SetAlias(ctx, reg, "p", "stable", ""))
+			_, err = reg.Get(ctx, "p", "@stable")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+			aliases, err = Aliases(ctx, reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"latest": "2.0.0-beta.1"}, aliases)
+
+			entries, err := History(ctx, reg, "p")
+			require.NoError(t, err)
+			require.Len(t, entries, 6)
+			assert.Equal(t, AuditAlias, entries[3].Action)
+			assert.Equal(t, "stable", entries[3].Alias)
+			assert.Equal(t, "1.0.0", entries[3].Version)
+			assert.Equal(t, "", entries[5].Version, "removal")
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	backends := map[string]Registry{
+		"memory":   NewMemoryRegistry(),
+		"file":     fr,
+		"fallback": struct{ Registry }{NewMemoryRegistry()}, // hides Batcher
+	}
+	for name, reg := range backends {
+		t.Run(name, func(t *testing.T) {
+			prompts := []*core.Prompt{
+				{ID: "p", Version: "1.0.0", Template: "a"},
+				{ID: "p", Version: "1.1.0", Template: "b"},
+				{ID: "q", Version: "1.0.0", Template: "c"},
+			}
+			require.NoError(t, StoreBatch(ctx, reg, prompts))
+			for _, p := range prompts {
+				assert.Equal(t, int64(1), p.Revision)
+			}
+			again := &core.Prompt{ID: "p", Version: "1.0.0", Template: "a2"}
+			require.NoError(t, StoreBatch(ctx, reg, []*core.Prompt{again}))
+			assert.Equal(t, int64(2), again.Revision)
+
+			dup := []*core.Prompt{{ID: "r", Version: "1.0.0"}, {ID: "r", Version: "1.0.0"}}
+			assert.ErrorIs(t, StoreBatch(ctx, reg, dup), ErrInvalidBatch)
+			assert.ErrorIs(t, StoreBatch(ctx, reg, []*core.Prompt{nil}), ErrInvalidBatch)
+			_, err := reg.Get(ctx, "r", "1.0.0")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound, "invalid batches store nothing")
+
+			require.NoError(t, reg.Archive(ctx, "q", "1.0.0"))
+			got, err := GetMany(ctx, reg, []VersionRef{
+				{ID: "p", Version: "1.0.0"}, {ID: "p", Version: "9.9.9"}, {ID: "p", Version: "1.1.0"}, {ID: "q", Version: "1.0.0"},
+			})
+			require.NoError(t, err)
+			require.Len(t, got, 4)
+			assert.Equal(t, "a2", got[0].Template)
+			assert.Nil(t, got[1])
+			assert.Equal(t, "b", got[2].Template)
+			assert.Nil(t, got[3], "archived")
+
+			require.NoError(t, reg.Promote(ctx, "p", "1.1.0", StageProduction))
+			err = DeleteBatch(ctx, reg, []VersionRef{{ID: "p", Version: "9.9.9"}, {ID: "p", Version: "1.1.0"}})
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+			_, err = reg.Get(ctx, "p", "1.1.0")
+			require.NoError(t, err, "failed batch deletes nothing")
+
+			require.NoError(t, DeleteBatch(ctx, reg, []VersionRef{{ID: "p", Version: "1.0.0"}, {ID: "p", Version: "1.1.0"}}))
+			_, err = reg.Get(ctx, "p", "1.0.0")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+			_, err = reg.GetProduction(ctx, "p")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound)
+			versions, err := reg.ListVersions(ctx, "p")
+			require.NoError(t, err)
+			assert.Empty(t, versions)
+
+			if _, ok := reg.(Auditor); ok {
+				entries, err := History(ctx, reg, "p")
+				require.NoError(t, err)
+				require.Len(t, entries, 6)
+				assert.Equal(t, AuditDelete, entries[4].Action)
+				assert.Equal(t, AuditDelete, entries[5].Action)
+			}
+		})
+	}
+}
+
+func TestListSearch(t *testing.T) {
+	ctx := context.Background()
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "sum", Version: "1.0.0", Name: "Summarizer", Template: "Summarize {{.text}}",
+				Metadata: map[string]interface{}{"team": "search", "tier": float64(2)}}))
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "sum", Version: "2.0.0", Name: "Summarizer", Description: "Bullet points",
+				Template: "List the key points of {{.text}}", Metadata: map[string]interface{}{"team": "search"}}))
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "tr", Version: "1.0.0", Template: "Translate {{.text}}",
+				Metadata: map[string]interface{}{"team": "i18n"}}))
+
+			ids := func(f Filter) []string {
+				list, err := reg.List(ctx, f)
+				require.NoError(t, err)
+				var out []string
+				for _, p := range list {
+					out = append(out, p.ID+"@"+p.Version)
+				}
+				return out
+			}
+			assert.Equal(t, []string{"sum@1.0.0", "sum@2.0.0"}, ids(Filter{Query: "summarizer"}))
+			assert.Equal(t, []string{"sum@2.0.0"}, ids(Filter{Query: "BULLET key"}))
+			assert.Equal(t, []string{"tr@1.0.0"}, ids(Filter{Query: "translate text"}))
+			assert.Empty(t, ids(Filter{Query: "translate bullet"}))
+			assert.Equal(t, []string{"sum@1.0.0", "sum@2.0.0"}, ids(Filter{Metadata: map[string]string{"team": "search"}}))
+			assert.Equal(t, []string{"sum@1.0.0"}, ids(Filter{Metadata: map[string]string{"team": "search", "tier": "2"}}))
+			assert.Equal(t, []string{"sum@2.0.0"}, ids(Filter{Query: "text", Metadata: map[string]string{"team": "search"}, Offset: 1}))
+		})
+	}
+}
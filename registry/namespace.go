@@ -0,0 +1,446 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrInvalidNamespace is returned for namespaces that ValidateNamespace rejects and for prompt ids
+// containing NamespaceSeparator.
+var ErrInvalidNamespace = errors.New("invalid namespace")
+
+// NamespaceSeparator joins a namespace and a prompt id in the ids a NamespacedRegistry stores in
+// its backend: prompt "summarize" in namespace "search" is stored as "search::summarize".
+const NamespaceSeparator = "::"
+
+// namespaceKey is the context key for the caller's namespace.
+type namespaceKey struct{}
+
+// WithNamespace returns a context whose registry calls use namespace ns (see NamespacedRegistry).
+// An empty ns is the default namespace.
+func WithNamespace(ctx context.Context, ns string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, ns)
+}
+
+// NamespaceFromContext returns the namespace set with WithNamespace, or "" (the default namespace).
+func NamespaceFromContext(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns
+}
+
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidateNamespace reports whether ns is a valid namespace name: up to 64 letters, digits, '.',
+// '_', or '-', starting with a letter or digit.
+func ValidateNamespace(ns string) error {
+	if !namespacePattern.MatchString(ns) {
+		return fmt.Errorf("%w %q: must be up to 64 letters, digits, '.', '_', or '-', starting with a letter or digit", ErrInvalidNamespace, ns)
+	}
+	return nil
+}
+
+// QualifyID returns the backend id of id in namespace ns; id itself in the default namespace.
+func QualifyID(ns, id string) string {
+	if ns == "" {
+		return id
+	}
+	return ns + NamespaceSeparator + id
+}
+
+// SplitID splits a backend id into its namespace and prompt id; ns is "" for ids in the default namespace.
+func SplitID(qualified string) (ns, id string) {
+	if ns, id, ok := strings.Cut(qualified, NamespaceSeparator); ok && namespacePattern.MatchString(ns) {
+		return ns, id
+	}
+	return "", qualified
+}
+
+// NamespacedRegistry partitions another Registry into namespaces, so several teams or projects can
+// share one backend without prompt id collisions. Each call uses the namespace of its context (see
+// WithNamespace), or Filter.Namespace for List: ids are stored with the namespace as a prefix (see
+// QualifyID) and returned without it, and every call only sees its own namespace. The default
+// namespace ("") stores ids unchanged, so an existing registry becomes the default namespace.
+//
+// Prompt ids may not contain NamespaceSeparator. Registry servers wrap their registry with
+// NewNamespaced and take the namespace from the caller (see NamespaceHeader).
+type NamespacedRegistry struct {
+	inner Registry
+}
+
+// NewNamespaced returns inner partitioned by namespace. If inner is already a NamespacedRegistry
+// it is returned as is.
+func NewNamespaced(inner Registry) *NamespacedRegistry {
+	if n, ok := inner.(*NamespacedRegistry); ok {
+		return n
+	}
+	return &NamespacedRegistry{inner: inner}
+}
+
+// namespace returns ctx's namespace after validating it.
+func (n *NamespacedRegistry) namespace(ctx context.Context) (string, error) {
+	ns := NamespaceFromContext(ctx)
+	if ns == "" {
+		return "", nil
+	}
+	return ns, ValidateNamespace(ns)
+}
+
+// qualify returns the backend id of id in ctx's namespace.
+func (n *NamespacedRegistry) qualify(ctx context.Context, id string) (string, error) {
+	ns, err := n.namespace(ctx)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(id, NamespaceSeparator) {
+		return "", fmt.Errorf("%w: prompt id %q contains %q", ErrInvalidNamespace, id, NamespaceSeparator)
+	}
+	return QualifyID(ns, id), nil
+}
+
+// unqualify returns the prompt id of a backend id if it is in namespace ns.
+func unqualify(ns, qualified string) (id string, ok bool) {
+	got, id := SplitID(qualified)
+	return id, got == ns
+}
+
+// Store implements Registry.
+func (n *NamespacedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	return n.store(ctx, prompt, func(p *core.Prompt) error { return n.inner.Store(ctx, p) })
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does).
+func (n *NamespacedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	return n.store(ctx, prompt, func(p *core.Prompt) error { return StoreIfMatch(ctx, n.inner, p, revision) })
+}
+
+// store calls fn with a copy of prompt under its backend id and copies back what fn set (e.g. Revision).
+func (n *NamespacedRegistry) store(ctx context.Context, prompt *core.Prompt, fn func(*core.Prompt) error) error {
+	if prompt == nil {
+		return fn(prompt)
+	}
+	qid, err := n.qualify(ctx, prompt.ID)
+	if err != nil {
+		return err
+	}
+	cp := *prompt
+	cp.ID = qid
+	if err := fn(&cp); err != nil {
+		return err
+	}
+	cp.ID = prompt.ID
+	*prompt = cp
+	return nil
+}
+
+// Get implements Registry.
+func (n *NamespacedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p, err := n.inner.Get(ctx, qid, version)
+	if err != nil {
+		return nil, err
+	}
+	p.ID = id
+	return p, nil
+}
+
+// GetProduction implements Registry.
+func (n *NamespacedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p, err := n.inner.GetProduction(ctx, qid)
+	if err != nil {
+		return nil, err
+	}
+	p.ID = id
+	return p, nil
+}
+
+// List implements Registry, listing the namespace in filter.Namespace, or ctx's namespace if it is
+// empty. Without filter.IDs the backend's list is paged through by cursor and filtered by namespace.
+func (n *NamespacedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	if filter.Namespace != "" {
+		ctx = WithNamespace(ctx, filter.Namespace)
+	}
+	ns, err := n.namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := filter.withCursorID(func(id string) (string, error) { return n.qualify(ctx, id) })
+	if err != nil {
+		return nil, err
+	}
+	inner.Namespace = ""
+	if len(filter.IDs) > 0 {
+		inner.IDs = make([]string, len(filter.IDs))
+		for i, id := range filter.IDs {
+			if inner.IDs[i], err = n.qualify(ctx, id); err != nil {
+				return nil, err
+			}
+		}
+		prompts, err := n.inner.List(ctx, inner)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range prompts {
+			p.ID, _ = unqualify(ns, p.ID)
+		}
+		return prompts, nil
+	}
+	limit := filter.limit()
+	skip := filter.Offset
+	var out []*core.Prompt
+	inner.Limit, inner.Offset = 1000, 0
+	err = eachPage(ctx, n.inner, inner, func(prompts []*core.Prompt) (bool, error) {
+		for _, p := range prompts {
+			id, ok := unqualify(ns, p.ID)
+			if !ok {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			p.ID = id
+			out = append(out, p)
+			if len(out) >= limit {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListVersions implements Registry.
+func (n *NamespacedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return n.inner.ListVersions(ctx, qid)
+}
+
+// Promote implements Registry.
+func (n *NamespacedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return err
+	}
+	return n.inner.Promote(ctx, qid, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does).
+func (n *NamespacedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return err
+	}
+	return RollbackProduction(ctx, n.inner, qid, from, version)
+}
+
+// Delete implements Registry.
+func (n *NamespacedRegistry) Delete(ctx context.Context, id, version string) error {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return err
+	}
+	return n.inner.Delete(ctx, qid, version)
+}
+
+// Tag implements Registry.
+func (n *NamespacedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return err
+	}
+	return n.inner.Tag(ctx, qid, version, tags)
+}
+
+// Archive implements Registry.
+func (n *NamespacedRegistry) Archive(ctx context.Context, id, version string) error {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return err
+	}
+	return n.inner.Archive(ctx, qid, version)
+}
+
+// Restore implements Registry.
+func (n *NamespacedRegistry) Restore(ctx context.Context, id, version string) error {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return err
+	}
+	return n.inner.Restore(ctx, qid, version)
+}
+
+// SetAlias implements Aliaser (if inner does).
+func (n *NamespacedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return err
+	}
+	return SetAlias(ctx, n.inner, qid, alias, version)
+}
+
+// Aliases implements Aliaser (if inner does).
+func (n *NamespacedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return Aliases(ctx, n.inner, qid)
+}
+
+// History implements Auditor (if inner does).
+func (n *NamespacedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := History(ctx, n.inner, qid)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].ID = id
+	}
+	return entries, nil
+}
+
+// Usage implements UsageReporter (if inner does).
+func (n *NamespacedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	qid, err := n.qualify(ctx, id)
+	if err != nil {
+		return UsageStats{}, err
+	}
+	u, err := Usage(ctx, n.inner, qid)
+	if err != nil {
+		return UsageStats{}, err
+	}
+	u.ID = id
+	return u, nil
+}
+
+// ListUsage implements UsageReporter (if inner does), returning only ids in ctx's namespace.
+func (n *NamespacedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	ns, err := n.namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all, err := ListUsage(ctx, n.inner)
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, u := range all {
+		if id, ok := unqualify(ns, u.ID); ok {
+			u.ID = id
+			out = append(out, u)
+		}
+	}
+	return out, nil
+}
+
+// WatchChanges implements ChangeWatcher (if inner does), reporting only changes in ctx's namespace.
+func (n *NamespacedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	ns, err := n.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	return WatchChanges(ctx, n.inner, func(qid string) {
+		if id, ok := unqualify(ns, qid); ok {
+			fn(id)
+		}
+	})
+}
+
+// StoreBatch implements Batcher (falling back to Store if inner does not).
+func (n *NamespacedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	cps := make([]*core.Prompt, len(prompts))
+	for i, p := range prompts {
+		qid, err := n.qualify(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		cp := *p
+		cp.ID = qid
+		cps[i] = &cp
+	}
+	err := StoreBatch(ctx, n.inner, cps)
+	for i, p := range prompts {
+		if cps[i].Revision != 0 {
+			id := p.ID
+			*p = *cps[i]
+			p.ID = id
+		}
+	}
+	return err
+}
+
+// DeleteBatch implements Batcher (falling back to Delete if inner does not).
+func (n *NamespacedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	qrefs, err := n.qualifyRefs(ctx, refs)
+	if err != nil {
+		return err
+	}
+	return DeleteBatch(ctx, n.inner, qrefs)
+}
+
+// GetMany implements Batcher (falling back to Get if inner does not).
+func (n *NamespacedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	qrefs, err := n.qualifyRefs(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := GetMany(ctx, n.inner, qrefs)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range prompts {
+		if p != nil {
+			p.ID = refs[i].ID
+		}
+	}
+	return prompts, nil
+}
+
+func (n *NamespacedRegistry) qualifyRefs(ctx context.Context, refs []VersionRef) ([]VersionRef, error) {
+	out := make([]VersionRef, len(refs))
+	for i, ref := range refs {
+		qid, err := n.qualify(ctx, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = VersionRef{ID: qid, Version: ref.Version}
+	}
+	return out, nil
+}
+
+// Ensure NamespacedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*NamespacedRegistry)(nil)
+	_ ConditionalStorer    = (*NamespacedRegistry)(nil)
+	_ Auditor              = (*NamespacedRegistry)(nil)
+	_ UsageReporter        = (*NamespacedRegistry)(nil)
+	_ ChangeWatcher        = (*NamespacedRegistry)(nil)
+	_ Aliaser              = (*NamespacedRegistry)(nil)
+	_ Batcher              = (*NamespacedRegistry)(nil)
+	_ ProductionRollbacker = (*NamespacedRegistry)(nil)
+)
@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespacedRegistry(t *testing.T) {
+	inner := NewMemoryRegistry()
+	reg := NewNamespaced(inner)
+	base := context.Background()
+	search := WithNamespace(base, "search")
+	support := WithNamespace(base, "support")
+
+	require.NoError(t, reg.Store(base, &core.Prompt{ID: "p", Version: "1.0.0", Template: "default"}))
+	p := &core.Prompt{ID: "p", Version: "1.0.0", Template: "search"}
+	require.NoError(t, reg.Store(search, p))
+	assert.Equal(t, "p", p.ID)
+	assert.NotZero(t, p.Revision)
+	require.NoError(t, reg.Store(support, &core.Prompt{ID: "p", Version: "1.0.0", Template: "support"}))
+	require.NoError(t, reg.Promote(search, "p", "1.0.0", StageProduction))
+
+	got, err := reg.Get(search, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "p", got.ID)
+	assert.Equal(t, "search", got.Template)
+	got, err = reg.Get(base, "p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "default", got.Template)
+	_, err = reg.GetProduction(support, "p")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound, "promotion is per namespace")
+
+	stored, err := inner.Get(base, "search::p", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "search", stored.Template)
+
+	list, err := reg.List(support, Filter{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "p", list[0].ID)
+	assert.Equal(t, "support", list[0].Template)
+	list, err = reg.List(base, Filter{})
+	require.NoError(t, err)
+	require.Len(t, list, 1, "the default namespace does not see other namespaces")
+	assert.Equal(t, "default", list[0].Template)
+	list, err = reg.List(base, Filter{Namespace: "search", IDs: []string{"p"}})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "search", list[0].Template)
+
+	require.NoError(t, reg.SetAlias(search, "p", "stable", "1.0.0"))
+	aliases, err := reg.Aliases(support, "p")
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+	entries, err := reg.History(search, "p")
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	assert.Equal(t, "p", entries[0].ID)
+
+	batch := []*core.Prompt{{ID: "q", Version: "1.0.0", Template: "q"}}
+	require.NoError(t, reg.StoreBatch(support, batch))
+	assert.Equal(t, "q", batch[0].ID)
+	many, err := reg.GetMany(support, []VersionRef{{ID: "q", Version: "1.0.0"}, {ID: "p", Version: "1.0.0"}})
+	require.NoError(t, err)
+	require.Len(t, many, 2)
+	assert.Equal(t, "q", many[0].ID)
+	assert.Equal(t, "support", many[1].Template)
+	_, err = reg.Get(search, "q", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+	assert.ErrorIs(t, reg.Store(base, &core.Prompt{ID: "search::p", Version: "2.0.0", Template: "x"}), ErrInvalidNamespace)
+	_, err = reg.Get(WithNamespace(base, "bad/ns"), "p", "1.0.0")
+	assert.ErrorIs(t, err, ErrInvalidNamespace)
+}
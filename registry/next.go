@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// StoreNext stores p as the next version of p.ID, for programs that update prompts without
+// tracking versions themselves. p.Version is ignored unless p.ID has no semver versions yet, in
+// which case it is stored as is (or as 1.0.0 if empty).
+//
+// If p has the same content (see core.Prompt.Fingerprint; a signature is ignored) as the latest version (see
+// GetLatest), nothing is stored. Otherwise the highest existing version, archived ones included,
+// is bumped: the minor version if the system or user template, variables, examples, or tools
+// changed, and the patch version if only the name, description, or metadata did. Major versions
+// are never bumped automatically; Store them explicitly.
+//
+// StoreNext sets p.Version (and, if unchanged, p.Revision) to that of the latest version and
+// reports whether it stored a new one. A concurrent StoreNext of the same version fails with
+// core.ErrConflict.
+func StoreNext(ctx context.Context, reg Registry, p *core.Prompt) (bool, error) {
+	if p.ID == "" {
+		return false, fmt.Errorf("registry: StoreNext requires an id")
+	}
+	latest, err := GetLatest(ctx, reg, p.ID)
+	if err != nil && !errors.Is(err, core.ErrPromptNotFound) {
+		return false, err
+	}
+	if latest != nil && unsigned(latest).Fingerprint() == unsigned(p).Fingerprint() {
+		p.Version, p.Revision = latest.Version, latest.Revision
+		return false, nil
+	}
+	infos, err := reg.ListVersions(ctx, p.ID)
+	if err != nil {
+		return false, err
+	}
+	var highest *semver
+	for _, vi := range infos {
+		if v, ok := parseSemver(vi.Version); ok && (highest == nil || v.compare(*highest) > 0) {
+			highest = &v
+		}
+	}
+	switch {
+	case highest == nil && p.Version == "":
+		p.Version = "1.0.0"
+	case highest != nil:
+		p.Version = bump(*highest, latest == nil || behaviorChanged(latest, p))
+	}
+	p.Revision = 0
+	if cs, ok := reg.(ConditionalStorer); ok {
+		err = cs.StoreIfMatch(ctx, p, 0)
+	} else {
+		err = storeNew(ctx, reg, p)
+	}
+	if errors.Is(err, core.ErrConflict) {
+		return false, fmt.Errorf("%w: %s@%s already exists", core.ErrConflict, p.ID, p.Version)
+	}
+	return err == nil, err
+}
+
+// behaviorChanged reports whether a and b differ in what they render or offer the model, as
+// opposed to only their name, description, or metadata.
+func behaviorChanged(a, b *core.Prompt) bool {
+	strip := func(p *core.Prompt) string {
+		return (&core.Prompt{System: p.System, Template: p.Template, Messages: p.Messages,
+			Variables: p.Variables, Examples: p.Examples, Tools: p.Tools, OutputSchema: p.OutputSchema}).Fingerprint()
+	}
+	return strip(a) != strip(b)
+}
+
+// bump returns the version after v: the next minor if minor is set, else the next patch. A
+// pre-release is bumped to its release.
+func bump(v semver, minor bool) string {
+	switch {
+	case v.pre != "":
+	case minor:
+		v.minor, v.patch = v.minor+1, 0
+	default:
+		v.patch++
+	}
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
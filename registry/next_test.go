@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreNext(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemoryRegistry()
+	next := func(p *core.Prompt) (string, bool) {
+		t.Helper()
+		stored, err := StoreNext(ctx, reg, p)
+		require.NoError(t, err)
+		return p.Version, stored
+	}
+	prompt := func() *core.Prompt {
+		return &core.Prompt{ID: "greet", Template: "Hi {{.name}}", Metadata: map[string]interface{}{"team": "cx"}}
+	}
+
+	v, stored := next(prompt())
+	assert.Equal(t, "1.0.0", v)
+	assert.True(t, stored)
+	p := prompt()
+	v, stored = next(p)
+	assert.Equal(t, "1.0.0", v, "unchanged content is not stored again")
+	assert.False(t, stored)
+	assert.Equal(t, int64(1), p.Revision)
+
+	p = prompt()
+	p.Metadata["team"] = "search"
+	p.Description = "Greets the user"
+	v, _ = next(p)
+	assert.Equal(t, "1.0.1", v, "metadata-only changes bump the patch")
+	p = prompt()
+	p.Metadata["team"] = "search"
+	p.Description = "Greets the user"
+	p.Template = "Hello {{.name}}"
+	v, _ = next(p)
+	assert.Equal(t, "1.1.0", v, "template changes bump the minor")
+	p.Tools = []core.Tool{{Name: "lookup"}}
+	v, _ = next(p)
+	assert.Equal(t, "1.2.0", v)
+
+	// Archived versions are not reused; the content is compared with the latest live version.
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "greet", Version: "1.5.0", Template: "old"}))
+	require.NoError(t, reg.Archive(ctx, "greet", "1.5.0"))
+	v, stored = next(p)
+	assert.Equal(t, "1.2.0", v)
+	assert.False(t, stored)
+	p.Name = "Greeting"
+	v, _ = next(p)
+	assert.Equal(t, "1.5.1", v)
+
+	// A first version keeps the caller's version; a pre-release is bumped to its release.
+	v, _ = next(&core.Prompt{ID: "rc", Version: "2.0.0-rc.1", Template: "a"})
+	assert.Equal(t, "2.0.0-rc.1", v)
+	v, _ = next(&core.Prompt{ID: "rc", Template: "b"})
+	assert.Equal(t, "2.0.0", v)
+
+	t.Run("signed", func(t *testing.T) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		signed := NewSigned(reg, WithSigningKey("ci", priv))
+		p := &core.Prompt{ID: "signed", Template: "x"}
+		_, err = StoreNext(ctx, signed, p)
+		require.NoError(t, err)
+		stored, err := StoreNext(ctx, signed, &core.Prompt{ID: "signed", Template: "x"})
+		require.NoError(t, err)
+		assert.False(t, stored, "the signature is not part of the content")
+	})
+
+	_, err := StoreNext(ctx, reg, &core.Prompt{Template: "x"})
+	assert.Error(t, err)
+}
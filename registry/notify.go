@@ -0,0 +1,245 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ChangeWatcher is implemented by backends that can report writes made by other processes
+// (e.g. RedisRegistry via pub/sub). WatchChanges calls fn with the id of every changed prompt
+// until ctx is done or the watch fails; it returns nil when ctx is done.
+type ChangeWatcher interface {
+	WatchChanges(ctx context.Context, fn func(id string)) error
+}
+
+// WatchChanges watches reg's backend for changes via WatchChanges. It returns an error if reg does
+// not implement ChangeWatcher.
+func WatchChanges(ctx context.Context, reg Registry, fn func(id string)) error {
+	w, ok := reg.(ChangeWatcher)
+	if !ok {
+		return fmt.Errorf("registry: %T does not support watching for changes", reg)
+	}
+	return w.WatchChanges(ctx, fn)
+}
+
+// NotifyingRegistry wraps a Registry and calls OnChange callbacks when a prompt id changes, so
+// libraries can hot-reload prompts. Writes made through the NotifyingRegistry notify immediately;
+// run Watch to also be notified of other processes' writes where the backend supports it.
+type NotifyingRegistry struct {
+	inner Registry
+
+	mu     sync.Mutex
+	nextID int
+	subs   map[string]map[int]func(*core.Prompt)
+}
+
+// NewNotifyingRegistry returns inner with change notifications.
+func NewNotifyingRegistry(inner Registry) *NotifyingRegistry {
+	return &NotifyingRegistry{inner: inner, subs: make(map[string]map[int]func(*core.Prompt))}
+}
+
+// OnChange registers fn to be called after every change to id with id's current production
+// version, or nil if it no longer has one (e.g. it was deleted or archived). fn runs in the
+// goroutine that made or observed the change, must not block, and must not modify the prompt.
+// It may be called more than once for the same version. The returned function unregisters fn.
+func (n *NotifyingRegistry) OnChange(id string, fn func(*core.Prompt)) (cancel func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nextID++
+	key := n.nextID
+	if n.subs[id] == nil {
+		n.subs[id] = make(map[int]func(*core.Prompt))
+	}
+	n.subs[id][key] = fn
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subs[id], key)
+		if len(n.subs[id]) == 0 {
+			delete(n.subs, id)
+		}
+	}
+}
+
+// Watch notifies OnChange callbacks of changes reported by the backend until ctx is done. It
+// returns an error immediately if inner does not implement ChangeWatcher.
+func (n *NotifyingRegistry) Watch(ctx context.Context) error {
+	return WatchChanges(ctx, n.inner, func(id string) { n.notify(ctx, id) })
+}
+
+// WatchChanges implements ChangeWatcher (if inner does).
+func (n *NotifyingRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, n.inner, fn)
+}
+
+// notify calls id's callbacks with its current production version. Callbacks are skipped if the
+// production version cannot be read, so a backend outage does not unload prompts.
+func (n *NotifyingRegistry) notify(ctx context.Context, id string) {
+	n.mu.Lock()
+	fns := make([]func(*core.Prompt), 0, len(n.subs[id]))
+	for _, fn := range n.subs[id] {
+		fns = append(fns, fn)
+	}
+	n.mu.Unlock()
+	if len(fns) == 0 {
+		return
+	}
+	p, err := n.inner.GetProduction(context.WithoutCancel(ctx), id)
+	if errors.Is(err, core.ErrPromptNotFound) {
+		p, err = nil, nil
+	}
+	if err != nil {
+		return
+	}
+	for _, fn := range fns {
+		fn(p)
+	}
+}
+
+// Get implements Registry.
+func (n *NotifyingRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	return n.inner.Get(ctx, id, version)
+}
+
+// GetProduction implements Registry.
+func (n *NotifyingRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	return n.inner.GetProduction(ctx, id)
+}
+
+// Store implements Registry and notifies prompt.ID's callbacks.
+func (n *NotifyingRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	if err := n.inner.Store(ctx, prompt); err != nil {
+		return err
+	}
+	n.notify(ctx, prompt.ID)
+	return nil
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does) and notifies prompt.ID's callbacks.
+func (n *NotifyingRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if err := StoreIfMatch(ctx, n.inner, prompt, revision); err != nil {
+		return err
+	}
+	n.notify(ctx, prompt.ID)
+	return nil
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time) and notifies the callbacks of
+// every stored id, also if the batch fails part way.
+func (n *NotifyingRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	err := StoreBatch(ctx, n.inner, prompts)
+	if !errors.Is(err, ErrInvalidBatch) {
+		n.notifyAll(ctx, refIDs(promptRefs(prompts)))
+	}
+	return err
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time) and notifies the callbacks
+// of every affected id, also if the batch fails part way.
+func (n *NotifyingRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	err := DeleteBatch(ctx, n.inner, refs)
+	if !errors.Is(err, ErrInvalidBatch) {
+		n.notifyAll(ctx, refIDs(refs))
+	}
+	return err
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time).
+func (n *NotifyingRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	return GetMany(ctx, n.inner, refs)
+}
+
+func (n *NotifyingRegistry) notifyAll(ctx context.Context, ids []string) {
+	for _, id := range ids {
+		n.notify(ctx, id)
+	}
+}
+
+// List implements Registry.
+func (n *NotifyingRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	return n.inner.List(ctx, filter)
+}
+
+// ListVersions implements Registry.
+func (n *NotifyingRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	return n.inner.ListVersions(ctx, id)
+}
+
+// Promote implements Registry and notifies id's callbacks.
+func (n *NotifyingRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	return n.notifyAfter(ctx, id, n.inner.Promote(ctx, id, version, stage))
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does) and notifies id's callbacks.
+func (n *NotifyingRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	return n.notifyAfter(ctx, id, RollbackProduction(ctx, n.inner, id, from, version))
+}
+
+// Delete implements Registry and notifies id's callbacks.
+func (n *NotifyingRegistry) Delete(ctx context.Context, id, version string) error {
+	return n.notifyAfter(ctx, id, n.inner.Delete(ctx, id, version))
+}
+
+// Tag implements Registry and notifies id's callbacks.
+func (n *NotifyingRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	return n.notifyAfter(ctx, id, n.inner.Tag(ctx, id, version, tags))
+}
+
+// Archive implements Registry and notifies id's callbacks.
+func (n *NotifyingRegistry) Archive(ctx context.Context, id, version string) error {
+	return n.notifyAfter(ctx, id, n.inner.Archive(ctx, id, version))
+}
+
+// Restore implements Registry and notifies id's callbacks.
+func (n *NotifyingRegistry) Restore(ctx context.Context, id, version string) error {
+	return n.notifyAfter(ctx, id, n.inner.Restore(ctx, id, version))
+}
+
+func (n *NotifyingRegistry) notifyAfter(ctx context.Context, id string, err error) error {
+	if err != nil {
+		return err
+	}
+	n.notify(ctx, id)
+	return nil
+}
+
+// SetAlias implements Aliaser (if inner does) and notifies id's callbacks.
+func (n *NotifyingRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	return n.notifyAfter(ctx, id, SetAlias(ctx, n.inner, id, alias, version))
+}
+
+// Aliases implements Aliaser (if inner does).
+func (n *NotifyingRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return Aliases(ctx, n.inner, id)
+}
+
+// History implements Auditor (if inner does).
+func (n *NotifyingRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	return History(ctx, n.inner, id)
+}
+
+// Usage implements UsageReporter (if inner does).
+func (n *NotifyingRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, n.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does).
+func (n *NotifyingRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, n.inner)
+}
+
+// Ensure NotifyingRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*NotifyingRegistry)(nil)
+	_ ConditionalStorer    = (*NotifyingRegistry)(nil)
+	_ Auditor              = (*NotifyingRegistry)(nil)
+	_ UsageReporter        = (*NotifyingRegistry)(nil)
+	_ ChangeWatcher        = (*NotifyingRegistry)(nil)
+	_ Aliaser              = (*NotifyingRegistry)(nil)
+	_ Batcher              = (*NotifyingRegistry)(nil)
+	_ ProductionRollbacker = (*NotifyingRegistry)(nil)
+)
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// watchedRegistry is a MemoryRegistry whose WatchChanges reports ids sent on changes.
+type watchedRegistry struct {
+	*MemoryRegistry
+	changes chan string
+}
+
+func (w *watchedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case id := <-w.changes:
+			fn(id)
+		}
+	}
+}
+
+func TestNotifyingRegistry(t *testing.T) {
+	ctx := context.Background()
+	reg := NewNotifyingRegistry(NewMemoryRegistry())
+	var got []*core.Prompt
+	cancel := reg.OnChange("p", func(p *core.Prompt) { got = append(got, p) })
+	reg.OnChange("other", func(*core.Prompt) { t.Error("unexpected notification for other") })
+
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.Len(t, got, 1)
+	assert.Nil(t, got[0], "no production version yet")
+
+	require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+	require.Len(t, got, 2)
+	assert.Equal(t, "v1", got[1].Template)
+
+	assert.Error(t, reg.Promote(ctx, "p", "9.9.9", StageProduction))
+	assert.Len(t, got, 2, "failed writes do not notify")
+
+	require.NoError(t, reg.Archive(ctx, "p", "1.0.0"))
+	require.Len(t, got, 3)
+	assert.Nil(t, got[2])
+
+	cancel()
+	require.NoError(t, reg.Restore(ctx, "p", "1.0.0"))
+	assert.Len(t, got, 3)
+
+	assert.Error(t, reg.Watch(ctx), "memory registry cannot watch")
+}
+
+func TestNotifyingRegistry_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	backend := &watchedRegistry{MemoryRegistry: NewMemoryRegistry(), changes: make(chan string)}
+	cached := NewCached(backend, time.Hour)
+	reg := NewNotifyingRegistry(cached)
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v1"}))
+	require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+	_, err := cached.GetProduction(ctx, "p")
+	require.NoError(t, err)
+
+	got := make(chan *core.Prompt, 1)
+	reg.OnChange("p", func(p *core.Prompt) { got <- p })
+	go func() { _ = reg.Watch(ctx) }()
+
+	// Another process changes the backend directly; the cache is invalidated by the watch event.
+	require.NoError(t, backend.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "v2"}))
+	backend.changes <- "p"
+	select {
+	case p := <-got:
+		assert.Equal(t, "v2", p.Template)
+	case <-time.After(time.Second):
+		t.Fatal("no notification")
+	}
+}
@@ -0,0 +1,303 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// SortField orders List results (see Filter.SortBy).
+type SortField string
+
+const (
+	// SortByID orders by id, then semantic version (see CompareVersions). It is the default.
+	SortByID SortField = "id"
+	// SortByCreatedAt orders by CreatedAt, then id and version.
+	SortByCreatedAt SortField = "created_at"
+	// SortByUpdatedAt orders by UpdatedAt, then id and version.
+	SortByUpdatedAt SortField = "updated_at"
+)
+
+var (
+	// ErrInvalidSort is returned by List for an unknown Filter.SortBy.
+	ErrInvalidSort = errors.New("registry: invalid sort field")
+	// ErrInvalidCursor is returned by List for a Filter.Cursor that is malformed or was made for
+	// a different sort order.
+	ErrInvalidCursor = errors.New("registry: invalid cursor")
+)
+
+// listKey is the sort position of one version.
+type listKey struct {
+	t           time.Time // CreatedAt or UpdatedAt, per the sort field; zero for SortByID
+	id, version string
+}
+
+// cursor is the encoded form of Filter.Cursor: the position of the last result of a page and the
+// order it was listed in.
+type cursor struct {
+	Sort    SortField `json:"s,omitempty"`
+	Desc    bool      `json:"d,omitempty"`
+	Time    time.Time `json:"t"`
+	ID      string    `json:"i"`
+	Version string    `json:"v"`
+}
+
+// sortField returns the filter's sort field, SortByID if it is empty.
+func (f Filter) sortField() (SortField, error) {
+	switch f.SortBy {
+	case "", SortByID:
+		return SortByID, nil
+	case SortByCreatedAt, SortByUpdatedAt:
+		return f.SortBy, nil
+	}
+	return "", fmt.Errorf("%w %q (want id, created_at, or updated_at)", ErrInvalidSort, f.SortBy)
+}
+
+// keyOf returns the sort position of a version with the given timestamps.
+func (f Filter) keyOf(id, version string, created, updated time.Time) listKey {
+	k := listKey{id: id, version: version}
+	switch f.SortBy {
+	case SortByCreatedAt:
+		k.t = created
+	case SortByUpdatedAt:
+		k.t = updated
+	}
+	return k
+}
+
+// compareKeys compares a and b in the filter's order.
+func (f Filter) compareKeys(a, b listKey) int {
+	c := a.t.Compare(b.t)
+	if c == 0 {
+		c = strings.Compare(a.id, b.id)
+	}
+	if c == 0 {
+		c = CompareVersions(a.version, b.version)
+	}
+	if f.Descending {
+		c = -c
+	}
+	return c
+}
+
+// cursorKey decodes the filter's Cursor; ok is false if there is none.
+func (f Filter) cursorKey() (k listKey, ok bool, err error) {
+	sortBy, err := f.sortField()
+	if err != nil || f.Cursor == "" {
+		return listKey{}, false, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return listKey{}, false, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return listKey{}, false, ErrInvalidCursor
+	}
+	if c.Sort == "" {
+		c.Sort = SortByID
+	}
+	if c.Sort != sortBy || c.Desc != f.Descending {
+		return listKey{}, false, fmt.Errorf("%w: made for sort %s (descending %t)", ErrInvalidCursor, c.Sort, c.Desc)
+	}
+	return listKey{t: c.Time, id: c.ID, version: c.Version}, true, nil
+}
+
+// encodeCursor returns the cursor for continuing after k in the filter's order.
+func (f Filter) encodeCursor(k listKey) string {
+	c := cursor{Desc: f.Descending, Time: k.t, ID: k.id, Version: k.version}
+	if f.SortBy != SortByID {
+		c.Sort = f.SortBy
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// withCursorID returns the filter with its Cursor's id replaced by fn(id), for decorators that
+// rename ids on the way to the backend.
+func (f Filter) withCursorID(fn func(id string) (string, error)) (Filter, error) {
+	k, ok, err := f.cursorKey()
+	if err != nil || !ok {
+		return f, err
+	}
+	if k.id, err = fn(k.id); err != nil {
+		return f, err
+	}
+	f.Cursor = f.encodeCursor(k)
+	return f, nil
+}
+
+// limit returns the filter's Limit, or the default of 1000.
+func (f Filter) limit() int {
+	if f.Limit <= 0 {
+		return 1000
+	}
+	return f.Limit
+}
+
+// NextCursor returns the Cursor that continues after page, the result of List(filter), or "" if
+// page is the last one (it has fewer than Limit prompts). Because the cursor names a position
+// rather than a count, prompts stored or deleted between pages do not shift later pages.
+func NextCursor(filter Filter, page []*core.Prompt) string {
+	if len(page) == 0 || len(page) < filter.limit() {
+		return ""
+	}
+	last := page[len(page)-1]
+	return filter.encodeCursor(filter.keyOf(last.ID, last.Version, last.CreatedAt, last.UpdatedAt))
+}
+
+// ListPage returns one page of reg.List and the cursor for the next page ("" after the last).
+//
+//	for filter.Cursor = ""; ; {
+//		page, next, err := registry.ListPage(ctx, reg, filter)
+//		...
+//		if next == "" {
+//			break
+//		}
+//		filter.Cursor = next
+//	}
+func ListPage(ctx context.Context, reg Registry, filter Filter) ([]*core.Prompt, string, error) {
+	page, err := reg.List(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	return page, NextCursor(filter, page), nil
+}
+
+// eachPage calls fn with successive pages of reg.List(filter), following cursors, until fn returns
+// stop or the pages run out. fn may modify the prompts.
+func eachPage(ctx context.Context, reg Registry, filter Filter, fn func(page []*core.Prompt) (stop bool, err error)) error {
+	for {
+		page, err := reg.List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		next := NextCursor(filter, page)
+		if next != "" && next == filter.Cursor {
+			// A backend that ignores Cursor (e.g. an older server) would repeat this page forever.
+			return fmt.Errorf("registry: %T returned the same page twice; it does not support cursors", reg)
+		}
+		if stop, err := fn(page); err != nil || stop {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		filter.Cursor = next
+	}
+}
+
+// ListVersionsPage returns one page of id's versions (see Registry.ListVersions) and the cursor for
+// the next page. Only the filter's SortBy, Descending, Cursor, Offset, and Limit apply; SortByID
+// orders by semantic version.
+func ListVersionsPage(ctx context.Context, reg Registry, id string, filter Filter) ([]VersionInfo, string, error) {
+	after, hasCursor, err := filter.cursorKey()
+	if err != nil {
+		return nil, "", err
+	}
+	infos, err := reg.ListVersions(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	key := func(vi VersionInfo) listKey { return filter.keyOf(vi.ID, vi.Version, vi.CreatedAt, vi.UpdatedAt) }
+	sort.SliceStable(infos, func(i, j int) bool { return filter.compareKeys(key(infos[i]), key(infos[j])) < 0 })
+	if hasCursor {
+		infos = infos[sort.Search(len(infos), func(i int) bool { return filter.compareKeys(key(infos[i]), after) > 0 }):]
+	}
+	if filter.Offset >= len(infos) {
+		return nil, "", nil
+	}
+	infos = infos[filter.Offset:]
+	if len(infos) <= filter.limit() {
+		return infos, "", nil
+	}
+	infos = infos[:filter.limit()]
+	return infos, filter.encodeCursor(key(infos[len(infos)-1])), nil
+}
+
+// ListEntry is a version that passed a backend's id, stage, tag, and archive checks, with the
+// timestamps List sorts by. Prompt is set if the backend already has the body.
+type ListEntry struct {
+	ID, Version          string
+	CreatedAt, UpdatedAt time.Time
+	Prompt               *core.Prompt
+}
+
+// Page sorts entries in the filter's order, skips those up to its Cursor, and returns the page:
+// bodies not yet set are fetched in order with load (which returns nil for versions that have
+// gone), and the search (MatchesSearch), Offset, and Limit apply to what remains. Backends that
+// cannot sort natively collect entries from their indexes and call Page, so only the bodies of the
+// returned page (or, when searching, of the candidates examined) are read. load may be nil if every
+// entry has its Prompt.
+func (f Filter) Page(entries []ListEntry, load func([]ListEntry) ([]*core.Prompt, error)) ([]*core.Prompt, error) {
+	after, hasCursor, err := f.cursorKey()
+	if err != nil {
+		return nil, err
+	}
+	key := func(e ListEntry) listKey { return f.keyOf(e.ID, e.Version, e.CreatedAt, e.UpdatedAt) }
+	sort.SliceStable(entries, func(i, j int) bool { return f.compareKeys(key(entries[i]), key(entries[j])) < 0 })
+	if hasCursor {
+		entries = entries[sort.Search(len(entries), func(i int) bool { return f.compareKeys(key(entries[i]), after) > 0 }):]
+	}
+	searching := f.Searching()
+	offset := f.Offset
+	if !searching {
+		if offset >= len(entries) {
+			return nil, nil
+		}
+		entries, offset = entries[offset:], 0
+	}
+	limit := f.limit()
+	var out []*core.Prompt
+	for len(entries) > 0 && len(out) < limit {
+		n := limit - len(out)
+		if searching {
+			n = limit
+		}
+		if n > len(entries) {
+			n = len(entries)
+		}
+		batch := entries[:n]
+		entries = entries[n:]
+		var missing []int
+		for i, e := range batch {
+			if e.Prompt == nil {
+				missing = append(missing, i)
+			}
+		}
+		if len(missing) > 0 {
+			refs := make([]ListEntry, len(missing))
+			for j, i := range missing {
+				refs[j] = batch[i]
+			}
+			prompts, err := load(refs)
+			if err != nil {
+				return nil, err
+			}
+			for j, i := range missing {
+				batch[i].Prompt = prompts[j]
+			}
+		}
+		for _, e := range batch {
+			if e.Prompt == nil || (searching && !f.MatchesSearch(e.Prompt)) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			out = append(out, e.Prompt)
+			if len(out) >= limit {
+				break
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListSortAndCursor(t *testing.T) {
+	ctx := context.Background()
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			for i, ref := range []string{"b@1.0.0", "a@2.0.0", "c@1.0.0", "a@10.0.0", "b@1.1.0"} {
+				id, version, _ := strings.Cut(ref, "@")
+				require.NoError(t, reg.Store(ctx, &core.Prompt{ID: id, Version: version, Template: "t",
+					CreatedAt: base.Add(time.Duration(i) * time.Hour), UpdatedAt: base.Add(time.Duration(10-i) * time.Hour)}))
+			}
+			refs := func(list []*core.Prompt) []string {
+				var out []string
+				for _, p := range list {
+					out = append(out, p.ID+"@"+p.Version)
+				}
+				return out
+			}
+			all := func(f Filter) []string {
+				var out []string
+				for {
+					page, next, err := ListPage(ctx, reg, f)
+					require.NoError(t, err)
+					out = append(out, refs(page)...)
+					if next == "" {
+						return out
+					}
+					f.Cursor = next
+				}
+			}
+
+			assert.Equal(t, []string{"a@2.0.0", "a@10.0.0", "b@1.0.0", "b@1.1.0", "c@1.0.0"}, all(Filter{Limit: 2}))
+			assert.Equal(t, []string{"b@1.1.0", "a@10.0.0", "c@1.0.0", "a@2.0.0", "b@1.0.0"}, all(Filter{Limit: 2, SortBy: SortByCreatedAt, Descending: true}))
+			assert.Equal(t, []string{"b@1.1.0", "a@10.0.0", "c@1.0.0", "a@2.0.0", "b@1.0.0"}, all(Filter{Limit: 3, SortBy: SortByUpdatedAt}))
+
+			// A version stored before the cursor's position does not shift the next page.
+			first, next, err := ListPage(ctx, reg, Filter{Limit: 2})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a@2.0.0", "a@10.0.0"}, refs(first))
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "a", Version: "1.0.0", Template: "t"}))
+			second, _, err := ListPage(ctx, reg, Filter{Limit: 2, Cursor: next})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"b@1.0.0", "b@1.1.0"}, refs(second))
+
+			_, err = reg.List(ctx, Filter{Cursor: next, SortBy: SortByCreatedAt})
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+			_, err = reg.List(ctx, Filter{Cursor: "not a cursor"})
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+			_, err = reg.List(ctx, Filter{SortBy: "name"})
+			assert.ErrorIs(t, err, ErrInvalidSort)
+
+			versions, next, err := ListVersionsPage(ctx, reg, "a", Filter{Limit: 2, Descending: true})
+			require.NoError(t, err)
+			require.Len(t, versions, 2)
+			assert.Equal(t, "10.0.0", versions[0].Version)
+			assert.Equal(t, "2.0.0", versions[1].Version)
+			versions, next, err = ListVersionsPage(ctx, reg, "a", Filter{Limit: 2, Descending: true, Cursor: next})
+			require.NoError(t, err)
+			require.Len(t, versions, 1)
+			assert.Equal(t, "1.0.0", versions[0].Version)
+			assert.Empty(t, next)
+		})
+	}
+}
+
+func TestListCursorThroughDecorators(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryRegistry()
+	ns := NewNamespaced(inner)
+	teamCtx := WithNamespace(ctx, "team")
+	for _, id := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, ns.Store(teamCtx, &core.Prompt{ID: id, Version: "1.0.0", Template: "t"}))
+		require.NoError(t, ns.Store(ctx, &core.Prompt{ID: id, Version: "1.0.0", Template: "t"}))
+	}
+	require.NoError(t, ns.Promote(ctx, "b", "1.0.0", StageProduction))
+
+	var got []string
+	f := Filter{Limit: 3}
+	for {
+		page, next, err := ListPage(teamCtx, ns, f)
+		require.NoError(t, err)
+		for _, p := range page {
+			got = append(got, p.ID)
+		}
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, got)
+
+	scoped := NewScoped(ns, Scope{Read: []Stage{StageDev}})
+	page, next, err := ListPage(ctx, scoped, Filter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, []string{"a", "c"}, []string{page[0].ID, page[1].ID})
+	page, _, err = ListPage(ctx, scoped, Filter{Limit: 2, Cursor: next})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "d", page[0].ID)
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/klejdi94/loom/core"
@@ -18,7 +19,8 @@ type PostgresRegistry struct {
 	table string
 }
 
-// NewPostgresRegistry creates a registry. table defaults to "prompts". If createTable is true, the table is created.
+// NewPostgresRegistry creates a registry. table defaults to "prompts". If createTable is true, the table,
+// its audit log table ({table}_audit), and its alias table ({table}_aliases) are created.
 func NewPostgresRegistry(db *sql.DB, table string, createTable bool) (*PostgresRegistry, error) {
 	if table == "" {
 		table = "prompts"
@@ -32,6 +34,9 @@ func NewPostgresRegistry(db *sql.DB, table string, createTable bool) (*PostgresR
 	return r, nil
 }
 
+// pgSearchVector is the full-text document matched by Filter.Query; createTable indexes it.
+const pgSearchVector = `to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(description, '') || ' ' || template)`
+
 func (r *PostgresRegistry) createTable(ctx context.Context) error {
 	// Use placeholder that works with lib/pq ($1, $2) and pgx
 	q := `CREATE TABLE IF NOT EXISTS ` + r.table + ` (
@@ -43,51 +48,260 @@ func (r *PostgresRegistry) createTable(ctx context.Context) error {
 		template TEXT NOT NULL,
 		variables JSONB,
 		examples JSONB,
+		tools JSONB,
 		metadata JSONB,
+		messages JSONB,
+		output_schema JSONB,
 		stage VARCHAR(32) DEFAULT 'dev',
 		tags JSONB,
 		created_at TIMESTAMPTZ,
 		updated_at TIMESTAMPTZ,
+		revision BIGINT NOT NULL DEFAULT 1,
+		archived BOOLEAN NOT NULL DEFAULT FALSE,
 		PRIMARY KEY (id, version)
 	)`
 	if _, err := r.db.ExecContext(ctx, q); err != nil {
 		return err
 	}
-	_, err := r.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_`+r.table+`_id_stage ON `+r.table+`(id, stage)`)
+	// Tables created before tool support lack the tools column.
+	if _, err := r.db.ExecContext(ctx, `ALTER TABLE `+r.table+` ADD COLUMN IF NOT EXISTS tools JSONB`); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `ALTER TABLE `+r.table+` ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 1`); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `ALTER TABLE `+r.table+` ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE`); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `ALTER TABLE `+r.table+` ADD COLUMN IF NOT EXISTS checksum TEXT`); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `ALTER TABLE `+r.table+` ADD COLUMN IF NOT EXISTS messages JSONB`); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `ALTER TABLE `+r.table+` ADD COLUMN IF NOT EXISTS output_schema JSONB`); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_`+r.table+`_id_stage ON `+r.table+`(id, stage)`); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_`+r.table+`_search ON `+r.table+` USING GIN (`+pgSearchVector+`)`); err != nil {
+		return err
+	}
+	q = `CREATE TABLE IF NOT EXISTS ` + r.auditTable() + ` (
+		seq BIGSERIAL PRIMARY KEY,
+		id VARCHAR(255) NOT NULL,
+		version VARCHAR(64) NOT NULL,
+		action VARCHAR(32) NOT NULL,
+		actor VARCHAR(255),
+		stage VARCHAR(32),
+		tags JSONB,
+		revision BIGINT,
+		at TIMESTAMPTZ NOT NULL
+	)`
+	if _, err := r.db.ExecContext(ctx, q); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `ALTER TABLE `+r.auditTable()+` ADD COLUMN IF NOT EXISTS alias VARCHAR(255)`); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_`+r.table+`_audit_id ON `+r.auditTable()+`(id, seq)`); err != nil {
+		return err
+	}
+	q = `CREATE TABLE IF NOT EXISTS ` + r.aliasTable() + ` (
+		id VARCHAR(255) NOT NULL,
+		alias VARCHAR(255) NOT NULL,
+		version VARCHAR(64) NOT NULL,
+		PRIMARY KEY (id, alias)
+	)`
+	_, err := r.db.ExecContext(ctx, q)
 	return err
 }
 
+func (r *PostgresRegistry) auditTable() string {
+	return r.table + "_audit"
+}
+
+func (r *PostgresRegistry) aliasTable() string {
+	return r.table + "_aliases"
+}
+
+// pgExecer is satisfied by *sql.DB and *sql.Tx.
+type pgExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// pgBatchSize bounds the rows of one multi-row statement, keeping it well under Postgres' limit
+// of 65535 parameters.
+const pgBatchSize = 500
+
+// pgValues returns the VALUES rows for n rows of cols parameters each, numbered from $1; row
+// formats one row from its placeholders.
+func pgValues(n, cols int, row func(ph []string) string) string {
+	rows := make([]string, n)
+	ph := make([]string, cols)
+	for i := range rows {
+		for j := range ph {
+			ph[j] = fmt.Sprintf("$%d", i*cols+j+1)
+		}
+		rows[i] = row(ph)
+	}
+	return strings.Join(rows, ", ")
+}
+
+// record inserts e into the audit table.
+func (r *PostgresRegistry) record(ctx context.Context, e AuditEntry) error {
+	return r.recordAll(ctx, r.db, []AuditEntry{e})
+}
+
+// recordAll inserts entries into the audit table using q.
+func (r *PostgresRegistry) recordAll(ctx context.Context, q pgExecer, entries []AuditEntry) error {
+	for start := 0; start < len(entries); start += pgBatchSize {
+		chunk := entries[start:min(start+pgBatchSize, len(entries))]
+		args := make([]interface{}, 0, len(chunk)*9)
+		for _, e := range chunk {
+			tags, _ := json.Marshal(e.Tags)
+			args = append(args, e.ID, e.Version, string(e.Action), e.Actor, string(e.Stage), tags, e.Revision, e.Alias, e.Time)
+		}
+		values := pgValues(len(chunk), 9, func(ph []string) string { return "(" + strings.Join(ph, ", ") + ")" })
+		_, err := q.ExecContext(ctx, `INSERT INTO `+r.auditTable()+` (id, version, action, actor, stage, tags, revision, alias, at)
+			VALUES `+values, args...)
+		if err != nil {
+			return fmt.Errorf("postgres registry audit: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRegistry) recordStore(ctx context.Context, prompt *core.Prompt) error {
+	e := NewAuditEntry(ctx, AuditStore, prompt.ID, prompt.Version)
+	e.Revision = prompt.Revision
+	return r.record(ctx, e)
+}
+
+// History implements Auditor.
+func (r *PostgresRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, version, action, actor, stage, tags, revision, alias, at FROM `+r.auditTable()+` WHERE id = $1 ORDER BY seq`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var action string
+		var actor, stage, alias sql.NullString
+		var tags []byte
+		var revision sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.Version, &action, &actor, &stage, &tags, &revision, &alias, &e.Time); err != nil {
+			return nil, err
+		}
+		e.Action = AuditAction(action)
+		e.Actor = actor.String
+		e.Alias = alias.String
+		e.Stage = Stage(stage.String)
+		e.Revision = revision.Int64
+		_ = json.Unmarshal(tags, &e.Tags)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
 func (r *PostgresRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
 	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
 		return fmt.Errorf("postgres registry: prompt id and version required")
 	}
 	variables, _ := json.Marshal(prompt.Variables)
 	examples, _ := json.Marshal(prompt.Examples)
+	tools, _ := json.Marshal(prompt.Tools)
 	metadata, _ := json.Marshal(prompt.Metadata)
+	messages, _ := json.Marshal(prompt.Messages)
+	outputSchema, _ := json.Marshal(prompt.OutputSchema)
 	now := time.Now()
 	if prompt.CreatedAt.IsZero() {
 		prompt.CreatedAt = now
 	}
 	prompt.UpdatedAt = now
-	q := `INSERT INTO ` + r.table + ` (id, version, name, description, system, template, variables, examples, metadata, stage, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'dev', '[]', $10, $11)
+	q := `INSERT INTO ` + r.table + ` (id, version, name, description, system, template, variables, examples, tools, metadata, stage, tags, created_at, updated_at, revision, checksum, messages, output_schema)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 'dev', '[]', $11, $12, 1, $13, $14, $15)
 		ON CONFLICT (id, version) DO UPDATE SET
 			name = EXCLUDED.name, description = EXCLUDED.description, system = EXCLUDED.system, template = EXCLUDED.template,
-			variables = EXCLUDED.variables, examples = EXCLUDED.examples, metadata = EXCLUDED.metadata,
-			updated_at = EXCLUDED.updated_at`
-	_, err := r.db.ExecContext(ctx, q,
+			variables = EXCLUDED.variables, examples = EXCLUDED.examples, tools = EXCLUDED.tools, metadata = EXCLUDED.metadata,
+			updated_at = EXCLUDED.updated_at, revision = ` + r.table + `.revision + 1, checksum = EXCLUDED.checksum, messages = EXCLUDED.messages, output_schema = EXCLUDED.output_schema
+		RETURNING revision`
+	err := r.db.QueryRowContext(ctx, q,
 		prompt.ID, prompt.Version, prompt.Name, prompt.Description, prompt.System, prompt.Template,
-		variables, examples, metadata, prompt.CreatedAt, prompt.UpdatedAt)
-	return err
+		variables, examples, tools, metadata, prompt.CreatedAt, prompt.UpdatedAt, Checksum(prompt), messages, outputSchema).Scan(&prompt.Revision)
+	if err != nil {
+		return err
+	}
+	return r.recordStore(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer. Revision 0 inserts only if the version does not exist;
+// otherwise the row is updated only while its revision still matches.
+func (r *PostgresRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
+		return fmt.Errorf("postgres registry: prompt id and version required")
+	}
+	variables, _ := json.Marshal(prompt.Variables)
+	examples, _ := json.Marshal(prompt.Examples)
+	tools, _ := json.Marshal(prompt.Tools)
+	metadata, _ := json.Marshal(prompt.Metadata)
+	messages, _ := json.Marshal(prompt.Messages)
+	outputSchema, _ := json.Marshal(prompt.OutputSchema)
+	now := time.Now()
+	if prompt.CreatedAt.IsZero() {
+		prompt.CreatedAt = now
+	}
+	var row *sql.Row
+	if revision == 0 {
+		q := `INSERT INTO ` + r.table + ` (id, version, name, description, system, template, variables, examples, tools, metadata, stage, tags, created_at, updated_at, revision, checksum, messages, output_schema)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 'dev', '[]', $11, $12, 1, $13, $14, $15)
+			ON CONFLICT (id, version) DO NOTHING
+			RETURNING revision`
+		row = r.db.QueryRowContext(ctx, q,
+			prompt.ID, prompt.Version, prompt.Name, prompt.Description, prompt.System, prompt.Template,
+			variables, examples, tools, metadata, prompt.CreatedAt, now, Checksum(prompt), messages, outputSchema)
+	} else {
+		q := `UPDATE ` + r.table + ` SET
+				name = $3, description = $4, system = $5, template = $6,
+				variables = $7, examples = $8, tools = $9, metadata = $10,
+				updated_at = $11, revision = revision + 1, checksum = $13, messages = $14, output_schema = $15
+			WHERE id = $1 AND version = $2 AND revision = $12
+			RETURNING revision`
+		row = r.db.QueryRowContext(ctx, q,
+			prompt.ID, prompt.Version, prompt.Name, prompt.Description, prompt.System, prompt.Template,
+			variables, examples, tools, metadata, now, revision, Checksum(prompt), messages, outputSchema)
+	}
+	var rev int64
+	if err := row.Scan(&rev); err != nil {
+		if err == sql.ErrNoRows {
+			return core.ErrConflict
+		}
+		return err
+	}
+	prompt.UpdatedAt = now
+	prompt.Revision = rev
+	return r.recordStore(ctx, prompt)
 }
 
 func (r *PostgresRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
-	q := `SELECT id, version, name, description, system, template, variables, examples, metadata, created_at, updated_at FROM ` + r.table + ` WHERE id = $1 AND version = $2`
+	if alias, ok := ParseAlias(version); ok {
+		var target string
+		err := r.db.QueryRowContext(ctx, `SELECT version FROM `+r.aliasTable()+` WHERE id = $1 AND alias = $2`, id, alias).Scan(&target)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		return GetByAlias(ctx, r, id, alias, target)
+	}
+	q := `SELECT id, version, name, description, system, template, variables, examples, tools, metadata, messages, output_schema, created_at, updated_at, revision FROM ` + r.table + ` WHERE id = $1 AND version = $2 AND NOT archived`
 	var p core.Prompt
-	var variables, examples, metadata []byte
+	var variables, examples, tools, metadata, messages, outputSchema []byte
 	err := r.db.QueryRowContext(ctx, q, id, version).Scan(
 		&p.ID, &p.Version, &p.Name, &p.Description, &p.System, &p.Template,
-		&variables, &examples, &metadata, &p.CreatedAt, &p.UpdatedAt)
+		&variables, &examples, &tools, &metadata, &messages, &outputSchema, &p.CreatedAt, &p.UpdatedAt, &p.Revision)
 	if err == sql.ErrNoRows {
 		return nil, core.ErrPromptNotFound
 	}
@@ -96,17 +310,20 @@ func (r *PostgresRegistry) Get(ctx context.Context, id, version string) (*core.P
 	}
 	_ = json.Unmarshal(variables, &p.Variables)
 	_ = json.Unmarshal(examples, &p.Examples)
+	_ = json.Unmarshal(tools, &p.Tools)
+	_ = json.Unmarshal(messages, &p.Messages)
+	_ = json.Unmarshal(outputSchema, &p.OutputSchema)
 	_ = json.Unmarshal(metadata, &p.Metadata)
 	return p.Copy(), nil
 }
 
 func (r *PostgresRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
-	q := `SELECT id, version, name, description, system, template, variables, examples, metadata, created_at, updated_at FROM ` + r.table + ` WHERE id = $1 AND stage = 'production' LIMIT 1`
+	q := `SELECT id, version, name, description, system, template, variables, examples, tools, metadata, messages, output_schema, created_at, updated_at, revision FROM ` + r.table + ` WHERE id = $1 AND stage = 'production' AND NOT archived LIMIT 1`
 	var p core.Prompt
-	var variables, examples, metadata []byte
+	var variables, examples, tools, metadata, messages, outputSchema []byte
 	err := r.db.QueryRowContext(ctx, q, id).Scan(
 		&p.ID, &p.Version, &p.Name, &p.Description, &p.System, &p.Template,
-		&variables, &examples, &metadata, &p.CreatedAt, &p.UpdatedAt)
+		&variables, &examples, &tools, &metadata, &messages, &outputSchema, &p.CreatedAt, &p.UpdatedAt, &p.Revision)
 	if err == sql.ErrNoRows {
 		return nil, core.ErrPromptNotFound
 	}
@@ -115,16 +332,25 @@ func (r *PostgresRegistry) GetProduction(ctx context.Context, id string) (*core.
 	}
 	_ = json.Unmarshal(variables, &p.Variables)
 	_ = json.Unmarshal(examples, &p.Examples)
+	_ = json.Unmarshal(tools, &p.Tools)
+	_ = json.Unmarshal(messages, &p.Messages)
+	_ = json.Unmarshal(outputSchema, &p.OutputSchema)
 	_ = json.Unmarshal(metadata, &p.Metadata)
 	return p.Copy(), nil
 }
 
+// List returns prompts matching the filter. Sorting and Cursor use a keyset query over the sort
+// columns; versions are ordered as text rather than by semantic version.
 func (r *PostgresRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
-	limit := filter.Limit
-	if limit <= 0 {
-		limit = 1000
+	sortBy, err := filter.sortField()
+	if err != nil {
+		return nil, err
+	}
+	after, hasCursor, err := filter.cursorKey()
+	if err != nil {
+		return nil, err
 	}
-	q := `SELECT id, version, name, description, system, template, variables, examples, metadata, tags, created_at, updated_at FROM ` + r.table + ` WHERE 1=1`
+	q := `SELECT id, version, name, description, system, template, variables, examples, tools, metadata, messages, output_schema, tags, created_at, updated_at, revision FROM ` + r.table + ` WHERE 1=1`
 	args := []interface{}{}
 	argNum := 1
 	if len(filter.IDs) > 0 {
@@ -132,13 +358,53 @@ func (r *PostgresRegistry) List(ctx context.Context, filter Filter) ([]*core.Pro
 		args = append(args, pq.Array(filter.IDs))
 		argNum++
 	}
+	if !filter.IncludeArchived {
+		q += ` AND NOT archived`
+	}
 	if filter.Stage != "" {
 		q += ` AND stage = $` + fmt.Sprint(argNum)
 		args = append(args, string(filter.Stage))
 		argNum++
 	}
-	q += ` ORDER BY id, version OFFSET $` + fmt.Sprint(argNum) + ` LIMIT $` + fmt.Sprint(argNum+1)
-	args = append(args, filter.Offset, limit)
+	if len(filter.Tags) > 0 {
+		tags, _ := json.Marshal(filter.Tags)
+		q += ` AND tags @> $` + fmt.Sprint(argNum) + `::jsonb`
+		args = append(args, string(tags))
+		argNum++
+	}
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		q += ` AND ` + pgSearchVector + ` @@ plainto_tsquery('simple', $` + fmt.Sprint(argNum) + `)`
+		args = append(args, query)
+		argNum++
+	}
+	for k, v := range filter.Metadata {
+		q += ` AND metadata->>$` + fmt.Sprint(argNum) + ` = $` + fmt.Sprint(argNum+1)
+		args = append(args, k, v)
+		argNum += 2
+	}
+	cols := []string{"id", "version"}
+	if sortBy != SortByID {
+		cols = []string{string(sortBy), "id", "version"}
+	}
+	dir, cmp := "", ">"
+	if filter.Descending {
+		dir, cmp = " DESC", "<"
+	}
+	if hasCursor {
+		vals := []interface{}{after.id, after.version}
+		if sortBy != SortByID {
+			vals = append([]interface{}{after.t}, vals...)
+		}
+		params := make([]string, len(vals))
+		for i := range vals {
+			params[i] = `$` + fmt.Sprint(argNum)
+			argNum++
+		}
+		q += ` AND (` + strings.Join(cols, ", ") + `) ` + cmp + ` (` + strings.Join(params, ", ") + `)`
+		args = append(args, vals...)
+	}
+	q += ` ORDER BY ` + strings.Join(cols, dir+", ") + dir + ` OFFSET $` + fmt.Sprint(argNum) + ` LIMIT $` + fmt.Sprint(argNum+1)
+	args = append(args, filter.Offset, filter.limit())
 	rows, err := r.db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
@@ -147,27 +413,23 @@ func (r *PostgresRegistry) List(ctx context.Context, filter Filter) ([]*core.Pro
 	var out []*core.Prompt
 	for rows.Next() {
 		var p core.Prompt
-		var variables, examples, metadata, tagsRaw []byte
-		if err := rows.Scan(&p.ID, &p.Version, &p.Name, &p.Description, &p.System, &p.Template, &variables, &examples, &metadata, &tagsRaw, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		var variables, examples, tools, metadata, messages, outputSchema, tagsRaw []byte // tags are filtered in SQL
+		if err := rows.Scan(&p.ID, &p.Version, &p.Name, &p.Description, &p.System, &p.Template, &variables, &examples, &tools, &metadata, &messages, &outputSchema, &tagsRaw, &p.CreatedAt, &p.UpdatedAt, &p.Revision); err != nil {
 			return nil, err
 		}
 		_ = json.Unmarshal(variables, &p.Variables)
 		_ = json.Unmarshal(examples, &p.Examples)
+		_ = json.Unmarshal(tools, &p.Tools)
+		_ = json.Unmarshal(messages, &p.Messages)
+		_ = json.Unmarshal(outputSchema, &p.OutputSchema)
 		_ = json.Unmarshal(metadata, &p.Metadata)
-		if len(filter.Tags) > 0 {
-			var tags []string
-			_ = json.Unmarshal(tagsRaw, &tags)
-			if !hasAll(tags, filter.Tags) {
-				continue
-			}
-		}
 		out = append(out, p.Copy())
 	}
 	return out, nil
 }
 
 func (r *PostgresRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
-	q := `SELECT id, version, stage, tags, created_at, updated_at FROM ` + r.table + ` WHERE id = $1 ORDER BY version`
+	q := `SELECT id, version, stage, tags, created_at, updated_at, archived, COALESCE(checksum, '') FROM ` + r.table + ` WHERE id = $1 ORDER BY version`
 	rows, err := r.db.QueryContext(ctx, q, id)
 	if err != nil {
 		return nil, err
@@ -178,14 +440,16 @@ func (r *PostgresRegistry) ListVersions(ctx context.Context, id string) ([]Versi
 		var vi VersionInfo
 		var stage string
 		var tags []byte
-		if err := rows.Scan(&vi.ID, &vi.Version, &stage, &tags, &vi.CreatedAt, &vi.UpdatedAt); err != nil {
+		if err := rows.Scan(&vi.ID, &vi.Version, &stage, &tags, &vi.CreatedAt, &vi.UpdatedAt, &vi.Archived, &vi.Checksum); err != nil {
 			return nil, err
 		}
 		vi.Stage = Stage(stage)
 		_ = json.Unmarshal(tags, &vi.Tags)
 		infos = append(infos, vi)
 	}
-	return infos, nil
+	// ORDER BY version is lexical ("1.10.0" < "1.2.0"); re-sort by semver.
+	sortVersionInfos(infos)
+	return infos, rows.Err()
 }
 
 func (r *PostgresRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
@@ -193,8 +457,65 @@ func (r *PostgresRegistry) Promote(ctx context.Context, id, version string, stag
 	if stage == StageProduction {
 		_, _ = r.db.ExecContext(ctx, `UPDATE `+r.table+` SET stage = 'dev' WHERE id = $1 AND stage = 'production'`, id)
 	}
-	_, err := r.db.ExecContext(ctx, `UPDATE `+r.table+` SET stage = $1 WHERE id = $2 AND version = $3`, string(stage), id, version)
-	return err
+	res, err := r.db.ExecContext(ctx, `UPDATE `+r.table+` SET stage = $1 WHERE id = $2 AND version = $3`, string(stage), id, version)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	e := NewAuditEntry(ctx, AuditPromote, id, version)
+	e.Stage = stage
+	return r.record(ctx, e)
+}
+
+// RollbackProduction implements ProductionRollbacker in one transaction that locks id's rows, so a
+// concurrent Promote waits for it and a promotion committed first is seen and reported as a conflict.
+func (r *PostgresRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `SELECT version, stage FROM `+r.table+` WHERE id = $1 FOR UPDATE`, id)
+	if err != nil {
+		return err
+	}
+	var current string
+	found := false
+	for rows.Next() {
+		var v, stage string
+		if err := rows.Scan(&v, &stage); err != nil {
+			rows.Close()
+			return err
+		}
+		if stage == string(StageProduction) {
+			current = v
+		}
+		found = found || v == version
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !found {
+		return core.ErrPromptNotFound
+	}
+	if current != from {
+		return fmt.Errorf("%w: %s production is %q, not %q", core.ErrConflict, id, current, from)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE `+r.table+` SET stage = 'dev' WHERE id = $1 AND stage = 'production'`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE `+r.table+` SET stage = 'production' WHERE id = $1 AND version = $2`, id, version); err != nil {
+		return err
+	}
+	e := NewAuditEntry(ctx, AuditRollback, id, version)
+	e.Stage = StageProduction
+	if err := r.recordAll(ctx, tx, []AuditEntry{e}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *PostgresRegistry) Delete(ctx context.Context, id, version string) error {
@@ -206,11 +527,262 @@ func (r *PostgresRegistry) Delete(ctx context.Context, id, version string) error
 	if n == 0 {
 		return core.ErrPromptNotFound
 	}
-	return nil
+	return r.record(ctx, NewAuditEntry(ctx, AuditDelete, id, version))
 }
 
 func (r *PostgresRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
 	data, _ := json.Marshal(tags)
-	_, err := r.db.ExecContext(ctx, `UPDATE `+r.table+` SET tags = $1 WHERE id = $2 AND version = $3`, data, id, version)
-	return err
+	res, err := r.db.ExecContext(ctx, `UPDATE `+r.table+` SET tags = $1 WHERE id = $2 AND version = $3`, data, id, version)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	e := NewAuditEntry(ctx, AuditTag, id, version)
+	e.Tags = append([]string(nil), tags...)
+	return r.record(ctx, e)
+}
+
+// SetAlias implements Aliaser.
+func (r *PostgresRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if err := ValidateAlias(alias); err != nil {
+		return err
+	}
+	var err error
+	if version == "" {
+		_, err = r.db.ExecContext(ctx, `DELETE FROM `+r.aliasTable()+` WHERE id = $1 AND alias = $2`, id, alias)
+	} else {
+		var res sql.Result
+		res, err = r.db.ExecContext(ctx, `INSERT INTO `+r.aliasTable()+` (id, alias, version)
+			SELECT id, $2, version FROM `+r.table+` WHERE id = $1 AND version = $3
+			ON CONFLICT (id, alias) DO UPDATE SET version = EXCLUDED.version`, id, alias, version)
+		if err == nil {
+			if n, _ := res.RowsAffected(); n == 0 {
+				return core.ErrPromptNotFound
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	e := NewAuditEntry(ctx, AuditAlias, id, version)
+	e.Alias = alias
+	return r.record(ctx, e)
+}
+
+// Aliases implements Aliaser.
+func (r *PostgresRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT alias, version FROM `+r.aliasTable()+` WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string]string)
+	for rows.Next() {
+		var alias, version string
+		if err := rows.Scan(&alias, &version); err != nil {
+			return nil, err
+		}
+		out[alias] = version
+	}
+	return out, rows.Err()
+}
+
+// StoreBatch implements Batcher with multi-row upserts and audit inserts in one transaction.
+func (r *PostgresRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	if len(prompts) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	revisions := make(map[VersionRef]int64, len(prompts))
+	for start := 0; start < len(prompts); start += pgBatchSize {
+		chunk := prompts[start:min(start+pgBatchSize, len(prompts))]
+		args := make([]interface{}, 0, len(chunk)*15)
+		for _, p := range chunk {
+			variables, _ := json.Marshal(p.Variables)
+			examples, _ := json.Marshal(p.Examples)
+			tools, _ := json.Marshal(p.Tools)
+			metadata, _ := json.Marshal(p.Metadata)
+			messages, _ := json.Marshal(p.Messages)
+			outputSchema, _ := json.Marshal(p.OutputSchema)
+			if p.CreatedAt.IsZero() {
+				p.CreatedAt = now
+			}
+			p.UpdatedAt = now
+			args = append(args, p.ID, p.Version, p.Name, p.Description, p.System, p.Template,
+				variables, examples, tools, metadata, p.CreatedAt, p.UpdatedAt, Checksum(p), messages, outputSchema)
+		}
+		values := pgValues(len(chunk), 15, func(ph []string) string {
+			return "(" + strings.Join(ph[:10], ", ") + ", 'dev', '[]', " + strings.Join(ph[10:12], ", ") + ", 1, " + strings.Join(ph[12:], ", ") + ")"
+		})
+		rows, err := tx.QueryContext(ctx, `INSERT INTO `+r.table+` (id, version, name, description, system, template, variables, examples, tools, metadata, stage, tags, created_at, updated_at, revision, checksum, messages, output_schema)
+			VALUES `+values+`
+			ON CONFLICT (id, version) DO UPDATE SET
+				name = EXCLUDED.name, description = EXCLUDED.description, system = EXCLUDED.system, template = EXCLUDED.template,
+				variables = EXCLUDED.variables, examples = EXCLUDED.examples, tools = EXCLUDED.tools, metadata = EXCLUDED.metadata,
+				updated_at = EXCLUDED.updated_at, revision = `+r.table+`.revision + 1, checksum = EXCLUDED.checksum, messages = EXCLUDED.messages, output_schema = EXCLUDED.output_schema
+			RETURNING id, version, revision`, args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var ref VersionRef
+			var rev int64
+			if err := rows.Scan(&ref.ID, &ref.Version, &rev); err != nil {
+				rows.Close()
+				return err
+			}
+			revisions[ref] = rev
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	entries := make([]AuditEntry, len(prompts))
+	for i, p := range prompts {
+		entries[i] = NewAuditEntry(ctx, AuditStore, p.ID, p.Version)
+		entries[i].Revision = revisions[VersionRef{ID: p.ID, Version: p.Version}]
+	}
+	if err := r.recordAll(ctx, tx, entries); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for i, p := range prompts {
+		p.Revision = entries[i].Revision
+	}
+	return nil
+}
+
+// DeleteBatch implements Batcher with a single DELETE in a transaction that is rolled back if one
+// of the versions does not exist.
+func (r *PostgresRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	ids, versions := refArrays(refs)
+	rows, err := tx.QueryContext(ctx, `DELETE FROM `+r.table+` AS t USING unnest($1::varchar[], $2::varchar[]) AS d(id, version)
+		WHERE t.id = d.id AND t.version = d.version RETURNING t.id, t.version`, pq.Array(ids), pq.Array(versions))
+	if err != nil {
+		return err
+	}
+	deleted := make(map[VersionRef]bool, len(refs))
+	for rows.Next() {
+		var ref VersionRef
+		if err := rows.Scan(&ref.ID, &ref.Version); err != nil {
+			rows.Close()
+			return err
+		}
+		deleted[ref] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	entries := make([]AuditEntry, len(refs))
+	for i, ref := range refs {
+		if !deleted[ref] {
+			return fmt.Errorf("%w: %s@%s", core.ErrPromptNotFound, ref.ID, ref.Version)
+		}
+		entries[i] = NewAuditEntry(ctx, AuditDelete, ref.ID, ref.Version)
+	}
+	if err := r.recordAll(ctx, tx, entries); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetMany implements Batcher with a single query.
+func (r *PostgresRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	out := make([]*core.Prompt, len(refs))
+	if len(refs) == 0 {
+		return out, nil
+	}
+	ids, versions := refArrays(refs)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, version, name, description, system, template, variables, examples, tools, metadata, messages, output_schema, created_at, updated_at, revision FROM `+r.table+`
+		WHERE (id, version) IN (SELECT * FROM unnest($1::varchar[], $2::varchar[])) AND NOT archived`, pq.Array(ids), pq.Array(versions))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := make(map[VersionRef]*core.Prompt, len(refs))
+	for rows.Next() {
+		var p core.Prompt
+		var variables, examples, tools, metadata, messages, outputSchema []byte
+		if err := rows.Scan(&p.ID, &p.Version, &p.Name, &p.Description, &p.System, &p.Template,
+			&variables, &examples, &tools, &metadata, &messages, &outputSchema, &p.CreatedAt, &p.UpdatedAt, &p.Revision); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal(variables, &p.Variables)
+		_ = json.Unmarshal(examples, &p.Examples)
+		_ = json.Unmarshal(tools, &p.Tools)
+		_ = json.Unmarshal(messages, &p.Messages)
+		_ = json.Unmarshal(outputSchema, &p.OutputSchema)
+		_ = json.Unmarshal(metadata, &p.Metadata)
+		found[VersionRef{ID: p.ID, Version: p.Version}] = &p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, ref := range refs {
+		if p, ok := found[ref]; ok {
+			out[i] = p.Copy()
+		}
+	}
+	return out, nil
+}
+
+// refArrays splits refs into parallel id and version arrays for unnest.
+func refArrays(refs []VersionRef) (ids, versions []string) {
+	ids = make([]string, len(refs))
+	versions = make([]string, len(refs))
+	for i, ref := range refs {
+		ids[i], versions[i] = ref.ID, ref.Version
+	}
+	return ids, versions
+}
+
+// Archive hides a prompt version from Get, GetProduction, and List until it is restored.
+func (r *PostgresRegistry) Archive(ctx context.Context, id, version string) error {
+	return r.setArchived(ctx, id, version, true)
+}
+
+// Restore makes an archived prompt version visible again.
+func (r *PostgresRegistry) Restore(ctx context.Context, id, version string) error {
+	return r.setArchived(ctx, id, version, false)
+}
+
+func (r *PostgresRegistry) setArchived(ctx context.Context, id, version string, archived bool) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE `+r.table+` SET archived = $1 WHERE id = $2 AND version = $3`, archived, id, version)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return core.ErrPromptNotFound
+	}
+	action := AuditArchive
+	if !archived {
+		action = AuditRestore
+	}
+	return r.record(ctx, NewAuditEntry(ctx, action, id, version))
 }
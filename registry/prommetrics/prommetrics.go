@@ -0,0 +1,75 @@
+// Package prommetrics exports registry operation metrics (see registry.NewInstrumented) to
+// Prometheus as one histogram, loom_registry_operation_duration_seconds, labelled by backend,
+// op, and outcome (ok, miss, conflict, error):
+//
+//	inst, err := prommetrics.New(prometheus.DefaultRegisterer)
+//	reg = registry.NewInstrumented(pg, "postgres", inst)
+//	http.Handle("/metrics", promhttp.Handler())
+//
+// For example, the 99th percentile latency of production reads from Postgres is
+//
+//	histogram_quantile(0.99, rate(loom_registry_operation_duration_seconds_bucket{backend="postgres",op="get_production"}[5m]))
+package prommetrics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/klejdi94/loom/registry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Instrumenter is a registry.Instrumenter that records into a Prometheus histogram.
+type Instrumenter struct {
+	duration *prometheus.HistogramVec
+}
+
+// Option configures New.
+type Option func(*prometheus.HistogramOpts)
+
+// WithBuckets sets the histogram buckets in seconds. The default, prometheus.DefBuckets, spans
+// 5ms to 10s.
+func WithBuckets(buckets ...float64) Option {
+	return func(o *prometheus.HistogramOpts) { o.Buckets = buckets }
+}
+
+// WithConstLabels adds labels with fixed values to every series, e.g. the service name.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *prometheus.HistogramOpts) { o.ConstLabels = labels }
+}
+
+// New registers the histogram with reg and returns an Instrumenter recording into it. If an
+// identical histogram is already registered (e.g. by a second registry in the same process), it
+// is shared.
+func New(reg prometheus.Registerer, opts ...Option) (*Instrumenter, error) {
+	ho := prometheus.HistogramOpts{
+		Namespace: "loom",
+		Subsystem: "registry",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of prompt registry operations by backend, operation, and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}
+	for _, o := range opts {
+		o(&ho)
+	}
+	h := prometheus.NewHistogramVec(ho, []string{"backend", "op", "outcome"})
+	if err := reg.Register(h); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return nil, err
+		}
+		existing, ok := are.ExistingCollector.(*prometheus.HistogramVec)
+		if !ok {
+			return nil, err
+		}
+		h = existing
+	}
+	return &Instrumenter{duration: h}, nil
+}
+
+// Observe implements registry.Instrumenter.
+func (i *Instrumenter) Observe(_ context.Context, o registry.Observation) {
+	i.duration.WithLabelValues(o.Backend, string(o.Op), string(o.Outcome)).Observe(o.Duration.Seconds())
+}
+
+var _ registry.Instrumenter = (*Instrumenter)(nil)
@@ -0,0 +1,58 @@
+package prommetrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleCount returns the number of observations in the series with labels, or -1 if there is none.
+func sampleCount(t *testing.T, g prometheus.Gatherer, labels map[string]string) int {
+	t.Helper()
+	families, err := g.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			match := true
+			for _, lp := range m.GetLabel() {
+				if want, ok := labels[lp.GetName()]; ok && want != lp.GetValue() {
+					match = false
+				}
+			}
+			if match {
+				return int(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return -1
+}
+
+func TestInstrumenter(t *testing.T) {
+	ctx := context.Background()
+	promReg := prometheus.NewRegistry()
+	inst, err := New(promReg, WithBuckets(0.001, 0.1))
+	require.NoError(t, err)
+	again, err := New(promReg, WithBuckets(0.001, 0.1))
+	require.NoError(t, err, "a second instrumenter shares the histogram")
+
+	reg := registry.NewInstrumented(registry.NewMemoryRegistry(), "memory", inst)
+	other := registry.NewInstrumented(registry.NewMemoryRegistry(), "other", again)
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "greet", Version: "1.0.0", Template: "Hi"}))
+	for _, v := range []string{"1.0.0", "1.0.0", "2.0.0"} {
+		_, _ = reg.Get(ctx, "greet", v)
+	}
+	_, err = other.GetProduction(ctx, "greet")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+	assert.Equal(t, 4, testutil.CollectAndCount(promReg, "loom_registry_operation_duration_seconds"))
+	assert.Equal(t, 1, sampleCount(t, promReg, map[string]string{"backend": "memory", "op": "store", "outcome": "ok"}))
+	assert.Equal(t, 2, sampleCount(t, promReg, map[string]string{"backend": "memory", "op": "get", "outcome": "ok"}))
+	assert.Equal(t, 1, sampleCount(t, promReg, map[string]string{"backend": "memory", "op": "get", "outcome": "miss"}))
+	assert.Equal(t, 1, sampleCount(t, promReg, map[string]string{"backend": "other", "op": "get_production", "outcome": "miss"}))
+}
@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// PrunePolicy selects the versions Prune deletes. A version is deleted only if it is outside the
+// KeepLast newest versions of its id and older than OlderThan (each ignored if zero; at least one
+// must be set), is not in one of SkipStages, is not the production version, and no alias points
+// at it.
+type PrunePolicy struct {
+	// KeepLast keeps the newest KeepLast versions of each id by semver, archived ones included.
+	KeepLast int
+	// OlderThan keeps versions updated more recently than this. Versions without a recorded
+	// update time are kept when it is set.
+	OlderThan time.Duration
+	// SkipStages keeps versions in these stages, e.g. StageStaging.
+	SkipStages []Stage
+	// IDs limits pruning to these ids; every id if empty.
+	IDs []string
+	// DryRun reports the versions that would be deleted without deleting them.
+	DryRun bool
+}
+
+// Prune deletes the stale versions of every id (or of policy.IDs) selected by policy, one
+// DeleteBatch per id, and returns them in id and version order. With DryRun it only returns them.
+// Deleted versions are gone for good (see Registry.Delete); Export first to keep a copy.
+func Prune(ctx context.Context, reg Registry, policy PrunePolicy) ([]VersionInfo, error) {
+	if policy.KeepLast <= 0 && policy.OlderThan <= 0 {
+		return nil, fmt.Errorf("registry: prune policy needs KeepLast or OlderThan")
+	}
+	ids := policy.IDs
+	if len(ids) == 0 {
+		seen := make(map[string]bool)
+		err := eachPage(ctx, reg, Filter{Limit: 1000, IncludeArchived: true}, func(page []*core.Prompt) (bool, error) {
+			for _, p := range page {
+				if !seen[p.ID] {
+					seen[p.ID] = true
+					ids = append(ids, p.ID)
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(ids)
+	}
+	skip := make(map[Stage]bool, len(policy.SkipStages)+1)
+	for _, st := range policy.SkipStages {
+		skip[st] = true
+	}
+	skip[StageProduction] = true
+	cutoff := time.Now().Add(-policy.OlderThan)
+
+	var pruned []VersionInfo
+	for _, id := range ids {
+		infos, err := reg.ListVersions(ctx, id)
+		if err != nil {
+			return pruned, err
+		}
+		aliased := make(map[string]bool)
+		if _, ok := reg.(Aliaser); ok {
+			aliases, err := Aliases(ctx, reg, id)
+			if err != nil {
+				return pruned, err
+			}
+			for _, v := range aliases {
+				aliased[v] = true
+			}
+		}
+		var refs []VersionRef
+		for i, vi := range infos {
+			if policy.KeepLast > 0 && i >= len(infos)-policy.KeepLast {
+				break
+			}
+			if skip[vi.Stage] || aliased[vi.Version] || (policy.OlderThan > 0 && !updatedBefore(vi, cutoff)) {
+				continue
+			}
+			refs = append(refs, VersionRef{ID: id, Version: vi.Version})
+			pruned = append(pruned, vi)
+		}
+		if len(refs) == 0 || policy.DryRun {
+			continue
+		}
+		if err := DeleteBatch(ctx, reg, refs); err != nil {
+			return pruned[:len(pruned)-len(refs)], fmt.Errorf("registry: prune %s: %w", id, err)
+		}
+	}
+	return pruned, nil
+}
+
+// updatedBefore reports whether vi was last updated (or created, if the backend does not record
+// updates) before t; false if neither time is known.
+func updatedBefore(vi VersionInfo, t time.Time) bool {
+	at := vi.UpdatedAt
+	if at.IsZero() {
+		at = vi.CreatedAt
+	}
+	return !at.IsZero() && at.Before(t)
+}
@@ -0,0 +1,59 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemoryRegistry()
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	for i, v := range []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0", "1.4.0", "2.0.0"} {
+		at := old
+		if i >= 4 {
+			at = time.Now()
+		}
+		require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "greet", Version: v, Template: "Hi", CreatedAt: at, UpdatedAt: at}))
+	}
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "other", Version: "1.0.0", Template: "x", CreatedAt: old, UpdatedAt: old}))
+	require.NoError(t, reg.Promote(ctx, "greet", "1.0.0", StageProduction))
+	require.NoError(t, reg.Promote(ctx, "greet", "1.1.0", StageStaging))
+	require.NoError(t, reg.SetAlias(ctx, "greet", "stable", "1.2.0"))
+
+	versions := func(infos []VersionInfo) []string {
+		var out []string
+		for _, vi := range infos {
+			out = append(out, vi.ID+"@"+vi.Version)
+		}
+		return out
+	}
+
+	_, err := Prune(ctx, reg, PrunePolicy{})
+	assert.Error(t, err, "an empty policy would delete everything")
+
+	pruned, err := Prune(ctx, reg, PrunePolicy{KeepLast: 1, DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"greet@1.1.0", "greet@1.3.0", "greet@1.4.0"}, versions(pruned), "production and aliased versions are kept")
+	infos, err := reg.ListVersions(ctx, "greet")
+	require.NoError(t, err)
+	assert.Len(t, infos, 6, "dry run deletes nothing")
+
+	pruned, err = Prune(ctx, reg, PrunePolicy{KeepLast: 1, OlderThan: 90 * 24 * time.Hour, SkipStages: []Stage{StageStaging}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"greet@1.3.0"}, versions(pruned), "recent and staging versions are kept")
+	infos, err = reg.ListVersions(ctx, "greet")
+	require.NoError(t, err)
+	assert.Len(t, infos, 5)
+	_, err = reg.Get(ctx, "greet", "1.3.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+	pruned, err = Prune(ctx, reg, PrunePolicy{OlderThan: time.Hour, IDs: []string{"other"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other@1.0.0"}, versions(pruned))
+}
@@ -17,32 +17,31 @@ const (
 	redisKeyMeta       = "meta:%s:%s"
 	redisKeyProduction = "production:%s"
 	redisKeyIDs        = "index:ids"
+	redisKeyIDsSorted  = "index:ids:sorted"
 	redisKeyVersions   = "index:versions:%s"
+	redisKeyAudit      = "audit:%s"
+	redisKeyChanges    = "changes"
+	redisKeyAliases    = "aliases:%s"
 )
 
-// RedisRegistry stores prompts in Redis. Keys: prompt:id:version (JSON), meta:id:version (JSON), production:id (version), index:ids (SET), index:versions:id (SET).
+// RedisRegistry stores prompts in Redis. Keys: prompt:id:version (JSON, or see WithCodec), meta:id:version (JSON), production:id (version), index:ids (SET),
+// index:ids:sorted (ZSET of the ids, all scored 0 so they sort by name), index:versions:id (SET), audit:id (STREAM of AuditEntry JSON), aliases:id (HASH alias -> version).
+// Every write also publishes the changed id on the changes channel (see WatchChanges).
+// Each write updates the prompt, meta, index, and audit keys in one MULTI/EXEC transaction, retried
+// if a key it read changes concurrently, so they stay consistent when a command fails. On Redis
+// Cluster the key prefix must contain a hash tag (e.g. "{loom}:") so all keys are in one slot.
 type RedisRegistry struct {
 	client redis.UniversalClient
 	prefix string
-}
-
-// RedisClient is the minimal Redis interface needed (satisfied by *redis.Client, *redis.ClusterClient).
-type RedisClient interface {
-	Get(ctx context.Context, key string) *redis.StringCmd
-	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
-	Del(ctx context.Context, keys ...string) *redis.IntCmd
-	Keys(ctx context.Context, pattern string) *redis.StringSliceCmd
-	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
-	SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
-	SMembers(ctx context.Context, key string) *redis.StringSliceCmd
+	storage
 }
 
 // NewRedisRegistry creates a registry using the given Redis client. Optional key prefix (e.g. "loom:").
-func NewRedisRegistry(client redis.UniversalClient, prefix string) *RedisRegistry {
+func NewRedisRegistry(client redis.UniversalClient, prefix string, opts ...StorageOption) *RedisRegistry {
 	if prefix != "" && !strings.HasSuffix(prefix, ":") {
 		prefix += ":"
 	}
-	return &RedisRegistry{client: client, prefix: prefix}
+	return &RedisRegistry{client: client, prefix: prefix, storage: newStorage(JSONCodec, opts)}
 }
 
 func (r *RedisRegistry) key(format string, a ...interface{}) string {
@@ -54,45 +53,347 @@ func (r *RedisRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
 	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
 		return fmt.Errorf("redis registry: prompt id and version required")
 	}
-	data, err := json.Marshal(prompt)
-	if err != nil {
-		return fmt.Errorf("redis registry encode: %w", err)
+	return retryTx(func() error { return r.store(ctx, prompt, -1) })
+}
+
+// StoreIfMatch implements ConditionalStorer using WATCH on the prompt key.
+func (r *RedisRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
+		return fmt.Errorf("redis registry: prompt id and version required")
 	}
-	k := r.key(redisKeyPrompt, prompt.ID, prompt.Version)
-	if err := r.client.Set(ctx, k, data, 0).Err(); err != nil {
-		return err
+	err := r.store(ctx, prompt, revision)
+	if err == redis.TxFailedErr {
+		return core.ErrConflict
 	}
-	meta := struct {
-		Stage     string    `json:"stage"`
-		Tags      []string  `json:"tags"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-	}{
+	return err
+}
+
+// redisStoreRetries bounds how often a write retries when a concurrent write races its transaction.
+const redisStoreRetries = 5
+
+// retryTx calls fn, which runs a WATCH transaction, again while it fails with redis.TxFailedErr,
+// up to redisStoreRetries times.
+func retryTx(fn func() error) error {
+	var err error
+	for attempt := 0; attempt < redisStoreRetries; attempt++ {
+		if err = fn(); err != redis.TxFailedErr {
+			break
+		}
+	}
+	return err
+}
+
+// store writes the prompt body with the next revision, its meta and index entries, and its audit
+// entry in a transaction watching its key; if revision >= 0 it must match the stored one. It
+// returns redis.TxFailedErr if the key changed concurrently.
+func (r *RedisRegistry) store(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	k := r.key(redisKeyPrompt, prompt.ID, prompt.Version)
+	mk := r.key(redisKeyMeta, prompt.ID, prompt.Version)
+	return r.client.Watch(ctx, func(tx *redis.Tx) error {
+		var current int64
+		prevMeta, err := tx.Get(ctx, mk).Bytes()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		old, err := tx.Get(ctx, k).Bytes()
+		switch {
+		case err == redis.Nil:
+		case err != nil:
+			return err
+		default:
+			var p core.Prompt
+			if err := decodePrompt(old, &p); err != nil {
+				return fmt.Errorf("redis registry decode: %w", err)
+			}
+			current = p.Revision
+		}
+		if revision >= 0 && revision != current {
+			return core.ErrConflict
+		}
+		next := *prompt
+		next.Revision = current + 1
+		data, err := encodePrompt(r.codec, &next)
+		if err != nil {
+			return fmt.Errorf("redis registry encode: %w", err)
+		}
+		if _, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, k, data, 0)
+			_ = r.storeMeta(ctx, pipe, prompt, prevMeta)
+			e := NewAuditEntry(ctx, AuditStore, prompt.ID, prompt.Version)
+			e.Revision = next.Revision
+			r.pipeRecord(ctx, pipe, e)
+			return nil
+		}); err != nil {
+			return err
+		}
+		prompt.Revision = next.Revision
+		return nil
+	}, k, mk)
+}
+
+// storeMeta writes the meta entry and index sets for a stored prompt with c (the client or a pipeline).
+// If the version already existed, prev is its meta entry, whose stage, tags, and archived state
+// are kept.
+func (r *RedisRegistry) storeMeta(ctx context.Context, c redis.Cmdable, prompt *core.Prompt, prev []byte) error {
+	meta := redisMeta{
 		Stage:     "dev",
 		Tags:      nil,
 		CreatedAt: prompt.CreatedAt,
 		UpdatedAt: prompt.UpdatedAt,
+		Checksum:  Checksum(prompt),
+	}
+	var old redisMeta
+	if len(prev) > 0 && json.Unmarshal(prev, &old) == nil {
+		meta.Stage, meta.Tags, meta.Archived = old.Stage, old.Tags, old.Archived
 	}
 	metaData, _ := json.Marshal(meta)
-	if err := r.client.Set(ctx, r.key(redisKeyMeta, prompt.ID, prompt.Version), metaData, 0).Err(); err != nil {
+	if err := c.Set(ctx, r.key(redisKeyMeta, prompt.ID, prompt.Version), metaData, 0).Err(); err != nil {
 		return err
 	}
-	r.client.SAdd(ctx, r.key(redisKeyIDs), prompt.ID)
-	r.client.SAdd(ctx, r.key(redisKeyVersions, prompt.ID), prompt.Version)
+	c.SAdd(ctx, r.key(redisKeyIDs), prompt.ID)
+	c.ZAdd(ctx, r.key(redisKeyIDsSorted), redis.Z{Member: prompt.ID})
+	c.SAdd(ctx, r.key(redisKeyVersions, prompt.ID), prompt.Version)
 	return nil
 }
 
-// Get retrieves a prompt by id and version.
+// StoreBatch implements Batcher. The prompts, their meta and index entries, and their audit
+// entries are written in one MULTI/EXEC transaction, retried if a stored version changes
+// concurrently. On Redis Cluster the key prefix must contain a hash tag (e.g. "{loom}:") so all
+// keys are in one slot.
+func (r *RedisRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	if len(prompts) == 0 {
+		return nil
+	}
+	keys := make([]string, len(prompts))
+	metaKeys := make([]string, len(prompts))
+	for i, p := range prompts {
+		keys[i] = r.key(redisKeyPrompt, p.ID, p.Version)
+		metaKeys[i] = r.key(redisKeyMeta, p.ID, p.Version)
+	}
+	return retryTx(func() error { return r.storeBatch(ctx, prompts, keys, metaKeys) })
+}
+
+func (r *RedisRegistry) storeBatch(ctx context.Context, prompts []*core.Prompt, keys, metaKeys []string) error {
+	return r.client.Watch(ctx, func(tx *redis.Tx) error {
+		olds, err := tx.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		prevMetas, err := tx.MGet(ctx, metaKeys...).Result()
+		if err != nil {
+			return err
+		}
+		revisions := make([]int64, len(prompts))
+		bodies := make([][]byte, len(prompts))
+		for i, p := range prompts {
+			var current int64
+			if old, ok := olds[i].(string); ok {
+				var op core.Prompt
+				if err := decodePrompt([]byte(old), &op); err != nil {
+					return fmt.Errorf("redis registry decode: %w", err)
+				}
+				current = op.Revision
+			}
+			next := *p
+			next.Revision = current + 1
+			if bodies[i], err = encodePrompt(r.codec, &next); err != nil {
+				return fmt.Errorf("redis registry encode: %w", err)
+			}
+			revisions[i] = next.Revision
+		}
+		if _, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, p := range prompts {
+				pipe.Set(ctx, keys[i], bodies[i], 0)
+				prev, _ := prevMetas[i].(string)
+				_ = r.storeMeta(ctx, pipe, p, []byte(prev))
+				e := NewAuditEntry(ctx, AuditStore, p.ID, p.Version)
+				e.Revision = revisions[i]
+				r.pipeRecord(ctx, pipe, e)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for i, p := range prompts {
+			p.Revision = revisions[i]
+		}
+		return nil
+	}, append(keys, metaKeys...)...)
+}
+
+// DeleteBatch implements Batcher in one MULTI/EXEC transaction; nothing is deleted if one of the
+// versions does not exist. See StoreBatch for Redis Cluster.
+func (r *RedisRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	ids := refIDs(refs)
+	keys := make([]string, 0, len(refs)+len(ids))
+	for _, ref := range refs {
+		keys = append(keys, r.key(redisKeyPrompt, ref.ID, ref.Version))
+	}
+	for _, id := range ids {
+		keys = append(keys, r.key(redisKeyProduction, id))
+	}
+	watched := append([]string(nil), keys...)
+	for _, id := range ids {
+		watched = append(watched, r.key(redisKeyVersions, id))
+	}
+	return retryTx(func() error { return r.deleteBatch(ctx, refs, ids, keys, watched) })
+}
+
+// deleteBatch deletes refs; keys are the refs' prompt keys followed by the production keys of ids,
+// and watched are keys followed by the version index keys of ids.
+func (r *RedisRegistry) deleteBatch(ctx context.Context, refs []VersionRef, ids, keys, watched []string) error {
+	return r.client.Watch(ctx, func(tx *redis.Tx) error {
+		vals, err := tx.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		for i, ref := range refs {
+			if vals[i] == nil {
+				return fmt.Errorf("%w: %s@%s", core.ErrPromptNotFound, ref.ID, ref.Version)
+			}
+		}
+		production := make(map[string]string, len(ids))
+		for i, id := range ids {
+			if v, ok := vals[len(refs)+i].(string); ok {
+				production[id] = v
+			}
+		}
+		remaining, err := r.remainingVersions(ctx, tx, refs)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, ref := range refs {
+				pipe.Del(ctx, keys[i], r.key(redisKeyMeta, ref.ID, ref.Version))
+				pipe.SRem(ctx, r.key(redisKeyVersions, ref.ID), ref.Version)
+				if production[ref.ID] == ref.Version {
+					pipe.Del(ctx, r.key(redisKeyProduction, ref.ID))
+				}
+				r.pipeRecord(ctx, pipe, NewAuditEntry(ctx, AuditDelete, ref.ID, ref.Version))
+			}
+			for _, id := range ids {
+				if remaining[id] == 0 {
+					pipe.SRem(ctx, r.key(redisKeyIDs), id)
+					pipe.ZRem(ctx, r.key(redisKeyIDsSorted), id)
+				}
+			}
+			return nil
+		})
+		return err
+	}, watched...)
+}
+
+// remainingVersions returns, per id of refs, how many indexed versions are left once refs are deleted.
+func (r *RedisRegistry) remainingVersions(ctx context.Context, tx *redis.Tx, refs []VersionRef) (map[string]int64, error) {
+	cards := make(map[string]*redis.IntCmd)
+	members := make([]*redis.BoolCmd, len(refs))
+	if _, err := tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, ref := range refs {
+			k := r.key(redisKeyVersions, ref.ID)
+			if cards[ref.ID] == nil {
+				cards[ref.ID] = pipe.SCard(ctx, k)
+			}
+			members[i] = pipe.SIsMember(ctx, k, ref.Version)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	remaining := make(map[string]int64, len(cards))
+	for id, c := range cards {
+		remaining[id] = c.Val()
+	}
+	for i, ref := range refs {
+		if members[i].Val() {
+			remaining[ref.ID]--
+		}
+	}
+	return remaining, nil
+}
+
+// GetMany implements Batcher with one pipeline of prompt and meta reads.
+func (r *RedisRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	out := make([]*core.Prompt, len(refs))
+	if len(refs) == 0 {
+		return out, nil
+	}
+	promptCmds := make([]*redis.StringCmd, len(refs))
+	metaCmds := make([]*redis.StringCmd, len(refs))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, ref := range refs {
+			promptCmds[i] = pipe.Get(ctx, r.key(redisKeyPrompt, ref.ID, ref.Version))
+			metaCmds[i] = pipe.Get(ctx, r.key(redisKeyMeta, ref.ID, ref.Version))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for i := range refs {
+		data, err := promptCmds[i].Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if metaData, err := metaCmds[i].Bytes(); err == nil {
+			var meta redisMeta
+			if json.Unmarshal(metaData, &meta) == nil && meta.Archived {
+				continue
+			}
+		}
+		var p core.Prompt
+		if err := decodePrompt(data, &p); err != nil {
+			return nil, fmt.Errorf("redis registry decode: %w", err)
+		}
+		out[i] = p.Copy()
+	}
+	return out, nil
+}
+
+// Get retrieves a prompt by id and version (or "@alias"). Archived versions are reported as not found.
 func (r *RedisRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
-	data, err := r.client.Get(ctx, r.key(redisKeyPrompt, id, version)).Bytes()
+	if alias, ok := ParseAlias(version); ok {
+		target, err := r.client.HGet(ctx, r.key(redisKeyAliases, id), alias).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		return GetByAlias(ctx, r, id, alias, target)
+	}
+	var promptCmd, metaCmd *redis.StringCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		promptCmd = pipe.Get(ctx, r.key(redisKeyPrompt, id, version))
+		metaCmd = pipe.Get(ctx, r.key(redisKeyMeta, id, version))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	data, err := promptCmd.Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, core.ErrPromptNotFound
 		}
 		return nil, err
 	}
+	if metaData, err := metaCmd.Bytes(); err == nil {
+		var meta redisMeta
+		if json.Unmarshal(metaData, &meta) == nil && meta.Archived {
+			return nil, core.ErrPromptNotFound
+		}
+	}
 	var p core.Prompt
-	if err := json.Unmarshal(data, &p); err != nil {
+	if err := decodePrompt(data, &p); err != nil {
 		return nil, fmt.Errorf("redis registry decode: %w", err)
 	}
 	return p.Copy(), nil
@@ -110,162 +411,536 @@ func (r *RedisRegistry) GetProduction(ctx context.Context, id string) (*core.Pro
 	return r.Get(ctx, id, version)
 }
 
-// List returns prompts matching the filter (scans index).
-func (r *RedisRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
-	ids, err := r.client.SMembers(ctx, r.key(redisKeyIDs)).Result()
-	if err != nil {
+// redisScanCount is the COUNT hint passed to SSCAN; each batch of versions is fetched with one pipeline.
+const redisScanCount = 200
+
+// redisMeta is the JSON value stored at meta:id:version.
+type redisMeta struct {
+	Stage     string    `json:"stage"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Archived  bool      `json:"archived,omitempty"`
+	Checksum  string    `json:"checksum,omitempty"`
+}
+
+// sscan iterates a set with SSCAN, calling fn for each batch of members until fn returns stop or the cursor is exhausted.
+// SSCAN may return a member more than once (e.g. while the set is rehashed); fn sees each member once.
+func (r *RedisRegistry) sscan(ctx context.Context, key string, fn func(members []string) (stop bool, err error)) error {
+	var cursor uint64
+	seen := make(map[string]bool)
+	for {
+		batch, next, err := r.client.SScan(ctx, key, cursor, "", redisScanCount).Result()
+		if err != nil {
+			return err
+		}
+		members := batch[:0]
+		for _, m := range batch {
+			if !seen[m] {
+				seen[m] = true
+				members = append(members, m)
+			}
+		}
+		if len(members) > 0 {
+			stop, err := fn(members)
+			if err != nil || stop {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// fetchMeta reads meta for many versions of an id in a single pipeline. Missing entries are nil.
+func (r *RedisRegistry) fetchMeta(ctx context.Context, id string, versions []string) ([]*redisMeta, error) {
+	cmds := make([]*redis.StringCmd, len(versions))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, v := range versions {
+			cmds[i] = pipe.Get(ctx, r.key(redisKeyMeta, id, v))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
 		return nil, err
 	}
-	limit := filter.Limit
-	if limit <= 0 {
-		limit = 1000
+	out := make([]*redisMeta, len(versions))
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		var m redisMeta
+		if json.Unmarshal(data, &m) == nil {
+			out[i] = &m
+		}
 	}
-	var out []*core.Prompt
-	offset := filter.Offset
-	for _, id := range ids {
-		if len(filter.IDs) > 0 && !contains(filter.IDs, id) {
+	return out, nil
+}
+
+// fetchEntries reads the prompts of entries in a single pipeline. Missing or undecodable entries are nil.
+func (r *RedisRegistry) fetchEntries(ctx context.Context, entries []ListEntry) ([]*core.Prompt, error) {
+	cmds := make([]*redis.StringCmd, len(entries))
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, e := range entries {
+			cmds[i] = pipe.Get(ctx, r.key(redisKeyPrompt, e.ID, e.Version))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	out := make([]*core.Prompt, len(entries))
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err != nil {
 			continue
 		}
-		vers, _ := r.client.SMembers(ctx, r.key(redisKeyVersions, id)).Result()
-		for _, version := range vers {
-			metaData, err := r.client.Get(ctx, r.key(redisKeyMeta, id, version)).Bytes()
-			if err == redis.Nil {
-				continue
-			}
+		var p core.Prompt
+		if decodePrompt(data, &p) == nil {
+			out[i] = p.Copy()
+		}
+	}
+	return out, nil
+}
+
+// List returns prompts matching the filter in the filter's order. Ids and versions are iterated
+// with SSCAN and the small meta records (stage, tags, timestamps) read in pipelined batches to
+// select and sort the matching versions; only then are prompt bodies fetched, for the requested
+// page. Query and Metadata are matched on the fetched bodies (see Filter.MatchesSearch), so a search
+// fetches bodies in order until enough matches are found.
+//
+// In the default order (SortByID) without IDs or a search, ids are read from the sorted id index
+// with ZRANGEBYLEX, starting at the Cursor's id, and only until a page's worth of versions
+// (Offset plus Limit) follows the cursor, so each page costs about the same however many prompts
+// there are. Other orders and searches read every id, so use Cursor rather than a large Offset to
+// page through them.
+func (r *RedisRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	if _, _, err := filter.cursorKey(); err != nil {
+		return nil, err
+	}
+	listID := func(id string) ([]ListEntry, error) {
+		var entries []ListEntry
+		err := r.sscan(ctx, r.key(redisKeyVersions, id), func(vers []string) (bool, error) {
+			metas, err := r.fetchMeta(ctx, id, vers)
 			if err != nil {
-				continue
-			}
-			var meta struct {
-				Stage string   `json:"stage"`
-				Tags  []string `json:"tags"`
-			}
-			_ = json.Unmarshal(metaData, &meta)
-			if filter.Stage != "" && Stage(meta.Stage) != filter.Stage {
-				continue
-			}
-			if len(filter.Tags) > 0 && !hasAll(meta.Tags, filter.Tags) {
-				continue
+				return true, err
 			}
-			if offset > 0 {
-				offset--
-				continue
+			for i, version := range vers {
+				meta := metas[i]
+				if meta == nil || (meta.Archived && !filter.IncludeArchived) {
+					continue
+				}
+				if filter.Stage != "" && Stage(meta.Stage) != filter.Stage {
+					continue
+				}
+				if len(filter.Tags) > 0 && !hasAll(meta.Tags, filter.Tags) {
+					continue
+				}
+				entries = append(entries, ListEntry{ID: id, Version: version, CreatedAt: meta.CreatedAt, UpdatedAt: meta.UpdatedAt})
 			}
-			p, err := r.Get(ctx, id, version)
+			return false, nil
+		})
+		return entries, err
+	}
+	var entries []ListEntry
+	switch {
+	case len(filter.IDs) > 0:
+		for _, id := range filter.IDs {
+			got, err := listID(id)
 			if err != nil {
-				continue
+				return nil, err
 			}
-			out = append(out, p)
-			if len(out) >= limit {
-				return out, nil
+			entries = append(entries, got...)
+		}
+	case (filter.SortBy == "" || filter.SortBy == SortByID) && !filter.Searching():
+		var err error
+		if entries, err = r.listSorted(ctx, filter, listID); err != nil {
+			return nil, err
+		}
+	default:
+		err := r.sscan(ctx, r.key(redisKeyIDs), func(ids []string) (bool, error) {
+			for _, id := range ids {
+				got, err := listID(id)
+				if err != nil {
+					return true, err
+				}
+				entries = append(entries, got...)
 			}
+			return false, nil
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
-	return out, nil
+	return filter.Page(entries, func(batch []ListEntry) ([]*core.Prompt, error) {
+		return r.fetchEntries(ctx, batch)
+	})
 }
 
-// ListVersions returns version info for an id.
-func (r *RedisRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
-	vers, err := r.client.SMembers(ctx, r.key(redisKeyVersions, id)).Result()
+// listSorted returns the entries listID finds for ids read in order from the sorted id index,
+// from the filter's cursor on, stopping after the id that brings the entries following the cursor
+// to Offset plus Limit.
+func (r *RedisRegistry) listSorted(ctx context.Context, filter Filter, listID func(id string) ([]ListEntry, error)) ([]ListEntry, error) {
+	if err := r.indexSortedIDs(ctx); err != nil {
+		return nil, err
+	}
+	after, hasCursor, err := filter.cursorKey()
 	if err != nil {
 		return nil, err
 	}
+	by := &redis.ZRangeBy{Min: "-", Max: "+", Count: redisScanCount}
+	if hasCursor {
+		// Inclusive: the cursor's id may have versions after the cursor.
+		if filter.Descending {
+			by.Max = "[" + after.id
+		} else {
+			by.Min = "[" + after.id
+		}
+	}
+	var entries []ListEntry
+	need, found := filter.Offset+filter.limit(), 0
+	for {
+		var ids []string
+		if filter.Descending {
+			ids, err = r.client.ZRevRangeByLex(ctx, r.key(redisKeyIDsSorted), by).Result()
+		} else {
+			ids, err = r.client.ZRangeByLex(ctx, r.key(redisKeyIDsSorted), by).Result()
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			got, err := listID(id)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range got {
+				if !hasCursor || filter.compareKeys(filter.keyOf(e.ID, e.Version, e.CreatedAt, e.UpdatedAt), after) > 0 {
+					found++
+				}
+			}
+			entries = append(entries, got...)
+			if found >= need {
+				return entries, nil
+			}
+		}
+		if len(ids) < redisScanCount {
+			return entries, nil
+		}
+		if last := ids[len(ids)-1]; filter.Descending {
+			by.Max = "(" + last
+		} else {
+			by.Min = "(" + last
+		}
+	}
+}
+
+// indexSortedIDs adds the ids of index:ids to the sorted id index if it has fewer, as it does for
+// prompts stored before the index existed. An id deleted meanwhile may be added back; it lists no
+// versions.
+func (r *RedisRegistry) indexSortedIDs(ctx context.Context) error {
+	var sorted, ids *redis.IntCmd
+	if _, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		sorted = pipe.ZCard(ctx, r.key(redisKeyIDsSorted))
+		ids = pipe.SCard(ctx, r.key(redisKeyIDs))
+		return nil
+	}); err != nil {
+		return err
+	}
+	if sorted.Val() >= ids.Val() {
+		return nil
+	}
+	return r.sscan(ctx, r.key(redisKeyIDs), func(ids []string) (bool, error) {
+		members := make([]redis.Z, len(ids))
+		for i, id := range ids {
+			members[i] = redis.Z{Member: id}
+		}
+		return false, r.client.ZAdd(ctx, r.key(redisKeyIDsSorted), members...).Err()
+	})
+}
+
+// ListVersions returns version info for an id (SSCAN over the version index, pipelined meta reads).
+func (r *RedisRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
 	var infos []VersionInfo
-	for _, version := range vers {
-		p, err := r.Get(ctx, id, version)
+	err := r.sscan(ctx, r.key(redisKeyVersions, id), func(vers []string) (bool, error) {
+		metas, err := r.fetchMeta(ctx, id, vers)
 		if err != nil {
-			continue
+			return true, err
 		}
-		metaData, _ := r.client.Get(ctx, r.key(redisKeyMeta, id, version)).Bytes()
-		vi := VersionInfo{ID: id, Version: version, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt}
-		if len(metaData) > 0 {
-			var meta struct {
-				Stage string   `json:"stage"`
-				Tags  []string `json:"tags"`
+		for i, version := range vers {
+			meta := metas[i]
+			if meta == nil {
+				continue
 			}
-			_ = json.Unmarshal(metaData, &meta)
-			vi.Stage = Stage(meta.Stage)
-			vi.Tags = meta.Tags
+			infos = append(infos, VersionInfo{
+				ID:        id,
+				Version:   version,
+				Stage:     Stage(meta.Stage),
+				Tags:      meta.Tags,
+				CreatedAt: meta.CreatedAt,
+				UpdatedAt: meta.UpdatedAt,
+				Archived:  meta.Archived,
+				Checksum:  meta.Checksum,
+			})
 		}
-		infos = append(infos, vi)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	sortVersionInfos(infos)
 	return infos, nil
 }
 
 // Promote sets the stage for id+version and updates production pointer.
 func (r *RedisRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
-	_, err := r.client.Get(ctx, r.key(redisKeyPrompt, id, version)).Result()
-	if err == redis.Nil {
-		return core.ErrPromptNotFound
-	}
-	if err != nil {
-		return err
-	}
-	metaData, _ := r.client.Get(ctx, r.key(redisKeyMeta, id, version)).Bytes()
-	var meta struct {
-		Stage     string   `json:"stage"`
-		Tags      []string `json:"tags"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
+	e := NewAuditEntry(ctx, AuditPromote, id, version)
+	e.Stage = stage
+	return r.updateMeta(ctx, id, version, e, func(meta *redisMeta, pipe redis.Pipeliner) {
+		meta.Stage = string(stage)
+		if stage == StageProduction {
+			pipe.Set(ctx, r.key(redisKeyProduction, id), version, 0)
+		}
+	})
+}
+
+// RollbackProduction implements ProductionRollbacker: the production key is watched, so the
+// transaction only commits if production is still from when it runs.
+func (r *RedisRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	e := NewAuditEntry(ctx, AuditRollback, id, version)
+	e.Stage = StageProduction
+	prodKey := r.key(redisKeyProduction, id)
+	check := func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, prodKey).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if current != from {
+			return fmt.Errorf("%w: %s production is %q, not %q", core.ErrConflict, id, current, from)
+		}
+		return nil
 	}
-	if len(metaData) > 0 {
-		_ = json.Unmarshal(metaData, &meta)
+	err := r.updateMetaIf(ctx, id, version, e, check, func(meta *redisMeta, pipe redis.Pipeliner) {
+		meta.Stage = string(StageProduction)
+		pipe.Set(ctx, prodKey, version, 0)
+	}, prodKey)
+	if err == redis.TxFailedErr {
+		return core.ErrConflict
 	}
-	meta.Stage = string(stage)
-	newMeta, _ := json.Marshal(meta)
-	if err := r.client.Set(ctx, r.key(redisKeyMeta, id, version), newMeta, 0).Err(); err != nil {
+	return err
+}
+
+// updateMeta applies update to the meta entry of id@version and records e in one transaction,
+// which fails with core.ErrPromptNotFound if the version does not exist. update may queue further
+// writes on pipe.
+func (r *RedisRegistry) updateMeta(ctx context.Context, id, version string, e AuditEntry, update func(meta *redisMeta, pipe redis.Pipeliner)) error {
+	return r.updateMetaIf(ctx, id, version, e, nil, update)
+}
+
+// updateMetaIf is updateMeta with a check, if not nil, that runs in the transaction after the
+// version is found and aborts it with its error; the watch keys it reads are watched too.
+func (r *RedisRegistry) updateMetaIf(ctx context.Context, id, version string, e AuditEntry, check func(tx *redis.Tx) error, update func(meta *redisMeta, pipe redis.Pipeliner), watch ...string) error {
+	pk, mk := r.key(redisKeyPrompt, id, version), r.key(redisKeyMeta, id, version)
+	return retryTx(func() error {
+		return r.client.Watch(ctx, func(tx *redis.Tx) error {
+			if err := r.exists(ctx, tx, pk); err != nil {
+				return err
+			}
+			if check != nil {
+				if err := check(tx); err != nil {
+					return err
+				}
+			}
+			metaData, err := tx.Get(ctx, mk).Bytes()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			var meta redisMeta
+			if len(metaData) > 0 {
+				_ = json.Unmarshal(metaData, &meta)
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				update(&meta, pipe)
+				newMeta, _ := json.Marshal(meta)
+				pipe.Set(ctx, mk, newMeta, 0)
+				r.pipeRecord(ctx, pipe, e)
+				return nil
+			})
+			return err
+		}, append([]string{pk, mk}, watch...)...)
+	})
+}
+
+// exists returns core.ErrPromptNotFound if the prompt key k does not exist.
+func (r *RedisRegistry) exists(ctx context.Context, tx *redis.Tx, k string) error {
+	n, err := tx.Exists(ctx, k).Result()
+	if err != nil {
 		return err
 	}
-	if stage == StageProduction {
-		if err := r.client.Set(ctx, r.key(redisKeyProduction, id), version, 0).Err(); err != nil {
-			return err
-		}
+	if n == 0 {
+		return core.ErrPromptNotFound
 	}
 	return nil
 }
 
 // Delete removes a prompt version from Redis.
 func (r *RedisRegistry) Delete(ctx context.Context, id, version string) error {
-	k := r.key(redisKeyPrompt, id, version)
-	_, err := r.client.Get(ctx, k).Result()
-	if err == redis.Nil {
-		return core.ErrPromptNotFound
+	pk, prodKey, versionsKey := r.key(redisKeyPrompt, id, version), r.key(redisKeyProduction, id), r.key(redisKeyVersions, id)
+	ref := []VersionRef{{ID: id, Version: version}}
+	return retryTx(func() error {
+		return r.client.Watch(ctx, func(tx *redis.Tx) error {
+			if err := r.exists(ctx, tx, pk); err != nil {
+				return err
+			}
+			prod, err := tx.Get(ctx, prodKey).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			remaining, err := r.remainingVersions(ctx, tx, ref)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, pk, r.key(redisKeyMeta, id, version))
+				pipe.SRem(ctx, versionsKey, version)
+				if prod == version {
+					pipe.Del(ctx, prodKey)
+				}
+				if remaining[id] == 0 {
+					pipe.SRem(ctx, r.key(redisKeyIDs), id)
+					pipe.ZRem(ctx, r.key(redisKeyIDsSorted), id)
+				}
+				r.pipeRecord(ctx, pipe, NewAuditEntry(ctx, AuditDelete, id, version))
+				return nil
+			})
+			return err
+		}, pk, prodKey, versionsKey)
+	})
+}
+
+// Tag sets tags for a prompt version.
+func (r *RedisRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	e := NewAuditEntry(ctx, AuditTag, id, version)
+	e.Tags = append([]string(nil), tags...)
+	return r.updateMeta(ctx, id, version, e, func(meta *redisMeta, _ redis.Pipeliner) {
+		meta.Tags = append([]string(nil), tags...)
+	})
+}
+
+// SetAlias implements Aliaser.
+func (r *RedisRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if err := ValidateAlias(alias); err != nil {
+		return err
 	}
-	if err != nil {
+	e := NewAuditEntry(ctx, AuditAlias, id, version)
+	e.Alias = alias
+	aliasKey := r.key(redisKeyAliases, id)
+	if version == "" {
+		_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, aliasKey, alias)
+			r.pipeRecord(ctx, pipe, e)
+			return nil
+		})
 		return err
 	}
-	r.client.Del(ctx, k, r.key(redisKeyMeta, id, version))
-	r.client.SRem(ctx, r.key(redisKeyVersions, id), version)
-	prod, _ := r.client.Get(ctx, r.key(redisKeyProduction, id)).Result()
-	if prod == version {
-		r.client.Del(ctx, r.key(redisKeyProduction, id))
+	pk := r.key(redisKeyPrompt, id, version)
+	return retryTx(func() error {
+		return r.client.Watch(ctx, func(tx *redis.Tx) error {
+			if err := r.exists(ctx, tx, pk); err != nil {
+				return err
+			}
+			_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, aliasKey, alias, version)
+				r.pipeRecord(ctx, pipe, e)
+				return nil
+			})
+			return err
+		}, pk)
+	})
+}
+
+// Aliases implements Aliaser.
+func (r *RedisRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, r.key(redisKeyAliases, id)).Result()
+}
+
+// Archive hides a prompt version from Get, GetProduction, and List until it is restored.
+func (r *RedisRegistry) Archive(ctx context.Context, id, version string) error {
+	return r.setArchived(ctx, id, version, true)
+}
+
+// Restore makes an archived prompt version visible again.
+func (r *RedisRegistry) Restore(ctx context.Context, id, version string) error {
+	return r.setArchived(ctx, id, version, false)
+}
+
+func (r *RedisRegistry) setArchived(ctx context.Context, id, version string, archived bool) error {
+	action := AuditArchive
+	if !archived {
+		action = AuditRestore
 	}
-	vers, _ := r.client.SMembers(ctx, r.key(redisKeyVersions, id)).Result()
-	if len(vers) == 0 {
-		r.client.SRem(ctx, r.key(redisKeyIDs), id)
+	return r.updateMeta(ctx, id, version, NewAuditEntry(ctx, action, id, version), func(meta *redisMeta, _ redis.Pipeliner) {
+		meta.Archived = archived
+	})
+}
+
+// pipeRecord queues an append of e to the id's audit stream and the announcement of the change to
+// watchers on pipe.
+func (r *RedisRegistry) pipeRecord(ctx context.Context, pipe redis.Pipeliner, e AuditEntry) {
+	pipe.XAdd(ctx, r.auditArgs(e))
+	pipe.Publish(ctx, r.key(redisKeyChanges), e.ID)
+}
+
+func (r *RedisRegistry) auditArgs(e AuditEntry) *redis.XAddArgs {
+	data, _ := json.Marshal(e)
+	return &redis.XAddArgs{
+		Stream: r.key(redisKeyAudit, e.ID),
+		Values: map[string]interface{}{"entry": data},
 	}
-	return nil
 }
 
-// Tag sets tags for a prompt version.
-func (r *RedisRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
-	_, err := r.client.Get(ctx, r.key(redisKeyPrompt, id, version)).Result()
-	if err == redis.Nil {
-		return core.ErrPromptNotFound
+// WatchChanges implements ChangeWatcher by subscribing to the changes channel. Changes published
+// while the subscription is reconnecting are missed.
+func (r *RedisRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	sub := r.client.Subscribe(ctx, r.key(redisKeyChanges))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("redis registry watch: %w", err)
 	}
-	if err != nil {
-		return err
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			fn(msg.Payload)
+		}
 	}
-	metaData, _ := r.client.Get(ctx, r.key(redisKeyMeta, id, version)).Bytes()
-	var meta struct {
-		Stage     string   `json:"stage"`
-		Tags      []string `json:"tags"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
+}
+
+// History implements Auditor by reading the id's audit stream.
+func (r *RedisRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	msgs, err := r.client.XRange(ctx, r.key(redisKeyAudit, id), "-", "+").Result()
+	if err != nil {
+		return nil, err
 	}
-	if len(metaData) > 0 {
-		_ = json.Unmarshal(metaData, &meta)
+	out := make([]AuditEntry, 0, len(msgs))
+	for _, msg := range msgs {
+		raw, _ := msg.Values["entry"].(string)
+		var e AuditEntry
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			return nil, fmt.Errorf("redis registry audit decode: %w", err)
+		}
+		out = append(out, e)
 	}
-	meta.Tags = append([]string(nil), tags...)
-	newMeta, _ := json.Marshal(meta)
-	return r.client.Set(ctx, r.key(redisKeyMeta, id, version), newMeta, 0).Err()
+	return out, nil
 }
@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/klejdi94/loom/core"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// overlappingScans makes every SSCAN return its set in two batches that share members, as Redis
+// may while a set is rehashed: first the whole set, then its second half again.
+type overlappingScans struct{}
+
+func (overlappingScans) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (overlappingScans) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (overlappingScans) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		scan, ok := cmd.(*redis.ScanCmd)
+		if !ok || cmd.Name() != "sscan" {
+			return next(ctx, cmd)
+		}
+		args := cmd.Args()
+		again := args[2] != uint64(0)
+		args[2] = uint64(0)
+		if err := next(ctx, cmd); err != nil {
+			return err
+		}
+		members, _ := scan.Val()
+		if again {
+			scan.SetVal(members[len(members)/2:], 0)
+		} else {
+			scan.SetVal(members, 1)
+		}
+		return nil
+	}
+}
+
+func TestRedisRegistry_DuplicateScanMembers(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	client.AddHook(overlappingScans{})
+	reg := NewRedisRegistry(client, "loom:")
+	versions := []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0"}
+	for _, v := range versions {
+		require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "greet", Version: v, Template: "Hi"}))
+	}
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "other", Version: "1.0.0", Template: "x"}))
+
+	infos, err := reg.ListVersions(ctx, "greet")
+	require.NoError(t, err)
+	var got []string
+	for _, vi := range infos {
+		got = append(got, vi.Version)
+	}
+	assert.ElementsMatch(t, versions, got)
+
+	all, err := reg.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Len(t, all, 5)
+	page, err := reg.List(ctx, Filter{IDs: []string{"greet"}, Offset: 2, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page, 2, "offsets count each version once")
+
+	pruned, err := Prune(ctx, reg, PrunePolicy{IDs: []string{"greet"}, KeepLast: 2})
+	require.NoError(t, err)
+	assert.Len(t, pruned, 2)
+	infos, err = reg.ListVersions(ctx, "greet")
+	require.NoError(t, err)
+	assert.Len(t, infos, 2)
+}
+
+// versionScans counts the SSCANs of version indexes, one per id List reads.
+type versionScans struct{ n *int }
+
+func (versionScans) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (versionScans) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (s versionScans) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if args := cmd.Args(); cmd.Name() == "sscan" && strings.HasPrefix(args[1].(string), "loom:index:versions:") {
+			*s.n++
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func TestRedisRegistry_ListSortedIndex(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	var scans int
+	client.AddHook(versionScans{&scans})
+	reg := NewRedisRegistry(client, "loom:")
+	for _, id := range []string{"d", "b", "e", "a", "c"} {
+		for _, v := range []string{"2.0.0", "1.0.0"} {
+			require.NoError(t, reg.Store(ctx, &core.Prompt{ID: id, Version: v, Template: "x"}))
+		}
+	}
+	refs := func(page []*core.Prompt) []string {
+		var out []string
+		for _, p := range page {
+			out = append(out, p.ID+"@"+p.Version)
+		}
+		return out
+	}
+
+	var got []string
+	filter := Filter{Limit: 3}
+	for pages := 0; ; pages++ {
+		scans = 0
+		page, next, err := ListPage(ctx, reg, filter)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, scans, 3, "a page of 3 reads the cursor's id and at most 2 more, not all 5")
+		got = append(got, refs(page)...)
+		if next == "" {
+			break
+		}
+		filter.Cursor = next
+	}
+	assert.Equal(t, []string{"a@1.0.0", "a@2.0.0", "b@1.0.0", "b@2.0.0", "c@1.0.0", "c@2.0.0", "d@1.0.0", "d@2.0.0", "e@1.0.0", "e@2.0.0"}, got)
+
+	page, err := reg.List(ctx, Filter{Limit: 3, Descending: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e@2.0.0", "e@1.0.0", "d@2.0.0"}, refs(page))
+
+	// Registries written before the sorted index existed get it on the next List.
+	require.NoError(t, client.Del(ctx, "loom:"+redisKeyIDsSorted).Err())
+	page, err = reg.List(ctx, Filter{})
+	require.NoError(t, err)
+	assert.Len(t, page, 10)
+	assert.Equal(t, int64(5), client.ZCard(ctx, "loom:"+redisKeyIDsSorted).Val())
+
+	require.NoError(t, reg.DeleteBatch(ctx, []VersionRef{{ID: "c", Version: "1.0.0"}, {ID: "c", Version: "2.0.0"}}))
+	ids, err := client.ZRangeByLex(ctx, "loom:"+redisKeyIDsSorted, &redis.ZRangeBy{Min: "-", Max: "+"}).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "d", "e"}, ids)
+}
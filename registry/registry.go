@@ -3,6 +3,7 @@ package registry
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/klejdi94/loom/core"
@@ -25,6 +26,11 @@ type VersionInfo struct {
 	Tags      []string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// Archived is true for versions hidden by Archive; ListVersions still reports them.
+	Archived bool
+	// Checksum is the Checksum of the content as stored, or "" if the backend did not record one
+	// (e.g. versions stored before checksums were added). See Verify.
+	Checksum string
 }
 
 // Filter limits which prompts are returned by List.
@@ -34,9 +40,38 @@ type Filter struct {
 	Tags   []string
 	Limit  int
 	Offset int
+	// IncludeArchived also returns archived versions.
+	IncludeArchived bool
+	// Namespace lists this namespace instead of the context's (see NamespacedRegistry).
+	Namespace string
+	// Query matches prompts whose name, description, or template contain every word of it (see
+	// MatchesSearch). PostgresRegistry uses full-text search instead, which matches whole words.
+	Query string
+	// Metadata matches prompts whose metadata has each key set to the given value.
+	Metadata map[string]string
+	// SortBy orders the results; SortByID if empty. Ties are broken by id and then version.
+	SortBy SortField
+	// Descending reverses the order.
+	Descending bool
+	// Cursor continues a previous List after its last result (see NextCursor and ListPage). Unlike
+	// Offset, it stays correct when prompts are stored or deleted between pages, and backends
+	// that cannot seek skip the bodies of earlier pages. SortBy and Descending must match the
+	// previous call's.
+	Cursor string
 }
 
 // Registry stores and retrieves versioned prompts.
+//
+// Store saves a version's content and increments its revision. A new version starts in StageDev
+// without tags; storing over an existing one keeps its stage, tags, archived flag, and production
+// pointer (as do StoreIfMatch and StoreBatch). Package registrytest checks these semantics.
+//
+// Delete removes a version permanently. Archive is a reversible soft delete: an archived version
+// keeps its content, stage, and tags, but Get and GetProduction report core.ErrPromptNotFound for it
+// and List skips it unless Filter.IncludeArchived is set. Restore makes it visible again.
+//
+// ListVersions returns versions in ascending semver order (see CompareVersions). To pin callers to a
+// range instead of an exact version, use Resolve or GetLatest.
 type Registry interface {
 	Store(ctx context.Context, prompt *core.Prompt) error
 	Get(ctx context.Context, id, version string) (*core.Prompt, error)
@@ -46,4 +81,24 @@ type Registry interface {
 	Promote(ctx context.Context, id, version string, stage Stage) error
 	Delete(ctx context.Context, id, version string) error
 	Tag(ctx context.Context, id, version string, tags []string) error
+	Archive(ctx context.Context, id, version string) error
+	Restore(ctx context.Context, id, version string) error
+}
+
+// ConditionalStorer is implemented by registries that support optimistic concurrency control.
+// StoreIfMatch stores prompt only if the stored revision of prompt.ID@prompt.Version equals revision
+// (0 means the version must not exist yet); otherwise it returns core.ErrConflict. On success
+// prompt.Revision is set to the new revision.
+type ConditionalStorer interface {
+	StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error
+}
+
+// StoreIfMatch stores prompt via reg's StoreIfMatch. It returns an error if reg does not implement
+// ConditionalStorer rather than falling back to an unconditional Store.
+func StoreIfMatch(ctx context.Context, reg Registry, prompt *core.Prompt, revision int64) error {
+	cs, ok := reg.(ConditionalStorer)
+	if !ok {
+		return fmt.Errorf("registry: %T does not support conditional store", reg)
+	}
+	return cs.StoreIfMatch(ctx, prompt, revision)
 }
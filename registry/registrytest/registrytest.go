@@ -0,0 +1,160 @@
+// Package registrytest checks that a registry.Registry backend follows the semantics the other
+// packages rely on. Backends run it from their tests with a function that opens an empty registry:
+//
+//	func TestConformance(t *testing.T) {
+//		registrytest.Run(t, func(t *testing.T) registry.Registry {
+//			return mybackend.New(t.TempDir())
+//		})
+//	}
+package registrytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the conformance tests as subtests of t, each on a registry returned by open.
+func Run(t *testing.T, open func(t *testing.T) registry.Registry) {
+	t.Run("StoreNewVersion", func(t *testing.T) { testStoreNewVersion(t, open(t)) })
+	t.Run("StoreKeepsContent", func(t *testing.T) { testStoreKeepsContent(t, open(t)) })
+	t.Run("StoreKeepsState", func(t *testing.T) {
+		testStoreKeepsState(t, open(t), func(ctx context.Context, reg registry.Registry, p *core.Prompt) error {
+			return reg.Store(ctx, p)
+		})
+	})
+	t.Run("StoreIfMatchKeepsState", func(t *testing.T) {
+		reg := open(t)
+		if _, ok := reg.(registry.ConditionalStorer); !ok {
+			t.Skipf("%T does not implement registry.ConditionalStorer", reg)
+		}
+		testStoreKeepsState(t, reg, func(ctx context.Context, reg registry.Registry, p *core.Prompt) error {
+			return registry.StoreIfMatch(ctx, reg, p, 1)
+		})
+	})
+	t.Run("StoreBatchKeepsState", func(t *testing.T) {
+		testStoreKeepsState(t, open(t), func(ctx context.Context, reg registry.Registry, p *core.Prompt) error {
+			return registry.StoreBatch(ctx, reg, []*core.Prompt{p})
+		})
+	})
+}
+
+// testStoreNewVersion checks that a new version starts in dev, untagged, at revision 1.
+func testStoreNewVersion(t *testing.T, reg registry.Registry) {
+	ctx := context.Background()
+	p := &core.Prompt{ID: "conf-new", Version: "1.0.0", Template: "v1"}
+	require.NoError(t, reg.Store(ctx, p))
+	assert.Equal(t, int64(1), p.Revision)
+	vi := versionInfo(t, reg, "conf-new", "1.0.0")
+	assert.Equal(t, registry.StageDev, vi.Stage)
+	assert.Empty(t, vi.Tags)
+	assert.False(t, vi.Archived)
+	_, err := reg.GetProduction(ctx, "conf-new")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+}
+
+// testStoreKeepsContent checks that Get returns the content fields a prompt was stored with.
+func testStoreKeepsContent(t *testing.T, reg registry.Registry) {
+	ctx := context.Background()
+	minPriority, maxPriority := 1.0, 5.0
+	p := &core.Prompt{
+		ID: "conf-content", Version: "1.0.0", Name: "Support", Description: "Answers tickets",
+		System:   "You are {{.tone}}.",
+		Template: "{{.question}}",
+		Messages: []core.Message{
+			{Role: core.RoleUser, Content: "Hi", Attachments: []core.Attachment{
+				{MIMEType: "image/png", URL: "https://example.com/logo.png"},
+				{MIMEType: "application/pdf", Data: []byte("%PDF"), Name: "faq.pdf"},
+			}},
+			{Role: core.RoleAssistant, Content: "Hello! How can I help?"},
+			{History: "history"},
+		},
+		Variables: []core.Variable{
+			{Name: "question", Type: core.VariableTypeString, Required: true, MaxLen: 500, Pattern: `\S`,
+				Rules: []core.ValidationRule{{Rule: "not_blank", Message: "ask a question"}}, Sensitive: true},
+			{Name: "priority", Type: core.VariableTypeInt, Min: &minPriority, Max: &maxPriority},
+			{Name: "tone", Type: core.VariableTypeEnum, Enum: []string{"formal", "casual"}},
+			{Name: "docs", Type: core.VariableTypeList, Items: core.VariableTypeString},
+			{Name: "customer", Type: core.VariableTypeObject, Fields: []core.Variable{{Name: "tier", Type: core.VariableTypeEnum, Enum: []string{"free", "pro"}}}},
+		},
+		Tools:    []core.Tool{{Name: "lookup", Description: "Finds an order"}},
+		Metadata: map[string]interface{}{"team": "support"},
+		OutputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"answer": map[string]interface{}{"type": "string"}},
+		},
+	}
+	require.NoError(t, reg.Store(ctx, p))
+	got, err := reg.Get(ctx, "conf-content", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, p.Name, got.Name)
+	assert.Equal(t, p.Description, got.Description)
+	assert.Equal(t, p.System, got.System)
+	assert.Equal(t, p.Template, got.Template)
+	assert.Equal(t, p.Messages, got.Messages)
+	assert.Equal(t, p.Variables, got.Variables)
+	assert.Equal(t, p.Tools, got.Tools)
+	assert.Equal(t, p.Metadata, got.Metadata)
+	assert.Equal(t, p.OutputSchema, got.OutputSchema)
+	assert.Equal(t, registry.Checksum(p), versionInfo(t, reg, "conf-content", "1.0.0").Checksum)
+}
+
+// testStoreKeepsState checks that storing over a version (at revision 1) with store replaces its
+// content and bumps its revision but keeps its stage, tags, archived flag, and production pointer.
+func testStoreKeepsState(t *testing.T, reg registry.Registry, store func(ctx context.Context, reg registry.Registry, p *core.Prompt) error) {
+	ctx := context.Background()
+	for _, v := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "conf", Version: v, Template: v}))
+	}
+	require.NoError(t, reg.Promote(ctx, "conf", "1.0.0", registry.StageProduction))
+	require.NoError(t, reg.Tag(ctx, "conf", "1.0.0", []string{"approved"}))
+	require.NoError(t, reg.Promote(ctx, "conf", "1.1.0", registry.StageStaging))
+	require.NoError(t, reg.Tag(ctx, "conf", "1.1.0", []string{"candidate", "reviewed"}))
+	require.NoError(t, reg.Archive(ctx, "conf", "1.2.0"))
+
+	for _, v := range []string{"1.0.0", "1.1.0", "1.2.0"} {
+		p := &core.Prompt{ID: "conf", Version: v, Template: v + " edited"}
+		require.NoError(t, store(ctx, reg, p), v)
+		assert.Equal(t, int64(2), p.Revision, v)
+	}
+
+	prod := versionInfo(t, reg, "conf", "1.0.0")
+	assert.Equal(t, registry.StageProduction, prod.Stage)
+	assert.Equal(t, []string{"approved"}, prod.Tags)
+	staging := versionInfo(t, reg, "conf", "1.1.0")
+	assert.Equal(t, registry.StageStaging, staging.Stage)
+	assert.ElementsMatch(t, []string{"candidate", "reviewed"}, staging.Tags)
+	assert.True(t, versionInfo(t, reg, "conf", "1.2.0").Archived, "storing does not restore an archived version")
+
+	p, err := reg.GetProduction(ctx, "conf")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", p.Version)
+	assert.Equal(t, "1.0.0 edited", p.Template)
+	p, err = reg.Get(ctx, "conf", "1.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0 edited", p.Template)
+	_, err = reg.Get(ctx, "conf", "1.2.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	require.NoError(t, reg.Restore(ctx, "conf", "1.2.0"))
+	p, err = reg.Get(ctx, "conf", "1.2.0")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.0 edited", p.Template)
+}
+
+// versionInfo returns the ListVersions entry for id@version.
+func versionInfo(t *testing.T, reg registry.Registry, id, version string) registry.VersionInfo {
+	t.Helper()
+	infos, err := reg.ListVersions(context.Background(), id)
+	require.NoError(t, err)
+	for _, vi := range infos {
+		if vi.Version == version {
+			return vi
+		}
+	}
+	require.Failf(t, "version not listed", "%s@%s", id, version)
+	return registry.VersionInfo{}
+}
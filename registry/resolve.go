@@ -0,0 +1,168 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrDependencyCycle is returned by Resolver.GetResolved when prompts depend on each other.
+var ErrDependencyCycle = errors.New("registry: dependency cycle")
+
+// Resolver assembles prompts with the prompts they depend on (see core.Dependency), e.g. a
+// shared preamble used by many prompts' system prompts:
+//
+//	preamble:  Template "You are a support agent for {{.product}}."
+//	answer:    System `{{template "preamble" .}} Be brief.`, depends on {ID: "preamble"}
+//
+//	res, err := registry.NewResolver(reg).GetResolved(ctx, "answer", "1.0.0")
+type Resolver struct {
+	reg        Registry
+	leftDelim  string
+	rightDelim string
+}
+
+// ResolverOption configures a Resolver.
+type ResolverOption func(*Resolver)
+
+// WithResolverDelims sets the template delimiters used to define dependencies of prompts without
+// their own (see core.Delims); they must match the engine that renders resolved prompts (default
+// "{{" and "}}", see template.WithDelims).
+func WithResolverDelims(left, right string) ResolverOption {
+	return func(r *Resolver) {
+		r.leftDelim = left
+		r.rightDelim = right
+	}
+}
+
+// NewResolver returns a Resolver reading prompts and their dependencies from reg.
+func NewResolver(reg Registry, opts ...ResolverOption) *Resolver {
+	r := &Resolver{reg: reg, leftDelim: "{{", rightDelim: "}}"}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Resolved is a prompt assembled with its dependencies by Resolver.GetResolved.
+type Resolved struct {
+	// Prompt is the requested version with the templates of every prompt in its dependency tree
+	// defined in its system prompt and template, their variables added after its own, and
+	// core.DependenciesKey removed from its metadata. It has no renderer set.
+	Prompt *core.Prompt
+	// Dependencies lists every prompt in the tree, dependencies before their dependents, with
+	// Version set to the version that was used and Name to its template name.
+	Dependencies []core.Dependency
+}
+
+// GetResolved returns id@version (or "@alias") with its dependency tree fetched and assembled.
+// Dependencies without a version use their production version. It fails with
+// ErrDependencyCycle if a prompt depends on itself through the tree, with core.ErrPromptNotFound
+// if a dependency is missing or archived, if two different prompts in the tree share a template
+// name, and if a dependency's delimiters differ from the requested prompt's.
+func (r *Resolver) GetResolved(ctx context.Context, id, version string) (*Resolved, error) {
+	root, err := r.reg.Get(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	delims, err := r.delimsOf(root)
+	if err != nil {
+		return nil, fmt.Errorf("registry: %s@%s: %w", root.ID, root.Version, err)
+	}
+	res := &resolution{delims: delims, names: make(map[string]string), walked: make(map[string]bool)}
+	if err := r.walk(ctx, root, []string{root.ID + "@" + root.Version}, res); err != nil {
+		return nil, err
+	}
+	p := root.Copy()
+	delete(p.Metadata, core.DependenciesKey)
+	if defs := res.defs.String(); defs != "" {
+		p.Template = defs + p.Template
+		if p.System != "" {
+			p.System = defs + p.System
+		}
+		for i := range p.Messages {
+			if p.Messages[i].Content != "" {
+				p.Messages[i].Content = defs + p.Messages[i].Content
+			}
+		}
+	}
+	declared := p.VariableMap()
+	for _, v := range res.vars {
+		if _, ok := declared[v.Name]; !ok {
+			declared[v.Name] = v
+			p.Variables = append(p.Variables, v)
+		}
+	}
+	return &Resolved{Prompt: p, Dependencies: res.deps}, nil
+}
+
+// resolution collects the assembled parts of a dependency tree.
+type resolution struct {
+	delims core.Delims // of the root, used by the whole tree
+	defs   strings.Builder
+	vars   []core.Variable
+	deps   []core.Dependency
+	names  map[string]string // template name -> id@version
+	walked map[string]bool   // id@version whose dependencies were collected
+}
+
+// walk collects the dependencies of p, whose path from the root (as id@version) is path.
+func (r *Resolver) walk(ctx context.Context, p *core.Prompt, path []string, res *resolution) error {
+	from := path[len(path)-1]
+	deps, err := core.DependenciesOf(p)
+	if err != nil {
+		return fmt.Errorf("registry: %s: %w", from, err)
+	}
+	for _, d := range deps {
+		var dp *core.Prompt
+		if d.Version == "" {
+			dp, err = r.reg.GetProduction(ctx, d.ID)
+		} else {
+			dp, err = r.reg.Get(ctx, d.ID, d.Version)
+		}
+		if err != nil {
+			return fmt.Errorf("registry: %s dependency %s: %w", from, d.ID, err)
+		}
+		key := dp.ID + "@" + dp.Version
+		if delims, err := r.delimsOf(dp); err != nil {
+			return fmt.Errorf("registry: %s dependency %s: %w", from, key, err)
+		} else if delims != res.delims {
+			return fmt.Errorf("registry: %s dependency %s uses delimiters %s %s, not %s %s", from, key, delims.Left, delims.Right, res.delims.Left, res.delims.Right)
+		}
+		for _, k := range path {
+			if k == key {
+				return fmt.Errorf("%w: %s -> %s", ErrDependencyCycle, strings.Join(path, " -> "), key)
+			}
+		}
+		name := d.TemplateName()
+		if other, ok := res.names[name]; ok && other != key {
+			return fmt.Errorf("registry: %s dependency template %q is both %s and %s", from, name, other, key)
+		}
+		if !res.walked[key] {
+			if err := r.walk(ctx, dp, append(path[:len(path):len(path)], key), res); err != nil {
+				return err
+			}
+			res.walked[key] = true
+			res.vars = append(res.vars, dp.Variables...)
+		}
+		if _, ok := res.names[name]; !ok {
+			res.names[name] = key
+			left, right := res.delims.Left, res.delims.Right
+			fmt.Fprintf(&res.defs, "%sdefine %q%s%s%send%s", left, name, right, dp.Template, left, right)
+			res.deps = append(res.deps, core.Dependency{ID: dp.ID, Version: dp.Version, Name: name})
+		}
+	}
+	return nil
+}
+
+// delimsOf returns p's template delimiters, or the resolver's if it has none.
+func (r *Resolver) delimsOf(p *core.Prompt) (core.Delims, error) {
+	delims, err := core.DelimsOf(p)
+	if err != nil || delims.Left != "" {
+		return delims, err
+	}
+	return core.Delims{Left: r.leftDelim, Right: r.rightDelim}, nil
+}
@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withDeps(p *core.Prompt, deps ...core.Dependency) *core.Prompt {
+	p.Metadata = map[string]interface{}{core.DependenciesKey: core.DependencyMetadata(deps)}
+	return p
+}
+
+func TestResolver(t *testing.T) {
+	ctx := context.Background()
+	reg := NewMemoryRegistry()
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "tone", Version: "1.0.0", Template: "Be brief."}))
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "tone", Version: "2.0.0", Template: "Be brief and kind."}))
+	require.NoError(t, reg.Promote(ctx, "tone", "2.0.0", StageProduction))
+	require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "preamble", Version: "1.0.0",
+		Template:  `You support {{.product}}. {{template "tone" .}}`,
+		Variables: []core.Variable{{Name: "product", Type: core.VariableTypeString, Required: true}}},
+		core.Dependency{ID: "tone", Version: "1.0.0"})))
+	require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "answer", Version: "1.0.0",
+		System:    `{{template "intro" .}}`,
+		Template:  `{{.question}} ({{template "tone" .}})`,
+		Variables: []core.Variable{{Name: "question", Type: core.VariableTypeString, Required: true}}},
+		core.Dependency{ID: "preamble", Version: "1.0.0", Name: "intro"}, core.Dependency{ID: "tone", Version: "1.0.0"})))
+
+	res, err := NewResolver(reg).GetResolved(ctx, "answer", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, []core.Dependency{
+		{ID: "tone", Version: "1.0.0", Name: "tone"},
+		{ID: "preamble", Version: "1.0.0", Name: "intro"},
+	}, res.Dependencies)
+	assert.NotContains(t, res.Prompt.Metadata, core.DependenciesKey)
+	assert.Len(t, res.Prompt.Variables, 2)
+
+	res.Prompt.SetRenderer(template.NewEngine())
+	_, err = res.Prompt.Render(ctx, core.Input{"question": "Why?"})
+	assert.ErrorIs(t, err, core.ErrValidationFailed, "dependency variables are required")
+	out, err := res.Prompt.Render(ctx, core.Input{"question": "Why?", "product": "loom"})
+	require.NoError(t, err)
+	assert.Equal(t, "You support loom. Be brief.", out.System)
+	assert.Equal(t, "Why? (Be brief.)", out.User)
+
+	t.Run("production", func(t *testing.T) {
+		require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "short", Version: "1.0.0", Template: `{{template "tone" .}}`},
+			core.Dependency{ID: "tone"})))
+		res, err := NewResolver(reg).GetResolved(ctx, "short", "1.0.0")
+		require.NoError(t, err)
+		assert.Equal(t, "2.0.0", res.Dependencies[0].Version)
+	})
+
+	t.Run("delims", func(t *testing.T) {
+		require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "angle", Version: "1.0.0", Template: `<<template "tone" .>>!`},
+			core.Dependency{ID: "tone", Version: "1.0.0"})))
+		res, err := NewResolver(reg, WithResolverDelims("<<", ">>")).GetResolved(ctx, "angle", "1.0.0")
+		require.NoError(t, err)
+		res.Prompt.SetRenderer(template.NewEngine(template.WithDelims("<<", ">>")))
+		out, err := res.Prompt.Render(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Be brief.!", out.User)
+	})
+
+	t.Run("prompt delims", func(t *testing.T) {
+		brackets := core.Delims{Left: "[[", Right: "]]"}.Metadata()
+		require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "json-tone", Version: "1.0.0", Template: `Reply with {"tone": "[[.tone]]"}.`,
+			Metadata: map[string]interface{}{core.DelimsKey: brackets}}))
+		p := withDeps(&core.Prompt{ID: "json-answer", Version: "1.0.0", Template: `[[template "json-tone" .]]`}, core.Dependency{ID: "json-tone", Version: "1.0.0"})
+		p.Metadata[core.DelimsKey] = brackets
+		require.NoError(t, reg.Store(ctx, p))
+		res, err := NewResolver(reg).GetResolved(ctx, "json-answer", "1.0.0")
+		require.NoError(t, err)
+		res.Prompt.SetRenderer(template.NewEngine())
+		out, err := res.Prompt.Render(ctx, core.Input{"tone": "warm"})
+		require.NoError(t, err)
+		assert.Equal(t, `Reply with {"tone": "warm"}.`, out.User)
+
+		require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "plain", Version: "1.0.0", Template: `{{template "json-tone" .}}`},
+			core.Dependency{ID: "json-tone", Version: "1.0.0"})))
+		_, err = NewResolver(reg).GetResolved(ctx, "plain", "1.0.0")
+		assert.ErrorContains(t, err, "dependency json-tone@1.0.0 uses delimiters [[ ]], not {{ }}")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "a", Version: "1.0.0", Template: "a"}, core.Dependency{ID: "b", Version: "1.0.0"})))
+		require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "b", Version: "1.0.0", Template: "b"}, core.Dependency{ID: "a", Version: "1.0.0"})))
+		_, err := NewResolver(reg).GetResolved(ctx, "a", "1.0.0")
+		assert.ErrorIs(t, err, ErrDependencyCycle)
+		assert.ErrorContains(t, err, "a@1.0.0 -> b@1.0.0 -> a@1.0.0")
+
+		require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "c", Version: "1.0.0", Template: "c"}, core.Dependency{ID: "missing"})))
+		_, err = NewResolver(reg).GetResolved(ctx, "c", "1.0.0")
+		assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+		require.NoError(t, reg.Store(ctx, withDeps(&core.Prompt{ID: "d", Version: "1.0.0", Template: "d"},
+			core.Dependency{ID: "tone", Version: "1.0.0", Name: "x"}, core.Dependency{ID: "tone", Version: "2.0.0", Name: "x"})))
+		_, err = NewResolver(reg).GetResolved(ctx, "d", "1.0.0")
+		assert.ErrorContains(t, err, `template "x" is both tone@1.0.0 and tone@2.0.0`)
+	})
+}
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrNoRollbackTarget is returned by Rollback and PreviousProduction when the production history
+// has no earlier version of the prompt that still exists.
+var ErrNoRollbackTarget = errors.New("registry: no earlier production version to roll back to")
+
+// ProductionRollbacker is implemented by registries that can roll production back atomically:
+// the check that production has not moved and the promotion are one step of the backend (a lock,
+// transaction, or conditional write), so a promotion made meanwhile is never overwritten.
+type ProductionRollbacker interface {
+	// RollbackProduction promotes version of id to production if from is still its production
+	// version, and records an AuditRollback entry; otherwise it returns core.ErrConflict.
+	RollbackProduction(ctx context.Context, id, from, version string) error
+}
+
+// RollbackProduction rolls id's production back from from to version via reg's
+// RollbackProduction. It returns an error if reg does not implement ProductionRollbacker rather
+// than falling back to a Promote that could overwrite a concurrent promotion.
+func RollbackProduction(ctx context.Context, reg Registry, id, from, version string) error {
+	rb, ok := reg.(ProductionRollbacker)
+	if !ok {
+		return fmt.Errorf("registry: %T does not support atomic rollback", reg)
+	}
+	return rb.RollbackProduction(ctx, id, from, version)
+}
+
+// Promotions returns the audit entries for id's promotions to production, including rollbacks,
+// newest first. reg must implement Auditor.
+func Promotions(ctx context.Context, reg Registry, id string) ([]AuditEntry, error) {
+	entries, err := History(ctx, reg, id)
+	if err != nil {
+		return nil, err
+	}
+	var out []AuditEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; (e.Action == AuditPromote || e.Action == AuditRollback) && e.Stage == StageProduction {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// ProductionHistory returns id's production history as a stack, oldest first, from the audit log:
+// each promotion to production pushes its version and each rollback pops the versions above the
+// one it went back to. The last element is normally the current production version, so repeated
+// rollbacks walk back through earlier versions rather than alternating between the last two.
+func ProductionHistory(ctx context.Context, reg Registry, id string) ([]string, error) {
+	promotions, err := Promotions(ctx, reg, id)
+	if err != nil {
+		return nil, err
+	}
+	var stack []string
+	for i := len(promotions) - 1; i >= 0; i-- {
+		e := promotions[i]
+		if e.Action == AuditRollback && len(stack) > 0 {
+			// Pop the version rolled back from and any skipped (deleted or archived) ones.
+			stack = stack[:len(stack)-1]
+			for len(stack) > 0 && stack[len(stack)-1] != e.Version {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		if len(stack) == 0 || stack[len(stack)-1] != e.Version {
+			stack = append(stack, e.Version)
+		}
+	}
+	return stack, nil
+}
+
+// PreviousProduction returns the version below id's current production version in its production
+// history (see ProductionHistory), the one Rollback goes back to. Versions that have since been
+// deleted or archived are skipped.
+func PreviousProduction(ctx context.Context, reg Registry, id string) (string, error) {
+	_, version, err := previousProduction(ctx, reg, id)
+	return version, err
+}
+
+// previousProduction returns id's current production version and PreviousProduction.
+func previousProduction(ctx context.Context, reg Registry, id string) (current, previous string, err error) {
+	p, err := reg.GetProduction(ctx, id)
+	if err != nil {
+		return "", "", err
+	}
+	stack, err := ProductionHistory(ctx, reg, id)
+	if err != nil {
+		return "", "", err
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == p.Version {
+			continue
+		}
+		if _, err := reg.Get(ctx, id, stack[i]); err != nil {
+			if errors.Is(err, core.ErrPromptNotFound) {
+				continue
+			}
+			return "", "", err
+		}
+		return p.Version, stack[i], nil
+	}
+	return "", "", fmt.Errorf("%w: %s", ErrNoRollbackTarget, id)
+}
+
+// Rollback returns id's production to the previous version in its production history (see
+// PreviousProduction) and returns it; calling it again goes back another version. The switch is
+// atomic (see ProductionRollbacker, which reg must implement): if production moved since it was
+// read, Rollback fails with core.ErrConflict and changes nothing. The rollback is audited as an
+// AuditRollback entry attributed to the context's actor.
+func Rollback(ctx context.Context, reg Registry, id string) (string, error) {
+	current, version, err := previousProduction(ctx, reg, id)
+	if err != nil {
+		return "", err
+	}
+	if err := RollbackProduction(ctx, reg, id, current, version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
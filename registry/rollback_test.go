@@ -0,0 +1,129 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/klejdi94/loom/core"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	for name, reg := range map[string]Registry{"memory": NewMemoryRegistry(), "file": fr} {
+		t.Run(name, func(t *testing.T) {
+			for _, v := range []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"} {
+				require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: v, Template: v}))
+			}
+			_, err := Rollback(ctx, reg, "p")
+			assert.ErrorIs(t, err, core.ErrPromptNotFound, "nothing in production")
+
+			require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+			_, err = Rollback(ctx, reg, "p")
+			assert.ErrorIs(t, err, ErrNoRollbackTarget)
+
+			require.NoError(t, reg.Promote(ctx, "p", "1.1.0", StageProduction))
+			require.NoError(t, reg.Promote(ctx, "p", "1.2.0", StageStaging))
+			require.NoError(t, reg.Promote(ctx, "p", "1.2.0", StageProduction))
+			require.NoError(t, reg.Archive(ctx, "p", "1.1.0"))
+			require.NoError(t, reg.Promote(ctx, "p", "2.0.0", StageProduction))
+
+			promotions, err := Promotions(ctx, reg, "p")
+			require.NoError(t, err)
+			require.Len(t, promotions, 4)
+			assert.Equal(t, "2.0.0", promotions[0].Version, "newest first")
+
+			v, err := Rollback(WithActor(ctx, "bot"), reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "1.2.0", v)
+			p, err := reg.GetProduction(ctx, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "1.2.0", p.Version)
+
+			// With 2.0.0 and 1.1.0 archived, the next rollback skips to 1.0.0.
+			require.NoError(t, reg.Archive(ctx, "p", "2.0.0"))
+			v, err = Rollback(ctx, reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "1.0.0", v)
+			entries, err := History(ctx, reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "bot", entries[len(entries)-3].Actor)
+		})
+	}
+}
+
+// rollbackRegistries returns a fresh registry of each backend that implements ProductionRollbacker
+// without external services.
+func rollbackRegistries(t *testing.T) map[string]Registry {
+	fr, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	return map[string]Registry{"memory": NewMemoryRegistry(), "file": fr, "redis": NewRedisRegistry(client, "loom:")}
+}
+
+func TestRollback_Repeated(t *testing.T) {
+	ctx := context.Background()
+	for name, reg := range rollbackRegistries(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, v := range []string{"1.0.0", "2.0.0", "3.0.0"} {
+				require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: v, Template: v}))
+				require.NoError(t, reg.Promote(ctx, "p", v, StageProduction))
+			}
+
+			for _, want := range []string{"2.0.0", "1.0.0"} {
+				v, err := Rollback(ctx, reg, "p")
+				require.NoError(t, err)
+				assert.Equal(t, want, v, "each rollback goes back one more version")
+				p, err := reg.GetProduction(ctx, "p")
+				require.NoError(t, err)
+				assert.Equal(t, want, p.Version)
+			}
+			_, err := Rollback(ctx, reg, "p")
+			assert.ErrorIs(t, err, ErrNoRollbackTarget)
+			stack, err := ProductionHistory(ctx, reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"1.0.0"}, stack)
+
+			// A promotion after rollbacks starts a new history on top of what is left.
+			require.NoError(t, reg.Promote(ctx, "p", "3.0.0", StageProduction))
+			v, err := Rollback(ctx, reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "1.0.0", v)
+		})
+	}
+}
+
+func TestRollback_ConcurrentPromote(t *testing.T) {
+	ctx := context.Background()
+	for name, reg := range rollbackRegistries(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, v := range []string{"1.0.0", "2.0.0", "3.0.0"} {
+				require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "p", Version: v, Template: v}))
+			}
+			require.NoError(t, reg.Promote(ctx, "p", "1.0.0", StageProduction))
+			require.NoError(t, reg.Promote(ctx, "p", "2.0.0", StageProduction))
+			target, err := PreviousProduction(ctx, reg, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "1.0.0", target)
+
+			// 3.0.0 is promoted between reading production and rolling back from it.
+			require.NoError(t, reg.Promote(ctx, "p", "3.0.0", StageProduction))
+			err = RollbackProduction(ctx, reg, "p", "2.0.0", target)
+			assert.ErrorIs(t, err, core.ErrConflict)
+			p, err := reg.GetProduction(ctx, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "3.0.0", p.Version, "the newer promotion is kept")
+
+			assert.ErrorIs(t, RollbackProduction(ctx, reg, "p", "3.0.0", "9.9.9"), core.ErrPromptNotFound)
+			require.NoError(t, RollbackProduction(ctx, reg, "p", "3.0.0", "2.0.0"))
+			p, err = reg.GetProduction(ctx, "p")
+			require.NoError(t, err)
+			assert.Equal(t, "2.0.0", p.Version)
+		})
+	}
+}
@@ -4,8 +4,12 @@ package registry
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/klejdi94/loom/core"
 )
@@ -18,19 +22,22 @@ type BlobStore interface {
 	Delete(ctx context.Context, key string) error
 }
 
-// S3Registry stores prompts using a BlobStore. Keys: prefix/prompt/id/version.json, prefix/meta/id/version.json, prefix/production/id.txt.
+// S3Registry stores prompts using a BlobStore. Keys: prefix/prompt/id/version.json, prefix/meta/id/version.json, prefix/production/id.txt,
+// prefix/audit/id/{unix nanos}-{action}.json (one AuditEntry per object), prefix/alias/id/alias.txt (version).
+// Prompt objects are JSON unless another codec is set with WithCodec; the key names stay the same.
 type S3Registry struct {
 	store  BlobStore
 	prefix string
+	storage
 }
 
 // NewS3Registry creates a registry using the given BlobStore (e.g. from registry/s3blob) and key prefix.
-func NewS3Registry(store BlobStore, prefix string) *S3Registry {
+func NewS3Registry(store BlobStore, prefix string, opts ...StorageOption) *S3Registry {
 	prefix = strings.Trim(prefix, "/")
 	if prefix != "" {
 		prefix += "/"
 	}
-	return &S3Registry{store: store, prefix: prefix}
+	return &S3Registry{store: store, prefix: prefix, storage: newStorage(JSONCodec, opts)}
 }
 
 func (s *S3Registry) promptKey(id, version string) string {
@@ -42,41 +49,83 @@ func (s *S3Registry) metaKey(id, version string) string {
 func (s *S3Registry) productionKey(id string) string {
 	return s.prefix + "production/" + id + ".txt"
 }
+func (s *S3Registry) aliasKey(id, alias string) string {
+	return s.prefix + "alias/" + id + "/" + alias + ".txt"
+}
+func (s *S3Registry) auditKey(e AuditEntry) string {
+	return fmt.Sprintf("%saudit/%s/%020d-%s.json", s.prefix, e.ID, e.Time.UnixNano(), e.Action)
+}
 
-// Store saves a prompt to the blob store.
+// Store saves a prompt to the blob store. Overwriting a version keeps its stage, tags, and archived
+// flag. The revision is incremented on a best-effort basis; BlobStore has no conditional writes,
+// so S3Registry does not implement ConditionalStorer.
 func (s *S3Registry) Store(ctx context.Context, prompt *core.Prompt) error {
 	if prompt == nil || prompt.ID == "" || prompt.Version == "" {
 		return fmt.Errorf("s3 registry: prompt id and version required")
 	}
-	data, err := json.Marshal(prompt)
+	var current int64
+	if old, err := s.read(ctx, prompt.ID, prompt.Version); err == nil {
+		current = old.Revision
+	}
+	prompt.Revision = current + 1
+	data, err := encodePrompt(s.codec, prompt)
 	if err != nil {
 		return err
 	}
 	if err := s.store.Put(ctx, s.promptKey(prompt.ID, prompt.Version), data); err != nil {
 		return err
 	}
+	// Overwriting a version keeps its stage, tags, and archived flag.
 	meta := struct {
 		Stage     string   `json:"stage"`
 		Tags      []string `json:"tags"`
 		CreatedAt string   `json:"created_at"`
 		UpdatedAt string   `json:"updated_at"`
-	}{
-		Stage:     "dev",
-		CreatedAt: prompt.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt: prompt.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Archived  bool     `json:"archived,omitempty"`
+		Checksum  string   `json:"checksum,omitempty"`
+	}{Stage: string(StageDev)}
+	if current > 0 {
+		if old, err := s.store.Get(ctx, s.metaKey(prompt.ID, prompt.Version)); err == nil {
+			_ = json.Unmarshal(old, &meta)
+		}
 	}
+	meta.CreatedAt = prompt.CreatedAt.Format(time.RFC3339Nano)
+	meta.UpdatedAt = prompt.UpdatedAt.Format(time.RFC3339Nano)
+	meta.Checksum = Checksum(prompt)
 	metaData, _ := json.Marshal(meta)
-	return s.store.Put(ctx, s.metaKey(prompt.ID, prompt.Version), metaData)
+	if err := s.store.Put(ctx, s.metaKey(prompt.ID, prompt.Version), metaData); err != nil {
+		return err
+	}
+	e := NewAuditEntry(ctx, AuditStore, prompt.ID, prompt.Version)
+	e.Revision = prompt.Revision
+	return s.record(ctx, e)
 }
 
-// Get retrieves a prompt by id and version.
+// Get retrieves a prompt by id and version (or "@alias"). Archived versions are reported as not found.
 func (s *S3Registry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	if alias, ok := ParseAlias(version); ok {
+		data, _ := s.store.Get(ctx, s.aliasKey(id, alias))
+		return GetByAlias(ctx, s, id, alias, strings.TrimSpace(string(data)))
+	}
+	if metaData, err := s.store.Get(ctx, s.metaKey(id, version)); err == nil {
+		var meta struct {
+			Archived bool `json:"archived"`
+		}
+		if json.Unmarshal(metaData, &meta) == nil && meta.Archived {
+			return nil, core.ErrPromptNotFound
+		}
+	}
+	return s.read(ctx, id, version)
+}
+
+// read retrieves a prompt regardless of its archived state.
+func (s *S3Registry) read(ctx context.Context, id, version string) (*core.Prompt, error) {
 	data, err := s.store.Get(ctx, s.promptKey(id, version))
 	if err != nil {
 		return nil, core.ErrPromptNotFound
 	}
 	var p core.Prompt
-	if err := json.Unmarshal(data, &p); err != nil {
+	if err := decodePrompt(data, &p); err != nil {
 		return nil, err
 	}
 	return p.Copy(), nil
@@ -95,18 +144,21 @@ func (s *S3Registry) GetProduction(ctx context.Context, id string) (*core.Prompt
 	return s.Get(ctx, id, version)
 }
 
-// List returns prompts matching the filter by listing the prompt prefix.
+// List returns prompts matching the filter by listing the prompt prefix. Versions are selected
+// and sorted by their meta objects; prompt bodies are read only for the requested page (and, when
+// searching, for the candidates examined). With SortByID and a Cursor, keys before the cursor are
+// skipped without reading anything.
 func (s *S3Registry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
-	keys, err := s.store.List(ctx, s.prefix+"prompt/")
+	after, hasCursor, err := filter.cursorKey()
 	if err != nil {
 		return nil, err
 	}
-	limit := filter.Limit
-	if limit <= 0 {
-		limit = 1000
+	keys, err := s.store.List(ctx, s.prefix+"prompt/")
+	if err != nil {
+		return nil, err
 	}
-	var out []*core.Prompt
-	offset := filter.Offset
+	byID := filter.SortBy == "" || filter.SortBy == SortByID
+	var entries []ListEntry
 	seen := make(map[string]bool)
 	for _, key := range keys {
 		if !strings.HasSuffix(key, ".json") {
@@ -125,34 +177,49 @@ func (s *S3Registry) List(ctx context.Context, filter Filter) ([]*core.Prompt, e
 		if len(filter.IDs) > 0 && !contains(filter.IDs, id) {
 			continue
 		}
+		if byID && hasCursor && filter.compareKeys(listKey{id: id, version: ver}, after) <= 0 {
+			continue
+		}
+		e := ListEntry{ID: id, Version: ver}
 		metaData, err := s.store.Get(ctx, s.metaKey(id, ver))
 		if err == nil {
 			var meta struct {
-				Stage string   `json:"stage"`
-				Tags  []string `json:"tags"`
+				Stage     string   `json:"stage"`
+				Tags      []string `json:"tags"`
+				CreatedAt string   `json:"created_at"`
+				UpdatedAt string   `json:"updated_at"`
+				Archived  bool     `json:"archived"`
 			}
 			_ = json.Unmarshal(metaData, &meta)
+			if meta.Archived && !filter.IncludeArchived {
+				continue
+			}
 			if filter.Stage != "" && Stage(meta.Stage) != filter.Stage {
 				continue
 			}
 			if len(filter.Tags) > 0 && !hasAll(meta.Tags, filter.Tags) {
 				continue
 			}
+			e.CreatedAt, _ = time.Parse(time.RFC3339Nano, meta.CreatedAt)
+			e.UpdatedAt, _ = time.Parse(time.RFC3339Nano, meta.UpdatedAt)
 		}
-		if offset > 0 {
-			offset--
-			continue
-		}
-		p, err := s.Get(ctx, id, ver)
-		if err != nil {
-			continue
-		}
-		out = append(out, p)
-		if len(out) >= limit {
-			return out, nil
+		// Meta written by older versions has whole seconds; sort by the body's exact times then.
+		if !byID && (err != nil || e.CreatedAt.Nanosecond() == 0 || e.UpdatedAt.Nanosecond() == 0) {
+			p, err := s.read(ctx, id, ver)
+			if err != nil {
+				continue
+			}
+			e.CreatedAt, e.UpdatedAt, e.Prompt = p.CreatedAt, p.UpdatedAt, p
 		}
+		entries = append(entries, e)
 	}
-	return out, nil
+	return filter.Page(entries, func(batch []ListEntry) ([]*core.Prompt, error) {
+		out := make([]*core.Prompt, len(batch))
+		for i, e := range batch {
+			out[i], _ = s.read(ctx, e.ID, e.Version)
+		}
+		return out, nil
+	})
 }
 
 // ListVersions returns version info for an id.
@@ -168,7 +235,7 @@ func (s *S3Registry) ListVersions(ctx context.Context, id string) ([]VersionInfo
 		}
 		suffix := strings.TrimPrefix(key, s.prefix+"prompt/"+id+"/")
 		ver := strings.TrimSuffix(suffix, ".json")
-		p, err := s.Get(ctx, id, ver)
+		p, err := s.read(ctx, id, ver)
 		if err != nil {
 			continue
 		}
@@ -176,15 +243,20 @@ func (s *S3Registry) ListVersions(ctx context.Context, id string) ([]VersionInfo
 		metaData, _ := s.store.Get(ctx, s.metaKey(id, ver))
 		if len(metaData) > 0 {
 			var meta struct {
-				Stage string   `json:"stage"`
-				Tags  []string `json:"tags"`
+				Stage    string   `json:"stage"`
+				Tags     []string `json:"tags"`
+				Archived bool     `json:"archived"`
+				Checksum string   `json:"checksum"`
 			}
 			_ = json.Unmarshal(metaData, &meta)
 			vi.Stage = Stage(meta.Stage)
 			vi.Tags = meta.Tags
+			vi.Archived = meta.Archived
+			vi.Checksum = meta.Checksum
 		}
 		infos = append(infos, vi)
 	}
+	sortVersionInfos(infos)
 	return infos, nil
 }
 
@@ -200,6 +272,8 @@ func (s *S3Registry) Promote(ctx context.Context, id, version string, stage Stag
 		Tags      []string `json:"tags"`
 		CreatedAt string   `json:"created_at"`
 		UpdatedAt string   `json:"updated_at"`
+		Archived  bool     `json:"archived,omitempty"`
+		Checksum  string   `json:"checksum,omitempty"`
 	}
 	if len(metaData) > 0 {
 		_ = json.Unmarshal(metaData, &meta)
@@ -210,9 +284,13 @@ func (s *S3Registry) Promote(ctx context.Context, id, version string, stage Stag
 		return err
 	}
 	if stage == StageProduction {
-		return s.store.Put(ctx, s.productionKey(id), []byte(version))
+		if err := s.store.Put(ctx, s.productionKey(id), []byte(version)); err != nil {
+			return err
+		}
 	}
-	return nil
+	e := NewAuditEntry(ctx, AuditPromote, id, version)
+	e.Stage = stage
+	return s.record(ctx, e)
 }
 
 // Delete removes a prompt version.
@@ -227,7 +305,111 @@ func (s *S3Registry) Delete(ctx context.Context, id, version string) error {
 	if string(prod) == version {
 		_ = s.store.Delete(ctx, s.productionKey(id))
 	}
-	return nil
+	return s.record(ctx, NewAuditEntry(ctx, AuditDelete, id, version))
+}
+
+// BlobBatchDeleter is implemented by BlobStores that delete many keys per request (e.g. S3
+// DeleteObjects); S3Registry.DeleteBatch uses it when available.
+type BlobBatchDeleter interface {
+	DeleteMany(ctx context.Context, keys []string) error
+}
+
+// s3Parallelism bounds the concurrent BlobStore requests of batch operations.
+const s3Parallelism = 16
+
+// parallel calls fn for 0..n-1 with up to s3Parallelism calls at once and returns the first error.
+func parallel(n int, fn func(i int) error) error {
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	sem := make(chan struct{}, s3Parallelism)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			if err := fn(i); err != nil {
+				once.Do(func() { first = err })
+			}
+		}(i)
+	}
+	wg.Wait()
+	return first
+}
+
+// StoreBatch implements Batcher by storing the prompts concurrently. A failed batch may be
+// partially stored.
+func (s *S3Registry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	return parallel(len(prompts), func(i int) error {
+		p := prompts[i]
+		if err := s.Store(ctx, p); err != nil {
+			return fmt.Errorf("store %s@%s: %w", p.ID, p.Version, err)
+		}
+		return nil
+	})
+}
+
+// DeleteBatch implements Batcher. Nothing is deleted if one of the versions does not exist; the
+// objects are removed with one DeleteMany per batch if the BlobStore implements BlobBatchDeleter.
+func (s *S3Registry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	err := parallel(len(refs), func(i int) error {
+		if _, err := s.store.Get(ctx, s.promptKey(refs[i].ID, refs[i].Version)); err != nil {
+			return fmt.Errorf("%w: %s@%s", core.ErrPromptNotFound, refs[i].ID, refs[i].Version)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	deleted := make(map[VersionRef]bool, len(refs))
+	keys := make([]string, 0, 2*len(refs))
+	for _, ref := range refs {
+		deleted[ref] = true
+		keys = append(keys, s.promptKey(ref.ID, ref.Version), s.metaKey(ref.ID, ref.Version))
+	}
+	for _, id := range refIDs(refs) {
+		if prod, _ := s.store.Get(ctx, s.productionKey(id)); deleted[VersionRef{ID: id, Version: string(prod)}] {
+			keys = append(keys, s.productionKey(id))
+		}
+	}
+	if bd, ok := s.store.(BlobBatchDeleter); ok {
+		if err := bd.DeleteMany(ctx, keys); err != nil {
+			return err
+		}
+	} else {
+		_ = parallel(len(keys), func(i int) error { return s.store.Delete(ctx, keys[i]) })
+	}
+	return parallel(len(refs), func(i int) error {
+		return s.record(ctx, NewAuditEntry(ctx, AuditDelete, refs[i].ID, refs[i].Version))
+	})
+}
+
+// GetMany implements Batcher by reading the versions concurrently.
+func (s *S3Registry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	out := make([]*core.Prompt, len(refs))
+	err := parallel(len(refs), func(i int) error {
+		if _, ok := ParseAlias(refs[i].Version); ok {
+			return nil
+		}
+		p, err := s.Get(ctx, refs[i].ID, refs[i].Version)
+		if errors.Is(err, core.ErrPromptNotFound) {
+			return nil
+		}
+		out[i] = p
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 // Tag updates meta with new tags.
@@ -242,11 +424,128 @@ func (s *S3Registry) Tag(ctx context.Context, id, version string, tags []string)
 		Tags      []string `json:"tags"`
 		CreatedAt string   `json:"created_at"`
 		UpdatedAt string   `json:"updated_at"`
+		Archived  bool     `json:"archived,omitempty"`
+		Checksum  string   `json:"checksum,omitempty"`
 	}
 	if len(metaData) > 0 {
 		_ = json.Unmarshal(metaData, &meta)
 	}
 	meta.Tags = append([]string(nil), tags...)
 	newMeta, _ := json.Marshal(meta)
-	return s.store.Put(ctx, s.metaKey(id, version), newMeta)
+	if err := s.store.Put(ctx, s.metaKey(id, version), newMeta); err != nil {
+		return err
+	}
+	e := NewAuditEntry(ctx, AuditTag, id, version)
+	e.Tags = append([]string(nil), tags...)
+	return s.record(ctx, e)
+}
+
+// Archive hides a prompt version from Get, GetProduction, and List until it is restored.
+func (s *S3Registry) Archive(ctx context.Context, id, version string) error {
+	return s.setArchived(ctx, id, version, true)
+}
+
+// Restore makes an archived prompt version visible again.
+func (s *S3Registry) Restore(ctx context.Context, id, version string) error {
+	return s.setArchived(ctx, id, version, false)
+}
+
+func (s *S3Registry) setArchived(ctx context.Context, id, version string, archived bool) error {
+	_, err := s.store.Get(ctx, s.promptKey(id, version))
+	if err != nil {
+		return core.ErrPromptNotFound
+	}
+	metaData, _ := s.store.Get(ctx, s.metaKey(id, version))
+	var meta struct {
+		Stage     string   `json:"stage"`
+		Tags      []string `json:"tags"`
+		CreatedAt string   `json:"created_at"`
+		UpdatedAt string   `json:"updated_at"`
+		Archived  bool     `json:"archived,omitempty"`
+		Checksum  string   `json:"checksum,omitempty"`
+	}
+	if len(metaData) > 0 {
+		_ = json.Unmarshal(metaData, &meta)
+	}
+	meta.Archived = archived
+	newMeta, _ := json.Marshal(meta)
+	if err := s.store.Put(ctx, s.metaKey(id, version), newMeta); err != nil {
+		return err
+	}
+	action := AuditArchive
+	if !archived {
+		action = AuditRestore
+	}
+	return s.record(ctx, NewAuditEntry(ctx, action, id, version))
+}
+
+// SetAlias implements Aliaser.
+func (s *S3Registry) SetAlias(ctx context.Context, id, alias, version string) error {
+	if err := ValidateAlias(alias); err != nil {
+		return err
+	}
+	var err error
+	if version == "" {
+		err = s.store.Delete(ctx, s.aliasKey(id, alias))
+	} else {
+		if _, err := s.store.Get(ctx, s.promptKey(id, version)); err != nil {
+			return core.ErrPromptNotFound
+		}
+		err = s.store.Put(ctx, s.aliasKey(id, alias), []byte(version))
+	}
+	if err != nil {
+		return err
+	}
+	e := NewAuditEntry(ctx, AuditAlias, id, version)
+	e.Alias = alias
+	return s.record(ctx, e)
+}
+
+// Aliases implements Aliaser by listing the id's alias objects.
+func (s *S3Registry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	prefix := s.prefix + "alias/" + id + "/"
+	keys, err := s.store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(keys))
+	for _, key := range keys {
+		data, err := s.store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		out[strings.TrimSuffix(strings.TrimPrefix(key, prefix), ".txt")] = strings.TrimSpace(string(data))
+	}
+	return out, nil
+}
+
+// record writes e as its own object under the id's audit prefix.
+func (s *S3Registry) record(ctx context.Context, e AuditEntry) error {
+	data, _ := json.Marshal(e)
+	if err := s.store.Put(ctx, s.auditKey(e), data); err != nil {
+		return fmt.Errorf("s3 registry audit: %w", err)
+	}
+	return nil
+}
+
+// History implements Auditor by listing the id's audit objects in key (time) order.
+func (s *S3Registry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	keys, err := s.store.List(ctx, s.prefix+"audit/"+id+"/")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	var out []AuditEntry
+	for _, key := range keys {
+		data, err := s.store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		var e AuditEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("s3 registry audit decode: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, nil
 }
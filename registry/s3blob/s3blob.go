@@ -5,11 +5,13 @@ package s3blob
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/klejdi94/loom/registry"
 )
 
@@ -100,5 +102,34 @@ func (s *Store) Delete(ctx context.Context, key string) error {
 	return err
 }
 
+// deleteObjectsLimit is the maximum number of keys per DeleteObjects request.
+const deleteObjectsLimit = 1000
+
+// DeleteMany implements registry.BlobBatchDeleter with DeleteObjects requests of up to 1000 keys.
+func (s *Store) DeleteMany(ctx context.Context, keys []string) error {
+	for start := 0; start < len(keys); start += deleteObjectsLimit {
+		end := min(start+deleteObjectsLimit, len(keys))
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for _, key := range keys[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(s.fullKey(key))})
+		}
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return fmt.Errorf("s3blob: delete %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+	}
+	return nil
+}
+
 // Ensure Store implements registry.BlobStore at compile time.
-var _ registry.BlobStore = (*Store)(nil)
+var (
+	_ registry.BlobStore        = (*Store)(nil)
+	_ registry.BlobBatchDeleter = (*Store)(nil)
+)
@@ -0,0 +1,447 @@
+package registry
+
+import (
+	"context"
+	"errors"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ErrForbidden is returned by ScopedRegistry when the scope does not allow an operation.
+var ErrForbidden = errors.New("forbidden for this scope")
+
+// StageAny matches every stage in a Scope.
+const StageAny Stage = "*"
+
+// Scope limits which stages a caller may read and write, e.g. a production service key
+// (Read: production) or a CI key (Read: dev, staging; Write: dev). Registry servers also confine
+// a key with a Namespace to that namespace (see NamespacedRegistry), and authorize its requests
+// as Principal (see WithAuthz; default: KeyActor(key, "")).
+type Scope struct {
+	Read      []Stage `json:"read"`
+	Write     []Stage `json:"write"`
+	Namespace string  `json:"namespace,omitempty"`
+	Principal string  `json:"principal,omitempty"`
+}
+
+// CanRead reports whether versions in stage may be read.
+func (s Scope) CanRead(stage Stage) bool { return hasStage(s.Read, stage) }
+
+// CanWrite reports whether versions in stage may be stored, promoted, tagged, or deleted.
+func (s Scope) CanWrite(stage Stage) bool { return hasStage(s.Write, stage) }
+
+func hasStage(stages []Stage, stage Stage) bool {
+	for _, st := range stages {
+		if st == stage || st == StageAny {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopedRegistry enforces a Scope on top of another Registry. A version's stage is looked up
+// with ListVersions; new versions are stored in StageDev. Promote requires write access to both
+// the version's current stage and the target stage.
+type ScopedRegistry struct {
+	inner Registry
+	scope Scope
+}
+
+// NewScoped returns inner restricted to scope.
+func NewScoped(inner Registry, scope Scope) *ScopedRegistry {
+	return &ScopedRegistry{inner: inner, scope: scope}
+}
+
+// stageOf returns the stage of id@version, or ok=false if the version does not exist.
+func (s *ScopedRegistry) stageOf(ctx context.Context, id, version string) (stage Stage, ok bool, err error) {
+	infos, err := s.inner.ListVersions(ctx, id)
+	if err != nil {
+		return "", false, err
+	}
+	for _, info := range infos {
+		if info.Version == version {
+			if info.Stage == "" {
+				return StageDev, true, nil
+			}
+			return info.Stage, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// checkWrite verifies write access to id@version's current stage (StageDev if it does not exist yet).
+func (s *ScopedRegistry) checkWrite(ctx context.Context, id, version string) error {
+	stage, ok, err := s.stageOf(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		stage = StageDev
+	}
+	if !s.scope.CanWrite(stage) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Get implements Registry. Missing versions are reported as not found regardless of scope. An
+// alias ("@stable") is resolved first and its version's stage checked.
+func (s *ScopedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	if _, ok := ParseAlias(version); ok {
+		p, err := s.inner.Get(ctx, id, version)
+		if err != nil {
+			return nil, err
+		}
+		if stage, ok, err := s.stageOf(ctx, id, p.Version); err != nil {
+			return nil, err
+		} else if ok && !s.scope.CanRead(stage) {
+			return nil, ErrForbidden
+		}
+		return p, nil
+	}
+	stage, ok, err := s.stageOf(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if ok && !s.scope.CanRead(stage) {
+		return nil, ErrForbidden
+	}
+	return s.inner.Get(ctx, id, version)
+}
+
+// GetProduction implements Registry.
+func (s *ScopedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	if !s.scope.CanRead(StageProduction) {
+		return nil, ErrForbidden
+	}
+	return s.inner.GetProduction(ctx, id)
+}
+
+// List implements Registry. Without a Stage filter, a scope that cannot read every stage only
+// sees versions in its readable stages; Offset and Limit apply to the visible results.
+func (s *ScopedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	if filter.Stage != "" {
+		if !s.scope.CanRead(filter.Stage) {
+			return nil, ErrForbidden
+		}
+		return s.inner.List(ctx, filter)
+	}
+	if hasStage(s.scope.Read, StageAny) {
+		return s.inner.List(ctx, filter)
+	}
+	limit := filter.limit()
+	skip := filter.Offset
+	stages := make(map[string]map[string]Stage) // id -> version -> stage
+	var out []*core.Prompt
+	inner := filter
+	inner.Limit, inner.Offset = 1000, 0
+	err := eachPage(ctx, s.inner, inner, func(prompts []*core.Prompt) (bool, error) {
+		for _, p := range prompts {
+			if stages[p.ID] == nil {
+				infos, err := s.inner.ListVersions(ctx, p.ID)
+				if err != nil {
+					return true, err
+				}
+				stages[p.ID] = make(map[string]Stage, len(infos))
+				for _, info := range infos {
+					stages[p.ID][info.Version] = info.Stage
+				}
+			}
+			st := stages[p.ID][p.Version]
+			if st == "" {
+				st = StageDev
+			}
+			if !s.scope.CanRead(st) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out = append(out, p)
+			if len(out) >= limit {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListVersions implements Registry, returning only versions in readable stages.
+func (s *ScopedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	infos, err := s.inner.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var out []VersionInfo
+	for _, info := range infos {
+		st := info.Stage
+		if st == "" {
+			st = StageDev
+		}
+		if s.scope.CanRead(st) {
+			out = append(out, info)
+		}
+	}
+	return out, nil
+}
+
+// Store implements Registry.
+func (s *ScopedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	if prompt == nil {
+		return s.inner.Store(ctx, prompt)
+	}
+	if err := s.checkWrite(ctx, prompt.ID, prompt.Version); err != nil {
+		return err
+	}
+	return s.inner.Store(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does).
+func (s *ScopedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	if prompt == nil {
+		return StoreIfMatch(ctx, s.inner, prompt, revision)
+	}
+	if err := s.checkWrite(ctx, prompt.ID, prompt.Version); err != nil {
+		return err
+	}
+	return StoreIfMatch(ctx, s.inner, prompt, revision)
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time). Nothing is stored unless the
+// scope may write every version.
+func (s *ScopedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	if err := ValidateBatch(prompts); err != nil {
+		return err
+	}
+	if err := s.checkWriteAll(ctx, promptRefs(prompts)); err != nil {
+		return err
+	}
+	return StoreBatch(ctx, s.inner, prompts)
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time). Nothing is deleted unless
+// the scope may write every version.
+func (s *ScopedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	if err := ValidateRefs(refs); err != nil {
+		return err
+	}
+	if err := s.checkWriteAll(ctx, refs); err != nil {
+		return err
+	}
+	return DeleteBatch(ctx, s.inner, refs)
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time). It returns ErrForbidden if the
+// scope may not read one of the existing versions.
+func (s *ScopedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	stages, err := s.stages(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if st, ok := stages[ref.ID][ref.Version]; ok && !s.scope.CanRead(st) {
+			return nil, ErrForbidden
+		}
+	}
+	return GetMany(ctx, s.inner, refs)
+}
+
+// checkWriteAll verifies write access to the current stage of every ref, like checkWrite.
+func (s *ScopedRegistry) checkWriteAll(ctx context.Context, refs []VersionRef) error {
+	stages, err := s.stages(ctx, refs)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		st, ok := stages[ref.ID][ref.Version]
+		if !ok {
+			st = StageDev
+		}
+		if !s.scope.CanWrite(st) {
+			return ErrForbidden
+		}
+	}
+	return nil
+}
+
+// stages returns the stages of the existing versions of the refs' ids (id -> version -> stage),
+// with one ListVersions per id.
+func (s *ScopedRegistry) stages(ctx context.Context, refs []VersionRef) (map[string]map[string]Stage, error) {
+	out := make(map[string]map[string]Stage)
+	for _, id := range refIDs(refs) {
+		infos, err := s.inner.ListVersions(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		out[id] = make(map[string]Stage, len(infos))
+		for _, info := range infos {
+			st := info.Stage
+			if st == "" {
+				st = StageDev
+			}
+			out[id][info.Version] = st
+		}
+	}
+	return out, nil
+}
+
+// Promote implements Registry.
+func (s *ScopedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	if !s.scope.CanWrite(stage) {
+		return ErrForbidden
+	}
+	if err := s.checkWrite(ctx, id, version); err != nil {
+		return err
+	}
+	return s.inner.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does) for keys that may promote
+// version to production.
+func (s *ScopedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	if !s.scope.CanWrite(StageProduction) {
+		return ErrForbidden
+	}
+	if err := s.checkWrite(ctx, id, version); err != nil {
+		return err
+	}
+	return RollbackProduction(ctx, s.inner, id, from, version)
+}
+
+// Delete implements Registry.
+func (s *ScopedRegistry) Delete(ctx context.Context, id, version string) error {
+	if err := s.checkWrite(ctx, id, version); err != nil {
+		return err
+	}
+	return s.inner.Delete(ctx, id, version)
+}
+
+// Tag implements Registry.
+func (s *ScopedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	if err := s.checkWrite(ctx, id, version); err != nil {
+		return err
+	}
+	return s.inner.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry.
+func (s *ScopedRegistry) Archive(ctx context.Context, id, version string) error {
+	if err := s.checkWrite(ctx, id, version); err != nil {
+		return err
+	}
+	return s.inner.Archive(ctx, id, version)
+}
+
+// Restore implements Registry.
+func (s *ScopedRegistry) Restore(ctx context.Context, id, version string) error {
+	if err := s.checkWrite(ctx, id, version); err != nil {
+		return err
+	}
+	return s.inner.Restore(ctx, id, version)
+}
+
+// SetAlias implements Aliaser (if inner does). It requires write access to the stage of the
+// version the alias points to now (if any) and of the new version.
+func (s *ScopedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	current, err := Aliases(ctx, s.inner, id)
+	if err != nil {
+		return err
+	}
+	if v := current[alias]; v != "" {
+		if err := s.checkWrite(ctx, id, v); err != nil {
+			return err
+		}
+	}
+	if version != "" {
+		if err := s.checkWrite(ctx, id, version); err != nil {
+			return err
+		}
+	}
+	return SetAlias(ctx, s.inner, id, alias, version)
+}
+
+// Aliases implements Aliaser (if inner does), keeping only aliases of versions the scope may read.
+func (s *ScopedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	aliases, err := Aliases(ctx, s.inner, id)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := s.inner.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	stages := make(map[string]Stage, len(infos))
+	for _, info := range infos {
+		stages[info.Version] = info.Stage
+	}
+	for alias, v := range aliases {
+		st := stages[v]
+		if st == "" {
+			st = StageDev
+		}
+		if !s.scope.CanRead(st) {
+			delete(aliases, alias)
+		}
+	}
+	return aliases, nil
+}
+
+// History implements Auditor (if inner does), keeping only entries for versions whose current
+// stage the scope may read. Entries of deleted versions are treated as StageDev.
+func (s *ScopedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	entries, err := History(ctx, s.inner, id)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := s.inner.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	stages := make(map[string]Stage, len(infos))
+	for _, info := range infos {
+		stages[info.Version] = info.Stage
+	}
+	out := entries[:0]
+	for _, e := range entries {
+		st := stages[e.Version]
+		if st == "" {
+			st = StageDev
+		}
+		if s.scope.CanRead(st) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// Usage implements UsageReporter (if inner does; counts are not restricted by the scope).
+func (s *ScopedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, s.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does; counts are not restricted by the scope).
+func (s *ScopedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, s.inner)
+}
+
+// WatchChanges implements ChangeWatcher (if inner does).
+func (s *ScopedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, s.inner, fn)
+}
+
+// Ensure ScopedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*ScopedRegistry)(nil)
+	_ ConditionalStorer    = (*ScopedRegistry)(nil)
+	_ Auditor              = (*ScopedRegistry)(nil)
+	_ UsageReporter        = (*ScopedRegistry)(nil)
+	_ ChangeWatcher        = (*ScopedRegistry)(nil)
+	_ Aliaser              = (*ScopedRegistry)(nil)
+	_ Batcher              = (*ScopedRegistry)(nil)
+	_ ProductionRollbacker = (*ScopedRegistry)(nil)
+)
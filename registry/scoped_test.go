@@ -0,0 +1,65 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopedRegistry(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryRegistry()
+	require.NoError(t, inner.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "prod"}))
+	require.NoError(t, inner.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "dev"}))
+	require.NoError(t, inner.Promote(ctx, "p", "1.0.0", StageProduction))
+
+	service := NewScoped(inner, Scope{Read: []Stage{StageProduction}})
+	_, err := service.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	_, err = service.Get(ctx, "p", "2.0.0")
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = service.Get(ctx, "p", "9.9.9")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+	list, err := service.List(ctx, Filter{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "1.0.0", list[0].Version)
+	infos, err := service.ListVersions(ctx, "p")
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+	assert.ErrorIs(t, service.Store(ctx, &core.Prompt{ID: "p", Version: "3.0.0", Template: "x"}), ErrForbidden)
+
+	ci := NewScoped(inner, Scope{Read: []Stage{StageDev, StageStaging}, Write: []Stage{StageDev}})
+	require.NoError(t, ci.Store(ctx, &core.Prompt{ID: "p", Version: "3.0.0", Template: "ci"}))
+	require.NoError(t, ci.Tag(ctx, "p", "3.0.0", []string{"ci"}))
+	assert.ErrorIs(t, ci.Promote(ctx, "p", "3.0.0", StageProduction), ErrForbidden)
+	assert.ErrorIs(t, ci.Store(ctx, &core.Prompt{ID: "p", Version: "1.0.0", Template: "overwrite prod"}), ErrForbidden)
+	assert.ErrorIs(t, ci.Delete(ctx, "p", "1.0.0"), ErrForbidden)
+	_, err = ci.GetProduction(ctx, "p")
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	admin := NewScoped(inner, Scope{Read: []Stage{StageAny}, Write: []Stage{StageAny}})
+	require.NoError(t, admin.Promote(ctx, "p", "3.0.0", StageProduction))
+
+	require.NoError(t, admin.SetAlias(ctx, "p", "canary", "2.0.0"))
+	_, err = service.Get(ctx, "p", "@canary")
+	assert.ErrorIs(t, err, ErrForbidden, "aliases cannot reach unreadable stages")
+	assert.ErrorIs(t, ci.SetAlias(ctx, "p", "canary", "1.0.0"), ErrForbidden)
+	aliases, err := service.Aliases(ctx, "p")
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+
+	batch := []*core.Prompt{{ID: "p", Version: "4.0.0", Template: "ci"}, {ID: "p", Version: "1.0.0", Template: "overwrite prod"}}
+	assert.ErrorIs(t, ci.StoreBatch(ctx, batch), ErrForbidden)
+	_, err = inner.Get(ctx, "p", "4.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound, "nothing stored from a forbidden batch")
+	_, err = service.GetMany(ctx, []VersionRef{{ID: "p", Version: "1.0.0"}, {ID: "p", Version: "2.0.0"}})
+	assert.ErrorIs(t, err, ErrForbidden)
+	got, err := service.GetMany(ctx, []VersionRef{{ID: "p", Version: "1.0.0"}, {ID: "p", Version: "9.9.9"}})
+	require.NoError(t, err)
+	assert.Equal(t, "prod", got[0].Template)
+	assert.Nil(t, got[1])
+}
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// Searching reports whether the filter has a Query or Metadata condition.
+func (f Filter) Searching() bool {
+	return strings.TrimSpace(f.Query) != "" || len(f.Metadata) > 0
+}
+
+// MatchesSearch reports whether p matches the filter's Query and Metadata. Backends without a
+// native search use it after their id, stage, and tag checks.
+//
+// Every word of Query must occur, ignoring case, in p's name, description, or template. Every
+// Metadata key must be set in p.Metadata to a value whose text (fmt.Sprint) equals the given one.
+func (f Filter) MatchesSearch(p *core.Prompt) bool {
+	for k, want := range f.Metadata {
+		v, ok := p.Metadata[k]
+		if !ok || v == nil || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	words := strings.Fields(strings.ToLower(f.Query))
+	if len(words) == 0 {
+		return true
+	}
+	text := strings.ToLower(p.Name + "\n" + p.Description + "\n" + p.Template)
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,336 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// semver is a parsed semantic version (major.minor.patch[-prerelease]). Build metadata is ignored.
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+// parseSemver parses versions like "1.2.3", "v1.2", "1.0.0-rc.1+build". Missing minor/patch default to 0.
+func parseSemver(v string) (semver, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	var sv semver
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		sv.pre = v[i+1:]
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return semver{}, false
+	}
+	nums := [3]int{}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+	sv.major, sv.minor, sv.patch = nums[0], nums[1], nums[2]
+	return sv, true
+}
+
+// compare returns -1, 0, or 1. A pre-release sorts before the corresponding release.
+func (a semver) compare(b semver) int {
+	for _, d := range [3]int{a.major - b.major, a.minor - b.minor, a.patch - b.patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case a.pre == b.pre:
+		return 0
+	case a.pre == "":
+		return 1
+	case b.pre == "":
+		return -1
+	}
+	return comparePrerelease(a.pre, b.pre)
+}
+
+// comparePrerelease compares dot-separated pre-release identifiers per semver precedence rules.
+func comparePrerelease(a, b string) int {
+	ap, bp := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		an, aErr := strconv.Atoi(ap[i])
+		bn, bErr := strconv.Atoi(bp[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(ap[i], bp[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(ap) < len(bp):
+		return -1
+	case len(ap) > len(bp):
+		return 1
+	}
+	return 0
+}
+
+// CompareVersions orders two version strings by semver precedence, returning -1, 0, or 1.
+// Valid semver sorts before non-semver strings, which are compared lexically.
+func CompareVersions(a, b string) int {
+	av, aok := parseSemver(a)
+	bv, bok := parseSemver(b)
+	switch {
+	case aok && bok:
+		if c := av.compare(bv); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	case aok:
+		return -1
+	case bok:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// sortVersions sorts version strings in ascending semver order.
+func sortVersions(vs []string) {
+	sort.Slice(vs, func(i, j int) bool { return CompareVersions(vs[i], vs[j]) < 0 })
+}
+
+// sortVersionInfos sorts infos in ascending semver order of Version.
+func sortVersionInfos(infos []VersionInfo) {
+	sort.Slice(infos, func(i, j int) bool { return CompareVersions(infos[i].Version, infos[j].Version) < 0 })
+}
+
+// comparator is one "op version" term of a constraint, e.g. ">=1.2.0".
+type comparator struct {
+	op string // "=", ">", ">=", "<", "<="
+	v  semver
+}
+
+func (c comparator) match(v semver) bool {
+	d := v.compare(c.v)
+	switch c.op {
+	case ">":
+		return d > 0
+	case ">=":
+		return d >= 0
+	case "<":
+		return d < 0
+	case "<=":
+		return d <= 0
+	}
+	return d == 0
+}
+
+// constraint is a disjunction ("||") of conjunctions of comparators.
+type constraint [][]comparator
+
+// parseConstraint parses npm-style version ranges: exact versions ("1.2.3"), wildcards ("*", "1.x",
+// "1.2"), caret ("^1.2": >=1.2.0 <2.0.0; "^0.2": <0.3.0), tilde ("~1.2.3": >=1.2.3 <1.3.0), and
+// comparators (">=1.2 <1.5"), with "||" between alternatives.
+func parseConstraint(s string) (constraint, error) {
+	var c constraint
+	for _, alt := range strings.Split(s, "||") {
+		set := []comparator{}
+		for _, term := range strings.Fields(strings.ReplaceAll(alt, ",", " ")) {
+			cmps, err := parseTerm(term)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q in constraint %q", core.ErrInvalidVersion, term, s)
+			}
+			set = append(set, cmps...)
+		}
+		c = append(c, set)
+	}
+	return c, nil
+}
+
+// parseTerm expands one constraint term into comparators (none for "*").
+func parseTerm(term string) ([]comparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(term, prefix) {
+			op, term = prefix, term[len(prefix):]
+			break
+		}
+	}
+	v, n, err := parsePartial(term)
+	if err != nil {
+		return nil, err
+	}
+	// bump returns v's prefix of n parts incremented, e.g. bump(1.2.3, 2) = 1.3.0.
+	bump := func(n int) semver {
+		switch n {
+		case 1:
+			return semver{major: v.major + 1}
+		case 2:
+			return semver{major: v.major, minor: v.minor + 1}
+		}
+		return semver{major: v.major, minor: v.minor, patch: v.patch + 1}
+	}
+	switch {
+	case n == 0:
+		if op == "<" || op == ">" {
+			// "<*" and ">*" match nothing.
+			return []comparator{{op: "<", v: semver{}}}, nil
+		}
+		return nil, nil
+	case op == "^":
+		upper := 1
+		if v.major == 0 && n > 1 {
+			upper = 2
+			if v.minor == 0 && n > 2 {
+				upper = 3
+			}
+		}
+		return []comparator{{">=", v}, {"<", bump(upper)}}, nil
+	case op == "~":
+		return []comparator{{">=", v}, {"<", bump(min(n, 2))}}, nil
+	case n < 3 && (op == "" || op == "="):
+		return []comparator{{">=", v}, {"<", bump(n)}}, nil
+	case n < 3 && op == ">":
+		return []comparator{{">=", bump(n)}}, nil
+	case n < 3 && op == "<=":
+		return []comparator{{"<", bump(n)}}, nil
+	case op == "":
+		op = "="
+	}
+	return []comparator{{op, v}}, nil
+}
+
+// parsePartial parses a possibly partial version ("1", "1.2", "1.x", "1.2.3-rc.1") and reports how
+// many of major, minor, and patch were given.
+func parsePartial(s string) (semver, int, error) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var v semver
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.pre = s[i+1:]
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return semver{}, 0, errors.New("too many parts")
+	}
+	nums := [3]int{}
+	n := 0
+	for _, p := range parts {
+		if p == "*" || p == "x" || p == "X" || p == "" && len(parts) == 1 {
+			break
+		}
+		num, err := strconv.Atoi(p)
+		if err != nil || num < 0 {
+			return semver{}, 0, fmt.Errorf("not a number: %q", p)
+		}
+		nums[n] = num
+		n++
+	}
+	if v.pre != "" && n < 3 {
+		return semver{}, 0, errors.New("pre-release requires a full version")
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	return v, n, nil
+}
+
+// match reports whether v satisfies c. As with npm ranges, a pre-release version only matches if a
+// comparator in the same alternative names a pre-release of the same major.minor.patch.
+func (c constraint) match(v semver) bool {
+	for _, set := range c {
+		ok := true
+		preAllowed := v.pre == ""
+		for _, cmp := range set {
+			if !cmp.match(v) {
+				ok = false
+				break
+			}
+			if cmp.v.pre != "" && cmp.v.major == v.major && cmp.v.minor == v.minor && cmp.v.patch == v.patch {
+				preAllowed = true
+			}
+		}
+		if ok && preAllowed {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchVersion reports whether version satisfies the range constraint (see Resolve for the syntax).
+// Versions that are not semver never match.
+func MatchVersion(constraint, version string) (bool, error) {
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	v, ok := parseSemver(version)
+	return ok && c.match(v), nil
+}
+
+// Resolve returns the highest non-archived version of id that satisfies constraint, an npm-style
+// range such as "1.2.3", "1.x", "^1.2", "~1.2.3", ">=1.2 <2", or "^1 || ^2". Pre-releases are only
+// chosen if the constraint names one (e.g. "^2.0.0-rc.1"). It works with any Registry via
+// ListVersions and Get, and returns core.ErrPromptNotFound if no version matches.
+func Resolve(ctx context.Context, reg Registry, id, constraint string) (*core.Prompt, error) {
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+	return resolve(ctx, reg, id, constraint, c.match)
+}
+
+// GetLatest returns the highest non-archived release version of id by semver precedence, or the
+// highest pre-release if id has no releases. Versions that are not semver are ignored.
+func GetLatest(ctx context.Context, reg Registry, id string) (*core.Prompt, error) {
+	p, err := resolve(ctx, reg, id, "latest", func(v semver) bool { return v.pre == "" })
+	if errors.Is(err, core.ErrPromptNotFound) {
+		p, err = resolve(ctx, reg, id, "latest", func(semver) bool { return true })
+	}
+	return p, err
+}
+
+func resolve(ctx context.Context, reg Registry, id, label string, match func(semver) bool) (*core.Prompt, error) {
+	infos, err := reg.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	found := ""
+	for _, vi := range infos {
+		v, ok := parseSemver(vi.Version)
+		if vi.Archived || !ok || !match(v) {
+			continue
+		}
+		if found == "" || CompareVersions(vi.Version, found) > 0 {
+			found = vi.Version
+		}
+	}
+	if found == "" {
+		return nil, fmt.Errorf("%w: no version of %s matches %q", core.ErrPromptNotFound, id, label)
+	}
+	return reg.Get(ctx, id, found)
+}
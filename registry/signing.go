@@ -0,0 +1,300 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/klejdi94/loom/core"
+)
+
+var (
+	// ErrUnsigned is returned by a verifying SignedRegistry for a prompt without a signature.
+	ErrUnsigned = errors.New("registry: prompt is not signed")
+	// ErrInvalidSignature is returned by a verifying SignedRegistry for a prompt whose signature
+	// does not match its content or was made with a key that is not trusted.
+	ErrInvalidSignature = errors.New("registry: invalid prompt signature")
+)
+
+// SignatureKey is the Prompt.Metadata key holding a prompt's signature: {"key_id": ..., "sig":
+// base64 ed25519 signature}.
+const SignatureKey = "loom_signature"
+
+// signingMessage returns the bytes signed for p: its id, version, and the Checksum of its content
+// without the signature itself.
+func signingMessage(p *core.Prompt) []byte {
+	return []byte("loom-signature-v1\x00" + p.ID + "\x00" + p.Version + "\x00" + Checksum(unsigned(p)))
+}
+
+// unsigned returns a shallow copy of p without its signature.
+func unsigned(p *core.Prompt) *core.Prompt {
+	q := *p
+	q.Metadata = make(map[string]interface{}, len(p.Metadata))
+	for k, v := range p.Metadata {
+		if k != SignatureKey {
+			q.Metadata[k] = v
+		}
+	}
+	return &q
+}
+
+// SignPrompt signs p's id, version, and content (see Checksum) with key and stores the signature
+// under SignatureKey, replacing any earlier one. keyID names the key for verifiers.
+func SignPrompt(p *core.Prompt, keyID string, key ed25519.PrivateKey) {
+	sig := ed25519.Sign(key, signingMessage(p))
+	if p.Metadata == nil {
+		p.Metadata = make(map[string]interface{})
+	}
+	p.Metadata[SignatureKey] = map[string]interface{}{"key_id": keyID, "sig": base64.StdEncoding.EncodeToString(sig)}
+}
+
+// VerifyPrompt checks p's signature against the trusted public keys by key id. It returns
+// ErrUnsigned if p has none and ErrInvalidSignature if it was changed since it was signed or
+// signed with a key not in trusted.
+func VerifyPrompt(p *core.Prompt, trusted map[string]ed25519.PublicKey) error {
+	m, ok := p.Metadata[SignatureKey].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: %s@%s", ErrUnsigned, p.ID, p.Version)
+	}
+	keyID, _ := m["key_id"].(string)
+	encoded, _ := m["sig"].(string)
+	pub, ok := trusted[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %s@%s: untrusted key %q", ErrInvalidSignature, p.ID, p.Version, keyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !ed25519.Verify(pub, signingMessage(p), sig) {
+		return fmt.Errorf("%w: %s@%s", ErrInvalidSignature, p.ID, p.Version)
+	}
+	return nil
+}
+
+// ParsePrivateKey decodes a base64 ed25519 private key, either the 32-byte seed or the 64-byte
+// key, as written by loom keygen.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("registry: private key: %w", err)
+	}
+	switch len(b) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(b), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(b), nil
+	}
+	return nil, fmt.Errorf("registry: private key: %d bytes, want %d or %d", len(b), ed25519.SeedSize, ed25519.PrivateKeySize)
+}
+
+// ParsePublicKey decodes a base64 ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("registry: public key: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("registry: public key: %d bytes, want %d", len(b), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// SignedRegistry signs prompts on Store and verifies them on read on top of another Registry,
+// so production services can refuse prompts that did not come through a review pipeline: the
+// pipeline writes through a registry WithSigningKey, and services read through one
+// WithTrustedKeys. Signatures cover the id, version, and content, so promoting, tagging, and
+// aliasing keep them valid, while any edit to the content needs a new signature.
+type SignedRegistry struct {
+	inner   Registry
+	keyID   string
+	key     ed25519.PrivateKey
+	trusted map[string]ed25519.PublicKey
+}
+
+// SignOption configures a SignedRegistry.
+type SignOption func(*SignedRegistry)
+
+// WithSigningKey signs every stored prompt with key, recorded under keyID.
+func WithSigningKey(keyID string, key ed25519.PrivateKey) SignOption {
+	return func(s *SignedRegistry) {
+		s.keyID = keyID
+		s.key = key
+	}
+}
+
+// WithTrustedKeys makes Get, GetProduction, GetMany, and List fail with ErrUnsigned or
+// ErrInvalidSignature unless each prompt is signed by one of keys (by key id).
+func WithTrustedKeys(keys map[string]ed25519.PublicKey) SignOption {
+	return func(s *SignedRegistry) {
+		s.trusted = keys
+	}
+}
+
+// NewSigned returns inner with prompts signed on Store and verified on read as configured by opts.
+func NewSigned(inner Registry, opts ...SignOption) *SignedRegistry {
+	s := &SignedRegistry{inner: inner}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+func (s *SignedRegistry) sign(p *core.Prompt) {
+	if s.key != nil && p != nil {
+		SignPrompt(p, s.keyID, s.key)
+	}
+}
+
+// verify checks p if the registry has trusted keys; it passes through err from the read.
+func (s *SignedRegistry) verify(p *core.Prompt, err error) (*core.Prompt, error) {
+	if err != nil || s.trusted == nil {
+		return p, err
+	}
+	if err := VerifyPrompt(p, s.trusted); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Store implements Registry, signing prompt (which gains SignatureKey in its metadata) first.
+func (s *SignedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	s.sign(prompt)
+	return s.inner.Store(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does).
+func (s *SignedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	s.sign(prompt)
+	return StoreIfMatch(ctx, s.inner, prompt, revision)
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time).
+func (s *SignedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	for _, p := range prompts {
+		s.sign(p)
+	}
+	return StoreBatch(ctx, s.inner, prompts)
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time).
+func (s *SignedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	return DeleteBatch(ctx, s.inner, refs)
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time). Missing versions stay nil.
+func (s *SignedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	prompts, err := GetMany(ctx, s.inner, refs)
+	if err != nil || s.trusted == nil {
+		return prompts, err
+	}
+	for _, p := range prompts {
+		if p == nil {
+			continue
+		}
+		if err := VerifyPrompt(p, s.trusted); err != nil {
+			return nil, err
+		}
+	}
+	return prompts, nil
+}
+
+// Get implements Registry.
+func (s *SignedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	return s.verify(s.inner.Get(ctx, id, version))
+}
+
+// GetProduction implements Registry.
+func (s *SignedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	return s.verify(s.inner.GetProduction(ctx, id))
+}
+
+// List implements Registry; with trusted keys it fails if any listed prompt does not verify.
+func (s *SignedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	prompts, err := s.inner.List(ctx, filter)
+	if err != nil || s.trusted == nil {
+		return prompts, err
+	}
+	for _, p := range prompts {
+		if err := VerifyPrompt(p, s.trusted); err != nil {
+			return nil, err
+		}
+	}
+	return prompts, nil
+}
+
+// ListVersions implements Registry.
+func (s *SignedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	return s.inner.ListVersions(ctx, id)
+}
+
+// Promote implements Registry.
+func (s *SignedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	return s.inner.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does).
+func (s *SignedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	return RollbackProduction(ctx, s.inner, id, from, version)
+}
+
+// Delete implements Registry.
+func (s *SignedRegistry) Delete(ctx context.Context, id, version string) error {
+	return s.inner.Delete(ctx, id, version)
+}
+
+// Tag implements Registry.
+func (s *SignedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	return s.inner.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry.
+func (s *SignedRegistry) Archive(ctx context.Context, id, version string) error {
+	return s.inner.Archive(ctx, id, version)
+}
+
+// Restore implements Registry.
+func (s *SignedRegistry) Restore(ctx context.Context, id, version string) error {
+	return s.inner.Restore(ctx, id, version)
+}
+
+// SetAlias implements Aliaser (if inner does).
+func (s *SignedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	return SetAlias(ctx, s.inner, id, alias, version)
+}
+
+// Aliases implements Aliaser (if inner does).
+func (s *SignedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return Aliases(ctx, s.inner, id)
+}
+
+// History implements Auditor (if inner does).
+func (s *SignedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	return History(ctx, s.inner, id)
+}
+
+// Usage implements UsageReporter (if inner does).
+func (s *SignedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return Usage(ctx, s.inner, id)
+}
+
+// ListUsage implements UsageReporter (if inner does).
+func (s *SignedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	return ListUsage(ctx, s.inner)
+}
+
+// WatchChanges implements ChangeWatcher (if inner does).
+func (s *SignedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, s.inner, fn)
+}
+
+// Ensure SignedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*SignedRegistry)(nil)
+	_ ConditionalStorer    = (*SignedRegistry)(nil)
+	_ Auditor              = (*SignedRegistry)(nil)
+	_ UsageReporter        = (*SignedRegistry)(nil)
+	_ ChangeWatcher        = (*SignedRegistry)(nil)
+	_ Aliaser              = (*SignedRegistry)(nil)
+	_ Batcher              = (*SignedRegistry)(nil)
+	_ ProductionRollbacker = (*SignedRegistry)(nil)
+)
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedRegistry(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPub, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	inner, err := NewFileRegistry(t.TempDir())
+	require.NoError(t, err)
+	pipeline := NewSigned(inner, WithSigningKey("review", priv))
+	service := NewSigned(inner, WithTrustedKeys(map[string]ed25519.PublicKey{"review": pub}))
+
+	require.NoError(t, pipeline.Store(ctx, &core.Prompt{ID: "greet", Version: "1.0.0", Template: "Hi {{.name}}",
+		Metadata: map[string]interface{}{"team": "cx"}}))
+	require.NoError(t, pipeline.Promote(ctx, "greet", "1.0.0", StageProduction))
+	require.NoError(t, pipeline.Tag(ctx, "greet", "1.0.0", []string{"approved"}))
+	p, err := service.GetProduction(ctx, "greet")
+	require.NoError(t, err, "promoting and tagging keep the signature valid")
+	assert.Equal(t, "Hi {{.name}}", p.Template)
+	_, err = service.List(ctx, Filter{})
+	require.NoError(t, err)
+
+	// Stored without the pipeline.
+	require.NoError(t, inner.Store(ctx, &core.Prompt{ID: "greet", Version: "1.1.0", Template: "Hey"}))
+	_, err = service.Get(ctx, "greet", "1.1.0")
+	assert.ErrorIs(t, err, ErrUnsigned)
+	_, err = service.List(ctx, Filter{})
+	assert.ErrorIs(t, err, ErrUnsigned)
+	ps, err := service.GetMany(ctx, []VersionRef{{ID: "greet", Version: "1.0.0"}, {ID: "greet", Version: "9.9.9"}})
+	require.NoError(t, err)
+	assert.Nil(t, ps[1])
+
+	// Edited after signing, or re-signed as another version.
+	p.Template = "Send me your password"
+	require.NoError(t, inner.Store(ctx, p))
+	_, err = service.Get(ctx, "greet", "1.0.0")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	q, err := pipeline.Get(ctx, "greet", "1.1.0")
+	require.NoError(t, err)
+	require.NoError(t, pipeline.Store(ctx, q))
+	q.Version = "2.0.0"
+	require.NoError(t, inner.Store(ctx, q))
+	_, err = service.Get(ctx, "greet", "2.0.0")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = service.Get(ctx, "greet", "1.1.0")
+	require.NoError(t, err)
+
+	// Signed with a key the service does not trust.
+	require.NoError(t, NewSigned(inner, WithSigningKey("review", otherPriv)).Store(ctx, &core.Prompt{ID: "x", Version: "1.0.0"}))
+	_, err = service.Get(ctx, "x", "1.0.0")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = NewSigned(inner, WithTrustedKeys(map[string]ed25519.PublicKey{"review": otherPub})).Get(ctx, "x", "1.0.0")
+	assert.NoError(t, err)
+
+	_, err = service.Get(ctx, "nope", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+}
+
+func TestParseKeys(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	k, err := ParsePrivateKey(base64.StdEncoding.EncodeToString(priv.Seed()))
+	require.NoError(t, err)
+	assert.Equal(t, priv, k)
+	k, err = ParsePrivateKey(base64.StdEncoding.EncodeToString(priv))
+	require.NoError(t, err)
+	assert.Equal(t, priv, k)
+	p, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub))
+	require.NoError(t, err)
+	assert.Equal(t, pub, p)
+	_, err = ParsePublicKey(base64.StdEncoding.EncodeToString(priv))
+	assert.Error(t, err)
+	_, err = ParsePrivateKey("not base64!")
+	assert.Error(t, err)
+}
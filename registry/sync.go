@@ -0,0 +1,394 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// ConflictPolicy decides what a Syncer does when the destination already has a version whose
+// content differs from the source's.
+type ConflictPolicy int
+
+const (
+	// SourceWins overwrites the destination version (the default).
+	SourceWins ConflictPolicy = iota
+	// DestinationWins keeps the destination version and skips it.
+	DestinationWins
+	// FailOnConflict stops the sync with an error wrapping core.ErrConflict.
+	FailOnConflict
+)
+
+// SyncResult counts what one Sync changed in the destination.
+type SyncResult struct {
+	Stored    int // versions created or overwritten
+	Updated   int // versions whose stage, tags, or archived flag changed
+	Deleted   int // versions pruned (WithPrune)
+	Conflicts int // versions that differed and were kept (DestinationWins)
+}
+
+// Syncer mirrors prompts from a source registry to a destination: content, stages, tags,
+// archived flags, production versions, and aliases (when both implement Aliaser). Sync runs
+// once; Run keeps the destination up to date, e.g. to publish prompts promoted in a staging
+// Postgres registry to a production S3 registry.
+//
+// Versions that are identical in both registries are not rewritten, so repeated syncs add no
+// revisions or audit entries. Nothing is deleted from the destination unless WithPrune is set.
+type Syncer struct {
+	src, dst Registry
+	policy   ConflictPolicy
+	stages   map[Stage]bool
+	prune    bool
+	onError  func(error)
+}
+
+// SyncOption configures a Syncer.
+type SyncOption func(*Syncer)
+
+// WithConflictPolicy sets how versions that differ between source and destination are handled.
+func WithConflictPolicy(p ConflictPolicy) SyncOption {
+	return func(s *Syncer) {
+		s.policy = p
+	}
+}
+
+// WithSyncStages mirrors only versions in the given stages (e.g. StageProduction); by default
+// every version is mirrored.
+func WithSyncStages(stages ...Stage) SyncOption {
+	return func(s *Syncer) {
+		s.stages = make(map[Stage]bool, len(stages))
+		for _, st := range stages {
+			s.stages[st] = true
+		}
+	}
+}
+
+// WithPrune deletes destination versions that are not (or no longer) mirrored from the source,
+// making the destination an exact copy.
+func WithPrune() SyncOption {
+	return func(s *Syncer) {
+		s.prune = true
+	}
+}
+
+// WithSyncErrorHandler sets the function Run passes sync errors to; by default they are dropped
+// and the next sync retries.
+func WithSyncErrorHandler(fn func(error)) SyncOption {
+	return func(s *Syncer) {
+		s.onError = fn
+	}
+}
+
+// NewSyncer returns a Syncer that copies src into dst.
+func NewSyncer(src, dst Registry, opts ...SyncOption) *Syncer {
+	s := &Syncer{src: src, dst: dst, onError: func(error) {}}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Sync mirrors every prompt id in the source (and, with WithPrune, removes destination ids the
+// source no longer has). It stops at the first error; a later Sync resumes where it left off.
+func (s *Syncer) Sync(ctx context.Context) (SyncResult, error) {
+	var res SyncResult
+	srcPrompts, err := listAll(ctx, s.src, nil)
+	if err != nil {
+		return res, fmt.Errorf("sync: source: %w", err)
+	}
+	dstPrompts, err := listAll(ctx, s.dst, nil)
+	if err != nil {
+		return res, fmt.Errorf("sync: destination: %w", err)
+	}
+	srcByID, dstByID := groupByID(srcPrompts), groupByID(dstPrompts)
+	for id := range srcByID {
+		if err := s.syncID(ctx, id, srcByID[id], dstByID[id], &res); err != nil {
+			return res, err
+		}
+	}
+	if s.prune {
+		for id := range dstByID {
+			if _, ok := srcByID[id]; !ok {
+				if err := s.syncID(ctx, id, nil, dstByID[id], &res); err != nil {
+					return res, err
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// SyncID mirrors a single prompt id, e.g. after a change notification.
+func (s *Syncer) SyncID(ctx context.Context, id string) (SyncResult, error) {
+	var res SyncResult
+	srcPrompts, err := listAll(ctx, s.src, []string{id})
+	if err != nil {
+		return res, fmt.Errorf("sync %s: source: %w", id, err)
+	}
+	dstPrompts, err := listAll(ctx, s.dst, []string{id})
+	if err != nil {
+		return res, fmt.Errorf("sync %s: destination: %w", id, err)
+	}
+	return res, s.syncID(ctx, id, srcPrompts, dstPrompts, &res)
+}
+
+// Run syncs immediately and then every interval until ctx is done. If the source implements
+// ChangeWatcher, changed ids are also synced as soon as they are reported, and interval only
+// bounds how long a missed notification goes unnoticed. Errors are passed to the
+// WithSyncErrorHandler function and do not stop Run, which returns nil when ctx is done.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("sync: interval must be positive")
+	}
+	if _, err := s.Sync(ctx); err != nil {
+		s.onError(err)
+	}
+	if _, ok := s.src.(ChangeWatcher); ok {
+		go func() {
+			err := WatchChanges(ctx, s.src, func(id string) {
+				if _, err := s.SyncID(ctx, id); err != nil {
+					s.onError(err)
+				}
+			})
+			if err != nil {
+				s.onError(fmt.Errorf("sync: watch: %w", err))
+			}
+		}()
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			if _, err := s.Sync(ctx); err != nil {
+				s.onError(err)
+			}
+		}
+	}
+}
+
+// syncID makes dst's versions of id match src's, given both registries' versions of it
+// (including archived ones).
+func (s *Syncer) syncID(ctx context.Context, id string, srcPrompts, dstPrompts []*core.Prompt, res *SyncResult) error {
+	srcInfos, err := versionInfos(ctx, s.src, id, len(srcPrompts) > 0)
+	if err != nil {
+		return fmt.Errorf("sync %s: source: %w", id, err)
+	}
+	dstInfos, err := versionInfos(ctx, s.dst, id, len(dstPrompts) > 0)
+	if err != nil {
+		return fmt.Errorf("sync %s: destination: %w", id, err)
+	}
+	dstByVersion := make(map[string]*core.Prompt, len(dstPrompts))
+	for _, p := range dstPrompts {
+		dstByVersion[p.Version] = p
+	}
+
+	mirrored := make(map[string]bool, len(srcPrompts))
+	var toStore []*core.Prompt
+	for _, p := range srcPrompts {
+		if s.stages != nil && !s.stages[srcInfos[p.Version].Stage] {
+			continue
+		}
+		mirrored[p.Version] = true
+		existing, ok := dstByVersion[p.Version]
+		if ok && p.Fingerprint() == existing.Fingerprint() {
+			continue
+		}
+		if ok {
+			switch s.policy {
+			case DestinationWins:
+				res.Conflicts++
+				continue
+			case FailOnConflict:
+				return fmt.Errorf("sync %s@%s: %w: destination version differs from source", id, p.Version, core.ErrConflict)
+			}
+		}
+		cp := p.Copy()
+		cp.Revision = 0
+		toStore = append(toStore, cp)
+	}
+	if len(toStore) > 0 {
+		if err := StoreBatch(ctx, s.dst, toStore); err != nil {
+			return fmt.Errorf("sync %s: %w", id, err)
+		}
+		res.Stored += len(toStore)
+		for _, p := range toStore {
+			if _, ok := dstInfos[p.Version]; !ok {
+				dstInfos[p.Version] = VersionInfo{ID: id, Version: p.Version}
+			}
+		}
+	}
+
+	srcProd := ""
+	if p, err := s.src.GetProduction(ctx, id); err == nil {
+		srcProd = p.Version
+	} else if !errors.Is(err, core.ErrPromptNotFound) {
+		return fmt.Errorf("sync %s: source: %w", id, err)
+	}
+	for _, p := range srcPrompts {
+		if !mirrored[p.Version] {
+			continue
+		}
+		want, have := srcInfos[p.Version], dstInfos[p.Version]
+		changed := false
+		// The production version is promoted last, below, so that it ends up as the pointer.
+		if want.Stage != have.Stage && want.Stage != "" && p.Version != srcProd {
+			if err := s.dst.Promote(ctx, id, p.Version, want.Stage); err != nil {
+				return fmt.Errorf("sync %s@%s: %w", id, p.Version, err)
+			}
+			changed = true
+		}
+		if !sameTags(want.Tags, have.Tags) {
+			if err := s.dst.Tag(ctx, id, p.Version, want.Tags); err != nil {
+				return fmt.Errorf("sync %s@%s: %w", id, p.Version, err)
+			}
+			changed = true
+		}
+		if want.Archived != have.Archived {
+			setArchived := s.dst.Restore
+			if want.Archived {
+				setArchived = s.dst.Archive
+			}
+			if err := setArchived(ctx, id, p.Version); err != nil {
+				return fmt.Errorf("sync %s@%s: %w", id, p.Version, err)
+			}
+			changed = true
+		}
+		if changed {
+			res.Updated++
+		}
+	}
+	if srcProd != "" && mirrored[srcProd] {
+		dstProd := ""
+		if p, err := s.dst.GetProduction(ctx, id); err == nil {
+			dstProd = p.Version
+		}
+		if dstProd != srcProd {
+			if err := s.dst.Promote(ctx, id, srcProd, StageProduction); err != nil {
+				return fmt.Errorf("sync %s@%s: %w", id, srcProd, err)
+			}
+			res.Updated++
+		}
+	}
+
+	if s.prune {
+		var refs []VersionRef
+		for _, p := range dstPrompts {
+			if !mirrored[p.Version] {
+				refs = append(refs, VersionRef{ID: id, Version: p.Version})
+			}
+		}
+		if len(refs) > 0 {
+			if err := DeleteBatch(ctx, s.dst, refs); err != nil {
+				return fmt.Errorf("sync %s: prune: %w", id, err)
+			}
+			res.Deleted += len(refs)
+		}
+	}
+	if err := s.syncAliases(ctx, id, mirrored); err != nil {
+		return fmt.Errorf("sync %s aliases: %w", id, err)
+	}
+	return nil
+}
+
+// syncAliases points the destination's aliases of id at the same versions as the source's,
+// for aliases whose version is mirrored. With WithPrune, other destination aliases are removed.
+func (s *Syncer) syncAliases(ctx context.Context, id string, mirrored map[string]bool) error {
+	_, srcOK := s.src.(Aliaser)
+	_, dstOK := s.dst.(Aliaser)
+	if !srcOK || !dstOK || len(mirrored) == 0 && !s.prune {
+		return nil
+	}
+	want := map[string]string{}
+	if len(mirrored) > 0 {
+		src, err := Aliases(ctx, s.src, id)
+		if err != nil {
+			return err
+		}
+		for alias, version := range src {
+			if mirrored[version] {
+				want[alias] = version
+			}
+		}
+	}
+	have, err := Aliases(ctx, s.dst, id)
+	if err != nil {
+		return err
+	}
+	for alias, version := range want {
+		if have[alias] != version {
+			if err := SetAlias(ctx, s.dst, id, alias, version); err != nil {
+				return err
+			}
+		}
+	}
+	if s.prune {
+		for alias := range have {
+			if _, ok := want[alias]; !ok {
+				if err := SetAlias(ctx, s.dst, id, alias, ""); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// listAll returns every version in reg, including archived ones, optionally limited to ids.
+func listAll(ctx context.Context, reg Registry, ids []string) ([]*core.Prompt, error) {
+	var all []*core.Prompt
+	err := eachPage(ctx, reg, Filter{IDs: ids, Limit: 1000, IncludeArchived: true}, func(prompts []*core.Prompt) (bool, error) {
+		all = append(all, prompts...)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func groupByID(prompts []*core.Prompt) map[string][]*core.Prompt {
+	m := make(map[string][]*core.Prompt)
+	for _, p := range prompts {
+		m[p.ID] = append(m[p.ID], p)
+	}
+	return m
+}
+
+// versionInfos returns id's versions keyed by version, or an empty map if exists is false.
+func versionInfos(ctx context.Context, reg Registry, id string, exists bool) (map[string]VersionInfo, error) {
+	m := make(map[string]VersionInfo)
+	if !exists {
+		return m, nil
+	}
+	infos, err := reg.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		m[info.Version] = info
+	}
+	return m, nil
+}
+
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int, len(a))
+	for _, t := range a {
+		seen[t]++
+	}
+	for _, t := range b {
+		if seen[t] == 0 {
+			return false
+		}
+		seen[t]--
+	}
+	return true
+}
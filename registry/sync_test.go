@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncer(t *testing.T) {
+	ctx := context.Background()
+	src, dst := NewMemoryRegistry(), NewMemoryRegistry()
+	for _, p := range []*core.Prompt{
+		{ID: "p", Version: "1.0.0", Template: "a"},
+		{ID: "p", Version: "1.1.0", Template: "b"},
+		{ID: "p", Version: "2.0.0", Template: "c"},
+		{ID: "q", Version: "1.0.0", Template: "d"},
+	} {
+		require.NoError(t, src.Store(ctx, p))
+	}
+	require.NoError(t, src.Promote(ctx, "p", "1.1.0", StageProduction))
+	require.NoError(t, src.Promote(ctx, "p", "1.0.0", StageProduction))
+	require.NoError(t, src.Promote(ctx, "p", "2.0.0", StageStaging))
+	require.NoError(t, src.Tag(ctx, "p", "1.0.0", []string{"stable"}))
+	require.NoError(t, SetAlias(ctx, src, "p", "current", "1.0.0"))
+
+	s := NewSyncer(src, dst, WithSyncStages(StageProduction))
+	res, err := s.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Stored)
+	prod, err := dst.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", prod.Version)
+	infos, err := dst.ListVersions(ctx, "p")
+	require.NoError(t, err)
+	require.Len(t, infos, 2, "only production versions")
+	assert.Equal(t, []string{"stable"}, infos[0].Tags)
+	current, err := dst.Get(ctx, "p", "@current")
+	require.NoError(t, err)
+	assert.Equal(t, "a", current.Template)
+	_, err = dst.Get(ctx, "q", "1.0.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+	res, err = s.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SyncResult{}, res, "nothing changes on a second sync")
+
+	// Promoting 2.0.0 in the source publishes it; pruning removes 1.1.0 once it leaves production.
+	require.NoError(t, src.Promote(ctx, "p", "2.0.0", StageProduction))
+	require.NoError(t, src.Promote(ctx, "p", "1.1.0", StageStaging))
+	res, err = NewSyncer(src, dst, WithSyncStages(StageProduction), WithPrune()).Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Stored)
+	assert.Equal(t, 1, res.Deleted)
+	prod, err = dst.GetProduction(ctx, "p")
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", prod.Version)
+	_, err = dst.Get(ctx, "p", "1.1.0")
+	assert.ErrorIs(t, err, core.ErrPromptNotFound)
+
+	// Conflicts: the destination edited a mirrored version.
+	require.NoError(t, dst.Store(ctx, &core.Prompt{ID: "p", Version: "2.0.0", Template: "edited"}))
+	_, err = NewSyncer(src, dst, WithConflictPolicy(FailOnConflict)).Sync(ctx)
+	assert.ErrorIs(t, err, core.ErrConflict)
+	res, err = NewSyncer(src, dst, WithSyncStages(StageProduction), WithConflictPolicy(DestinationWins)).Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Conflicts)
+	got, err := dst.Get(ctx, "p", "2.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "edited", got.Template)
+	_, err = NewSyncer(src, dst, WithSyncStages(StageProduction)).Sync(ctx)
+	require.NoError(t, err)
+	got, err = dst.Get(ctx, "p", "2.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "c", got.Template, "source wins by default")
+}
+
+func TestSyncer_Run(t *testing.T) {
+	src, dst := NewMemoryRegistry(), NewMemoryRegistry()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewSyncer(src, dst).Run(ctx, 10*time.Millisecond) }()
+
+	require.NoError(t, src.Store(context.Background(), &core.Prompt{ID: "p", Version: "1.0.0", Template: "a"}))
+	assert.Eventually(t, func() bool {
+		_, err := dst.Get(context.Background(), "p", "1.0.0")
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+}
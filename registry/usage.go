@@ -0,0 +1,288 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// UsageStats counts reads of a prompt id since Since. LastRead is zero if it was never read.
+type UsageStats struct {
+	ID             string    `json:"id"`
+	Gets           int64     `json:"gets"`
+	ProductionGets int64     `json:"production_gets"`
+	LastRead       time.Time `json:"last_read"`
+	Since          time.Time `json:"since"`
+}
+
+// Reads returns the total of Gets and ProductionGets.
+func (u UsageStats) Reads() int64 { return u.Gets + u.ProductionGets }
+
+// UsageReporter is implemented by registries that track reads (see NewTracked).
+// ListUsage returns one entry per stored id, least recently read (or never read) first,
+// which makes unused prompts that are safe to archive easy to spot.
+type UsageReporter interface {
+	Usage(ctx context.Context, id string) (UsageStats, error)
+	ListUsage(ctx context.Context) ([]UsageStats, error)
+}
+
+// Usage returns the read statistics for id via reg's Usage. It returns an error if reg does not
+// implement UsageReporter.
+func Usage(ctx context.Context, reg Registry, id string) (UsageStats, error) {
+	u, ok := reg.(UsageReporter)
+	if !ok {
+		return UsageStats{}, fmt.Errorf("registry: %T does not track usage", reg)
+	}
+	return u.Usage(ctx, id)
+}
+
+// ListUsage returns the read statistics for every id via reg's ListUsage. It returns an error if reg
+// does not implement UsageReporter.
+func ListUsage(ctx context.Context, reg Registry) ([]UsageStats, error) {
+	u, ok := reg.(UsageReporter)
+	if !ok {
+		return nil, fmt.Errorf("registry: %T does not track usage", reg)
+	}
+	return u.ListUsage(ctx)
+}
+
+// UsageStore persists read counts for a TrackedRegistry. Usage returns zero counts (with Since set)
+// for ids that were never read; ListUsage returns only ids that were read.
+type UsageStore interface {
+	RecordRead(ctx context.Context, id string, production bool, at time.Time) error
+	Usage(ctx context.Context, id string) (UsageStats, error)
+	ListUsage(ctx context.Context) ([]UsageStats, error)
+}
+
+// MemoryUsageStore is an in-memory UsageStore; counts start over when the process restarts.
+type MemoryUsageStore struct {
+	mu    sync.Mutex
+	since time.Time
+	stats map[string]*UsageStats
+}
+
+// NewMemoryUsageStore creates an empty usage store.
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{since: time.Now().UTC(), stats: make(map[string]*UsageStats)}
+}
+
+// RecordRead implements UsageStore.
+func (m *MemoryUsageStore) RecordRead(ctx context.Context, id string, production bool, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.stats[id]
+	if s == nil {
+		s = &UsageStats{ID: id, Since: m.since}
+		m.stats[id] = s
+	}
+	if production {
+		s.ProductionGets++
+	} else {
+		s.Gets++
+	}
+	if at.After(s.LastRead) {
+		s.LastRead = at
+	}
+	return nil
+}
+
+// Usage implements UsageStore.
+func (m *MemoryUsageStore) Usage(ctx context.Context, id string) (UsageStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.stats[id]; s != nil {
+		return *s, nil
+	}
+	return UsageStats{ID: id, Since: m.since}, nil
+}
+
+// ListUsage implements UsageStore.
+func (m *MemoryUsageStore) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]UsageStats, 0, len(m.stats))
+	for _, s := range m.stats {
+		out = append(out, *s)
+	}
+	return out, nil
+}
+
+// TrackedRegistry records every successful Get and GetProduction in a UsageStore. Wrap the
+// outermost registry (e.g. around a CachedRegistry) so cache hits are counted too. Failing to
+// record a read never fails the read.
+type TrackedRegistry struct {
+	inner Registry
+	store UsageStore
+}
+
+// NewTracked returns inner with read tracking into store.
+func NewTracked(inner Registry, store UsageStore) *TrackedRegistry {
+	return &TrackedRegistry{inner: inner, store: store}
+}
+
+// Get implements Registry and counts the read.
+func (t *TrackedRegistry) Get(ctx context.Context, id, version string) (*core.Prompt, error) {
+	p, err := t.inner.Get(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	_ = t.store.RecordRead(ctx, id, false, time.Now().UTC())
+	return p, nil
+}
+
+// GetProduction implements Registry and counts the read.
+func (t *TrackedRegistry) GetProduction(ctx context.Context, id string) (*core.Prompt, error) {
+	p, err := t.inner.GetProduction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	_ = t.store.RecordRead(ctx, id, true, time.Now().UTC())
+	return p, nil
+}
+
+// GetMany implements Batcher (via inner's, or one Get at a time) and counts a read for every
+// version found.
+func (t *TrackedRegistry) GetMany(ctx context.Context, refs []VersionRef) ([]*core.Prompt, error) {
+	out, err := GetMany(ctx, t.inner, refs)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for i, p := range out {
+		if p != nil {
+			_ = t.store.RecordRead(ctx, refs[i].ID, false, now)
+		}
+	}
+	return out, nil
+}
+
+// Usage implements UsageReporter.
+func (t *TrackedRegistry) Usage(ctx context.Context, id string) (UsageStats, error) {
+	return t.store.Usage(ctx, id)
+}
+
+// ListUsage implements UsageReporter, including stored ids that were never read.
+func (t *TrackedRegistry) ListUsage(ctx context.Context) ([]UsageStats, error) {
+	ids := make(map[string]bool)
+	err := eachPage(ctx, t.inner, Filter{Limit: 500, IncludeArchived: true}, func(page []*core.Prompt) (bool, error) {
+		for _, p := range page {
+			ids[p.ID] = true
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]UsageStats, 0, len(ids))
+	for id := range ids {
+		u, err := t.store.Usage(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LastRead.Equal(out[j].LastRead) {
+			return out[i].LastRead.Before(out[j].LastRead)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+// WatchChanges implements ChangeWatcher (if inner does).
+func (t *TrackedRegistry) WatchChanges(ctx context.Context, fn func(id string)) error {
+	return WatchChanges(ctx, t.inner, fn)
+}
+
+// Store implements Registry.
+func (t *TrackedRegistry) Store(ctx context.Context, prompt *core.Prompt) error {
+	return t.inner.Store(ctx, prompt)
+}
+
+// StoreIfMatch implements ConditionalStorer (if inner does).
+func (t *TrackedRegistry) StoreIfMatch(ctx context.Context, prompt *core.Prompt, revision int64) error {
+	return StoreIfMatch(ctx, t.inner, prompt, revision)
+}
+
+// StoreBatch implements Batcher (via inner's, or one Store at a time).
+func (t *TrackedRegistry) StoreBatch(ctx context.Context, prompts []*core.Prompt) error {
+	return StoreBatch(ctx, t.inner, prompts)
+}
+
+// DeleteBatch implements Batcher (via inner's, or one Delete at a time).
+func (t *TrackedRegistry) DeleteBatch(ctx context.Context, refs []VersionRef) error {
+	return DeleteBatch(ctx, t.inner, refs)
+}
+
+// List implements Registry. Listing does not count as a read.
+func (t *TrackedRegistry) List(ctx context.Context, filter Filter) ([]*core.Prompt, error) {
+	return t.inner.List(ctx, filter)
+}
+
+// ListVersions implements Registry.
+func (t *TrackedRegistry) ListVersions(ctx context.Context, id string) ([]VersionInfo, error) {
+	return t.inner.ListVersions(ctx, id)
+}
+
+// Promote implements Registry.
+func (t *TrackedRegistry) Promote(ctx context.Context, id, version string, stage Stage) error {
+	return t.inner.Promote(ctx, id, version, stage)
+}
+
+// RollbackProduction implements ProductionRollbacker (if inner does).
+func (t *TrackedRegistry) RollbackProduction(ctx context.Context, id, from, version string) error {
+	return RollbackProduction(ctx, t.inner, id, from, version)
+}
+
+// Delete implements Registry.
+func (t *TrackedRegistry) Delete(ctx context.Context, id, version string) error {
+	return t.inner.Delete(ctx, id, version)
+}
+
+// Tag implements Registry.
+func (t *TrackedRegistry) Tag(ctx context.Context, id, version string, tags []string) error {
+	return t.inner.Tag(ctx, id, version, tags)
+}
+
+// Archive implements Registry.
+func (t *TrackedRegistry) Archive(ctx context.Context, id, version string) error {
+	return t.inner.Archive(ctx, id, version)
+}
+
+// Restore implements Registry.
+func (t *TrackedRegistry) Restore(ctx context.Context, id, version string) error {
+	return t.inner.Restore(ctx, id, version)
+}
+
+// SetAlias implements Aliaser (if inner does).
+func (t *TrackedRegistry) SetAlias(ctx context.Context, id, alias, version string) error {
+	return SetAlias(ctx, t.inner, id, alias, version)
+}
+
+// Aliases implements Aliaser (if inner does).
+func (t *TrackedRegistry) Aliases(ctx context.Context, id string) (map[string]string, error) {
+	return Aliases(ctx, t.inner, id)
+}
+
+// History implements Auditor (if inner does).
+func (t *TrackedRegistry) History(ctx context.Context, id string) ([]AuditEntry, error) {
+	return History(ctx, t.inner, id)
+}
+
+// Ensure TrackedRegistry implements Registry at compile time.
+var (
+	_ Registry             = (*TrackedRegistry)(nil)
+	_ ConditionalStorer    = (*TrackedRegistry)(nil)
+	_ Auditor              = (*TrackedRegistry)(nil)
+	_ UsageReporter        = (*TrackedRegistry)(nil)
+	_ ChangeWatcher        = (*TrackedRegistry)(nil)
+	_ Aliaser              = (*TrackedRegistry)(nil)
+	_ Batcher              = (*TrackedRegistry)(nil)
+	_ ProductionRollbacker = (*TrackedRegistry)(nil)
+	_ UsageStore           = (*MemoryUsageStore)(nil)
+)
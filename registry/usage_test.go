@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klejdi94/loom/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackedRegistry(t *testing.T) {
+	ctx := context.Background()
+	reg := NewTracked(NewCached(NewMemoryRegistry(), time.Minute), NewMemoryUsageStore())
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "used", Version: "1.0.0", Template: "a"}))
+	require.NoError(t, reg.Store(ctx, &core.Prompt{ID: "dead", Version: "1.0.0", Template: "b"}))
+	require.NoError(t, reg.Promote(ctx, "used", "1.0.0", StageProduction))
+
+	_, err := reg.Get(ctx, "used", "1.0.0")
+	require.NoError(t, err)
+	_, err = reg.GetProduction(ctx, "used")
+	require.NoError(t, err)
+	_, err = reg.GetProduction(ctx, "used")
+	require.NoError(t, err)
+	_, err = reg.Get(ctx, "missing", "1.0.0")
+	assert.Error(t, err)
+	_, err = reg.List(ctx, Filter{})
+	require.NoError(t, err)
+
+	u, err := Usage(ctx, reg, "used")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), u.Gets)
+	assert.Equal(t, int64(2), u.ProductionGets)
+	assert.Equal(t, int64(3), u.Reads())
+	assert.False(t, u.LastRead.IsZero())
+	assert.False(t, u.Since.IsZero())
+
+	missing, err := Usage(ctx, reg, "missing")
+	require.NoError(t, err)
+	assert.Zero(t, missing.Reads(), "failed reads are not counted")
+
+	all, err := ListUsage(ctx, reg)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "dead", all[0].ID, "never-read ids come first")
+	assert.Zero(t, all[0].Reads())
+	assert.True(t, all[0].LastRead.IsZero())
+	assert.Equal(t, "used", all[1].ID)
+
+	_, err = Usage(ctx, NewMemoryRegistry(), "used")
+	assert.Error(t, err)
+
+	scoped := NewScoped(reg, Scope{Read: []Stage{StageDev}})
+	u, err = Usage(ctx, scoped, "used")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), u.Reads())
+}
@@ -0,0 +1,307 @@
+// Package rollback watches the analytics of newly promoted prompt versions and rolls them back
+// when they regress. After a version is promoted to production, a Watcher compares its error rate
+// and average latency during a bake-in window with those of the version it replaced; if either
+// regresses beyond the Thresholds, the previous version is promoted back (see registry.Rollback;
+// the registry must implement registry.ProductionRollbacker), or in dry-run mode the regression is
+// only reported.
+//
+//	w := rollback.New(reg, store, rollback.Thresholds{MaxErrorRateIncrease: 0.05, MaxLatencyIncrease: 0.5},
+//		rollback.WithBakeIn(time.Hour),
+//		rollback.OnRegression(func(ctx context.Context, e rollback.Event) { alert(e) }))
+//	go w.Run(ctx, time.Minute)
+//
+// Runs must be recorded with the prompt id and version (analytics.RunRecord) for the watcher to
+// see them.
+package rollback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/klejdi94/loom/analytics"
+	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/registry"
+)
+
+// DefaultActor is the audit actor of the watcher's rollbacks (see WithActor).
+const DefaultActor = "loom-rollback"
+
+// Thresholds decide when a promoted version has regressed. A zero field disables its check.
+type Thresholds struct {
+	// MaxErrorRateIncrease is how far the version's error rate may exceed the previous version's,
+	// in absolute terms (0.05: from 2% to at most 7%).
+	MaxErrorRateIncrease float64
+	// MaxLatencyIncrease is how much slower the version's average latency may be than the previous
+	// version's, as a fraction (0.5: at most 50% slower). It is not checked while the previous
+	// version has no runs to compare with.
+	MaxLatencyIncrease float64
+	// MinRuns is how many runs the version needs before it is judged; default 20.
+	MinRuns int64
+}
+
+// Event describes a regression the watcher found.
+type Event struct {
+	ID string
+	// Version is the promoted version that regressed.
+	Version string
+	// Baseline is the version it replaced, which was (or in dry-run mode would be) promoted back.
+	Baseline string
+	// PromotedAt is when Version was promoted.
+	PromotedAt time.Time
+	// Reason says which threshold was exceeded.
+	Reason string
+	// Current holds Version's runs since PromotedAt; Previous holds Baseline's runs in the
+	// bake-in window before it.
+	Current, Previous analytics.Aggregate
+	// RolledBack is false in dry-run mode.
+	RolledBack bool
+}
+
+// Option configures a Watcher.
+type Option func(*Watcher)
+
+// WithBakeIn sets how long after its promotion a version is watched; default 30 minutes. The
+// previous version's runs in the same length of time before the promotion are the baseline.
+func WithBakeIn(d time.Duration) Option {
+	return func(w *Watcher) { w.bakeIn = d }
+}
+
+// WithIDs restricts the watcher to the given prompt ids (default: every id with a production version).
+func WithIDs(ids ...string) Option {
+	return func(w *Watcher) { w.ids = append([]string(nil), ids...) }
+}
+
+// DryRun makes the watcher report regressions (OnRegression) without rolling back.
+func DryRun() Option {
+	return func(w *Watcher) { w.dryRun = true }
+}
+
+// OnRegression registers a function called for each regression found, e.g. to send an alert.
+func OnRegression(fn func(ctx context.Context, e Event)) Option {
+	return func(w *Watcher) { w.onRegression = fn }
+}
+
+// OnError registers a function called with errors from Run, which does not stop on them. By
+// default they are dropped.
+func OnError(fn func(err error)) Option {
+	return func(w *Watcher) { w.onError = fn }
+}
+
+// WithActor sets the audit actor of rollbacks; default DefaultActor. Rollbacks and promotions by
+// this actor are not watched, so a rollback is never itself rolled back.
+func WithActor(actor string) Option {
+	return func(w *Watcher) { w.actor = actor }
+}
+
+// Watcher rolls back production promotions whose analytics regress. Create it with New.
+type Watcher struct {
+	reg          registry.Registry
+	store        analytics.Store
+	th           Thresholds
+	bakeIn       time.Duration
+	ids          []string
+	dryRun       bool
+	onRegression func(ctx context.Context, e Event)
+	onError      func(err error)
+	actor        string
+	now          func() time.Time
+
+	mu     sync.Mutex
+	judged map[string]bool // promotions already rolled back or reported, by id@version@time
+}
+
+// New returns a Watcher for the production promotions in reg, judged by the runs in store. reg must
+// keep an audit log (registry.Auditor), which records when and from which version each prompt was promoted.
+func New(reg registry.Registry, store analytics.Store, th Thresholds, opts ...Option) *Watcher {
+	w := &Watcher{
+		reg:     reg,
+		store:   store,
+		th:      th,
+		bakeIn:  30 * time.Minute,
+		actor:   DefaultActor,
+		onError: func(error) {},
+		now:     time.Now,
+		judged:  make(map[string]bool),
+	}
+	if w.th.MinRuns <= 0 {
+		w.th.MinRuns = 20
+	}
+	for _, o := range opts {
+		o(w)
+	}
+	return w
+}
+
+// Check examines each watched prompt once and returns the regressions found. A promotion is
+// judged once it has MinRuns runs and is reported at most once; versions past their bake-in
+// window, promoted by the watcher itself, or without an earlier production version are skipped.
+// Errors for one prompt do not stop the others; they are joined in the returned error.
+func (w *Watcher) Check(ctx context.Context) ([]Event, error) {
+	ids, err := w.watchedIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	var errs []error
+	for _, id := range ids {
+		e, err := w.checkID(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rollback %s: %w", id, err))
+			continue
+		}
+		if e != nil {
+			events = append(events, *e)
+		}
+	}
+	return events, errors.Join(errs...)
+}
+
+// Run checks immediately and then every interval until ctx is done. Errors are passed to the
+// OnError function and do not stop Run, which returns nil when ctx is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("rollback: interval must be positive")
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if _, err := w.Check(ctx); err != nil {
+			w.onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// watchedIDs returns the WithIDs ids, or every id with a production version.
+func (w *Watcher) watchedIDs(ctx context.Context) ([]string, error) {
+	if len(w.ids) > 0 {
+		return w.ids, nil
+	}
+	var ids []string
+	seen := make(map[string]bool)
+	filter := registry.Filter{Stage: registry.StageProduction, Limit: 1000}
+	for {
+		page, next, err := registry.ListPage(ctx, w.reg, filter)
+		if err != nil {
+			return nil, fmt.Errorf("rollback: %w", err)
+		}
+		for _, p := range page {
+			if !seen[p.ID] {
+				seen[p.ID] = true
+				ids = append(ids, p.ID)
+			}
+		}
+		if next == "" {
+			return ids, nil
+		}
+		filter.Cursor = next
+	}
+}
+
+// checkID judges id's latest production promotion, rolling it back if it regressed.
+func (w *Watcher) checkID(ctx context.Context, id string) (*Event, error) {
+	current, err := w.reg.GetProduction(ctx, id)
+	if errors.Is(err, core.ErrPromptNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	promotions, err := registry.Promotions(ctx, w.reg, id)
+	if err != nil || len(promotions) == 0 {
+		return nil, err
+	}
+	promo := promotions[0]
+	now := w.now()
+	if promo.Version != current.Version || promo.Action == registry.AuditRollback || promo.Actor == w.actor || now.Sub(promo.Time) > w.bakeIn {
+		return nil, nil
+	}
+	key := fmt.Sprintf("%s@%s@%d", id, promo.Version, promo.Time.UnixNano())
+	w.mu.Lock()
+	judged := w.judged[key]
+	w.mu.Unlock()
+	if judged {
+		return nil, nil
+	}
+	baseline, err := registry.PreviousProduction(ctx, w.reg, id)
+	if errors.Is(err, registry.ErrNoRollbackTarget) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cur, err := w.aggregate(ctx, id, promo.Version, promo.Time, now)
+	if err != nil || cur.Runs < w.th.MinRuns {
+		return nil, err
+	}
+	prev, err := w.aggregate(ctx, id, baseline, promo.Time.Add(-w.bakeIn), promo.Time)
+	if err != nil {
+		return nil, err
+	}
+	reason := w.regression(cur, prev)
+	if reason == "" {
+		return nil, nil
+	}
+	e := &Event{ID: id, Version: promo.Version, Baseline: baseline, PromotedAt: promo.Time, Reason: reason, Current: cur, Previous: prev}
+	if !w.dryRun {
+		if err := registry.RollbackProduction(registry.WithActor(ctx, w.actor), w.reg, id, promo.Version, baseline); err != nil {
+			return nil, err
+		}
+		e.RolledBack = true
+	}
+	w.mu.Lock()
+	w.judged[key] = true
+	w.mu.Unlock()
+	if w.onRegression != nil {
+		w.onRegression(ctx, *e)
+	}
+	return e, nil
+}
+
+// aggregate returns id@version's runs between from and to as one aggregate.
+func (w *Watcher) aggregate(ctx context.Context, id, version string, from, to time.Time) (analytics.Aggregate, error) {
+	aggs, err := w.store.Query(ctx, analytics.Query{PromptID: id, Version: version, From: from, To: to})
+	if err != nil {
+		return analytics.Aggregate{}, err
+	}
+	out := analytics.Aggregate{Key: id + "@" + version}
+	for _, a := range aggs {
+		if out.Runs+a.Runs > 0 {
+			out.AvgLatencyMs = (out.AvgLatencyMs*float64(out.Runs) + a.AvgLatencyMs*float64(a.Runs)) / float64(out.Runs+a.Runs)
+		}
+		out.Runs += a.Runs
+		out.SuccessCount += a.SuccessCount
+		out.TotalInputTokens += a.TotalInputTokens
+		out.TotalOutputTokens += a.TotalOutputTokens
+	}
+	return out, nil
+}
+
+// regression returns why cur regressed from prev, or "" if it is within the thresholds.
+func (w *Watcher) regression(cur, prev analytics.Aggregate) string {
+	if w.th.MaxErrorRateIncrease > 0 {
+		if c, p := errorRate(cur), errorRate(prev); c-p > w.th.MaxErrorRateIncrease {
+			return fmt.Sprintf("error rate %.1f%% (previous version %.1f%%)", c*100, p*100)
+		}
+	}
+	if w.th.MaxLatencyIncrease > 0 && prev.Runs > 0 && prev.AvgLatencyMs > 0 &&
+		cur.AvgLatencyMs > prev.AvgLatencyMs*(1+w.th.MaxLatencyIncrease) {
+		return fmt.Sprintf("average latency %.0fms (previous version %.0fms)", cur.AvgLatencyMs, prev.AvgLatencyMs)
+	}
+	return ""
+}
+
+// errorRate returns the share of a's runs that failed, 0 if it has none.
+func errorRate(a analytics.Aggregate) float64 {
+	if a.Runs == 0 {
+		return 0
+	}
+	return 1 - float64(a.SuccessCount)/float64(a.Runs)
+}
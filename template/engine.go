@@ -4,11 +4,18 @@ package template
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
+	"unicode"
 
 	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/cost"
+	"github.com/klejdi94/loom/fewshot"
 )
 
 // Engine renders prompt templates using Go text/template with custom functions.
@@ -16,12 +23,37 @@ type Engine struct {
 	leftDelim  string
 	rightDelim string
 	funcMap    template.FuncMap
+	embedder   fewshot.Embedder
+	embCache   *fewshot.EmbeddingCache
+	selector   fewshot.Selector
+	formatter  fewshot.Formatter
+	counter    cost.TokenCounter
+	syntax     core.TemplateSyntax
+	maxOutput  int
+	timeout    time.Duration
+	denied     map[string]bool
+	trimBlocks bool
+
+	preRender      []PreRenderHook
+	postProcessors []PostProcessor
+
+	mu       sync.Mutex
+	parsed   map[parseKey]*template.Template // see parse
+	partials map[string]*template.Template   // by name, see RegisterPartial
 }
 
+// maxParsed bounds the engine's cache of parsed templates; it is emptied when full.
+const maxParsed = 1024
+
+// maxIncludeDepth bounds nested includes, so a partial that includes itself fails instead of
+// looping.
+const maxIncludeDepth = 32
+
 // EngineOption configures the engine.
 type EngineOption func(*Engine)
 
-// WithDelims sets custom delimiters (default "{{" and "}}").
+// WithDelims sets the delimiters of prompts without their own under core.DelimsKey, in both
+// template syntaxes, and of partials (default "{{" and "}}").
 func WithDelims(left, right string) EngineOption {
 	return func(e *Engine) {
 		e.leftDelim = left
@@ -38,27 +70,166 @@ func WithFuncMap(fm template.FuncMap) EngineOption {
 	}
 }
 
-// NewEngine creates a new template engine with default or custom options.
+// WithEmbedder sets the embedder used by prompts whose example selection strategy is
+// "similarity" (see fewshot.Spec). Example embeddings are cached in memory.
+func WithEmbedder(emb fewshot.Embedder) EngineOption {
+	return func(e *Engine) {
+		e.embedder = emb
+		e.embCache = fewshot.NewEmbeddingCache(10000)
+	}
+}
+
+// WithExampleSelector sets the selector for prompts without a fewshot.Spec in their metadata
+// (default: all examples).
+func WithExampleSelector(s fewshot.Selector) EngineOption {
+	return func(e *Engine) {
+		e.selector = s
+	}
+}
+
+// WithExampleFormatter sets how examples are written for prompts with a placement under
+// fewshot.FormatKey (default fewshot.TextFormatter{}).
+func WithExampleFormatter(f fewshot.Formatter) EngineOption {
+	return func(e *Engine) {
+		e.formatter = f
+	}
+}
+
+// WithTokenCounter sets the counter used to enforce prompts' token budgets (see core.TokenBudget)
+// and examples' (see fewshot.Spec.MaxTokens), to fill in Rendered.Tokens, and by the truncateTokens and tailTokens template functions (default
+// cost.SimpleCounter).
+func WithTokenCounter(c cost.TokenCounter) EngineOption {
+	return func(e *Engine) {
+		e.counter = c
+	}
+}
+
+// WithSyntax sets the template syntax of prompts without one under core.SyntaxKey (default
+// core.SyntaxGo). With core.SyntaxMustache, every prompt the engine renders is logic-less unless it
+// opts into Go templates itself.
+func WithSyntax(syntax core.TemplateSyntax) EngineOption {
+	return func(e *Engine) {
+		e.syntax = syntax
+	}
+}
+
+// NewEngine creates a new template engine with default or custom options. Besides the functions
+// added with WithFuncMap, Go templates can use join, upper, lower, trim, default, json and
+// jsonIndent (real JSON, for structured data the model must read), dedent (see Dedent), include
+// (see RegisterPartial), and the token-aware truncateTokens and tailTokens, which keep the start
+// or the end of a long value at word boundaries:
+//
+//	{{truncateTokens .document 2000}}
+//	{{tailTokens .transcript 1000}}
+//
+// For optional sections, isset reports whether every named input is set, and anyset whether any
+// is: given (or defaulted) and not null, an empty string, or an empty list or object. Unlike
+// {{if .retries}}, they treat 0 and false as set, and Compile checks that the names are declared:
+//
+//	{{if isset "context"}}Context:
+//	{{.context}}
+//	{{end}}{{if anyset "email" "phone"}}Offer to follow up.{{end}}
 func NewEngine(opts ...EngineOption) *Engine {
 	e := &Engine{
 		leftDelim:  "{{",
 		rightDelim: "}}",
 		funcMap:    defaultFuncMap(),
+		formatter:  fewshot.TextFormatter{},
+		counter:    cost.SimpleCounter{},
+		syntax:     core.SyntaxGo,
 	}
+	e.funcMap["include"] = e.include(pass{})
+	e.funcMap["isset"] = issetFunc(nil)
+	e.funcMap["anyset"] = anysetFunc(nil)
+	e.funcMap["truncateTokens"] = e.truncateTokensFunc
+	e.funcMap["tailTokens"] = e.tailTokensFunc
 	for _, o := range opts {
 		o(e)
 	}
 	return e
 }
 
+// RegisterPartial defines (or replaces) the partial name, shared boilerplate such as a tone guide
+// or an output format block that any prompt rendered by e can insert with include:
+//
+//	engine.RegisterPartial("tone", "Be concise and friendly. Answer in {{default \"English\" .language}}.")
+//	prompt := loom.New("support").WithSystem("You are a support agent.\n{{include \"tone\"}}").Build(engine)
+//
+// {{include "name"}} renders the partial with the prompt's input (as the prompt itself sees it,
+// examples included), and {{include "name" .x}} with the given value instead. Partials may
+// include other partials. They belong to the engine, not to prompts, so editing one changes
+// every prompt that includes it without a new prompt version (and without changing response
+// cache keys).
+func (e *Engine) RegisterPartial(name, text string) error {
+	if e.trimBlocks {
+		text = trimBlocks(text, e.leftDelim, e.rightDelim)
+	}
+	t, err := template.New(name).Delims(e.leftDelim, e.rightDelim).Funcs(e.funcMap).Parse(text)
+	if err == nil {
+		err = e.checkFuncs(t)
+	}
+	if err != nil {
+		return fmt.Errorf("%w partial %s: %w", core.ErrRenderFailed, name, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.partials == nil {
+		e.partials = make(map[string]*template.Template)
+	}
+	e.partials[name] = t
+	return nil
+}
+
+// include returns the include template function for templates run in x.
+func (e *Engine) include(x pass) func(name string, data ...interface{}) (string, error) {
+	return func(name string, data ...interface{}) (string, error) {
+		if len(data) > 1 {
+			return "", fmt.Errorf("include %q: at most one value, got %d", name, len(data))
+		}
+		if x.depth >= maxIncludeDepth {
+			return "", fmt.Errorf("include %q: nested more than %d deep", name, maxIncludeDepth)
+		}
+		e.mu.Lock()
+		t, ok := e.partials[name]
+		e.mu.Unlock()
+		if !ok {
+			return "", fmt.Errorf("include %q: no such partial", name)
+		}
+		dot := x.root
+		if len(data) == 1 {
+			dot = data[0]
+		}
+		return e.run(t, dot, pass{root: x.root, depth: x.depth + 1, deadline: x.deadline})
+	}
+}
+
+// run executes t with dot in x, binding the functions that read the input (include, isset, and
+// anyset) to x if t calls them.
+func (e *Engine) run(t *template.Template, dot interface{}, x pass) (string, error) {
+	if readsInput(t) {
+		var err error
+		if t, err = t.Clone(); err != nil {
+			return "", err
+		}
+		t.Funcs(template.FuncMap{"include": e.include(x), "isset": issetFunc(x.root), "anyset": anysetFunc(x.root)})
+	}
+	out := &output{limit: e.maxOutput, deadline: x.deadline}
+	if err := t.Execute(out, dot); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
 func defaultFuncMap() template.FuncMap {
 	return template.FuncMap{
-		"join":    strings.Join,
-		"upper":   strings.ToUpper,
-		"lower":   strings.ToLower,
-		"trim":    strings.TrimSpace,
-		"default": defaultFunc,
-		"json":    jsonFunc,
+		"join":       strings.Join,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"default":    defaultFunc,
+		"json":       jsonFunc,
+		"jsonIndent": jsonIndentFunc,
+		"dedent":     Dedent,
 	}
 }
 
@@ -69,60 +240,634 @@ func defaultFunc(def, val interface{}) interface{} {
 	return val
 }
 
-func jsonFunc(v interface{}) string {
-	// Simple JSON-like representation for template debugging
-	return fmt.Sprint(v)
+// jsonFunc is the json template function: v encoded as compact JSON, with <, >, and & left as
+// they are since prompts are not HTML.
+func jsonFunc(v interface{}) (string, error) {
+	return encodeJSON(v, "")
+}
+
+// jsonIndentFunc is the jsonIndent template function: v encoded as JSON indented by two spaces.
+func jsonIndentFunc(v interface{}) (string, error) {
+	return encodeJSON(v, "  ")
 }
 
-// Render implements core.Renderer. It validates input, then renders system and template.
+// encodeJSON encodes v as JSON without HTML escaping, indented by indent if it is not empty.
+func encodeJSON(v interface{}, indent string) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return "", fmt.Errorf("json: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// Render implements core.Renderer. It validates input, coerces it (see core.Variable.Coerce),
+// selects the prompt's few-shot examples, then renders system, messages, and template as Go
+// templates, or as Mustache for prompts with core.SyntaxMustache (see WithSyntax). The
+// selected examples are available to all of them as .examples (unless the input has a value of
+// that name), e.g.
+//
+//	{{range .examples}}Input: {{.Input.text}}
+//	Output: {{.Output}}
+//	{{end}}
+//
+// or {{#examples}}...{{/examples}} in Mustache, where .Input.text is {{Input.text}}.
+//
+// A prompt with a placement under fewshot.FormatKey also gets them written into its system or user
+// message by the engine's fewshot.Formatter, without referring to .examples. A prompt with a
+// core.TokenBudget under core.TokenBudgetKey is shortened to fit it, or fails with
+// core.ErrTokenBudgetExceeded.
+//
+// Fields of object inputs are reached with dotted access, {{.user.profile.name}}; a missing or
+// null object on the way reads as empty rather than failing the render, so optional parts of
+// structured inputs can be given defaults: {{.user.profile.name | default "friend"}}.
+//
+// The input first passes through the engine's pre-render hooks, and every rendered message
+// through its post-processors (see WithPreRenderHooks and WithPostProcessors).
+//
+// A prompt with core.Locales under core.LocalesKey renders its variant for the input's
+// core.LocaleInputKey, or else for the context's locale (see core.WithLocale), falling back to its
+// own text.
 func (e *Engine) Render(ctx context.Context, p *core.Prompt, input core.Input) (*core.Rendered, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
 	}
+	input, err := e.preRenderInput(ctx, p, input)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", core.ErrRenderFailed, err)
+	}
 	if err := p.ValidateInput(input); err != nil {
 		return nil, fmt.Errorf("%w: %w", core.ErrValidationFailed, err)
 	}
-	// Apply defaults
+	p, err = p.Localize(core.LocaleOf(ctx, input))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", core.ErrRenderFailed, err)
+	}
+	// Apply defaults; null values count as missing, here and in objects (see withoutNulls).
 	data := make(map[string]interface{}, len(input))
 	for _, v := range p.Variables {
-		if val, ok := input[v.Name]; ok {
-			data[v.Name] = val
-		} else if v.Default != nil {
+		if v.Default != nil {
 			data[v.Name] = v.Default
 		}
 	}
 	for k, v := range input {
-		data[k] = v
+		if v != nil {
+			data[k] = withoutNulls(v)
+		}
 	}
-	system, err := e.execute(p.System, data)
+	for _, v := range p.Variables {
+		if val, ok := data[v.Name]; ok {
+			data[v.Name] = v.Coerce(val)
+		}
+	}
+	examples, err := e.selectExamples(ctx, p, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w examples: %w", core.ErrRenderFailed, err)
+	}
+	_, ownExamples := data["examples"]
+	render := func(examples []core.Example) (*core.Rendered, error) {
+		if !ownExamples && len(p.Examples) > 0 {
+			data["examples"] = examples
+		}
+		return e.render(p, data, examples)
+	}
+	rendered, err := render(examples)
+	if err != nil {
+		return nil, err
+	}
+	budget, err := core.TokenBudgetOf(p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", core.ErrRenderFailed, err)
+	}
+	if budget.MaxPromptTokens > 0 {
+		if rendered, err = e.fit(budget, data, rendered, render); err != nil {
+			return nil, err
+		}
+	}
+	rendered.Input = input
+	rendered.Tokens = e.tokenCounts(rendered)
+	for _, v := range p.Variables {
+		if v.Sensitive {
+			rendered.Sensitive = append(rendered.Sensitive, v.Name)
+		}
+	}
+	return rendered, nil
+}
+
+// render renders p's system prompt, messages, and template with data, writing examples into them
+// if p has a placement under fewshot.FormatKey.
+func (e *Engine) render(p *core.Prompt, data map[string]interface{}, examples []core.Example) (*core.Rendered, error) {
+	d, err := e.dialectOf(p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", core.ErrRenderFailed, err)
+	}
+	system, err := e.execute(d, p.System, data)
 	if err != nil {
 		return nil, fmt.Errorf("%w system: %w", core.ErrRenderFailed, err)
 	}
-	user, err := e.execute(p.Template, data)
+	turns, err := e.renderMessages(d, p.Messages, data)
+	if err != nil {
+		return nil, err
+	}
+	user, err := e.execute(d, p.Template, data)
 	if err != nil {
 		return nil, fmt.Errorf("%w template: %w", core.ErrRenderFailed, err)
 	}
+	placement, err := fewshot.PlacementOf(p)
+	if err != nil {
+		return nil, fmt.Errorf("%w examples: %w", core.ErrRenderFailed, err)
+	}
+	if placement != "" && len(examples) > 0 {
+		text := e.formatter.FormatExamples(examples)
+		if placement == fewshot.PlacementUser && p.Template != "" {
+			user = joinBlocks(text, user)
+		} else {
+			system = joinBlocks(system, text)
+		}
+	}
+	if system, err = e.postProcess(core.RoleSystem, system); err != nil {
+		return nil, fmt.Errorf("%w system: %w", core.ErrRenderFailed, err)
+	}
+	if user, err = e.postProcess(core.RoleUser, user); err != nil {
+		return nil, fmt.Errorf("%w template: %w", core.ErrRenderFailed, err)
+	}
+	messages := make([]core.Message, 0, len(turns)+2)
+	if system != "" {
+		messages = append(messages, core.Message{Role: core.RoleSystem, Content: system})
+	}
+	messages = append(messages, turns...)
+	if user != "" {
+		messages = append(messages, core.Message{Role: core.RoleUser, Content: user})
+	}
+	if attachments := attachmentsOf(p.Variables, data); len(attachments) > 0 {
+		messages = attach(messages, attachments)
+	}
+	if p.Template == "" {
+		for i := len(turns) - 1; i >= 0; i-- {
+			if turns[i].Role == core.RoleUser {
+				user = turns[i].Content
+				break
+			}
+		}
+	}
 	return &core.Rendered{
-		System: system,
-		User:   user,
-		Input:  input,
+		System:   system,
+		User:     user,
+		Messages: messages,
+		Tools:    append([]core.Tool(nil), p.Tools...),
+		Examples: examples,
 	}, nil
 }
 
-// execute parses and executes a single template string with data.
-func (e *Engine) execute(tpl string, data map[string]interface{}) (string, error) {
+// fit applies budget to rendered: while it is over budget.MaxPromptTokens it re-renders with
+// render, first with fewer examples, then with the Truncate variable in data shortened.
+func (e *Engine) fit(budget core.TokenBudget, data map[string]interface{}, rendered *core.Rendered, render func([]core.Example) (*core.Rendered, error)) (*core.Rendered, error) {
+	var err error
+	n := e.countTokens(rendered)
+	for budget.DropExamples && n > budget.MaxPromptTokens && len(rendered.Examples) > 0 {
+		if rendered, err = render(rendered.Examples[:len(rendered.Examples)-1]); err != nil {
+			return nil, err
+		}
+		n = e.countTokens(rendered)
+	}
+	if text, ok := data[budget.Truncate].(string); ok && budget.Truncate != "" {
+		// Shorten by the excess until the render fits; templates may repeat the variable, so the
+		// render can shrink by more than the variable does.
+		for n > budget.MaxPromptTokens && text != "" {
+			limit := e.counter.CountTokens(text) - (n - budget.MaxPromptTokens)
+			if limit < 0 {
+				limit = 0
+			}
+			text = headTokens(text, limit, e.counter)
+			data[budget.Truncate] = text
+			if rendered, err = render(rendered.Examples); err != nil {
+				return nil, err
+			}
+			n = e.countTokens(rendered)
+		}
+	}
+	if n > budget.MaxPromptTokens {
+		return nil, fmt.Errorf("%w: %d tokens, limit %d", core.ErrTokenBudgetExceeded, n, budget.MaxPromptTokens)
+	}
+	return rendered, nil
+}
+
+// countTokens counts the tokens of rendered's messages.
+func (e *Engine) countTokens(rendered *core.Rendered) int {
+	n := 0
+	for _, m := range rendered.Messages {
+		n += e.counter.CountTokens(m.Content)
+	}
+	return n
+}
+
+// tokenCounts counts the tokens of rendered's parts.
+func (e *Engine) tokenCounts(rendered *core.Rendered) core.TokenCounts {
+	counts := core.TokenCounts{
+		System: e.counter.CountTokens(rendered.System),
+		User:   e.counter.CountTokens(rendered.User),
+		Total:  e.countTokens(rendered),
+	}
+	if len(rendered.Examples) > 0 {
+		counts.Examples = e.counter.CountTokens(e.formatter.FormatExamples(rendered.Examples))
+	}
+	return counts
+}
+
+// headTokens returns the longest prefix of text ending at a word boundary that fits maxTokens.
+func headTokens(text string, maxTokens int, counter cost.TokenCounter) string {
+	if counter.CountTokens(text) <= maxTokens {
+		return text
+	}
+	_, ends := wordBounds(text)
+	n := sort.Search(len(ends), func(i int) bool {
+		return counter.CountTokens(text[:ends[i]]) > maxTokens
+	})
+	if n == 0 {
+		return ""
+	}
+	return text[:ends[n-1]]
+}
+
+// tailTokens returns the longest suffix of text starting at a word boundary that fits maxTokens.
+func tailTokens(text string, maxTokens int, counter cost.TokenCounter) string {
+	if counter.CountTokens(text) <= maxTokens {
+		return text
+	}
+	starts, _ := wordBounds(text)
+	n := sort.Search(len(starts), func(i int) bool {
+		return counter.CountTokens(text[starts[i]:]) <= maxTokens
+	})
+	if n == len(starts) {
+		return ""
+	}
+	return text[starts[n]:]
+}
+
+// wordBounds returns the byte offsets at which the words of text (separated by white space) start
+// and end.
+func wordBounds(text string) (starts, ends []int) {
+	inWord := false
+	for i, r := range text {
+		space := unicode.IsSpace(r)
+		switch {
+		case space && inWord:
+			ends = append(ends, i)
+		case !space && !inWord:
+			starts = append(starts, i)
+		}
+		inWord = !space
+	}
+	if inWord {
+		ends = append(ends, len(text))
+	}
+	return starts, ends
+}
+
+// truncateTokensFunc is the truncateTokens template function: v, formatted as it would be
+// inserted, cut after the last word that fits maxTokens by the engine's counter.
+func (e *Engine) truncateTokensFunc(v interface{}, maxTokens int) string {
+	if v == nil {
+		return ""
+	}
+	return headTokens(fmt.Sprint(v), maxTokens, e.counter)
+}
+
+// tailTokensFunc is the tailTokens template function: like truncateTokens, but keeps the end of v.
+func (e *Engine) tailTokensFunc(v interface{}, maxTokens int) string {
+	if v == nil {
+		return ""
+	}
+	return tailTokens(fmt.Sprint(v), maxTokens, e.counter)
+}
+
+// renderMessages renders the content of msgs and expands their history placeholders from data.
+// History turns are taken as given, not rendered.
+func (e *Engine) renderMessages(d dialect, msgs []core.Message, data map[string]interface{}) ([]core.Message, error) {
+	var out []core.Message
+	for i, m := range msgs {
+		if err := m.Validate(); err != nil {
+			return nil, fmt.Errorf("%w message %d: %w", core.ErrRenderFailed, i, err)
+		}
+		if m.History == "" {
+			content, err := e.execute(d, m.Content, data)
+			if err == nil {
+				content, err = e.postProcess(m.Role, content)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w message %d: %w", core.ErrRenderFailed, i, err)
+			}
+			out = append(out, core.Message{Role: m.Role, Content: content, Attachments: m.Attachments})
+			continue
+		}
+		turns, err := core.HistoryMessages(data[m.History])
+		if err != nil {
+			return nil, fmt.Errorf("%w: history %q: %w", core.ErrValidationFailed, m.History, err)
+		}
+		for j, turn := range turns {
+			if err := turn.Validate(); err != nil || turn.History != "" {
+				return nil, fmt.Errorf("%w: history %q turn %d: invalid role %q", core.ErrValidationFailed, m.History, j, turn.Role)
+			}
+			out = append(out, turn)
+		}
+	}
+	return out, nil
+}
+
+// withoutNulls returns v with null fields left out of the objects (maps with string keys, as
+// decoded from JSON) in it, at any depth, so that {{.user.profile.name | default "friend"}} on a
+// null profile reads as empty, as it does on a missing one, rather than failing. Maps and lists
+// are copied; v itself is not modified.
+func withoutNulls(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if val != nil {
+				out[k] = withoutNulls(val)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = withoutNulls(val)
+		}
+		return out
+	}
+	return v
+}
+
+// attachmentsOf returns the values of the image and file variables (and lists of them) in data,
+// in declaration order.
+func attachmentsOf(vars []core.Variable, data map[string]interface{}) []core.Attachment {
+	var out []core.Attachment
+	for _, v := range vars {
+		t := v.Type
+		if t == core.VariableTypeList {
+			t = v.Items
+		}
+		if t != core.VariableTypeImage && t != core.VariableTypeFile {
+			continue
+		}
+		switch val := data[v.Name].(type) {
+		case core.Attachment:
+			out = append(out, val)
+		case []core.Attachment:
+			out = append(out, val...)
+		}
+	}
+	return out
+}
+
+// attach adds attachments to the last user message, appending an empty one if there is none.
+func attach(messages []core.Message, attachments []core.Attachment) []core.Message {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == core.RoleUser {
+			m := &messages[i]
+			m.Attachments = append(append([]core.Attachment(nil), m.Attachments...), attachments...)
+			return messages
+		}
+	}
+	return append(messages, core.Message{Role: core.RoleUser, Attachments: attachments})
+}
+
+// joinBlocks joins the non-empty blocks with blank lines.
+func joinBlocks(blocks ...string) string {
+	var out []string
+	for _, b := range blocks {
+		if b != "" {
+			out = append(out, b)
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// selectExamples applies p's fewshot.Spec, or the engine's selector if it has none.
+func (e *Engine) selectExamples(ctx context.Context, p *core.Prompt, data map[string]interface{}) ([]core.Example, error) {
+	if len(p.Examples) == 0 {
+		return nil, nil
+	}
+	sel := e.selector
+	if _, ok := p.Metadata[fewshot.MetadataKey]; ok || sel == nil {
+		spec, err := fewshot.SpecOf(p)
+		if err != nil {
+			return nil, err
+		}
+		if sel, err = spec.Selector(e.embedder, e.embCache, e.counter); err != nil {
+			return nil, err
+		}
+	}
+	return sel.Select(ctx, core.Input(data), p.Examples)
+}
+
+// Compile parses p's system prompt, template, and messages, and those of its locale variants (see
+// core.Locales), ahead of rendering, and checks that its variables' validation rules are
+// registered and have valid arguments. Parsed templates are cached by the engine, so later renders
+// of p skip parsing; errors are returned as ErrRenderFailed.
+func (e *Engine) Compile(p *core.Prompt) error {
+	d, err := e.dialectOf(p)
+	if err != nil {
+		return fmt.Errorf("%w: %w", core.ErrRenderFailed, err)
+	}
+	locales, err := core.LocalesOf(p)
+	if err != nil {
+		return fmt.Errorf("%w: %w", core.ErrRenderFailed, err)
+	}
+	declared := declaredNames(p, locales)
+	if err := e.compileText("", d, declared, p.System, p.Template, p.Messages); err != nil {
+		return err
+	}
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		l := locales[tag]
+		if err := e.compileText("locale "+tag+" ", d, declared, l.System, l.Template, l.Messages); err != nil {
+			return err
+		}
+	}
+	for _, v := range p.Variables {
+		for _, r := range v.Rules {
+			if _, err := r.Compile(); err != nil {
+				return fmt.Errorf("%w variable %s: %w", core.ErrRenderFailed, v.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compileText parses a system prompt, template, and messages, whose input variables are declared;
+// where prefixes the part named in errors.
+func (e *Engine) compileText(where string, d dialect, declared map[string]bool, system, tpl string, messages []core.Message) error {
+	if err := e.check(d, declared, system); err != nil {
+		return fmt.Errorf("%w %ssystem: %w", core.ErrRenderFailed, where, err)
+	}
+	if err := e.check(d, declared, tpl); err != nil {
+		return fmt.Errorf("%w %stemplate: %w", core.ErrRenderFailed, where, err)
+	}
+	for i, m := range messages {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("%w %smessage %d: %w", core.ErrRenderFailed, where, i, err)
+		}
+		if err := e.check(d, declared, m.Content); err != nil {
+			return fmt.Errorf("%w %smessage %d: %w", core.ErrRenderFailed, where, i, err)
+		}
+	}
+	return nil
+}
+
+// dialect is the template syntax and delimiters a prompt is written in.
+type dialect struct {
+	syntax      core.TemplateSyntax
+	left, right string
+}
+
+// dialectOf returns p's template syntax and delimiters, or the engine's defaults where it has
+// none.
+func (e *Engine) dialectOf(p *core.Prompt) (dialect, error) {
+	d := dialect{syntax: e.syntax, left: e.leftDelim, right: e.rightDelim}
+	syntax, err := core.SyntaxOf(p)
+	if err != nil {
+		return d, err
+	}
+	if syntax != "" {
+		d.syntax = syntax
+	}
+	delims, err := core.DelimsOf(p)
+	if err != nil {
+		return d, err
+	}
+	if delims.Left != "" {
+		d.left, d.right = delims.Left, delims.Right
+	}
+	return d, nil
+}
+
+// check parses tpl in d, returning any syntax error or use of isset and anyset with a variable
+// that is not declared.
+func (e *Engine) check(d dialect, declared map[string]bool, tpl string) error {
+	if d.syntax == core.SyntaxMustache {
+		_, err := parseMustache(tpl, d.left, d.right)
+		return err
+	}
+	t, err := e.parse(d, tpl)
+	if err != nil {
+		return err
+	}
+	return checkSetNames(t, declared)
+}
+
+// declaredNames returns the names of p's input variables: its variables, the history
+// placeholders of its messages and of its locale variants', and examples if it has any.
+func declaredNames(p *core.Prompt, locales core.Locales) map[string]bool {
+	declared := make(map[string]bool, len(p.Variables)+1)
+	for _, v := range p.Variables {
+		declared[v.Name] = true
+	}
+	addHistory := func(msgs []core.Message) {
+		for _, m := range msgs {
+			if m.History != "" {
+				declared[m.History] = true
+			}
+		}
+	}
+	addHistory(p.Messages)
+	for _, l := range locales {
+		addHistory(l.Messages)
+	}
+	if len(p.Examples) > 0 {
+		declared["examples"] = true
+	}
+	return declared
+}
+
+// parseKey identifies a parsed Go template in the engine's cache.
+type parseKey struct {
+	left, right, text string
+}
+
+// parse returns the Go template tpl parsed with d's delimiters, from the cache if it was parsed
+// before.
+func (e *Engine) parse(d dialect, tpl string) (*template.Template, error) {
+	key := parseKey{d.left, d.right, tpl}
+	e.mu.Lock()
+	t, ok := e.parsed[key]
+	e.mu.Unlock()
+	if ok {
+		return t, nil
+	}
+	text := tpl
+	if e.trimBlocks {
+		text = trimBlocks(text, d.left, d.right)
+	}
+	t, err := template.New("").Delims(d.left, d.right).Funcs(e.funcMap).Parse(text)
+	if err == nil {
+		err = e.checkFuncs(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.parsed == nil || len(e.parsed) >= maxParsed {
+		e.parsed = make(map[parseKey]*template.Template)
+	}
+	e.parsed[key] = t
+	return t, nil
+}
+
+// execute executes a single template string in d with data, within the engine's
+// WithRenderTimeout. Mustache templates are parsed on each call, which is a single pass over the
+// text; Go templates are cached (see parse).
+func (e *Engine) execute(d dialect, tpl string, data map[string]interface{}) (string, error) {
 	if tpl == "" {
 		return "", nil
 	}
-	t, err := template.New("").Delims(e.leftDelim, e.rightDelim).Funcs(e.funcMap).Parse(tpl)
-	if err != nil {
-		return "", err
+	var run func(x pass) (string, error)
+	if d.syntax == core.SyntaxMustache {
+		nodes, err := parseMustache(tpl, d.left, d.right)
+		if err != nil {
+			return "", err
+		}
+		run = func(x pass) (string, error) {
+			out := &output{limit: e.maxOutput, deadline: x.deadline}
+			renderMustache(out, nodes, []interface{}{data})
+			return out.String(), out.err
+		}
+	} else {
+		t, err := e.parse(d, tpl)
+		if err != nil {
+			return "", err
+		}
+		run = func(x pass) (string, error) {
+			return e.run(t, data, x)
+		}
 	}
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, data); err != nil {
-		return "", err
+	if e.timeout <= 0 {
+		return run(pass{root: data})
+	}
+	type result struct {
+		text string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		text, err := run(pass{root: data, deadline: time.Now().Add(e.timeout)})
+		done <- result{text, err}
+	}()
+	timer := time.NewTimer(e.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.text, r.err
+	case <-timer.C:
+		return "", fmt.Errorf("%w after %v", ErrRenderTimeout, e.timeout)
 	}
-	return buf.String(), nil
 }
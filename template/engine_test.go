@@ -2,9 +2,14 @@ package template
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"text/template"
+	"time"
 
 	"github.com/klejdi94/loom/core"
+	"github.com/klejdi94/loom/fewshot"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,6 +28,16 @@ func TestEngine_Render(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "You are assistant.", rendered.System)
 	assert.Equal(t, "Hello, World!", rendered.User)
+	assert.Equal(t, []core.Message{
+		{Role: core.RoleSystem, Content: "You are assistant."},
+		{Role: core.RoleUser, Content: "Hello, World!"},
+	}, rendered.Messages)
+	assert.Empty(t, rendered.Tools)
+
+	p.Tools = []core.Tool{{Name: "lookup", Parameters: map[string]interface{}{"type": "object"}}}
+	rendered, err = eng.Render(context.Background(), p, core.Input{"role": "assistant", "name": "World"})
+	require.NoError(t, err)
+	assert.Equal(t, p.Tools, rendered.Tools)
 }
 
 func TestEngine_Render_ValidationFails(t *testing.T) {
@@ -50,3 +65,680 @@ func TestEngine_Render_Default(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "Hi Guest", rendered.User)
 }
+
+func TestEngine_Compile(t *testing.T) {
+	eng := NewEngine()
+	p := &core.Prompt{System: "You are {{.role}}.", Template: "Hi {{.name}}"}
+	require.NoError(t, eng.Compile(p))
+	assert.Len(t, eng.parsed, 2)
+	rendered, err := eng.Render(context.Background(), p, core.Input{"role": "terse", "name": "Ann"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Ann", rendered.User)
+	assert.Len(t, eng.parsed, 2, "renders reuse the compiled templates")
+
+	err = eng.Compile(&core.Prompt{Template: "Hi {{.name"})
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+
+	err = eng.Compile(&core.Prompt{Template: "Hi", Variables: []core.Variable{
+		{Name: "name", Rules: []core.ValidationRule{{Rule: "no_such_rule"}}},
+	}})
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+}
+
+func TestEngine_Render_Messages(t *testing.T) {
+	p := &core.Prompt{
+		System: "Classify {{.kind}}.",
+		Messages: []core.Message{
+			{Role: core.RoleUser, Content: "Classify: {{.sample}}"},
+			{Role: core.RoleAssistant, Content: "positive"},
+			{History: "history"},
+		},
+		Template: "Classify: {{.text}}",
+		Variables: []core.Variable{
+			{Name: "kind", Type: core.VariableTypeString, Default: "sentiment"},
+			{Name: "sample", Type: core.VariableTypeString, Default: "I love it"},
+		},
+	}
+	ctx := context.Background()
+	eng := NewEngine()
+	require.NoError(t, eng.Compile(p))
+	history := []interface{}{
+		map[string]interface{}{"role": "user", "content": "Classify: {{.kind}}"},
+		map[string]interface{}{"role": "assistant", "content": "neutral"},
+	}
+	rendered, err := eng.Render(ctx, p, core.Input{"text": "meh", "history": history})
+	require.NoError(t, err)
+	assert.Equal(t, []core.Message{
+		{Role: core.RoleSystem, Content: "Classify sentiment."},
+		{Role: core.RoleUser, Content: "Classify: I love it"},
+		{Role: core.RoleAssistant, Content: "positive"},
+		{Role: core.RoleUser, Content: "Classify: {{.kind}}"}, // history is not rendered
+		{Role: core.RoleAssistant, Content: "neutral"},
+		{Role: core.RoleUser, Content: "Classify: meh"},
+	}, rendered.Messages)
+	assert.Equal(t, "Classify: meh", rendered.User)
+
+	rendered, err = eng.Render(ctx, p, core.Input{"text": "meh"})
+	require.NoError(t, err)
+	assert.Len(t, rendered.Messages, 4, "missing history adds no turns")
+
+	_, err = eng.Render(ctx, p, core.Input{"text": "meh", "history": "hi"})
+	assert.ErrorIs(t, err, core.ErrValidationFailed)
+	_, err = eng.Render(ctx, p, core.Input{"text": "meh", "history": []core.Message{{Role: "bot", Content: "hi"}}})
+	assert.ErrorIs(t, err, core.ErrValidationFailed)
+
+	p.Template = ""
+	rendered, err = eng.Render(ctx, p, core.Input{})
+	require.NoError(t, err)
+	assert.Equal(t, "Classify: I love it", rendered.User, "last user message without a template")
+
+	err = eng.Compile(&core.Prompt{Messages: []core.Message{{Role: core.RoleUser, Content: "{{.x"}}})
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+	err = eng.Compile(&core.Prompt{Messages: []core.Message{{Content: "no role"}}})
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+}
+
+func TestEngine_Render_Coerce(t *testing.T) {
+	p := &core.Prompt{
+		Template: "{{.when.Format \"Jan 2\"}}: {{join .docs \"; \"}} ({{.tone}})",
+		Variables: []core.Variable{
+			{Name: "when", Type: core.VariableTypeTimestamp, Required: true},
+			{Name: "docs", Type: core.VariableTypeList, Items: core.VariableTypeString},
+			{Name: "tone", Type: core.VariableTypeEnum, Enum: []string{"formal", "casual"}, Default: "formal"},
+		},
+	}
+	input := core.Input{"when": "2026-03-01T12:00:00Z", "docs": []interface{}{"a", "b"}}
+	rendered, err := NewEngine().Render(context.Background(), p, input)
+	require.NoError(t, err)
+	assert.Equal(t, "Mar 1: a; b (formal)", rendered.User)
+	assert.Equal(t, "2026-03-01T12:00:00Z", rendered.Input["when"], "input is kept as given")
+
+	_, err = NewEngine().Render(context.Background(), p, core.Input{"when": "2026-03-01T12:00:00Z", "tone": "rude"})
+	assert.ErrorIs(t, err, core.ErrValidationFailed)
+}
+
+type fakeEmbedder map[string][]float32
+
+func (f fakeEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return f[text], nil
+}
+
+func TestEngine_Render_Examples(t *testing.T) {
+	p := &core.Prompt{
+		Template: "{{range .examples}}{{.Input.text}}={{.Output}};{{end}}{{.text}}",
+		Variables: []core.Variable{
+			{Name: "text", Type: core.VariableTypeString, Required: true},
+		},
+		Examples: []core.Example{
+			{Input: map[string]interface{}{"text": "great"}, Output: "positive", Weight: 1},
+			{Input: map[string]interface{}{"text": "awful"}, Output: "negative", Weight: 3},
+			{Input: map[string]interface{}{"text": "fine"}, Output: "neutral", Weight: 2},
+		},
+	}
+	ctx := context.Background()
+	rendered, err := NewEngine().Render(ctx, p, core.Input{"text": "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, "great=positive;awful=negative;fine=neutral;ok", rendered.User, "all examples by default")
+	assert.Len(t, rendered.Examples, 3)
+
+	p.Metadata = map[string]interface{}{fewshot.MetadataKey: fewshot.Spec{Strategy: fewshot.StrategyBudget, MaxTokens: 10}.Metadata()}
+	rendered, err = NewEngine().Render(ctx, p, core.Input{"text": "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, "awful=negative;fine=neutral;ok", rendered.User, "highest weights that fit")
+	rendered, err = NewEngine(WithTokenCounter(wordCounter{})).Render(ctx, p, core.Input{"text": "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, "awful=negative;fine=neutral;great=positive;ok", rendered.User, "counted with the engine's counter")
+
+	p.Metadata = map[string]interface{}{fewshot.MetadataKey: fewshot.Spec{Strategy: fewshot.StrategyTopK, K: 2}.Metadata()}
+	rendered, err = NewEngine().Render(ctx, p, core.Input{"text": "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, "awful=negative;fine=neutral;ok", rendered.User, "highest weights")
+
+	p.Metadata = map[string]interface{}{fewshot.MetadataKey: fewshot.Spec{Strategy: fewshot.StrategySimilarity, K: 1}.Metadata()}
+	_, err = NewEngine().Render(ctx, p, core.Input{"text": "ok"})
+	assert.ErrorIs(t, err, core.ErrRenderFailed, "similarity needs an embedder")
+	emb := fakeEmbedder{
+		"text: great\n": {1, 0}, "text: awful\n": {0, 1}, "text: fine\n": {1, 1},
+		"text: terrible\n": {0.1, 1},
+	}
+	rendered, err = NewEngine(WithEmbedder(emb)).Render(ctx, p, core.Input{"text": "terrible"})
+	require.NoError(t, err)
+	assert.Equal(t, "awful=negative;terrible", rendered.User)
+
+	p.Metadata = map[string]interface{}{fewshot.MetadataKey: fewshot.Spec{Strategy: fewshot.StrategyRandom, K: 2}.Metadata()}
+	rendered, err = NewEngine().Render(ctx, p, core.Input{"text": "ok"})
+	require.NoError(t, err)
+	assert.Len(t, rendered.Examples, 2)
+}
+
+func TestEngine_Render_Attachments(t *testing.T) {
+	p := &core.Prompt{
+		System:   "Describe images.",
+		Template: "What is in {{len .photos}} photos?",
+		Variables: []core.Variable{
+			{Name: "photos", Type: core.VariableTypeList, Items: core.VariableTypeImage, Required: true},
+			{Name: "doc", Type: core.VariableTypeFile},
+		},
+	}
+	rendered, err := NewEngine().Render(context.Background(), p, core.Input{
+		"photos": []interface{}{"https://example.com/a.png", "data:image/png;base64,aGk="},
+	})
+	require.NoError(t, err)
+	require.Len(t, rendered.Messages, 2)
+	assert.Equal(t, "What is in 2 photos?", rendered.Messages[1].Content)
+	assert.Equal(t, []core.Attachment{
+		{MIMEType: "image/png", URL: "https://example.com/a.png"},
+		{MIMEType: "image/png", Data: []byte("hi")},
+	}, rendered.Messages[1].Attachments)
+	assert.Empty(t, rendered.Messages[0].Attachments)
+
+	_, err = NewEngine().Render(context.Background(), p, core.Input{"photos": []interface{}{"a.png"}})
+	assert.ErrorIs(t, err, core.ErrValidationFailed)
+}
+
+func TestEngine_Render_TokenBudget(t *testing.T) {
+	p := &core.Prompt{
+		Template: "{{range .examples}}{{.Output}} {{end}}Context: {{.context}}",
+		Examples: []core.Example{
+			{Output: "one two three"},
+			{Output: "four five six"},
+		},
+		Metadata: map[string]interface{}{
+			core.TokenBudgetKey: core.TokenBudget{MaxPromptTokens: 9, DropExamples: true, Truncate: "context"}.Metadata(),
+		},
+	}
+	eng := NewEngine(WithTokenCounter(wordCounter{}))
+	ctx := context.Background()
+	rendered, err := eng.Render(ctx, p, core.Input{"context": "a b"})
+	require.NoError(t, err)
+	assert.Equal(t, "one two three four five six Context: a b", rendered.User, "fits without changes")
+
+	rendered, err = eng.Render(ctx, p, core.Input{"context": "a b c d"})
+	require.NoError(t, err)
+	assert.Equal(t, "one two three Context: a b c d", rendered.User, "examples dropped first")
+	assert.Len(t, rendered.Examples, 1)
+
+	rendered, err = eng.Render(ctx, p, core.Input{"context": "a b c d e f g h i j"})
+	require.NoError(t, err)
+	assert.Equal(t, "Context: a b c d e f g h", rendered.User, "then the variable trimmed")
+	assert.Equal(t, "a b c d e f g h i j", rendered.Input["context"])
+
+	p.Metadata[core.TokenBudgetKey] = core.TokenBudget{MaxPromptTokens: 8}.Metadata()
+	_, err = eng.Render(ctx, p, core.Input{"context": "a b"})
+	assert.ErrorIs(t, err, core.ErrTokenBudgetExceeded)
+}
+
+// wordCounter counts words as tokens.
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(text string) int { return len(strings.Fields(text)) }
+
+func TestEngine_Render_ExampleFormat(t *testing.T) {
+	p := &core.Prompt{
+		System:   "Classify sentiment.",
+		Template: "text: {{.text}}",
+		Examples: []core.Example{
+			{Input: map[string]interface{}{"text": "great"}, Output: "positive"},
+			{Input: map[string]interface{}{"text": "awful"}, Output: "negative"},
+		},
+		Metadata: map[string]interface{}{fewshot.FormatKey: fewshot.PlacementSystem},
+	}
+	ctx := context.Background()
+	rendered, err := NewEngine().Render(ctx, p, core.Input{"text": "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, "Classify sentiment.\n\nExamples:\n\ntext: great\nOutput: positive\n\ntext: awful\nOutput: negative", rendered.System)
+	assert.Equal(t, "text: ok", rendered.User)
+	assert.Equal(t, rendered.System, rendered.Messages[0].Content)
+
+	p.Metadata[fewshot.FormatKey] = fewshot.PlacementUser
+	short := fewshot.FormatterFunc(func(examples []core.Example) string {
+		return fmt.Sprintf("%d examples", len(examples))
+	})
+	rendered, err = NewEngine(WithExampleFormatter(short)).Render(ctx, p, core.Input{"text": "ok"})
+	require.NoError(t, err)
+	assert.Equal(t, "Classify sentiment.", rendered.System)
+	assert.Equal(t, "2 examples\n\ntext: ok", rendered.User)
+	assert.Equal(t, rendered.User, rendered.Messages[1].Content)
+
+	p.Metadata[fewshot.FormatKey] = "footer"
+	_, err = NewEngine().Render(ctx, p, core.Input{"text": "ok"})
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+}
+
+func TestEngine_Render_Locales(t *testing.T) {
+	p := &core.Prompt{
+		System:    "You help with {{.product}}.",
+		Template:  "Question: {{.q}}",
+		Variables: []core.Variable{{Name: "product", Type: core.VariableTypeString}, {Name: "q", Type: core.VariableTypeString}},
+		Metadata: map[string]interface{}{
+			core.LocalesKey: core.Locales{
+				"fr":    {System: "Vous aidez avec {{.product}}.", Template: "Question : {{.q}}"},
+				"pt-BR": {Template: "Pergunta: {{.q}}"},
+			}.Metadata(),
+		},
+	}
+	eng := NewEngine()
+	require.NoError(t, eng.Compile(p))
+	ctx := context.Background()
+
+	rendered, err := eng.Render(ctx, p, core.Input{"product": "Acme", "q": "?"})
+	require.NoError(t, err)
+	assert.Equal(t, "You help with Acme.", rendered.System)
+
+	rendered, err = eng.Render(ctx, p, core.Input{"product": "Acme", "q": "?", "locale": "fr-CA"})
+	require.NoError(t, err)
+	assert.Equal(t, "Vous aidez avec Acme.", rendered.System, "falls back to the language")
+	assert.Equal(t, "Question : ?", rendered.User)
+
+	rendered, err = eng.Render(core.WithLocale(ctx, "pt_br"), p, core.Input{"product": "Acme", "q": "?"})
+	require.NoError(t, err)
+	assert.Equal(t, "You help with Acme.", rendered.System, "empty fields keep the prompt's own")
+	assert.Equal(t, "Pergunta: ?", rendered.User)
+
+	rendered, err = eng.Render(core.WithLocale(ctx, "fr"), p, core.Input{"product": "Acme", "q": "?", "locale": "de"})
+	require.NoError(t, err)
+	assert.Equal(t, "Question: ?", rendered.User, "the input's locale wins; unknown locales use the default")
+
+	p.Metadata[core.LocalesKey] = core.Locales{"fr": {Template: "{{.q"}}.Metadata()
+	assert.ErrorIs(t, eng.Compile(p), core.ErrRenderFailed)
+}
+
+func TestEngine_Render_Tokens(t *testing.T) {
+	p := &core.Prompt{
+		System:   "Be brief.",
+		Template: "Summarize: {{.text}}",
+		Messages: []core.Message{{Role: core.RoleAssistant, Content: "Ready when you are"}},
+		Examples: []core.Example{{Input: map[string]interface{}{"text": "long"}, Output: "short"}},
+		Metadata: map[string]interface{}{fewshot.FormatKey: fewshot.PlacementSystem},
+	}
+	eng := NewEngine(WithTokenCounter(wordCounter{}), WithExampleFormatter(fewshot.FormatterFunc(func(examples []core.Example) string {
+		return "Example: " + examples[0].Output
+	})))
+	rendered, err := eng.Render(context.Background(), p, core.Input{"text": "a b c"})
+	require.NoError(t, err)
+	assert.Equal(t, core.TokenCounts{System: 4, User: 4, Examples: 2, Total: 12}, rendered.Tokens)
+}
+
+func TestEngine_Render_Sensitive(t *testing.T) {
+	p := &core.Prompt{
+		Template: "Reply to {{.email}} about {{.topic}}",
+		Variables: []core.Variable{
+			{Name: "email", Type: core.VariableTypeString, Sensitive: true},
+			{Name: "topic", Type: core.VariableTypeString},
+		},
+	}
+	rendered, err := NewEngine().Render(context.Background(), p, core.Input{"email": "ada@example.com", "topic": "billing"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"email"}, rendered.Sensitive)
+	assert.Equal(t, "Reply to ada@example.com about billing", rendered.User)
+	assert.Equal(t, "Reply to [REDACTED] about billing", rendered.RedactedUser())
+}
+
+func TestEngine_Render_Partials(t *testing.T) {
+	ctx := context.Background()
+	eng := NewEngine()
+	require.NoError(t, eng.RegisterPartial("tone", `Be friendly. Answer in {{default "English" .language}}.`))
+	require.NoError(t, eng.RegisterPartial("format", `Reply as JSON with {{join . ", "}}.`))
+	require.NoError(t, eng.RegisterPartial("footer", `{{include "tone"}} {{include "format" .fields}}`))
+	assert.ErrorIs(t, eng.RegisterPartial("bad", "{{.x"), core.ErrRenderFailed)
+
+	p := &core.Prompt{System: `You are a support agent. {{include "footer"}}`, Template: "{{.question}}"}
+	rendered, err := eng.Render(ctx, p, core.Input{"question": "Hi", "language": "French", "fields": []string{"answer", "sources"}})
+	require.NoError(t, err)
+	assert.Equal(t, "You are a support agent. Be friendly. Answer in French. Reply as JSON with answer, sources.", rendered.System)
+
+	require.NoError(t, eng.RegisterPartial("tone", "Be brief."))
+	rendered, err = eng.Render(ctx, p, core.Input{"question": "Hi", "fields": []string{"answer"}})
+	require.NoError(t, err)
+	assert.Equal(t, "You are a support agent. Be brief. Reply as JSON with answer.", rendered.System, "replaced partials apply to later renders")
+
+	_, err = eng.Render(ctx, &core.Prompt{Template: `{{include "missing"}}`}, nil)
+	require.ErrorIs(t, err, core.ErrRenderFailed)
+	assert.Contains(t, err.Error(), `include "missing": no such partial`)
+	require.NoError(t, eng.RegisterPartial("loop", `{{include "loop"}}`))
+	_, err = eng.Render(ctx, &core.Prompt{Template: `{{include "loop"}}`}, nil)
+	assert.ErrorContains(t, err, "nested more than 32 deep")
+	_, err = NewEngine().Render(ctx, &core.Prompt{Template: `{{include "tone"}}`}, nil)
+	assert.ErrorContains(t, err, "no such partial", "partials belong to the engine they were registered with")
+}
+
+func TestEngine_Render_Mustache(t *testing.T) {
+	ctx := context.Background()
+	eng := NewEngine()
+	p := &core.Prompt{
+		System: "You are {{role}} for {{company.name}}.",
+		Template: "Questions:\n" +
+			"{{#questions}}\n" +
+			"- {{text}} ({{company.name}})\n" +
+			"{{/questions}}\n" +
+			"{{^questions}}\n" +
+			"No questions.\n" +
+			"{{/questions}}\n" +
+			"{{! reviewers: keep this short }}\n" +
+			"{{#examples}}Q: {{Input.q}} A: {{Output}}{{/examples}} {{{raw}}} {{missing}}",
+		Examples: []core.Example{{Input: map[string]interface{}{"q": "2+2"}, Output: "4"}},
+		Metadata: map[string]interface{}{core.SyntaxKey: "mustache"},
+	}
+	input := core.Input{
+		"role":      "an assistant",
+		"company":   map[string]interface{}{"name": "Acme"},
+		"questions": []interface{}{map[string]interface{}{"text": "Where is my order?"}, map[string]interface{}{"text": "Can I pay by card?"}},
+		"raw":       "<b>",
+	}
+	rendered, err := eng.Render(ctx, p, input)
+	require.NoError(t, err)
+	assert.Equal(t, "You are an assistant for Acme.", rendered.System)
+	assert.Equal(t, "Questions:\n- Where is my order? (Acme)\n- Can I pay by card? (Acme)\nQ: 2+2 A: 4 <b> ", rendered.User)
+
+	input["questions"] = nil
+	rendered, err = eng.Render(ctx, p, input)
+	require.NoError(t, err)
+	assert.Equal(t, "Questions:\nNo questions.\nQ: 2+2 A: 4 <b> ", rendered.User)
+
+	for tpl, msg := range map[string]string{
+		"{{#a}}x":        "line 1: {{#a}} is not closed",
+		"{{#a}}\n{{/b}}": "line 2: {{/b}} closes {{#a}} from line 1",
+		"{{> footer}}":   "partials and delimiter changes are not supported",
+		"{{name":         "line 1: unclosed tag",
+		"x\n{{/a}}":      "line 2: {{/a}} closes no section",
+	} {
+		err := eng.Compile(&core.Prompt{Template: tpl, Metadata: map[string]interface{}{core.SyntaxKey: "mustache"}})
+		require.ErrorIs(t, err, core.ErrRenderFailed, tpl)
+		assert.Contains(t, err.Error(), msg, tpl)
+	}
+	assert.Error(t, eng.Compile(&core.Prompt{Template: "{{name}}"}), "Go templates have no function name")
+	assert.ErrorContains(t, eng.Compile(&core.Prompt{Metadata: map[string]interface{}{core.SyntaxKey: "jinja"}}), `unknown template syntax jinja`)
+
+	mustache := NewEngine(WithSyntax(core.SyntaxMustache))
+	rendered, err = mustache.Render(ctx, &core.Prompt{Template: "Hi {{name}}"}, core.Input{"name": "Ann"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Ann", rendered.User)
+	rendered, err = mustache.Render(ctx, &core.Prompt{Template: "Hi {{.name}}", Metadata: map[string]interface{}{core.SyntaxKey: "go"}}, core.Input{"name": "Ann"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Ann", rendered.User, "prompts can opt back into Go templates")
+
+	top, nested, err := MustacheNames("{{a.b}} {{#list}}{{x}}{{a}}{{/list}} {{^c}}{{.}}{{/c}}", "{{", "}}")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "list", "c"}, top)
+	assert.Equal(t, []string{"x", "a"}, nested)
+}
+
+func TestEngine_Render_TruncateTokens(t *testing.T) {
+	eng := NewEngine(WithTokenCounter(wordCounter{}))
+	p := &core.Prompt{Template: "{{truncateTokens .doc 3}}|{{tailTokens .doc 3}}|{{truncateTokens .short 3}}|{{tailTokens .missing 3}}"}
+	rendered, err := eng.Render(context.Background(), p, core.Input{"doc": "one two\nthree  four five", "short": 42})
+	require.NoError(t, err)
+	assert.Equal(t, "one two\nthree|three  four five|42|", rendered.User, "white space inside the kept part is preserved")
+}
+
+func TestEngine_Render_JSON(t *testing.T) {
+	eng := NewEngine()
+	p := &core.Prompt{Template: "{{json .order}}\n{{jsonIndent .order}}\n{{json .missing}} {{json .note}}"}
+	order := map[string]interface{}{"id": 7, "items": []string{"a&b", "<c>"}}
+	rendered, err := eng.Render(context.Background(), p, core.Input{"order": order, "note": `say "hi"`})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":7,"items":["a&b","<c>"]}`+"\n{\n  \"id\": 7,\n  \"items\": [\n    \"a&b\",\n    \"<c>\"\n  ]\n}\nnull \"say \\\"hi\\\"\"", rendered.User)
+
+	_, err = eng.Render(context.Background(), p, core.Input{"order": func() {}})
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+}
+
+func TestEngine_Render_Hooks(t *testing.T) {
+	ctx := context.Background()
+	var order []string
+	hook := func(name string) PreRenderHook {
+		return func(_ context.Context, _ *core.Prompt, input core.Input) (core.Input, error) {
+			order = append(order, name)
+			return input, nil
+		}
+	}
+	eng := NewEngine(
+		WithPreRenderHooks(hook("first"), TrimInput, hook("second")),
+		WithPostProcessors(StripPhrases("guaranteed"), NormalizeWhitespace, AppendFooter(core.RoleSystem, "Do not give legal advice.")),
+	)
+	p := &core.Prompt{
+		System:    "You are   a support agent.  \n\n\n\n{{if .vip}}Be extra polite.{{end}}\n",
+		Messages:  []core.Message{{Role: core.RoleAssistant, Content: "Results GUARANTEED!"}},
+		Template:  "  Question:  {{.question}}\n  Thanks",
+		Variables: []core.Variable{{Name: "question", Type: core.VariableTypeString, Required: true, MaxLen: 10}},
+	}
+	input := core.Input{"question": "  refund?  "}
+	rendered, err := eng.Render(ctx, p, input)
+	require.NoError(t, err, "trimmed before validation")
+	assert.Equal(t, []string{"first", "second"}, order)
+	assert.Equal(t, "You are a support agent.\n\nDo not give legal advice.", rendered.System)
+	assert.Equal(t, "Question: refund?\n  Thanks", rendered.User)
+	assert.Equal(t, []core.Message{
+		{Role: core.RoleSystem, Content: "You are a support agent.\n\nDo not give legal advice."},
+		{Role: core.RoleAssistant, Content: "Results !"},
+		{Role: core.RoleUser, Content: "Question: refund?\n  Thanks"},
+	}, rendered.Messages)
+	assert.Equal(t, "  refund?  ", input["question"], "hooks do not modify the caller's input")
+
+	failing := NewEngine(WithPostProcessors(func(role, text string) (string, error) { return "", fmt.Errorf("banned") }))
+	_, err = failing.Render(ctx, &core.Prompt{Template: "x"}, nil)
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+}
+
+func TestEngine_Render_Limits(t *testing.T) {
+	ctx := context.Background()
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = "item"
+	}
+	input := core.Input{"items": items}
+
+	t.Run("output size", func(t *testing.T) {
+		eng := NewEngine(WithMaxOutputSize(100))
+		_, err := eng.Render(ctx, &core.Prompt{Template: "{{range .items}}{{.}} {{end}}"}, input)
+		assert.ErrorIs(t, err, core.ErrRenderFailed)
+		assert.ErrorIs(t, err, ErrOutputTooLarge)
+		_, err = eng.Render(ctx, &core.Prompt{Template: "{{#items}}{{.}} {{/items}}", Metadata: map[string]interface{}{core.SyntaxKey: "mustache"}}, input)
+		assert.ErrorIs(t, err, ErrOutputTooLarge)
+		rendered, err := eng.Render(ctx, &core.Prompt{Template: "{{index .items 0}}"}, input)
+		require.NoError(t, err)
+		assert.Equal(t, "item", rendered.User)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		eng := NewEngine(
+			WithRenderTimeout(20*time.Millisecond),
+			WithFuncMap(template.FuncMap{"hang": func() string { <-release; return "" }}),
+		)
+		_, err := eng.Render(ctx, &core.Prompt{Template: "{{hang}}"}, nil)
+		assert.ErrorIs(t, err, core.ErrRenderFailed)
+		assert.ErrorIs(t, err, ErrRenderTimeout)
+		rendered, err := eng.Render(ctx, &core.Prompt{Template: "fast"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "fast", rendered.User)
+	})
+
+	t.Run("denied funcs", func(t *testing.T) {
+		eng := NewEngine(
+			WithFuncMap(template.FuncMap{"env": func(string) string { return "secret" }}),
+			WithDeniedFuncs("env", "call"),
+		)
+		err := eng.Compile(&core.Prompt{System: "ok", Template: "{{if .x}}{{env \"HOME\"}}{{end}}"})
+		assert.ErrorIs(t, err, core.ErrRenderFailed)
+		assert.ErrorContains(t, err, `function "env" is not allowed`)
+		_, err = eng.Render(ctx, &core.Prompt{Template: "{{call .f}}"}, core.Input{"f": func() string { return "" }})
+		assert.ErrorContains(t, err, `function "call" is not allowed`)
+		assert.ErrorContains(t, eng.RegisterPartial("p", "{{define \"x\"}}{{env \"HOME\"}}{{end}}"), `function "env" is not allowed`)
+		rendered, err := eng.Render(ctx, &core.Prompt{Template: "{{upper .x}}"}, core.Input{"x": "env"})
+		require.NoError(t, err)
+		assert.Equal(t, "ENV", rendered.User)
+	})
+}
+
+func TestEngine_Render_PromptDelims(t *testing.T) {
+	ctx := context.Background()
+	eng := NewEngine()
+	brackets := map[string]interface{}{core.DelimsKey: core.Delims{Left: "[[", Right: "]]"}.Metadata()}
+	p := &core.Prompt{
+		System:   "Answer as {{\"answer\": \"...\"}}.",
+		Template: "[[.question]] {{literal}}",
+		Metadata: brackets,
+	}
+	require.NoError(t, eng.Compile(p))
+	rendered, err := eng.Render(ctx, p, core.Input{"question": "Why?"})
+	require.NoError(t, err)
+	assert.Equal(t, "Answer as {{\"answer\": \"...\"}}.", rendered.System)
+	assert.Equal(t, "Why? {{literal}}", rendered.User)
+
+	// The same text with the engine's delimiters is parsed separately.
+	rendered, err = eng.Render(ctx, &core.Prompt{Template: "[[.question]] {{.question}}"}, core.Input{"question": "Why?"})
+	require.NoError(t, err)
+	assert.Equal(t, "[[.question]] Why?", rendered.User)
+
+	brackets[core.SyntaxKey] = "mustache"
+	rendered, err = eng.Render(ctx, &core.Prompt{Template: "[[#items]][[.]] {{x}} [[/items]]", Metadata: brackets}, core.Input{"items": []string{"a", "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, "a {{x}} b {{x}} ", rendered.User)
+
+	err = eng.Compile(&core.Prompt{Template: "x", Metadata: map[string]interface{}{core.DelimsKey: map[string]interface{}{"left": "[["}}})
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+}
+
+func TestEngine_Render_NestedFields(t *testing.T) {
+	ctx := context.Background()
+	eng := NewEngine()
+	user := core.Object(
+		core.WithField("profile", core.Object(core.WithField("name", core.String(core.Required())))),
+		core.WithField("since", core.Timestamp()),
+	)
+	user.Name = "user"
+	p := &core.Prompt{
+		Template:  `Hi {{.user.profile.name | default "friend"}}{{with .user.since}}, member since {{.Year}}{{end}}.`,
+		Variables: []core.Variable{user},
+	}
+	for _, tt := range []struct {
+		name string
+		user interface{}
+		want string
+	}{
+		{"missing", nil, "Hi friend."},
+		{"null profile", map[string]interface{}{"profile": nil}, "Hi friend."},
+		{"set", map[string]interface{}{"profile": map[string]interface{}{"name": "Ann"}, "since": "2024-05-01T00:00:00Z"}, "Hi Ann, member since 2024."},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered, err := eng.Render(ctx, p, core.Input{"user": tt.user})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rendered.User)
+		})
+	}
+	_, err := eng.Render(ctx, p, core.Input{"user": map[string]interface{}{"profile": map[string]interface{}{}}})
+	assert.ErrorIs(t, err, core.ErrValidationFailed)
+	assert.ErrorContains(t, err, "user.profile.name: required field is missing")
+}
+
+func TestEngine_Render_Isset(t *testing.T) {
+	ctx := context.Background()
+	eng := NewEngine()
+	require.NoError(t, eng.RegisterPartial("contact", `{{if anyset "email" "phone"}}Offer to follow up.{{end}}`))
+	p := &core.Prompt{
+		Template: "{{.question}}\n{{if isset \"context\"}}Context: {{.context}}\n{{end}}" +
+			"{{if isset \"retries\"}}Retries: {{.retries}}\n{{end}}{{range .tags}}{{if isset \"context\"}}#{{.}}{{end}}{{end}}{{include \"contact\"}}",
+		Variables: []core.Variable{
+			{Name: "question", Type: core.VariableTypeString},
+			{Name: "context", Type: core.VariableTypeString},
+			{Name: "retries", Type: core.VariableTypeInt},
+			{Name: "tags", Type: core.VariableTypeList},
+			{Name: "email", Type: core.VariableTypeString},
+			{Name: "phone", Type: core.VariableTypeString},
+		},
+	}
+	require.NoError(t, eng.Compile(p))
+	rendered, err := eng.Render(ctx, p, core.Input{"question": "Why?", "context": "", "retries": 0, "tags": []string{"a"}, "phone": "555"})
+	require.NoError(t, err)
+	assert.Equal(t, "Why?\nRetries: 0\nOffer to follow up.", rendered.User)
+	rendered, err = eng.Render(ctx, p, core.Input{"question": "Why?", "context": "docs", "tags": []string{"a"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Why?\nContext: docs\n#a", rendered.User)
+
+	err = eng.Compile(&core.Prompt{Template: `{{if isset "contxt"}}x{{end}}`, Variables: p.Variables})
+	assert.ErrorIs(t, err, core.ErrRenderFailed)
+	assert.ErrorContains(t, err, `isset: variable "contxt" is not declared`)
+	_, err = eng.Render(ctx, &core.Prompt{Template: `{{if isset}}x{{end}}`}, nil)
+	assert.ErrorContains(t, err, "isset: no variable names")
+}
+
+func TestEngine_Render_TrimBlocks(t *testing.T) {
+	ctx := context.Background()
+	tpl := Dedent(`
+		Rules:
+		  {{/* one per line */ -}}
+		{{range $i, $r := .rules}}
+		  {{if $i}}
+		  ---
+		  {{end}}
+		  - {{$r}}
+		{{else}}
+		  (none)
+		{{end}}
+		{{/* a comment */}}
+		Answer {{if .short}}briefly{{end}}.
+		{{include "sig"}}
+	`)
+	assert.True(t, strings.HasPrefix(tpl, "Rules:\n  {{/*"), "dedented: %q", tpl)
+	eng := NewEngine(WithTrimBlocks())
+	require.NoError(t, eng.RegisterPartial("sig", "{{if true}}\n-- {{.name}}\n{{end}}\n"))
+	p := &core.Prompt{Template: tpl}
+	rendered, err := eng.Render(ctx, p, core.Input{"rules": []string{"a", "b"}, "short": true, "name": "Bot"})
+	require.NoError(t, err)
+	assert.Equal(t, "Rules:\n  - a\n  ---\n  - b\nAnswer briefly.\n-- Bot\n", rendered.User)
+	rendered, err = eng.Render(ctx, p, core.Input{"name": "Bot"})
+	require.NoError(t, err)
+	assert.Equal(t, "Rules:\n  (none)\nAnswer .\n-- Bot\n", rendered.User)
+
+	rendered, err = NewEngine().Render(ctx, &core.Prompt{Template: "{{if .x}}\nyes\n{{end}}"}, core.Input{"x": true})
+	require.NoError(t, err)
+	assert.Equal(t, "\nyes\n", rendered.User, "off by default")
+
+	// Trimming keeps line numbers in errors.
+	_, err = eng.Render(ctx, &core.Prompt{Template: "{{if .x}}\n  {{end}}\n{{.y | nope}}"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ":3:")
+
+	// A delimiter in a string does not end the tag.
+	rendered, err = eng.Render(ctx, &core.Prompt{Template: "a\n  {{if eq .x \"}}\"}}\nb\n{{end}}\nc"}, core.Input{"x": "}}"})
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\nc", rendered.User)
+}
+
+func TestDedent(t *testing.T) {
+	assert.Equal(t, "a\n  b\n\nc", Dedent("\n    a\n      b\n  \n    c\n  "))
+	assert.Equal(t, "a\nb", Dedent("a\nb"))
+	assert.Equal(t, "", Dedent(" \n\t\n"))
+
+	rendered, err := NewEngine().Render(context.Background(), &core.Prompt{Template: "{{dedent .code}}"}, core.Input{"code": "\t\tif x {\n\t\t\treturn\n\t\t}"})
+	require.NoError(t, err)
+	assert.Equal(t, "if x {\n\treturn\n}", rendered.User)
+}
+
+func TestPreview(t *testing.T) {
+	ctx := context.Background()
+	vars := []core.Variable{{Name: "name", Type: core.VariableTypeString, Required: true}}
+	base := &core.Prompt{ID: "greet", Version: "1.0.0", System: "Be kind.", Template: "Hello, {{.name}}!\nHow are you?", Variables: vars}
+	edited := &core.Prompt{ID: "greet", Version: "1.1.0", System: "Be kind.", Template: "Hi, {{.name}}!\nHow are you?", Variables: vars}
+	samples := []core.Input{{"name": "Ann"}, {}}
+
+	report := Preview(ctx, edited, samples, CompareTo(base))
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, 1, report.Changed)
+	first := report.Results[0]
+	assert.Equal(t, "[system]\nBe kind.\n\n[user]\nHi, Ann!\nHow are you?", first.Text)
+	assert.Equal(t, "--- greet@1.0.0\n+++ greet@1.1.0\n@@ -2,5 +2,5 @@\n Be kind.\n \n [user]\n-Hello, Ann!\n+Hi, Ann!\n How are you?\n", first.Diff)
+	require.NotNil(t, first.Rendered)
+	second := report.Results[1]
+	assert.Equal(t, 1, second.Sample)
+	assert.Contains(t, second.Error, "required field is missing")
+	assert.Equal(t, second.Error, second.BaseError)
+	assert.Empty(t, second.Diff)
+
+	report = Preview(ctx, base, samples[:1])
+	assert.Zero(t, report.Changed)
+	assert.Empty(t, report.Results[0].Diff)
+}
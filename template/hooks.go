@@ -0,0 +1,115 @@
+package template
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// PreRenderHook runs before a prompt is validated and rendered and returns the input to render it
+// with, e.g. with values normalized or filled in. It must not modify input itself.
+type PreRenderHook func(ctx context.Context, p *core.Prompt, input core.Input) (core.Input, error)
+
+// PostProcessor rewrites the rendered text of one message: the system prompt (role
+// core.RoleSystem), the template (core.RoleUser), or one of the prompt's messages. History turns
+// are not rendered and are not post-processed; neither are empty messages.
+type PostProcessor func(role, text string) (string, error)
+
+// WithPreRenderHooks adds hooks run, in order, before every render.
+func WithPreRenderHooks(hooks ...PreRenderHook) EngineOption {
+	return func(e *Engine) {
+		e.preRender = append(e.preRender, hooks...)
+	}
+}
+
+// WithPostProcessors adds processors run, in order, on every rendered message. They run before
+// token budgets are applied and tokens counted, so both see the final text.
+func WithPostProcessors(procs ...PostProcessor) EngineOption {
+	return func(e *Engine) {
+		e.postProcessors = append(e.postProcessors, procs...)
+	}
+}
+
+// TrimInput is a PreRenderHook that trims leading and trailing white space from string inputs.
+func TrimInput(ctx context.Context, p *core.Prompt, input core.Input) (core.Input, error) {
+	out := make(core.Input, len(input))
+	for k, v := range input {
+		if s, ok := v.(string); ok {
+			v = strings.TrimSpace(s)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+var (
+	trailingSpace = regexp.MustCompile(`[ \t]+\n`)
+	blankLines    = regexp.MustCompile(`\n{3,}`)
+	innerSpace    = regexp.MustCompile(`([^\s])[ \t]{2,}`)
+)
+
+// NormalizeWhitespace is a PostProcessor that removes trailing spaces from lines, collapses runs of
+// spaces within lines and of blank lines to one, and trims the text, cleaning up after template
+// actions. Indentation at the start of lines is kept.
+func NormalizeWhitespace(role, text string) (string, error) {
+	text = trailingSpace.ReplaceAllString(text, "\n")
+	text = innerSpace.ReplaceAllString(text, "$1 ")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text), nil
+}
+
+// StripPhrases returns a PostProcessor that removes every occurrence of phrases, ignoring case,
+// e.g. to keep banned wording out of prompts assembled from user-supplied or shared text.
+func StripPhrases(phrases ...string) PostProcessor {
+	quoted := make([]string, 0, len(phrases))
+	for _, p := range phrases {
+		if p != "" {
+			quoted = append(quoted, regexp.QuoteMeta(p))
+		}
+	}
+	if len(quoted) == 0 {
+		return func(role, text string) (string, error) { return text, nil }
+	}
+	re := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	return func(role, text string) (string, error) {
+		return re.ReplaceAllString(text, ""), nil
+	}
+}
+
+// AppendFooter returns a PostProcessor that appends footer, after a blank line, to messages of
+// role, e.g. a compliance notice at the end of every system prompt.
+func AppendFooter(role, footer string) PostProcessor {
+	return func(r, text string) (string, error) {
+		if r != role {
+			return text, nil
+		}
+		return joinBlocks(text, footer), nil
+	}
+}
+
+// preRenderInput runs the engine's pre-render hooks on input.
+func (e *Engine) preRenderInput(ctx context.Context, p *core.Prompt, input core.Input) (core.Input, error) {
+	for _, h := range e.preRender {
+		var err error
+		if input, err = h(ctx, p, input); err != nil {
+			return nil, err
+		}
+	}
+	return input, nil
+}
+
+// postProcess runs the engine's post-processors on the rendered text of a message of role.
+func (e *Engine) postProcess(role, text string) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	for _, proc := range e.postProcessors {
+		var err error
+		if text, err = proc(role, text); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
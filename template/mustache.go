@@ -0,0 +1,266 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// mustacheNode is a parsed piece of a Mustache template: text, a variable, or a section.
+type mustacheNode struct {
+	kind  byte   // 0 for text, 'v' for a variable, '#' for a section, '^' for an inverted section
+	text  string // the text, or the variable or section name
+	nodes []mustacheNode
+}
+
+// parseMustache parses a Mustache template: {{name}} (also {{{name}}} and {{& name}}), sections
+// {{#name}}...{{/name}} and {{^name}}...{{/name}}, and comments {{! ...}}. Names may be dotted
+// (a.b) or "." for the current value. Section and comment tags alone on a line are removed with
+// their line, as the Mustache spec requires. Tags are delimited by left and right instead of "{{"
+// and "}}" if those are given; {{{name}}} is only recognized with the standard delimiters.
+// Partials and delimiter changes are not supported.
+func parseMustache(text, left, right string) ([]mustacheNode, error) {
+	type section struct {
+		node  mustacheNode
+		line  int
+		outer []mustacheNode
+	}
+	var nodes []mustacheNode
+	var open []section
+	pos, lastTagEnd := 0, 0
+	for {
+		i := strings.Index(text[pos:], left)
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		line := strings.Count(text[:start], "\n") + 1
+		closing, nameStart := right, start+len(left)
+		triple := left == "{{" && right == "}}" && strings.HasPrefix(text[start:], "{{{")
+		if triple {
+			closing, nameStart = "}}}", start+3
+		}
+		j := strings.Index(text[nameStart:], closing)
+		if j < 0 {
+			return nil, fmt.Errorf("mustache: line %d: unclosed tag", line)
+		}
+		end := nameStart + j + len(closing)
+		tag := strings.TrimSpace(text[nameStart : nameStart+j])
+		kind, name := byte('v'), tag
+		if triple {
+			kind = '&'
+		} else if tag != "" && strings.IndexByte("#^/!>&=", tag[0]) >= 0 {
+			kind, name = tag[0], strings.TrimSpace(tag[1:])
+		}
+		before := text[pos:start]
+		if strings.IndexByte("#^/!", kind) >= 0 {
+			if lineStart, lineEnd, ok := standalone(text, start, end, lastTagEnd); ok {
+				before, end = text[pos:lineStart], lineEnd
+			}
+		}
+		if before != "" {
+			nodes = append(nodes, mustacheNode{text: before})
+		}
+		pos, lastTagEnd = end, end
+		switch kind {
+		case '!':
+			continue
+		case '>', '=':
+			return nil, fmt.Errorf("mustache: line %d: %q: partials and delimiter changes are not supported", line, left+tag+right)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("mustache: line %d: empty tag", line)
+		}
+		switch kind {
+		case 'v', '&':
+			nodes = append(nodes, mustacheNode{kind: 'v', text: name})
+		case '#', '^':
+			open = append(open, section{node: mustacheNode{kind: kind, text: name}, line: line, outer: nodes})
+			nodes = nil
+		case '/':
+			if len(open) == 0 {
+				return nil, fmt.Errorf("mustache: line %d: %s/%s%s closes no section", line, left, name, right)
+			}
+			s := open[len(open)-1]
+			if s.node.text != name {
+				return nil, fmt.Errorf("mustache: line %d: %s/%s%s closes %s%c%s%s from line %d", line, left, name, right, left, s.node.kind, s.node.text, right, s.line)
+			}
+			open = open[:len(open)-1]
+			s.node.nodes = nodes
+			nodes = append(s.outer, s.node)
+		}
+	}
+	if pos < len(text) {
+		nodes = append(nodes, mustacheNode{text: text[pos:]})
+	}
+	if len(open) > 0 {
+		s := open[len(open)-1]
+		return nil, fmt.Errorf("mustache: line %d: %s%c%s%s is not closed", s.line, left, s.node.kind, s.node.text, right)
+	}
+	return nodes, nil
+}
+
+// standalone reports whether the tag at text[start:end] is alone on its line (apart from
+// whitespace and after any tag ending at lastTagEnd), and if so returns the start of its line and
+// the position after its line's newline.
+func standalone(text string, start, end, lastTagEnd int) (lineStart, lineEnd int, ok bool) {
+	lineStart = strings.LastIndexByte(text[:start], '\n') + 1
+	if lineStart < lastTagEnd || strings.TrimLeft(text[lineStart:start], " \t") != "" {
+		return 0, 0, false
+	}
+	rest, lineEnd := text[end:], len(text)
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		rest, lineEnd = rest[:i], end+i+1
+	}
+	if strings.TrimRight(rest, " \t\r") != "" {
+		return 0, 0, false
+	}
+	return lineStart, lineEnd, true
+}
+
+// renderMustache writes nodes rendered with the context stack (innermost last) to out, stopping
+// once a write fails. Values are written as fmt.Sprint formats them, without HTML escaping;
+// missing ones are empty.
+func renderMustache(out *output, nodes []mustacheNode, stack []interface{}) {
+	for _, n := range nodes {
+		if out.err != nil {
+			return
+		}
+		switch n.kind {
+		case 0:
+			io.WriteString(out, n.text)
+		case 'v':
+			if v := lookup(stack, n.text); v != nil {
+				fmt.Fprint(out, v)
+			}
+		case '#':
+			v := lookup(stack, n.text)
+			if !truthy(v) {
+				continue
+			}
+			stack := stack[:len(stack):len(stack)]
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+				for i := 0; i < rv.Len(); i++ {
+					renderMustache(out, n.nodes, append(stack, rv.Index(i).Interface()))
+				}
+				continue
+			}
+			renderMustache(out, n.nodes, append(stack, v))
+		case '^':
+			if !truthy(lookup(stack, n.text)) {
+				renderMustache(out, n.nodes, stack)
+			}
+		}
+	}
+}
+
+// lookup resolves name in the context stack: "." is the innermost value, and the first part of a
+// dotted name is looked up from the innermost value outwards.
+func lookup(stack []interface{}, name string) interface{} {
+	if len(stack) == 0 {
+		return nil
+	}
+	if name == "." {
+		return stack[len(stack)-1]
+	}
+	parts := strings.Split(name, ".")
+	for i := len(stack) - 1; i >= 0; i-- {
+		v, ok := field(stack[i], parts[0])
+		if !ok {
+			continue
+		}
+		for _, p := range parts[1:] {
+			v, _ = field(v, p)
+		}
+		return v
+	}
+	return nil
+}
+
+// field returns the value of key in a map with string keys or the exported field key of a struct.
+func field(v interface{}, key string) (interface{}, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		val, ok := m[key]
+		return val, ok
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		val := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+		if !val.IsValid() {
+			return nil, false
+		}
+		return val.Interface(), true
+	case reflect.Struct:
+		f, ok := rv.Type().FieldByName(key)
+		if !ok || !f.IsExported() {
+			return nil, false
+		}
+		return rv.FieldByIndex(f.Index).Interface(), true
+	}
+	return nil, false
+}
+
+// truthy reports whether a section renders for v: not nil, false, an empty string, or an empty
+// list.
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return rv.Len() > 0
+	case reflect.Pointer, reflect.Interface, reflect.Map:
+		return !rv.IsNil()
+	}
+	return true
+}
+
+// MustacheNames parses a Mustache template (see core.SyntaxMustache) and returns the names it
+// refers to, by the first part of dotted names and in order of first use: top-level ones, which
+// must be input variables, and ones inside sections, which may also be fields of a section's
+// value. Tags are delimited by left and right, usually "{{" and "}}" (see core.Delims).
+func MustacheNames(text, left, right string) (top, nested []string, err error) {
+	nodes, err := parseMustache(text, left, right)
+	if err != nil {
+		return nil, nil, err
+	}
+	seenTop, seenNested := make(map[string]bool), make(map[string]bool)
+	var walk func(nodes []mustacheNode, depth int)
+	walk = func(nodes []mustacheNode, depth int) {
+		for _, n := range nodes {
+			if n.kind == 0 {
+				continue
+			}
+			name, _, _ := strings.Cut(n.text, ".") // "" for ".", the current value
+			switch {
+			case name == "":
+			case depth == 0 && !seenTop[name]:
+				seenTop[name] = true
+				top = append(top, name)
+			case depth > 0 && !seenNested[name]:
+				seenNested[name] = true
+				nested = append(nested, name)
+			}
+			walk(n.nodes, depth+1)
+		}
+	}
+	walk(nodes, 0)
+	return top, nested, nil
+}
@@ -0,0 +1,235 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/klejdi94/loom/core"
+)
+
+// PreviewResult is a prompt rendered with one sample input by Preview.
+type PreviewResult struct {
+	// Sample is the index of the input in the samples passed to Preview.
+	Sample int `json:"sample"`
+	// Text is the render as a transcript: each message as its role in brackets, then its content.
+	Text string `json:"text,omitempty"`
+	// Error is why the sample did not render, if it did not.
+	Error string `json:"error,omitempty"`
+	// BaseError is why the sample did not render with the CompareTo prompt, if it did not.
+	BaseError string `json:"base_error,omitempty"`
+	// Diff is a unified diff from the CompareTo prompt's transcript to Text, or empty if they are
+	// the same or there is no base.
+	Diff string `json:"diff,omitempty"`
+	// Rendered is the render itself, nil if it failed.
+	Rendered *core.Rendered `json:"-"`
+}
+
+// PreviewReport is the result of Preview.
+type PreviewReport struct {
+	Results []PreviewResult `json:"results"`
+	// Errors counts the samples that did not render.
+	Errors int `json:"errors"`
+	// Changed counts the samples whose render differs from the CompareTo prompt's, including
+	// those that render with only one of the two.
+	Changed int `json:"changed"`
+}
+
+// PreviewOption configures Preview.
+type PreviewOption func(*previewConfig)
+
+type previewConfig struct {
+	base *core.Prompt
+}
+
+// CompareTo diffs each sample's render against base's, e.g. the stored version a template edit
+// replaces.
+func CompareTo(base *core.Prompt) PreviewOption {
+	return func(c *previewConfig) {
+		c.base = base
+	}
+}
+
+// Preview renders p with each of samples, so a template edit can be checked against typical
+// inputs before it is stored, by hand or in CI:
+//
+//	report := template.Preview(ctx, edited, samples, template.CompareTo(current))
+//	for _, r := range report.Results {
+//		fmt.Print(r.Error, r.Diff)
+//	}
+//
+// A sample that fails to render is reported in its result rather than stopping the preview.
+// Prompts are rendered with their renderer, or with NewEngine() if they have none.
+func Preview(ctx context.Context, p *core.Prompt, samples []core.Input, opts ...PreviewOption) *PreviewReport {
+	var c previewConfig
+	for _, o := range opts {
+		o(&c)
+	}
+	fallback := NewEngine()
+	report := &PreviewReport{Results: make([]PreviewResult, 0, len(samples))}
+	for i, input := range samples {
+		res := PreviewResult{Sample: i}
+		rendered, err := renderWith(ctx, fallback, p, input)
+		if err != nil {
+			res.Error = err.Error()
+			report.Errors++
+		} else {
+			res.Rendered, res.Text = rendered, transcript(rendered)
+		}
+		if c.base != nil {
+			var baseText string
+			base, err := renderWith(ctx, fallback, c.base, input)
+			if err != nil {
+				res.BaseError = err.Error()
+			} else {
+				baseText = transcript(base)
+			}
+			res.Diff = unifiedDiff(promptName(c.base), promptName(p), baseText, res.Text)
+			if res.Diff != "" || (res.Error == "") != (res.BaseError == "") {
+				report.Changed++
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// renderWith renders p with its renderer, or with eng if it has none.
+func renderWith(ctx context.Context, eng *Engine, p *core.Prompt, input core.Input) (*core.Rendered, error) {
+	rendered, err := p.Render(ctx, input)
+	if errors.Is(err, core.ErrNoRenderer) {
+		return eng.Render(ctx, p, input)
+	}
+	return rendered, err
+}
+
+// promptName returns "id@version", or "prompt" for a prompt without an id.
+func promptName(p *core.Prompt) string {
+	if p.ID == "" {
+		return "prompt"
+	}
+	return p.ID + "@" + p.Version
+}
+
+// transcript writes rendered's messages as text, each as "[role]" (with its attachments counted)
+// on a line of its own followed by its content.
+func transcript(rendered *core.Rendered) string {
+	var sb strings.Builder
+	for i, m := range rendered.Messages {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString("[" + m.Role)
+		if n := len(m.Attachments); n > 0 {
+			fmt.Fprintf(&sb, ", %d attachments", n)
+		}
+		sb.WriteString("]\n" + m.Content)
+	}
+	return sb.String()
+}
+
+// diffContext is the number of unchanged lines shown around changes in a diff.
+const diffContext = 3
+
+// diffLine is a line of a diff: kept (' '), removed ('-'), or added ('+'). a and b are the
+// numbers of the lines of the old and new text that come before it.
+type diffLine struct {
+	kind byte
+	text string
+	a, b int
+}
+
+// unifiedDiff returns a unified diff of the lines of from and to, labeled fromName and toName, or
+// "" if they are equal.
+func unifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	lines := diffLines(splitLines(from), splitLines(to))
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			i++
+			continue
+		}
+		// A hunk runs from diffContext lines before a change to diffContext lines after the last
+		// change that is not more than 2*diffContext unchanged lines from the one before it.
+		last := i
+		for j := i; j < len(lines) && j-last <= 2*diffContext; j++ {
+			if lines[j].kind != ' ' {
+				last = j
+			}
+		}
+		start, end := max(0, i-diffContext), min(len(lines), last+diffContext+1)
+		var fromCount, toCount int
+		for _, l := range lines[start:end] {
+			if l.kind != '+' {
+				fromCount++
+			}
+			if l.kind != '-' {
+				toCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(lines[start].a, fromCount), hunkRange(lines[start].b, toCount))
+		for _, l := range lines[start:end] {
+			sb.WriteByte(l.kind)
+			sb.WriteString(l.text)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkRange formats the lines of one side of a hunk that start after line before.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// splitLines returns the lines of text; the empty text has none.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines returns the shortest edit from a to b as a list of lines, by longest common
+// subsequence.
+func diffLines(a, b []string) []diffLine {
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	var out []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, diffLine{' ', a[i], i, j})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			out = append(out, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			out = append(out, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+var (
+	// ErrOutputTooLarge is returned (wrapped in core.ErrRenderFailed) when a template writes more
+	// than the engine's WithMaxOutputSize.
+	ErrOutputTooLarge = errors.New("template: output too large")
+	// ErrRenderTimeout is returned (wrapped in core.ErrRenderFailed) when a template runs longer
+	// than the engine's WithRenderTimeout.
+	ErrRenderTimeout = errors.New("template: render timed out")
+)
+
+// WithMaxOutputSize limits the text a single template may produce to n bytes: each of a prompt's
+// system prompt, template, and messages, and each partial it includes. Rendering stops as soon as
+// a template goes over, so a loop over a large input cannot exhaust memory. 0 (the default) means
+// no limit.
+func WithMaxOutputSize(n int) EngineOption {
+	return func(e *Engine) {
+		e.maxOutput = n
+	}
+}
+
+// WithRenderTimeout limits how long a single template (with the partials it includes) may run. A
+// render that takes longer fails with ErrRenderTimeout; the template itself stops at its next
+// write, so a loop that writes nothing and a custom function that never returns keep running in
+// the background until they finish. 0 (the default) means no limit.
+func WithRenderTimeout(d time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.timeout = d
+	}
+}
+
+// WithDeniedFuncs forbids templates from calling the named functions, whether added with
+// WithFuncMap, built in to the engine (such as include), or built in to Go templates (such as
+// call, which calls function values found in the input). Templates that call them fail to compile,
+// which keeps prompts from an untrusted registry away from functions meant for trusted code:
+//
+//	template.NewEngine(template.WithFuncMap(fm), template.WithDeniedFuncs("readFile", "call"))
+func WithDeniedFuncs(names ...string) EngineOption {
+	return func(e *Engine) {
+		if e.denied == nil {
+			e.denied = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			e.denied[name] = true
+		}
+	}
+}
+
+// pass is one execution of a template and the partials it includes.
+type pass struct {
+	root     interface{} // the render's input, which included partials see by default
+	depth    int         // how many includes deep
+	deadline time.Time   // zero without WithRenderTimeout
+}
+
+// output collects a template's text, failing writes beyond limit bytes (if positive) or after
+// deadline (if set). Once a write fails, all later writes fail the same way.
+type output struct {
+	buf      bytes.Buffer
+	limit    int
+	deadline time.Time
+	err      error
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	if !o.deadline.IsZero() && time.Now().After(o.deadline) {
+		o.err = ErrRenderTimeout
+		return 0, o.err
+	}
+	if o.limit > 0 && o.buf.Len()+len(p) > o.limit {
+		o.err = fmt.Errorf("%w: more than %d bytes", ErrOutputTooLarge, o.limit)
+		return 0, o.err
+	}
+	return o.buf.Write(p)
+}
+
+func (o *output) String() string {
+	return o.buf.String()
+}
+
+// checkFuncs returns an error if t, or a template it defines, calls a function the engine denies.
+func (e *Engine) checkFuncs(t *template.Template) error {
+	if len(e.denied) == 0 {
+		return nil
+	}
+	for _, d := range t.Templates() {
+		if d.Tree == nil || d.Tree.Root == nil {
+			continue
+		}
+		var denied *parse.IdentifierNode
+		inspect(d.Tree.Root, func(n parse.Node) bool {
+			if id, ok := n.(*parse.IdentifierNode); ok && e.denied[id.Ident] {
+				denied = id
+			}
+			return denied == nil
+		})
+		if denied != nil {
+			location, _ := d.Tree.ErrorContext(denied)
+			return fmt.Errorf("template: %s: function %q is not allowed", location, denied.Ident)
+		}
+	}
+	return nil
+}
+
+// inspect calls visit for n and the nodes under it, depth first, until visit returns false. It
+// reports whether the walk ran to the end.
+func inspect(n parse.Node, visit func(parse.Node) bool) bool {
+	if !visit(n) {
+		return false
+	}
+	var nodes []parse.Node
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			nodes = n.Nodes
+		}
+	case *parse.ActionNode:
+		nodes = []parse.Node{n.Pipe}
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			nodes = []parse.Node{n.Pipe}
+		}
+	case *parse.IfNode:
+		nodes = branchNodes(&n.BranchNode)
+	case *parse.RangeNode:
+		nodes = branchNodes(&n.BranchNode)
+	case *parse.WithNode:
+		nodes = branchNodes(&n.BranchNode)
+	case *parse.PipeNode:
+		if n != nil {
+			for _, c := range n.Cmds {
+				nodes = append(nodes, c)
+			}
+		}
+	case *parse.CommandNode:
+		nodes = n.Args
+	case *parse.ChainNode:
+		nodes = []parse.Node{n.Node}
+	}
+	for _, c := range nodes {
+		if !inspect(c, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+// branchNodes returns the pipeline and lists of an if, range, or with.
+func branchNodes(b *parse.BranchNode) []parse.Node {
+	nodes := []parse.Node{b.Pipe, b.List}
+	if b.ElseList != nil {
+		nodes = append(nodes, b.ElseList)
+	}
+	return nodes
+}
@@ -0,0 +1,114 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+)
+
+// inputFuncs are the template functions that read the render's input rather than their
+// arguments; run binds them to it.
+var inputFuncs = map[string]bool{"include": true, "isset": true, "anyset": true}
+
+// issetFunc returns the isset template function for a render with input root: whether every
+// named input is set (see isSet).
+func issetFunc(root interface{}) func(names ...string) (bool, error) {
+	return func(names ...string) (bool, error) {
+		if len(names) == 0 {
+			return false, fmt.Errorf("isset: no variable names")
+		}
+		for _, name := range names {
+			if !isSet(root, name) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+}
+
+// anysetFunc returns the anyset template function for a render with input root: whether any
+// named input is set (see isSet).
+func anysetFunc(root interface{}) func(names ...string) (bool, error) {
+	return func(names ...string) (bool, error) {
+		if len(names) == 0 {
+			return false, fmt.Errorf("anyset: no variable names")
+		}
+		for _, name := range names {
+			if isSet(root, name) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// isSet reports whether the input root has a value for name (given or defaulted) that is not
+// null, an empty string, or an empty list or object. Zero numbers and false are set.
+func isSet(root interface{}, name string) bool {
+	data, _ := root.(map[string]interface{})
+	v, ok := data[name]
+	if !ok || v == nil {
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() > 0
+	case reflect.Pointer, reflect.Interface:
+		return !rv.IsNil()
+	}
+	return true
+}
+
+// readsInput reports whether t, or a template it defines, calls one of inputFuncs.
+func readsInput(t *template.Template) bool {
+	for _, d := range t.Templates() {
+		if d.Tree == nil || d.Tree.Root == nil {
+			continue
+		}
+		found := false
+		inspect(d.Tree.Root, func(n parse.Node) bool {
+			id, ok := n.(*parse.IdentifierNode)
+			found = ok && inputFuncs[id.Ident]
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSetNames returns an error if t, or a template it defines, passes isset or anyset the name
+// of a variable that is not in declared.
+func checkSetNames(t *template.Template, declared map[string]bool) error {
+	for _, d := range t.Templates() {
+		if d.Tree == nil || d.Tree.Root == nil {
+			continue
+		}
+		var err error
+		inspect(d.Tree.Root, func(n parse.Node) bool {
+			cmd, ok := n.(*parse.CommandNode)
+			if !ok || len(cmd.Args) == 0 {
+				return true
+			}
+			fn, ok := cmd.Args[0].(*parse.IdentifierNode)
+			if !ok || (fn.Ident != "isset" && fn.Ident != "anyset") {
+				return true
+			}
+			for _, arg := range cmd.Args[1:] {
+				if s, ok := arg.(*parse.StringNode); ok && !declared[s.Text] {
+					location, _ := d.Tree.ErrorContext(s)
+					err = fmt.Errorf("template: %s: %s: variable %q is not declared", location, fn.Ident, s.Text)
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package template
+
+import (
+	"strings"
+	"unicode"
+)
+
+// WithTrimBlocks removes the lines of Go template block tags from the output: an if, else, end,
+// range, with, define, block, break, or continue action, or a comment, alone on its line (apart
+// from spaces and tabs) drops with the line's indentation and line break, as Mustache sections
+// do. Templates can then be laid out with one tag per line and indented bodies:
+//
+//	Answer the question.
+//	{{range .rules}}
+//	- {{.}}
+//	{{end}}
+//
+// renders each rule on a line of its own, without blank lines around the list. Partials are
+// trimmed too; Mustache templates always are. Errors still point at the template's own lines.
+func WithTrimBlocks() EngineOption {
+	return func(e *Engine) {
+		e.trimBlocks = true
+	}
+}
+
+// blockKeywords are the actions WithTrimBlocks removes the lines of.
+var blockKeywords = map[string]bool{
+	"if": true, "else": true, "end": true, "range": true, "with": true,
+	"define": true, "block": true, "break": true, "continue": true,
+}
+
+// trimBlocks returns the Go template text (delimited by left and right) with each standalone
+// block tag rewritten to produce no whitespace: its indentation and line break are moved inside
+// it (into the comment, for a comment), where they are ignored, so every line and column is
+// where it was. A tag that already trims a side with a trim marker is left as it is on that side.
+func trimBlocks(text, left, right string) string {
+	var sb strings.Builder
+	pos, lastTagEnd := 0, 0 // pos is how much of text is written
+	for {
+		i := strings.Index(text[lastTagEnd:], left)
+		if i < 0 {
+			break
+		}
+		start := lastTagEnd + i
+		end := actionEnd(text, start+len(left), right)
+		if end < 0 {
+			break
+		}
+		inner := text[start+len(left) : end-len(right)]
+		lineStart, lineEnd, ok := standalone(text, start, end, lastTagEnd)
+		lastTagEnd = end
+		// Trim markers are "- " after the left delimiter and " -" before the right one.
+		trimLeft := len(inner) >= 2 && inner[0] == '-' && isSpace(inner[1])
+		trimRight := strings.HasSuffix(inner, " -")
+		body := inner
+		if trimLeft {
+			body = inner[2:]
+		}
+		body = strings.TrimLeft(body, " \t\r\n")
+		comment := strings.HasPrefix(body, "/*")
+		if !ok || !(comment || blockKeywords[keyword(body)]) {
+			continue
+		}
+		// The indentation goes after the left delimiter (and its trim marker), the line break
+		// before the right one; comments must start and end right at the delimiters, so they go
+		// inside the comment instead.
+		indent, trailing := text[lineStart:start], text[end:lineEnd]
+		at, before := 0, len(inner)
+		switch {
+		case comment:
+			at, before = strings.Index(inner, "/*")+2, strings.LastIndex(inner, "*/")
+		case trimLeft:
+			at = 2
+		}
+		if trimRight && !comment {
+			before -= 2
+		}
+		sb.WriteString(text[pos:lineStart])
+		if trimLeft {
+			sb.WriteString(indent) // trimmed by the marker
+			indent = ""
+		}
+		if trimRight {
+			trailing = "" // trimmed by the marker, below
+		}
+		sb.WriteString(left + inner[:at] + indent + inner[at:before] + trailing + inner[before:] + right)
+		if trimRight {
+			sb.WriteString(text[end:lineEnd])
+		}
+		pos, lastTagEnd = lineEnd, lineEnd
+	}
+	if pos == 0 {
+		return text
+	}
+	sb.WriteString(text[pos:])
+	return sb.String()
+}
+
+// actionEnd returns the position after the right delimiter that closes the action whose text
+// starts at from, skipping over quoted strings and comments, or -1 if it is not closed.
+func actionEnd(text string, from int, right string) int {
+	for i := from; i < len(text); i++ {
+		if strings.HasPrefix(text[i:], right) {
+			return i + len(right)
+		}
+		var closing string
+		switch text[i] {
+		case '"', '\'':
+			closing = text[i : i+1]
+		case '`':
+			closing = "`"
+		case '/':
+			if strings.HasPrefix(text[i:], "/*") {
+				closing = "*/"
+			}
+		}
+		if closing == "" {
+			continue
+		}
+		for i += len(closing); i < len(text) && !strings.HasPrefix(text[i:], closing); i++ {
+			if text[i] == '\\' && closing != "`" && closing != "*/" {
+				i++
+			}
+		}
+		i += len(closing) - 1
+	}
+	return -1
+}
+
+// keyword returns the identifier an action's text starts with.
+func keyword(action string) string {
+	end := strings.IndexFunc(action, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		return action
+	}
+	return action[:end]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// Dedent removes the indentation common to the lines of text that are not blank, empties lines
+// that hold only spaces and tabs, and drops blank lines at the start and end, so a template
+// written as an indented raw string in Go code reads as it looks:
+//
+//	prompt := loom.New("summarize").WithTemplate(template.Dedent(`
+//		Summarize the text below.
+//		{{.text}}
+//	`))
+//
+// It is also the dedent template function, e.g. for an indented partial or input:
+// {{include "format" | dedent}}.
+func Dedent(text string) string {
+	lines := strings.Split(text, "\n")
+	var margin string
+	first := true
+	for i, line := range lines {
+		if strings.TrimLeft(line, " \t\r") == "" {
+			lines[i] = ""
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			margin, first = indent, false
+			continue
+		}
+		n := 0
+		for n < len(margin) && n < len(indent) && margin[n] == indent[n] {
+			n++
+		}
+		margin = margin[:n]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, margin)
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}